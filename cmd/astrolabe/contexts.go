@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ammarlakis/astrolabe/pkg/api"
+	"github.com/ammarlakis/astrolabe/pkg/config"
+	"github.com/ammarlakis/astrolabe/pkg/federation"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/informers"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// Besides the cluster of its primary kubeconfig context, Astrolabe can watch the clusters of
+// other contexts of the same kubeconfig. Each is watched into a graph of its own, served
+// in-process over gRPC to the federator, which merges it into the graph under the context name
+// like the graph of a federated instance.
+
+// contextBufferSize is the buffer of the in-process connections to the graphs of contexts
+const contextBufferSize = 1 << 20
+
+// kubeconfigPath returns the kubeconfig file: --kubeconfig, $KUBECONFIG or ~/.kube/config
+func kubeconfigPath() (string, error) {
+	if kubeconfig != "" {
+		return kubeconfig, nil
+	}
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return homeDir + "/.kube/config", nil
+}
+
+// kubeContexts returns the kubeconfig contexts to watch, the primary one first: those listed
+// by --context, then with --kubeconfig-context-all every other context of the kubeconfig. It
+// returns none when neither is set, so the in-cluster config or the current context is used.
+func kubeContexts() ([]string, error) {
+	contexts := splitList(kubeContext)
+	if !kubeContextAll {
+		return contexts, nil
+	}
+
+	path, err := kubeconfigPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if len(contexts) == 0 {
+		if raw.CurrentContext == "" {
+			return nil, fmt.Errorf("kubeconfig %s has no current context, set --context", path)
+		}
+		contexts = append(contexts, raw.CurrentContext)
+	}
+	listed := make(map[string]bool, len(contexts))
+	for _, name := range contexts {
+		listed[name] = true
+	}
+	others := make([]string, 0, len(raw.Contexts))
+	for name := range raw.Contexts {
+		if !listed[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(contexts, others...), nil
+}
+
+// contextConfig returns the client config of a kubeconfig context ("" for the current one),
+// and the name of the context
+func contextConfig(name string) (*rest.Config, string, error) {
+	path, err := kubeconfigPath()
+	if err != nil {
+		return nil, "", err
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{CurrentContext: name},
+	)
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		if name == "" {
+			return nil, "", fmt.Errorf("failed to build config from kubeconfig: %w", err)
+		}
+		return nil, "", fmt.Errorf("failed to build config of context %q from kubeconfig: %w", name, err)
+	}
+	if name == "" {
+		if raw, err := clientConfig.RawConfig(); err == nil {
+			name = raw.CurrentContext
+		}
+	}
+	return restConfig, name, nil
+}
+
+// contextClusterName returns the name a context is merged under. Federated cluster names must
+// not contain / or :, which context names often do (e.g. the ARN of an EKS cluster).
+func contextClusterName(name string) string {
+	return strings.NewReplacer("/", "-", ":", "-").Replace(name)
+}
+
+// contextWatcher watches the cluster of a kubeconfig context other than the primary one into
+// its own graph, which the federator reads over an in-process connection
+type contextWatcher struct {
+	context      string
+	config       *rest.Config
+	graph        *graph.Graph
+	options      informers.Options
+	enricher     processors.NodeEnricher
+	deprecations []config.DeprecatedAPI
+	listener     *bufconn.Listener
+	server       *api.GRPCServer
+
+	// manager is the informer manager of the current run, reconfigured on reload
+	mu      sync.Mutex
+	manager *informers.Manager
+}
+
+// newContextWatcher creates the watcher of a context. The informers and processors are
+// configured like those of the primary context, except for lazy namespaces and the
+// subsystems bound to the primary cluster (timeline, observers, audit log, Job pruner).
+func newContextWatcher(name string, options informers.Options, enricher processors.NodeEnricher, deprecations []config.DeprecatedAPI) (*contextWatcher, error) {
+	restConfig, _, err := contextConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	g := graph.NewGraph()
+	options.LazyNamespaces = false
+	options.NamespacePatterns = nil
+	options.Processors.Observers = nil
+	options.Processors.Timeline = nil
+	options.Processors.Audit = nil
+	options.Processors.PrunedJobs = nil
+	return &contextWatcher{
+		context:      name,
+		config:       restConfig,
+		graph:        g,
+		options:      options,
+		enricher:     enricher,
+		deprecations: deprecations,
+		listener:     bufconn.Listen(contextBufferSize),
+		server:       api.NewGRPCServer(api.NewServer(g, 0, api.DefaultOptions()), 0, grpcWatchInterval),
+	}, nil
+}
+
+// cluster returns the federated cluster reading the graph of the context
+func (w *contextWatcher) cluster() federation.Cluster {
+	return federation.Cluster{
+		Name:    contextClusterName(w.context),
+		Address: "passthrough:///" + contextClusterName(w.context),
+		Dialer: func(ctx context.Context, _ string) (net.Conn, error) {
+			return w.listener.DialContext(ctx)
+		},
+	}
+}
+
+// serve serves the graph of the context on the in-process listener until ctx is cancelled
+func (w *contextWatcher) serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		w.server.Stop()
+	}()
+	if err := w.server.Serve(w.listener); err != nil && ctx.Err() == nil {
+		klog.Errorf("Context %s: in-process gRPC server failed: %v", w.context, err)
+	}
+}
+
+// start connects to the cluster of the context and runs its informers until ctx is cancelled
+func (w *contextWatcher) start(ctx context.Context) error {
+	clientset, err := kubernetes.NewForConfig(w.config)
+	if err != nil {
+		return fmt.Errorf("failed to create clientset of context %q: %w", w.context, err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(w.config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client of context %q: %w", w.context, err)
+	}
+	serverVersion, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the cluster of context %q: %w", w.context, err)
+	}
+	klog.Infof("Context %s: connected to Kubernetes cluster version %s", w.context, serverVersion.GitVersion)
+
+	w.mu.Lock()
+	options := w.options
+	options.DynamicClient = dynamicClient
+	options.Processors.Enrichers = []processors.NodeEnricher{w.enricher}
+	table, err := newDeprecationTable(serverVersion.GitVersion, w.deprecations)
+	if err != nil {
+		w.mu.Unlock()
+		return fmt.Errorf("invalid deprecations: %w", err)
+	}
+	options.Processors.Enrichers = append(options.Processors.Enrichers, table)
+	manager := informers.NewManager(clientset, w.graph, options)
+	w.manager = manager
+	w.mu.Unlock()
+	return manager.Start(ctx)
+}
+
+// reconfigure applies new filters to the informers of the context, now or when they start
+func (w *contextWatcher) reconfigure(filters informers.Filters) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.options.LabelSelector = filters.LabelSelector
+	w.options.LabelSelectors = filters.LabelSelectors
+	w.options.Namespaces = filters.Namespaces
+	w.options.Processors.Kinds = filters.Kinds
+	if w.manager == nil {
+		return nil
+	}
+	return w.manager.Reconfigure(filters)
+}
@@ -2,45 +2,250 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ammarlakis/astrolabe/pkg/actions"
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"github.com/ammarlakis/astrolabe/pkg/anomalies"
 	"github.com/ammarlakis/astrolabe/pkg/api"
+	"github.com/ammarlakis/astrolabe/pkg/audit"
+	"github.com/ammarlakis/astrolabe/pkg/config"
+	"github.com/ammarlakis/astrolabe/pkg/deprecations"
+	"github.com/ammarlakis/astrolabe/pkg/events"
+	"github.com/ammarlakis/astrolabe/pkg/export"
+	"github.com/ammarlakis/astrolabe/pkg/federation"
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
 	"github.com/ammarlakis/astrolabe/pkg/informers"
+	"github.com/ammarlakis/astrolabe/pkg/logsampler"
+	"github.com/ammarlakis/astrolabe/pkg/manifest"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/notify"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"github.com/ammarlakis/astrolabe/pkg/releasemetrics"
 	"github.com/ammarlakis/astrolabe/pkg/storage"
+	"github.com/ammarlakis/astrolabe/pkg/supervisor"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+	"github.com/ammarlakis/astrolabe/pkg/tombstones"
+	"github.com/ammarlakis/astrolabe/pkg/tracing"
+	"github.com/ammarlakis/astrolabe/pkg/uninstalls"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 )
 
 var (
+	configFile        string
 	kubeconfig        string
 	port              int
 	labelSelector     string
+	watchKinds        string
+	excludeKinds      string
+	cascadeDelete     string
+	idStrategy        string
+	clusterName       string
+	namespaces        string
+	lazyNamespaces    bool
+	namespacePatterns string
 	inCluster         bool
 	enablePersistence bool
 	redisAddr         string
 	redisPassword     string
 	redisDB           int
 	snapshotInterval  int
+
+	readSnapshotInterval time.Duration
+
+	edgeStaleResyncs int
+	edgeStaleAction  string
+
+	pruneStaleNodes string
+
+	keepInactiveReplicaSets int
+
+	keepSucceededJobs int
+	keepFailedJobs    int
+	jobPruneInterval  time.Duration
+
+	eventRateLimit int
+	eventBurst     int
+	dependentRate  int
+	eventWorkers   string
+
+	discoveryTTL time.Duration
+
+	consistencyCheckInterval time.Duration
+	consistencyRepair        bool
+
+	analysisInterval time.Duration
+
+	deprecationTargetVersion string
+
+	timelineSize             int
+	restartCorrelationWindow time.Duration
+	uninstallGracePeriod     time.Duration
+	anomalyWindow            time.Duration
+
+	tombstoneRetention time.Duration
+
+	snapshotVerify           string
+	persistenceBatchSize     int
+	persistenceFlushInterval time.Duration
+	persistenceMode          string
+	snapshotFormat           string
+	persistenceEnqueueWait   time.Duration
+	persistenceMaxOverflow   int
+	storageCompactInterval   time.Duration
+	orphanedKeyTTL           time.Duration
+
+	dumpDir string
+
+	apiOptions          = api.DefaultOptions()
+	httpCompression     string
+	httpShutdownTimeout time.Duration
+
+	grpcPort          int
+	grpcWatchInterval time.Duration
+
+	enableActions bool
+
+	podLogSampling    bool
+	logSamplerOptions = logsampler.DefaultOptions()
+
+	watchEvents   bool
+	eventsOptions = events.DefaultOptions()
+
+	releaseMetrics         bool
+	releaseMetricsReleases string
+	releaseMetricsOptions  = releasemetrics.DefaultOptions()
+
+	graphExportOptions = export.DefaultOptions()
+
+	auditEnabled bool
+	auditOptions = audit.DefaultOptions()
+
+	healthHistoryEnabled bool
+	healthHistoryOptions = healthhistory.DefaultOptions()
+
+	kubeContext    string
+	kubeContextAll bool
+
+	configReloadInterval time.Duration
+
+	tracingOptions tracing.Options
 )
 
 func init() {
+	flag.StringVar(&configFile, "config", getEnv("ASTROLABE_CONFIG", ""), "Path to a YAML configuration file (flags set explicitly take precedence)")
+	flag.DurationVar(&configReloadInterval, "config-reload-interval", 10*time.Second, "How often the configuration file is checked for changes to reload (0 = only on SIGHUP)")
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to kubeconfig file (optional, uses in-cluster config if not set)")
+	flag.StringVar(&kubeContext, "context", getEnv("KUBE_CONTEXT", ""), "Comma-separated kubeconfig contexts to watch; the first is the primary cluster, the others are merged under their name like federated clusters (default: current context)")
+	flag.BoolVar(&kubeContextAll, "kubeconfig-context-all", getEnvBool("KUBECONFIG_CONTEXT_ALL", false), "Watch the clusters of every kubeconfig context, merging those other than the primary one under their name")
 	flag.IntVar(&port, "port", 8080, "HTTP API server port")
-	flag.StringVar(&labelSelector, "label-selector", "", "Label selector to filter resources (empty for all resources)")
+	flag.StringVar(&labelSelector, "label-selector", getEnv("LABEL_SELECTOR", ""), "Label selector to filter resources (empty for all resources)")
+	flag.StringVar(&watchKinds, "watch-kinds", getEnv("WATCH_KINDS", ""), "Comma-separated list of kinds to watch (empty for all supported kinds)")
+	flag.StringVar(&excludeKinds, "exclude-kinds", getEnv("EXCLUDE_KINDS", ""), "Comma-separated list of kinds not to watch")
+	flag.StringVar(&namespaces, "namespaces", getEnv("NAMESPACES", ""), "Comma-separated namespaces to watch (empty for all namespaces)")
+	flag.BoolVar(&lazyNamespaces, "lazy-namespaces", getEnvBool("LAZY_NAMESPACES", false), "Discover namespaces first and only start their informers when they match --namespace-patterns or are first queried")
+	flag.StringVar(&namespacePatterns, "namespace-patterns", getEnv("NAMESPACE_PATTERNS", ""), "Comma-separated glob patterns of namespaces watched from the start in lazy namespace mode")
+	flag.StringVar(&cascadeDelete, "cascade-delete", getEnv("CASCADE_DELETE", "none"), "Handling of owned resources when their owner is deleted: none, mark or remove")
+	flag.StringVar(&idStrategy, "id-strategy", getEnv("ID_STRATEGY", graph.IDStrategyUID), "How nodes are identified: uid, cluster-uid or logical (namespace/kind/name)")
+	flag.StringVar(&clusterName, "cluster-name", getEnv("CLUSTER_NAME", ""), "Name of the watched cluster, used by the cluster-uid and logical ID strategies")
+	flag.IntVar(&edgeStaleResyncs, "edge-stale-resyncs", getEnvInt("EDGE_STALE_RESYNCS", 0), "Resync periods after which an edge that was not reconfirmed is stale (0 to disable the edge sweeper)")
+	flag.StringVar(&edgeStaleAction, "edge-stale-action", getEnv("EDGE_STALE_ACTION", string(graph.SweepFlag)), "What happens to stale edges: flag or remove")
+	flag.StringVar(&pruneStaleNodes, "prune-stale-nodes", getEnv("PRUNE_STALE_NODES", string(informers.PruneRemove)), "What happens to nodes whose object is no longer in the synced informer caches (checked after startup and every resync): off, mark or remove")
+	flag.IntVar(&keepInactiveReplicaSets, "keep-inactive-replicasets", getEnvInt("KEEP_INACTIVE_REPLICASETS", 1), "Number of inactive ReplicaSets (previous rollout revisions) kept per Deployment, linked to it by revision-of edges")
+	flag.IntVar(&keepSucceededJobs, "keep-succeeded-jobs", getEnvInt("KEEP_SUCCEEDED_JOBS", -1), "Number of succeeded Jobs kept per CronJob, older ones are pruned from the graph with their Pods (-1 keeps all)")
+	flag.IntVar(&keepFailedJobs, "keep-failed-jobs", getEnvInt("KEEP_FAILED_JOBS", -1), "Number of failed Jobs kept per CronJob, older ones are pruned from the graph with their Pods (-1 keeps all)")
+	flag.DurationVar(&jobPruneInterval, "job-prune-interval", time.Minute, "How often finished Jobs beyond --keep-succeeded-jobs and --keep-failed-jobs are pruned")
+	flag.IntVar(&eventRateLimit, "event-rate-limit", getEnvInt("EVENT_RATE_LIMIT", 0), "Maximum informer events processed per second (0 for unlimited); updates of still queued objects are coalesced")
+	flag.IntVar(&eventBurst, "event-burst", getEnvInt("EVENT_BURST", 100), "Number of informer events processed in a burst above --event-rate-limit")
+	flag.IntVar(&dependentRate, "dependent-reprocess-rate", getEnvInt("DEPENDENT_REPROCESS_RATE", 20), "Maximum resources reprocessed per second because a resource they reference, such as a Service or ConfigMap, changed (0 to wait for their resync)")
+	flag.StringVar(&eventWorkers, "processor-workers", getEnv("PROCESSOR_WORKERS", ""), "Comma-separated number of workers processing the events of kinds concurrently, e.g. Pod=8,ReplicaSet=2; the events of an object stay in order, other kinds share a single worker")
+	flag.DurationVar(&discoveryTTL, "discovery-ttl", informers.DefaultDiscoveryTTL, "How long discovered API resources are cached; they are refreshed at this interval and newly installed supported CRDs are then watched")
+	flag.DurationVar(&consistencyCheckInterval, "consistency-check-interval", 15*time.Minute, "How often the graph is checked for dangling edges, stale indexes and drift from Redis (0 to disable)")
+	flag.BoolVar(&consistencyRepair, "consistency-repair", true, "Repair inconsistencies found by the consistency checker")
+	flag.DurationVar(&analysisInterval, "analysis-interval", 30*time.Second, "How often the background analyses (orphans, selector conflicts, spread, antipatterns, deprecations) rerun when the graph changed (0 to disable)")
+	flag.StringVar(&deprecationTargetVersion, "deprecation-target-version", getEnv("DEPRECATION_TARGET_VERSION", ""), "Kubernetes version deprecated APIs are checked against, e.g. 1.29 to prepare an upgrade (default: the cluster's version)")
+	flag.IntVar(&timelineSize, "timeline-size", getEnvInt("TIMELINE_SIZE", timeline.DefaultCapacity), "Number of release events, such as rollbacks, kept in memory for /api/v1/releases/<name>/timeline (0 to disable)")
+	flag.DurationVar(&restartCorrelationWindow, "restart-correlation-window", timeline.DefaultCorrelationWindow, "How long after a ConfigMap or Secret change or a rollout recreated Pods are recorded in the release timeline as restarted by it (0 to disable)")
+	flag.DurationVar(&uninstallGracePeriod, "uninstall-grace-period", 10*time.Minute, "How long resources of an uninstalled Helm release may remain before the release is reported as an orphaned uninstall (0 to disable)")
+	flag.DurationVar(&anomalyWindow, "anomaly-window", 15*time.Minute, "Window within which container restarts and Pod status changes are counted to flag restarting or flapping Pods on /api/v1/anomalies (0 to disable)")
+	flag.DurationVar(&tombstoneRetention, "tombstone-retention", 0, "How long deleted resources are kept as tombstones, returned by /api/v1/resources and /api/v1/graph with includeDeleted=true (0 to disable)")
 	flag.BoolVar(&inCluster, "in-cluster", true, "Use in-cluster configuration")
 	flag.BoolVar(&enablePersistence, "enable-persistence", getEnvBool("ENABLE_PERSISTENCE", false), "Enable Redis persistence")
 	flag.StringVar(&redisAddr, "redis-addr", getEnv("REDIS_ADDR", "localhost:6379"), "Redis address")
 	flag.StringVar(&redisPassword, "redis-password", getEnv("REDIS_PASSWORD", ""), "Redis password")
 	flag.IntVar(&redisDB, "redis-db", getEnvInt("REDIS_DB", 0), "Redis database number")
-	flag.IntVar(&snapshotInterval, "snapshot-interval", 300, "Snapshot interval in seconds (0 to disable periodic snapshots)")
+	flag.IntVar(&snapshotInterval, "snapshot-interval", 300, "Snapshot interval in seconds, or change log compaction interval with --persistence-mode=log (0 to disable)")
+	flag.StringVar(&snapshotVerify, "snapshot-verify", getEnv("SNAPSHOT_VERIFY", string(storage.VerifyWarn)), "Verification of persisted data against the snapshot manifest on startup: strict (refuse), warn or off")
+	flag.IntVar(&persistenceBatchSize, "persistence-batch-size", getEnvInt("PERSISTENCE_BATCH_SIZE", 100), "Number of queued writes sent to Redis in one pipeline")
+	flag.DurationVar(&persistenceFlushInterval, "persistence-flush-interval", 30*time.Second, "Maximum time queued writes wait before being sent to Redis")
+	flag.DurationVar(&persistenceEnqueueWait, "persistence-enqueue-timeout", time.Second, "How long a write waits for room in the full write queue before it is spilled to the overflow buffer")
+	flag.IntVar(&persistenceMaxOverflow, "persistence-max-overflow", getEnvInt("PERSISTENCE_MAX_OVERFLOW", 100000), "Maximum number of writes held in the overflow buffer and retry list; writes beyond it are dropped")
+	flag.DurationVar(&storageCompactInterval, "storage-compaction-interval", time.Hour, "How often the keys Redis holds for nodes and edges no longer in the graph are reclaimed (0 to disable)")
+	flag.DurationVar(&orphanedKeyTTL, "orphaned-key-ttl", 24*time.Hour, "Expiry given to orphaned Redis keys until the next storage compaction deletes them, in case Astrolabe stops first")
+	flag.StringVar(&persistenceMode, "persistence-mode", getEnv("PERSISTENCE_MODE", "snapshot"), "How writes are persisted: snapshot (update records, periodic full snapshots) or log (append to a change log, periodically compacted)")
+	flag.StringVar(&snapshotFormat, "snapshot-format", getEnv("SNAPSHOT_FORMAT", string(storage.SnapshotRecords)), "How snapshots are stored: records (one JSON record per node and edge) or binary (one zstd-compressed protobuf blob, with the writes in between appended to a change log)")
+	flag.BoolVar(&auditEnabled, "audit-log", getEnvBool("AUDIT_LOG", false), "Record every graph mutation with the event that caused it in Redis, for astrolabe replay (requires --enable-persistence)")
+	flag.DurationVar(&auditOptions.CheckpointInterval, "audit-checkpoint-interval", auditOptions.CheckpointInterval, "How often the full graph is recorded in the audit log; replays start from the last checkpoint")
+	flag.DurationVar(&auditOptions.Retention, "audit-retention", 7*24*time.Hour, "How long graph mutations stay replayable (0 to keep them forever)")
+	flag.BoolVar(&healthHistoryEnabled, "health-history", getEnvBool("HEALTH_HISTORY", false), "Record the hourly health of every Helm release in Redis, for /api/v1/releases/{name}/health-history (requires --enable-persistence)")
+	flag.DurationVar(&healthHistoryOptions.Retention, "health-history-retention", healthHistoryOptions.Retention, "How long the hourly health of releases is kept (0 to keep it forever)")
+	flag.StringVar(&dumpDir, "dump-dir", getEnv("DUMP_DIR", os.TempDir()), "Directory the graph state is dumped to on SIGUSR1, for debugging")
+	flag.DurationVar(&readSnapshotInterval, "read-snapshot-interval", time.Second, "How often the read-only graph snapshot used by the API is rebuilt (0 to read the live graph)")
+
+	flag.BoolVar(&enableActions, "enable-actions", getEnvBool("ENABLE_ACTIONS", false), "Enable the write API (rollout restart, scale) authorized against the caller's RBAC permissions")
+	flag.BoolVar(&podLogSampling, "pod-log-sampling", getEnvBool("POD_LOG_SAMPLING", false), "Attach the last log lines of crashed containers to failing Pods")
+	flag.Int64Var(&logSamplerOptions.TailLines, "pod-log-tail-lines", logSamplerOptions.TailLines, "Number of log lines sampled from a crashed container")
+	flag.IntVar(&logSamplerOptions.MaxExcerptBytes, "pod-log-max-bytes", logSamplerOptions.MaxExcerptBytes, "Maximum size of the log excerpt attached to a Pod")
+	flag.Float64Var(&logSamplerOptions.Rate, "pod-log-rate", logSamplerOptions.Rate, "Maximum log requests per second sent to the Kubernetes API")
+	flag.BoolVar(&watchEvents, "watch-events", getEnvBool("WATCH_EVENTS", false), "Watch Warning Events and attach the recent ones to the resources they are about")
+	flag.IntVar(&eventsOptions.MaxPerResource, "events-per-resource", eventsOptions.MaxPerResource, "Number of recent Warning Events kept per resource")
+	flag.DurationVar(&eventsOptions.MaxAge, "event-max-age", eventsOptions.MaxAge, "How long Warning Events stay attached after they were last seen")
+	flag.BoolVar(&releaseMetrics, "release-metrics", getEnvBool("RELEASE_METRICS", false), "Export the resource counts and Pod restarts of each Helm release as Prometheus series")
+	flag.StringVar(&releaseMetricsReleases, "release-metrics-releases", getEnv("RELEASE_METRICS_RELEASES", ""), "Comma-separated glob patterns of the Helm releases exported by --release-metrics (empty for all releases)")
+	flag.IntVar(&releaseMetricsOptions.MaxReleases, "release-metrics-max-releases", getEnvInt("RELEASE_METRICS_MAX_RELEASES", releaseMetricsOptions.MaxReleases), "Number of Helm releases with their own series; the others are summed into release=\"_other\" (0 for no limit)")
+
+	flag.StringVar(&graphExportOptions.Backend, "graph-export", getEnv("GRAPH_EXPORT", ""), "Mirror the graph into an external graph database: neo4j or janusgraph (empty to disable)")
+	flag.StringVar(&graphExportOptions.URL, "graph-export-url", getEnv("GRAPH_EXPORT_URL", ""), "HTTP endpoint of the graph database (Neo4j server or JanusGraph Gremlin Server)")
+	flag.StringVar(&graphExportOptions.Database, "graph-export-database", getEnv("GRAPH_EXPORT_DATABASE", graphExportOptions.Database), "Neo4j database the graph is exported to")
+	flag.StringVar(&graphExportOptions.Username, "graph-export-username", getEnv("GRAPH_EXPORT_USERNAME", ""), "Username of the graph database")
+	flag.StringVar(&graphExportOptions.Password, "graph-export-password", getEnv("GRAPH_EXPORT_PASSWORD", ""), "Password of the graph database")
+	flag.IntVar(&graphExportOptions.BatchSize, "graph-export-batch-size", getEnvInt("GRAPH_EXPORT_BATCH_SIZE", graphExportOptions.BatchSize), "Number of nodes or edges upserted in one request to the graph database")
+	flag.DurationVar(&graphExportOptions.Interval, "graph-export-interval", graphExportOptions.Interval, "How often graph changes are exported to the graph database")
+
+	flag.IntVar(&grpcPort, "grpc-port", getEnvInt("GRPC_PORT", 0), "gRPC API server port (0 to disable the gRPC API)")
+	flag.DurationVar(&grpcWatchInterval, "grpc-watch-interval", time.Second, "How often WatchGraph streams check the graph for changes")
+
+	flag.StringVar(&apiOptions.TLSCertFile, "tls-cert-file", "", "TLS certificate file for the API server (enables HTTPS and HTTP/2)")
+	flag.StringVar(&apiOptions.TLSKeyFile, "tls-key-file", "", "TLS private key file for the API server")
+	flag.BoolVar(&apiOptions.EnableH2C, "enable-h2c", false, "Serve HTTP/2 without TLS (h2c prior knowledge)")
+	flag.DurationVar(&apiOptions.ReadTimeout, "http-read-timeout", apiOptions.ReadTimeout, "Maximum duration for reading an entire request")
+	flag.DurationVar(&apiOptions.WriteTimeout, "http-write-timeout", apiOptions.WriteTimeout, "Maximum duration before timing out writes of a response (0 = no timeout, for streaming)")
+	flag.DurationVar(&apiOptions.IdleTimeout, "http-idle-timeout", apiOptions.IdleTimeout, "How long idle keep-alive connections are kept open")
+	flag.DurationVar(&httpShutdownTimeout, "http-shutdown-timeout", 10*time.Second, "How long requests and gRPC calls in flight are drained on shutdown before their connections are closed; new requests are answered with 503 meanwhile (0 = closed at once)")
+	flag.IntVar(&apiOptions.MaxConcurrentStreams, "http2-max-concurrent-streams", 0, "Maximum concurrent HTTP/2 streams per connection (0 = default of 250)")
+	flag.IntVar(&apiOptions.TLSPort, "tls-port", getEnvInt("TLS_PORT", 0), "Serve the API with TLS on this port, keeping --port in plaintext (0 = TLS on --port when a certificate is set)")
+	flag.IntVar(&apiOptions.AdminPort, "admin-port", getEnvInt("ADMIN_PORT", 0), "Serve /metrics and the debug endpoints on this internal port instead of --port (0 = disabled)")
+	flag.IntVar(&apiOptions.WriteBufferSize, "http-write-buffer-size", 0, "Socket write buffer size in bytes for API connections (0 = OS default)")
+	flag.StringVar(&httpCompression, "http-compression", getEnv("HTTP_COMPRESSION", strings.Join(apiOptions.Compression, ",")), "Comma-separated encodings to compress API responses with, by preference (zstd, gzip; none = uncompressed)")
+
+	flag.StringVar(&tracingOptions.Endpoint, "otlp-endpoint", getEnv("OTLP_ENDPOINT", ""), "host:port of the OTLP/gRPC receiver traces of API requests, event processing and Redis operations are exported to (empty to disable tracing)")
+	flag.BoolVar(&tracingOptions.Insecure, "otlp-insecure", getEnvBool("OTLP_INSECURE", false), "Export traces to --otlp-endpoint without TLS")
+	flag.Float64Var(&tracingOptions.SampleRatio, "trace-sample-ratio", 1, "Fraction of traces recorded, unless the caller of an API request decided whether to sample it")
 
 	klog.InitFlags(nil)
 }
@@ -68,28 +273,175 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// newDeprecationTable creates the table of deprecated APIs of the config file, checked against
+// --deprecation-target-version or else the cluster's version
+func newDeprecationTable(clusterVersion string, apis []config.DeprecatedAPI) (*deprecations.Table, error) {
+	value := clusterVersion
+	if deprecationTargetVersion != "" {
+		value = deprecationTargetVersion
+	}
+	version, err := deprecations.ParseVersion(value)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]deprecations.API, 0, len(apis))
+	for _, api := range apis {
+		entries = append(entries, deprecations.API{
+			APIVersion:   api.APIVersion,
+			Kind:         api.Kind,
+			DeprecatedIn: api.DeprecatedIn,
+			RemovedIn:    api.RemovedIn,
+			Replacement:  api.Replacement,
+		})
+	}
+	return deprecations.NewTable(version, entries)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "replay" {
+		os.Exit(runReplay(flag.Args()[1:]))
+	}
 
 	klog.Info("Starting Astrolabe Server")
 
-	// Check for environment variable override for label selector
-	if envSelector := os.Getenv("LABEL_SELECTOR"); envSelector != "" || os.Getenv("LABEL_SELECTOR") == "" {
-		// If LABEL_SELECTOR env var is explicitly set (even to empty), use it
-		if _, exists := os.LookupEnv("LABEL_SELECTOR"); exists {
-			labelSelector = envSelector
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		klog.Fatalf("Failed to load configuration: %v", err)
+	}
+	explicit := explicitFlags()
+	var reloader *configReloader
+	if configFile != "" {
+		if reloader, err = newConfigReloader(configFile, cfg, configReloadInterval, explicit); err != nil {
+			klog.Fatalf("Failed to load configuration: %v", err)
+		}
+	}
+	// Flags set on the command line take precedence over those of the config file
+	if err := applyConfigFlags(cfg, explicit); err != nil {
+		klog.Fatalf("Invalid configuration: %v", err)
+	}
+
+	shutdownTracing := func(context.Context) error { return nil }
+	if tracingOptions.Endpoint != "" {
+		if shutdownTracing, err = tracing.Setup(context.Background(), tracingOptions); err != nil {
+			klog.Fatalf("Failed to set up tracing: %v", err)
 		}
 	}
 
+	// Kinds given on the command line replace those from the config file
+	kindFilter := configKindFilter(cfg)
+	if err := kindFilter.Validate(); err != nil {
+		klog.Fatalf("Invalid kind filter: %v", err)
+	}
+	klog.Infof("Watched kinds: %s", strings.Join(kindFilter.EnabledKinds(), ", "))
+
+	cascadeMode, err := processors.ParseCascadeMode(cascadeDelete)
+	if err != nil {
+		klog.Fatalf("Invalid --cascade-delete: %v", err)
+	}
+	if keepInactiveReplicaSets < 0 {
+		klog.Fatalf("Invalid --keep-inactive-replicasets %d: must not be negative", keepInactiveReplicaSets)
+	}
+	if keepSucceededJobs < -1 || keepFailedJobs < -1 {
+		klog.Fatalf("Invalid --keep-succeeded-jobs %d or --keep-failed-jobs %d: must be -1 (keep all) or more", keepSucceededJobs, keepFailedJobs)
+	}
+	if storageCompactInterval > 0 && orphanedKeyTTL <= storageCompactInterval {
+		klog.Fatalf("Invalid --orphaned-key-ttl %v: must be longer than --storage-compaction-interval %v", orphanedKeyTTL, storageCompactInterval)
+	}
+	if uninstallGracePeriod < 0 {
+		klog.Fatalf("Invalid --uninstall-grace-period %v: must not be negative", uninstallGracePeriod)
+	}
+	if anomalyWindow < 0 {
+		klog.Fatalf("Invalid --anomaly-window %v: must not be negative", anomalyWindow)
+	}
+	if jobPruneInterval <= 0 {
+		klog.Fatalf("Invalid --job-prune-interval %v: must be positive", jobPruneInterval)
+	}
+
+	sweepMode, err := graph.ParseSweepMode(edgeStaleAction)
+	if err != nil {
+		klog.Fatalf("Invalid --edge-stale-action: %v", err)
+	}
+
+	pruneMode, err := informers.ParsePruneMode(pruneStaleNodes)
+	if err != nil {
+		klog.Fatalf("Invalid --prune-stale-nodes: %v", err)
+	}
+	workers, err := informers.ParseWorkers(eventWorkers)
+	if err != nil {
+		klog.Fatalf("Invalid --processor-workers: %v", err)
+	}
+	custom, err := customResources(cfg.CustomResources)
+	if err != nil {
+		klog.Fatalf("Invalid custom resources: %v", err)
+	}
+
+	computedFields := make([]processors.ComputedField, 0, len(cfg.ComputedFields))
+	for _, field := range cfg.ComputedFields {
+		computedFields = append(computedFields, processors.ComputedField{
+			Kind:     field.Kind,
+			Name:     field.Name,
+			JSONPath: field.JSONPath,
+		})
+	}
+	enricher, err := processors.NewEnricher(computedFields)
+	if err != nil {
+		klog.Fatalf("Invalid computed fields: %v", err)
+	}
+	enrichers := []processors.NodeEnricher{enricher}
+
+	statusRules := make([]processors.StatusRule, 0, len(cfg.Status.Rules))
+	for _, rule := range cfg.Status.Rules {
+		statusRules = append(statusRules, processors.StatusRule{
+			Kind:     rule.Kind,
+			JSONPath: rule.JSONPath,
+			Equals:   rule.Equals,
+			Status:   graph.ResourceStatus(rule.Status),
+			Reason:   rule.Reason,
+			Message:  rule.Message,
+		})
+	}
+	statusEngine, err := processors.NewStatusEngine(statusRules, cfg.Status.KStatus)
+	if err != nil {
+		klog.Fatalf("Invalid status configuration: %v", err)
+	}
+
 	if labelSelector == "" {
 		klog.Info("Label selector: <empty> (watching ALL resources)")
 	} else {
 		klog.Infof("Label selector: %s", labelSelector)
 	}
+	labelSelectors, err := configLabelSelectors(cfg)
+	if err != nil {
+		klog.Fatalf("Invalid label selectors: %v", err)
+	}
+	if len(labelSelectors.Kinds) > 0 || len(labelSelectors.Exclude) > 0 {
+		klog.Infof("Label selectors of kinds: %v, exclusions: %v", labelSelectors.Kinds, labelSelectors.Exclude)
+	}
 	klog.Infof("API port: %d", port)
 
 	// Create Kubernetes client
-	config, err := getKubeConfig()
+	contexts, err := kubeContexts()
+	if err != nil {
+		klog.Fatalf("Invalid kubeconfig contexts: %v", err)
+	}
+	var primaryContext string
+	if len(contexts) > 0 {
+		primaryContext = contexts[0]
+	}
+	config, primaryContext, err := getKubeConfig(primaryContext)
 	if err != nil {
 		klog.Fatalf("Failed to get Kubernetes config: %v", err)
 	}
@@ -99,6 +451,11 @@ func main() {
 		klog.Fatalf("Failed to create Kubernetes clientset: %v", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes dynamic client: %v", err)
+	}
+
 	// Test connection
 	serverVersion, err := clientset.Discovery().ServerVersion()
 	if err != nil {
@@ -106,21 +463,71 @@ func main() {
 	}
 	klog.Infof("Connected to Kubernetes cluster version: %s", serverVersion.GitVersion)
 
+	deprecationTable, err := newDeprecationTable(serverVersion.GitVersion, cfg.Deprecations)
+	if err != nil {
+		klog.Fatalf("Invalid deprecations: %v", err)
+	}
+	enrichers = append(enrichers, deprecationTable)
+	klog.Infof("Checking API versions against the deprecations of Kubernetes %s", deprecationTable.Version())
+
+	strategy, err := graph.NewIDStrategy(idStrategy, clusterName)
+	if err != nil {
+		klog.Fatalf("Invalid --id-strategy: %v", err)
+	}
+	graph.SetIDStrategy(strategy)
+	klog.Infof("Node ID strategy: %s", strategy.Name())
+
+	if len(cfg.Applications.Groupers) > 0 {
+		groupers, err := applicationGroupers(cfg.Applications.Groupers)
+		if err != nil {
+			klog.Fatalf("Invalid application groupers: %v", err)
+		}
+		graph.SetDefaultGroupers(groupers)
+	}
+
 	var g graph.GraphInterface
 	var persistentGraph *graph.PersistentGraph
+	var redisStore *storage.RedisStore
 
 	if enablePersistence {
 		klog.Infof("Persistence enabled - connecting to Redis at %s", redisAddr)
 
 		// Create Redis backend
-		redisStore, err := storage.NewRedisStore(redisAddr, redisPassword, redisDB)
+		redisStore, err = storage.NewRedisStore(redisAddr, redisPassword, redisDB)
 		if err != nil {
 			klog.Fatalf("Failed to create Redis store: %v", err)
 		}
+
+		verifyMode, err := storage.ParseVerifyMode(snapshotVerify)
+		if err != nil {
+			klog.Fatalf("Invalid --snapshot-verify: %v", err)
+		}
+		redisStore.SetVerifyMode(verifyMode)
+		switch persistenceMode {
+		case "snapshot":
+		case "log":
+			redisStore.EnableDeltaLog()
+		default:
+			klog.Fatalf("Invalid --persistence-mode %q (expected snapshot or log)", persistenceMode)
+		}
+		format, err := storage.ParseSnapshotFormat(snapshotFormat)
+		if err != nil {
+			klog.Fatalf("Invalid --snapshot-format: %v", err)
+		}
+		if format == storage.SnapshotBinary && persistenceMode == "log" {
+			klog.Fatalf("--snapshot-format=binary requires --persistence-mode=snapshot: the writes between binary snapshots are already appended to the change log, which each snapshot trims")
+		}
+		redisStore.SetSnapshotFormat(format)
 		defer redisStore.Close()
 
-		// Create persistent graph with async writes for better performance
-		persistentGraph = graph.NewPersistentGraph(redisStore, true)
+		// Create persistent graph with async, pipelined writes for better performance
+		persistentGraph = graph.NewPersistentGraph(redisStore, graph.PersistentGraphOptions{
+			AsyncWrites:    true,
+			BatchSize:      persistenceBatchSize,
+			FlushInterval:  persistenceFlushInterval,
+			EnqueueTimeout: persistenceEnqueueWait,
+			MaxOverflow:    persistenceMaxOverflow,
+		})
 		g = persistentGraph
 
 		// Load existing graph from Redis
@@ -134,54 +541,434 @@ func main() {
 		g = graph.NewGraph()
 	}
 
+	// Writers other than the processors record their mutations through their own audit wrapper
+	var auditLog *audit.Log
+	writer := func(origin string) graph.GraphInterface { return g }
+	if auditEnabled {
+		if redisStore == nil {
+			klog.Fatal("--audit-log requires --enable-persistence")
+		}
+		auditLog = audit.NewLog(redisStore, g, auditOptions)
+		writer = func(origin string) graph.GraphInterface { return auditLog.Graph(g, origin) }
+		klog.Infof("Audit log of graph mutations enabled (checkpoint every %v, retention: %v)", auditOptions.CheckpointInterval, auditOptions.Retention)
+	}
+
+	var notifier *notify.Notifier
+	var observers []processors.ChangeObserver
+	if len(cfg.Notifications.Webhooks) > 0 {
+		notifier, err = notify.NewNotifier(notifyOptions(cfg.Notifications))
+		if err != nil {
+			klog.Fatalf("Invalid notifications config: %v", err)
+		}
+		observers = append(observers, notifier)
+		klog.Infof("Status change notifications enabled (%d webhook(s))", len(cfg.Notifications.Webhooks))
+	}
+	var tombstoneStore *tombstones.Store
+	if tombstoneRetention > 0 {
+		tombstoneStore = tombstones.New(tombstoneRetention)
+		observers = append(observers, tombstoneStore)
+		klog.Infof("Tombstones of deleted resources enabled (retention: %v)", tombstoneRetention)
+	}
+
+	var logSampler *logsampler.Sampler
+	if podLogSampling {
+		logSampler = logsampler.NewSampler(clientset, writer(audit.OriginLogSampler), logSamplerOptions)
+		enrichers = append(enrichers, logSampler)
+		klog.Infof("Pod log sampling enabled (%d lines, %.2f requests/s)", logSamplerOptions.TailLines, logSamplerOptions.Rate)
+	}
+	var eventRecorder *events.Recorder
+	if watchEvents {
+		eventsOptions.Namespaces = splitList(namespaces)
+		eventRecorder = events.NewRecorder(clientset, writer(audit.OriginWarnings), eventsOptions)
+		enrichers = append(enrichers, eventRecorder)
+		klog.Infof("Warning Events enabled (%d per resource, kept for %v)", eventsOptions.MaxPerResource, eventsOptions.MaxAge)
+	}
+
 	// Create informer manager
-	manager := informers.NewManager(clientset, g, labelSelector)
+	watchedNamespaces := splitList(namespaces)
+	if len(watchedNamespaces) > 0 {
+		klog.Infof("Watching namespaces: %s", strings.Join(watchedNamespaces, ", "))
+	}
+	lazyPatterns := splitList(namespacePatterns)
+	if lazyNamespaces && len(watchedNamespaces) > 0 {
+		klog.Fatal("--lazy-namespaces and --namespaces are mutually exclusive")
+	}
+	if len(lazyPatterns) > 0 && !lazyNamespaces {
+		klog.Fatal("--namespace-patterns requires --lazy-namespaces")
+	}
+	for _, pattern := range lazyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			klog.Fatalf("Invalid --namespace-patterns pattern %q: %v", pattern, err)
+		}
+	}
 
-	// Create API server
-	apiServer := api.NewServer(g, port)
+	var releaseTimeline *timeline.Timeline
+	if timelineSize > 0 {
+		releaseTimeline = timeline.New(timelineSize)
+		if restartCorrelationWindow > 0 {
+			observers = append(observers, timeline.NewRestartCorrelator(releaseTimeline, g, restartCorrelationWindow))
+		}
+	}
+
+	// Releases whose release Secrets disappear while resources of them remain are reported
+	var uninstallDetector *uninstalls.Detector
+	if uninstallGracePeriod > 0 {
+		uninstallDetector = uninstalls.New(g, uninstallGracePeriod, releaseTimeline)
+		observers = append(observers, uninstallDetector)
+		klog.Infof("Uninstall detection enabled (grace period: %v)", uninstallGracePeriod)
+	}
+
+	// Pods restarting or flapping more than the thresholds of their namespace are flagged
+	var anomalyDetector *anomalies.Detector
+	if anomalyWindow > 0 {
+		anomalyDetector, err = newAnomalyDetector(anomalyWindow, cfg.Anomalies)
+		if err != nil {
+			klog.Fatalf("Invalid anomalies configuration: %v", err)
+		}
+		observers = append(observers, anomalyDetector)
+		klog.Infof("Anomaly detection enabled (window: %v)", anomalyWindow)
+	}
+
+	// Finished Jobs beyond the limits are pruned, and skipped by the processors afterwards
+	var jobPruner *graph.JobPruner
+	var prunedJobs processors.PrunedJobs
+	if keepSucceededJobs >= 0 || keepFailedJobs >= 0 {
+		jobPruner = graph.NewJobPruner(writer(audit.OriginJobPruner), keepSucceededJobs, keepFailedJobs, jobPruneInterval)
+		prunedJobs = jobPruner
+	}
+
+	managerOptions := informers.Options{
+		LabelSelector:  labelSelector,
+		LabelSelectors: labelSelectors,
+		Namespaces:     watchedNamespaces,
+		DynamicClient:  dynamicClient,
+
+		LazyNamespaces:    lazyNamespaces,
+		NamespacePatterns: lazyPatterns,
+		Prune:             pruneMode,
+		EventRateLimit:    eventRateLimit,
+		EventBurst:        eventBurst,
+		DependentRate:     dependentRate,
+		Workers:           workers,
+		DiscoveryTTL:      discoveryTTL,
+		Processors: processors.Options{
+			Kinds:         kindFilter,
+			CascadeDelete: cascadeMode,
+			Status:        statusEngine,
+			Enrichers:     enrichers,
+			Observers:     observers,
+			Timeline:      releaseTimeline,
+			Audit:         auditLog,
+
+			KeepInactiveReplicaSets: keepInactiveReplicaSets,
+			PrunedJobs:              prunedJobs,
+			CustomResources:         custom,
+		},
+	}
+	manager := informers.NewManager(clientset, g, managerOptions)
+
+	// The clusters of the other kubeconfig contexts are merged like federated clusters
+	var contextWatchers []*contextWatcher
+	for _, name := range contexts[min(len(contexts), 1):] {
+		watcher, err := newContextWatcher(name, managerOptions, enricher, cfg.Deprecations)
+		if err != nil {
+			klog.Fatalf("Invalid kubeconfig contexts: %v", err)
+		}
+		contextWatchers = append(contextWatchers, watcher)
+	}
+
+	var federator *federation.Federator
+	var clusterNames []string
+	if len(cfg.Federation.Clusters) > 0 || len(contextWatchers) > 0 {
+		clusters, err := federatedClusters(cfg.Federation.Clusters)
+		if err != nil {
+			klog.Fatalf("Invalid federation config: %v", err)
+		}
+		for _, watcher := range contextWatchers {
+			clusters = append(clusters, watcher.cluster())
+		}
+		for _, cluster := range clusters {
+			clusterNames = append(clusterNames, cluster.Name)
+		}
+		federator, err = federation.NewFederator(writer(audit.OriginFederation), clusters)
+		if err != nil {
+			klog.Fatalf("Invalid federation config: %v", err)
+		}
+		if len(cfg.Federation.Clusters) > 0 {
+			klog.Infof("Federation enabled (%d cluster(s))", len(cfg.Federation.Clusters))
+		}
+		if len(contextWatchers) > 0 {
+			klog.Infof("Watching %d more kubeconfig context(s): %s", len(contextWatchers), strings.Join(contexts[1:], ", "))
+		}
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if logSampler != nil {
+		go logSampler.Start(ctx)
+	}
+	if eventRecorder != nil {
+		go eventRecorder.Start(ctx)
+	}
+	if edgeStaleResyncs > 0 {
+		maxAge := time.Duration(edgeStaleResyncs) * informers.ResyncPeriod
+		sweeper := graph.NewEdgeSweeper(writer(audit.OriginEdgeSweeper), maxAge, sweepMode)
+		go sweeper.Start(ctx)
+		klog.Infof("Edge sweeper enabled (%s edges not reconfirmed within %v)", sweepMode, maxAge)
+	}
+	if jobPruner != nil {
+		go jobPruner.Start(ctx)
+		klog.Infof("Job pruner enabled (keeping %d succeeded and %d failed Job(s) per CronJob, -1 for all)", keepSucceededJobs, keepFailedJobs)
+	}
+	if notifier != nil {
+		go notifier.Start(ctx)
+	}
+
+	var consistencyChecker *graph.ConsistencyChecker
+	if target, ok := g.(graph.ConsistencyCheckable); ok && consistencyCheckInterval > 0 {
+		consistencyChecker = graph.NewConsistencyChecker(target, consistencyCheckInterval, consistencyRepair)
+		go consistencyChecker.Start(ctx)
+		klog.Infof("Consistency checks enabled (every %v, repair: %v)", consistencyCheckInterval, consistencyRepair)
+	}
+	if redisStore != nil && storageCompactInterval > 0 {
+		go storage.NewCompactor(redisStore, g, storageCompactInterval, orphanedKeyTTL).Start(ctx)
+		klog.Infof("Storage compaction enabled (every %v, orphaned key TTL: %v)", storageCompactInterval, orphanedKeyTTL)
+	}
+
+	// Serve API reads from a periodically rebuilt snapshot so they never contend with writers
+	apiGraph := g
+	if readSnapshotInterval > 0 {
+		snapshotView := graph.NewSnapshotView(g, readSnapshotInterval)
+		supervisor.Go(ctx, "read-snapshot", func(ctx context.Context) error {
+			snapshotView.Start(ctx)
+			return nil
+		})
+		apiGraph = snapshotView
+		klog.Infof("API reads served from graph snapshot (refresh interval: %v)", readSnapshotInterval)
+	}
+
+	if releaseMetrics {
+		releaseMetricsOptions.Releases = splitList(releaseMetricsReleases)
+		for _, pattern := range releaseMetricsOptions.Releases {
+			if _, err := path.Match(pattern, ""); err != nil {
+				klog.Fatalf("Invalid --release-metrics-releases pattern %q: %v", pattern, err)
+			}
+		}
+		metrics.Register(releasemetrics.NewCollector(apiGraph, releaseMetricsOptions))
+		klog.Info("Helm release metrics enabled")
+	}
+
+	var analysisScheduler *analysis.Scheduler
+	if analysisInterval > 0 {
+		analysisScheduler = analysis.NewScheduler(apiGraph, analysisInterval,
+			append(analysis.DefaultAnalyzers(), analysis.Deprecations{Table: deprecationTable})...)
+		go analysisScheduler.Start(ctx)
+		klog.Infof("Background analyses enabled (every %v when the graph changed)", analysisInterval)
+	}
+
+	var healthRecorder *healthhistory.Recorder
+	if healthHistoryEnabled {
+		if redisStore == nil {
+			klog.Fatal("--health-history requires --enable-persistence")
+		}
+		healthRecorder = healthhistory.NewRecorder(redisStore, apiGraph, healthHistoryOptions)
+		klog.Infof("Release health history enabled (retention: %v)", healthHistoryOptions.Retention)
+	}
+
+	var exporter *export.Exporter
+	if graphExportOptions.Backend != "" {
+		graphExportOptions.Source = clusterName
+		sink, err := export.NewSink(graphExportOptions)
+		if err != nil {
+			klog.Fatalf("Invalid graph export configuration: %v", err)
+		}
+		exporter = export.NewExporter(apiGraph, sink, graphExportOptions)
+		klog.Infof("Graph export enabled (%s at %s, every %v)", graphExportOptions.Backend, graphExportOptions.URL, graphExportOptions.Interval)
+	}
+
+	// Create API server
+	if (apiOptions.TLSCertFile == "") != (apiOptions.TLSKeyFile == "") {
+		klog.Fatal("Both --tls-cert-file and --tls-key-file must be set to enable TLS")
+	}
+	if apiOptions.TLSPort > 0 && apiOptions.TLSCertFile == "" {
+		klog.Fatal("--tls-port requires --tls-cert-file and --tls-key-file")
+	}
+	apiOptions.Compression = nil
+	if httpCompression != "none" {
+		for _, encoding := range splitList(httpCompression) {
+			if !slices.Contains(api.CompressionEncodings, encoding) {
+				klog.Fatalf("Unknown --http-compression encoding %q (supported: %s)", encoding, strings.Join(api.CompressionEncodings, ", "))
+			}
+			apiOptions.Compression = append(apiOptions.Compression, encoding)
+		}
+	}
+	listeners := map[int]string{port: "--port"}
+	for name, listenerPort := range map[string]int{"--tls-port": apiOptions.TLSPort, "--admin-port": apiOptions.AdminPort, "--grpc-port": grpcPort} {
+		if listenerPort == 0 {
+			continue
+		}
+		if other, used := listeners[listenerPort]; used {
+			klog.Fatalf("%s and %s are both set to port %d", name, other, listenerPort)
+		}
+		listeners[listenerPort] = name
+	}
+	apiServer := api.NewServer(apiGraph, port, apiOptions)
+	apiServer.SetKubeContexts(primaryContext, contexts[min(len(contexts), 1):])
+	apiServer.SetFeatures(api.Features{Persistence: enablePersistence, GRPCPort: grpcPort, Clusters: clusterNames})
+	if enableActions {
+		apiServer.EnableActions(actions.NewProxy(clientset))
+		klog.Info("Action API enabled (restart, scale)")
+	}
+	if consistencyChecker != nil {
+		apiServer.EnableConsistencyChecks(consistencyChecker)
+	}
+	if analysisScheduler != nil {
+		apiServer.EnableAnalyses(analysisScheduler)
+	}
+	if releaseTimeline != nil {
+		apiServer.EnableTimeline(releaseTimeline)
+	}
+	if healthRecorder != nil {
+		apiServer.EnableHealthHistory(healthRecorder)
+	}
+	apiServer.EnableClusterAPIs(manager)
+	apiServer.AddReadinessCheck("informers", func(context.Context) error {
+		if !manager.HasSynced() {
+			return errors.New("informer caches have not synced")
+		}
+		return nil
+	})
+	if redisStore != nil {
+		apiServer.AddReadinessCheck("redis", redisStore.Ping)
+	}
+	if eventRecorder != nil {
+		apiServer.EnableEvents(eventRecorder)
+	}
+	if tombstoneStore != nil {
+		apiServer.EnableTombstones(tombstoneStore)
+	}
+	if uninstallDetector != nil {
+		apiServer.EnableUninstalls(uninstallDetector)
+	}
+	if anomalyDetector != nil {
+		apiServer.EnableAnomalies(anomalyDetector)
+	}
+	apiServer.EnableManifests(manifest.NewFetcher(dynamicClient, clientset.Discovery()))
+	if lazyNamespaces {
+		apiServer.EnableLazyNamespaces(manager)
+	}
+	if dumpable, ok := g.(graph.Dumpable); ok {
+		apiServer.EnableStateDumps(dumpable)
+		go dumpOnSignal(ctx, dumpable)
+	}
+	if measurable, ok := g.(graph.Measurable); ok {
+		var store graph.StoreStatsReporter
+		if redisStore != nil {
+			store = redisStore
+		}
+		apiServer.EnableStats(measurable, store)
+	}
+	if len(cfg.Tenancy.Tokens) > 0 {
+		tokens, err := apiTokens(cfg.Tenancy.Tokens)
+		if err != nil {
+			klog.Fatalf("Invalid tenancy config: %v", err)
+		}
+		authenticator, err := tenancy.NewAuthenticator(tokens)
+		if err != nil {
+			klog.Fatalf("Invalid tenancy config: %v", err)
+		}
+		apiServer.EnableTenancy(authenticator)
+		klog.Infof("API access scoped by API token (%d token(s))", len(tokens))
+		if cfg.Tenancy.Anonymous {
+			apiServer.EnableAnonymousAccess()
+			klog.Infof("Aggregate endpoints served without an API token")
+		}
+	} else if cfg.Tenancy.Anonymous {
+		klog.Fatalf("Invalid tenancy config: anonymous access needs API tokens, without them every endpoint is served without a token")
+	}
+
+	var grpcServer *api.GRPCServer
+	if grpcPort > 0 {
+		grpcServer = api.NewGRPCServer(apiServer, grpcPort, grpcWatchInterval)
+	}
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start API server in goroutine
+	// Start the API listeners and the gRPC server together: when one fails, all are stopped
+	servers := api.NewGroup(apiServer)
+	if grpcServer != nil {
+		servers.Add(grpcServer)
+	}
 	go func() {
-		if err := apiServer.Start(); err != nil {
+		if err := servers.Start(); err != nil {
 			klog.Errorf("API server error: %v", err)
 			cancel()
 		}
 	}()
 
-	// Start informers in goroutine
-	go func() {
-		if err := manager.Start(ctx); err != nil {
-			klog.Errorf("Informer manager error: %v", err)
-			cancel()
-		}
-	}()
+	// Start informers under supervision: a failed run is restarted with backoff
+	if auditLog != nil {
+		supervisor.Go(ctx, "audit-log", auditLog.Start)
+	}
+	supervisor.Go(ctx, "informers", manager.Start)
+	for _, watcher := range contextWatchers {
+		go watcher.serve(ctx)
+		supervisor.Go(ctx, "context/"+watcher.context, watcher.start)
+	}
+	if reloader != nil {
+		reloader.managers = append(reloader.managers, manager)
+		reloader.contexts = contextWatchers
+		supervisor.Go(ctx, "config-reload", reloader.Start)
+	}
+	if federator != nil {
+		federator.Start(ctx)
+	}
+	if exporter != nil {
+		supervisor.Go(ctx, "graph-export", exporter.Start)
+	}
+	if healthRecorder != nil {
+		supervisor.Go(ctx, "health-history", healthRecorder.Start)
+	}
+	if uninstallDetector != nil {
+		go uninstallDetector.Start(ctx)
+	}
+	if anomalyDetector != nil {
+		go anomalyDetector.Start(ctx)
+	}
 
-	// Start periodic snapshot if enabled
+	// Start periodic snapshot, or change log compaction, if enabled
 	if enablePersistence && persistentGraph != nil && snapshotInterval > 0 {
-		go func() {
+		supervisor.Go(ctx, "snapshots", func(ctx context.Context) error {
 			ticker := time.NewTicker(time.Duration(snapshotInterval) * time.Second)
 			defer ticker.Stop()
 
 			for {
 				select {
 				case <-ticker.C:
+					if persistenceMode == "log" {
+						klog.V(2).Info("Compacting change log...")
+						if err := persistentGraph.Compact(); err != nil {
+							klog.Errorf("Failed to compact change log: %v", err)
+						}
+						continue
+					}
 					klog.V(2).Info("Creating periodic snapshot...")
 					if err := persistentGraph.Snapshot(); err != nil {
 						klog.Errorf("Failed to create snapshot: %v", err)
 					}
 				case <-ctx.Done():
-					return
+					return nil
 				}
 			}
-		}()
-		klog.Infof("Periodic snapshots enabled (interval: %ds)", snapshotInterval)
+		})
+		if persistenceMode == "log" {
+			klog.Infof("Periodic change log compaction enabled (interval: %ds)", snapshotInterval)
+		} else {
+			klog.Infof("Periodic snapshots enabled (interval: %ds)", snapshotInterval)
+		}
 	}
 
 	klog.Info("Astrolabe is running. Press Ctrl+C to exit.")
@@ -194,19 +981,25 @@ func main() {
 		klog.Info("Context cancelled")
 	}
 
-	// Graceful shutdown
+	// Graceful shutdown: the API is drained first, as requests in flight read the graph the
+	// informers and persistence keep until they are stopped
 	klog.Info("Shutting down...")
-	cancel()
-
-	if err := apiServer.Stop(); err != nil {
-		klog.Errorf("Error stopping API server: %v", err)
+	klog.Infof("Draining API requests (timeout: %v)", httpShutdownTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	if err := servers.Shutdown(drainCtx); err != nil {
+		klog.Errorf("Error stopping API servers: %v", err)
 	}
+	cancelDrain()
+	cancel()
 
-	// Create final snapshot if persistence is enabled
+	// Create final snapshot if persistence is enabled; the change log needs none, pending
+	// writes are appended to it when the graph is closed
 	if enablePersistence && persistentGraph != nil {
-		klog.Info("Creating final snapshot before shutdown...")
-		if err := persistentGraph.Snapshot(); err != nil {
-			klog.Errorf("Failed to create final snapshot: %v", err)
+		if persistenceMode != "log" {
+			klog.Info("Creating final snapshot before shutdown...")
+			if err := persistentGraph.Snapshot(); err != nil {
+				klog.Errorf("Failed to create final snapshot: %v", err)
+			}
 		}
 
 		// Close persistent graph (flushes pending writes)
@@ -215,39 +1008,196 @@ func main() {
 		}
 	}
 
+	// Export the spans still buffered
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		klog.Errorf("Error exporting traces: %v", err)
+	}
+	cancelTracing()
+
 	klog.Info("Shutdown complete")
 }
 
-func getKubeConfig() (*rest.Config, error) {
+// dumpOnSignal writes the state of the graph to --dump-dir on every SIGUSR1, so the state of a
+// misbehaving instance can be captured before it is restarted
+func dumpOnSignal(ctx context.Context, g graph.Dumpable) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-signals:
+			name, err := graph.WriteStateDumpFile(dumpDir, g)
+			if err != nil {
+				klog.Errorf("Failed to dump graph state: %v", err)
+				continue
+			}
+			klog.Infof("Dumped graph state to %s", name)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// getKubeConfig returns the config of a kubeconfig context, or of the current context when
+// name is empty, and the name of the context. Without a context, the in-cluster config is used
+// when requested, and the returned name is empty.
+func getKubeConfig(name string) (*rest.Config, string, error) {
 	// Try in-cluster config first if requested
-	if inCluster && kubeconfig == "" {
+	if inCluster && kubeconfig == "" && name == "" {
 		klog.Info("Using in-cluster Kubernetes configuration")
 		config, err := rest.InClusterConfig()
 		if err == nil {
-			return config, nil
+			return config, "", nil
 		}
 		klog.Warningf("Failed to use in-cluster config: %v", err)
 	}
 
 	// Fall back to kubeconfig
-	if kubeconfig == "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
+	path, err := kubeconfigPath()
+	if err != nil {
+		return nil, "", err
+	}
+	config, name, err := contextConfig(name)
+	if err != nil {
+		return nil, "", err
 	}
+	klog.Infof("Using kubeconfig: %s (context %s)", path, name)
+	return config, name, nil
+}
 
-	if kubeconfig == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
+// notifyOptions converts the notifications section of the config file
+func notifyOptions(cfg config.Notifications) notify.Options {
+	opts := notify.Options{Debounce: 30 * time.Second}
+	if cfg.Debounce != nil {
+		opts.Debounce = cfg.Debounce.Duration
+	}
+	for _, webhook := range cfg.Webhooks {
+		opts.Webhooks = append(opts.Webhooks, notify.Webhook{
+			Name:       webhook.Name,
+			URL:        webhook.URL,
+			Format:     webhook.Format,
+			Releases:   webhook.Releases,
+			Namespaces: webhook.Namespaces,
+		})
+	}
+	return opts
+}
+
+// newAnomalyDetector creates the anomaly detector with the thresholds of the config file
+func newAnomalyDetector(window time.Duration, cfg config.Anomalies) (*anomalies.Detector, error) {
+	namespaces := make([]anomalies.NamespaceThresholds, 0, len(cfg.Namespaces))
+	for _, ns := range cfg.Namespaces {
+		namespaces = append(namespaces, anomalies.NamespaceThresholds{
+			Namespace:  ns.Namespace,
+			Thresholds: anomalies.Thresholds{Restarts: ns.Restarts, Flaps: ns.Flaps},
+		})
+	}
+	return anomalies.New(window, anomalies.Thresholds{Restarts: cfg.Restarts, Flaps: cfg.Flaps}, namespaces)
+}
+
+// customResources converts the custom resources of the config file
+func customResources(cfg []config.CustomResource) ([]processors.CustomResource, error) {
+	resources := make([]processors.CustomResource, 0, len(cfg))
+	kinds := make(map[string]bool, len(cfg))
+	for _, resource := range cfg {
+		if resource.Kind == "" || resource.Version == "" || resource.Resource == "" {
+			return nil, fmt.Errorf("custom resource %q: kind, version and resource are required", resource.Kind)
+		}
+		for _, supported := range processors.SupportedKinds() {
+			if strings.EqualFold(supported, resource.Kind) {
+				return nil, fmt.Errorf("custom resource %q: %s is a supported kind, use watchKinds", resource.Kind, supported)
+			}
+		}
+		if kinds[strings.ToLower(resource.Kind)] {
+			return nil, fmt.Errorf("custom resource %q is configured twice", resource.Kind)
 		}
-		kubeconfig = homeDir + "/.kube/config"
+		kinds[strings.ToLower(resource.Kind)] = true
+
+		references := make([]processors.ReferencePath, 0, len(resource.References))
+		for _, reference := range resource.References {
+			if reference.Path == "" {
+				return nil, fmt.Errorf("custom resource %q: reference without a path", resource.Kind)
+			}
+			references = append(references, processors.ReferencePath{Path: reference.Path, Kind: reference.Kind})
+		}
+		resources = append(resources, processors.CustomResource{
+			Kind:          resource.Kind,
+			Resource:      schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource},
+			ClusterScoped: resource.ClusterScoped,
+			References:    references,
+		})
 	}
+	return resources, nil
+}
 
-	klog.Infof("Using kubeconfig: %s", kubeconfig)
+// federatedClusters converts the federated clusters of the config file, reading their tokens
+func federatedClusters(cfg []config.FederatedCluster) ([]federation.Cluster, error) {
+	clusters := make([]federation.Cluster, 0, len(cfg))
+	for _, cluster := range cfg {
+		var token string
+		if cluster.TokenFile != "" {
+			data, err := os.ReadFile(cluster.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("federated cluster %q: failed to read token file: %w", cluster.Name, err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		clusters = append(clusters, federation.Cluster{
+			Name:    cluster.Name,
+			Address: cluster.Address,
+			CAFile:  cluster.CAFile,
+			Token:   token,
+		})
+	}
+	return clusters, nil
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build config from kubeconfig: %w", err)
+// apiTokens converts the API tokens of the config file, reading their token files
+func apiTokens(cfg []config.APIToken) ([]tenancy.Token, error) {
+	tokens := make([]tenancy.Token, 0, len(cfg))
+	for _, token := range cfg {
+		value := token.Token
+		if token.TokenFile != "" {
+			if value != "" {
+				return nil, fmt.Errorf("API token %q sets both token and tokenFile", token.Name)
+			}
+			data, err := os.ReadFile(token.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("API token %q: failed to read token file: %w", token.Name, err)
+			}
+			value = strings.TrimSpace(string(data))
+		}
+		tokens = append(tokens, tenancy.Token{
+			Name:  token.Name,
+			Token: value,
+			Scope: tenancy.Scope{Namespaces: token.Namespaces, Releases: token.Releases},
+		})
 	}
+	return tokens, nil
+}
 
-	return config, nil
+// applicationGroupers converts the application groupers of the config file
+func applicationGroupers(cfg []config.Grouper) ([]graph.Grouper, error) {
+	builtin := graph.BuiltinGroupers()
+	groupers := make([]graph.Grouper, 0, len(cfg))
+	for _, c := range cfg {
+		switch {
+		case c.Name == "":
+			return nil, fmt.Errorf("grouper without a name")
+		case c.Label != "" && c.Annotation != "":
+			return nil, fmt.Errorf("grouper %q: label and annotation are mutually exclusive", c.Name)
+		case c.Label != "":
+			groupers = append(groupers, graph.LabelGrouper{GrouperName: c.Name, Key: c.Label})
+		case c.Annotation != "":
+			groupers = append(groupers, graph.LabelGrouper{GrouperName: c.Name, Key: c.Annotation, Annotation: true})
+		default:
+			grouper, ok := builtin[c.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown built-in grouper %q (custom groupers need a label or annotation)", c.Name)
+			}
+			groupers = append(groupers, grouper)
+		}
+	}
+	return groupers, nil
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/config"
+	"github.com/ammarlakis/astrolabe/pkg/informers"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"k8s.io/klog/v2"
+)
+
+// The configuration file sets any flag under flags, below the flags set on the command line.
+// Some of its values are applied at runtime when the file changes or on SIGHUP: the label
+// selectors, the namespaces and the watched kinds, whose informers are rebuilt. Other changes
+// take effect on restart.
+
+// reloadableFlags are the flags applied when the configuration file is reloaded
+var reloadableFlags = []string{"label-selector", "namespaces"}
+
+// reservedConfigFlags are the flags the configuration file cannot set under flags, with the
+// field to use instead
+var reservedConfigFlags = map[string]string{
+	"config":        "",
+	"watch-kinds":   "watchKinds",
+	"exclude-kinds": "excludeKinds",
+}
+
+// explicitFlags returns the flags set on the command line
+func explicitFlags() map[string]bool {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+// validateConfigFlags checks that the flags of the configuration file exist and can be set
+// from it, and returns their values
+func validateConfigFlags(cfg *config.Config) (map[string]string, error) {
+	values, err := cfg.FlagValues()
+	if err != nil {
+		return nil, err
+	}
+	for name := range values {
+		if field, reserved := reservedConfigFlags[name]; reserved {
+			if field == "" {
+				return nil, fmt.Errorf("flag %s cannot be set in the configuration file", name)
+			}
+			return nil, fmt.Errorf("flag %s cannot be set under flags, set %s instead", name, field)
+		}
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown flag %s", name)
+		}
+	}
+	return values, nil
+}
+
+// applyConfigFlags sets the flags of the configuration file that were not set on the command
+// line
+func applyConfigFlags(cfg *config.Config, explicit map[string]bool) error {
+	values, err := validateConfigFlags(cfg)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q of flag %s: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// configKindFilter returns the watched kinds: those of the configuration file, replaced by
+// --watch-kinds and --exclude-kinds when set
+func configKindFilter(cfg *config.Config) processors.KindFilter {
+	kindFilter := processors.KindFilter{
+		Watch:   cfg.WatchKinds,
+		Exclude: cfg.ExcludeKinds,
+	}
+	if watchKinds != "" {
+		kindFilter.Watch = splitList(watchKinds)
+	}
+	if excludeKinds != "" {
+		kindFilter.Exclude = splitList(excludeKinds)
+	}
+	return kindFilter
+}
+
+// configLabelSelectors returns the label selectors of kinds and exclusions of the configuration
+// file, with the kinds spelled as the supported and custom kinds
+func configLabelSelectors(cfg *config.Config) (informers.LabelSelectors, error) {
+	selectors := informers.LabelSelectors{Exclude: cfg.LabelSelectors.Exclude}
+	kinds := processors.SupportedKinds()
+	for _, resource := range cfg.CustomResources {
+		kinds = append(kinds, resource.Kind)
+	}
+	for kind, selector := range cfg.LabelSelectors.Kinds {
+		i := slices.IndexFunc(kinds, func(known string) bool { return strings.EqualFold(known, kind) })
+		if i < 0 {
+			return informers.LabelSelectors{}, fmt.Errorf("label selector of unknown kind %q (supported: %s, and the custom resources)", kind, strings.Join(processors.SupportedKinds(), ", "))
+		}
+		if selectors.Kinds == nil {
+			selectors.Kinds = make(map[string]string, len(cfg.LabelSelectors.Kinds))
+		}
+		selectors.Kinds[kinds[i]] = selector
+	}
+	if err := selectors.Validate(); err != nil {
+		return informers.LabelSelectors{}, err
+	}
+	return selectors, nil
+}
+
+// configReloader reloads the configuration file when it changes or on SIGHUP, and applies the
+// new filters to the informer managers
+type configReloader struct {
+	path     string
+	interval time.Duration
+	explicit map[string]bool
+	// base holds the values of the reloadable flags before the file was applied, used when
+	// the file no longer sets them
+	base map[string]string
+
+	current  *config.Config
+	data     []byte
+	managers []*informers.Manager
+	contexts []*contextWatcher
+}
+
+// newConfigReloader creates the reloader of the configuration file loaded as cfg. It must be
+// created before the flags of the file are applied.
+func newConfigReloader(path string, cfg *config.Config, interval time.Duration, explicit map[string]bool) (*configReloader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	base := make(map[string]string, len(reloadableFlags))
+	for _, name := range reloadableFlags {
+		base[name] = flag.Lookup(name).Value.String()
+	}
+	return &configReloader{
+		path:     path,
+		interval: interval,
+		explicit: explicit,
+		base:     base,
+		current:  cfg,
+		data:     data,
+	}, nil
+}
+
+// Start reloads the configuration file on SIGHUP, and when its content changes, checked every
+// interval, until ctx is cancelled
+func (r *configReloader) Start(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-signals:
+			klog.Infof("Received SIGHUP, reloading %s", r.path)
+			r.reload(true)
+		case <-tick:
+			r.reload(false)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reload reads the configuration file and applies it if it changed, or if forced
+func (r *configReloader) reload(force bool) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		klog.Errorf("Failed to reload configuration: %v", err)
+		return
+	}
+	if !force && bytes.Equal(data, r.data) {
+		return
+	}
+	r.data = data
+	if !force {
+		klog.Infof("Configuration file %s changed, reloading", r.path)
+	}
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		klog.Errorf("Failed to reload configuration, keeping the current one: %v", err)
+		return
+	}
+	filters, err := r.filters(cfg)
+	if err != nil {
+		klog.Errorf("Failed to reload configuration, keeping the current one: %v", err)
+		return
+	}
+	for _, manager := range r.managers {
+		if err := manager.Reconfigure(filters); err != nil {
+			klog.Errorf("Failed to apply the reloaded configuration: %v", err)
+			return
+		}
+	}
+	for _, watcher := range r.contexts {
+		if err := watcher.reconfigure(filters); err != nil {
+			klog.Errorf("Context %s: failed to apply the reloaded configuration: %v", watcher.context, err)
+		}
+	}
+	if !reflect.DeepEqual(withoutReloadable(cfg), withoutReloadable(r.current)) {
+		klog.Warning("The configuration file has changes other than the label selectors, namespaces and watched kinds, they take effect on restart")
+	}
+	r.current = cfg
+}
+
+// filters returns the informer filters of a configuration
+func (r *configReloader) filters(cfg *config.Config) (informers.Filters, error) {
+	values, err := validateConfigFlags(cfg)
+	if err != nil {
+		return informers.Filters{}, err
+	}
+	value := func(name string) string {
+		if r.explicit[name] {
+			return flag.Lookup(name).Value.String()
+		}
+		if value, set := values[name]; set {
+			return value
+		}
+		return r.base[name]
+	}
+
+	filters := informers.Filters{
+		LabelSelector: value("label-selector"),
+		Namespaces:    splitList(value("namespaces")),
+		Kinds:         configKindFilter(cfg),
+	}
+	if err := filters.Kinds.Validate(); err != nil {
+		return informers.Filters{}, fmt.Errorf("invalid kind filter: %w", err)
+	}
+	if filters.LabelSelectors, err = configLabelSelectors(cfg); err != nil {
+		return informers.Filters{}, fmt.Errorf("invalid label selectors: %w", err)
+	}
+	return filters, nil
+}
+
+// withoutReloadable returns a copy of cfg without the values applied on reload
+func withoutReloadable(cfg *config.Config) config.Config {
+	stripped := *cfg
+	stripped.WatchKinds = nil
+	stripped.ExcludeKinds = nil
+	stripped.LabelSelectors = config.LabelSelectors{}
+	stripped.Flags = make(map[string]interface{}, len(cfg.Flags))
+	for name, value := range cfg.Flags {
+		stripped.Flags[name] = value
+	}
+	for _, name := range reloadableFlags {
+		delete(stripped.Flags, name)
+	}
+	return stripped
+}
@@ -0,0 +1,86 @@
+// Command golden checks the fixture sets under testdata/golden against their expected graph
+// and API responses, or regenerates the expected files with -update. See pkg/golden.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/golden"
+	"k8s.io/klog/v2"
+)
+
+func main() {
+	dir := flag.String("dir", "testdata/golden", "Directory holding the fixture sets")
+	update := flag.Bool("update", false, "Regenerate the expected files from the current behavior")
+	run := flag.String("run", "", "Only run the fixture sets whose path contains this string")
+	klog.InitFlags(nil)
+	flag.Set("logtostderr", "true")
+	flag.Set("v", "0")
+	flag.Parse()
+
+	dirs, err := golden.Discover(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list fixture sets: %v\n", err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	ran := 0
+	for _, setDir := range dirs {
+		if !strings.Contains(setDir, *run) {
+			continue
+		}
+		ran++
+		set, err := golden.Load(setDir)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", setDir, err)
+			failed++
+			continue
+		}
+		result, err := set.Run()
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", setDir, err)
+			failed++
+			continue
+		}
+
+		if *update {
+			if err := set.Update(result); err != nil {
+				fmt.Printf("FAIL %s: %v\n", setDir, err)
+				failed++
+				continue
+			}
+			fmt.Printf("updated %s\n", setDir)
+			continue
+		}
+		drifts, err := set.Compare(result)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", setDir, err)
+			failed++
+			continue
+		}
+		if len(drifts) > 0 {
+			fmt.Printf("FAIL %s\n", setDir)
+			for _, drift := range drifts {
+				fmt.Printf("  %s\n", strings.ReplaceAll(drift.String(), "\n", "\n  "))
+			}
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %s\n", setDir)
+	}
+
+	if ran == 0 {
+		fmt.Fprintf(os.Stderr, "No fixture sets found under %s\n", *dir)
+		os.Exit(2)
+	}
+	if failed > 0 {
+		if !*update {
+			fmt.Printf("%d of %d fixture set(s) drifted, run with -update to accept the changes\n", failed, ran)
+		}
+		os.Exit(1)
+	}
+}
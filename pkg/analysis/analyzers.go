@@ -0,0 +1,278 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// systemNamespaces hold cluster components whose objects are used outside the graph's view
+var systemNamespaces = map[string]bool{
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// Orphans finds resources nothing uses: ConfigMaps no workload references, PVCs no Pod mounts
+// and Services that route to no Pod. Secrets are not checked since image pull secrets and
+// Ingress TLS references are not tracked as edges.
+type Orphans struct{}
+
+func (Orphans) Name() string { return "orphans" }
+
+func (Orphans) Analyze(g graph.GraphInterface) []Finding {
+	var findings []Finding
+	for _, node := range g.GetAllNodes() {
+		if systemNamespaces[node.Namespace] || hasIncoming(node, graph.EdgeOwnership) {
+			continue
+		}
+
+		switch node.Kind {
+		case "ConfigMap":
+			if node.Name != "kube-root-ca.crt" && !hasIncoming(node, graph.EdgeConfigMapRef) {
+				findings = append(findings, newFinding(node, "unused-configmap", "No workload references this ConfigMap"))
+			}
+		case "PersistentVolumeClaim":
+			if !hasIncoming(node, graph.EdgePodVolume) {
+				findings = append(findings, newFinding(node, "unmounted-pvc", "No Pod mounts this claim"))
+			}
+		case "Service":
+			if node.Metadata != nil && node.Metadata.ServiceType == "ExternalName" {
+				continue
+			}
+			if !serviceHasEndpoints(g, node) {
+				findings = append(findings, newFinding(node, "service-without-endpoints", "The Service has no ready endpoints"))
+			}
+		}
+	}
+	return findings
+}
+
+// serviceHasEndpoints reports whether any EndpointSlice of a Service has ready endpoints
+func serviceHasEndpoints(g graph.GraphInterface, service *graph.Node) bool {
+	for toUID, edge := range service.OutgoingEdges {
+		if edge.Type != graph.EdgeHasEndpoints {
+			continue
+		}
+		if slice, exists := g.GetNode(toUID); exists && slice.StatusReason != graph.ReasonNoReadyEndpoints {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectorConflicts finds objects that select or target the same resources although
+// Kubernetes expects at most one of them: Pods covered by several PodDisruptionBudgets (the
+// eviction API refuses to evict them) and workloads scaled by several HorizontalPodAutoscalers
+// (which fight over the replica count).
+type SelectorConflicts struct{}
+
+func (SelectorConflicts) Name() string { return "selector-conflicts" }
+
+func (SelectorConflicts) Analyze(g graph.GraphInterface) []Finding {
+	var findings []Finding
+	for _, node := range g.GetAllNodes() {
+		switch node.Kind {
+		case "Pod":
+			if pdbs := incomingFrom(g, node, graph.EdgeServiceSelector, "PodDisruptionBudget"); len(pdbs) > 1 {
+				findings = append(findings, newFinding(node, "multiple-pdbs",
+					fmt.Sprintf("Pod is selected by %d PodDisruptionBudgets: %s", len(pdbs), strings.Join(pdbs, ", "))))
+			}
+		default:
+			if hpas := incomingFrom(g, node, graph.EdgeHPATarget, "HorizontalPodAutoscaler"); len(hpas) > 1 {
+				findings = append(findings, newFinding(node, "multiple-hpas",
+					fmt.Sprintf("%s is scaled by %d HorizontalPodAutoscalers: %s", node.Kind, len(hpas), strings.Join(hpas, ", "))))
+			}
+		}
+	}
+	return findings
+}
+
+// Spread finds replicated workloads whose Pods all run on the same cluster node, so a single
+// node failure takes down every replica, or in the same zone of a multi-zone cluster
+type Spread struct{}
+
+func (Spread) Name() string { return "spread" }
+
+func (Spread) Analyze(g graph.GraphInterface) []Finding {
+	clusterZones := make(map[string]bool)
+	for _, node := range g.GetNodesByNamespaceKind("", "Node") {
+		if node.Metadata != nil && node.Metadata.Zone != "" {
+			clusterZones[node.Metadata.Zone] = true
+		}
+	}
+
+	var findings []Finding
+	for _, node := range g.GetAllNodes() {
+		if node.Kind != "Deployment" && node.Kind != "StatefulSet" {
+			continue
+		}
+		if node.Metadata == nil || node.Metadata.Replicas == nil || node.Metadata.Replicas.Desired < 2 {
+			continue
+		}
+
+		nodeNames := make(map[string]bool)
+		zones := make(map[string]bool)
+		pods, zoned := 0, 0
+		for _, pod := range ownedPods(g, node) {
+			if pod.Metadata != nil && pod.Metadata.NodeName != "" {
+				nodeNames[pod.Metadata.NodeName] = true
+				pods++
+				if pod.Metadata.Zone != "" {
+					zones[pod.Metadata.Zone] = true
+					zoned++
+				}
+			}
+		}
+		if pods >= 2 && len(nodeNames) == 1 {
+			for name := range nodeNames {
+				findings = append(findings, newFinding(node, "single-node",
+					fmt.Sprintf("All %d scheduled Pods run on node %s", pods, name)))
+			}
+		} else if zoned == pods && pods >= 2 && len(zones) == 1 && len(clusterZones) > 1 {
+			for zone := range zones {
+				findings = append(findings, newFinding(node, "single-zone",
+					fmt.Sprintf("All %d scheduled Pods run in zone %s of %d", pods, zone, len(clusterZones))))
+			}
+		}
+	}
+	return findings
+}
+
+// Antipatterns finds common configuration mistakes: Pods without a controller, and containers
+// using mutable image tags or without resource requests or memory limits. Container findings
+// are reported once on the workload that owns the Pods.
+type Antipatterns struct{}
+
+func (Antipatterns) Name() string { return "antipatterns" }
+
+func (Antipatterns) Analyze(g graph.GraphInterface) []Finding {
+	var findings []Finding
+	type key struct {
+		uid              types.UID
+		check, container string
+	}
+	seen := make(map[key]bool)
+	report := func(target *graph.Node, check, container, message string) {
+		k := key{target.UID, check, container}
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		findings = append(findings, newFinding(target, check, message))
+	}
+
+	for _, node := range g.GetAllNodes() {
+		if node.Kind != "Pod" || systemNamespaces[node.Namespace] {
+			continue
+		}
+
+		owner := topOwner(g, node)
+		if owner == node {
+			report(node, "naked-pod", "", "Pod is not managed by a controller and will not be rescheduled")
+		}
+		if node.Metadata == nil {
+			continue
+		}
+
+		for _, container := range node.Metadata.Containers {
+			if container.Init {
+				continue
+			}
+			if mutableImageTag(container.Image) {
+				report(owner, "mutable-image-tag", container.Name,
+					fmt.Sprintf("Container %s uses image %s without a fixed tag or digest", container.Name, container.Image))
+			}
+			if len(container.Requests) == 0 {
+				report(owner, "no-resource-requests", container.Name,
+					fmt.Sprintf("Container %s has no resource requests", container.Name))
+			}
+			if container.Limits["memory"] == "" {
+				report(owner, "no-memory-limit", container.Name,
+					fmt.Sprintf("Container %s has no memory limit", container.Name))
+			}
+		}
+	}
+	return findings
+}
+
+// mutableImageTag reports whether an image reference has no tag, or the latest tag, and no digest
+func mutableImageTag(image string) bool {
+	if image == "" || strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i < 0 || name[i+1:] == "latest"
+}
+
+// hasIncoming reports whether a node has an incoming edge of a type
+func hasIncoming(node *graph.Node, edgeType graph.EdgeType) bool {
+	for _, edge := range node.IncomingEdges {
+		if edge.Type == edgeType {
+			return true
+		}
+	}
+	return false
+}
+
+// incomingFrom returns the names of the nodes of a kind with an edge of a type to node
+func incomingFrom(g graph.GraphInterface, node *graph.Node, edgeType graph.EdgeType, kind string) []string {
+	var names []string
+	for fromUID, edge := range node.IncomingEdges {
+		if edge.Type != edgeType {
+			continue
+		}
+		if from, exists := g.GetNode(fromUID); exists && from.Kind == kind {
+			names = append(names, from.Name)
+		}
+	}
+	return names
+}
+
+// ownedPods returns the Pods owned by a workload directly or through ReplicaSets
+func ownedPods(g graph.GraphInterface, workload *graph.Node) []*graph.Node {
+	var pods []*graph.Node
+	for toUID, edge := range workload.OutgoingEdges {
+		if edge.Type != graph.EdgeOwnership {
+			continue
+		}
+		child, exists := g.GetNode(toUID)
+		if !exists {
+			continue
+		}
+		switch child.Kind {
+		case "Pod":
+			pods = append(pods, child)
+		case "ReplicaSet":
+			pods = append(pods, ownedPods(g, child)...)
+		}
+	}
+	return pods
+}
+
+// topOwner follows ownership edges up from a node to its top-level owner, e.g. from a Pod to
+// its Deployment. A node without owners is its own top owner.
+func topOwner(g graph.GraphInterface, node *graph.Node) *graph.Node {
+	current := node
+	visited := map[types.UID]bool{node.UID: true}
+	for {
+		var owner *graph.Node
+		for fromUID, edge := range current.IncomingEdges {
+			if edge.Type != graph.EdgeOwnership {
+				continue
+			}
+			if n, exists := g.GetNode(fromUID); exists && !visited[fromUID] {
+				owner = n
+				break
+			}
+		}
+		if owner == nil {
+			return current
+		}
+		visited[owner.UID] = true
+		current = owner
+	}
+}
@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/ammarlakis/astrolabe/pkg/deprecations"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// Deprecations finds resources applied with API versions that are deprecated or removed in
+// the Kubernetes version of the table, as flagged on their nodes, and Helm releases whose
+// deployed manifest uses such API versions, which makes their next upgrade fail once the API
+// is removed.
+type Deprecations struct {
+	Table *deprecations.Table
+}
+
+func (Deprecations) Name() string { return "deprecations" }
+
+func (d Deprecations) Analyze(g graph.GraphInterface) []Finding {
+	var findings []Finding
+	for _, node := range g.GetAllNodes() {
+		if node.Metadata == nil {
+			continue
+		}
+		if deprecation := node.Metadata.Deprecation; deprecation != nil {
+			findings = append(findings, newFinding(node, deprecationCheck(deprecation),
+				deprecationMessage(node.Kind, deprecation)))
+		}
+
+		revision := node.Metadata.HelmRevision
+		if revision == nil || !d.deployed(g, revision) {
+			continue
+		}
+		for _, api := range revision.APIs {
+			if deprecation := d.Table.Check(api.APIVersion, api.Kind); deprecation != nil {
+				finding := newFinding(node, "release-"+deprecationCheck(deprecation),
+					fmt.Sprintf("Revision %d of release %s: %s", revision.Revision, revision.Release, deprecationMessage(api.Kind, deprecation)))
+				finding.Release = revision.Release
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// deployed reports whether a revision is the deployed one of its release
+func (d Deprecations) deployed(g graph.GraphInterface, revision *graph.HelmRevision) bool {
+	current := graph.DeployedRevision(g.GetReleaseHistory(revision.Release), revision.Namespace)
+	return current != nil && current.Revision == revision.Revision
+}
+
+func deprecationCheck(deprecation *graph.APIDeprecation) string {
+	if deprecation.Removed {
+		return "removed-api"
+	}
+	return "deprecated-api"
+}
+
+func deprecationMessage(kind string, deprecation *graph.APIDeprecation) string {
+	message := fmt.Sprintf("%s %s is deprecated since Kubernetes %s", deprecation.APIVersion, kind, deprecation.DeprecatedIn)
+	switch {
+	case deprecation.Removed:
+		message = fmt.Sprintf("%s %s was removed in Kubernetes %s", deprecation.APIVersion, kind, deprecation.RemovedIn)
+	case deprecation.RemovedIn != "":
+		message += " and removed in " + deprecation.RemovedIn
+	}
+	if deprecation.Replacement != "" {
+		return message + "; use " + deprecation.Replacement
+	}
+	return message + "; there is no replacement"
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+// Analyzer is an analysis of the whole graph that is too expensive to run on every request
+type Analyzer interface {
+	// Name identifies the analysis in the API, e.g. orphans
+	Name() string
+	// Analyze returns the findings of the analysis
+	Analyze(g graph.GraphInterface) []Finding
+}
+
+// Finding is a resource flagged by an analysis
+type Finding struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	Release   string `json:"release,omitempty"`
+	// Check is the rule of the analysis that flagged the resource, e.g. unused-configmap
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// newFinding creates a finding about a node
+func newFinding(node *graph.Node, check, message string) Finding {
+	return Finding{
+		Kind:      node.Kind,
+		Namespace: node.Namespace,
+		Name:      node.Name,
+		UID:       string(node.UID),
+		Release:   node.HelmRelease,
+		Check:     check,
+		Message:   message,
+	}
+}
+
+// Result is the cached outcome of an analysis
+type Result struct {
+	Name       string    `json:"name"`
+	ComputedAt time.Time `json:"computedAt"`
+	// Generation is the graph generation the analysis ran on
+	Generation uint64    `json:"generation"`
+	Duration   string    `json:"duration"`
+	Count      int       `json:"count"`
+	Findings   []Finding `json:"findings"`
+}
+
+// Scheduler runs the analyzers in the background whenever the graph changed, at most once per
+// interval, and caches their results so requests never wait for an analysis
+type Scheduler struct {
+	graph     graph.GraphInterface
+	analyzers []Analyzer
+	interval  time.Duration
+
+	mu      sync.RWMutex
+	results map[string]*Result
+}
+
+// NewScheduler creates a scheduler for the analyzers
+func NewScheduler(g graph.GraphInterface, interval time.Duration, analyzers ...Analyzer) *Scheduler {
+	return &Scheduler{
+		graph:     g,
+		analyzers: analyzers,
+		interval:  interval,
+		results:   make(map[string]*Result, len(analyzers)),
+	}
+}
+
+// DefaultAnalyzers returns the built-in analyses
+func DefaultAnalyzers() []Analyzer {
+	return []Analyzer{
+		Orphans{},
+		SelectorConflicts{},
+		Spread{},
+		Antipatterns{},
+	}
+}
+
+// Start runs the analyzers right away and again on every tick the graph changed, until ctx
+// is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	generation := s.runAll()
+	for {
+		select {
+		case <-ticker.C:
+			if s.graph.Generation() != generation {
+				generation = s.runAll()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runAll runs every analyzer and returns the graph generation the results are based on
+func (s *Scheduler) runAll() uint64 {
+	generation := s.graph.Generation()
+	for _, analyzer := range s.analyzers {
+		start := time.Now()
+		findings := analyzer.Analyze(s.graph)
+		duration := time.Since(start)
+
+		sort.Slice(findings, func(i, j int) bool {
+			a, b := findings[i], findings[j]
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.Check < b.Check
+		})
+		if findings == nil {
+			findings = make([]Finding, 0)
+		}
+
+		s.mu.Lock()
+		s.results[analyzer.Name()] = &Result{
+			Name:       analyzer.Name(),
+			ComputedAt: start,
+			Generation: generation,
+			Duration:   duration.String(),
+			Count:      len(findings),
+			Findings:   findings,
+		}
+		s.mu.Unlock()
+
+		metrics.AnalysisDuration.WithLabelValues(analyzer.Name()).Observe(duration.Seconds())
+		metrics.AnalysisFindings.WithLabelValues(analyzer.Name()).Set(float64(len(findings)))
+		klog.V(3).Infof("Analysis %s: %d finding(s) in %v", analyzer.Name(), len(findings), duration)
+	}
+	return generation
+}
+
+// Names returns the names of the analyses, in order
+func (s *Scheduler) Names() []string {
+	names := make([]string, 0, len(s.analyzers))
+	for _, analyzer := range s.analyzers {
+		names = append(names, analyzer.Name())
+	}
+	return names
+}
+
+// Known reports whether an analysis exists
+func (s *Scheduler) Known(name string) bool {
+	for _, analyzer := range s.analyzers {
+		if analyzer.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Result returns the cached result of an analysis, or nil before it first ran
+func (s *Scheduler) Result(name string) *Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.results[name]
+}
@@ -0,0 +1,282 @@
+// Package anomalies detects Pods behaving like CrashLoopBackOff: restarting or flapping between
+// statuses repeatedly within a window. The restart counts of the containers and the status of
+// each Pod are tracked as they change, and a Pod is flagged when its restarts, or its status
+// changes, within the window reach the thresholds of its namespace.
+package anomalies
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Reasons a Pod is flagged for
+const (
+	// ReasonRestarts is a Pod whose containers restarted at least the restart threshold
+	ReasonRestarts = "restarts"
+	// ReasonFlapping is a Pod whose status changed at least the flap threshold
+	ReasonFlapping = "flapping"
+)
+
+// Default thresholds, within the window
+const (
+	DefaultRestarts = 3
+	DefaultFlaps    = 6
+)
+
+// Thresholds are the number of restarts and status changes within the window from which a Pod
+// is flagged. 0 inherits the default; a negative threshold never flags.
+type Thresholds struct {
+	Restarts int `json:"restarts"`
+	Flaps    int `json:"flaps"`
+}
+
+// inherit returns t with its unset thresholds taken from defaults
+func (t Thresholds) inherit(defaults Thresholds) Thresholds {
+	if t.Restarts == 0 {
+		t.Restarts = defaults.Restarts
+	}
+	if t.Flaps == 0 {
+		t.Flaps = defaults.Flaps
+	}
+	return t
+}
+
+// reached returns the reasons to flag a Pod with the given restarts and status changes
+func (t Thresholds) reached(restarts, flaps int) []string {
+	var reasons []string
+	if t.Restarts > 0 && restarts >= t.Restarts {
+		reasons = append(reasons, ReasonRestarts)
+	}
+	if t.Flaps > 0 && flaps >= t.Flaps {
+		reasons = append(reasons, ReasonFlapping)
+	}
+	return reasons
+}
+
+// NamespaceThresholds overrides the thresholds of the namespaces matching a pattern
+type NamespaceThresholds struct {
+	// Namespace is a path.Match pattern
+	Namespace string
+	Thresholds
+}
+
+// Anomaly is a Pod flagged for restarting or flapping
+type Anomaly struct {
+	UID       types.UID
+	Namespace string
+	Name      string
+	// Restarts and Flaps are the container restarts and status changes within the window
+	Restarts int
+	Flaps    int
+	// Thresholds are those of the Pod's namespace
+	Thresholds Thresholds
+	Reasons    []string
+	// Since is the first restart or status change within the window
+	Since time.Time
+	// LastRestart is zero when no container restarted within the window
+	LastRestart time.Time
+}
+
+// pod is the tracked restarts and status changes of a Pod
+type pod struct {
+	namespace string
+	name      string
+	// restartCount is the sum of the restart counts of the containers when last seen
+	restartCount int32
+	status       graph.ResourceStatus
+	// restarts and changes hold when each restart and status change was seen, oldest first
+	restarts []time.Time
+	changes  []time.Time
+	flagged  bool
+}
+
+// prune drops the restarts and changes before cutoff
+func (p *pod) prune(cutoff time.Time) {
+	p.restarts = after(p.restarts, cutoff)
+	p.changes = after(p.changes, cutoff)
+}
+
+func after(times []time.Time, cutoff time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(cutoff) })
+	return times[i:]
+}
+
+// Detector tracks the restarts and status changes of every Pod. It is a
+// processors.ChangeObserver.
+type Detector struct {
+	window     time.Duration
+	defaults   Thresholds
+	namespaces []NamespaceThresholds
+
+	mu   sync.Mutex
+	pods map[types.UID]*pod
+}
+
+// New creates a detector flagging the Pods that restart, or change status, as often as their
+// thresholds within window. The thresholds of the first pattern matching a namespace apply to
+// it, and defaults to the others; unset thresholds are inherited from defaults, and unset
+// defaults are DefaultRestarts and DefaultFlaps.
+func New(window time.Duration, defaults Thresholds, namespaces []NamespaceThresholds) (*Detector, error) {
+	for _, ns := range namespaces {
+		if _, err := path.Match(ns.Namespace, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", ns.Namespace, err)
+		}
+	}
+	return &Detector{
+		window:     window,
+		defaults:   defaults.inherit(Thresholds{Restarts: DefaultRestarts, Flaps: DefaultFlaps}),
+		namespaces: namespaces,
+		pods:       make(map[types.UID]*pod),
+	}, nil
+}
+
+// Thresholds returns the thresholds of a namespace
+func (d *Detector) Thresholds(namespace string) Thresholds {
+	for _, ns := range d.namespaces {
+		if matched, _ := path.Match(ns.Namespace, namespace); matched {
+			return ns.Thresholds.inherit(d.defaults)
+		}
+	}
+	return d.defaults
+}
+
+// Window returns the window within which restarts and status changes are counted
+func (d *Detector) Window() time.Duration {
+	return d.window
+}
+
+// NodeChanged records the restarts and status changes of Pods. The restarts counted before a
+// Pod is first seen, e.g. on startup, are not attributed to the window.
+func (d *Detector) NodeChanged(old, updated *graph.Node) {
+	node := updated
+	if node == nil {
+		node = old
+	}
+	if node.Kind != "Pod" || node.Cluster != "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if updated == nil {
+		delete(d.pods, old.UID)
+		return
+	}
+	now := time.Now()
+	restartCount := restarts(updated)
+	p, exists := d.pods[updated.UID]
+	if !exists {
+		d.pods[updated.UID] = &pod{
+			namespace:    updated.Namespace,
+			name:         updated.Name,
+			restartCount: restartCount,
+			status:       updated.Status,
+		}
+		return
+	}
+
+	// Restart counts only drop when containers are renamed or removed, which restarts nothing
+	for i := p.restartCount; i < restartCount; i++ {
+		p.restarts = append(p.restarts, now)
+	}
+	p.restartCount = restartCount
+	if updated.Status != p.status {
+		p.changes = append(p.changes, now)
+		p.status = updated.Status
+	}
+	p.prune(now.Add(-d.window))
+
+	reasons := d.Thresholds(p.namespace).reached(len(p.restarts), len(p.changes))
+	if len(reasons) > 0 && !p.flagged {
+		p.flagged = true
+		metrics.PodAnomaliesDetected.Inc()
+		klog.Warningf("Pod %s/%s restarted %d time(s) and changed status %d time(s) in the last %v",
+			p.namespace, p.name, len(p.restarts), len(p.changes), d.window)
+	}
+}
+
+// restarts returns the sum of the restart counts of the containers of a Pod
+func restarts(node *graph.Node) int32 {
+	if node.Metadata == nil {
+		return 0
+	}
+	var count int32
+	for _, container := range node.Metadata.Containers {
+		count += container.Restarts
+	}
+	return count
+}
+
+// Start checks the Pods at a fraction of the window until ctx is cancelled
+func (d *Detector) Start(ctx context.Context) {
+	ticker := time.NewTicker(max(d.window/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check forgets the restarts and status changes older than the window, clears the flag of the
+// Pods that settled, and returns the number of flagged Pods
+func (d *Detector) Check() int {
+	return len(d.Anomalies(time.Now()))
+}
+
+// Anomalies returns the flagged Pods at now, by namespace and name
+func (d *Detector) Anomalies(now time.Time) []Anomaly {
+	cutoff := now.Add(-d.window)
+	d.mu.Lock()
+	var anomalies []Anomaly
+	for uid, p := range d.pods {
+		p.prune(cutoff)
+		thresholds := d.Thresholds(p.namespace)
+		reasons := thresholds.reached(len(p.restarts), len(p.changes))
+		p.flagged = len(reasons) > 0
+		if !p.flagged {
+			continue
+		}
+
+		anomaly := Anomaly{
+			UID:        uid,
+			Namespace:  p.namespace,
+			Name:       p.name,
+			Restarts:   len(p.restarts),
+			Flaps:      len(p.changes),
+			Thresholds: thresholds,
+			Reasons:    reasons,
+		}
+		if len(p.restarts) > 0 {
+			anomaly.Since = p.restarts[0]
+			anomaly.LastRestart = p.restarts[len(p.restarts)-1]
+		}
+		if len(p.changes) > 0 && (anomaly.Since.IsZero() || p.changes[0].Before(anomaly.Since)) {
+			anomaly.Since = p.changes[0]
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Namespace != anomalies[j].Namespace {
+			return anomalies[i].Namespace < anomalies[j].Namespace
+		}
+		return anomalies[i].Name < anomalies[j].Name
+	})
+	metrics.PodAnomalies.Set(float64(len(anomalies)))
+	return anomalies
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/actions"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const maxActionBodyBytes = 64 * 1024
+
+// handleRestart triggers a rollout restart of a tracked workload
+func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
+	req, node, caller, ok := s.prepareAction(w, r, actions.ActionRestart)
+	if !ok {
+		return
+	}
+
+	target := actions.Target{Kind: node.Kind, Namespace: node.Namespace, Name: node.Name}
+	if err := s.actions.Restart(r.Context(), caller, target); err != nil {
+		writeActionError(w, err)
+		return
+	}
+
+	writeJSON(w, actionResponse(actions.ActionRestart, node, caller, req.Replicas))
+}
+
+// handleScale sets the replica count of a tracked workload
+func (s *Server) handleScale(w http.ResponseWriter, r *http.Request) {
+	req, node, caller, ok := s.prepareAction(w, r, actions.ActionScale)
+	if !ok {
+		return
+	}
+
+	target := actions.Target{Kind: node.Kind, Namespace: node.Namespace, Name: node.Name}
+	if err := s.actions.Scale(r.Context(), caller, target, *req.Replicas); err != nil {
+		writeActionError(w, err)
+		return
+	}
+
+	writeJSON(w, actionResponse(actions.ActionScale, node, caller, req.Replicas))
+}
+
+// prepareAction decodes the request, authenticates the caller, resolves the target in the
+// graph and checks that the caller is allowed to perform the action. It writes the error
+// response when it fails. The caller is authenticated before the target is looked up, and
+// unknown targets get the same response as denied ones, so the endpoint does not reveal which
+// resources exist to callers who may not act on them. Actions are exempt from API tokens, so
+// the target is looked up in the whole graph: the SubjectAccessReview of the caller's
+// Kubernetes identity is the only authorization check.
+func (s *Server) prepareAction(w http.ResponseWriter, r *http.Request, action actions.Action) (*ActionRequest, *graph.Node, *actions.Caller, bool) {
+	var req ActionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxActionBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return nil, nil, nil, false
+	}
+	if action == actions.ActionScale && (req.Replicas == nil || *req.Replicas < 0) {
+		writeError(w, http.StatusBadRequest, "replicas must be set to a non-negative number")
+		return nil, nil, nil, false
+	}
+
+	if req.UID == "" && req.Kind != "" && !actions.Supports(action, req.Kind) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is not supported for %s", action, req.Kind))
+		return nil, nil, nil, false
+	}
+
+	caller, err := s.actions.Authenticate(r.Context(), bearerToken(r))
+	if err != nil {
+		writeActionError(w, err)
+		return nil, nil, nil, false
+	}
+
+	node := s.resolveActionTarget(&req)
+	if node == nil || !actions.Supports(action, node.Kind) {
+		writeTargetUnavailable(w)
+		return nil, nil, nil, false
+	}
+
+	target := actions.Target{Kind: node.Kind, Namespace: node.Namespace, Name: node.Name}
+	if err := s.actions.Authorize(r.Context(), caller, action, target); err != nil {
+		if errors.Is(err, actions.ErrForbidden) {
+			writeTargetUnavailable(w)
+		} else {
+			writeActionError(w, err)
+		}
+		return nil, nil, nil, false
+	}
+
+	return &req, node, caller, true
+}
+
+// writeTargetUnavailable answers an action on a target that is unknown or that the caller
+// may not act on, without telling which
+func writeTargetUnavailable(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "target resource not found, or not allowed to perform this action")
+}
+
+// resolveActionTarget finds the target by UID or by kind, namespace and name
+func (s *Server) resolveActionTarget(req *ActionRequest) *graph.Node {
+	if req.UID != "" {
+		node, exists := s.graph.GetNode(types.UID(req.UID))
+		if !exists {
+			return nil
+		}
+		return node
+	}
+
+	if req.Kind == "" || req.Name == "" {
+		return nil
+	}
+	if node, exists := s.graph.GetNodeByRef(req.Namespace, req.Kind, req.Name); exists {
+		return node
+	}
+	return nil
+}
+
+func actionResponse(action actions.Action, node *graph.Node, caller *actions.Caller, replicas *int32) ActionResponse {
+	resp := ActionResponse{
+		Action:    string(action),
+		Kind:      node.Kind,
+		Namespace: node.Namespace,
+		Name:      node.Name,
+		UID:       string(node.UID),
+		User:      caller.Username,
+	}
+	if action == actions.ActionScale {
+		resp.Replicas = replicas
+	}
+	return resp
+}
+
+// writeActionError maps action and Kubernetes API errors to HTTP statuses
+func writeActionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, actions.ErrUnauthenticated):
+		writeError(w, http.StatusUnauthorized, "a valid Kubernetes bearer token is required")
+	case errors.Is(err, actions.ErrForbidden):
+		writeError(w, http.StatusForbidden, "not allowed to perform this action")
+	case errors.Is(err, actions.ErrUnsupported):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case apierrors.IsNotFound(err):
+		writeError(w, http.StatusNotFound, err.Error())
+	case apierrors.IsConflict(err), apierrors.IsInvalid(err):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
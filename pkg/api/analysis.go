@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+)
+
+// handleAnalyses lists the background analyses and when they last ran
+func (s *Server) handleAnalyses(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiateFormat(w, r, formatJSON, formatCSV)
+	if !ok {
+		return
+	}
+	var columns []csvColumn[AnalysisSummary]
+	if format == formatCSV {
+		if columns, ok = selectColumns(w, r, analysisSummaryColumns, defaultAnalysisSummaryColumns); !ok {
+			return
+		}
+	}
+
+	scoped := !tenancy.FromContext(r.Context()).Unrestricted()
+	summaries := make([]AnalysisSummary, 0)
+	for _, name := range s.analyses.Names() {
+		summary := AnalysisSummary{Name: name}
+		if result := s.analyses.Result(name); result != nil {
+			summary.Computed = true
+			summary.ComputedAt = &result.ComputedAt
+			summary.Count = result.Count
+			if scoped {
+				summary.Count = len(scopedFindings(s.graphFor(r.Context()), result.Findings))
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	if format == formatCSV {
+		writeCSV(w, "analyses.csv", columns, summaries)
+		return
+	}
+	writeJSON(w, summaries)
+}
+
+// handleAnalysis returns the cached result of an analysis. Analyses run in the background, so
+// the findings reflect the graph at computedAt.
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiateFormat(w, r, formatJSON, formatCSV)
+	if !ok {
+		return
+	}
+	var columns []csvColumn[analysis.Finding]
+	if format == formatCSV {
+		if columns, ok = selectColumns(w, r, findingColumns, defaultFindingColumns); !ok {
+			return
+		}
+	}
+
+	name := r.PathValue("name")
+	if !s.analyses.Known(name) {
+		writeError(w, http.StatusNotFound, "unknown analysis "+name)
+		return
+	}
+	result := s.analyses.Result(name)
+	if result == nil {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, "analysis "+name+" has not run yet")
+		return
+	}
+
+	findings := result.Findings
+	if !tenancy.FromContext(r.Context()).Unrestricted() {
+		findings = scopedFindings(s.graphFor(r.Context()), findings)
+	}
+	namespace := r.URL.Query().Get("namespace")
+	if format == formatCSV {
+		if namespace != "" {
+			findings = filterFindings(findings, namespace)
+		}
+		writeCSV(w, name+".csv", columns, findings)
+		return
+	}
+	if namespace == "" && len(findings) == len(result.Findings) {
+		writeJSON(w, result)
+		return
+	}
+
+	filtered := *result
+	filtered.Findings = filterFindings(findings, namespace)
+	filtered.Count = len(filtered.Findings)
+	writeJSON(w, filtered)
+}
+
+// filterFindings returns the findings in the namespace, or all of them
+func filterFindings(findings []analysis.Finding, namespace string) []analysis.Finding {
+	filtered := make([]analysis.Finding, 0)
+	for _, finding := range findings {
+		if namespace == "" || finding.Namespace == namespace {
+			filtered = append(filtered, finding)
+		}
+	}
+	return filtered
+}
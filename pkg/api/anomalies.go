@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleAnomalies lists the Pods flagged for restarting or flapping within the anomaly window
+func (s *Server) handleAnomalies(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	namespace, release := query.Get("namespace"), query.Get("release")
+
+	resp := AnomaliesResponse{Window: s.anomalies.Window().String(), Anomalies: make([]PodAnomaly, 0)}
+	for _, anomaly := range s.anomalies.Anomalies(time.Now()) {
+		if namespace != "" && anomaly.Namespace != namespace {
+			continue
+		}
+		// Pods out of the token's scope are not in its graph
+		pod, exists := g.GetNode(anomaly.UID)
+		if !exists {
+			continue
+		}
+		workload := topOwner(g, pod)
+		podRelease := pod.HelmRelease
+		if podRelease == "" {
+			podRelease = workload.HelmRelease
+		}
+		if release != "" && podRelease != release {
+			continue
+		}
+
+		flagged := PodAnomaly{
+			UID:              string(pod.UID),
+			Namespace:        pod.Namespace,
+			Name:             pod.Name,
+			Release:          podRelease,
+			Status:           string(pod.Status),
+			StatusReason:     pod.StatusReason,
+			Reasons:          anomaly.Reasons,
+			Restarts:         anomaly.Restarts,
+			Flaps:            anomaly.Flaps,
+			RestartThreshold: anomaly.Thresholds.Restarts,
+			FlapThreshold:    anomaly.Thresholds.Flaps,
+			Since:            anomaly.Since,
+		}
+		if workload != pod {
+			flagged.Workload = workload.Kind + "/" + workload.Name
+		}
+		if !anomaly.LastRestart.IsZero() {
+			flagged.LastRestart = &anomaly.LastRestart
+		}
+		resp.Anomalies = append(resp.Anomalies, flagged)
+	}
+	writeJSON(w, resp)
+}
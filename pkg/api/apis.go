@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/informers"
+)
+
+// handleClusterAPIs lists the API groups and resources served by the cluster, as cached by
+// the informer manager, and whether their kinds are watched. ?watched=true|false keeps only
+// the watched or unwatched resources, and ?group= a single API group ("core" for the core group).
+func (s *Server) handleClusterAPIs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	watched := query.Get("watched")
+	if watched != "" && watched != "true" && watched != "false" {
+		writeError(w, http.StatusBadRequest, "watched must be true or false")
+		return
+	}
+	group, filterGroup := query.Get("group"), query.Has("group")
+	if group == "core" {
+		group = ""
+	}
+
+	report := s.apis.APIs()
+	groups := make([]informers.APIGroup, 0, len(report.Groups))
+	for _, apiGroup := range report.Groups {
+		if filterGroup && apiGroup.Name != group {
+			continue
+		}
+		if watched != "" {
+			resources := make([]informers.APIResource, 0)
+			for _, resource := range apiGroup.Resources {
+				if resource.Watched == (watched == "true") {
+					resources = append(resources, resource)
+				}
+			}
+			if len(resources) == 0 {
+				continue
+			}
+			apiGroup.Resources = resources
+		}
+		groups = append(groups, apiGroup)
+	}
+	report.Groups = groups
+	writeJSON(w, report)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// handleApplications lists applications formed by the configured groupers (Helm releases,
+// ArgoCD applications, app.kubernetes.io/part-of groups, ...)
+func (s *Server) handleApplications(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	source := query.Get("source")
+	namespace := query.Get("namespace")
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+
+	apps := make([]Application, 0)
+	for _, app := range g.GetApplications() {
+		if source != "" && app.Source != source {
+			continue
+		}
+
+		row := SummaryRow{}
+		for _, node := range exclude.apply(app.Nodes) {
+			if namespace != "" && node.Namespace != namespace {
+				continue
+			}
+			row.add(node.Status)
+		}
+		if row.Total == 0 {
+			continue
+		}
+
+		apps = append(apps, Application{
+			Name:       app.Name,
+			Source:     app.Source,
+			Namespaces: applicationNamespaces(app, namespace),
+			Total:      row.Total,
+			Ready:      row.Ready,
+			Pending:    row.Pending,
+			Error:      row.Error,
+			Unknown:    row.Unknown,
+		})
+	}
+
+	writeJSON(w, apps)
+}
+
+func applicationNamespaces(app graph.Application, namespace string) []string {
+	if namespace != "" {
+		return []string{namespace}
+	}
+	return app.Namespaces
+}
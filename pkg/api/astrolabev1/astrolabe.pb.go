@@ -0,0 +1,1550 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: astrolabe/v1/astrolabe.proto
+
+package astrolabev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Filter selects part of the graph. Empty fields match everything.
+type Filter struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Helm release name
+	Release string `protobuf:"bytes,1,opt,name=release,proto3" json:"release,omitempty"`
+	// Namespace (cluster-scoped resources are always included)
+	Namespace string `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// Kinds to leave out, e.g. Secret or *.coordination.k8s.io (see the excludeKinds parameter
+	// of the HTTP API)
+	ExcludeKinds  []string `protobuf:"bytes,3,rep,name=exclude_kinds,json=excludeKinds,proto3" json:"exclude_kinds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Filter) Reset() {
+	*x = Filter{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Filter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Filter) ProtoMessage() {}
+
+func (x *Filter) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Filter.ProtoReflect.Descriptor instead.
+func (*Filter) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Filter) GetRelease() string {
+	if x != nil {
+		return x.Release
+	}
+	return ""
+}
+
+func (x *Filter) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Filter) GetExcludeKinds() []string {
+	if x != nil {
+		return x.ExcludeKinds
+	}
+	return nil
+}
+
+type GetGraphRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGraphRequest) Reset() {
+	*x = GetGraphRequest{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGraphRequest) ProtoMessage() {}
+
+func (x *GetGraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGraphRequest.ProtoReflect.Descriptor instead.
+func (*GetGraphRequest) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetGraphRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type GetResourcesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResourcesRequest) Reset() {
+	*x = GetResourcesRequest{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResourcesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResourcesRequest) ProtoMessage() {}
+
+func (x *GetResourcesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResourcesRequest.ProtoReflect.Descriptor instead.
+func (*GetResourcesRequest) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetResourcesRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type GetResourcesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*Node                `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResourcesResponse) Reset() {
+	*x = GetResourcesResponse{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResourcesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResourcesResponse) ProtoMessage() {}
+
+func (x *GetResourcesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResourcesResponse.ProtoReflect.Descriptor instead.
+func (*GetResourcesResponse) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetResourcesResponse) GetNodes() []*Node {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+type WatchGraphRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchGraphRequest) Reset() {
+	*x = WatchGraphRequest{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchGraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchGraphRequest) ProtoMessage() {}
+
+func (x *WatchGraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchGraphRequest.ProtoReflect.Descriptor instead.
+func (*WatchGraphRequest) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WatchGraphRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type Graph struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Nodes []*Node                `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Edges []*Edge                `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
+	// Generation of the graph the response was built from
+	Generation    uint64 `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Graph) Reset() {
+	*x = Graph{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Graph) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Graph) ProtoMessage() {}
+
+func (x *Graph) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Graph.ProtoReflect.Descriptor instead.
+func (*Graph) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Graph) GetNodes() []*Node {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *Graph) GetEdges() []*Edge {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
+func (x *Graph) GetGeneration() uint64 {
+	if x != nil {
+		return x.Generation
+	}
+	return 0
+}
+
+// GraphUpdate is a message of the WatchGraph stream
+type GraphUpdate struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Generation uint64                 `protobuf:"varint,1,opt,name=generation,proto3" json:"generation,omitempty"`
+	// Set on the first message, which holds the full graph; later messages hold changes only
+	Initial bool `protobuf:"varint,2,opt,name=initial,proto3" json:"initial,omitempty"`
+	// Added or changed nodes
+	Nodes []*Node `protobuf:"bytes,3,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	// UIDs of removed nodes (or nodes that no longer match the filter)
+	DeletedNodes []string `protobuf:"bytes,4,rep,name=deleted_nodes,json=deletedNodes,proto3" json:"deleted_nodes,omitempty"`
+	// Added or changed edges
+	Edges []*Edge `protobuf:"bytes,5,rep,name=edges,proto3" json:"edges,omitempty"`
+	// Removed edges, identified by their endpoints
+	DeletedEdges  []*EdgeRef `protobuf:"bytes,6,rep,name=deleted_edges,json=deletedEdges,proto3" json:"deleted_edges,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GraphUpdate) Reset() {
+	*x = GraphUpdate{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GraphUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GraphUpdate) ProtoMessage() {}
+
+func (x *GraphUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GraphUpdate.ProtoReflect.Descriptor instead.
+func (*GraphUpdate) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GraphUpdate) GetGeneration() uint64 {
+	if x != nil {
+		return x.Generation
+	}
+	return 0
+}
+
+func (x *GraphUpdate) GetInitial() bool {
+	if x != nil {
+		return x.Initial
+	}
+	return false
+}
+
+func (x *GraphUpdate) GetNodes() []*Node {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *GraphUpdate) GetDeletedNodes() []string {
+	if x != nil {
+		return x.DeletedNodes
+	}
+	return nil
+}
+
+func (x *GraphUpdate) GetEdges() []*Edge {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
+func (x *GraphUpdate) GetDeletedEdges() []*EdgeRef {
+	if x != nil {
+		return x.DeletedEdges
+	}
+	return nil
+}
+
+type Node struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Uid               string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Name              string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Namespace         string                 `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind              string                 `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	ApiVersion        string                 `protobuf:"bytes,5,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
+	ResourceVersion   string                 `protobuf:"bytes,6,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	Labels            map[string]string      `protobuf:"bytes,7,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CreationTimestamp *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=creation_timestamp,json=creationTimestamp,proto3" json:"creation_timestamp,omitempty"`
+	// Ready, Pending, Error or Unknown
+	Status   string    `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`
+	Message  string    `protobuf:"bytes,10,opt,name=message,proto3" json:"message,omitempty"`
+	Chart    string    `protobuf:"bytes,11,opt,name=chart,proto3" json:"chart,omitempty"`
+	Release  string    `protobuf:"bytes,12,opt,name=release,proto3" json:"release,omitempty"`
+	Metadata *Metadata `protobuf:"bytes,13,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// Machine-readable code for the status, e.g. ReplicasUnavailable or ImagePullBackOff
+	Reason string `protobuf:"bytes,14,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Set on the tombstones of deleted resources, returned when deleted resources are requested
+	DeletedAt     *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Node) Reset() {
+	*x = Node{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Node) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Node) ProtoMessage() {}
+
+func (x *Node) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Node.ProtoReflect.Descriptor instead.
+func (*Node) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Node) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *Node) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Node) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Node) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Node) GetApiVersion() string {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return ""
+}
+
+func (x *Node) GetResourceVersion() string {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return ""
+}
+
+func (x *Node) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Node) GetCreationTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreationTimestamp
+	}
+	return nil
+}
+
+func (x *Node) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Node) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Node) GetChart() string {
+	if x != nil {
+		return x.Chart
+	}
+	return ""
+}
+
+func (x *Node) GetRelease() string {
+	if x != nil {
+		return x.Release
+	}
+	return ""
+}
+
+func (x *Node) GetMetadata() *Metadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Node) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Node) GetDeletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return nil
+}
+
+// Metadata holds the kind-specific details of a resource
+type Metadata struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Pod
+	NodeName     string       `protobuf:"bytes,1,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	Image        string       `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	RestartCount int32        `protobuf:"varint,3,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	Containers   []*Container `protobuf:"bytes,4,rep,name=containers,proto3" json:"containers,omitempty"`
+	LogExcerpt   *LogExcerpt  `protobuf:"bytes,5,opt,name=log_excerpt,json=logExcerpt,proto3" json:"log_excerpt,omitempty"`
+	// Workloads
+	Replicas *Replicas `protobuf:"bytes,6,opt,name=replicas,proto3" json:"replicas,omitempty"`
+	// PersistentVolumeClaim and PersistentVolume
+	VolumeName string           `protobuf:"bytes,7,opt,name=volume_name,json=volumeName,proto3" json:"volume_name,omitempty"`
+	ClaimRef   *ObjectReference `protobuf:"bytes,8,opt,name=claim_ref,json=claimRef,proto3" json:"claim_ref,omitempty"`
+	// Service
+	ClusterIp   string `protobuf:"bytes,9,opt,name=cluster_ip,json=clusterIp,proto3" json:"cluster_ip,omitempty"`
+	ServiceType string `protobuf:"bytes,10,opt,name=service_type,json=serviceType,proto3" json:"service_type,omitempty"`
+	// Ingress
+	IngressClass string `protobuf:"bytes,11,opt,name=ingress_class,json=ingressClass,proto3" json:"ingress_class,omitempty"`
+	// HorizontalPodAutoscaler
+	ScaleTargetRef  *ObjectReference `protobuf:"bytes,12,opt,name=scale_target_ref,json=scaleTargetRef,proto3" json:"scale_target_ref,omitempty"`
+	MinReplicas     *int32           `protobuf:"varint,13,opt,name=min_replicas,json=minReplicas,proto3,oneof" json:"min_replicas,omitempty"`
+	MaxReplicas     int32            `protobuf:"varint,14,opt,name=max_replicas,json=maxReplicas,proto3" json:"max_replicas,omitempty"`
+	CurrentReplicas int32            `protobuf:"varint,15,opt,name=current_replicas,json=currentReplicas,proto3" json:"current_replicas,omitempty"`
+	DesiredReplicas int32            `protobuf:"varint,16,opt,name=desired_replicas,json=desiredReplicas,proto3" json:"desired_replicas,omitempty"`
+	// ArgoCD Application, Flux Kustomization and HelmRelease
+	GitOps *GitOpsStatus `protobuf:"bytes,17,opt,name=git_ops,json=gitOps,proto3" json:"git_ops,omitempty"`
+	// Fields computed from the raw object (computedFields in the config file)
+	Computed      map[string]string `protobuf:"bytes,18,rep,name=computed,proto3" json:"computed,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metadata) Reset() {
+	*x = Metadata{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metadata) ProtoMessage() {}
+
+func (x *Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metadata.ProtoReflect.Descriptor instead.
+func (*Metadata) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Metadata) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *Metadata) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Metadata) GetRestartCount() int32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *Metadata) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+func (x *Metadata) GetLogExcerpt() *LogExcerpt {
+	if x != nil {
+		return x.LogExcerpt
+	}
+	return nil
+}
+
+func (x *Metadata) GetReplicas() *Replicas {
+	if x != nil {
+		return x.Replicas
+	}
+	return nil
+}
+
+func (x *Metadata) GetVolumeName() string {
+	if x != nil {
+		return x.VolumeName
+	}
+	return ""
+}
+
+func (x *Metadata) GetClaimRef() *ObjectReference {
+	if x != nil {
+		return x.ClaimRef
+	}
+	return nil
+}
+
+func (x *Metadata) GetClusterIp() string {
+	if x != nil {
+		return x.ClusterIp
+	}
+	return ""
+}
+
+func (x *Metadata) GetServiceType() string {
+	if x != nil {
+		return x.ServiceType
+	}
+	return ""
+}
+
+func (x *Metadata) GetIngressClass() string {
+	if x != nil {
+		return x.IngressClass
+	}
+	return ""
+}
+
+func (x *Metadata) GetScaleTargetRef() *ObjectReference {
+	if x != nil {
+		return x.ScaleTargetRef
+	}
+	return nil
+}
+
+func (x *Metadata) GetMinReplicas() int32 {
+	if x != nil && x.MinReplicas != nil {
+		return *x.MinReplicas
+	}
+	return 0
+}
+
+func (x *Metadata) GetMaxReplicas() int32 {
+	if x != nil {
+		return x.MaxReplicas
+	}
+	return 0
+}
+
+func (x *Metadata) GetCurrentReplicas() int32 {
+	if x != nil {
+		return x.CurrentReplicas
+	}
+	return 0
+}
+
+func (x *Metadata) GetDesiredReplicas() int32 {
+	if x != nil {
+		return x.DesiredReplicas
+	}
+	return 0
+}
+
+func (x *Metadata) GetGitOps() *GitOpsStatus {
+	if x != nil {
+		return x.GitOps
+	}
+	return nil
+}
+
+func (x *Metadata) GetComputed() map[string]string {
+	if x != nil {
+		return x.Computed
+	}
+	return nil
+}
+
+type Container struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Name                  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Image                 string                 `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	Init                  bool                   `protobuf:"varint,3,opt,name=init,proto3" json:"init,omitempty"`
+	Ready                 bool                   `protobuf:"varint,4,opt,name=ready,proto3" json:"ready,omitempty"`
+	Restarts              int32                  `protobuf:"varint,5,opt,name=restarts,proto3" json:"restarts,omitempty"`
+	State                 string                 `protobuf:"bytes,6,opt,name=state,proto3" json:"state,omitempty"`
+	Requests              map[string]string      `protobuf:"bytes,7,rep,name=requests,proto3" json:"requests,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Limits                map[string]string      `protobuf:"bytes,8,rep,name=limits,proto3" json:"limits,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LastTerminationReason string                 `protobuf:"bytes,9,opt,name=last_termination_reason,json=lastTerminationReason,proto3" json:"last_termination_reason,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *Container) Reset() {
+	*x = Container{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Container) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Container) ProtoMessage() {}
+
+func (x *Container) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Container.ProtoReflect.Descriptor instead.
+func (*Container) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Container) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Container) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Container) GetInit() bool {
+	if x != nil {
+		return x.Init
+	}
+	return false
+}
+
+func (x *Container) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *Container) GetRestarts() int32 {
+	if x != nil {
+		return x.Restarts
+	}
+	return 0
+}
+
+func (x *Container) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Container) GetRequests() map[string]string {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+func (x *Container) GetLimits() map[string]string {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *Container) GetLastTerminationReason() string {
+	if x != nil {
+		return x.LastTerminationReason
+	}
+	return ""
+}
+
+type LogExcerpt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Container     string                 `protobuf:"bytes,1,opt,name=container,proto3" json:"container,omitempty"`
+	Previous      bool                   `protobuf:"varint,2,opt,name=previous,proto3" json:"previous,omitempty"`
+	Lines         string                 `protobuf:"bytes,3,opt,name=lines,proto3" json:"lines,omitempty"`
+	SampledAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=sampled_at,json=sampledAt,proto3" json:"sampled_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogExcerpt) Reset() {
+	*x = LogExcerpt{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogExcerpt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogExcerpt) ProtoMessage() {}
+
+func (x *LogExcerpt) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogExcerpt.ProtoReflect.Descriptor instead.
+func (*LogExcerpt) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *LogExcerpt) GetContainer() string {
+	if x != nil {
+		return x.Container
+	}
+	return ""
+}
+
+func (x *LogExcerpt) GetPrevious() bool {
+	if x != nil {
+		return x.Previous
+	}
+	return false
+}
+
+func (x *LogExcerpt) GetLines() string {
+	if x != nil {
+		return x.Lines
+	}
+	return ""
+}
+
+func (x *LogExcerpt) GetSampledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SampledAt
+	}
+	return nil
+}
+
+type Replicas struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Desired       int32                  `protobuf:"varint,1,opt,name=desired,proto3" json:"desired,omitempty"`
+	Current       int32                  `protobuf:"varint,2,opt,name=current,proto3" json:"current,omitempty"`
+	Ready         int32                  `protobuf:"varint,3,opt,name=ready,proto3" json:"ready,omitempty"`
+	Available     int32                  `protobuf:"varint,4,opt,name=available,proto3" json:"available,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Replicas) Reset() {
+	*x = Replicas{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Replicas) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Replicas) ProtoMessage() {}
+
+func (x *Replicas) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Replicas.ProtoReflect.Descriptor instead.
+func (*Replicas) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Replicas) GetDesired() int32 {
+	if x != nil {
+		return x.Desired
+	}
+	return 0
+}
+
+func (x *Replicas) GetCurrent() int32 {
+	if x != nil {
+		return x.Current
+	}
+	return 0
+}
+
+func (x *Replicas) GetReady() int32 {
+	if x != nil {
+		return x.Ready
+	}
+	return 0
+}
+
+func (x *Replicas) GetAvailable() int32 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+type ObjectReference struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Uid           string                 `protobuf:"bytes,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ObjectReference) Reset() {
+	*x = ObjectReference{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ObjectReference) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObjectReference) ProtoMessage() {}
+
+func (x *ObjectReference) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObjectReference.ProtoReflect.Descriptor instead.
+func (*ObjectReference) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ObjectReference) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+type GitOpsStatus struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	SyncStatus           string                 `protobuf:"bytes,1,opt,name=sync_status,json=syncStatus,proto3" json:"sync_status,omitempty"`
+	HealthStatus         string                 `protobuf:"bytes,2,opt,name=health_status,json=healthStatus,proto3" json:"health_status,omitempty"`
+	RepoUrl              string                 `protobuf:"bytes,3,opt,name=repo_url,json=repoUrl,proto3" json:"repo_url,omitempty"`
+	Path                 string                 `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Revision             string                 `protobuf:"bytes,5,opt,name=revision,proto3" json:"revision,omitempty"`
+	DestinationNamespace string                 `protobuf:"bytes,6,opt,name=destination_namespace,json=destinationNamespace,proto3" json:"destination_namespace,omitempty"`
+	SourceRef            string                 `protobuf:"bytes,7,opt,name=source_ref,json=sourceRef,proto3" json:"source_ref,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *GitOpsStatus) Reset() {
+	*x = GitOpsStatus{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GitOpsStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GitOpsStatus) ProtoMessage() {}
+
+func (x *GitOpsStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GitOpsStatus.ProtoReflect.Descriptor instead.
+func (*GitOpsStatus) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GitOpsStatus) GetSyncStatus() string {
+	if x != nil {
+		return x.SyncStatus
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetHealthStatus() string {
+	if x != nil {
+		return x.HealthStatus
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetRepoUrl() string {
+	if x != nil {
+		return x.RepoUrl
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetDestinationNamespace() string {
+	if x != nil {
+		return x.DestinationNamespace
+	}
+	return ""
+}
+
+func (x *GitOpsStatus) GetSourceRef() string {
+	if x != nil {
+		return x.SourceRef
+	}
+	return ""
+}
+
+type Edge struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// owns, selects, mounts, ... (see the edge types in the README)
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LastConfirmed *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_confirmed,json=lastConfirmed,proto3" json:"last_confirmed,omitempty"`
+	Stale         bool                   `protobuf:"varint,6,opt,name=stale,proto3" json:"stale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Edge) Reset() {
+	*x = Edge{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Edge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Edge) ProtoMessage() {}
+
+func (x *Edge) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Edge.ProtoReflect.Descriptor instead.
+func (*Edge) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Edge) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Edge) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Edge) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *Edge) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Edge) GetLastConfirmed() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastConfirmed
+	}
+	return nil
+}
+
+func (x *Edge) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+type EdgeRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EdgeRef) Reset() {
+	*x = EdgeRef{}
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EdgeRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EdgeRef) ProtoMessage() {}
+
+func (x *EdgeRef) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_v1_astrolabe_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EdgeRef.ProtoReflect.Descriptor instead.
+func (*EdgeRef) Descriptor() ([]byte, []int) {
+	return file_astrolabe_v1_astrolabe_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EdgeRef) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *EdgeRef) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+var File_astrolabe_v1_astrolabe_proto protoreflect.FileDescriptor
+
+const file_astrolabe_v1_astrolabe_proto_rawDesc = "" +
+	"\n" +
+	"\x1castrolabe/v1/astrolabe.proto\x12\fastrolabe.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"e\n" +
+	"\x06Filter\x12\x18\n" +
+	"\arelease\x18\x01 \x01(\tR\arelease\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12#\n" +
+	"\rexclude_kinds\x18\x03 \x03(\tR\fexcludeKinds\"?\n" +
+	"\x0fGetGraphRequest\x12,\n" +
+	"\x06filter\x18\x01 \x01(\v2\x14.astrolabe.v1.FilterR\x06filter\"C\n" +
+	"\x13GetResourcesRequest\x12,\n" +
+	"\x06filter\x18\x01 \x01(\v2\x14.astrolabe.v1.FilterR\x06filter\"@\n" +
+	"\x14GetResourcesResponse\x12(\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x12.astrolabe.v1.NodeR\x05nodes\"A\n" +
+	"\x11WatchGraphRequest\x12,\n" +
+	"\x06filter\x18\x01 \x01(\v2\x14.astrolabe.v1.FilterR\x06filter\"{\n" +
+	"\x05Graph\x12(\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x12.astrolabe.v1.NodeR\x05nodes\x12(\n" +
+	"\x05edges\x18\x02 \x03(\v2\x12.astrolabe.v1.EdgeR\x05edges\x12\x1e\n" +
+	"\n" +
+	"generation\x18\x03 \x01(\x04R\n" +
+	"generation\"\xfc\x01\n" +
+	"\vGraphUpdate\x12\x1e\n" +
+	"\n" +
+	"generation\x18\x01 \x01(\x04R\n" +
+	"generation\x12\x18\n" +
+	"\ainitial\x18\x02 \x01(\bR\ainitial\x12(\n" +
+	"\x05nodes\x18\x03 \x03(\v2\x12.astrolabe.v1.NodeR\x05nodes\x12#\n" +
+	"\rdeleted_nodes\x18\x04 \x03(\tR\fdeletedNodes\x12(\n" +
+	"\x05edges\x18\x05 \x03(\v2\x12.astrolabe.v1.EdgeR\x05edges\x12:\n" +
+	"\rdeleted_edges\x18\x06 \x03(\v2\x15.astrolabe.v1.EdgeRefR\fdeletedEdges\"\xd1\x04\n" +
+	"\x04Node\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1c\n" +
+	"\tnamespace\x18\x03 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12\x1f\n" +
+	"\vapi_version\x18\x05 \x01(\tR\n" +
+	"apiVersion\x12)\n" +
+	"\x10resource_version\x18\x06 \x01(\tR\x0fresourceVersion\x126\n" +
+	"\x06labels\x18\a \x03(\v2\x1e.astrolabe.v1.Node.LabelsEntryR\x06labels\x12I\n" +
+	"\x12creation_timestamp\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x11creationTimestamp\x12\x16\n" +
+	"\x06status\x18\t \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\n" +
+	" \x01(\tR\amessage\x12\x14\n" +
+	"\x05chart\x18\v \x01(\tR\x05chart\x12\x18\n" +
+	"\arelease\x18\f \x01(\tR\arelease\x122\n" +
+	"\bmetadata\x18\r \x01(\v2\x16.astrolabe.v1.MetadataR\bmetadata\x12\x16\n" +
+	"\x06reason\x18\x0e \x01(\tR\x06reason\x129\n" +
+	"\n" +
+	"deleted_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tdeletedAt\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xfd\x06\n" +
+	"\bMetadata\x12\x1b\n" +
+	"\tnode_name\x18\x01 \x01(\tR\bnodeName\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\tR\x05image\x12#\n" +
+	"\rrestart_count\x18\x03 \x01(\x05R\frestartCount\x127\n" +
+	"\n" +
+	"containers\x18\x04 \x03(\v2\x17.astrolabe.v1.ContainerR\n" +
+	"containers\x129\n" +
+	"\vlog_excerpt\x18\x05 \x01(\v2\x18.astrolabe.v1.LogExcerptR\n" +
+	"logExcerpt\x122\n" +
+	"\breplicas\x18\x06 \x01(\v2\x16.astrolabe.v1.ReplicasR\breplicas\x12\x1f\n" +
+	"\vvolume_name\x18\a \x01(\tR\n" +
+	"volumeName\x12:\n" +
+	"\tclaim_ref\x18\b \x01(\v2\x1d.astrolabe.v1.ObjectReferenceR\bclaimRef\x12\x1d\n" +
+	"\n" +
+	"cluster_ip\x18\t \x01(\tR\tclusterIp\x12!\n" +
+	"\fservice_type\x18\n" +
+	" \x01(\tR\vserviceType\x12#\n" +
+	"\ringress_class\x18\v \x01(\tR\fingressClass\x12G\n" +
+	"\x10scale_target_ref\x18\f \x01(\v2\x1d.astrolabe.v1.ObjectReferenceR\x0escaleTargetRef\x12&\n" +
+	"\fmin_replicas\x18\r \x01(\x05H\x00R\vminReplicas\x88\x01\x01\x12!\n" +
+	"\fmax_replicas\x18\x0e \x01(\x05R\vmaxReplicas\x12)\n" +
+	"\x10current_replicas\x18\x0f \x01(\x05R\x0fcurrentReplicas\x12)\n" +
+	"\x10desired_replicas\x18\x10 \x01(\x05R\x0fdesiredReplicas\x123\n" +
+	"\agit_ops\x18\x11 \x01(\v2\x1a.astrolabe.v1.GitOpsStatusR\x06gitOps\x12@\n" +
+	"\bcomputed\x18\x12 \x03(\v2$.astrolabe.v1.Metadata.ComputedEntryR\bcomputed\x1a;\n" +
+	"\rComputedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x0f\n" +
+	"\r_min_replicas\"\xc1\x03\n" +
+	"\tContainer\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\tR\x05image\x12\x12\n" +
+	"\x04init\x18\x03 \x01(\bR\x04init\x12\x14\n" +
+	"\x05ready\x18\x04 \x01(\bR\x05ready\x12\x1a\n" +
+	"\brestarts\x18\x05 \x01(\x05R\brestarts\x12\x14\n" +
+	"\x05state\x18\x06 \x01(\tR\x05state\x12A\n" +
+	"\brequests\x18\a \x03(\v2%.astrolabe.v1.Container.RequestsEntryR\brequests\x12;\n" +
+	"\x06limits\x18\b \x03(\v2#.astrolabe.v1.Container.LimitsEntryR\x06limits\x126\n" +
+	"\x17last_termination_reason\x18\t \x01(\tR\x15lastTerminationReason\x1a;\n" +
+	"\rRequestsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLimitsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x97\x01\n" +
+	"\n" +
+	"LogExcerpt\x12\x1c\n" +
+	"\tcontainer\x18\x01 \x01(\tR\tcontainer\x12\x1a\n" +
+	"\bprevious\x18\x02 \x01(\bR\bprevious\x12\x14\n" +
+	"\x05lines\x18\x03 \x01(\tR\x05lines\x129\n" +
+	"\n" +
+	"sampled_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tsampledAt\"r\n" +
+	"\bReplicas\x12\x18\n" +
+	"\adesired\x18\x01 \x01(\x05R\adesired\x12\x18\n" +
+	"\acurrent\x18\x02 \x01(\x05R\acurrent\x12\x14\n" +
+	"\x05ready\x18\x03 \x01(\x05R\x05ready\x12\x1c\n" +
+	"\tavailable\x18\x04 \x01(\x05R\tavailable\"i\n" +
+	"\x0fObjectReference\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x04 \x01(\tR\x03uid\"\xf3\x01\n" +
+	"\fGitOpsStatus\x12\x1f\n" +
+	"\vsync_status\x18\x01 \x01(\tR\n" +
+	"syncStatus\x12#\n" +
+	"\rhealth_status\x18\x02 \x01(\tR\fhealthStatus\x12\x19\n" +
+	"\brepo_url\x18\x03 \x01(\tR\arepoUrl\x12\x12\n" +
+	"\x04path\x18\x04 \x01(\tR\x04path\x12\x1a\n" +
+	"\brevision\x18\x05 \x01(\tR\brevision\x123\n" +
+	"\x15destination_namespace\x18\x06 \x01(\tR\x14destinationNamespace\x12\x1d\n" +
+	"\n" +
+	"source_ref\x18\a \x01(\tR\tsourceRef\"\x92\x02\n" +
+	"\x04Edge\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\x12<\n" +
+	"\bmetadata\x18\x04 \x03(\v2 .astrolabe.v1.Edge.MetadataEntryR\bmetadata\x12A\n" +
+	"\x0elast_confirmed\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\rlastConfirmed\x12\x14\n" +
+	"\x05stale\x18\x06 \x01(\bR\x05stale\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"-\n" +
+	"\aEdgeRef\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to2\xee\x01\n" +
+	"\tAstrolabe\x12>\n" +
+	"\bGetGraph\x12\x1d.astrolabe.v1.GetGraphRequest\x1a\x13.astrolabe.v1.Graph\x12U\n" +
+	"\fGetResources\x12!.astrolabe.v1.GetResourcesRequest\x1a\".astrolabe.v1.GetResourcesResponse\x12J\n" +
+	"\n" +
+	"WatchGraph\x12\x1f.astrolabe.v1.WatchGraphRequest\x1a\x19.astrolabe.v1.GraphUpdate0\x01BAZ?github.com/ammarlakis/astrolabe/pkg/api/astrolabev1;astrolabev1b\x06proto3"
+
+var (
+	file_astrolabe_v1_astrolabe_proto_rawDescOnce sync.Once
+	file_astrolabe_v1_astrolabe_proto_rawDescData []byte
+)
+
+func file_astrolabe_v1_astrolabe_proto_rawDescGZIP() []byte {
+	file_astrolabe_v1_astrolabe_proto_rawDescOnce.Do(func() {
+		file_astrolabe_v1_astrolabe_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_astrolabe_v1_astrolabe_proto_rawDesc), len(file_astrolabe_v1_astrolabe_proto_rawDesc)))
+	})
+	return file_astrolabe_v1_astrolabe_proto_rawDescData
+}
+
+var file_astrolabe_v1_astrolabe_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_astrolabe_v1_astrolabe_proto_goTypes = []any{
+	(*Filter)(nil),                // 0: astrolabe.v1.Filter
+	(*GetGraphRequest)(nil),       // 1: astrolabe.v1.GetGraphRequest
+	(*GetResourcesRequest)(nil),   // 2: astrolabe.v1.GetResourcesRequest
+	(*GetResourcesResponse)(nil),  // 3: astrolabe.v1.GetResourcesResponse
+	(*WatchGraphRequest)(nil),     // 4: astrolabe.v1.WatchGraphRequest
+	(*Graph)(nil),                 // 5: astrolabe.v1.Graph
+	(*GraphUpdate)(nil),           // 6: astrolabe.v1.GraphUpdate
+	(*Node)(nil),                  // 7: astrolabe.v1.Node
+	(*Metadata)(nil),              // 8: astrolabe.v1.Metadata
+	(*Container)(nil),             // 9: astrolabe.v1.Container
+	(*LogExcerpt)(nil),            // 10: astrolabe.v1.LogExcerpt
+	(*Replicas)(nil),              // 11: astrolabe.v1.Replicas
+	(*ObjectReference)(nil),       // 12: astrolabe.v1.ObjectReference
+	(*GitOpsStatus)(nil),          // 13: astrolabe.v1.GitOpsStatus
+	(*Edge)(nil),                  // 14: astrolabe.v1.Edge
+	(*EdgeRef)(nil),               // 15: astrolabe.v1.EdgeRef
+	nil,                           // 16: astrolabe.v1.Node.LabelsEntry
+	nil,                           // 17: astrolabe.v1.Metadata.ComputedEntry
+	nil,                           // 18: astrolabe.v1.Container.RequestsEntry
+	nil,                           // 19: astrolabe.v1.Container.LimitsEntry
+	nil,                           // 20: astrolabe.v1.Edge.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 21: google.protobuf.Timestamp
+}
+var file_astrolabe_v1_astrolabe_proto_depIdxs = []int32{
+	0,  // 0: astrolabe.v1.GetGraphRequest.filter:type_name -> astrolabe.v1.Filter
+	0,  // 1: astrolabe.v1.GetResourcesRequest.filter:type_name -> astrolabe.v1.Filter
+	7,  // 2: astrolabe.v1.GetResourcesResponse.nodes:type_name -> astrolabe.v1.Node
+	0,  // 3: astrolabe.v1.WatchGraphRequest.filter:type_name -> astrolabe.v1.Filter
+	7,  // 4: astrolabe.v1.Graph.nodes:type_name -> astrolabe.v1.Node
+	14, // 5: astrolabe.v1.Graph.edges:type_name -> astrolabe.v1.Edge
+	7,  // 6: astrolabe.v1.GraphUpdate.nodes:type_name -> astrolabe.v1.Node
+	14, // 7: astrolabe.v1.GraphUpdate.edges:type_name -> astrolabe.v1.Edge
+	15, // 8: astrolabe.v1.GraphUpdate.deleted_edges:type_name -> astrolabe.v1.EdgeRef
+	16, // 9: astrolabe.v1.Node.labels:type_name -> astrolabe.v1.Node.LabelsEntry
+	21, // 10: astrolabe.v1.Node.creation_timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 11: astrolabe.v1.Node.metadata:type_name -> astrolabe.v1.Metadata
+	21, // 12: astrolabe.v1.Node.deleted_at:type_name -> google.protobuf.Timestamp
+	9,  // 13: astrolabe.v1.Metadata.containers:type_name -> astrolabe.v1.Container
+	10, // 14: astrolabe.v1.Metadata.log_excerpt:type_name -> astrolabe.v1.LogExcerpt
+	11, // 15: astrolabe.v1.Metadata.replicas:type_name -> astrolabe.v1.Replicas
+	12, // 16: astrolabe.v1.Metadata.claim_ref:type_name -> astrolabe.v1.ObjectReference
+	12, // 17: astrolabe.v1.Metadata.scale_target_ref:type_name -> astrolabe.v1.ObjectReference
+	13, // 18: astrolabe.v1.Metadata.git_ops:type_name -> astrolabe.v1.GitOpsStatus
+	17, // 19: astrolabe.v1.Metadata.computed:type_name -> astrolabe.v1.Metadata.ComputedEntry
+	18, // 20: astrolabe.v1.Container.requests:type_name -> astrolabe.v1.Container.RequestsEntry
+	19, // 21: astrolabe.v1.Container.limits:type_name -> astrolabe.v1.Container.LimitsEntry
+	21, // 22: astrolabe.v1.LogExcerpt.sampled_at:type_name -> google.protobuf.Timestamp
+	20, // 23: astrolabe.v1.Edge.metadata:type_name -> astrolabe.v1.Edge.MetadataEntry
+	21, // 24: astrolabe.v1.Edge.last_confirmed:type_name -> google.protobuf.Timestamp
+	1,  // 25: astrolabe.v1.Astrolabe.GetGraph:input_type -> astrolabe.v1.GetGraphRequest
+	2,  // 26: astrolabe.v1.Astrolabe.GetResources:input_type -> astrolabe.v1.GetResourcesRequest
+	4,  // 27: astrolabe.v1.Astrolabe.WatchGraph:input_type -> astrolabe.v1.WatchGraphRequest
+	5,  // 28: astrolabe.v1.Astrolabe.GetGraph:output_type -> astrolabe.v1.Graph
+	3,  // 29: astrolabe.v1.Astrolabe.GetResources:output_type -> astrolabe.v1.GetResourcesResponse
+	6,  // 30: astrolabe.v1.Astrolabe.WatchGraph:output_type -> astrolabe.v1.GraphUpdate
+	28, // [28:31] is the sub-list for method output_type
+	25, // [25:28] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_astrolabe_v1_astrolabe_proto_init() }
+func file_astrolabe_v1_astrolabe_proto_init() {
+	if File_astrolabe_v1_astrolabe_proto != nil {
+		return
+	}
+	file_astrolabe_v1_astrolabe_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_astrolabe_v1_astrolabe_proto_rawDesc), len(file_astrolabe_v1_astrolabe_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_astrolabe_v1_astrolabe_proto_goTypes,
+		DependencyIndexes: file_astrolabe_v1_astrolabe_proto_depIdxs,
+		MessageInfos:      file_astrolabe_v1_astrolabe_proto_msgTypes,
+	}.Build()
+	File_astrolabe_v1_astrolabe_proto = out.File
+	file_astrolabe_v1_astrolabe_proto_goTypes = nil
+	file_astrolabe_v1_astrolabe_proto_depIdxs = nil
+}
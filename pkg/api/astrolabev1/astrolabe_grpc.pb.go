@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: astrolabe/v1/astrolabe.proto
+
+package astrolabev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Astrolabe_GetGraph_FullMethodName     = "/astrolabe.v1.Astrolabe/GetGraph"
+	Astrolabe_GetResources_FullMethodName = "/astrolabe.v1.Astrolabe/GetResources"
+	Astrolabe_WatchGraph_FullMethodName   = "/astrolabe.v1.Astrolabe/WatchGraph"
+)
+
+// AstrolabeClient is the client API for Astrolabe service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Astrolabe serves the resource graph to backend services. It mirrors the HTTP API's
+// /api/v1/graph and /api/v1/resources endpoints and adds a streaming subscription.
+type AstrolabeClient interface {
+	// GetGraph returns the resources matching the filter with the edges between them. With a
+	// release, directly related resources of the release are included as in /api/v1/graph.
+	GetGraph(ctx context.Context, in *GetGraphRequest, opts ...grpc.CallOption) (*Graph, error)
+	// GetResources returns the resources matching the filter, without edges
+	GetResources(ctx context.Context, in *GetResourcesRequest, opts ...grpc.CallOption) (*GetResourcesResponse, error)
+	// WatchGraph streams the graph matching the filter: the full graph first, then the
+	// changes whenever the graph changes
+	WatchGraph(ctx context.Context, in *WatchGraphRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GraphUpdate], error)
+}
+
+type astrolabeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAstrolabeClient(cc grpc.ClientConnInterface) AstrolabeClient {
+	return &astrolabeClient{cc}
+}
+
+func (c *astrolabeClient) GetGraph(ctx context.Context, in *GetGraphRequest, opts ...grpc.CallOption) (*Graph, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Graph)
+	err := c.cc.Invoke(ctx, Astrolabe_GetGraph_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *astrolabeClient) GetResources(ctx context.Context, in *GetResourcesRequest, opts ...grpc.CallOption) (*GetResourcesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResourcesResponse)
+	err := c.cc.Invoke(ctx, Astrolabe_GetResources_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *astrolabeClient) WatchGraph(ctx context.Context, in *WatchGraphRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GraphUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Astrolabe_ServiceDesc.Streams[0], Astrolabe_WatchGraph_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchGraphRequest, GraphUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Astrolabe_WatchGraphClient = grpc.ServerStreamingClient[GraphUpdate]
+
+// AstrolabeServer is the server API for Astrolabe service.
+// All implementations must embed UnimplementedAstrolabeServer
+// for forward compatibility.
+//
+// Astrolabe serves the resource graph to backend services. It mirrors the HTTP API's
+// /api/v1/graph and /api/v1/resources endpoints and adds a streaming subscription.
+type AstrolabeServer interface {
+	// GetGraph returns the resources matching the filter with the edges between them. With a
+	// release, directly related resources of the release are included as in /api/v1/graph.
+	GetGraph(context.Context, *GetGraphRequest) (*Graph, error)
+	// GetResources returns the resources matching the filter, without edges
+	GetResources(context.Context, *GetResourcesRequest) (*GetResourcesResponse, error)
+	// WatchGraph streams the graph matching the filter: the full graph first, then the
+	// changes whenever the graph changes
+	WatchGraph(*WatchGraphRequest, grpc.ServerStreamingServer[GraphUpdate]) error
+	mustEmbedUnimplementedAstrolabeServer()
+}
+
+// UnimplementedAstrolabeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAstrolabeServer struct{}
+
+func (UnimplementedAstrolabeServer) GetGraph(context.Context, *GetGraphRequest) (*Graph, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetGraph not implemented")
+}
+func (UnimplementedAstrolabeServer) GetResources(context.Context, *GetResourcesRequest) (*GetResourcesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetResources not implemented")
+}
+func (UnimplementedAstrolabeServer) WatchGraph(*WatchGraphRequest, grpc.ServerStreamingServer[GraphUpdate]) error {
+	return status.Error(codes.Unimplemented, "method WatchGraph not implemented")
+}
+func (UnimplementedAstrolabeServer) mustEmbedUnimplementedAstrolabeServer() {}
+func (UnimplementedAstrolabeServer) testEmbeddedByValue()                   {}
+
+// UnsafeAstrolabeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AstrolabeServer will
+// result in compilation errors.
+type UnsafeAstrolabeServer interface {
+	mustEmbedUnimplementedAstrolabeServer()
+}
+
+func RegisterAstrolabeServer(s grpc.ServiceRegistrar, srv AstrolabeServer) {
+	// If the following call panics, it indicates UnimplementedAstrolabeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Astrolabe_ServiceDesc, srv)
+}
+
+func _Astrolabe_GetGraph_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGraphRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AstrolabeServer).GetGraph(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Astrolabe_GetGraph_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AstrolabeServer).GetGraph(ctx, req.(*GetGraphRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Astrolabe_GetResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AstrolabeServer).GetResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Astrolabe_GetResources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AstrolabeServer).GetResources(ctx, req.(*GetResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Astrolabe_WatchGraph_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchGraphRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AstrolabeServer).WatchGraph(m, &grpc.GenericServerStream[WatchGraphRequest, GraphUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Astrolabe_WatchGraphServer = grpc.ServerStreamingServer[GraphUpdate]
+
+// Astrolabe_ServiceDesc is the grpc.ServiceDesc for Astrolabe service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Astrolabe_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "astrolabe.v1.Astrolabe",
+	HandlerType: (*AstrolabeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetGraph",
+			Handler:    _Astrolabe_GetGraph_Handler,
+		},
+		{
+			MethodName: "GetResources",
+			Handler:    _Astrolabe_GetResources_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchGraph",
+			Handler:       _Astrolabe_WatchGraph_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "astrolabe/v1/astrolabe.proto",
+}
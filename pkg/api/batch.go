@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// maxBatchRefs bounds the references of a batch query
+	maxBatchRefs = 500
+	// maxBatchBodyBytes bounds the body of a batch query
+	maxBatchBodyBytes = 1024 * 1024
+)
+
+// handleBatch resolves a list of resource references in one request, so dashboards rendering
+// many panels do not issue one GET per resource. References that cannot be resolved get an
+// error in their result instead of failing the whole batch.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var refs []BatchRef
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&refs); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(refs) > maxBatchRefs {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many references: %d (at most %d)", len(refs), maxBatchRefs))
+		return
+	}
+
+	g := s.graphFor(r.Context())
+	nodes := make([]*graph.Node, len(refs))
+	resp := BatchResponse{Results: make([]BatchResult, len(refs))}
+	var found []*graph.Node
+	for i, ref := range refs {
+		resp.Results[i].Ref = ref
+		node, err := resolveBatchRef(g, ref)
+		if err != nil {
+			resp.Results[i].Error = err.Error()
+			continue
+		}
+		nodes[i] = node
+		found = append(found, node)
+	}
+
+	// Resources are converted together so the lookups of related resources are shared
+	resources := s.nodesToResources(g, found)
+	next := 0
+	for i, node := range nodes {
+		if node == nil {
+			continue
+		}
+		resp.Results[i].UID = string(node.UID)
+		resp.Results[i].Resource = &resources[next]
+		resp.Results[i].Edges = immediateEdges(g, node)
+		next++
+	}
+	writeJSON(w, resp)
+}
+
+// resolveBatchRef finds the node of a reference by UID or by kind, namespace and name
+func resolveBatchRef(g graph.GraphInterface, ref BatchRef) (*graph.Node, error) {
+	if ref.UID != "" {
+		if node, exists := g.GetNode(types.UID(ref.UID)); exists {
+			return node, nil
+		}
+		return nil, fmt.Errorf("resource not found in graph")
+	}
+	if ref.Kind == "" || ref.Name == "" {
+		return nil, fmt.Errorf("reference needs a uid, or a kind and a name")
+	}
+	if node, exists := g.GetNodeByRef(ref.Namespace, ref.Kind, ref.Name); exists {
+		return node, nil
+	}
+	return nil, fmt.Errorf("resource not found in graph")
+}
+
+// immediateEdges returns the outgoing and incoming edges of a node whose other end is visible
+// in g, outgoing edges first
+func immediateEdges(g graph.GraphInterface, node *graph.Node) []EdgeResponse {
+	edges := make([]EdgeResponse, 0, len(node.OutgoingEdges)+len(node.IncomingEdges))
+	add := func(edge *graph.Edge, other types.UID) {
+		if _, exists := g.GetNode(other); !exists {
+			return
+		}
+		edges = append(edges, EdgeResponse{
+			Type:          string(edge.Type),
+			From:          string(edge.FromUID),
+			To:            string(edge.ToUID),
+			Metadata:      edge.Metadata,
+			LastConfirmed: edge.LastConfirmed,
+			Stale:         edge.Stale,
+		})
+	}
+	for _, edge := range sortedEdges(node.OutgoingEdges) {
+		add(edge, edge.ToUID)
+	}
+
+	for _, edge := range sortedIncomingEdges(node.IncomingEdges) {
+		add(edge, edge.FromUID)
+	}
+	return edges
+}
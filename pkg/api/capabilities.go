@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/version"
+)
+
+// apiVersion is the version of the HTTP API, part of every path
+const apiVersion = "v1"
+
+// Features are the capabilities of the deployment that the API server does not serve itself,
+// reported on /api/v1/capabilities
+type Features struct {
+	// Persistence is set when the graph is persisted to Redis
+	Persistence bool
+	// GRPCPort serves the gRPC API, with its WatchGraph streams (0 = disabled)
+	GRPCPort int
+	// Clusters are the federated clusters and kubeconfig contexts merged into the graph
+	Clusters []string
+}
+
+// SetFeatures reports the features of the deployment on /api/v1/capabilities
+func (s *Server) SetFeatures(features Features) {
+	s.features = features
+}
+
+// handleCapabilities describes what this server supports, so clients such as the Grafana
+// datasource and the CLI can adapt to older and newer servers: the optional features enabled,
+// the endpoints served with their query parameters and formats, and the limits of requests.
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	api, _ := s.routes()
+	resp := CapabilitiesResponse{
+		Version:    version.Get(),
+		APIVersion: apiVersion,
+		Features: map[string]bool{
+			"persistence":    s.features.Persistence,
+			"streaming":      s.features.GRPCPort > 0,
+			"multiCluster":   len(s.features.Clusters) > 0,
+			"actions":        s.actions != nil,
+			"tenancy":        s.tenancy != nil,
+			"analyses":       s.analyses != nil,
+			"timeline":       s.timeline != nil,
+			"healthHistory":  s.healthHistory != nil,
+			"tombstones":     s.tombstones != nil,
+			"events":         s.events != nil,
+			"manifests":      s.manifests != nil,
+			"lazyNamespaces": s.namespaces != nil,
+			"uninstalls":     s.uninstalls != nil,
+			"anomalies":      s.anomalies != nil,
+			"stats":          s.stats != nil,
+			"graphChanges":   s.changeToken(0) != "",
+		},
+		Clusters:    s.features.Clusters,
+		GRPCPort:    s.features.GRPCPort,
+		Compression: s.options.Compression,
+		Endpoints:   make([]CapabilityEndpoint, 0, len(apiEndpoints)),
+		Limits: CapabilityLimits{
+			BatchReferences:       maxBatchRefs,
+			BatchBodyBytes:        maxBatchBodyBytes,
+			SearchResults:         maxSearchLimit,
+			SummarizedNodes:       defaultMaxGraphNodes,
+			RequestTimeoutSeconds: int(s.options.WriteTimeout.Seconds()),
+		},
+	}
+	if resp.Compression == nil {
+		resp.Compression = []string{}
+	}
+
+	for _, ep := range apiEndpoints {
+		if !served(api, ep) {
+			continue
+		}
+		params := make([]string, 0, len(ep.query))
+		for _, q := range ep.queryParams() {
+			params = append(params, q.name)
+		}
+		resp.Endpoints = append(resp.Endpoints, CapabilityEndpoint{
+			Method:          ep.method,
+			Path:            ep.path,
+			QueryParameters: params,
+			Formats:         ep.formats(),
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// served reports whether the main port serves a documented endpoint, as optional endpoints are
+// only registered when their feature is enabled
+func served(mux *http.ServeMux, ep endpoint) bool {
+	segments := strings.Split(ep.path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			segments[i] = "_"
+		}
+	}
+	r, err := http.NewRequest(ep.method, strings.Join(segments, "/"), nil)
+	if err != nil {
+		return false
+	}
+	_, pattern := mux.Handler(r)
+	return pattern != ""
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+)
+
+// Graph responses carry a change token, <epoch>.<generation>, that a client passes back as
+// ?since= to only receive the nodes changed after that response. The epoch identifies the
+// process, whose graph generations restart from zero, so tokens of a previous run are refused
+// rather than misread.
+
+// changesEpoch identifies the tokens issued by this process
+var changesEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// changeToken returns the token of a generation of the graph, "" when the graph does not
+// track its changes
+func (s *Server) changeToken(generation uint64) string {
+	if _, ok := s.graph.(graph.ChangeTracker); !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s.%d", changesEpoch, generation)
+}
+
+// parseChangeToken returns the generation of a token issued by this process
+func parseChangeToken(token string) (uint64, bool) {
+	epoch, generation, found := strings.Cut(token, ".")
+	if !found || epoch != changesEpoch {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(generation, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return parsed, true
+}
+
+// snapshotGraph is a graph serving reads from snapshots, such as graph.SnapshotView
+type snapshotGraph interface {
+	Current() *graph.Graph
+}
+
+// readGraph returns the graph to read a whole response from: the current snapshot when reads
+// are served from snapshots, so that the generation, the nodes and the changes of the response
+// agree, else the live graph.
+func (s *Server) readGraph() graph.GraphInterface {
+	if view, ok := s.graph.(snapshotGraph); ok {
+		return view.Current()
+	}
+	return s.graph
+}
+
+// graphChanges returns the changes of g after a since token. Tokens that are invalid, of a
+// previous run or too old get 410 Gone, and the client reads the whole graph again.
+func graphChanges(w http.ResponseWriter, g graph.GraphInterface, since string) (*graph.Changes, bool) {
+	tracker, ok := g.(graph.ChangeTracker)
+	if !ok {
+		writeError(w, http.StatusGone, "incremental graphs are not available, read the whole graph")
+		return nil, false
+	}
+	generation, ok := parseChangeToken(since)
+	if !ok {
+		writeError(w, http.StatusGone, "unknown change token, read the whole graph")
+		return nil, false
+	}
+	changes, ok := tracker.ChangesSince(generation)
+	if !ok {
+		writeError(w, http.StatusGone, "changes since this token are no longer known, read the whole graph")
+		return nil, false
+	}
+	return changes, true
+}
+
+// writeGraphChanges answers a graph request with a since token: the nodes of the selection
+// changed after the token, all the edges of these nodes within the selection, and the UIDs of
+// the nodes removed or no longer selected. Clients replace the changed nodes and their edges.
+// The changes are read before the selection and the returned token is the generation read
+// before both, so nodes changed meanwhile on the live graph are sent again on the next call
+// rather than missed.
+func (s *Server) writeGraphChanges(w http.ResponseWriter, r *http.Request, g graph.GraphInterface, generation uint64, changes *graph.Changes, selection []*graph.Node, edgeTypes edgeTypeFilter) {
+	selected := make(map[string]bool, len(selection))
+	for _, node := range selection {
+		selected[string(node.UID)] = true
+	}
+
+	resp := GraphChangesResponse{
+		Token:   s.changeToken(generation),
+		Nodes:   make([]NodeResponse, 0),
+		Edges:   make([]EdgeResponse, 0),
+		Deleted: make([]string, 0),
+	}
+	changed := make(map[string]bool, len(changes.Nodes))
+	for _, node := range changes.Nodes {
+		changed[string(node.UID)] = true
+	}
+
+	charts := newChartResolver(g)
+	for _, node := range selection {
+		if !changed[string(node.UID)] {
+			continue
+		}
+		resp.Nodes = append(resp.Nodes, nodeResponse(node, charts))
+		for _, edge := range nodeEdges(node) {
+			if selected[string(edge.FromUID)] && selected[string(edge.ToUID)] {
+				resp.Edges = append(resp.Edges, edgeResponse(edge))
+			}
+		}
+		// Edges from changed nodes are listed with their source
+		for _, edge := range sortedIncomingEdges(node.IncomingEdges) {
+			from := string(edge.FromUID)
+			if selected[from] && !changed[from] {
+				resp.Edges = append(resp.Edges, edgeResponse(edge))
+			}
+		}
+	}
+	resp.Edges = edgeTypes.edges(resp.Edges)
+
+	// Changed nodes outside the selection may have left it. Deletions are not matched against
+	// the release, whose related resources are not known anymore, so clients ignore the UIDs
+	// they do not hold.
+	scope := tenancy.FromContext(r.Context())
+	namespace := r.URL.Query().Get("namespace")
+	gone := func(node *graph.Node) {
+		if !scope.AllowsDetached(node) {
+			return
+		}
+		if namespace != "" && node.Namespace != "" && node.Namespace != namespace {
+			return
+		}
+		resp.Deleted = append(resp.Deleted, string(node.UID))
+	}
+	for _, node := range changes.Nodes {
+		if !selected[string(node.UID)] {
+			gone(node)
+		}
+	}
+	for _, node := range changes.Removed {
+		gone(node)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
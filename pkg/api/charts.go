@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// handleChartReleases lists the releases running a chart and their chart versions
+func (s *Server) handleChartReleases(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	chart := r.PathValue("chart")
+	namespace := r.URL.Query().Get("namespace")
+
+	resp := ChartReleasesResponse{
+		Chart:    chart,
+		Versions: make([]string, 0),
+		Releases: make([]ChartRelease, 0),
+	}
+	versions := make(map[string]bool)
+	for _, release := range g.GetChartReleases(chart) {
+		if namespace != "" && release.Namespace != namespace {
+			continue
+		}
+		resp.Releases = append(resp.Releases, ChartRelease{
+			Release:   release.Release,
+			Namespace: release.Namespace,
+			Version:   release.Version,
+			Resources: release.Resources,
+		})
+		if !versions[release.Version] {
+			versions[release.Version] = true
+			resp.Versions = append(resp.Versions, release.Version)
+		}
+	}
+	sort.Strings(resp.Versions)
+
+	if len(resp.Releases) == 0 {
+		writeError(w, http.StatusNotFound, "no releases of chart "+chart)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// handleReleaseCharts returns the chart hierarchy of a release: the chart it was installed
+// from, the subcharts it declares and the charts its resources were rendered from, so
+// umbrella releases can be navigated by component chart (with ?chart= on /resources, /graph
+// and the release topology)
+func (s *Server) handleReleaseCharts(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	release := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+
+	type chartKey struct{ namespace, name string }
+	charts := make(map[chartKey]*ReleaseChart)
+	add := func(namespace, name, version, parent string) *ReleaseChart {
+		key := chartKey{namespace, name}
+		chart, exists := charts[key]
+		if !exists {
+			chart = &ReleaseChart{Namespace: namespace, Chart: name, Version: version, ParentChart: parent}
+			charts[key] = chart
+		}
+		return chart
+	}
+
+	resolver := newChartResolver(g)
+	for _, node := range g.GetNodesByHelmRelease(release) {
+		releaseNamespace := graph.ReleaseNamespace(node)
+		if node.HelmChart == "" || (namespace != "" && releaseNamespace != namespace) {
+			continue
+		}
+		info := resolver.resolve(node)
+		add(releaseNamespace, info.name, info.version, info.parent).Resources++
+	}
+	// The release's chart and its declared subcharts are listed even without resources of
+	// their own, as umbrella charts often have none and subcharts can be disabled
+	namespaces := make(map[string]bool)
+	for key := range charts {
+		namespaces[key.namespace] = true
+	}
+	if namespace != "" {
+		namespaces[namespace] = true
+	}
+	for releaseNamespace := range namespaces {
+		revision := resolver.revision(release, releaseNamespace)
+		if revision == nil {
+			continue
+		}
+		add(releaseNamespace, revision.Chart, revision.ChartVersion, "")
+		for _, dependency := range revision.Dependencies {
+			add(releaseNamespace, dependency.ChartName(), "", revision.Chart).Declared = true
+		}
+	}
+
+	if len(charts) == 0 {
+		writeError(w, http.StatusNotFound, "no charts of release "+release)
+		return
+	}
+	resp := ReleaseChartsResponse{Release: release, Charts: make([]ReleaseChart, 0, len(charts))}
+	for _, chart := range charts {
+		resp.Charts = append(resp.Charts, *chart)
+	}
+	sort.Slice(resp.Charts, func(i, j int) bool {
+		a, b := resp.Charts[i], resp.Charts[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if (a.ParentChart == "") != (b.ParentChart == "") {
+			return a.ParentChart == ""
+		}
+		return a.Chart < b.Chart
+	})
+	writeJSON(w, resp)
+}
+
+// chartResolver places resources in the chart hierarchy of their Helm release, caching the
+// deployed revision of each release
+type chartResolver struct {
+	g         graph.GraphInterface
+	revisions map[string]*graph.HelmRevision
+}
+
+// chartInfo is the chart a resource was rendered from: its name and version, and the chart
+// of the release when it is one of its subcharts
+type chartInfo struct {
+	name, version, parent string
+}
+
+func newChartResolver(g graph.GraphInterface) *chartResolver {
+	return &chartResolver{g: g, revisions: make(map[string]*graph.HelmRevision)}
+}
+
+func (c *chartResolver) resolve(node *graph.Node) chartInfo {
+	if node.HelmChart == "" {
+		return chartInfo{}
+	}
+	name, version := graph.SplitChart(node.HelmChart)
+	info := chartInfo{name: name, version: version}
+	if node.HelmRelease != "" {
+		info.parent = graph.ParentChart(c.revision(node.HelmRelease, graph.ReleaseNamespace(node)), name)
+	}
+	return info
+}
+
+// revision returns the deployed revision of a release in a namespace, nil when its release
+// Secrets are not tracked
+func (c *chartResolver) revision(release, namespace string) *graph.HelmRevision {
+	key := namespace + "/" + release
+	if revision, cached := c.revisions[key]; cached {
+		return revision
+	}
+	revision := graph.DeployedRevision(c.g.GetReleaseHistory(release), namespace)
+	c.revisions[key] = revision
+	return revision
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// defaultMaxGraphNodes is the node limit of summarized graphs
+	defaultMaxGraphNodes = 200
+	// collapsedUIDPrefix marks the UIDs of aggregated nodes
+	collapsedUIDPrefix = "collapsed:"
+)
+
+// nodeGroup is a set of homogeneous nodes that can be shown as one aggregated node
+type nodeGroup struct {
+	key     string
+	owner   *graph.Node
+	members []*graph.Node
+}
+
+// summarizeGraph builds the graph of nodes with at most maxNodes nodes when possible, by
+// collapsing homogeneous groups into aggregated nodes, largest first. Nodes of one kind with
+// the same owner (the Pods of a ReplicaSet) are collapsed first, then nodes of one kind in one
+// namespace. Edges of collapsed nodes are moved to their aggregated node.
+func (s *Server) summarizeGraph(g graph.GraphInterface, nodes []*graph.Node, maxNodes int) GraphResponse {
+	if len(nodes) <= maxNodes {
+		return s.buildGraphResponse(g, nodes)
+	}
+
+	inSet := make(map[types.UID]bool, len(nodes))
+	for _, node := range nodes {
+		inSet[node.UID] = true
+	}
+
+	collapsedInto := make(map[types.UID]*nodeGroup)
+	count := len(nodes)
+	collapse := func(groups []*nodeGroup) {
+		sort.SliceStable(groups, func(i, j int) bool {
+			if len(groups[i].members) != len(groups[j].members) {
+				return len(groups[i].members) > len(groups[j].members)
+			}
+			return groups[i].key < groups[j].key
+		})
+		for _, group := range groups {
+			if count <= maxNodes {
+				return
+			}
+			for _, member := range group.members {
+				collapsedInto[member.UID] = group
+			}
+			count -= len(group.members) - 1
+		}
+	}
+
+	// Nodes of one kind with the same owner
+	byOwner := make(map[string]*nodeGroup)
+	var ownerGroups []*nodeGroup
+	for _, node := range nodes {
+		owner := s.soleOwner(g, node, inSet)
+		if owner == nil {
+			continue
+		}
+		key := string(owner.UID) + "/" + node.Kind
+		group, exists := byOwner[key]
+		if !exists {
+			group = &nodeGroup{key: key, owner: owner}
+			byOwner[key] = group
+			ownerGroups = append(ownerGroups, group)
+		}
+		group.members = append(group.members, node)
+	}
+	collapse(collapsible(ownerGroups))
+
+	// Remaining nodes of one kind in one namespace
+	byNamespace := make(map[string]*nodeGroup)
+	var namespaceGroups []*nodeGroup
+	for _, node := range nodes {
+		if collapsedInto[node.UID] != nil {
+			continue
+		}
+		key := node.Cluster + "/" + node.Namespace + "/" + node.Kind
+		group, exists := byNamespace[key]
+		if !exists {
+			group = &nodeGroup{key: key}
+			byNamespace[key] = group
+			namespaceGroups = append(namespaceGroups, group)
+		}
+		group.members = append(group.members, node)
+	}
+	collapse(collapsible(namespaceGroups))
+
+	resp := GraphResponse{
+		Nodes:      make([]NodeResponse, 0, count),
+		Edges:      make([]EdgeResponse, 0),
+		Summarized: true,
+		TotalNodes: len(nodes),
+	}
+	// Aggregated nodes take the place of their first member
+	charts := newChartResolver(g)
+	emitted := make(map[*nodeGroup]bool)
+	for _, node := range nodes {
+		group := collapsedInto[node.UID]
+		if group == nil {
+			resp.Nodes = append(resp.Nodes, nodeResponse(node, charts))
+		} else if !emitted[group] {
+			emitted[group] = true
+			resp.Nodes = append(resp.Nodes, group.response())
+		}
+	}
+
+	endpoint := func(uid types.UID) string {
+		if group := collapsedInto[uid]; group != nil {
+			return group.uid()
+		}
+		return string(uid)
+	}
+	edgeIndex := make(map[string]int)
+	for _, node := range nodes {
+		for _, edge := range nodeEdges(node) {
+			if !inSet[edge.FromUID] || !inSet[edge.ToUID] {
+				continue
+			}
+			from, to := endpoint(edge.FromUID), endpoint(edge.ToUID)
+			if from == to {
+				continue
+			}
+			if collapsedInto[edge.FromUID] == nil && collapsedInto[edge.ToUID] == nil {
+				resp.Edges = append(resp.Edges, EdgeResponse{
+					Type:          string(edge.Type),
+					From:          from,
+					To:            to,
+					Metadata:      edge.Metadata,
+					LastConfirmed: edge.LastConfirmed,
+					Stale:         edge.Stale,
+				})
+				continue
+			}
+			// Edges merged into one between aggregated nodes carry no metadata, are confirmed
+			// when any of them is and stale when all of them are
+			key := string(edge.Type) + "|" + from + "|" + to
+			if i, exists := edgeIndex[key]; exists {
+				merged := &resp.Edges[i]
+				if edge.LastConfirmed.After(merged.LastConfirmed) {
+					merged.LastConfirmed = edge.LastConfirmed
+				}
+				merged.Stale = merged.Stale && edge.Stale
+				continue
+			}
+			edgeIndex[key] = len(resp.Edges)
+			resp.Edges = append(resp.Edges, EdgeResponse{
+				Type:          string(edge.Type),
+				From:          from,
+				To:            to,
+				LastConfirmed: edge.LastConfirmed,
+				Stale:         edge.Stale,
+			})
+		}
+	}
+
+	return resp
+}
+
+// soleOwner returns the owner of a node when it has exactly one among the nodes in the set
+func (s *Server) soleOwner(g graph.GraphInterface, node *graph.Node, inSet map[types.UID]bool) *graph.Node {
+	var owner types.UID
+	for _, edge := range node.IncomingEdges {
+		if edge.Type != graph.EdgeOwnership || !inSet[edge.FromUID] {
+			continue
+		}
+		if owner != "" {
+			return nil
+		}
+		owner = edge.FromUID
+	}
+	if owner == "" {
+		return nil
+	}
+	ownerNode, exists := g.GetNode(owner)
+	if !exists {
+		return nil
+	}
+	return ownerNode
+}
+
+// collapsible returns the groups of more than one node
+func collapsible(groups []*nodeGroup) []*nodeGroup {
+	var result []*nodeGroup
+	for _, group := range groups {
+		if len(group.members) > 1 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+func (g *nodeGroup) uid() string {
+	return collapsedUIDPrefix + g.key
+}
+
+// response builds the aggregated node of the group. Its status is the worst status of its
+// members; namespace and release are set when all members share them.
+func (g *nodeGroup) response() NodeResponse {
+	first := g.members[0]
+	aggregate := &AggregateInfo{Members: make([]string, 0, len(g.members))}
+	namespace, release, chart := first.Namespace, first.HelmRelease, first.HelmChart
+	for _, member := range g.members {
+		aggregate.add(member.Status)
+		aggregate.Members = append(aggregate.Members, string(member.UID))
+		if member.Namespace != namespace {
+			namespace = ""
+		}
+		if member.HelmRelease != release || member.HelmChart != chart {
+			release, chart = "", ""
+		}
+	}
+
+	name := fmt.Sprintf("%d %s", len(g.members), pluralKind(first.Kind))
+	if g.owner != nil {
+		aggregate.Owner = string(g.owner.UID)
+		name += " of " + g.owner.Name
+	}
+	return NodeResponse{
+		UID:       g.uid(),
+		Name:      name,
+		Namespace: namespace,
+		Kind:      first.Kind,
+		Cluster:   first.Cluster,
+		Status:    string(aggregate.status()),
+		Message:   aggregate.message(),
+		Reason:    "Collapsed",
+		Chart:     chart,
+		Release:   release,
+		Aggregate: aggregate,
+	}
+}
+
+func (a *AggregateInfo) add(status graph.ResourceStatus) {
+	a.Count++
+	switch status {
+	case graph.StatusReady:
+		a.Ready++
+	case graph.StatusPending:
+		a.Pending++
+	case graph.StatusError:
+		a.Error++
+	default:
+		a.Unknown++
+	}
+}
+
+// status returns the worst status of the members: Error, then Pending, then Unknown
+func (a *AggregateInfo) status() graph.ResourceStatus {
+	switch {
+	case a.Error > 0:
+		return graph.StatusError
+	case a.Pending > 0:
+		return graph.StatusPending
+	case a.Unknown > 0:
+		return graph.StatusUnknown
+	default:
+		return graph.StatusReady
+	}
+}
+
+// message lists the member counts by status, e.g. "48 Ready, 2 Error"
+func (a *AggregateInfo) message() string {
+	var parts []string
+	for _, count := range []struct {
+		n      int
+		status graph.ResourceStatus
+	}{{a.Ready, graph.StatusReady}, {a.Pending, graph.StatusPending}, {a.Error, graph.StatusError}, {a.Unknown, graph.StatusUnknown}} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.status))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pluralKind returns the plural of a kind for display, e.g. Pods or Ingresses
+func pluralKind(kind string) string {
+	if strings.HasSuffix(kind, "s") {
+		return kind + "es"
+	}
+	if strings.HasSuffix(kind, "y") && !strings.HasSuffix(kind, "ay") {
+		return strings.TrimSuffix(kind, "y") + "ies"
+	}
+	return kind + "s"
+}
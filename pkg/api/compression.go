@@ -0,0 +1,177 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/klog/v2"
+)
+
+// CompressionEncodings are the encodings responses can be compressed with, in the default
+// order of preference
+var CompressionEncodings = []string{"zstd", "gzip"}
+
+// minCompressSize is the size under which responses are sent uncompressed, as compressing
+// them costs more than it saves
+const minCompressSize = 1024
+
+// encoder is a compressor that can be reused for another response
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+	"zstd": {New: func() interface{} {
+		// Responses are compressed synchronously, one goroutine per request
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// compressionMiddleware compresses responses with the configured encoding the client prefers,
+// according to its Accept-Encoding header. Responses the handler already encoded (/metrics)
+// and small responses are sent as is.
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	if len(s.options.Compression) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"), s.options.Compression)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns the encoding of encodings with the highest quality in an
+// Accept-Encoding header, the first in encodings on ties, or "" when none is accepted
+func acceptedEncoding(header string, encodings []string) string {
+	if header == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, value, found := strings.Cut(strings.TrimSpace(param), "="); found && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = parsed
+				}
+			}
+		}
+		qualities[name] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		quality, listed := qualities[encoding]
+		if !listed {
+			quality, listed = qualities["*"]
+		}
+		if listed && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter compresses a response once it outgrows minCompressSize. Until then, the
+// status and body are held back.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	started bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if !w.started {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < minCompressSize {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// start sends the status and the held back body, compressed when compress is set and the
+// handler did not encode the response itself
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" && w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = encoderPools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Close sends a response that stayed under minCompressSize as is, or ends the compressed
+// stream
+func (w *compressWriter) Close() {
+	if !w.started {
+		if err := w.start(false); err != nil {
+			klog.V(2).Infof("Failed to write response: %v", err)
+		}
+		return
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Close(); err != nil {
+			klog.V(2).Infof("Failed to write %s response: %v", w.encoding, err)
+		}
+		w.encoder.Reset(io.Discard)
+		encoderPools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"k8s.io/klog/v2"
+)
+
+// CSV responses let platform teams pull inventory and compliance reports straight into
+// spreadsheets. Each endpoint serving CSV has a table of columns; the columns query parameter
+// picks and orders them, and defaults to the most useful ones.
+
+// csvColumn is a column of a CSV response
+type csvColumn[T any] struct {
+	name  string
+	value func(T) string
+}
+
+// columnNames returns the names of columns
+func columnNames[T any](columns []csvColumn[T]) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+// selectColumns returns the columns named by the comma-separated columns query parameter, or
+// the default ones. It writes a 400 response when a column is unknown.
+func selectColumns[T any](w http.ResponseWriter, r *http.Request, available []csvColumn[T], defaults []string) ([]csvColumn[T], bool) {
+	names := defaults
+	if value := r.URL.Query().Get("columns"); value != "" {
+		names = splitCommaList(value)
+	}
+	columns := make([]csvColumn[T], 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, column := range available {
+			if strings.EqualFold(column.name, name) {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown column %q (available: %s)", name, strings.Join(columnNames(available), ", ")))
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+// writeCSV writes rows as a CSV response with a header row, as an attachment named filename
+func writeCSV[T any](w http.ResponseWriter, filename string, columns []csvColumn[T], rows []T) {
+	w.Header().Set("Content-Type", formatCSV+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	writer := csv.NewWriter(w)
+	record := columnNames(columns)
+	writer.Write(record)
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = csvCell(column.value(row))
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		klog.Errorf("Failed to write CSV response: %v", err)
+	}
+}
+
+// csvCell escapes values a spreadsheet would evaluate as a formula, such as a status message
+// starting with "=", by prefixing them with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// splitCommaList splits a comma-separated list, dropping empty items
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// resourceColumns are the columns of /api/v1/resources?format=csv
+var resourceColumns = []csvColumn[Resource]{
+	{"name", func(r Resource) string { return r.Name }},
+	{"namespace", func(r Resource) string { return r.Namespace }},
+	{"kind", func(r Resource) string { return r.Kind }},
+	{"apiVersion", func(r Resource) string { return r.APIVersion }},
+	{"cluster", func(r Resource) string { return r.Cluster }},
+	{"status", func(r Resource) string { return r.Status }},
+	{"reason", func(r Resource) string { return r.Reason }},
+	{"message", func(r Resource) string { return r.Message }},
+	{"release", func(r Resource) string { return r.Release }},
+	{"chart", func(r Resource) string { return r.Chart }},
+	{"chartName", func(r Resource) string { return r.ChartName }},
+	{"chartVersion", func(r Resource) string { return r.ChartVersion }},
+	{"age", func(r Resource) string { return r.Age }},
+	{"creationTimestamp", func(r Resource) string { return r.CreationTimestamp }},
+	{"image", func(r Resource) string { return r.Image }},
+	{"images", func(r Resource) string {
+		images := make([]string, 0, len(r.Containers))
+		for _, container := range r.Containers {
+			images = append(images, container.Image)
+		}
+		return strings.Join(images, ";")
+	}},
+	{"nodeName", func(r Resource) string { return r.NodeName }},
+	{"zone", func(r Resource) string { return r.Zone }},
+	{"restartCount", func(r Resource) string { return strconv.Itoa(r.RestartCount) }},
+	{"replicas", func(r Resource) string {
+		if r.Replicas == nil {
+			return ""
+		}
+		return fmt.Sprintf("%d/%d", r.Replicas.Ready, r.Replicas.Desired)
+	}},
+	{"owners", func(r Resource) string {
+		owners := make([]string, 0, len(r.OwnerReferences))
+		for _, owner := range r.OwnerReferences {
+			owners = append(owners, owner.Kind+"/"+owner.Name)
+		}
+		return strings.Join(owners, ";")
+	}},
+	{"serviceAccountName", func(r Resource) string { return r.ServiceAccountName }},
+	{"timeToReady", func(r Resource) string {
+		if r.TimeToReady == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*r.TimeToReady, 'f', -1, 64)
+	}},
+	{"deletedAt", func(r Resource) string {
+		if r.DeletedAt == nil {
+			return ""
+		}
+		return csvTime(*r.DeletedAt)
+	}},
+}
+
+var defaultResourceColumns = []string{"name", "namespace", "kind", "status", "reason", "message", "release", "chart", "image", "creationTimestamp"}
+
+// findingColumns are the columns of /api/v1/analysis/{name}?format=csv
+var findingColumns = []csvColumn[analysis.Finding]{
+	{"check", func(f analysis.Finding) string { return f.Check }},
+	{"kind", func(f analysis.Finding) string { return f.Kind }},
+	{"namespace", func(f analysis.Finding) string { return f.Namespace }},
+	{"name", func(f analysis.Finding) string { return f.Name }},
+	{"uid", func(f analysis.Finding) string { return f.UID }},
+	{"release", func(f analysis.Finding) string { return f.Release }},
+	{"message", func(f analysis.Finding) string { return f.Message }},
+}
+
+var defaultFindingColumns = []string{"check", "kind", "namespace", "name", "release", "message"}
+
+// analysisSummaryColumns are the columns of /api/v1/analysis?format=csv
+var analysisSummaryColumns = []csvColumn[AnalysisSummary]{
+	{"name", func(s AnalysisSummary) string { return s.Name }},
+	{"computed", func(s AnalysisSummary) string { return strconv.FormatBool(s.Computed) }},
+	{"computedAt", func(s AnalysisSummary) string {
+		if s.ComputedAt == nil {
+			return ""
+		}
+		return csvTime(*s.ComputedAt)
+	}},
+	{"count", func(s AnalysisSummary) string { return strconv.Itoa(s.Count) }},
+}
+
+var defaultAnalysisSummaryColumns = columnNames(analysisSummaryColumns)
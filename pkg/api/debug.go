@@ -0,0 +1,31 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/klog/v2"
+)
+
+// handleConsistency returns the report of the last consistency check. With run=true a check
+// is performed first (repairing if the checker is configured to).
+func (s *Server) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	report := s.consistency.Last()
+	if report == nil || r.URL.Query().Get("run") == "true" {
+		current := s.consistency.Run()
+		report = &current
+	}
+	writeJSON(w, report)
+}
+
+// handleStateDump sends a dump of the live graph, its pending edges and index sizes, and the
+// goroutine stacks of the process, as a timestamped JSON attachment
+func (s *Server) handleStateDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, graph.StateDumpName(time.Now())))
+	if err := graph.WriteStateDump(w, s.dumps); err != nil {
+		klog.Errorf("Failed to write state dump: %v", err)
+	}
+}
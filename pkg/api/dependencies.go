@@ -0,0 +1,167 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// Edge types that express one resource consuming another
+var dependencyEdgeTypes = map[graph.EdgeType]bool{
+	graph.EdgeIngressBackend: true,
+	graph.EdgePodVolume:      true,
+	graph.EdgePVCBinding:     true,
+	graph.EdgeConfigMapRef:   true,
+	graph.EdgeSecretRef:      true,
+	graph.EdgeServiceAccount: true,
+	graph.EdgeHPATarget:      true,
+}
+
+// handleReleaseDependencies returns the dependency graph between Helm releases
+func (s *Server) handleReleaseDependencies(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	namespace := r.URL.Query().Get("namespace")
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, s.buildReleaseDependencies(g, namespace, exclude))
+}
+
+func (s *Server) buildReleaseDependencies(g graph.GraphInterface, namespace string, exclude kindFilter) ReleaseDependenciesResponse {
+	releaseSet := make(map[string]bool)
+	deps := make(map[[2]string]*ReleaseDependency)
+
+	for _, release := range g.GetAllHelmReleases() {
+		for _, node := range exclude.apply(g.GetNodesByHelmRelease(release)) {
+			if namespace != "" && node.Namespace != namespace {
+				continue
+			}
+			releaseSet[release] = true
+
+			for _, edge := range node.OutgoingEdges {
+				if !dependencyEdgeTypes[edge.Type] {
+					continue
+				}
+				target, exists := g.GetNode(edge.ToUID)
+				if !exists || target.HelmRelease == "" || target.HelmRelease == release || exclude.matches(target.Kind, target.APIVersion) {
+					continue
+				}
+
+				key := [2]string{release, target.HelmRelease}
+				dep, exists := deps[key]
+				if !exists {
+					dep = &ReleaseDependency{From: release, To: target.HelmRelease}
+					deps[key] = dep
+				}
+				dep.References = append(dep.References, ReleaseReferenceRef{
+					Type:          string(edge.Type),
+					FromKind:      node.Kind,
+					FromNamespace: node.Namespace,
+					FromName:      node.Name,
+					ToKind:        target.Kind,
+					ToNamespace:   target.Namespace,
+					ToName:        target.Name,
+				})
+				releaseSet[target.HelmRelease] = true
+			}
+		}
+	}
+
+	resp := ReleaseDependenciesResponse{
+		Releases:     make([]string, 0, len(releaseSet)),
+		Dependencies: make([]ReleaseDependency, 0, len(deps)),
+	}
+	for release := range releaseSet {
+		resp.Releases = append(resp.Releases, release)
+	}
+	sort.Strings(resp.Releases)
+
+	for _, dep := range deps {
+		sort.Slice(dep.References, func(i, j int) bool {
+			a, b := dep.References[i], dep.References[j]
+			if a.FromKind+a.FromName != b.FromKind+b.FromName {
+				return a.FromKind+a.FromName < b.FromKind+b.FromName
+			}
+			return a.ToKind+a.ToName < b.ToKind+b.ToName
+		})
+		resp.Dependencies = append(resp.Dependencies, *dep)
+	}
+	sort.Slice(resp.Dependencies, func(i, j int) bool {
+		if resp.Dependencies[i].From != resp.Dependencies[j].From {
+			return resp.Dependencies[i].From < resp.Dependencies[j].From
+		}
+		return resp.Dependencies[i].To < resp.Dependencies[j].To
+	})
+
+	resp.DeployOrder, resp.Cycles = releaseDeployOrder(resp.Releases, resp.Dependencies)
+	return resp
+}
+
+// releaseDeployOrder orders releases so dependencies come first and reports dependency cycles.
+// Tarjan's algorithm emits strongly connected components only after every component
+// they depend on, which is exactly the deploy order; components larger than one are cycles.
+func releaseDeployOrder(releases []string, deps []ReleaseDependency) ([]string, [][]string) {
+	dependsOn := make(map[string][]string)
+	for _, dep := range deps {
+		dependsOn[dep.From] = append(dependsOn[dep.From], dep.To)
+	}
+
+	var (
+		order   = make([]string, 0, len(releases))
+		cycles  [][]string
+		counter int
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+	)
+
+	var strongConnect func(release string)
+	strongConnect = func(release string) {
+		counter++
+		index[release] = counter
+		lowlink[release] = counter
+		stack = append(stack, release)
+		onStack[release] = true
+
+		for _, next := range dependsOn[release] {
+			if _, visited := index[next]; !visited {
+				strongConnect(next)
+				lowlink[release] = min(lowlink[release], lowlink[next])
+			} else if onStack[next] {
+				lowlink[release] = min(lowlink[release], index[next])
+			}
+		}
+
+		if lowlink[release] != index[release] {
+			return
+		}
+
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == release {
+				break
+			}
+		}
+		sort.Strings(component)
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+		order = append(order, component...)
+	}
+
+	for _, release := range releases {
+		if _, visited := index[release]; !visited {
+			strongConnect(release)
+		}
+	}
+
+	return order, cycles
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/api/astrolabev1"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// edgeTypeFilter holds the edge types of the edgeTypes query parameter, nil for all
+type edgeTypeFilter map[string]bool
+
+// onlyEdgeTypes parses the edgeTypes query parameter, a comma-separated list of edge types,
+// writing a 400 response if a type is unknown
+func onlyEdgeTypes(w http.ResponseWriter, r *http.Request) (edgeTypeFilter, bool) {
+	values := splitCommaList(r.URL.Query().Get("edgeTypes"))
+	if len(values) == 0 {
+		return nil, true
+	}
+	known := make(map[string]bool, len(graph.EdgeTypes))
+	names := make([]string, 0, len(graph.EdgeTypes))
+	for _, edgeType := range graph.EdgeTypes {
+		known[string(edgeType)] = true
+		names = append(names, string(edgeType))
+	}
+	filter := make(edgeTypeFilter, len(values))
+	for _, value := range values {
+		if !known[value] {
+			writeError(w, http.StatusBadRequest, "unknown edge type "+value+" (expected "+strings.Join(names, ", ")+")")
+			return nil, false
+		}
+		filter[value] = true
+	}
+	return filter, true
+}
+
+// edges drops the edges of the types not selected
+func (f edgeTypeFilter) edges(edges []EdgeResponse) []EdgeResponse {
+	if f == nil {
+		return edges
+	}
+	filtered := make([]EdgeResponse, 0, len(edges))
+	for _, edge := range edges {
+		if f[edge.Type] {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
+
+// messages drops the edge messages of the types not selected
+func (f edgeTypeFilter) messages(edges []*astrolabev1.Edge) []*astrolabev1.Edge {
+	if f == nil {
+		return edges
+	}
+	filtered := make([]*astrolabev1.Edge, 0, len(edges))
+	for _, edge := range edges {
+		if f[edge.Type] {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
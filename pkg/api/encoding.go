@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/munnerz/goautoneg"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// Formats of the endpoints serving resources in bulk. The protobuf format uses the messages
+// of the gRPC API (see proto/astrolabe/v1/astrolabe.proto); the CSV format serves tables for
+// spreadsheets (see csv.go).
+const (
+	formatJSON     = "application/json"
+	formatProtobuf = "application/x-protobuf"
+	formatCSV      = "text/csv"
+)
+
+// formatNames maps the values of the format query parameter to formats
+var formatNames = map[string]string{"json": formatJSON, "protobuf": formatProtobuf, "csv": formatCSV}
+
+// negotiateFormat picks the format of a response among the formats an endpoint serves: the
+// one named by the format query parameter when set, else the best match of the Accept header,
+// JSON when there is none. It writes a 406 response when the client accepts none of them.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, formats ...string) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	if name := r.URL.Query().Get("format"); name != "" {
+		if format := formatNames[name]; containsString(formats, format) {
+			return format, true
+		}
+	} else {
+		accept := r.Header.Get("Accept")
+		if accept == "" {
+			return formatJSON, true
+		}
+		offered := formats
+		if containsString(formats, formatProtobuf) {
+			offered = append(offered[:len(offered):len(offered)], "application/protobuf")
+		}
+		switch format := goautoneg.Negotiate(accept, offered); format {
+		case "":
+		case "application/protobuf":
+			return formatProtobuf, true
+		default:
+			return format, true
+		}
+	}
+	writeError(w, http.StatusNotAcceptable, "supported formats are "+strings.Join(formats, ", "))
+	return "", false
+}
+
+// writeProtobuf writes m as a protobuf response
+func writeProtobuf(w http.ResponseWriter, m proto.Message) {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		klog.Errorf("Failed to encode response: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	w.Header().Set("Content-Type", formatProtobuf)
+	w.Write(data)
+}
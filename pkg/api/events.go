@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleResourceEvents serves the recent Warning Events about a tracked resource, newest first
+func (s *Server) handleResourceEvents(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	node, exists := g.GetNode(types.UID(r.PathValue("uid")))
+	if !exists {
+		writeError(w, http.StatusNotFound, "resource not found in graph")
+		return
+	}
+
+	resp := ResourceEventsResponse{
+		UID:       string(node.UID),
+		Kind:      node.Kind,
+		Namespace: node.Namespace,
+		Name:      node.Name,
+		Events:    s.events.Events(node.UID),
+	}
+	if resp.Events == nil {
+		resp.Events = make([]graph.WarningEvent, 0)
+	}
+	writeJSON(w, resp)
+}
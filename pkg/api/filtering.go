@@ -9,7 +9,7 @@ import (
 
 // expandRelatedNodes performs a breadth-first traversal to include related resources.
 // releaseName is used to filter out resources from other Helm releases during traversal.
-func (s *Server) expandRelatedNodes(base []*graph.Node, namespace string, releaseName string) []*graph.Node {
+func (s *Server) expandRelatedNodes(g graph.GraphInterface, base []*graph.Node, namespace string, releaseName string) []*graph.Node {
 	if len(base) == 0 {
 		return base
 	}
@@ -65,12 +65,12 @@ func (s *Server) expandRelatedNodes(base []*graph.Node, namespace string, releas
 		neighbours := make([]*graph.Node, 0, len(current.OutgoingEdges)+len(current.IncomingEdges))
 
 		for _, edge := range current.OutgoingEdges {
-			if neighbour, exists := s.graph.GetNode(edge.ToUID); exists {
+			if neighbour, exists := g.GetNode(edge.ToUID); exists {
 				neighbours = append(neighbours, neighbour)
 			}
 		}
 		for _, edge := range current.IncomingEdges {
-			if neighbour, exists := s.graph.GetNode(edge.FromUID); exists {
+			if neighbour, exists := g.GetNode(edge.FromUID); exists {
 				neighbours = append(neighbours, neighbour)
 			}
 		}
@@ -112,7 +112,7 @@ func (s *Server) expandRelatedNodes(base []*graph.Node, namespace string, releas
 
 // includePersistentVolumes adds PVs bound to PVCs that belong to the specified release.
 // If releaseName is empty, it includes PVs for all PVCs in the node set.
-func (s *Server) includePersistentVolumes(nodes []*graph.Node, releaseName string) []*graph.Node {
+func (s *Server) includePersistentVolumes(g graph.GraphInterface, nodes []*graph.Node, releaseName string) []*graph.Node {
 	if len(nodes) == 0 {
 		return nodes
 	}
@@ -152,7 +152,7 @@ func (s *Server) includePersistentVolumes(nodes []*graph.Node, releaseName strin
 				continue
 			}
 
-			if pvNode, exists := s.graph.GetNode(edge.ToUID); exists {
+			if pvNode, exists := g.GetNode(edge.ToUID); exists {
 				addPV(pvNode)
 			}
 		}
@@ -164,7 +164,7 @@ func (s *Server) includePersistentVolumes(nodes []*graph.Node, releaseName strin
 
 		if pvByName == nil {
 			pvByName = make(map[string]*graph.Node)
-			for _, candidate := range s.graph.GetAllNodes() {
+			for _, candidate := range g.GetAllNodes() {
 				if strings.ToLower(candidate.Kind) == "persistentvolume" {
 					pvByName[candidate.Name] = candidate
 				}
@@ -178,3 +178,99 @@ func (s *Server) includePersistentVolumes(nodes []*graph.Node, releaseName strin
 
 	return nodes
 }
+
+// resourceNodes selects the nodes returned by /resources: the release's resources (or all
+// resources) in the namespace, rendered from the chart when one is given, plus the
+// PersistentVolumes bound to them
+func (s *Server) resourceNodes(g graph.GraphInterface, releaseName, namespace, chart string) []*graph.Node {
+	var nodes []*graph.Node
+
+	if releaseName != "" {
+		// Get resources by Helm release
+		nodes = g.GetNodesByHelmRelease(releaseName)
+
+		// Filter by namespace if specified
+		if namespace != "" {
+			filtered := make([]*graph.Node, 0)
+			for _, node := range nodes {
+				if node.Namespace == namespace || node.Namespace == "" {
+					filtered = append(filtered, node)
+				}
+			}
+			nodes = filtered
+		}
+
+		nodes = filterChart(nodes, chart)
+		nodes = s.includePersistentVolumes(g, nodes, releaseName)
+	} else {
+		// Get all nodes
+		nodes = g.GetAllNodes()
+
+		// Filter by namespace if specified
+		if namespace != "" {
+			filtered := make([]*graph.Node, 0)
+			for _, node := range nodes {
+				if node.Namespace == namespace || node.Namespace == "" {
+					filtered = append(filtered, node)
+				}
+			}
+			nodes = filtered
+		}
+
+		nodes = filterChart(nodes, chart)
+		nodes = s.includePersistentVolumes(g, nodes, "")
+	}
+
+	return nodes
+}
+
+// graphNodes selects the nodes returned by /graph. With a release, directly related
+// resources of the release are included. With a chart, only the resources rendered from it
+// (and the resources related to them) are.
+func (s *Server) graphNodes(g graph.GraphInterface, releaseName, namespace, chart string) []*graph.Node {
+	var nodes []*graph.Node
+
+	if releaseName != "" {
+		nodes = g.GetNodesByHelmRelease(releaseName)
+		if namespace != "" {
+			filtered := make([]*graph.Node, 0)
+			for _, node := range nodes {
+				if node.Namespace == namespace || node.Namespace == "" {
+					filtered = append(filtered, node)
+				}
+			}
+			nodes = filtered
+		}
+		nodes = filterChart(nodes, chart)
+		nodes = s.expandRelatedNodes(g, nodes, namespace, releaseName)
+		nodes = s.includePersistentVolumes(g, nodes, releaseName)
+	} else if namespace != "" {
+		allNodes := g.GetAllNodes()
+		for _, node := range allNodes {
+			if node.Namespace == namespace || node.Namespace == "" {
+				nodes = append(nodes, node)
+			}
+		}
+		nodes = filterChart(nodes, chart)
+		nodes = s.includePersistentVolumes(g, nodes, "")
+	} else {
+		nodes = filterChart(g.GetAllNodes(), chart)
+		nodes = s.includePersistentVolumes(g, nodes, "")
+	}
+
+	return nodes
+}
+
+// filterChart keeps the nodes rendered from a chart, by chart name; all nodes when chart is ""
+func filterChart(nodes []*graph.Node, chart string) []*graph.Node {
+	if chart == "" {
+		return nodes
+	}
+	filtered := make([]*graph.Node, 0)
+	for _, node := range nodes {
+		if name, _ := graph.SplitChart(node.HelmChart); name == chart {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/api/astrolabev1"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+)
+
+// GRPCServer serves the graph over gRPC (see proto/astrolabe/v1/astrolabe.proto), selecting
+// resources the same way as the HTTP API
+type GRPCServer struct {
+	astrolabev1.UnimplementedAstrolabeServer
+
+	api           *Server
+	port          int
+	watchInterval time.Duration
+	server        *grpc.Server
+
+	// draining is closed on shutdown, ending the watch streams
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+// NewGRPCServer creates a gRPC server reading the graph of the HTTP API server. Watch streams
+// check for graph changes every watchInterval.
+func NewGRPCServer(api *Server, port int, watchInterval time.Duration) *GRPCServer {
+	return &GRPCServer{
+		api:           api,
+		port:          port,
+		watchInterval: watchInterval,
+		draining:      make(chan struct{}),
+	}
+}
+
+// Start starts the gRPC server
+func (s *GRPCServer) Start() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return err
+	}
+	klog.Infof("Starting gRPC server on port %d", s.port)
+	return s.Serve(listener)
+}
+
+// Serve serves gRPC on a listener, e.g. an in-process one
+func (s *GRPCServer) Serve(listener net.Listener) error {
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.api.unaryScopeInterceptor),
+		grpc.ChainStreamInterceptor(s.api.streamScopeInterceptor),
+	}
+	if s.api.options.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.api.options.TLSCertFile, s.api.options.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	s.server = grpc.NewServer(options...)
+	astrolabev1.RegisterAstrolabeServer(s.server, s)
+	return s.server.Serve(listener)
+}
+
+// Stop stops the gRPC server, ending open watch streams
+func (s *GRPCServer) Stop() error {
+	if s.server != nil {
+		s.server.Stop()
+	}
+	return nil
+}
+
+// Shutdown ends the watch streams and stops the gRPC server once the calls in flight returned,
+// or when ctx is done
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+	if s.server == nil {
+		return nil
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		klog.Warning("gRPC server: calls still in flight after the drain timeout, closing their connections")
+		s.server.Stop()
+	}
+	return nil
+}
+
+// GetGraph returns the nodes matching the filter with the edges between them
+func (s *GRPCServer) GetGraph(ctx context.Context, req *astrolabev1.GetGraphRequest) (*astrolabev1.Graph, error) {
+	release, namespace, exclude, err := filterValues(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.activateNamespace(ctx, namespace); err != nil {
+		return nil, err
+	}
+
+	g := s.api.graphFor(ctx)
+	generation := s.api.graph.Generation()
+	return graphMessage(sortedNodes(exclude.apply(s.api.graphNodes(g, release, namespace, ""))), generation), nil
+}
+
+// GetResources returns the nodes matching the filter
+func (s *GRPCServer) GetResources(ctx context.Context, req *astrolabev1.GetResourcesRequest) (*astrolabev1.GetResourcesResponse, error) {
+	release, namespace, exclude, err := filterValues(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.activateNamespace(ctx, namespace); err != nil {
+		return nil, err
+	}
+
+	g := s.api.graphFor(ctx)
+	return resourcesMessage(sortedNodes(exclude.apply(s.api.resourceNodes(g, release, namespace, "")))), nil
+}
+
+// WatchGraph sends the graph matching the filter, then the changes whenever the graph
+// generation moves on, until the client goes away
+func (s *GRPCServer) WatchGraph(req *astrolabev1.WatchGraphRequest, stream astrolabev1.Astrolabe_WatchGraphServer) error {
+	ctx := stream.Context()
+	release, namespace, exclude, err := filterValues(req.GetFilter())
+	if err != nil {
+		return err
+	}
+	if err := s.activateNamespace(ctx, namespace); err != nil {
+		return err
+	}
+
+	g := s.api.graphFor(ctx)
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	var last *watchState
+	for {
+		generation := s.api.graph.Generation()
+		if last == nil || generation != last.generation {
+			current := newWatchState(generation, exclude.apply(s.api.graphNodes(g, release, namespace, "")))
+			if update := current.diff(last); update != nil {
+				if err := stream.Send(update); err != nil {
+					return err
+				}
+			}
+			last = current
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		case <-s.draining:
+			// Clients reconnect to another replica
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+// activateNamespace starts a lazily watched namespace before it is read
+func (s *GRPCServer) activateNamespace(ctx context.Context, namespace string) error {
+	if s.api.namespaces == nil || namespace == "" || !tenancy.FromContext(ctx).AllowsNamespace(namespace) {
+		return nil
+	}
+	syncCtx, cancel := context.WithTimeout(ctx, namespaceSyncTimeout)
+	defer cancel()
+	if err := s.api.namespaces.ActivateNamespace(syncCtx, namespace); err != nil {
+		if ctx.Err() != nil {
+			// The client gave up waiting
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		klog.V(2).Infof("Serving namespace %s before its informers synced: %v", namespace, err)
+	}
+	return nil
+}
+
+func filterValues(filter *astrolabev1.Filter) (string, string, kindFilter, error) {
+	exclude, err := parseKindFilter(filter.GetExcludeKinds()...)
+	if err != nil {
+		return "", "", nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return filter.GetRelease(), filter.GetNamespace(), exclude, nil
+}
+
+// watchState is the part of the graph sent to a watch stream, used to compute the next update
+type watchState struct {
+	generation uint64
+	nodes      map[string]*astrolabev1.Node
+	edges      map[edgeKey]*astrolabev1.Edge
+}
+
+type edgeKey struct {
+	from, to string
+}
+
+func newWatchState(generation uint64, selected []*graph.Node) *watchState {
+	nodes, edges := graphMessages(selected)
+	state := &watchState{
+		generation: generation,
+		nodes:      make(map[string]*astrolabev1.Node, len(nodes)),
+		edges:      make(map[edgeKey]*astrolabev1.Edge, len(edges)),
+	}
+	for _, node := range nodes {
+		state.nodes[node.Uid] = node
+	}
+	for _, edge := range edges {
+		state.edges[edgeKey{edge.From, edge.To}] = edge
+	}
+	return state
+}
+
+// diff returns the update turning previous into s, the full graph without previous, or nil
+// if nothing visible changed
+func (s *watchState) diff(previous *watchState) *astrolabev1.GraphUpdate {
+	update := &astrolabev1.GraphUpdate{Generation: s.generation}
+	if previous == nil {
+		update.Initial = true
+		for _, node := range s.nodes {
+			update.Nodes = append(update.Nodes, node)
+		}
+		for _, edge := range s.edges {
+			update.Edges = append(update.Edges, edge)
+		}
+		sortUpdate(update)
+		return update
+	}
+
+	for uid, node := range s.nodes {
+		if old, exists := previous.nodes[uid]; !exists || !proto.Equal(old, node) {
+			update.Nodes = append(update.Nodes, node)
+		}
+	}
+	for uid := range previous.nodes {
+		if _, exists := s.nodes[uid]; !exists {
+			update.DeletedNodes = append(update.DeletedNodes, uid)
+		}
+	}
+	for key, edge := range s.edges {
+		if old, exists := previous.edges[key]; !exists || edgeChanged(old, edge) {
+			update.Edges = append(update.Edges, edge)
+		}
+	}
+	for key := range previous.edges {
+		if _, exists := s.edges[key]; !exists {
+			update.DeletedEdges = append(update.DeletedEdges, &astrolabev1.EdgeRef{From: key.from, To: key.to})
+		}
+	}
+
+	if len(update.Nodes)+len(update.DeletedNodes)+len(update.Edges)+len(update.DeletedEdges) == 0 {
+		return nil
+	}
+	sortUpdate(update)
+	return update
+}
+
+// sortUpdate orders the nodes of an update like the other responses and edges by endpoints
+func sortUpdate(update *astrolabev1.GraphUpdate) {
+	sort.Slice(update.Nodes, func(i, j int) bool {
+		a, b := update.Nodes[i], update.Nodes[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Uid < b.Uid
+	})
+	sort.Slice(update.Edges, func(i, j int) bool {
+		a, b := update.Edges[i], update.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	sort.Strings(update.DeletedNodes)
+	sort.Slice(update.DeletedEdges, func(i, j int) bool {
+		a, b := update.DeletedEdges[i], update.DeletedEdges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+}
+
+// edgeChanged compares edges ignoring LastConfirmed, which moves on every resync
+func edgeChanged(a, b *astrolabev1.Edge) bool {
+	if a.Type != b.Type || a.Stale != b.Stale || len(a.Metadata) != len(b.Metadata) {
+		return true
+	}
+	for key, value := range a.Metadata {
+		if b.Metadata[key] != value {
+			return true
+		}
+	}
+	return false
+}
+
+// Conversion to protobuf messages
+
+// graphMessage converts nodes and the edges between them, as of generation
+func graphMessage(nodes []*graph.Node, generation uint64) *astrolabev1.Graph {
+	nodeMessages, edgeMessages := graphMessages(nodes)
+	return &astrolabev1.Graph{
+		Nodes:      nodeMessages,
+		Edges:      edgeMessages,
+		Generation: generation,
+	}
+}
+
+// resourcesMessage converts nodes, without their edges
+func resourcesMessage(nodes []*graph.Node) *astrolabev1.GetResourcesResponse {
+	message := &astrolabev1.GetResourcesResponse{Nodes: make([]*astrolabev1.Node, 0, len(nodes))}
+	for _, node := range nodes {
+		message.Nodes = append(message.Nodes, nodeMessage(node))
+	}
+	return message
+}
+
+// graphMessages converts nodes and the edges between them
+func graphMessages(nodes []*graph.Node) ([]*astrolabev1.Node, []*astrolabev1.Edge) {
+	selected := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		selected[string(node.UID)] = true
+	}
+
+	nodeMessages := make([]*astrolabev1.Node, 0, len(nodes))
+	var edgeMessages []*astrolabev1.Edge
+	for _, node := range nodes {
+		nodeMessages = append(nodeMessages, nodeMessage(node))
+		for _, edge := range nodeEdges(node) {
+			if selected[string(edge.FromUID)] && selected[string(edge.ToUID)] {
+				edgeMessages = append(edgeMessages, edgeMessage(edge))
+			}
+		}
+	}
+	return nodeMessages, edgeMessages
+}
+
+// sortedNodes sorts nodes in the default order of the HTTP API (namespace, kind, name)
+func sortedNodes(nodes []*graph.Node) []*graph.Node {
+	sortOrder{}.sortNodes(nodes)
+	return nodes
+}
+
+func nodeMessage(node *graph.Node) *astrolabev1.Node {
+	message := &astrolabev1.Node{
+		Uid:               string(node.UID),
+		Name:              node.Name,
+		Namespace:         node.Namespace,
+		Kind:              node.Kind,
+		ApiVersion:        node.APIVersion,
+		ResourceVersion:   node.ResourceVersion,
+		Labels:            node.Labels,
+		CreationTimestamp: timestamp(node.CreationTimestamp),
+		Status:            string(node.Status),
+		Message:           node.StatusMessage,
+		Reason:            node.StatusReason,
+		Chart:             node.HelmChart,
+		Release:           node.HelmRelease,
+		Metadata:          metadataMessage(node.Metadata),
+	}
+	if node.DeletedAt != nil {
+		message.DeletedAt = timestamppb.New(*node.DeletedAt)
+	}
+	return message
+}
+
+func metadataMessage(metadata *graph.ResourceMetadata) *astrolabev1.Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	message := &astrolabev1.Metadata{
+		NodeName:        metadata.NodeName,
+		Image:           metadata.Image,
+		RestartCount:    int32(metadata.RestartCount),
+		VolumeName:      metadata.VolumeName,
+		ClaimRef:        objectReferenceMessage(metadata.ClaimRef),
+		ClusterIp:       metadata.ClusterIP,
+		ServiceType:     metadata.ServiceType,
+		IngressClass:    metadata.IngressClass,
+		ScaleTargetRef:  objectReferenceMessage(metadata.ScaleTargetRef),
+		MinReplicas:     metadata.MinReplicas,
+		MaxReplicas:     metadata.MaxReplicas,
+		CurrentReplicas: metadata.CurrentReplicas,
+		DesiredReplicas: metadata.DesiredReplicas,
+		Computed:        metadata.Computed,
+	}
+	for _, container := range metadata.Containers {
+		message.Containers = append(message.Containers, &astrolabev1.Container{
+			Name:                  container.Name,
+			Image:                 container.Image,
+			Init:                  container.Init,
+			Ready:                 container.Ready,
+			Restarts:              container.Restarts,
+			State:                 container.State,
+			Requests:              container.Requests,
+			Limits:                container.Limits,
+			LastTerminationReason: container.LastTerminationReason,
+		})
+	}
+	if excerpt := metadata.LogExcerpt; excerpt != nil {
+		message.LogExcerpt = &astrolabev1.LogExcerpt{
+			Container: excerpt.Container,
+			Previous:  excerpt.Previous,
+			Lines:     excerpt.Lines,
+			SampledAt: timestamp(excerpt.SampledAt),
+		}
+	}
+	if replicas := metadata.Replicas; replicas != nil {
+		message.Replicas = &astrolabev1.Replicas{
+			Desired:   replicas.Desired,
+			Current:   replicas.Current,
+			Ready:     replicas.Ready,
+			Available: replicas.Available,
+		}
+	}
+	if gitOps := metadata.GitOps; gitOps != nil {
+		message.GitOps = &astrolabev1.GitOpsStatus{
+			SyncStatus:           gitOps.SyncStatus,
+			HealthStatus:         gitOps.HealthStatus,
+			RepoUrl:              gitOps.RepoURL,
+			Path:                 gitOps.Path,
+			Revision:             gitOps.Revision,
+			DestinationNamespace: gitOps.DestinationNamespace,
+			SourceRef:            gitOps.SourceRef,
+		}
+	}
+	return message
+}
+
+func objectReferenceMessage(ref *graph.ObjectReference) *astrolabev1.ObjectReference {
+	if ref == nil {
+		return nil
+	}
+	return &astrolabev1.ObjectReference{
+		Kind:      ref.Kind,
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+		Uid:       string(ref.UID),
+	}
+}
+
+func edgeMessage(edge *graph.Edge) *astrolabev1.Edge {
+	return &astrolabev1.Edge{
+		Type:          string(edge.Type),
+		From:          string(edge.FromUID),
+		To:            string(edge.ToUID),
+		Metadata:      edge.Metadata,
+		LastConfirmed: timestamp(edge.LastConfirmed),
+		Stale:         edge.Stale,
+	}
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+)
+
+// defaultHealthWindow is the window of the health history when none is requested
+const defaultHealthWindow = "7d"
+
+// handleReleaseHealthHistory returns the hourly health of a release over ?window=, as the
+// percentage of samples in which it was ready, degraded or in error
+func (s *Server) handleReleaseHealthHistory(w http.ResponseWriter, r *http.Request) {
+	release := r.PathValue("name")
+	value := r.URL.Query().Get("window")
+	if value == "" {
+		value = defaultHealthWindow
+	}
+	window, err := parseWindow(value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Points aggregate the whole release, so scoped tokens only see the history of releases
+	// they currently see resources of
+	scope := tenancy.FromContext(r.Context())
+	if !scope.AllowsRelease(release) ||
+		(!scope.Unrestricted() && len(s.graphFor(r.Context()).GetNodesByHelmRelease(release)) == 0) {
+		writeError(w, http.StatusNotFound, "release "+release+" not found")
+		return
+	}
+
+	points, err := s.healthHistory.History(release, time.Now().Add(-window))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read health history: "+err.Error())
+		return
+	}
+
+	resp := ReleaseHealthHistoryResponse{
+		Release: release,
+		Window:  value,
+		Points:  make([]HealthPoint, 0, len(points)),
+	}
+	var total healthhistory.Point
+	for _, point := range points {
+		total.Add(point)
+		resp.Points = append(resp.Points, HealthPoint{Time: point.Time, HealthShare: healthShare(point)})
+	}
+	resp.Overall = healthShare(total)
+	writeJSON(w, resp)
+}
+
+func healthShare(point healthhistory.Point) HealthShare {
+	samples := point.Samples()
+	percent := func(count int) float64 {
+		if samples == 0 {
+			return 0
+		}
+		return math.Round(float64(count)*10000/float64(samples)) / 100
+	}
+	return HealthShare{
+		Samples:  samples,
+		Ready:    percent(point.Ready),
+		Degraded: percent(point.Degraded),
+		Error:    percent(point.Error),
+	}
+}
+
+// parseWindow parses a duration, accepting days (e.g. 30d) besides time.ParseDuration units
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil {
+			window = time.Duration(n) * 24 * time.Hour
+		}
+	} else {
+		window, _ = time.ParseDuration(value)
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be a positive duration, e.g. 30d or 12h")
+	}
+	return window, nil
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleImages lists the container images in use, i.e. of the containers and init containers
+// of the Pods in the graph, with the namespaces, releases and workloads running them. Pods are
+// attributed to the top of their ownership chain, so the Pods of a Deployment are reported as
+// the Deployment.
+func (s *Server) handleImages(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	writeJSON(w, buildImages(g, query.Get("namespace"), query.Get("release"), query.Get("image")))
+}
+
+// imageUsage accumulates the use of an image
+type imageUsage struct {
+	ImageUsage
+	namespaces map[string]bool
+	releases   map[string]bool
+	workloads  map[types.UID]*ImageWorkload
+}
+
+func buildImages(g graph.GraphInterface, namespace, release, image string) ImagesResponse {
+	image = strings.ToLower(image)
+	images := make(map[string]*imageUsage)
+	for _, pod := range g.GetAllNodes() {
+		if pod.Kind != "Pod" || pod.Metadata == nil {
+			continue
+		}
+		if namespace != "" && pod.Namespace != namespace {
+			continue
+		}
+		workload := topOwner(g, pod)
+		podRelease := pod.HelmRelease
+		if podRelease == "" {
+			podRelease = workload.HelmRelease
+		}
+		if release != "" && podRelease != release {
+			continue
+		}
+
+		// A Pod counts once per image, however many of its containers run it
+		seen := make(map[string]bool, len(pod.Metadata.Containers))
+		for _, container := range pod.Metadata.Containers {
+			if container.Image == "" || seen[container.Image] {
+				continue
+			}
+			if image != "" && !strings.Contains(strings.ToLower(container.Image), image) {
+				continue
+			}
+			seen[container.Image] = true
+
+			usage, exists := images[container.Image]
+			if !exists {
+				usage = &imageUsage{
+					ImageUsage: ImageUsage{Image: container.Image},
+					namespaces: make(map[string]bool),
+					releases:   make(map[string]bool),
+					workloads:  make(map[types.UID]*ImageWorkload),
+				}
+				images[container.Image] = usage
+			}
+			usage.Pods++
+			usage.namespaces[pod.Namespace] = true
+			if podRelease != "" {
+				usage.releases[podRelease] = true
+			}
+			used, exists := usage.workloads[workload.UID]
+			if !exists {
+				used = &ImageWorkload{
+					UID:       string(workload.UID),
+					Kind:      workload.Kind,
+					Namespace: workload.Namespace,
+					Name:      workload.Name,
+					Release:   workload.HelmRelease,
+					Cluster:   workload.Cluster,
+				}
+				usage.workloads[workload.UID] = used
+			}
+			used.Pods++
+		}
+	}
+
+	resp := ImagesResponse{Images: make([]ImageUsage, 0, len(images))}
+	for _, usage := range images {
+		usage.Namespaces = sortedSet(usage.namespaces)
+		usage.Releases = sortedSet(usage.releases)
+		usage.Workloads = make([]ImageWorkload, 0, len(usage.workloads))
+		for _, workload := range usage.workloads {
+			usage.Workloads = append(usage.Workloads, *workload)
+		}
+		sort.Slice(usage.Workloads, func(i, j int) bool {
+			a, b := usage.Workloads[i], usage.Workloads[j]
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
+		resp.Images = append(resp.Images, usage.ImageUsage)
+	}
+	sort.Slice(resp.Images, func(i, j int) bool { return resp.Images[i].Image < resp.Images[j].Image })
+	return resp
+}
+
+// sortedSet returns the members of a set in order
+func sortedSet(set map[string]bool) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
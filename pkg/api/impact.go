@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// impactEdgeTypes are the edge types through which a kind of object is used
+var impactEdgeTypes = map[string]graph.EdgeType{
+	"ConfigMap": graph.EdgeConfigMapRef,
+	"Secret":    graph.EdgeSecretRef,
+}
+
+// handleImpact lists the workloads and releases using a ConfigMap or Secret, i.e. those
+// affected by a change to it. The resources referencing the object, Pods or workloads, are
+// attributed to the top of their ownership chain, so the Pods of a Deployment are reported as
+// the Deployment.
+func (s *Server) handleImpact(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	namespace, name := query.Get("namespace"), query.Get("name")
+
+	var kind string
+	for impactKind := range impactEdgeTypes {
+		if strings.EqualFold(impactKind, query.Get("kind")) {
+			kind = impactKind
+		}
+	}
+	if kind == "" {
+		writeError(w, http.StatusBadRequest, "kind must be ConfigMap or Secret")
+		return
+	}
+	if namespace == "" || name == "" {
+		writeError(w, http.StatusBadRequest, "namespace and name are required")
+		return
+	}
+
+	target, exists := g.GetNodeByRef(namespace, kind, name)
+	if !exists {
+		writeError(w, http.StatusNotFound, kind+" "+namespace+"/"+name+" not found")
+		return
+	}
+	writeJSON(w, buildImpact(g, target, impactEdgeTypes[kind]))
+}
+
+func buildImpact(g graph.GraphInterface, target *graph.Node, edgeType graph.EdgeType) ImpactResponse {
+	workloads := make(map[types.UID]*ImpactedWorkload)
+	releases := make(map[string]bool)
+	for _, edge := range sortedIncomingEdges(target.IncomingEdges) {
+		if edge.Type != edgeType {
+			continue
+		}
+		source, exists := g.GetNode(edge.FromUID)
+		if !exists {
+			continue
+		}
+		workload := topOwner(g, source)
+		impacted, exists := workloads[workload.UID]
+		if !exists {
+			impacted = &ImpactedWorkload{
+				UID:        string(workload.UID),
+				Kind:       workload.Kind,
+				Namespace:  workload.Namespace,
+				Name:       workload.Name,
+				Release:    workload.HelmRelease,
+				Status:     string(workload.Status),
+				References: make([]ImpactReference, 0, 1),
+			}
+			workloads[workload.UID] = impacted
+		}
+		impacted.References = append(impacted.References, ImpactReference{
+			Kind: source.Kind,
+			Name: source.Name,
+			Refs: splitCommaList(edge.Metadata[graph.EdgeMetaRefs]),
+			Keys: splitCommaList(edge.Metadata[graph.EdgeMetaKeys]),
+		})
+		for _, node := range []*graph.Node{workload, source} {
+			if node.HelmRelease != "" {
+				releases[node.HelmRelease] = true
+			}
+		}
+	}
+
+	resp := ImpactResponse{
+		Kind:      target.Kind,
+		Namespace: target.Namespace,
+		Name:      target.Name,
+		UID:       string(target.UID),
+		Workloads: make([]ImpactedWorkload, 0, len(workloads)),
+		Releases:  make([]string, 0, len(releases)),
+	}
+	for _, workload := range workloads {
+		sort.Slice(workload.References, func(i, j int) bool {
+			a, b := workload.References[i], workload.References[j]
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
+		resp.Workloads = append(resp.Workloads, *workload)
+	}
+	sort.Slice(resp.Workloads, func(i, j int) bool {
+		a, b := resp.Workloads[i], resp.Workloads[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	for release := range releases {
+		resp.Releases = append(resp.Releases, release)
+	}
+	sort.Strings(resp.Releases)
+	return resp
+}
+
+// topOwner follows the ownership edges of a node up to the resource at the top of the chain,
+// e.g. from a Pod to its Deployment, stopping at owners that are not in g
+func topOwner(g graph.GraphInterface, node *graph.Node) *graph.Node {
+	visited := map[types.UID]bool{node.UID: true}
+	for {
+		var owner *graph.Node
+		for _, edge := range sortedIncomingEdges(node.IncomingEdges) {
+			if edge.Type != graph.EdgeOwnership || visited[edge.FromUID] {
+				continue
+			}
+			if found, exists := g.GetNode(edge.FromUID); exists {
+				owner = found
+				break
+			}
+		}
+		if owner == nil {
+			return node
+		}
+		visited[owner.UID] = true
+		node = owner
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// kindFilter holds the patterns of the excludeKinds and kinds query parameters. Patterns are matched
+// case-insensitively against the kind (e.g. Secret) and the kind qualified with its API group
+// (e.g. Lease.coordination.k8s.io), using path.Match wildcards such as *.coordination.k8s.io.
+type kindFilter []string
+
+// parseKindFilter parses a comma-separated list of kind patterns
+func parseKindFilter(values ...string) (kindFilter, error) {
+	var filter kindFilter
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid kind pattern %q: %w", pattern, err)
+			}
+			filter = append(filter, pattern)
+		}
+	}
+	return filter, nil
+}
+
+// excludeKinds parses the excludeKinds query parameter, writing a 400 response if it is invalid
+func excludeKinds(w http.ResponseWriter, r *http.Request) (kindFilter, bool) {
+	filter, err := parseKindFilter(r.URL.Query().Get("excludeKinds"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// onlyKinds parses the kinds query parameter, writing a 400 response if it is invalid
+func onlyKinds(w http.ResponseWriter, r *http.Request) (kindFilter, bool) {
+	filter, err := parseKindFilter(r.URL.Query().Get("kinds"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// matches reports whether a kind of the API version matches a pattern
+func (f kindFilter) matches(kind, apiVersion string) bool {
+	kind = strings.ToLower(kind)
+	qualified := kind
+	if group, _, found := strings.Cut(apiVersion, "/"); found {
+		qualified = kind + "." + strings.ToLower(group)
+	}
+	for _, pattern := range f {
+		if matched, _ := path.Match(pattern, kind); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, qualified); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// apply drops the nodes of excluded kinds. Patterns are evaluated once per kind, before any
+// node is serialized.
+func (f kindFilter) apply(nodes []*graph.Node) []*graph.Node {
+	return f.filter(nodes, false)
+}
+
+// keep drops the nodes of the kinds not matched, keeping all nodes when there are no patterns
+func (f kindFilter) keep(nodes []*graph.Node) []*graph.Node {
+	return f.filter(nodes, true)
+}
+
+// filter keeps the nodes whose kind matches or, when matching is false, does not match
+func (f kindFilter) filter(nodes []*graph.Node, matching bool) []*graph.Node {
+	if len(f) == 0 {
+		return nodes
+	}
+	matched := make(map[[2]string]bool)
+	filtered := make([]*graph.Node, 0, len(nodes))
+	for _, node := range nodes {
+		key := [2]string{node.Kind, node.APIVersion}
+		match, evaluated := matched[key]
+		if !evaluated {
+			match = f.matches(node.Kind, node.APIVersion)
+			matched[key] = match
+		}
+		if match == matching {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"github.com/ammarlakis/astrolabe/pkg/uninstalls"
+)
+
+// handleReleaseLeftovers reports whether a release was uninstalled with resources left, and
+// lists them for clean-up
+func (s *Server) handleReleaseLeftovers(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	release := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+
+	scope := tenancy.FromContext(r.Context())
+	var found []uninstalls.Uninstall
+	for _, u := range s.uninstalls.Uninstalls(release) {
+		if !scope.AllowsRelease(release) || !scope.AllowsNamespace(u.Namespace) {
+			continue
+		}
+		if namespace == "" || u.Namespace == namespace {
+			found = append(found, u)
+		}
+	}
+	if len(found) > 1 {
+		namespaces := make([]string, 0, len(found))
+		for _, u := range found {
+			namespaces = append(namespaces, u.Namespace)
+		}
+		writeError(w, http.StatusBadRequest, "release "+release+" was uninstalled from namespaces "+
+			strings.Join(namespaces, ", ")+", set namespace")
+		return
+	}
+
+	resp := ReleaseLeftoversResponse{Release: release, Namespace: namespace, Leftovers: make([]LeftoverResource, 0)}
+	if len(found) == 0 {
+		if !releaseExists(g, release, namespace) {
+			writeError(w, http.StatusNotFound, "no resources of release "+release)
+			return
+		}
+		resp.Status = uninstalls.StateInstalled
+		writeJSON(w, resp)
+		return
+	}
+
+	u := found[0]
+	leftovers := uninstalls.Leftovers(g, release, u.Namespace)
+	graceEnds := u.At.Add(s.uninstalls.Grace())
+	resp.Namespace = u.Namespace
+	resp.Status = s.uninstalls.State(u, len(leftovers), time.Now())
+	resp.UninstalledAt = &u.At
+	resp.GracePeriodEnds = &graceEnds
+	for _, node := range leftovers {
+		resp.Leftovers = append(resp.Leftovers, LeftoverResource{
+			UID:        string(node.UID),
+			APIVersion: node.APIVersion,
+			Kind:       node.Kind,
+			Namespace:  node.Namespace,
+			Name:       node.Name,
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// releaseExists reports whether a release has resources, in a namespace when set
+func releaseExists(g graph.GraphInterface, release, namespace string) bool {
+	for _, node := range g.GetNodesByHelmRelease(release) {
+		if namespace == "" || graph.ReleaseNamespace(node) == namespace {
+			return true
+		}
+	}
+	return false
+}
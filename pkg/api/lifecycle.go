@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// Lifecycle is a server that is started and stopped with the others of a Group
+type Lifecycle interface {
+	// Start serves until the server is stopped or fails. It returns nil once stopped.
+	Start() error
+	// Stop stops serving. It can be called more than once.
+	Stop() error
+	// Shutdown stops accepting requests and waits for those in flight until ctx is done, when
+	// it stops serving like Stop
+	Shutdown(ctx context.Context) error
+}
+
+// Group starts its members together and stops them together: when one fails, the others are
+// stopped too, so the process never runs with only part of its listeners
+type Group struct {
+	members []Lifecycle
+}
+
+// NewGroup creates a group of members
+func NewGroup(members ...Lifecycle) *Group {
+	return &Group{members: members}
+}
+
+// Add adds a member; members must be added before Start
+func (g *Group) Add(member Lifecycle) {
+	g.members = append(g.members, member)
+}
+
+// Start starts every member and waits until all are stopped. It returns the first failure.
+func (g *Group) Start() error {
+	errs := make(chan error, len(g.members))
+	for _, member := range g.members {
+		go func() {
+			errs <- member.Start()
+		}()
+	}
+
+	var first error
+	for range g.members {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			if stopErr := g.Stop(); stopErr != nil {
+				klog.Errorf("Error stopping servers after a failure: %v", stopErr)
+			}
+		}
+	}
+	return first
+}
+
+// Stop stops every member
+func (g *Group) Stop() error {
+	var errs []error
+	for _, member := range g.members {
+		if err := member.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown drains every member concurrently, so they share the deadline of ctx
+func (g *Group) Shutdown(ctx context.Context) error {
+	errs := make(chan error, len(g.members))
+	for _, member := range g.members {
+		go func() {
+			errs <- member.Shutdown(ctx)
+		}()
+	}
+	var all []error
+	for range g.members {
+		if err := <-errs; err != nil {
+			all = append(all, err)
+		}
+	}
+	return errors.Join(all...)
+}
+
+// drainer tracks the requests in flight, so that they can complete on shutdown while new ones
+// are rejected
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	// idle is closed once draining with no request in flight
+	idle chan struct{}
+}
+
+// begin counts a request in flight, returning false when draining
+func (d *drainer) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight++
+	return true
+}
+
+// end counts a request as completed
+func (d *drainer) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight--
+	if d.draining && d.inFlight == 0 {
+		close(d.idle)
+	}
+}
+
+// wait starts draining and waits until no request is in flight or ctx is done
+func (d *drainer) wait(ctx context.Context) {
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		d.idle = make(chan struct{})
+		if d.inFlight == 0 {
+			close(d.idle)
+		}
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+}
+
+// httpListener serves a handler on one port, with TLS when a certificate is set
+type httpListener struct {
+	name            string
+	port            int
+	certFile        string
+	keyFile         string
+	writeBufferSize int
+	server          *http.Server
+}
+
+// newHTTPListener creates a listener serving server's handler on its address
+func newHTTPListener(name string, server *http.Server, port int) *httpListener {
+	server.Addr = fmt.Sprintf(":%d", port)
+	return &httpListener{name: name, port: port, server: server}
+}
+
+func (l *httpListener) Start() error {
+	listener, err := net.Listen("tcp", l.server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s listener: %w", l.name, err)
+	}
+	if l.writeBufferSize > 0 {
+		listener = &bufferedListener{Listener: listener, writeBufferSize: l.writeBufferSize}
+	}
+
+	if l.certFile != "" {
+		klog.Infof("Starting %s listener on port %d (TLS, HTTP/2 enabled)", l.name, l.port)
+		err = l.server.ServeTLS(listener, l.certFile, l.keyFile)
+	} else {
+		klog.Infof("Starting %s listener on port %d", l.name, l.port)
+		err = l.server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (l *httpListener) Stop() error {
+	return l.server.Close()
+}
+
+func (l *httpListener) Shutdown(ctx context.Context) error {
+	err := l.server.Shutdown(ctx)
+	if ctx.Err() != nil {
+		klog.Warningf("%s listener: requests still in flight after the drain timeout, closing their connections", l.name)
+		return l.server.Close()
+	}
+	return err
+}
+
+// bufferedListener sets the socket send buffer size of accepted TCP connections
+type bufferedListener struct {
+	net.Listener
+	writeBufferSize int
+}
+
+func (l *bufferedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetWriteBuffer(l.writeBufferSize); err != nil {
+			klog.V(2).Infof("Failed to set write buffer size: %v", err)
+		}
+	}
+	return conn, nil
+}
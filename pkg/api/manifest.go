@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/manifest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// handleResourceManifest serves the live object of a tracked resource, fetched from the
+// Kubernetes API with the server's credentials and sanitized. ?format=yaml (default) or json.
+func (s *Server) handleResourceManifest(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		writeError(w, http.StatusBadRequest, "format must be yaml or json")
+		return
+	}
+
+	node, exists := g.GetNode(types.UID(r.PathValue("uid")))
+	if !exists {
+		writeError(w, http.StatusNotFound, "resource not found in graph")
+		return
+	}
+	if node.Cluster != "" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("resource of federated cluster %s: fetch its manifest from the cluster's instance", node.Cluster))
+		return
+	}
+
+	obj, err := s.manifests.Fetch(r.Context(), manifest.Ref{
+		APIVersion: node.APIVersion,
+		Kind:       node.Kind,
+		Namespace:  node.Namespace,
+		Name:       node.Name,
+	})
+	if err != nil {
+		writeManifestError(w, node.Kind, node.Namespace, node.Name, err)
+		return
+	}
+
+	if format == "json" {
+		writeJSON(w, obj.Object)
+		return
+	}
+	body, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to encode manifest: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(body)
+}
+
+// writeManifestError maps Kubernetes API errors to HTTP statuses. A forbidden read means the
+// server's service account lacks the get permission, which is reported as such.
+func writeManifestError(w http.ResponseWriter, kind, namespace, name string, err error) {
+	switch {
+	case apierrors.IsForbidden(err):
+		klog.Warningf("Not allowed to get the manifest of %s %s/%s: %v", kind, namespace, name, err)
+		writeError(w, http.StatusForbidden, fmt.Sprintf("astrolabe is not allowed to get %s objects; grant its service account the get permission", kind))
+	case apierrors.IsNotFound(err):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s no longer exists in the cluster", kind, name))
+	case meta.IsNoMatchError(err):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("the cluster no longer serves %s: %v", kind, err))
+	default:
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
@@ -0,0 +1,388 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/informers"
+)
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// endpoint documents one API route for the OpenAPI document
+type endpoint struct {
+	method      string
+	path        string
+	summary     string
+	query       []queryParam
+	requestBody interface{} // zero value of the request struct, nil for none
+	response    interface{} // zero value of the response type
+	protobuf    string      // message served for Accept: application/x-protobuf, "" for none
+	csv         []string    // columns served with format=csv, nil for none
+}
+
+type queryParam struct {
+	name        string
+	description string
+	enum        []string
+}
+
+var namespaceParam = queryParam{name: "namespace", description: "Only include resources in this namespace"}
+var releaseParam = queryParam{name: "release", description: "Only include resources of this Helm release"}
+var sortByParam = queryParam{name: "sortBy", description: "Sort by this field (default: namespace, kind, name)", enum: nodeSortKeys}
+var sortByNameParam = queryParam{name: "sortBy", description: "Sort by this field", enum: []string{sortByName}}
+var namesOnlyParam = queryParam{name: "namesOnly", description: "Only return the release names, as an array of strings", enum: []string{"true"}}
+var orderParam = queryParam{name: "order", description: "Sort direction", enum: []string{"asc", "desc"}}
+var chartParam = queryParam{name: "chart", description: "Only include resources rendered from this chart or subchart (name without version)"}
+var excludeKindsParam = queryParam{name: "excludeKinds", description: "Comma-separated kinds to leave out, with wildcards on the group-qualified kind (e.g. Secret,*.coordination.k8s.io)"}
+var includeDeletedParam = queryParam{name: "includeDeleted", description: "Also return the resources deleted within the tombstone retention window, with their deletedAt (requires --tombstone-retention)", enum: []string{"true"}}
+
+// apiEndpoints lists the documented routes. Keep it in sync with the handlers registered in Start.
+var apiEndpoints = []endpoint{
+	{method: "GET", path: "/health", summary: "Health check", response: HealthResponse{}},
+	{method: "GET", path: "/healthz", summary: "Liveness probe: the process serves requests", response: ProbeResponse{}},
+	{method: "GET", path: "/readyz", summary: "Readiness probe: informer caches synced, Redis reachable when persistence is on and the graph readable (503 otherwise)",
+		response: ProbeResponse{}},
+	{method: "GET", path: "/api/v1/capabilities", summary: "Server version, enabled features, endpoints with their query parameters and formats, and request limits, for clients to adapt to the server",
+		response: CapabilitiesResponse{}},
+	{method: "GET", path: "/api/v1/resources", summary: "List resources in the format used by the Grafana datasource",
+		query: []queryParam{releaseParam, namespaceParam, chartParam, excludeKindsParam, sortByParam, orderParam, includeDeletedParam}, response: []Resource{},
+		protobuf: "astrolabe.v1.GetResourcesResponse", csv: columnNames(resourceColumns)},
+	{method: "POST", path: "/api/v1/resources/batch", summary: "Resolve a list of resources by UID or by kind, namespace and name, with their immediate edges (at most 500)",
+		requestBody: []BatchRef{}, response: BatchResponse{}},
+	{method: "GET", path: "/api/v1/releases", summary: "List Helm releases with their chart, status counts, health and last change (names only with namesOnly=true)",
+		query: []queryParam{namespaceParam, namesOnlyParam, sortByNameParam, orderParam}, response: []Release{}},
+	{method: "GET", path: "/api/v1/releases/dependencies", summary: "Dependency graph and deploy order between releases",
+		query: []queryParam{namespaceParam, excludeKindsParam}, response: ReleaseDependenciesResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/history", summary: "Revisions of a Helm release decoded from its release Secrets, newest first",
+		query: []queryParam{namespaceParam}, response: ReleaseHistoryResponse{}},
+	{method: "GET", path: "/api/v1/releases/diff", summary: "Compare two releases of the same chart: resources only one has, and image, replica count and ConfigMap and Secret reference differences",
+		query: []queryParam{{name: "a", description: "First release"}, {name: "b", description: "Second release"},
+			{name: "aNamespace", description: "Namespace of the first release, when the name is installed in several namespaces"},
+			{name: "bNamespace", description: "Namespace of the second release, when the name is installed in several namespaces"}},
+		response: ReleaseDiffResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/leftovers", summary: "Whether a release was uninstalled with resources left, and those resources (requires --uninstall-grace-period)",
+		query: []queryParam{namespaceParam}, response: ReleaseLeftoversResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/topology", summary: "Resources of a release in dependency order, with their level in the hierarchy",
+		query: []queryParam{namespaceParam, chartParam, excludeKindsParam}, response: ReleaseTopologyResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/time-to-ready", summary: "How long the resources of a release took from creation to first Ready, slowest first",
+		query: []queryParam{namespaceParam}, response: ReleaseTimeToReadyResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/charts", summary: "Chart hierarchy of a release: its chart and the subcharts its resources were rendered from",
+		query: []queryParam{namespaceParam}, response: ReleaseChartsResponse{}},
+	{method: "GET", path: "/api/v1/charts", summary: "List Helm chart names", query: []queryParam{namespaceParam}, response: []string{}},
+	{method: "GET", path: "/api/v1/charts/{chart}/releases", summary: "Releases running a chart (name without version) and their chart versions",
+		query: []queryParam{namespaceParam}, response: ChartReleasesResponse{}},
+	{method: "GET", path: "/api/v1/namespaces", summary: "List namespaces that contain resources",
+		query: []queryParam{sortByNameParam, orderParam}, response: []string{}},
+	{method: "GET", path: "/api/v1/graph", summary: "Nodes and edges of the resource graph",
+		query: []queryParam{releaseParam, namespaceParam, chartParam, excludeKindsParam, sortByParam, orderParam, includeDeletedParam,
+			{name: "kinds", description: "Comma-separated kinds to keep, with wildcards on the group-qualified kind like excludeKinds (e.g. Deployment,ReplicaSet,Pod)"},
+			{name: "edgeTypes", description: "Comma-separated edge types to keep (e.g. owns for the ownership tree), all by default"},
+			{name: "summarize", description: "Collapse groups of homogeneous resources into aggregated nodes when there are more than maxNodes", enum: []string{"true"}},
+			{name: "maxNodes", description: "Node limit of a summarized graph (default 200)"},
+			{name: "since", description: "Token of a previous response; only return the nodes changed after it, their edges and the deleted UIDs (GraphChangesResponse), 410 when the token expired"}},
+		response: GraphResponse{}, protobuf: "astrolabe.v1.Graph"},
+	{method: "GET", path: "/api/v1/summary", summary: "Resource counts per status",
+		query:    []queryParam{namespaceParam, excludeKindsParam, {name: "groupBy", description: "Group counts by this field", enum: summaryGroups}},
+		response: SummaryResponse{}},
+	{method: "GET", path: "/api/v1/applications", summary: "Applications formed by Helm releases, ArgoCD, app.kubernetes.io/part-of and other groupers",
+		query:    []queryParam{namespaceParam, excludeKindsParam, {name: "source", description: "Only include applications of this grouper"}},
+		response: []Application{}},
+	{method: "GET", path: "/api/v1/topology", summary: "Pods and PersistentVolumes grouped by the zone of their Node, with the zones each Deployment and StatefulSet runs in",
+		query: []queryParam{releaseParam, namespaceParam}, response: ZonesResponse{}},
+	{method: "GET", path: "/api/v1/search", summary: "Resources whose name, namespace, images or labels match a query, best matches first",
+		query: []queryParam{{name: "q", description: "Search text; every word must match by prefix, substring or up to two typos"},
+			namespaceParam, excludeKindsParam, {name: "limit", description: "Maximum number of results (default 50, at most 500)"}},
+		response: SearchResponse{}},
+	{method: "GET", path: "/api/v1/rollouts/{namespace}/{kind}/{name}/changes", summary: "Image, env and resource changes in the Pod template of a Deployment, StatefulSet or DaemonSet between rollout revisions",
+		query: []queryParam{{name: "revision", description: "Compare this revision with the one before it (default: the current revision)"}}, response: RolloutChangesResponse{}},
+	{method: "GET", path: "/api/v1/impact", summary: "Workloads and releases using a ConfigMap or Secret, i.e. affected by a change to it",
+		query: []queryParam{{name: "kind", description: "Kind of the object", enum: []string{"ConfigMap", "Secret"}},
+			{name: "namespace", description: "Namespace of the object"}, {name: "name", description: "Name of the object"}},
+		response: ImpactResponse{}},
+	{method: "GET", path: "/api/v1/anomalies", summary: "Pods restarting or changing status at least as often as the thresholds of their namespace within the anomaly window (requires --anomaly-window)",
+		query: []queryParam{namespaceParam, releaseParam}, response: AnomaliesResponse{}},
+	{method: "GET", path: "/api/v1/images", summary: "Container images in use by Pods, with the namespaces, releases and workloads running each",
+		query:    []queryParam{namespaceParam, releaseParam, {name: "image", description: "Only include images whose reference contains this text, e.g. a repository or tag"}},
+		response: ImagesResponse{}},
+	{method: "POST", path: "/api/v1/actions/restart", summary: "Rollout restart a workload (requires --enable-actions)",
+		requestBody: ActionRequest{}, response: ActionResponse{}},
+	{method: "POST", path: "/api/v1/actions/scale", summary: "Scale a workload (requires --enable-actions)",
+		requestBody: ActionRequest{}, response: ActionResponse{}},
+	{method: "GET", path: "/api/v1/debug/consistency", summary: "Report of the last graph consistency check (requires --consistency-check-interval)",
+		query: []queryParam{{name: "run", description: "Run a check now", enum: []string{"true"}}}, response: graph.ConsistencyReport{}},
+	{method: "GET", path: "/api/v1/debug/dump", summary: "Dump of the live graph, its pending edges and index sizes, and the goroutine stacks of the process, for debugging",
+		response: graph.StateDump{}},
+	{method: "GET", path: "/api/v1/stats", summary: "Node and edge counts by kind and type, index sizes, pending edges, memory estimates and, with persistence, Redis key counts and the last snapshot, for sizing and troubleshooting",
+		response: StatsResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/timeline", summary: "Recorded events of a release, such as rollbacks, oldest first (disabled with --timeline-size=0)",
+		query: []queryParam{namespaceParam, {name: "since", description: "Only events at or after this RFC 3339 timestamp"}}, response: ReleaseTimelineResponse{}},
+	{method: "GET", path: "/api/v1/releases/{name}/health-history", summary: "Hourly health of a release: percentage of samples ready, degraded or in error (requires --health-history)",
+		query: []queryParam{{name: "window", description: "How far back to go, e.g. 30d or 12h (default 7d)"}}, response: ReleaseHealthHistoryResponse{}},
+	{method: "GET", path: "/api/v1/cluster/apis", summary: "API groups and resources served by the cluster (cached discovery data) and whether their kinds are watched",
+		query: []queryParam{{name: "watched", description: "Only watched (true) or unwatched (false) resources", enum: []string{"true", "false"}},
+			{name: "group", description: "Only this API group (core for the core group)"}}, response: informers.APIReport{}},
+	{method: "GET", path: "/api/v1/resources/{uid}/manifest", summary: "Live object of a resource from the Kubernetes API, without managed fields and with Secret values redacted",
+		query: []queryParam{{name: "format", description: "Manifest format (default yaml)", enum: []string{"yaml", "json"}}}, response: map[string]interface{}{}},
+	{method: "GET", path: "/api/v1/resources/{uid}/events", summary: "Recent Warning Events about a resource, newest first (requires --watch-events)",
+		response: ResourceEventsResponse{}},
+	{method: "GET", path: "/api/v1/analysis", summary: "Background analyses and when they last ran (requires --analysis-interval)",
+		response: []AnalysisSummary{}, csv: columnNames(analysisSummaryColumns)},
+	{method: "GET", path: "/api/v1/analysis/{name}", summary: "Cached findings of an analysis: orphans, selector-conflicts, spread or antipatterns",
+		query: []queryParam{namespaceParam}, response: analysis.Result{}, csv: columnNames(findingColumns)},
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument map[string]interface{}
+)
+
+// handleOpenAPI serves the OpenAPI 3 document of the API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDocument = buildOpenAPI()
+	})
+	writeJSON(w, openAPIDocument)
+}
+
+// handleSwaggerUI serves a Swagger UI page for the OpenAPI document
+func (s *Server) handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(swaggerHTML)
+}
+
+// pathParams returns the names of the {wildcards} in a route path
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.Trim(segment, "{}"))
+		}
+	}
+	return names
+}
+
+// formats returns the response formats of an endpoint
+func (ep endpoint) formats() []string {
+	formats := []string{"json"}
+	if ep.protobuf != "" {
+		formats = append(formats, "protobuf")
+	}
+	if len(ep.csv) > 0 {
+		formats = append(formats, "csv")
+	}
+	return formats
+}
+
+// queryParams returns the query parameters of an endpoint, with those selecting the format and
+// columns of CSV responses
+func (ep endpoint) queryParams() []queryParam {
+	if len(ep.csv) == 0 {
+		return ep.query
+	}
+	return append(ep.query[:len(ep.query):len(ep.query)],
+		queryParam{name: "format", description: "Response format, overriding the Accept header", enum: ep.formats()},
+		queryParam{name: "columns", description: "Comma-separated columns of a CSV response: " + strings.Join(ep.csv, ", ")})
+}
+
+// buildOpenAPI derives the document from the endpoint table and the response structs
+func buildOpenAPI() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	for _, ep := range apiEndpoints {
+		operation := map[string]interface{}{
+			"summary": ep.summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(ep.response), schemas)},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(ErrorResponse{}), schemas)},
+					},
+				},
+			},
+		}
+
+		if ep.protobuf != "" {
+			content := operation["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+			content[formatProtobuf] = map[string]interface{}{"schema": map[string]interface{}{
+				"type":        "string",
+				"format":      "binary",
+				"description": ep.protobuf + " message (see proto/astrolabe/v1/astrolabe.proto)",
+			}}
+		}
+
+		query := ep.queryParams()
+		if len(ep.csv) > 0 {
+			content := operation["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+			content[formatCSV] = map[string]interface{}{"schema": map[string]interface{}{
+				"type":        "string",
+				"description": "Comma-separated values with a header row",
+			}}
+		}
+
+		var params []interface{}
+		for _, name := range pathParams(ep.path) {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(query) > 0 {
+			for _, q := range query {
+				schema := map[string]interface{}{"type": "string"}
+				if len(q.enum) > 0 {
+					schema["enum"] = q.enum
+				}
+				params = append(params, map[string]interface{}{
+					"name":        q.name,
+					"in":          "query",
+					"description": q.description,
+					"schema":      schema,
+				})
+			}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if ep.requestBody != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(ep.requestBody), schemas)},
+				},
+			}
+			operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		} else if !containsString(tenancyExemptPaths, ep.path) {
+			// API tokens are only required when scoped API access is configured
+			operation["security"] = []interface{}{map[string]interface{}{"apiToken": []string{}}, map[string]interface{}{}}
+		}
+
+		pathItem, ok := paths[ep.path].(map[string]interface{})
+		if !ok {
+			pathItem = make(map[string]interface{})
+			paths[ep.path] = pathItem
+		}
+		pathItem[strings.ToLower(ep.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Astrolabe API",
+			"description": "Kubernetes resource graph with Helm release tracking",
+			"version":     apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Kubernetes bearer token"},
+				"apiToken":   map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Astrolabe API token, scoped to namespaces and Helm releases"},
+			},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the JSON schema of t, registering named structs as components
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaFor(t.Elem(), schemas)
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return structSchema(t, schemas)
+		}
+		if _, exists := schemas[name]; !exists {
+			schemas[name] = map[string]interface{}{} // placeholder for recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// Embedded struct fields are encoded inline
+			embedded := structSchema(field.Type, schemas)
+			for embeddedName, property := range embedded["properties"].(map[string]interface{}) {
+				properties[embeddedName] = property
+			}
+			if embeddedRequired, ok := embedded["required"].([]string); ok {
+				required = append(required, embeddedRequired...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaFor(field.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Kubernetes probes the process on /healthz, which only reports that it serves requests, and
+// routes traffic once /readyz passes: the informer caches have synced, Redis is reachable when
+// persistence is on, and the API can read the graph. Until then, the graph may be empty or
+// partial.
+
+// readinessCheckTimeout bounds each readiness check
+const readinessCheckTimeout = 2 * time.Second
+
+// readinessCheck is a named condition of readiness, failing with an error
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// AddReadinessCheck makes /readyz fail while check returns an error
+func (s *Server) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	s.readinessChecks = append(s.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// handleLiveness reports that the process serves requests
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, ProbeResponse{Status: "ok"})
+}
+
+// handleReadiness runs the readiness checks, answering 503 when any fails
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	resp := ProbeResponse{Status: "ready", Checks: make([]ProbeCheck, 0, len(s.readinessChecks)+1)}
+	checks := append([]readinessCheck{{name: "api", check: s.checkGraph}}, s.readinessChecks...)
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		err := check.check(ctx)
+		cancel()
+		result := ProbeCheck{Name: check.name, Status: "ok"}
+		if err != nil {
+			result.Status, result.Error = "failed", err.Error()
+			resp.Status = "not ready"
+		}
+		resp.Checks = append(resp.Checks, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkGraph fails when the graph cannot be read in time, e.g. when a writer holds its lock
+func (s *Server) checkGraph(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.graph.GetNode("")
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("graph not readable in time")
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// handleReleaseTimeToReady aggregates the time to ready of the resources of a release,
+// slowest resources first
+func (s *Server) handleReleaseTimeToReady(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	release := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+
+	var nodes []*graph.Node
+	for _, node := range g.GetNodesByHelmRelease(release) {
+		if namespace == "" || node.Namespace == namespace {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		writeError(w, http.StatusNotFound, "no resources of release "+release)
+		return
+	}
+
+	resp := ReleaseTimeToReadyResponse{Release: release, Resources: make([]ResourceTimeToReady, 0)}
+	for _, node := range nodes {
+		if node.FirstReady.IsZero() {
+			resp.NotReady++
+			continue
+		}
+		timeToReady, ok := node.TimeToReady()
+		if !ok {
+			continue
+		}
+		resp.Resources = append(resp.Resources, ResourceTimeToReady{
+			Kind:        node.Kind,
+			Namespace:   node.Namespace,
+			Name:        node.Name,
+			TimeToReady: timeToReady.Seconds(),
+			FirstReady:  node.FirstReady,
+		})
+	}
+	sort.Slice(resp.Resources, func(i, j int) bool {
+		a, b := resp.Resources[i], resp.Resources[j]
+		if a.TimeToReady != b.TimeToReady {
+			return a.TimeToReady > b.TimeToReady
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	resp.Measured = len(resp.Resources)
+	if resp.Measured > 0 {
+		total := 0.0
+		for _, resource := range resp.Resources {
+			total += resource.TimeToReady
+		}
+		resp.Mean = total / float64(resp.Measured)
+		resp.Max = resp.Resources[0].TimeToReady
+		resp.P50 = slowestPercentile(resp.Resources, 0.5)
+		resp.P90 = slowestPercentile(resp.Resources, 0.9)
+	}
+	writeJSON(w, resp)
+}
+
+// slowestPercentile returns the nearest-rank percentile of times to ready sorted slowest first
+func slowestPercentile(resources []ResourceTimeToReady, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(resources))))
+	return resources[len(resources)-max(rank, 1)].TimeToReady
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// releasePlaceholder replaces the release name in the names of resources, so the resources of
+// two releases of a chart, e.g. staging-web and prod-web, are matched
+const releasePlaceholder = "<release>"
+
+// handleReleaseDiff compares two releases of the same chart, e.g. staging and prod before a
+// promotion: the resources only one of them has, and for the resources both have, differences
+// in images, replica counts and ConfigMap and Secret references
+func (s *Server) handleReleaseDiff(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	if query.Get("a") == "" || query.Get("b") == "" {
+		writeError(w, http.StatusBadRequest, "a and b are required")
+		return
+	}
+
+	resolver := newChartResolver(g)
+	a, status, message := diffSide(g, resolver, query.Get("a"), query.Get("aNamespace"), "aNamespace")
+	if status != 0 {
+		writeError(w, status, message)
+		return
+	}
+	b, status, message := diffSide(g, resolver, query.Get("b"), query.Get("bNamespace"), "bNamespace")
+	if status != 0 {
+		writeError(w, status, message)
+		return
+	}
+	if a.chart != b.chart {
+		writeError(w, http.StatusBadRequest, "releases "+a.info.Release+" (chart "+a.chart+") and "+
+			b.info.Release+" (chart "+b.chart+") are not of the same chart")
+		return
+	}
+	writeJSON(w, buildReleaseDiff(g, a, b))
+}
+
+// releaseDiffSide is a release being compared and its resources, by key
+type releaseDiffSide struct {
+	info      ReleaseDiffRelease
+	chart     string
+	resources map[string]*graph.Node
+}
+
+// diffSide collects the resources of a release rendered from its chart. The namespace is
+// required when the release name is installed in several namespaces. It returns the HTTP
+// status and message of the error, if any.
+func diffSide(g graph.GraphInterface, resolver *chartResolver, release, namespace, namespaceParam string) (releaseDiffSide, int, string) {
+	nodes := make([]*graph.Node, 0)
+	namespaces := make(map[string]bool)
+	for _, node := range g.GetNodesByHelmRelease(release) {
+		releaseNamespace := graph.ReleaseNamespace(node)
+		if namespace != "" && releaseNamespace != namespace {
+			continue
+		}
+		// Release Secrets and the resources created by controllers, such as Pods, are not
+		// rendered from the chart
+		if (node.Metadata != nil && node.Metadata.HelmRevision != nil) || hasOwner(g, node) {
+			continue
+		}
+		nodes = append(nodes, node)
+		namespaces[releaseNamespace] = true
+	}
+	if len(nodes) == 0 {
+		return releaseDiffSide{}, http.StatusNotFound, "no resources of release " + release
+	}
+	if len(namespaces) > 1 {
+		names := make([]string, 0, len(namespaces))
+		for name := range namespaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return releaseDiffSide{}, http.StatusBadRequest, "release " + release + " is installed in namespaces " +
+			strings.Join(names, ", ") + ", set " + namespaceParam
+	}
+	if namespace == "" {
+		namespace = graph.ReleaseNamespace(nodes[0])
+	}
+
+	side := releaseDiffSide{
+		info:      ReleaseDiffRelease{Release: release, Namespace: namespace, Resources: len(nodes)},
+		resources: make(map[string]*graph.Node, len(nodes)),
+	}
+	if revision := resolver.revision(release, namespace); revision != nil {
+		side.chart, side.info.ChartVersion = revision.Chart, revision.ChartVersion
+		side.info.Revision = revision.Revision
+		side.info.ValuesDigest = revision.ValuesDigest
+	} else {
+		side.chart, side.info.ChartVersion = renderedChart(resolver, nodes)
+	}
+	for _, node := range nodes {
+		side.resources[node.Kind+"/"+releaseKey(node.Name, release)] = node
+	}
+	return side, 0, ""
+}
+
+// renderedChart returns the chart most resources were rendered from, outside of subcharts, for
+// releases whose release Secrets are not tracked
+func renderedChart(resolver *chartResolver, nodes []*graph.Node) (string, string) {
+	counts := make(map[chartInfo]int)
+	for _, node := range nodes {
+		if info := resolver.resolve(node); info.name != "" && info.parent == "" {
+			counts[info]++
+		}
+	}
+	var chart chartInfo
+	for info, count := range counts {
+		if count > counts[chart] || (count == counts[chart] && info.name+info.version < chart.name+chart.version) {
+			chart = info
+		}
+	}
+	return chart.name, chart.version
+}
+
+// releaseKey returns the name of a resource with the first occurrence of its release name
+// replaced by releasePlaceholder
+func releaseKey(name, release string) string {
+	return strings.Replace(name, release, releasePlaceholder, 1)
+}
+
+// hasOwner reports whether a node is owned by another node of the graph
+func hasOwner(g graph.GraphInterface, node *graph.Node) bool {
+	for _, edge := range node.IncomingEdges {
+		if edge.Type != graph.EdgeOwnership {
+			continue
+		}
+		if _, exists := g.GetNode(edge.FromUID); exists {
+			return true
+		}
+	}
+	return false
+}
+
+func buildReleaseDiff(g graph.GraphInterface, a, b releaseDiffSide) ReleaseDiffResponse {
+	resp := ReleaseDiffResponse{
+		Chart:   a.chart,
+		A:       a.info,
+		B:       b.info,
+		OnlyInA: make([]DiffResource, 0),
+		OnlyInB: make([]DiffResource, 0),
+		Changed: make([]ResourceDiff, 0),
+	}
+	for key, nodeA := range a.resources {
+		nodeB, exists := b.resources[key]
+		if !exists {
+			resp.OnlyInA = append(resp.OnlyInA, DiffResource{Kind: nodeA.Kind, Name: nodeA.Name})
+			continue
+		}
+		changes := resourceDiffChanges(g, nodeA, nodeB, a.info.Release, b.info.Release)
+		if len(changes) == 0 {
+			resp.Unchanged++
+			continue
+		}
+		resp.Changed = append(resp.Changed, ResourceDiff{Kind: nodeA.Kind, A: nodeA.Name, B: nodeB.Name, Changes: changes})
+	}
+	for key, nodeB := range b.resources {
+		if _, exists := a.resources[key]; !exists {
+			resp.OnlyInB = append(resp.OnlyInB, DiffResource{Kind: nodeB.Kind, Name: nodeB.Name})
+		}
+	}
+
+	for _, resources := range [][]DiffResource{resp.OnlyInA, resp.OnlyInB} {
+		sort.Slice(resources, func(i, j int) bool {
+			if resources[i].Kind != resources[j].Kind {
+				return resources[i].Kind < resources[j].Kind
+			}
+			return resources[i].Name < resources[j].Name
+		})
+	}
+	sort.Slice(resp.Changed, func(i, j int) bool {
+		if resp.Changed[i].Kind != resp.Changed[j].Kind {
+			return resp.Changed[i].Kind < resp.Changed[j].Kind
+		}
+		return resp.Changed[i].A < resp.Changed[j].A
+	})
+	return resp
+}
+
+// resourceDiffChanges lists the differences between the same resource of two releases: the
+// desired replicas, the containers of the Pod template and the ConfigMaps and Secrets used
+func resourceDiffChanges(g graph.GraphInterface, a, b *graph.Node, releaseA, releaseB string) []TemplateChange {
+	changes := make([]TemplateChange, 0)
+
+	replicasA, replicasB := desiredReplicas(a), desiredReplicas(b)
+	if replicasA != replicasB {
+		changes = append(changes, TemplateChange{Field: "replicas", Change: "changed", From: replicasA, To: replicasB})
+	}
+
+	changes = append(changes, templateChanges(templateContainers(g, a), templateContainers(g, b))...)
+
+	refsA, refsB := configRefs(g, a, releaseA), configRefs(g, b, releaseB)
+	for _, key := range sortedKeys(refsA, refsB) {
+		from, to := refsA[key], refsB[key]
+		field := strings.ToLower(key[:strings.Index(key, "/")])
+		switch {
+		case from == "":
+			changes = append(changes, TemplateChange{Field: field, Change: "added", To: to})
+		case to == "":
+			changes = append(changes, TemplateChange{Field: field, Change: "removed", From: from})
+		}
+	}
+	return changes
+}
+
+// desiredReplicas returns the desired replicas of a workload, "" for other resources
+func desiredReplicas(node *graph.Node) string {
+	if node.Metadata == nil || node.Metadata.Replicas == nil {
+		return ""
+	}
+	return strconv.Itoa(int(node.Metadata.Replicas.Desired))
+}
+
+// templateContainers returns the containers of the Pod template of a workload, from its
+// current rollout revision, or the containers of a Pod
+func templateContainers(g graph.GraphInterface, node *graph.Node) []graph.ContainerInfo {
+	revisionKind, isWorkload := revisionKinds[node.Kind]
+	if !isWorkload {
+		if node.Metadata != nil {
+			return node.Metadata.Containers
+		}
+		return nil
+	}
+	controller := node.Kind + "/" + node.Name
+	var current *graph.Node
+	for _, revision := range g.GetNodesByNamespaceKind(node.Namespace, revisionKind) {
+		if revision.Metadata == nil || revision.Metadata.Controller != controller {
+			continue
+		}
+		if current == nil || revision.Metadata.Revision > current.Metadata.Revision {
+			current = revision
+		}
+	}
+	if current == nil {
+		return nil
+	}
+	return current.Metadata.Containers
+}
+
+// configRefs returns the ConfigMaps and Secrets a resource uses, by <Kind>/<name> with the
+// release name replaced by releasePlaceholder
+func configRefs(g graph.GraphInterface, node *graph.Node, release string) map[string]string {
+	refs := make(map[string]string)
+	for _, edge := range node.OutgoingEdges {
+		if edge.Type != graph.EdgeConfigMapRef && edge.Type != graph.EdgeSecretRef {
+			continue
+		}
+		if target, exists := g.GetNode(edge.ToUID); exists {
+			refs[target.Kind+"/"+releaseKey(target.Name, release)] = target.Name
+		}
+	}
+	return refs
+}
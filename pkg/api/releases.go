@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
+)
+
+// buildRelease summarizes the resources of a release, only those in namespace when set. It
+// returns false when the release has no resources there.
+func buildRelease(g graph.GraphInterface, name, namespace string) (Release, bool) {
+	row := SummaryRow{}
+	namespaces := make(map[string]bool)
+	charts := make(map[string]int)
+	var releaseChart string
+	var lastChange time.Time
+	for _, node := range g.GetNodesByHelmRelease(name) {
+		if namespace != "" && node.Namespace != namespace {
+			continue
+		}
+		row.add(node.Status)
+		if node.Namespace != "" {
+			namespaces[node.Namespace] = true
+		}
+
+		changed := node.CreationTimestamp
+		if graph.IsReleaseNode(node) {
+			// The release node carries the chart the release was installed from, while its
+			// resources may have been rendered from subcharts
+			if releaseChart == "" || node.HelmChart < releaseChart {
+				releaseChart = node.HelmChart
+			}
+			if node.Metadata != nil && node.Metadata.HelmRevision != nil {
+				changed = node.Metadata.HelmRevision.LastDeployed
+			}
+		} else if node.HelmChart != "" {
+			charts[node.HelmChart]++
+		}
+		if changed.After(lastChange) {
+			lastChange = changed
+		}
+	}
+	if row.Total == 0 {
+		return Release{}, false
+	}
+
+	release := Release{
+		Name:       name,
+		Namespaces: make([]string, 0, len(namespaces)),
+		Chart:      releaseChart,
+		Health:     releaseHealth(row),
+		Total:      row.Total,
+		Ready:      row.Ready,
+		Pending:    row.Pending,
+		Error:      row.Error,
+		Unknown:    row.Unknown,
+	}
+	for namespace := range namespaces {
+		release.Namespaces = append(release.Namespaces, namespace)
+	}
+	sort.Strings(release.Namespaces)
+	// Without its release Secret, the chart is the one most of the resources were rendered from
+	if release.Chart == "" {
+		for chart, count := range charts {
+			if count > charts[release.Chart] || (count == charts[release.Chart] && chart < release.Chart) {
+				release.Chart = chart
+			}
+		}
+	}
+	if !lastChange.IsZero() {
+		release.LastChange = &lastChange
+	}
+	return release, true
+}
+
+// releaseHealth returns the health of a release from the status counts of its resources, as
+// the health history records it
+func releaseHealth(row SummaryRow) healthhistory.State {
+	switch {
+	case row.Error > 0:
+		return healthhistory.StateError
+	case row.Ready < row.Total:
+		return healthhistory.StateDegraded
+	}
+	return healthhistory.StateReady
+}
+
+// handleReleaseHistory lists the revisions of a Helm release, newest first, decoded from the
+// release Secrets Helm keeps (up to --history-max per release)
+func (s *Server) handleReleaseHistory(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	release := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+
+	resp := ReleaseHistoryResponse{
+		Release:   release,
+		Current:   make([]ReleaseRevision, 0),
+		Revisions: make([]ReleaseRevision, 0),
+	}
+	for _, revision := range g.GetReleaseHistory(release) {
+		if namespace != "" && revision.Namespace != namespace {
+			continue
+		}
+		entry := ReleaseRevision{
+			Namespace:     revision.Namespace,
+			Revision:      revision.Revision,
+			Status:        revision.Status,
+			Chart:         revision.Chart,
+			ChartVersion:  revision.ChartVersion,
+			AppVersion:    revision.AppVersion,
+			FirstDeployed: revision.FirstDeployed,
+			LastDeployed:  revision.LastDeployed,
+			Description:   revision.Description,
+			ValuesDigest:  revision.ValuesDigest,
+			Secret:        revision.Secret,
+		}
+		resp.Revisions = append(resp.Revisions, entry)
+		if revision.Status == "deployed" {
+			resp.Current = append(resp.Current, entry)
+		}
+	}
+
+	if len(resp.Revisions) == 0 {
+		writeError(w, http.StatusNotFound, "no revisions of release "+release)
+		return
+	}
+	writeJSON(w, resp)
+}
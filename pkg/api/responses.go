@@ -2,9 +2,12 @@ package api
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -12,25 +15,39 @@ type Resource struct {
 	Name               string                 `json:"name"`
 	Namespace          string                 `json:"namespace"`
 	Kind               string                 `json:"kind"`
+	Cluster            string                 `json:"cluster,omitempty"`
 	APIVersion         string                 `json:"apiVersion"`
 	Status             string                 `json:"status"`
 	Message            string                 `json:"message"`
+	Reason             string                 `json:"reason,omitempty"`
 	Chart              string                 `json:"chart"`
+	ChartName          string                 `json:"chartName,omitempty"`
+	ChartVersion       string                 `json:"chartVersion,omitempty"`
+	ParentChart        string                 `json:"parentChart,omitempty"`
 	Release            string                 `json:"release"`
 	Age                string                 `json:"age"`
 	CreationTimestamp  string                 `json:"creationTimestamp"`
 	Image              string                 `json:"image,omitempty"`
 	NodeName           string                 `json:"nodeName,omitempty"`
+	Zone               string                 `json:"zone,omitempty"`
+	Region             string                 `json:"region,omitempty"`
 	RestartCount       int                    `json:"restartCount,omitempty"`
+	Containers         []graph.ContainerInfo  `json:"containers,omitempty"`
 	Replicas           *graph.ReplicaInfo     `json:"replicas,omitempty"`
 	OwnerReferences    []OwnerReference       `json:"ownerReferences,omitempty"`
 	VolumeName         string                 `json:"volumeName,omitempty"`
 	ClaimRef           *graph.ObjectReference `json:"claimRef,omitempty"`
+	LogExcerpt         *graph.LogExcerpt      `json:"logExcerpt,omitempty"`
 	TargetPods         []string               `json:"targetPods,omitempty"`
 	MountedPVCs        []string               `json:"mountedPVCs,omitempty"`
 	UsedConfigMaps     []string               `json:"usedConfigMaps,omitempty"`
 	UsedSecrets        []string               `json:"usedSecrets,omitempty"`
 	ServiceAccountName string                 `json:"serviceAccountName,omitempty"`
+	Computed           map[string]string      `json:"computed,omitempty"`
+	// TimeToReady is the number of seconds from creation to first Ready, when observed
+	TimeToReady *float64 `json:"timeToReady,omitempty"`
+	// DeletedAt is set on deleted resources, returned with includeDeleted=true
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
 type OwnerReference struct {
@@ -38,9 +55,126 @@ type OwnerReference struct {
 	Name string `json:"name"`
 }
 
+// HealthResponse is returned by /health
+type HealthResponse struct {
+	Status string `json:"status"`
+	Nodes  int    `json:"nodes"`
+	// Context is the kubeconfig context of the watched cluster (empty in-cluster)
+	Context string `json:"context,omitempty"`
+	// Contexts lists the other kubeconfig contexts watched and merged into the graph
+	Contexts []string `json:"contexts,omitempty"`
+}
+
+// CapabilitiesResponse is returned by /api/v1/capabilities
+type CapabilitiesResponse struct {
+	// Version is the version of the server build
+	Version string `json:"version"`
+	// APIVersion is the version of the HTTP API
+	APIVersion string `json:"apiVersion"`
+	// Features tells which optional features are enabled
+	Features map[string]bool `json:"features"`
+	// Clusters are the other clusters merged into the graph, with multiCluster
+	Clusters []string `json:"clusters,omitempty"`
+	// GRPCPort serves the gRPC API and its WatchGraph streams, with streaming
+	GRPCPort int `json:"grpcPort,omitempty"`
+	// Compression lists the encodings responses can be compressed with, by preference
+	Compression []string             `json:"compression"`
+	Endpoints   []CapabilityEndpoint `json:"endpoints"`
+	Limits      CapabilityLimits     `json:"limits"`
+}
+
+// CapabilityEndpoint is an endpoint the server serves
+type CapabilityEndpoint struct {
+	Method          string   `json:"method"`
+	Path            string   `json:"path"`
+	QueryParameters []string `json:"queryParameters"`
+	// Formats are the response formats: json, protobuf or csv
+	Formats []string `json:"formats"`
+}
+
+// CapabilityLimits are the bounds of requests
+type CapabilityLimits struct {
+	// BatchReferences is the most references of a batch query
+	BatchReferences int `json:"batchReferences"`
+	// BatchBodyBytes is the largest body of a batch query
+	BatchBodyBytes int `json:"batchBodyBytes"`
+	// SearchResults is the highest limit of a search
+	SearchResults int `json:"searchResults"`
+	// SummarizedNodes is the default node limit of a summarized graph
+	SummarizedNodes int `json:"summarizedNodes"`
+	// RequestTimeoutSeconds is how long a response may take to write (0 = unbounded)
+	RequestTimeoutSeconds int `json:"requestTimeoutSeconds"`
+}
+
+// ProbeResponse is returned by /healthz and /readyz
+type ProbeResponse struct {
+	// Status is ok on /healthz, ready or not ready on /readyz
+	Status string       `json:"status"`
+	Checks []ProbeCheck `json:"checks,omitempty"`
+}
+
+// ProbeCheck is the result of a readiness check
+type ProbeCheck struct {
+	Name string `json:"name"`
+	// Status is ok or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SearchResponse is returned by /api/v1/search
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+	// Truncated is set when more resources matched than the limit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SearchResult is a resource matching a search query
+type SearchResult struct {
+	UID       string  `json:"uid"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace,omitempty"`
+	Kind      string  `json:"kind"`
+	Status    string  `json:"status"`
+	Release   string  `json:"release,omitempty"`
+	Score     float64 `json:"score"`
+	// Matches lists the fields matched by the query: name, image, label or namespace
+	Matches []string `json:"matches"`
+}
+
+// AnalysisSummary describes an analysis listed by /api/v1/analysis
+type AnalysisSummary struct {
+	Name string `json:"name"`
+	// Computed is false until the analysis first ran
+	Computed   bool       `json:"computed"`
+	ComputedAt *time.Time `json:"computedAt,omitempty"`
+	Count      int        `json:"count"`
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
 type GraphResponse struct {
 	Nodes []NodeResponse `json:"nodes"`
 	Edges []EdgeResponse `json:"edges"`
+	// Summarized is set when groups of nodes were collapsed to stay within maxNodes
+	Summarized bool `json:"summarized,omitempty"`
+	// TotalNodes is the number of nodes before collapsing
+	TotalNodes int `json:"totalNodes,omitempty"`
+	// Token is passed back as ?since= to only read the changes after this response
+	Token string `json:"token,omitempty"`
+}
+
+// GraphChangesResponse are the changes of a graph since a token
+type GraphChangesResponse struct {
+	Token string         `json:"token"`
+	Nodes []NodeResponse `json:"nodes"`
+	// Edges are all the edges of the changed nodes, replacing the ones clients hold
+	Edges []EdgeResponse `json:"edges"`
+	// Deleted are the UIDs of the nodes removed or no longer selected
+	Deleted []string `json:"deleted"`
 }
 
 type NodeResponse struct {
@@ -48,21 +182,51 @@ type NodeResponse struct {
 	Name      string                  `json:"name"`
 	Namespace string                  `json:"namespace"`
 	Kind      string                  `json:"kind"`
+	Cluster   string                  `json:"cluster,omitempty"`
 	Status    string                  `json:"status"`
 	Message   string                  `json:"message"`
+	Reason    string                  `json:"reason,omitempty"`
 	Chart     string                  `json:"chart,omitempty"`
 	Release   string                  `json:"release,omitempty"`
 	Metadata  *graph.ResourceMetadata `json:"metadata,omitempty"`
+	// ChartName and ChartVersion split Chart; ParentChart is the chart of the release when the
+	// resource was rendered from one of its subcharts
+	ChartName    string `json:"chartName,omitempty"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	ParentChart  string `json:"parentChart,omitempty"`
+	// TimeToReady is the number of seconds from creation to first Ready, when observed
+	TimeToReady *float64 `json:"timeToReady,omitempty"`
+	// DeletedAt is set on deleted resources, returned with includeDeleted=true
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Aggregate is set on nodes standing for a collapsed group of resources
+	Aggregate *AggregateInfo `json:"aggregate,omitempty"`
+}
+
+// AggregateInfo describes the resources collapsed into an aggregated node of a summarized graph
+type AggregateInfo struct {
+	Count   int `json:"count"`
+	Ready   int `json:"ready"`
+	Pending int `json:"pending"`
+	Error   int `json:"error"`
+	Unknown int `json:"unknown"`
+	// Owner is the UID of the common owner of the resources, when they were grouped by owner
+	Owner string `json:"owner,omitempty"`
+	// Members are the UIDs of the collapsed resources
+	Members []string `json:"members"`
 }
 
 type EdgeResponse struct {
 	Type string `json:"type"`
 	From string `json:"from"`
 	To   string `json:"to"`
+	// Metadata holds details of the relationship, e.g. target ports or mount paths
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	LastConfirmed time.Time         `json:"lastConfirmed"`
+	Stale         bool              `json:"stale,omitempty"`
 }
 
 // Resource represents a resource in the API response (compatible with datasource)
-func (s *Server) nodesToResources(nodes []*graph.Node) []Resource {
+func (s *Server) nodesToResources(g graph.GraphInterface, nodes []*graph.Node) []Resource {
 	resources := make([]Resource, 0, len(nodes))
 
 	// Build a cache of all UIDs we might need to lookup
@@ -74,14 +238,14 @@ func (s *Server) nodesToResources(nodes []*graph.Node) []Resource {
 		// Cache UIDs from edges
 		for _, edge := range node.IncomingEdges {
 			if _, cached := uidCache[edge.FromUID]; !cached {
-				if n, exists := s.graph.GetNode(edge.FromUID); exists {
+				if n, exists := g.GetNode(edge.FromUID); exists {
 					uidCache[edge.FromUID] = n
 				}
 			}
 		}
 		for _, edge := range node.OutgoingEdges {
 			if _, cached := uidCache[edge.ToUID]; !cached {
-				if n, exists := s.graph.GetNode(edge.ToUID); exists {
+				if n, exists := g.GetNode(edge.ToUID); exists {
 					uidCache[edge.ToUID] = n
 				}
 			}
@@ -89,28 +253,42 @@ func (s *Server) nodesToResources(nodes []*graph.Node) []Resource {
 	}
 
 	// Now build resources using the cache
+	charts := newChartResolver(g)
 	for _, node := range nodes {
+		chart := charts.resolve(node)
 		resource := Resource{
 			Name:              node.Name,
 			Namespace:         node.Namespace,
 			Kind:              node.Kind,
+			Cluster:           node.Cluster,
 			APIVersion:        node.APIVersion,
 			Status:            string(node.Status),
 			Message:           node.StatusMessage,
+			Reason:            node.StatusReason,
 			Chart:             node.HelmChart,
+			ChartName:         chart.name,
+			ChartVersion:      chart.version,
+			ParentChart:       chart.parent,
 			Release:           node.HelmRelease,
 			Age:               formatAge(node.CreationTimestamp),
 			CreationTimestamp: node.CreationTimestamp.Format(time.RFC3339),
+			TimeToReady:       timeToReadySeconds(node),
+			DeletedAt:         node.DeletedAt,
 		}
 
 		// Add metadata
 		if node.Metadata != nil {
 			resource.Image = node.Metadata.Image
 			resource.NodeName = node.Metadata.NodeName
+			resource.Zone = node.Metadata.Zone
+			resource.Region = node.Metadata.Region
 			resource.RestartCount = node.Metadata.RestartCount
+			resource.Containers = node.Metadata.Containers
 			resource.Replicas = node.Metadata.Replicas
 			resource.VolumeName = node.Metadata.VolumeName
 			resource.ClaimRef = node.Metadata.ClaimRef
+			resource.LogExcerpt = node.Metadata.LogExcerpt
+			resource.Computed = node.Metadata.Computed
 		}
 
 		// Extract owner references using cache
@@ -124,9 +302,30 @@ func (s *Server) nodesToResources(nodes []*graph.Node) []Resource {
 				}
 			}
 		}
+		// Owners outside the graph, e.g. filtered out by the label selector, are reported from
+		// the owner references of the object
+		if node.Metadata != nil {
+			for _, owner := range node.Metadata.Owners {
+				ref := OwnerReference{Kind: owner.Kind, Name: owner.Name}
+				if !slices.Contains(resource.OwnerReferences, ref) {
+					resource.OwnerReferences = append(resource.OwnerReferences, ref)
+				}
+			}
+		}
+		sort.Slice(resource.OwnerReferences, func(i, j int) bool {
+			a, b := resource.OwnerReferences[i], resource.OwnerReferences[j]
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
 
 		// Extract related resources using cache
-		resource.TargetPods = s.getRelatedNodeNames(node, graph.EdgeServiceSelector, uidCache)
+		if node.Kind == "Service" {
+			resource.TargetPods = endpointPods(g, node)
+		} else {
+			resource.TargetPods = s.getRelatedNodeNames(node, graph.EdgeServiceSelector, uidCache)
+		}
 		resource.MountedPVCs = s.getRelatedNodeNames(node, graph.EdgePodVolume, uidCache)
 		resource.UsedConfigMaps = s.getRelatedNodeNames(node, graph.EdgeConfigMapRef, uidCache)
 		resource.UsedSecrets = s.getRelatedNodeNames(node, graph.EdgeSecretRef, uidCache)
@@ -156,11 +355,39 @@ func (s *Server) getRelatedNodeNames(node *graph.Node, edgeType graph.EdgeType,
 			}
 		}
 	}
+	sort.Strings(names)
+	return names
+}
+
+// endpointPods returns the names of the Pods a Service routes to through its EndpointSlices,
+// once each although dual-stack Services list their Pods in a slice per address family
+func endpointPods(g graph.GraphInterface, service *graph.Node) []string {
+	seen := make(map[types.UID]bool)
+	names := make([]string, 0)
+	for sliceUID, edge := range service.OutgoingEdges {
+		if edge.Type != graph.EdgeHasEndpoints {
+			continue
+		}
+		slice, exists := g.GetNode(sliceUID)
+		if !exists {
+			continue
+		}
+		for podUID, edge := range slice.OutgoingEdges {
+			if edge.Type != graph.EdgeEndpointOf || seen[podUID] {
+				continue
+			}
+			if pod, exists := g.GetNode(podUID); exists {
+				seen[podUID] = true
+				names = append(names, pod.Name)
+			}
+		}
+	}
+	sort.Strings(names)
 	return names
 }
 
 // GraphResponse represents the graph API response
-func (s *Server) buildGraphResponse(nodes []*graph.Node) GraphResponse {
+func (s *Server) buildGraphResponse(g graph.GraphInterface, nodes []*graph.Node) GraphResponse {
 	nodeMap := make(map[string]bool)
 	for _, node := range nodes {
 		nodeMap[string(node.UID)] = true
@@ -171,27 +398,14 @@ func (s *Server) buildGraphResponse(nodes []*graph.Node) GraphResponse {
 		Edges: make([]EdgeResponse, 0),
 	}
 
+	charts := newChartResolver(g)
 	for _, node := range nodes {
-		resp.Nodes = append(resp.Nodes, NodeResponse{
-			UID:       string(node.UID),
-			Name:      node.Name,
-			Namespace: node.Namespace,
-			Kind:      node.Kind,
-			Status:    string(node.Status),
-			Message:   node.StatusMessage,
-			Chart:     node.HelmChart,
-			Release:   node.HelmRelease,
-			Metadata:  node.Metadata,
-		})
+		resp.Nodes = append(resp.Nodes, nodeResponse(node, charts))
 
 		// Add edges where both nodes are in the result set
-		for _, edge := range node.OutgoingEdges {
-			if nodeMap[string(edge.ToUID)] {
-				resp.Edges = append(resp.Edges, EdgeResponse{
-					Type: string(edge.Type),
-					From: string(edge.FromUID),
-					To:   string(edge.ToUID),
-				})
+		for _, edge := range nodeEdges(node) {
+			if nodeMap[string(edge.FromUID)] && nodeMap[string(edge.ToUID)] {
+				resp.Edges = append(resp.Edges, edgeResponse(edge))
 			}
 		}
 	}
@@ -199,6 +413,79 @@ func (s *Server) buildGraphResponse(nodes []*graph.Node) GraphResponse {
 	return resp
 }
 
+func edgeResponse(edge *graph.Edge) EdgeResponse {
+	return EdgeResponse{
+		Type:          string(edge.Type),
+		From:          string(edge.FromUID),
+		To:            string(edge.ToUID),
+		Metadata:      edge.Metadata,
+		LastConfirmed: edge.LastConfirmed,
+		Stale:         edge.Stale,
+	}
+}
+
+func nodeResponse(node *graph.Node, charts *chartResolver) NodeResponse {
+	chart := charts.resolve(node)
+	return NodeResponse{
+		UID:          string(node.UID),
+		Name:         node.Name,
+		Namespace:    node.Namespace,
+		Kind:         node.Kind,
+		Cluster:      node.Cluster,
+		Status:       string(node.Status),
+		Message:      node.StatusMessage,
+		Reason:       node.StatusReason,
+		Chart:        node.HelmChart,
+		ChartName:    chart.name,
+		ChartVersion: chart.version,
+		ParentChart:  chart.parent,
+		Release:      node.HelmRelease,
+		Metadata:     node.Metadata,
+		TimeToReady:  timeToReadySeconds(node),
+		DeletedAt:    node.DeletedAt,
+	}
+}
+
+// timeToReadySeconds returns the time to ready of a node in seconds, nil when unknown
+func timeToReadySeconds(node *graph.Node) *float64 {
+	timeToReady, ok := node.TimeToReady()
+	if !ok {
+		return nil
+	}
+	seconds := timeToReady.Seconds()
+	return &seconds
+}
+
+// sortedEdges returns edges ordered by target UID and type, for stable responses
+func sortedEdges(edges map[types.UID]*graph.Edge) []*graph.Edge {
+	sorted := make([]*graph.Edge, 0, len(edges))
+	for _, edge := range edges {
+		sorted = append(sorted, edge)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ToUID != sorted[j].ToUID {
+			return sorted[i].ToUID < sorted[j].ToUID
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+	return sorted
+}
+
+// sortedIncomingEdges returns edges ordered by source UID and type, for stable responses
+func sortedIncomingEdges(edges map[types.UID]*graph.Edge) []*graph.Edge {
+	sorted := make([]*graph.Edge, 0, len(edges))
+	for _, edge := range edges {
+		sorted = append(sorted, edge)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].FromUID != sorted[j].FromUID {
+			return sorted[i].FromUID < sorted[j].FromUID
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+	return sorted
+}
+
 func formatAge(t time.Time) string {
 	duration := time.Since(t)
 
@@ -212,3 +499,568 @@ func formatAge(t time.Time) string {
 		return fmt.Sprintf("%dd", int(duration.Hours()/24))
 	}
 }
+
+// SummaryResponse is the aggregated health summary of the tracked resources
+type SummaryResponse struct {
+	Total   int          `json:"total"`
+	Ready   int          `json:"ready"`
+	Pending int          `json:"pending"`
+	Error   int          `json:"error"`
+	Unknown int          `json:"unknown"`
+	Rows    []SummaryRow `json:"rows"`
+}
+
+// SummaryRow holds the status counts of one group value (e.g. kind=Pod), flattened for table panels
+type SummaryRow struct {
+	Group   string `json:"group"`
+	Key     string `json:"key"`
+	Total   int    `json:"total"`
+	Ready   int    `json:"ready"`
+	Pending int    `json:"pending"`
+	Error   int    `json:"error"`
+	Unknown int    `json:"unknown"`
+}
+
+func (r *SummaryRow) add(status graph.ResourceStatus) {
+	r.Total++
+	switch status {
+	case graph.StatusReady:
+		r.Ready++
+	case graph.StatusPending:
+		r.Pending++
+	case graph.StatusError:
+		r.Error++
+	default:
+		r.Unknown++
+	}
+}
+
+// Application is an application formed by one of the groupers, with the status counts of its resources
+type Application struct {
+	Name       string   `json:"name"`
+	Source     string   `json:"source"`
+	Namespaces []string `json:"namespaces"`
+	Total      int      `json:"total"`
+	Ready      int      `json:"ready"`
+	Pending    int      `json:"pending"`
+	Error      int      `json:"error"`
+	Unknown    int      `json:"unknown"`
+}
+
+// Release is a Helm release with the status counts and health of its resources
+type Release struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces"`
+	// Chart is the chart the release was installed from, as <name>-<version>
+	Chart string `json:"chart,omitempty"`
+	// Health is error when a resource is in Error, degraded when one is Pending or Unknown,
+	// ready otherwise
+	Health  healthhistory.State `json:"health"`
+	Total   int                 `json:"total"`
+	Ready   int                 `json:"ready"`
+	Pending int                 `json:"pending"`
+	Error   int                 `json:"error"`
+	Unknown int                 `json:"unknown"`
+	// LastChange is when the release was last deployed, or a resource of it last created
+	LastChange *time.Time `json:"lastChange,omitempty"`
+}
+
+// ChartReleasesResponse lists the releases running a chart
+type ChartReleasesResponse struct {
+	Chart string `json:"chart"`
+	// Versions are the distinct chart versions in use
+	Versions []string       `json:"versions"`
+	Releases []ChartRelease `json:"releases"`
+}
+
+// ChartRelease is a release running a version of the chart
+type ChartRelease struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	Version   string `json:"version"`
+	Resources int    `json:"resources"`
+}
+
+// ReleaseChartsResponse is the chart hierarchy of a Helm release
+type ReleaseChartsResponse struct {
+	Release string         `json:"release"`
+	Charts  []ReleaseChart `json:"charts"`
+}
+
+// ReleaseChart is the chart of a release or one of its subcharts
+type ReleaseChart struct {
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	// Version is empty for declared subcharts without resources
+	Version string `json:"version,omitempty"`
+	// ParentChart is the chart of the release for subcharts, empty for the release's chart
+	ParentChart string `json:"parentChart,omitempty"`
+	// Declared tells whether the release's chart lists the subchart as a dependency; subcharts
+	// of subcharts are not listed
+	Declared  bool `json:"declared,omitempty"`
+	Resources int  `json:"resources"`
+}
+
+// ReleaseHistoryResponse lists the revisions of a Helm release
+type ReleaseHistoryResponse struct {
+	Release string `json:"release"`
+	// Current is the deployed revision, for each namespace the release is installed in
+	Current   []ReleaseRevision `json:"current"`
+	Revisions []ReleaseRevision `json:"revisions"`
+}
+
+// ReleaseTimelineResponse lists the recorded events of a release, oldest first
+type ReleaseTimelineResponse struct {
+	Release string          `json:"release"`
+	Events  []TimelineEvent `json:"events"`
+}
+
+// ReleaseHealthHistoryResponse lists the hourly health of a release, oldest first
+type ReleaseHealthHistoryResponse struct {
+	Release string `json:"release"`
+	Window  string `json:"window"`
+	// Overall is the health over the whole window
+	Overall HealthShare   `json:"overall"`
+	Points  []HealthPoint `json:"points"`
+}
+
+// HealthPoint is the health of a release over the hour starting at Time
+type HealthPoint struct {
+	Time time.Time `json:"time"`
+	HealthShare
+}
+
+// HealthShare is the percentage of samples in which a release was in each state. A release is
+// ready when all its resources are Ready, in error when any is in Error, and degraded otherwise.
+type HealthShare struct {
+	Samples  int     `json:"samples"`
+	Ready    float64 `json:"ready"`
+	Degraded float64 `json:"degraded"`
+	Error    float64 `json:"error"`
+}
+
+// TimelineEvent is an event of a release, e.g. a rollback
+type TimelineEvent struct {
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	Namespace string            `json:"namespace"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// ReleaseTopologyResponse lists the resources of a release in dependency order
+type ReleaseTopologyResponse struct {
+	Release string `json:"release"`
+	// Levels is the number of levels of the hierarchy
+	Levels int            `json:"levels"`
+	Nodes  []TopologyNode `json:"nodes"`
+	Edges  []EdgeResponse `json:"edges"`
+}
+
+// TopologyNode is a resource and its place in the release hierarchy
+type TopologyNode struct {
+	NodeResponse
+	// Level is the length of the longest path from a root; roots are at level 0
+	Level int `json:"level"`
+	// Parents are the UIDs of the resources with an edge to this one
+	Parents []string `json:"parents"`
+	// Cyclic is set when the resource is part of a cycle, broken to order it
+	Cyclic bool `json:"cyclic,omitempty"`
+}
+
+// ZonesResponse groups Pods and PersistentVolumes by the zone of their Node
+type ZonesResponse struct {
+	Zones []TopologyZone `json:"zones"`
+	// Unzoned are the Pods and PersistentVolumes whose zone is unknown: Pods not scheduled yet
+	// and resources on Nodes without topology labels
+	Unzoned []ZoneResource `json:"unzoned"`
+	// Workloads are the Deployments and StatefulSets with scheduled Pods, and their zones
+	Workloads []WorkloadSpread `json:"workloads"`
+}
+
+// TopologyZone is a zone of the cluster, with its Nodes and the resources in it
+type TopologyZone struct {
+	Zone      string         `json:"zone"`
+	Region    string         `json:"region,omitempty"`
+	Nodes     []string       `json:"nodes"`
+	Resources []ZoneResource `json:"resources"`
+}
+
+// ZoneResource is a Pod or PersistentVolume placed in a zone
+type ZoneResource struct {
+	UID       string `json:"uid"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	// NodeName is the Node a Pod runs on, or a local volume is pinned to
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// WorkloadSpread lists the zones the scheduled Pods of a workload run in
+type WorkloadSpread struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Pods      int      `json:"pods"`
+	Zones     []string `json:"zones"`
+	// SingleZone is set when several Pods all run in one zone of a multi-zone cluster
+	SingleZone bool `json:"singleZone,omitempty"`
+}
+
+// ReleaseRevision is a revision of a Helm release, as recorded in its release Secret
+type ReleaseRevision struct {
+	Namespace     string    `json:"namespace"`
+	Revision      int       `json:"revision"`
+	Status        string    `json:"status"`
+	Chart         string    `json:"chart"`
+	ChartVersion  string    `json:"chartVersion"`
+	AppVersion    string    `json:"appVersion,omitempty"`
+	FirstDeployed time.Time `json:"firstDeployed"`
+	LastDeployed  time.Time `json:"lastDeployed"`
+	Description   string    `json:"description,omitempty"`
+	// ValuesDigest is a SHA-256 of the user-supplied values; it changes when the values do
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+	Secret       string `json:"secret"`
+}
+
+// RolloutChangesResponse describes what changed in the Pod template of a workload between
+// two rollout revisions
+type RolloutChangesResponse struct {
+	Kind      string          `json:"kind"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Current   RolloutRevision `json:"current"`
+	// Previous is unset when only one revision is known
+	Previous *RolloutRevision `json:"previous,omitempty"`
+	Changes  []TemplateChange `json:"changes"`
+}
+
+// RolloutRevision is a ReplicaSet or ControllerRevision of a workload
+type RolloutRevision struct {
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	Revision     int64     `json:"revision"`
+	TemplateHash string    `json:"templateHash,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TemplateChange is a difference between the Pod templates of two revisions
+type TemplateChange struct {
+	// Container is unset for changes of the resource itself (release diffs)
+	Container string `json:"container,omitempty"`
+	// Field is container, image, env, requests.<resource> or limits.<resource>; release diffs
+	// also report replicas, configmap and secret
+	Field string `json:"field"`
+	// Change is added, removed or changed
+	Change string `json:"change"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// ReleaseDiffResponse compares two releases of the same chart. Resources are matched by kind
+// and name, with the release name in their names ignored.
+type ReleaseDiffResponse struct {
+	Chart string             `json:"chart"`
+	A     ReleaseDiffRelease `json:"a"`
+	B     ReleaseDiffRelease `json:"b"`
+	// OnlyInA and OnlyInB list the resources of one release without a match in the other
+	OnlyInA []DiffResource `json:"onlyInA"`
+	OnlyInB []DiffResource `json:"onlyInB"`
+	// Changed lists the resources of both releases that differ, from A to B
+	Changed []ResourceDiff `json:"changed"`
+	// Unchanged counts the resources of both releases without differences
+	Unchanged int `json:"unchanged"`
+}
+
+// ReleaseDiffRelease is a release compared by a release diff
+type ReleaseDiffRelease struct {
+	Release      string `json:"release"`
+	Namespace    string `json:"namespace"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// Revision and ValuesDigest are those of the deployed revision, when its release Secret
+	// is tracked; different digests mean different user-supplied values
+	Revision     int    `json:"revision,omitempty"`
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+	// Resources counts the resources rendered from the chart
+	Resources int `json:"resources"`
+}
+
+// DiffResource is a resource of one of the compared releases
+type DiffResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ReleaseLeftoversResponse is returned by /api/v1/releases/{name}/leftovers
+type ReleaseLeftoversResponse struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace,omitempty"`
+	// Status is installed, uninstalling (resources left within the grace period),
+	// orphaned-uninstall (resources left past it) or uninstalled (no resources left)
+	Status          string     `json:"status"`
+	UninstalledAt   *time.Time `json:"uninstalledAt,omitempty"`
+	GracePeriodEnds *time.Time `json:"gracePeriodEnds,omitempty"`
+	// Leftovers are the resources of the release still present, except those owned by
+	// other resources
+	Leftovers []LeftoverResource `json:"leftovers"`
+}
+
+// LeftoverResource is a resource of an uninstalled release
+type LeftoverResource struct {
+	UID        string `json:"uid"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// AnomaliesResponse is returned by /api/v1/anomalies
+type AnomaliesResponse struct {
+	// Window is the window within which restarts and status changes are counted
+	Window    string       `json:"window"`
+	Anomalies []PodAnomaly `json:"anomalies"`
+}
+
+// PodAnomaly is a Pod flagged for restarting or flapping
+type PodAnomaly struct {
+	UID          string `json:"uid"`
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	Release      string `json:"release,omitempty"`
+	Status       string `json:"status"`
+	StatusReason string `json:"statusReason,omitempty"`
+	// Workload is the top of the Pod's ownership chain, e.g. Deployment/web
+	Workload string `json:"workload,omitempty"`
+	// Reasons are restarts and/or flapping
+	Reasons []string `json:"reasons"`
+	// Restarts and Flaps are the container restarts and status changes within the window
+	Restarts int `json:"restarts"`
+	Flaps    int `json:"flaps"`
+	// RestartThreshold and FlapThreshold are those of the Pod's namespace; negative never flags
+	RestartThreshold int        `json:"restartThreshold"`
+	FlapThreshold    int        `json:"flapThreshold"`
+	Since            time.Time  `json:"since"`
+	LastRestart      *time.Time `json:"lastRestart,omitempty"`
+}
+
+// ResourceDiff lists the differences of a resource between the compared releases
+type ResourceDiff struct {
+	Kind    string           `json:"kind"`
+	A       string           `json:"a"`
+	B       string           `json:"b"`
+	Changes []TemplateChange `json:"changes"`
+}
+
+// ImpactResponse lists the workloads and releases affected by a change to a ConfigMap or Secret
+type ImpactResponse struct {
+	Kind      string             `json:"kind"`
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	UID       string             `json:"uid"`
+	Workloads []ImpactedWorkload `json:"workloads"`
+	Releases  []string           `json:"releases"`
+}
+
+// ImpactedWorkload is a workload using the ConfigMap or Secret, itself or through the Pods it
+// owns. Resources without an owner are their own workload.
+type ImpactedWorkload struct {
+	UID       string `json:"uid"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Release   string `json:"release,omitempty"`
+	Status    string `json:"status"`
+	// References are the resources of the workload referencing the object
+	References []ImpactReference `json:"references"`
+}
+
+// ImpactReference is a resource referencing the ConfigMap or Secret
+type ImpactReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Refs lists how the object is used: volume, envFrom and/or env
+	Refs []string `json:"refs,omitempty"`
+	// Keys lists the keys used by env vars or volume items
+	Keys []string `json:"keys,omitempty"`
+}
+
+// ReleaseDependenciesResponse is the release-level dependency graph
+type ReleaseDependenciesResponse struct {
+	Releases     []string            `json:"releases"`
+	Dependencies []ReleaseDependency `json:"dependencies"`
+	// DeployOrder lists releases so that every release comes after the releases it depends on.
+	// Releases that form a cycle are listed next to each other.
+	DeployOrder []string   `json:"deployOrder"`
+	Cycles      [][]string `json:"cycles,omitempty"`
+}
+
+// ReleaseDependency means resources of release From reference resources of release To
+type ReleaseDependency struct {
+	From       string                `json:"from"`
+	To         string                `json:"to"`
+	References []ReleaseReferenceRef `json:"references"`
+}
+
+// ReleaseReferenceRef is a single cross-release edge backing a dependency
+type ReleaseReferenceRef struct {
+	Type          string `json:"type"`
+	FromKind      string `json:"fromKind"`
+	FromNamespace string `json:"fromNamespace,omitempty"`
+	FromName      string `json:"fromName"`
+	ToKind        string `json:"toKind"`
+	ToNamespace   string `json:"toNamespace,omitempty"`
+	ToName        string `json:"toName"`
+}
+
+// ActionRequest is the body of POST /api/v1/actions/{restart,scale}.
+// The target is identified either by uid or by kind, namespace and name.
+type ActionRequest struct {
+	UID       string `json:"uid,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Replicas  *int32 `json:"replicas,omitempty"`
+}
+
+// ActionResponse describes a performed action
+type ActionResponse struct {
+	Action    string `json:"action"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	Replicas  *int32 `json:"replicas,omitempty"`
+	User      string `json:"user"`
+}
+
+// ReleaseTimeToReadyResponse summarizes how long the resources of a release took to become
+// Ready. Durations are in seconds and computed over the resources with a known time to ready.
+type ReleaseTimeToReadyResponse struct {
+	Release string `json:"release"`
+	// Measured is the number of resources with a known time to ready
+	Measured int `json:"measured"`
+	// NotReady is the number of resources never seen Ready
+	NotReady  int                   `json:"notReady"`
+	Mean      float64               `json:"mean"`
+	P50       float64               `json:"p50"`
+	P90       float64               `json:"p90"`
+	Max       float64               `json:"max"`
+	Resources []ResourceTimeToReady `json:"resources"`
+}
+
+// ResourceTimeToReady is the time to ready of a resource of a release
+type ResourceTimeToReady struct {
+	Kind        string    `json:"kind"`
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	TimeToReady float64   `json:"timeToReady"`
+	FirstReady  time.Time `json:"firstReady"`
+}
+
+// ResourceEventsResponse lists the recent Warning Events about a resource, newest first
+type ResourceEventsResponse struct {
+	UID       string               `json:"uid"`
+	Kind      string               `json:"kind"`
+	Namespace string               `json:"namespace,omitempty"`
+	Name      string               `json:"name"`
+	Events    []graph.WarningEvent `json:"events"`
+}
+
+// BatchRef references a resource of a batch query, by UID or by kind, namespace and name
+type BatchRef struct {
+	UID       string `json:"uid,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// BatchResponse lists the results of a batch query in the order of the references
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the resource a reference resolved to, with its incoming and outgoing edges,
+// or the reason it could not be resolved
+type BatchResult struct {
+	Ref      BatchRef       `json:"ref"`
+	UID      string         `json:"uid,omitempty"`
+	Resource *Resource      `json:"resource,omitempty"`
+	Edges    []EdgeResponse `json:"edges,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// ImagesResponse is the inventory of the container images in use
+type ImagesResponse struct {
+	Images []ImageUsage `json:"images"`
+}
+
+// ImageUsage is a container image and where it runs
+type ImageUsage struct {
+	// Image is the image reference as written in the Pod spec
+	Image string `json:"image"`
+	// Pods is the number of Pods with a container or init container of the image
+	Pods       int             `json:"pods"`
+	Namespaces []string        `json:"namespaces"`
+	Releases   []string        `json:"releases"`
+	Workloads  []ImageWorkload `json:"workloads"`
+}
+
+// ImageWorkload is a workload running an image through its Pods. Pods without an owner are
+// their own workload.
+type ImageWorkload struct {
+	UID       string `json:"uid"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Release   string `json:"release,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+	// Pods is the number of Pods of the workload running the image
+	Pods int `json:"pods"`
+}
+
+// StatsResponse is returned by /api/v1/stats
+type StatsResponse struct {
+	// Generation is incremented on every change of the graph
+	Generation  uint64         `json:"generation"`
+	Nodes       int            `json:"nodes"`
+	Edges       int            `json:"edges"`
+	NodesByKind map[string]int `json:"nodesByKind"`
+	EdgesByType map[string]int `json:"edgesByType"`
+	// IndexSizes is the number of entries of each index of the graph
+	IndexSizes   map[string]int    `json:"indexSizes"`
+	PendingEdges PendingEdgeCounts `json:"pendingEdges"`
+	Memory       MemoryStats       `json:"memory"`
+	// Storage describes the Redis data when persistence is enabled
+	Storage *StorageStats `json:"storage,omitempty"`
+}
+
+// PendingEdgeCounts counts the edges waiting for one of their nodes to be created
+type PendingEdgeCounts struct {
+	// Target counts the edges waiting for their target, Source for their source and Owner
+	// the ownership edges waiting for their owner
+	Target int `json:"target"`
+	Source int `json:"source"`
+	Owner  int `json:"owner"`
+}
+
+// MemoryStats describes the memory of the process
+type MemoryStats struct {
+	// GraphEstimateBytes is a rough estimate of the memory held by the nodes and edges
+	GraphEstimateBytes int64  `json:"graphEstimateBytes"`
+	HeapAllocBytes     uint64 `json:"heapAllocBytes"`
+	HeapInuseBytes     uint64 `json:"heapInuseBytes"`
+	SysBytes           uint64 `json:"sysBytes"`
+}
+
+// StorageStats describes the data of the persistence store
+type StorageStats struct {
+	// Keys counts the keys by family, e.g. node, edge or index
+	Keys            map[string]int64 `json:"keys,omitempty"`
+	TotalKeys       int64            `json:"totalKeys"`
+	UsedMemoryBytes int64            `json:"usedMemoryBytes,omitempty"`
+	// LastSnapshot describes the last full snapshot, when one was written
+	LastSnapshot *graph.SnapshotInfo `json:"lastSnapshot,omitempty"`
+	// Error is set when the store could not be read
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// revisionKinds maps workload kinds to the kind of their rollout revisions
+var revisionKinds = map[string]string{
+	"Deployment":  "ReplicaSet",
+	"StatefulSet": "ControllerRevision",
+	"DaemonSet":   "ControllerRevision",
+}
+
+// handleRolloutChanges compares the Pod template of a workload's current revision with the
+// previous one, or of ?revision=N with the revision before it
+func (s *Server) handleRolloutChanges(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	namespace, name := r.PathValue("namespace"), r.PathValue("name")
+
+	var kind string
+	for workloadKind := range revisionKinds {
+		if strings.EqualFold(workloadKind, r.PathValue("kind")) {
+			kind = workloadKind
+		}
+	}
+	if kind == "" {
+		writeError(w, http.StatusBadRequest, "kind must be Deployment, StatefulSet or DaemonSet")
+		return
+	}
+
+	var wanted int64
+	if value := r.URL.Query().Get("revision"); value != "" {
+		revision, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || revision <= 0 {
+			writeError(w, http.StatusBadRequest, "revision must be a positive integer")
+			return
+		}
+		wanted = revision
+	}
+
+	if s.findNode(g, namespace, kind, name) == nil {
+		writeError(w, http.StatusNotFound, kind+" "+namespace+"/"+name+" not found")
+		return
+	}
+
+	// Revisions of the workload, newest first
+	controller := kind + "/" + name
+	var revisions []*graph.Node
+	for _, node := range g.GetNodesByNamespaceKind(namespace, revisionKinds[kind]) {
+		if node.Metadata != nil && node.Metadata.Controller == controller && node.Metadata.Revision > 0 {
+			revisions = append(revisions, node)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Metadata.Revision > revisions[j].Metadata.Revision })
+
+	if len(revisions) == 0 {
+		writeError(w, http.StatusNotFound, "no rollout revisions of "+kind+" "+namespace+"/"+name+" in the graph")
+		return
+	}
+
+	current := 0
+	if wanted > 0 {
+		current = -1
+		for i, revision := range revisions {
+			if revision.Metadata.Revision == wanted {
+				current = i
+			}
+		}
+	}
+	if current < 0 {
+		writeError(w, http.StatusNotFound, "revision "+strconv.FormatInt(wanted, 10)+" not found in the graph")
+		return
+	}
+
+	resp := RolloutChangesResponse{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Current:   rolloutRevision(revisions[current]),
+		Changes:   make([]TemplateChange, 0),
+	}
+	if current+1 < len(revisions) {
+		previous := rolloutRevision(revisions[current+1])
+		resp.Previous = &previous
+		resp.Changes = templateChanges(revisions[current+1].Metadata.Containers, revisions[current].Metadata.Containers)
+	}
+	writeJSON(w, resp)
+}
+
+// findNode returns the node of a kind with a name in a namespace, or nil
+func (s *Server) findNode(g graph.GraphInterface, namespace, kind, name string) *graph.Node {
+	if node, exists := g.GetNodeByRef(namespace, kind, name); exists {
+		return node
+	}
+	return nil
+}
+
+func rolloutRevision(node *graph.Node) RolloutRevision {
+	return RolloutRevision{
+		Name:         node.Name,
+		Kind:         node.Kind,
+		Revision:     node.Metadata.Revision,
+		TemplateHash: node.Metadata.TemplateHash,
+		CreatedAt:    node.CreationTimestamp,
+	}
+}
+
+// templateChanges lists the differences between the containers of two Pod templates: added
+// and removed containers, changed images, added and removed environment variables, and
+// changed resource requests and limits
+func templateChanges(previous, current []graph.ContainerInfo) []TemplateChange {
+	changes := make([]TemplateChange, 0)
+
+	before := make(map[string]graph.ContainerInfo, len(previous))
+	for _, c := range previous {
+		before[c.Name] = c
+	}
+	after := make(map[string]graph.ContainerInfo, len(current))
+	for _, c := range current {
+		after[c.Name] = c
+	}
+
+	for _, old := range previous {
+		if _, exists := after[old.Name]; !exists {
+			changes = append(changes, TemplateChange{Container: old.Name, Field: "container", Change: "removed", From: old.Image})
+		}
+	}
+	for _, c := range current {
+		old, exists := before[c.Name]
+		if !exists {
+			changes = append(changes, TemplateChange{Container: c.Name, Field: "container", Change: "added", To: c.Image})
+			continue
+		}
+
+		if old.Image != c.Image {
+			changes = append(changes, TemplateChange{Container: c.Name, Field: "image", Change: "changed", From: old.Image, To: c.Image})
+		}
+
+		oldEnv := make(map[string]bool, len(old.Env))
+		for _, env := range old.Env {
+			oldEnv[env] = true
+		}
+		newEnv := make(map[string]bool, len(c.Env))
+		for _, env := range c.Env {
+			newEnv[env] = true
+			if !oldEnv[env] {
+				changes = append(changes, TemplateChange{Container: c.Name, Field: "env", Change: "added", To: env})
+			}
+		}
+		for _, env := range old.Env {
+			if !newEnv[env] {
+				changes = append(changes, TemplateChange{Container: c.Name, Field: "env", Change: "removed", From: env})
+			}
+		}
+
+		changes = append(changes, resourceChanges(c.Name, "requests", old.Requests, c.Requests)...)
+		changes = append(changes, resourceChanges(c.Name, "limits", old.Limits, c.Limits)...)
+	}
+	return changes
+}
+
+// resourceChanges compares resource quantities, reported as <field>.<resource>
+func resourceChanges(container, field string, previous, current map[string]string) []TemplateChange {
+	var changes []TemplateChange
+	for _, resource := range sortedKeys(previous, current) {
+		from, to := previous[resource], current[resource]
+		change := TemplateChange{Container: container, Field: field + "." + resource, From: from, To: to}
+		switch {
+		case from == to:
+			continue
+		case from == "":
+			change.Change = "added"
+		case to == "":
+			change.Change = "removed"
+		default:
+			change.Change = "changed"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// handleSearch returns the resources matching a free-text query, best matches first
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	q := query.Get("q")
+	namespace := query.Get("namespace")
+
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxSearchLimit))
+			return
+		}
+		limit = parsed
+	}
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+
+	resp := SearchResponse{Query: q, Results: make([]SearchResult, 0)}
+	for _, result := range g.Search(q, 0) {
+		node := result.Node
+		if namespace != "" && node.Namespace != namespace {
+			continue
+		}
+		if exclude.matches(node.Kind, node.APIVersion) {
+			continue
+		}
+		if len(resp.Results) == limit {
+			resp.Truncated = true
+			break
+		}
+		resp.Results = append(resp.Results, searchResult(result))
+	}
+
+	writeJSON(w, resp)
+}
+
+func searchResult(result graph.SearchResult) SearchResult {
+	node := result.Node
+	matches := make([]string, 0, len(result.Matches))
+	for _, field := range result.Matches {
+		matches = append(matches, string(field))
+	}
+	return SearchResult{
+		UID:       string(node.UID),
+		Name:      node.Name,
+		Namespace: node.Namespace,
+		Kind:      node.Kind,
+		Status:    string(node.Status),
+		Release:   node.HelmRelease,
+		Score:     result.Score,
+		Matches:   matches,
+	}
+}
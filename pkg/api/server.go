@@ -1,62 +1,413 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/ammarlakis/astrolabe/pkg/actions"
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"github.com/ammarlakis/astrolabe/pkg/anomalies"
+	"github.com/ammarlakis/astrolabe/pkg/events"
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
+	"github.com/ammarlakis/astrolabe/pkg/informers"
+	"github.com/ammarlakis/astrolabe/pkg/manifest"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+	"github.com/ammarlakis/astrolabe/pkg/tombstones"
+	"github.com/ammarlakis/astrolabe/pkg/uninstalls"
 	"k8s.io/klog/v2"
 )
 
+// Options tunes the HTTP server for long-lived and streaming consumers
+type Options struct {
+	// TLSCertFile and TLSKeyFile enable TLS, which also enables HTTP/2
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// EnableH2C serves HTTP/2 without TLS (prior knowledge), e.g. behind a proxy
+	EnableH2C bool
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// MaxConcurrentStreams limits HTTP/2 streams per connection (0 = Go default)
+	MaxConcurrentStreams int
+	// WriteBufferSize sets the socket send buffer of accepted connections (0 = OS default)
+	WriteBufferSize int
+
+	// TLSPort serves the API with TLS on its own port, leaving the main port in plaintext
+	// (0 = TLS on the main port when a certificate is set)
+	TLSPort int
+	// AdminPort moves /metrics and the debug endpoints to an internal port (0 = served with
+	// the API)
+	AdminPort int
+
+	// Compression lists the encodings API responses are compressed with, by preference (see
+	// CompressionEncodings; none = uncompressed)
+	Compression []string
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+		Compression:  append([]string(nil), CompressionEncodings...),
+	}
+}
+
+// namespaceSyncTimeout bounds how long a request waits for a lazily started namespace to sync
+const namespaceSyncTimeout = 10 * time.Second
+
 // Server is the HTTP API server
 type Server struct {
-	graph  graph.GraphInterface
-	port   int
-	server *http.Server
+	graph   graph.GraphInterface
+	port    int
+	options Options
+	actions *actions.Proxy
+
+	consistency   *graph.ConsistencyChecker
+	dumps         graph.Dumpable
+	stats         graph.Measurable
+	storeStats    graph.StoreStatsReporter
+	namespaces    *informers.Manager
+	apis          *informers.Manager
+	analyses      *analysis.Scheduler
+	timeline      *timeline.Timeline
+	manifests     *manifest.Fetcher
+	tenancy       *tenancy.Authenticator
+	anonymous     bool
+	events        *events.Recorder
+	tombstones    *tombstones.Store
+	healthHistory *healthhistory.Recorder
+	uninstalls    *uninstalls.Detector
+	anomalies     *anomalies.Detector
+	kubeContext   string
+	kubeContexts  []string
+	features      Features
+
+	readinessChecks []readinessCheck
+
+	mu        sync.Mutex
+	listeners *Group
+	drain     drainer
 }
 
 // NewServer creates a new API server
-func NewServer(g graph.GraphInterface, port int) *Server {
+func NewServer(g graph.GraphInterface, port int, options Options) *Server {
 	return &Server{
-		graph: g,
-		port:  port,
+		graph:   g,
+		port:    port,
+		options: options,
 	}
 }
 
-// Start starts the HTTP server
+// EnableActions turns on the write API that proxies workload mutations through the proxy
+func (s *Server) EnableActions(proxy *actions.Proxy) {
+	s.actions = proxy
+}
+
+// EnableConsistencyChecks serves the reports of the consistency checker on /api/v1/debug/consistency
+func (s *Server) EnableConsistencyChecks(checker *graph.ConsistencyChecker) {
+	s.consistency = checker
+}
+
+// EnableStateDumps serves state dumps of the live graph on /api/v1/debug/dump
+func (s *Server) EnableStateDumps(g graph.Dumpable) {
+	s.dumps = g
+}
+
+// EnableStats serves the size of the live graph on /api/v1/stats, with the data of the
+// persistence store when store is not nil
+func (s *Server) EnableStats(g graph.Measurable, store graph.StoreStatsReporter) {
+	s.stats = g
+	s.storeStats = store
+}
+
+// EnableLazyNamespaces starts watching a namespace through the manager when a request first
+// filters on it
+func (s *Server) EnableLazyNamespaces(manager *informers.Manager) {
+	s.namespaces = manager
+}
+
+// EnableClusterAPIs serves the API resources discovered by the manager on /api/v1/cluster/apis
+func (s *Server) EnableClusterAPIs(manager *informers.Manager) {
+	s.apis = manager
+}
+
+// EnableAnalyses serves the cached results of the scheduler's analyses on /api/v1/analysis
+func (s *Server) EnableAnalyses(scheduler *analysis.Scheduler) {
+	s.analyses = scheduler
+}
+
+// EnableTimeline serves the release events of the timeline on /api/v1/releases/{name}/timeline
+func (s *Server) EnableTimeline(t *timeline.Timeline) {
+	s.timeline = t
+}
+
+// EnableManifests serves the sanitized live objects fetched by the fetcher on
+// /api/v1/resources/{uid}/manifest
+func (s *Server) EnableManifests(fetcher *manifest.Fetcher) {
+	s.manifests = fetcher
+}
+
+// EnableEvents serves the Warning Events kept by the recorder on /api/v1/resources/{uid}/events
+func (s *Server) EnableEvents(recorder *events.Recorder) {
+	s.events = recorder
+}
+
+// EnableTombstones returns the deleted resources kept by the store with the live ones when
+// /api/v1/resources and /api/v1/graph are called with includeDeleted=true
+func (s *Server) EnableTombstones(store *tombstones.Store) {
+	s.tombstones = store
+}
+
+// EnableHealthHistory serves the hourly health of releases kept by the recorder on
+// /api/v1/releases/{name}/health-history
+func (s *Server) EnableHealthHistory(recorder *healthhistory.Recorder) {
+	s.healthHistory = recorder
+}
+
+// EnableUninstalls serves the resources left by the uninstalled releases the detector found on
+// /api/v1/releases/{name}/leftovers
+func (s *Server) EnableUninstalls(detector *uninstalls.Detector) {
+	s.uninstalls = detector
+}
+
+// EnableAnomalies serves the Pods the detector flagged for restarting or flapping on
+// /api/v1/anomalies
+func (s *Server) EnableAnomalies(detector *anomalies.Detector) {
+	s.anomalies = detector
+}
+
+// SetKubeContexts reports on /health the kubeconfig context of the watched cluster and those
+// of the other watched clusters
+func (s *Server) SetKubeContexts(current string, others []string) {
+	s.kubeContext = current
+	s.kubeContexts = others
+}
+
+// EnableTenancy requires an API token on every request and limits the resources served to
+// the namespaces and releases the token is scoped to
+func (s *Server) EnableTenancy(authenticator *tenancy.Authenticator) {
+	s.tenancy = authenticator
+}
+
+// EnableAnonymousAccess serves the aggregate endpoints, which name no resources, to requests
+// without an API token when tenancy is enabled
+func (s *Server) EnableAnonymousAccess() {
+	s.anonymous = true
+}
+
+// Start serves the API on its listeners until they are stopped: the main port, the TLS port
+// and the admin port when configured. When one listener fails, the others are stopped.
 func (s *Server) Start() error {
-	mux := http.NewServeMux()
+	api, admin := s.routes()
+	handler := s.middleware(api)
+	primary := s.listener("API", s.port, handler)
+	group := NewGroup(primary)
+	if s.options.TLSPort > 0 {
+		secure := s.listener("TLS API", s.options.TLSPort, handler)
+		secure.certFile, secure.keyFile = s.options.TLSCertFile, s.options.TLSKeyFile
+		group.Add(secure)
+	} else {
+		primary.certFile, primary.keyFile = s.options.TLSCertFile, s.options.TLSKeyFile
+	}
+	if s.options.AdminPort > 0 {
+		group.Add(s.listener("admin", s.options.AdminPort, s.loggingMiddleware(admin)))
+	}
+
+	s.mu.Lock()
+	s.listeners = group
+	s.mu.Unlock()
+	return group.Start()
+}
+
+// Handler returns the handler of the main port, e.g. to serve the API in-process to golden
+// tests. Without an admin port, it also serves the admin endpoints.
+func (s *Server) Handler() http.Handler {
+	api, _ := s.routes()
+	return s.middleware(api)
+}
+
+// routes registers the handlers of the main port and of the admin port, which are the same mux
+// without --admin-port
+func (s *Server) routes() (*http.ServeMux, *http.ServeMux) {
+	api := http.NewServeMux()
+	admin := api
+	if s.options.AdminPort > 0 {
+		admin = http.NewServeMux()
+		admin.HandleFunc("/health", s.handleHealth)
+		admin.HandleFunc("/healthz", s.handleLiveness)
+		admin.HandleFunc("/readyz", s.handleReadiness)
+	}
 
 	// Register handlers
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/api/v1/resources", s.handleResources)
-	mux.HandleFunc("/api/v1/releases", s.handleReleases)
-	mux.HandleFunc("/api/v1/charts", s.handleCharts)
-	mux.HandleFunc("/api/v1/namespaces", s.handleNamespaces)
-	mux.HandleFunc("/api/v1/graph", s.handleGraph)
-
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.port),
-		Handler:      s.loggingMiddleware(mux),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	api.HandleFunc("/health", s.handleHealth)
+	api.HandleFunc("/healthz", s.handleLiveness)
+	api.HandleFunc("/readyz", s.handleReadiness)
+	api.HandleFunc("/api/v1/resources", s.handleResources)
+	api.HandleFunc("POST /api/v1/resources/batch", s.handleBatch)
+	api.HandleFunc("/api/v1/releases", s.handleReleases)
+	api.HandleFunc("/api/v1/releases/dependencies", s.handleReleaseDependencies)
+	api.HandleFunc("GET /api/v1/releases/diff", s.handleReleaseDiff)
+	api.HandleFunc("GET /api/v1/releases/{name}/history", s.handleReleaseHistory)
+	api.HandleFunc("GET /api/v1/releases/{name}/topology", s.handleReleaseTopology)
+	api.HandleFunc("GET /api/v1/releases/{name}/time-to-ready", s.handleReleaseTimeToReady)
+	api.HandleFunc("GET /api/v1/releases/{name}/charts", s.handleReleaseCharts)
+	api.HandleFunc("/api/v1/charts", s.handleCharts)
+	api.HandleFunc("GET /api/v1/charts/{chart}/releases", s.handleChartReleases)
+	api.HandleFunc("/api/v1/namespaces", s.handleNamespaces)
+	api.HandleFunc("/api/v1/graph", s.handleGraph)
+	api.HandleFunc("/api/v1/summary", s.handleSummary)
+	api.HandleFunc("/api/v1/applications", s.handleApplications)
+	api.HandleFunc("GET /api/v1/topology", s.handleZones)
+	api.HandleFunc("GET /api/v1/search", s.handleSearch)
+	api.HandleFunc("GET /api/v1/rollouts/{namespace}/{kind}/{name}/changes", s.handleRolloutChanges)
+	api.HandleFunc("GET /api/v1/impact", s.handleImpact)
+	api.HandleFunc("GET /api/v1/images", s.handleImages)
+	api.HandleFunc("GET /api/v1/capabilities", s.handleCapabilities)
+	api.HandleFunc("/api/v1/openapi.json", s.handleOpenAPI)
+	api.HandleFunc("/api/v1/docs", s.handleSwaggerUI)
+	if s.actions != nil {
+		api.HandleFunc("POST /api/v1/actions/restart", s.handleRestart)
+		api.HandleFunc("POST /api/v1/actions/scale", s.handleScale)
+	}
+	if s.consistency != nil {
+		admin.HandleFunc("GET /api/v1/debug/consistency", s.handleConsistency)
+	}
+	if s.dumps != nil {
+		admin.HandleFunc("GET /api/v1/debug/dump", s.handleStateDump)
+	}
+	if s.stats != nil {
+		admin.HandleFunc("GET /api/v1/stats", s.handleStats)
 	}
+	if s.apis != nil {
+		api.HandleFunc("GET /api/v1/cluster/apis", s.handleClusterAPIs)
+	}
+	if s.manifests != nil {
+		api.HandleFunc("GET /api/v1/resources/{uid}/manifest", s.handleResourceManifest)
+	}
+	if s.events != nil {
+		api.HandleFunc("GET /api/v1/resources/{uid}/events", s.handleResourceEvents)
+	}
+	if s.analyses != nil {
+		api.HandleFunc("GET /api/v1/analysis", s.handleAnalyses)
+		api.HandleFunc("GET /api/v1/analysis/{name}", s.handleAnalysis)
+	}
+	if s.timeline != nil {
+		api.HandleFunc("GET /api/v1/releases/{name}/timeline", s.handleReleaseTimeline)
+	}
+	if s.healthHistory != nil {
+		api.HandleFunc("GET /api/v1/releases/{name}/health-history", s.handleReleaseHealthHistory)
+	}
+	if s.uninstalls != nil {
+		api.HandleFunc("GET /api/v1/releases/{name}/leftovers", s.handleReleaseLeftovers)
+	}
+	if s.anomalies != nil {
+		api.HandleFunc("GET /api/v1/anomalies", s.handleAnomalies)
+	}
+	admin.Handle("/metrics", metrics.Handler())
+	return api, admin
+}
 
-	klog.Infof("Starting API server on port %d", s.port)
-	return s.server.ListenAndServe()
+// middleware wraps the handlers of the main port
+func (s *Server) middleware(api http.Handler) http.Handler {
+	return s.tracingMiddleware(api, s.loggingMiddleware(s.compressionMiddleware(s.tenancyMiddleware(s.namespaceMiddleware(api)))))
 }
 
-// Stop stops the HTTP server
+// listener creates an HTTP listener with the tuning of the server options
+func (s *Server) listener(name string, port int, handler http.Handler) *httpListener {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(s.options.EnableH2C)
+
+	l := newHTTPListener(name, &http.Server{
+		Handler:      s.drainMiddleware(handler),
+		ReadTimeout:  s.options.ReadTimeout,
+		WriteTimeout: s.options.WriteTimeout,
+		IdleTimeout:  s.options.IdleTimeout,
+		Protocols:    protocols,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: s.options.MaxConcurrentStreams,
+		},
+	}, port)
+	l.writeBufferSize = s.options.WriteBufferSize
+	return l
+}
+
+// Stop stops every listener of the server
 func (s *Server) Stop() error {
-	if s.server != nil {
-		return s.server.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listeners != nil {
+		return s.listeners.Stop()
+	}
+	return nil
+}
+
+// Shutdown drains the listeners: new requests are answered with 503, so that load balancers
+// and the readiness probe move traffic to other replicas, until those in flight complete or
+// ctx is done. The listeners are then closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.drain.wait(ctx)
+	s.mu.Lock()
+	listeners := s.listeners
+	s.mu.Unlock()
+	if listeners != nil {
+		return listeners.Shutdown(ctx)
 	}
 	return nil
 }
 
+// drainMiddleware counts the requests in flight and rejects those received during shutdown
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.drain.begin() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+			return
+		}
+		defer s.drain.end()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed to encode response: %v", err)
+	}
+}
+
+// writeError writes a JSON error response with the given status code
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Middleware
 
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
@@ -67,63 +418,93 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// namespaceMiddleware activates the namespace of a request in lazy namespace mode, waiting a
+// bounded time for its informers to sync so the first response is (mostly) complete. Namespaces
+// outside the caller's scope are not activated.
+func (s *Server) namespaceMiddleware(next http.Handler) http.Handler {
+	if s.namespaces == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if namespace := r.URL.Query().Get("namespace"); namespace != "" && tenancy.FromContext(r.Context()).AllowsNamespace(namespace) {
+			ctx, cancel := context.WithTimeout(r.Context(), namespaceSyncTimeout)
+			if err := s.namespaces.ActivateNamespace(ctx, namespace); err != nil {
+				klog.V(2).Infof("Serving namespace %s before its informers synced: %v", namespace, err)
+			}
+			cancel()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Handlers
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "healthy",
-		"nodes":  len(s.graph.GetAllNodes()),
+	json.NewEncoder(w).Encode(HealthResponse{
+		Status:   "healthy",
+		Nodes:    len(s.graph.GetAllNodes()),
+		Context:  s.kubeContext,
+		Contexts: s.kubeContexts,
 	})
 }
 
 func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
 	start := time.Now()
 	query := r.URL.Query()
 	releaseName := query.Get("release")
 	namespace := query.Get("namespace")
+	chart := query.Get("chart")
 
-	klog.V(2).Infof("API: /resources request - release=%s namespace=%s", releaseName, namespace)
-
-	var nodes []*graph.Node
-
-	if releaseName != "" {
-		// Get resources by Helm release
-		nodes = s.graph.GetNodesByHelmRelease(releaseName)
-		klog.V(2).Infof("API: Found %d nodes for release '%s' (from cache)", len(nodes), releaseName)
-
-		// Filter by namespace if specified
-		if namespace != "" {
-			filtered := make([]*graph.Node, 0)
-			for _, node := range nodes {
-				if node.Namespace == namespace || node.Namespace == "" {
-					filtered = append(filtered, node)
-				}
-			}
-			nodes = filtered
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+	order, ok := parseSortOrder(w, r, nodeSortKeys...)
+	if !ok {
+		return
+	}
+	format, ok := negotiateFormat(w, r, formatJSON, formatProtobuf, formatCSV)
+	if !ok {
+		return
+	}
+	var columns []csvColumn[Resource]
+	if format == formatCSV {
+		if columns, ok = selectColumns(w, r, resourceColumns, defaultResourceColumns); !ok {
+			return
 		}
+	}
+	includeDeleted, ok := s.includeDeleted(w, r)
+	if !ok {
+		return
+	}
 
-		nodes = s.includePersistentVolumes(nodes, releaseName)
-	} else {
-		// Get all nodes
-		nodes = s.graph.GetAllNodes()
-
-		// Filter by namespace if specified
-		if namespace != "" {
-			filtered := make([]*graph.Node, 0)
-			for _, node := range nodes {
-				if node.Namespace == namespace || node.Namespace == "" {
-					filtered = append(filtered, node)
+	klog.V(2).Infof("API: /resources request - release=%s namespace=%s", releaseName, namespace)
+
+	nodes := s.resourceNodes(g, releaseName, namespace, chart)
+	if includeDeleted {
+		nodes = append(nodes, s.deletedNodes(r.Context(), releaseName, namespace, chart)...)
+	}
+	nodes = exclude.apply(nodes)
+	order.sortNodes(nodes)
+
+	if format == formatProtobuf {
+		message := resourcesMessage(nodes)
+		if releaseName != "" {
+			for _, node := range message.Nodes {
+				if node.Release == "" {
+					node.Release = releaseName
 				}
 			}
-			nodes = filtered
 		}
-
-		nodes = s.includePersistentVolumes(nodes, "")
+		writeProtobuf(w, message)
+		klog.V(2).Infof("API: Returning %d resources (took %v)", len(nodes), time.Since(start))
+		return
 	}
 
 	// Convert to response format compatible with the datasource
-	resources := s.nodesToResources(nodes)
+	resources := s.nodesToResources(g, nodes)
 
 	if releaseName != "" {
 		for i := range resources {
@@ -135,48 +516,62 @@ func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
 
 	klog.V(2).Infof("API: Returning %d resources (took %v)", len(resources), time.Since(start))
 
+	if format == formatCSV {
+		writeCSV(w, "resources.csv", columns, resources)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resources)
 }
 
+// handleReleases lists the Helm releases with their chart, status counts, health and last
+// change, or only their names with ?namesOnly=true
 func (s *Server) handleReleases(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
 	query := r.URL.Query()
 	namespace := query.Get("namespace")
+	namesOnly := query.Get("namesOnly") == "true"
+	order, ok := parseSortOrder(w, r, sortByName)
+	if !ok {
+		return
+	}
 
-	releases := s.graph.GetAllHelmReleases()
+	names := g.GetAllHelmReleases()
+	order.sortStrings(names)
 
-	// Filter by namespace if specified
-	if namespace != "" {
-		filtered := make([]string, 0)
-		for _, release := range releases {
-			// Check if release has resources in the namespace
-			nodes := s.graph.GetNodesByHelmRelease(release)
-			for _, node := range nodes {
-				if node.Namespace == namespace {
-					filtered = append(filtered, release)
-					break
-				}
-			}
+	releases := make([]Release, 0, len(names))
+	for _, name := range names {
+		// Releases without resources in the namespace are left out
+		if release, ok := buildRelease(g, name, namespace); ok {
+			releases = append(releases, release)
 		}
-		releases = filtered
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if namesOnly {
+		filtered := make([]string, 0, len(releases))
+		for _, release := range releases {
+			filtered = append(filtered, release.Name)
+		}
+		json.NewEncoder(w).Encode(filtered)
+		return
+	}
 	json.NewEncoder(w).Encode(releases)
 }
 
 func (s *Server) handleCharts(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
 	query := r.URL.Query()
 	namespace := query.Get("namespace")
 
-	charts := s.graph.GetAllHelmCharts()
+	charts := g.GetAllHelmCharts()
 
 	// Filter by namespace if specified
 	if namespace != "" {
 		filtered := make([]string, 0)
 		chartSet := make(map[string]bool)
 
-		nodes := s.graph.GetAllNodes()
+		nodes := g.GetAllNodes()
 		for _, node := range nodes {
 			if node.Namespace == namespace && node.HelmChart != "" {
 				if !chartSet[node.HelmChart] {
@@ -187,15 +582,22 @@ func (s *Server) handleCharts(w http.ResponseWriter, r *http.Request) {
 		}
 		charts = filtered
 	}
+	sort.Strings(charts)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(charts)
 }
 
 func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	order, ok := parseSortOrder(w, r, sortByName)
+	if !ok {
+		return
+	}
+
 	namespaces := make(map[string]bool)
 
-	nodes := s.graph.GetAllNodes()
+	nodes := g.GetAllNodes()
 	for _, node := range nodes {
 		if node.Namespace != "" {
 			namespaces[node.Namespace] = true
@@ -206,46 +608,104 @@ func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
 	for ns := range namespaces {
 		result = append(result, ns)
 	}
+	order.sortStrings(result)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
 func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	view := s.readGraph()
+	g := tenancy.FromContext(r.Context()).Graph(view)
 	query := r.URL.Query()
 	releaseName := query.Get("release")
 	namespace := query.Get("namespace")
-
-	var nodes []*graph.Node
-
-	if releaseName != "" {
-		nodes = s.graph.GetNodesByHelmRelease(releaseName)
-		if namespace != "" {
-			filtered := make([]*graph.Node, 0)
-			for _, node := range nodes {
-				if node.Namespace == namespace || node.Namespace == "" {
-					filtered = append(filtered, node)
-				}
-			}
-			nodes = filtered
+	chart := query.Get("chart")
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+	kinds, ok := onlyKinds(w, r)
+	if !ok {
+		return
+	}
+	edgeTypes, ok := onlyEdgeTypes(w, r)
+	if !ok {
+		return
+	}
+	order, ok := parseSortOrder(w, r, nodeSortKeys...)
+	if !ok {
+		return
+	}
+	format, ok := negotiateFormat(w, r, formatJSON, formatProtobuf)
+	if !ok {
+		return
+	}
+	includeDeleted, ok := s.includeDeleted(w, r)
+	if !ok {
+		return
+	}
+	summarize := query.Get("summarize") == "true"
+	if summarize && format == formatProtobuf {
+		writeError(w, http.StatusNotAcceptable, "summarized graphs are only available as JSON")
+		return
+	}
+	since := query.Get("since")
+	if since != "" && format == formatProtobuf {
+		writeError(w, http.StatusNotAcceptable, "incremental graphs are only available as JSON")
+		return
+	}
+	if since != "" && (summarize || includeDeleted) {
+		writeError(w, http.StatusBadRequest, "since cannot be combined with summarize or includeDeleted")
+		return
+	}
+	maxNodes := defaultMaxGraphNodes
+	if value := query.Get("maxNodes"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(w, http.StatusBadRequest, "maxNodes must be a positive number")
+			return
 		}
-		nodes = s.expandRelatedNodes(nodes, namespace, releaseName)
-		nodes = s.includePersistentVolumes(nodes, releaseName)
-	} else if namespace != "" {
-		allNodes := s.graph.GetAllNodes()
-		for _, node := range allNodes {
-			if node.Namespace == namespace || node.Namespace == "" {
-				nodes = append(nodes, node)
-			}
+		maxNodes = parsed
+	}
+
+	generation := view.Generation()
+	var changes *graph.Changes
+	if since != "" {
+		if changes, ok = graphChanges(w, view, since); !ok {
+			return
 		}
-		nodes = s.includePersistentVolumes(nodes, "")
-	} else {
-		nodes = s.graph.GetAllNodes()
-		nodes = s.includePersistentVolumes(nodes, "")
+	}
+	nodes := s.graphNodes(g, releaseName, namespace, chart)
+	if includeDeleted {
+		nodes = append(nodes, s.deletedNodes(r.Context(), releaseName, namespace, chart)...)
+	}
+	nodes = kinds.keep(exclude.apply(nodes))
+	order.sortNodes(nodes)
+
+	if since != "" {
+		s.writeGraphChanges(w, r, g, generation, changes, nodes, edgeTypes)
+		return
+	}
+
+	if format == formatProtobuf {
+		message := graphMessage(nodes, generation)
+		message.Edges = edgeTypes.messages(message.Edges)
+		writeProtobuf(w, message)
+		return
 	}
 
 	// Build graph response with nodes and edges
-	graphResp := s.buildGraphResponse(nodes)
+	var graphResp GraphResponse
+	if summarize {
+		graphResp = s.summarizeGraph(g, nodes, maxNodes)
+	} else {
+		graphResp = s.buildGraphResponse(g, nodes)
+	}
+	graphResp.Edges = edgeTypes.edges(graphResp.Edges)
+	if !summarize {
+		graphResp.Token = s.changeToken(generation)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(graphResp)
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// Sort keys of the sortBy query parameter
+const (
+	sortByName   = "name"
+	sortByAge    = "age"
+	sortByStatus = "status"
+	sortByKind   = "kind"
+)
+
+// nodeSortKeys are the sort keys accepted by endpoints returning resources
+var nodeSortKeys = []string{sortByName, sortByAge, sortByStatus, sortByKind}
+
+// statusRank orders statuses by severity, so problems come first in ascending order
+var statusRank = map[graph.ResourceStatus]int{
+	graph.StatusError:   0,
+	graph.StatusPending: 1,
+	graph.StatusUnknown: 2,
+	graph.StatusReady:   3,
+}
+
+// sortOrder is the ordering requested with the sortBy and order query parameters. Without
+// sortBy, results are ordered by namespace, kind and name.
+type sortOrder struct {
+	by         string
+	descending bool
+}
+
+// parseSortOrder parses the sortBy and order query parameters, writing a 400 response if they
+// are invalid. keys are the sortBy values supported by the endpoint.
+func parseSortOrder(w http.ResponseWriter, r *http.Request, keys ...string) (sortOrder, bool) {
+	query := r.URL.Query()
+	order := sortOrder{by: query.Get("sortBy")}
+
+	if order.by != "" && !containsString(keys, order.by) {
+		writeError(w, http.StatusBadRequest, "sortBy must be one of "+strings.Join(keys, ", "))
+		return order, false
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		order.descending = true
+	default:
+		writeError(w, http.StatusBadRequest, "order must be asc or desc")
+		return order, false
+	}
+	return order, true
+}
+
+// sortNodes sorts nodes in place. Ties are broken by namespace, kind, name and UID so the
+// order is the same on every request.
+func (o sortOrder) sortNodes(nodes []*graph.Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if o.descending {
+			a, b = b, a
+		}
+		if c := o.compare(a, b); c != 0 {
+			return c < 0
+		}
+		return compareNodeIdentity(a, b) < 0
+	})
+}
+
+// compare compares two nodes by the sort key, returning 0 when they are equal on it
+func (o sortOrder) compare(a, b *graph.Node) int {
+	switch o.by {
+	case sortByName:
+		return strings.Compare(a.Name, b.Name)
+	case sortByAge:
+		// Youngest first, as the age column grows
+		return b.CreationTimestamp.Compare(a.CreationTimestamp)
+	case sortByStatus:
+		return statusRank[a.Status] - statusRank[b.Status]
+	case sortByKind:
+		return strings.Compare(a.Kind, b.Kind)
+	}
+	return 0
+}
+
+// compareNodeIdentity gives the default order: namespace, kind, name, then UID
+func compareNodeIdentity(a, b *graph.Node) int {
+	if a.Namespace != b.Namespace {
+		return strings.Compare(a.Namespace, b.Namespace)
+	}
+	if a.Kind != b.Kind {
+		return strings.Compare(a.Kind, b.Kind)
+	}
+	if a.Name != b.Name {
+		return strings.Compare(a.Name, b.Name)
+	}
+	return strings.Compare(string(a.UID), string(b.UID))
+}
+
+// sortStrings sorts names in the requested direction
+func (o sortOrder) sortStrings(values []string) {
+	if o.descending {
+		sort.Sort(sort.Reverse(sort.StringSlice(values)))
+		return
+	}
+	sort.Strings(values)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// storeStatsTimeout bounds the scan of the keys of the persistence store
+const storeStatsTimeout = 10 * time.Second
+
+// handleStats reports the size of the live graph, its pending edges and indexes, the memory of
+// the process and the data of the persistence store, for sizing and troubleshooting. Stats of
+// a store that cannot be read carry the error instead, the others are still returned.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.stats.Stats()
+	resp := StatsResponse{
+		Generation:  stats.Generation,
+		Nodes:       stats.Nodes,
+		Edges:       stats.Edges,
+		NodesByKind: stats.NodesByKind,
+		EdgesByType: make(map[string]int, len(stats.EdgesByType)),
+		IndexSizes:  stats.IndexSizes,
+		PendingEdges: PendingEdgeCounts{
+			Target: stats.PendingEdges,
+			Source: stats.ReversePendingEdges,
+			Owner:  stats.PendingOwnerEdges,
+		},
+	}
+	for edgeType, count := range stats.EdgesByType {
+		resp.EdgesByType[string(edgeType)] = count
+	}
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	resp.Memory = MemoryStats{
+		GraphEstimateBytes: stats.EstimatedBytes,
+		HeapAllocBytes:     memory.HeapAlloc,
+		HeapInuseBytes:     memory.HeapInuse,
+		SysBytes:           memory.Sys,
+	}
+
+	if s.storeStats != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), storeStatsTimeout)
+		defer cancel()
+		if store, err := s.storeStats.StoreStats(ctx); err != nil {
+			resp.Storage = &StorageStats{Error: err.Error()}
+		} else {
+			resp.Storage = &StorageStats{
+				Keys:            store.Keys,
+				TotalKeys:       store.TotalKeys,
+				UsedMemoryBytes: store.UsedMemoryBytes,
+				LastSnapshot:    store.LastSnapshot,
+			}
+		}
+	}
+	writeJSON(w, resp)
+}
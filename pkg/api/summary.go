@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// Summary groups, in the order rows are returned
+var summaryGroups = []string{"status", "kind", "namespace", "release"}
+
+// handleSummary returns resource counts by status, kind, namespace and release
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	groupBy := query.Get("groupBy")
+
+	if groupBy != "" && !containsString(summaryGroups, groupBy) {
+		writeError(w, http.StatusBadRequest, "groupBy must be one of status, kind, namespace, release")
+		return
+	}
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+
+	nodes := exclude.apply(g.GetAllNodes())
+	if namespace != "" {
+		filtered := make([]*graph.Node, 0)
+		for _, node := range nodes {
+			if node.Namespace == namespace {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+
+	writeJSON(w, buildSummary(nodes, groupBy))
+}
+
+func buildSummary(nodes []*graph.Node, groupBy string) SummaryResponse {
+	var totals SummaryRow
+	rows := make(map[string]map[string]*SummaryRow, len(summaryGroups))
+	for _, group := range summaryGroups {
+		rows[group] = make(map[string]*SummaryRow)
+	}
+
+	count := func(group, key string, status graph.ResourceStatus) {
+		if key == "" {
+			return
+		}
+		row, exists := rows[group][key]
+		if !exists {
+			row = &SummaryRow{Group: group, Key: key}
+			rows[group][key] = row
+		}
+		row.add(status)
+	}
+
+	for _, node := range nodes {
+		totals.add(node.Status)
+
+		namespace := node.Namespace
+		if namespace == "" {
+			namespace = "_cluster"
+		}
+
+		count("status", string(node.Status), node.Status)
+		count("kind", node.Kind, node.Status)
+		count("namespace", namespace, node.Status)
+		count("release", node.HelmRelease, node.Status)
+	}
+
+	resp := SummaryResponse{
+		Total:   totals.Total,
+		Ready:   totals.Ready,
+		Pending: totals.Pending,
+		Error:   totals.Error,
+		Unknown: totals.Unknown,
+		Rows:    make([]SummaryRow, 0),
+	}
+
+	for _, group := range summaryGroups {
+		if groupBy != "" && group != groupBy {
+			continue
+		}
+		keys := make([]string, 0, len(rows[group]))
+		for key := range rows[group] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			resp.Rows = append(resp.Rows, *rows[group][key])
+		}
+	}
+
+	return resp
+}
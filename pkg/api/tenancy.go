@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/analysis"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// tenancyExemptPaths are served without an API token: they expose no topology, and actions
+// are authorized with the caller's Kubernetes token instead
+var tenancyExemptPaths = []string{"/health", "/healthz", "/readyz", "/metrics", "/api/v1/capabilities", "/api/v1/openapi.json", "/api/v1/docs", "/api/v1/actions/"}
+
+// anonymousPaths are served to requests without an API token in anonymous mode. They only
+// return counts by status, kind, namespace and release, and namespace names: no resource names
+// or labels. Release health is the summary grouped by release.
+var anonymousPaths = []string{"/api/v1/summary", "/api/v1/namespaces"}
+
+// graphFor returns the graph as seen by the caller of a request: limited to its scope when
+// API tokens are scoped, the whole graph otherwise
+func (s *Server) graphFor(ctx context.Context) graph.GraphInterface {
+	return tenancy.FromContext(ctx).Graph(s.graph)
+}
+
+// bearerToken returns the bearer token of a request, or ""
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token)
+}
+
+// tenancyMiddleware authenticates the API token of a request and attaches its scope. The
+// debug and stats endpoints, which cover the whole graph, are only served to unrestricted
+// tokens.
+func (s *Server) tenancyMiddleware(next http.Handler) http.Handler {
+	if s.tenancy == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range tenancyExemptPaths {
+			if r.URL.Path == path || (strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		token := bearerToken(r)
+		if token == "" && s.anonymous && containsString(anonymousPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scope, ok := s.tenancy.Authenticate(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="astrolabe"`)
+			writeError(w, http.StatusUnauthorized, "a valid API token is required")
+			return
+		}
+		if (strings.HasPrefix(r.URL.Path, "/api/v1/debug/") || r.URL.Path == "/api/v1/stats") && !scope.Unrestricted() {
+			writeError(w, http.StatusForbidden, "the debug and stats endpoints require an unrestricted API token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(tenancy.WithScope(r.Context(), scope)))
+	})
+}
+
+// grpcScope authenticates the API token in the authorization metadata of a call and attaches
+// its scope
+func (s *Server) grpcScope(ctx context.Context) (context.Context, error) {
+	if s.tenancy == nil {
+		return ctx, nil
+	}
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	scope, ok := s.tenancy.Authenticate(strings.TrimSpace(token))
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "a valid API token is required")
+	}
+	return tenancy.WithScope(ctx, scope), nil
+}
+
+func (s *Server) unaryScopeInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.grpcScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamScopeInterceptor(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcScope(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &scopedStream{ServerStream: stream, ctx: ctx})
+}
+
+// scopedStream is a server stream carrying the scope of its caller
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedStream) Context() context.Context {
+	return s.ctx
+}
+
+// scopedFindings returns the findings about resources in the graph as seen by the caller
+func scopedFindings(g graph.GraphInterface, findings []analysis.Finding) []analysis.Finding {
+	filtered := make([]analysis.Finding, 0, len(findings))
+	for _, finding := range findings {
+		if _, exists := g.GetNode(types.UID(finding.UID)); exists {
+			filtered = append(filtered, finding)
+		}
+	}
+	return filtered
+}
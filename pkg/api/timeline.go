@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+)
+
+// handleReleaseTimeline lists the recorded events of a release, oldest first
+func (s *Server) handleReleaseTimeline(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := timeline.Filter{Release: r.PathValue("name"), Namespace: query.Get("namespace")}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		filter.Since = t
+	}
+
+	scope := tenancy.FromContext(r.Context())
+	resp := ReleaseTimelineResponse{Release: filter.Release, Events: make([]TimelineEvent, 0)}
+	for _, event := range s.timeline.Events(filter) {
+		if !scope.AllowsRelease(event.Release) || !scope.AllowsNamespace(event.Namespace) {
+			continue
+		}
+		resp.Events = append(resp.Events, TimelineEvent{
+			Time:      event.Time,
+			Type:      string(event.Type),
+			Namespace: event.Namespace,
+			Message:   event.Message,
+			Details:   event.Details,
+		})
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/tenancy"
+)
+
+// includeDeleted reports whether a request asks for deleted resources with includeDeleted=true,
+// writing an error when tombstones are not kept
+func (s *Server) includeDeleted(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	if r.URL.Query().Get("includeDeleted") != "true" {
+		return false, true
+	}
+	if s.tombstones == nil {
+		writeError(w, http.StatusBadRequest, "deleted resources are not kept (see --tombstone-retention)")
+		return false, false
+	}
+	return true, true
+}
+
+// deletedNodes returns the tombstones of the deleted resources of the release (or all
+// resources) in the namespace, rendered from the chart when one is given, that are in the
+// scope of the caller
+func (s *Server) deletedNodes(ctx context.Context, releaseName, namespace, chart string) []*graph.Node {
+	scope := tenancy.FromContext(ctx)
+	nodes := make([]*graph.Node, 0)
+	for _, node := range s.tombstones.Nodes() {
+		if releaseName != "" && node.HelmRelease != releaseName {
+			continue
+		}
+		if namespace != "" && node.Namespace != namespace && node.Namespace != "" {
+			continue
+		}
+		if scope.AllowsDetached(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return filterChart(nodes, chart)
+}
+
+// nodeEdges returns the outgoing edges of a node. Tombstones also return their incoming
+// edges, which the neighbours of a deleted resource no longer hold.
+func nodeEdges(node *graph.Node) []*graph.Edge {
+	edges := sortedEdges(node.OutgoingEdges)
+	if node.DeletedAt != nil {
+		edges = append(edges, sortedIncomingEdges(node.IncomingEdges)...)
+	}
+	return edges
+}
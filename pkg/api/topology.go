@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleReleaseTopology returns the resources of a release in dependency order: every
+// resource comes after the resources with an edge to it (owners, selecting Services, routing
+// Ingresses, mounting Pods, ...), with its level in the hierarchy, for tree views
+func (s *Server) handleReleaseTopology(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	release := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+	chart := r.URL.Query().Get("chart")
+	exclude, ok := excludeKinds(w, r)
+	if !ok {
+		return
+	}
+
+	nodes := exclude.apply(s.graphNodes(g, release, namespace, chart))
+	if len(nodes) == 0 {
+		writeError(w, http.StatusNotFound, "no resources of release "+release)
+		return
+	}
+	writeJSON(w, s.buildTopology(g, release, nodes))
+}
+
+// buildTopology sorts nodes topologically over the edges between them. A node's level is
+// the length of the longest path from a root, a node without incoming edges. Edges closing a
+// cycle are ignored for ordering and the nodes they enter are flagged as cyclic.
+func (s *Server) buildTopology(g graph.GraphInterface, release string, nodes []*graph.Node) ReleaseTopologyResponse {
+	graphResp := s.buildGraphResponse(g, nodes)
+	charts := newChartResolver(g)
+
+	byUID := make(map[types.UID]*graph.Node, len(nodes))
+	for _, node := range nodes {
+		byUID[node.UID] = node
+	}
+	parents := make(map[types.UID][]string, len(nodes))
+	children := make(map[types.UID][]types.UID, len(nodes))
+	indegree := make(map[types.UID]int, len(nodes))
+	for _, edge := range graphResp.Edges {
+		from, to := types.UID(edge.From), types.UID(edge.To)
+		if from == to {
+			continue
+		}
+		parents[to] = append(parents[to], edge.From)
+		children[from] = append(children[from], to)
+		indegree[to]++
+	}
+
+	// Nodes in a stable order: kind, namespace, name
+	sorted := append([]*graph.Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return topologyLess(sorted[i], sorted[j]) })
+
+	level := make(map[types.UID]int, len(nodes))
+	placed := make(map[types.UID]bool, len(nodes))
+	cyclic := make(map[types.UID]bool)
+	order := make([]*graph.Node, 0, len(nodes))
+
+	place := func(node *graph.Node) []*graph.Node {
+		placed[node.UID] = true
+		order = append(order, node)
+		var ready []*graph.Node
+		for _, child := range children[node.UID] {
+			if placed[child] {
+				continue
+			}
+			level[child] = max(level[child], level[node.UID]+1)
+			if indegree[child]--; indegree[child] == 0 {
+				ready = append(ready, byUID[child])
+			}
+		}
+		return ready
+	}
+
+	var queue []*graph.Node
+	for _, node := range sorted {
+		if indegree[node.UID] == 0 {
+			queue = append(queue, node)
+		}
+	}
+	for len(order) < len(nodes) {
+		if len(queue) == 0 {
+			// Only cycles remain: break one at its first remaining node
+			for _, node := range sorted {
+				if !placed[node.UID] {
+					cyclic[node.UID] = true
+					queue = append(queue, node)
+					break
+				}
+			}
+		}
+		node := queue[0]
+		queue = queue[1:]
+		if placed[node.UID] {
+			continue
+		}
+		ready := place(node)
+		sort.Slice(ready, func(i, j int) bool { return topologyLess(ready[i], ready[j]) })
+		queue = append(queue, ready...)
+	}
+
+	resp := ReleaseTopologyResponse{
+		Release: release,
+		Nodes:   make([]TopologyNode, 0, len(order)),
+		Edges:   graphResp.Edges,
+	}
+	for _, node := range order {
+		nodeParents := parents[node.UID]
+		if nodeParents == nil {
+			nodeParents = make([]string, 0)
+		}
+		sort.Strings(nodeParents)
+		resp.Nodes = append(resp.Nodes, TopologyNode{
+			NodeResponse: nodeResponse(node, charts),
+			Level:        level[node.UID],
+			Parents:      nodeParents,
+			Cyclic:       cyclic[node.UID],
+		})
+		resp.Levels = max(resp.Levels, level[node.UID]+1)
+	}
+	// Level by level, keeping the topological order within a level
+	sort.SliceStable(resp.Nodes, func(i, j int) bool { return resp.Nodes[i].Level < resp.Nodes[j].Level })
+	return resp
+}
+
+func topologyLess(a, b *graph.Node) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// handleZones groups the Pods and PersistentVolumes of a release, or of the cluster, by the
+// zone of their Node, with the spread of each Deployment and StatefulSet across zones, to
+// tell whether an application survives the loss of a failure domain
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	g := s.graphFor(r.Context())
+	query := r.URL.Query()
+	release := query.Get("release")
+	namespace := query.Get("namespace")
+
+	nodes := s.graphNodes(g, release, namespace, "")
+	if release != "" && len(nodes) == 0 {
+		writeError(w, http.StatusNotFound, "no resources of release "+release)
+		return
+	}
+	writeJSON(w, buildZones(g, nodes))
+}
+
+// buildZones groups the Pods and PersistentVolumes of nodes by zone. Every zone of the
+// cluster's Nodes is listed, even without resources.
+func buildZones(g graph.GraphInterface, nodes []*graph.Node) ZonesResponse {
+	resp := ZonesResponse{
+		Zones:     make([]TopologyZone, 0),
+		Unzoned:   make([]ZoneResource, 0),
+		Workloads: make([]WorkloadSpread, 0),
+	}
+	zones := make(map[string]*TopologyZone)
+	zoneOf := func(zone, region string) *TopologyZone {
+		group, exists := zones[zone]
+		if !exists {
+			group = &TopologyZone{Zone: zone, Region: region, Nodes: make([]string, 0), Resources: make([]ZoneResource, 0)}
+			zones[zone] = group
+		}
+		return group
+	}
+
+	for _, node := range g.GetNodesByNamespaceKind("", "Node") {
+		if zone, region := nodeZone(node); zone != "" {
+			group := zoneOf(zone, region)
+			group.Nodes = append(group.Nodes, node.Name)
+		}
+	}
+
+	clusterZones := len(zones)
+
+	sorted := append([]*graph.Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return topologyLess(sorted[i], sorted[j]) })
+	for _, node := range sorted {
+		switch node.Kind {
+		case "Pod", "PersistentVolume":
+		case "Deployment", "StatefulSet":
+			if spread, ok := workloadSpread(g, node, clusterZones); ok {
+				resp.Workloads = append(resp.Workloads, spread)
+			}
+			continue
+		default:
+			continue
+		}
+		resource := ZoneResource{
+			UID:       string(node.UID),
+			Kind:      node.Kind,
+			Namespace: node.Namespace,
+			Name:      node.Name,
+			Status:    string(node.Status),
+		}
+		if node.Metadata != nil {
+			resource.NodeName = node.Metadata.NodeName
+		}
+		if zone, region := nodeZone(node); zone != "" {
+			group := zoneOf(zone, region)
+			group.Resources = append(group.Resources, resource)
+		} else {
+			resp.Unzoned = append(resp.Unzoned, resource)
+		}
+	}
+
+	for _, group := range zones {
+		sort.Strings(group.Nodes)
+		resp.Zones = append(resp.Zones, *group)
+	}
+	sort.Slice(resp.Zones, func(i, j int) bool {
+		a, b := resp.Zones[i], resp.Zones[j]
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.Zone < b.Zone
+	})
+	return resp
+}
+
+// nodeZone returns the zone and region of a node, "" when unknown
+func nodeZone(node *graph.Node) (string, string) {
+	if node.Metadata == nil {
+		return "", ""
+	}
+	return node.Metadata.Zone, node.Metadata.Region
+}
+
+// workloadSpread returns the zones the scheduled Pods of a workload run in. It returns false
+// when none of its Pods is scheduled.
+func workloadSpread(g graph.GraphInterface, workload *graph.Node, clusterZones int) (WorkloadSpread, bool) {
+	spread := WorkloadSpread{
+		Kind:      workload.Kind,
+		Namespace: workload.Namespace,
+		Name:      workload.Name,
+		Zones:     make([]string, 0),
+	}
+	zones := make(map[string]bool)
+	for _, descendant := range g.OwnedDescendants(workload.UID) {
+		if descendant.Kind != "Pod" || descendant.Metadata == nil || descendant.Metadata.NodeName == "" {
+			continue
+		}
+		spread.Pods++
+		if zone, _ := nodeZone(descendant); zone != "" && !zones[zone] {
+			zones[zone] = true
+			spread.Zones = append(spread.Zones, zone)
+		}
+	}
+	if spread.Pods == 0 {
+		return WorkloadSpread{}, false
+	}
+	sort.Strings(spread.Zones)
+	spread.SingleZone = spread.Pods >= 2 && len(spread.Zones) == 1 && clusterZones > 1
+	return spread, true
+}
@@ -0,0 +1,264 @@
+// Package audit records every mutation of the graph, with the event that caused it, in an
+// append-only log kept by the persistence backend. The log is periodically checkpointed with
+// the full graph, so the state of the graph at any recorded moment can be rebuilt by replaying
+// the mutations after the last checkpoint before it (see Replay).
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Op is a recorded graph mutation
+type Op string
+
+const (
+	OpSaveNode           Op = "saveNode"
+	OpDeleteNode         Op = "deleteNode"
+	OpSaveEdge           Op = "saveEdge"
+	OpDeleteEdge         Op = "deleteEdge"
+	OpPendingEdge        Op = "pendingEdge"
+	OpReversePendingEdge Op = "reversePendingEdge"
+	OpPendingOwnerEdge   Op = "pendingOwnerEdge"
+	OpFlagStaleEdges     Op = "flagStaleEdges"
+	// OpCheckpoint holds the full graph; replays start from one
+	OpCheckpoint Op = "checkpoint"
+)
+
+// Origins of mutations not caused by an informer event
+const (
+	OriginEvent       = "event"
+	OriginPrune       = "prune"
+	OriginFederation  = "federation"
+	OriginLogSampler  = "log-sampler"
+	OriginWarnings    = "warning-events"
+	OriginEdgeSweeper = "edge-sweeper"
+	OriginJobPruner   = "job-pruner"
+	OriginCheckpoint  = "checkpoint"
+)
+
+// Source is what caused a mutation: the subsystem, and for informer events the kind and node
+// ID of the object and the event type
+type Source struct {
+	Origin string    `json:"origin"`
+	Kind   string    `json:"kind,omitempty"`
+	UID    types.UID `json:"uid,omitempty"`
+	Event  string    `json:"event,omitempty"`
+}
+
+// Entry is one recorded mutation. Only the fields of its operation are set.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Op     Op        `json:"op"`
+	Source Source    `json:"source"`
+
+	// Node is the saved node, without edges
+	Node *graph.Node `json:"node,omitempty"`
+	Edge *graph.Edge `json:"edge,omitempty"`
+	// UID is the deleted node, or the node an edge or pending edge starts (or ends) at
+	UID   types.UID `json:"uid,omitempty"`
+	ToUID types.UID `json:"toUID,omitempty"`
+	// Ref, EdgeType and Metadata describe a pending edge
+	Ref      *graph.RefKey     `json:"ref,omitempty"`
+	EdgeType graph.EdgeType    `json:"edgeType,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Cutoff is the cutoff of flagged stale edges
+	Cutoff *time.Time `json:"cutoff,omitempty"`
+
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Checkpoint is the full graph at the time of a checkpoint entry
+type Checkpoint struct {
+	Nodes []*graph.Node `json:"nodes"`
+	Edges []*graph.Edge `json:"edges"`
+}
+
+// Record is an encoded entry handed to the store
+type Record struct {
+	Time       time.Time
+	Checkpoint bool
+	Data       []byte
+}
+
+// Store keeps the log. Records are appended in order and ranged by the time they were appended.
+type Store interface {
+	// AppendAudit appends records in order
+	AppendAudit(records []Record) error
+	// AuditCheckpoint returns the ID of the last checkpoint appended at or before at, or ""
+	AuditCheckpoint(at time.Time) (string, error)
+	// ReadAudit calls fn with the records from the one with ID from ("" for the first) up to
+	// the ones appended at to
+	ReadAudit(from string, to time.Time, fn func(id string, data []byte) error) error
+	// TrimAudit removes the records that are no longer needed to replay any moment after before
+	TrimAudit(before time.Time) error
+}
+
+// Options configures the log
+type Options struct {
+	// CheckpointInterval is how often the full graph is recorded (default 1h)
+	CheckpointInterval time.Duration
+	// Retention is how long mutations stay replayable; 0 keeps them forever
+	Retention time.Duration
+	// FlushInterval is the maximum time recorded mutations wait before being stored (default 1s)
+	FlushInterval time.Duration
+	// BatchSize is the number of recorded mutations that triggers a write (default 500)
+	BatchSize int
+}
+
+// DefaultOptions returns the default log options
+func DefaultOptions() Options {
+	return Options{
+		CheckpointInterval: time.Hour,
+		FlushInterval:      time.Second,
+		BatchSize:          500,
+	}
+}
+
+// Log records graph mutations made through its Graph wrappers
+type Log struct {
+	store   Store
+	graph   graph.GraphInterface
+	opts    Options
+	records chan Record
+	done    chan struct{}
+	stop    sync.Once
+}
+
+// NewLog creates a log of the mutations of g, kept in store
+func NewLog(store Store, g graph.GraphInterface, opts Options) *Log {
+	defaults := DefaultOptions()
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = defaults.CheckpointInterval
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaults.FlushInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaults.BatchSize
+	}
+	return &Log{
+		store:   store,
+		graph:   g,
+		opts:    opts,
+		records: make(chan Record, 10*opts.BatchSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Graph returns a wrapper of the graph recording the mutations made through it with origin
+func (l *Log) Graph(g graph.GraphInterface, origin string) *Graph {
+	return &Graph{GraphInterface: g, log: l, source: Source{Origin: origin}}
+}
+
+// record encodes an entry and queues it. It blocks while the queue is full, so mutations are
+// never left out of the log, unless the log was stopped.
+func (l *Log) record(entry Entry) {
+	entry.Time = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		klog.Errorf("Failed to encode %s audit entry: %v", entry.Op, err)
+		metrics.AuditEntries.WithLabelValues("failed").Inc()
+		return
+	}
+	select {
+	case l.records <- Record{Time: entry.Time, Data: data}:
+	case <-l.done:
+		metrics.AuditEntries.WithLabelValues("failed").Inc()
+	}
+}
+
+// Start records a checkpoint, then stores queued mutations in batches and records a
+// checkpoint every CheckpointInterval until ctx is cancelled
+func (l *Log) Start(ctx context.Context) error {
+	flush := time.NewTicker(l.opts.FlushInterval)
+	defer flush.Stop()
+	checkpoint := time.NewTicker(l.opts.CheckpointInterval)
+	defer checkpoint.Stop()
+
+	batch := make([]Record, 0, l.opts.BatchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.store.AppendAudit(batch); err != nil {
+			klog.Errorf("Failed to store %d audit entries: %v", len(batch), err)
+			metrics.AuditEntries.WithLabelValues("failed").Add(float64(len(batch)))
+		} else {
+			metrics.AuditEntries.WithLabelValues("recorded").Add(float64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	// Mutations dequeued so far happened before the checkpoint is taken; the ones still
+	// queued may have happened before too, and are replayed after it, which is harmless
+	// as every entry holds the full state it writes
+	takeCheckpoint := func() {
+		write()
+		if err := l.checkpoint(); err != nil {
+			klog.Errorf("Failed to record audit checkpoint: %v", err)
+		}
+	}
+
+	takeCheckpoint()
+	for {
+		select {
+		case record := <-l.records:
+			batch = append(batch, record)
+			if len(batch) >= l.opts.BatchSize {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case <-checkpoint.C:
+			takeCheckpoint()
+		case <-ctx.Done():
+			l.stop.Do(func() { close(l.done) })
+			for {
+				select {
+				case record := <-l.records:
+					batch = append(batch, record)
+				default:
+					write()
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// checkpoint records the full graph and trims what is past retention
+func (l *Log) checkpoint() error {
+	start := time.Now()
+	clone := l.graph.Clone()
+	cp := &Checkpoint{Nodes: clone.GetAllNodes(), Edges: make([]*graph.Edge, 0)}
+	for _, node := range cp.Nodes {
+		for _, edge := range node.OutgoingEdges {
+			cp.Edges = append(cp.Edges, edge)
+		}
+	}
+
+	entry := Entry{Time: time.Now(), Op: OpCheckpoint, Source: Source{Origin: OriginCheckpoint}, Checkpoint: cp}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := l.store.AppendAudit([]Record{{Time: entry.Time, Checkpoint: true, Data: data}}); err != nil {
+		return err
+	}
+	klog.V(2).Infof("Recorded audit checkpoint of %d nodes and %d edges in %v", len(cp.Nodes), len(cp.Edges), time.Since(start))
+
+	if l.opts.Retention > 0 {
+		if err := l.store.TrimAudit(time.Now().Add(-l.opts.Retention)); err != nil {
+			return fmt.Errorf("failed to trim audit log: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Graph wraps a graph and records the mutations made through it. Each subsystem gets its own
+// wrapper, so the source set by the event worker is never attributed to other writers.
+type Graph struct {
+	graph.GraphInterface
+	log *Log
+
+	mu     sync.Mutex
+	source Source
+}
+
+// SetEvent attributes the following mutations to an informer event, until ClearEvent
+func (g *Graph) SetEvent(kind string, uid types.UID, event string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.source.Kind, g.source.UID, g.source.Event = kind, uid, event
+}
+
+// ClearEvent ends the attribution of mutations to an event
+func (g *Graph) ClearEvent() {
+	g.SetEvent("", "", "")
+}
+
+func (g *Graph) record(entry Entry) {
+	g.mu.Lock()
+	entry.Source = g.source
+	g.mu.Unlock()
+	g.log.record(entry)
+}
+
+func (g *Graph) AddNode(node *graph.Node) {
+	g.GraphInterface.AddNode(node)
+	g.record(Entry{Op: OpSaveNode, Node: node})
+}
+
+func (g *Graph) UpdateNode(uid types.UID, update func(node *graph.Node) bool) (*graph.Node, bool) {
+	node, updated := g.GraphInterface.UpdateNode(uid, update)
+	if updated {
+		g.record(Entry{Op: OpSaveNode, Node: node})
+	}
+	return node, updated
+}
+
+func (g *Graph) RemoveNode(uid types.UID) {
+	g.GraphInterface.RemoveNode(uid)
+	g.record(Entry{Op: OpDeleteNode, UID: uid})
+}
+
+func (g *Graph) AddEdge(edge *graph.Edge) bool {
+	if !g.GraphInterface.AddEdge(edge) {
+		return false
+	}
+	g.record(Entry{Op: OpSaveEdge, Edge: edge})
+	return true
+}
+
+func (g *Graph) RemoveEdge(fromUID, toUID types.UID) {
+	g.GraphInterface.RemoveEdge(fromUID, toUID)
+	g.record(Entry{Op: OpDeleteEdge, UID: fromUID, ToUID: toUID})
+}
+
+func (g *Graph) FlagStaleEdges(cutoff time.Time) int {
+	flagged := g.GraphInterface.FlagStaleEdges(cutoff)
+	if flagged > 0 {
+		g.record(Entry{Op: OpFlagStaleEdges, Cutoff: &cutoff})
+	}
+	return flagged
+}
+
+func (g *Graph) AddPendingEdge(fromUID types.UID, targetRef graph.RefKey, edgeType graph.EdgeType, metadata map[string]string) {
+	g.GraphInterface.AddPendingEdge(fromUID, targetRef, edgeType, metadata)
+	g.record(Entry{Op: OpPendingEdge, UID: fromUID, Ref: &targetRef, EdgeType: edgeType, Metadata: metadata})
+}
+
+func (g *Graph) AddReversePendingEdge(toUID types.UID, sourceRef graph.RefKey, edgeType graph.EdgeType) {
+	g.GraphInterface.AddReversePendingEdge(toUID, sourceRef, edgeType)
+	g.record(Entry{Op: OpReversePendingEdge, ToUID: toUID, Ref: &sourceRef, EdgeType: edgeType})
+}
+
+func (g *Graph) AddPendingOwnerEdge(childUID, ownerUID types.UID, ownerRef graph.RefKey) {
+	g.GraphInterface.AddPendingOwnerEdge(childUID, ownerUID, ownerRef)
+	g.record(Entry{Op: OpPendingOwnerEdge, UID: childUID, ToUID: ownerUID, Ref: &ownerRef})
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the structure of the optional YAML configuration file (--config).
+// Command-line flags that are set explicitly take precedence over file values.
+type Config struct {
+	// Flags sets command-line flags by name, e.g. label-selector or enable-persistence. Lists
+	// are joined with commas.
+	Flags map[string]interface{} `json:"flags,omitempty"`
+	// WatchKinds limits the watched resource kinds (empty = all supported kinds)
+	WatchKinds []string `json:"watchKinds,omitempty"`
+	// ExcludeKinds disables informers for these kinds
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
+	// LabelSelectors refine the label selector by kind and exclude labeled objects
+	LabelSelectors LabelSelectors `json:"labelSelectors,omitempty"`
+	// ComputedFields adds site-specific metadata fields derived from raw objects
+	ComputedFields []ComputedField `json:"computedFields,omitempty"`
+	// Status overrides how resource statuses are computed
+	Status Status `json:"status,omitempty"`
+	// Notifications posts release resource status transitions to webhooks
+	Notifications Notifications `json:"notifications,omitempty"`
+	// Applications configures how resources are grouped into applications
+	Applications Applications `json:"applications,omitempty"`
+	// Federation merges the graphs of per-cluster Astrolabe instances into this one
+	Federation Federation `json:"federation,omitempty"`
+	// Tenancy scopes API access to namespaces and Helm releases with API tokens
+	Tenancy Tenancy `json:"tenancy,omitempty"`
+	// Deprecations adds to, or overrides, the built-in table of deprecated API versions
+	Deprecations []DeprecatedAPI `json:"deprecations,omitempty"`
+	// Anomalies sets the thresholds from which Pods are flagged for restarting or flapping
+	// within the --anomaly-window
+	Anomalies Anomalies `json:"anomalies,omitempty"`
+	// CustomResources are custom resource kinds watched without a dedicated processor, whose
+	// edges are inferred from their owner references, managed-by label and spec
+	CustomResources []CustomResource `json:"customResources,omitempty"`
+}
+
+// LabelSelectors select the watched objects by label, on top of the label-selector flag
+type LabelSelectors struct {
+	// Kinds replace the label selector for these kinds; "" watches all their objects, e.g.
+	// the Nodes, which do not carry the labels of applications
+	Kinds map[string]string `json:"kinds,omitempty"`
+	// Exclude lists selectors of objects not watched, whatever their kind, e.g.
+	// astrolabe.io/ignore=true. Each has a single requirement.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// CustomResource is a custom resource watched with dynamic informers, e.g. the Clusters of
+// postgresql.cnpg.io/v1
+type CustomResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	// Kind is the kind of the resource, e.g. Cluster, which must not be a supported kind
+	Kind string `json:"kind"`
+	// ClusterScoped is set for resources without a namespace
+	ClusterScoped bool `json:"clusterScoped,omitempty"`
+	// References lists the paths of references the inference misses
+	References []CustomReference `json:"references,omitempty"`
+}
+
+// CustomReference locates references of a custom resource to other objects
+type CustomReference struct {
+	// Path is the dot-separated path of the references, e.g. spec.backup.credentials; lists on
+	// the path are traversed. A reference is an object name, or an object with name and
+	// optionally namespace and kind fields.
+	Path string `json:"path"`
+	// Kind is the kind of the referenced objects, unless the references name it
+	Kind string `json:"kind,omitempty"`
+}
+
+// Anomalies sets the number of container restarts and Pod status changes within the window
+// from which a Pod is flagged. Unset thresholds use the defaults (3 restarts, 6 status
+// changes); a negative threshold never flags.
+type Anomalies struct {
+	Restarts int `json:"restarts,omitempty"`
+	Flaps    int `json:"flaps,omitempty"`
+	// Namespaces override the thresholds of the namespaces they match, first match wins
+	Namespaces []NamespaceAnomalies `json:"namespaces,omitempty"`
+}
+
+// NamespaceAnomalies overrides the anomaly thresholds of namespaces; unset thresholds are
+// inherited
+type NamespaceAnomalies struct {
+	// Namespace is a path.Match pattern, e.g. dev-*
+	Namespace string `json:"namespace"`
+	Restarts  int    `json:"restarts,omitempty"`
+	Flaps     int    `json:"flaps,omitempty"`
+}
+
+// DeprecatedAPI is an API version of a kind deprecated, or removed, in a Kubernetes version
+type DeprecatedAPI struct {
+	// APIVersion is the group/version, e.g. policy/v1beta1
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// DeprecatedIn and RemovedIn are Kubernetes minor versions, e.g. "1.21"
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+	// Replacement is the API version to migrate to
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Tenancy lists the API tokens. When any is configured, every API request needs one.
+type Tenancy struct {
+	Tokens []APIToken `json:"tokens,omitempty"`
+	// Anonymous serves the aggregate endpoints (summary and namespaces) to requests without a
+	// token, e.g. for a status page; every other endpoint still needs one
+	Anonymous bool `json:"anonymous,omitempty"`
+}
+
+// APIToken binds a bearer token to the namespaces and Helm releases it may read
+type APIToken struct {
+	Name string `json:"name"`
+	// TokenFile holds the token, e.g. a mounted Secret; Token sets it inline instead
+	TokenFile string `json:"tokenFile,omitempty"`
+	Token     string `json:"token,omitempty"`
+	// Namespaces and Releases are path.Match patterns; a token without either reads everything
+	Namespaces []string `json:"namespaces,omitempty"`
+	Releases   []string `json:"releases,omitempty"`
+}
+
+// Federation lists the Astrolabe instances whose graphs are aggregated
+type Federation struct {
+	Clusters []FederatedCluster `json:"clusters,omitempty"`
+}
+
+// FederatedCluster is an Astrolabe instance serving the gRPC API (--grpc-port) of a cluster
+type FederatedCluster struct {
+	// Name prefixes the UIDs, namespaces and releases of the cluster's resources
+	Name string `json:"name"`
+	// Address is the host:port of the instance's gRPC API
+	Address string `json:"address"`
+	// CAFile enables TLS, verifying the instance's certificate against this CA bundle
+	CAFile string `json:"caFile,omitempty"`
+	// TokenFile holds the API token sent to the instance when it scopes API access
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// Applications configures the application groupers
+type Applications struct {
+	// Groupers lists built-in groupers by name (helm, argocd, part-of, kustomize) or custom
+	// label/annotation groupers. Unset means all built-in groupers; helm is always enabled.
+	Groupers []Grouper `json:"groupers,omitempty"`
+}
+
+// Grouper is a built-in grouper (name only) or a custom one keyed by a label or annotation
+type Grouper struct {
+	Name       string `json:"name"`
+	Label      string `json:"label,omitempty"`
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// Notifications configures status change webhooks
+type Notifications struct {
+	// Debounce is how long a transition must persist before it is sent (default 30s)
+	Debounce *metav1.Duration `json:"debounce,omitempty"`
+	Webhooks []Webhook        `json:"webhooks,omitempty"`
+}
+
+// Webhook is a notification target and the releases routed to it
+type Webhook struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Format     string   `json:"format,omitempty"`
+	Releases   []string `json:"releases,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// ComputedField maps a JSONPath expression over objects of a kind to a named metadata field
+type ComputedField struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	JSONPath string `json:"jsonPath"`
+}
+
+// Status configures the status evaluation of resources
+type Status struct {
+	// KStatus lists kinds (or patterns such as *) evaluated with the kstatus conventions
+	// instead of the built-in logic
+	KStatus []string `json:"kstatus,omitempty"`
+	// Rules set the status of objects of a kind when their expression matches; the first
+	// matching rule of a kind wins
+	Rules []StatusRule `json:"rules,omitempty"`
+}
+
+// StatusRule sets the status, reason and message of objects whose JSONPath expression
+// produces Equals (or any non-empty value when Equals is unset)
+type StatusRule struct {
+	Kind     string `json:"kind"`
+	JSONPath string `json:"jsonPath"`
+	Equals   string `json:"equals,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// FlagValues returns the values of Flags as they would be given on the command line, by flag
+// name
+func (c *Config) FlagValues() (map[string]string, error) {
+	values := make(map[string]string, len(c.Flags))
+	for name, raw := range c.Flags {
+		value, err := flagValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of flag %s: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// flagValue formats a YAML scalar, or a list of scalars, as a flag value
+func flagValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, isList := item.([]interface{}); isList {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			formatted, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("expected a string, number, boolean or list, got %T", value)
+	}
+}
+
+// Load reads a configuration file. An empty path returns an empty configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
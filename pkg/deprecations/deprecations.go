@@ -0,0 +1,221 @@
+// Package deprecations flags resources applied with API versions that are deprecated or
+// removed in a Kubernetes version, from a built-in table of the deprecations of the upstream
+// Kubernetes APIs that can be extended or overridden from the config file.
+package deprecations
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// API is an API version of a kind deprecated in a Kubernetes version, and removed in a later one
+type API struct {
+	APIVersion string
+	Kind       string
+	// DeprecatedIn and RemovedIn are Kubernetes minor versions, e.g. 1.21; RemovedIn is empty
+	// while no removal is planned
+	DeprecatedIn string
+	RemovedIn    string
+	// Replacement is the API version to migrate to, empty when the kind has none
+	Replacement string
+}
+
+// builtin lists the deprecated APIs of upstream Kubernetes, following
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var builtin = []API{
+	// Removed in 1.16
+	{"extensions/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.11", "1.16", "policy/v1beta1"},
+	{"apps/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+
+	// Removed in 1.22
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"extensions/v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", "1.19", "1.22", "storage.k8s.io/v1"},
+
+	// Removed in 1.25
+	{"batch/v1beta1", "CronJob", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", "1.22", "1.25", "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.21", "1.25", ""},
+	{"node.k8s.io/v1beta1", "RuntimeClass", "1.22", "1.25", "node.k8s.io/v1"},
+
+	// Removed in 1.26
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2"},
+
+	// Removed in 1.27
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1"},
+
+	// Removed in 1.29
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+
+	// Removed in 1.32
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// Version is a Kubernetes minor version
+type Version struct {
+	Major, Minor int
+}
+
+// ParseVersion parses a Kubernetes version such as 1.29, v1.29.3 or v1.27.8-eks-8cb36c9
+func ParseVersion(value string) (Version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, "v"), ".", 3)
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q", value)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q", value)
+	}
+	// Managed distributions add suffixes to the minor version, e.g. 27+
+	minor, err := strconv.Atoi(strings.TrimRight(strings.SplitN(parts[1], "-", 2)[0], "+"))
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q", value)
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// AtLeast reports whether v is the same as or later than other
+func (v Version) AtLeast(other Version) bool {
+	return v.Major > other.Major || (v.Major == other.Major && v.Minor >= other.Minor)
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+type key struct {
+	apiVersion, kind string
+}
+
+type entry struct {
+	api                       API
+	deprecated, removed       Version
+	hasDeprecated, hasRemoved bool
+}
+
+// Table looks up the deprecation of API versions, as of the Kubernetes version it checks
+// against
+type Table struct {
+	version Version
+	apis    map[key]entry
+}
+
+// NewTable creates a table of the built-in deprecations and apis, which override built-in
+// entries of the same API version and kind, checked against a Kubernetes version
+func NewTable(version Version, apis []API) (*Table, error) {
+	t := &Table{version: version, apis: make(map[key]entry, len(builtin)+len(apis))}
+	for _, api := range append(append([]API{}, builtin...), apis...) {
+		if api.APIVersion == "" || api.Kind == "" {
+			return nil, fmt.Errorf("deprecated API requires apiVersion and kind: %+v", api)
+		}
+		e := entry{api: api}
+		if api.DeprecatedIn != "" {
+			v, err := ParseVersion(api.DeprecatedIn)
+			if err != nil {
+				return nil, fmt.Errorf("deprecated API %s %s: %w", api.APIVersion, api.Kind, err)
+			}
+			e.deprecated, e.hasDeprecated = v, true
+		}
+		if api.RemovedIn != "" {
+			v, err := ParseVersion(api.RemovedIn)
+			if err != nil {
+				return nil, fmt.Errorf("deprecated API %s %s: %w", api.APIVersion, api.Kind, err)
+			}
+			e.removed, e.hasRemoved = v, true
+		}
+		if !e.hasDeprecated && !e.hasRemoved {
+			return nil, fmt.Errorf("deprecated API %s %s requires deprecatedIn or removedIn", api.APIVersion, api.Kind)
+		}
+		t.apis[key{api.APIVersion, api.Kind}] = e
+	}
+	return t, nil
+}
+
+// Version returns the Kubernetes version the table checks against
+func (t *Table) Version() Version {
+	return t.version
+}
+
+// Check returns the deprecation of an API version of a kind, or nil when it is not
+// deprecated in the table's Kubernetes version
+func (t *Table) Check(apiVersion, kind string) *graph.APIDeprecation {
+	e, exists := t.apis[key{apiVersion, kind}]
+	if !exists {
+		return nil
+	}
+	removed := e.hasRemoved && t.version.AtLeast(e.removed)
+	if !removed && !(e.hasDeprecated && t.version.AtLeast(e.deprecated)) {
+		return nil
+	}
+	return &graph.APIDeprecation{
+		APIVersion:   apiVersion,
+		DeprecatedIn: e.api.DeprecatedIn,
+		RemovedIn:    e.api.RemovedIn,
+		Replacement:  e.api.Replacement,
+		Removed:      removed,
+	}
+}
+
+// CheckNode returns the deprecation of the API version a resource was applied with: the one
+// in its kubectl.kubernetes.io/last-applied-configuration annotation, else the one it is
+// watched with
+func (t *Table) CheckNode(node *graph.Node) *graph.APIDeprecation {
+	if applied := node.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; applied != "" {
+		var object struct {
+			APIVersion string `json:"apiVersion"`
+		}
+		if err := json.Unmarshal([]byte(applied), &object); err == nil && object.APIVersion != "" {
+			if deprecation := t.Check(object.APIVersion, node.Kind); deprecation != nil {
+				return deprecation
+			}
+		}
+	}
+	return t.Check(node.APIVersion, node.Kind)
+}
+
+// Enrich sets the deprecation of the API version a resource was applied with on its node
+func (t *Table) Enrich(node *graph.Node, _ interface{}) {
+	deprecation := t.CheckNode(node)
+	if deprecation == nil {
+		return
+	}
+	if node.Metadata == nil {
+		node.Metadata = &graph.ResourceMetadata{}
+	}
+	node.Metadata.Deprecation = deprecation
+}
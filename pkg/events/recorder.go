@@ -0,0 +1,289 @@
+// Package events correlates Kubernetes Warning Events with the graph: it watches core/v1
+// Events and attaches the recent warnings about a resource to its node, so the graph tells
+// why a resource is failing or Pending (FailedScheduling, FailedMount, BackOff...).
+package events
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Options configures the event recorder
+type Options struct {
+	// MaxPerResource is the number of recent events kept per resource
+	MaxPerResource int
+	// MaxAge is how long events stay attached after they were last seen
+	MaxAge time.Duration
+	// FlushInterval is how often changed event lists are written to the nodes
+	FlushInterval time.Duration
+	// Namespaces limits the watched Events (empty = all namespaces)
+	Namespaces []string
+}
+
+// DefaultOptions returns the default recorder options
+func DefaultOptions() Options {
+	return Options{
+		MaxPerResource: 10,
+		MaxAge:         time.Hour,
+		FlushInterval:  time.Second,
+	}
+}
+
+// Recorder keeps the recent Warning Events of each resource and attaches them to its node
+type Recorder struct {
+	client kubernetes.Interface
+	graph  graph.GraphInterface
+	opts   Options
+
+	mu sync.Mutex
+	// events holds the events of each node ID by event object (namespace/name)
+	events map[types.UID]map[string]graph.WarningEvent
+	dirty  map[types.UID]bool
+}
+
+// NewRecorder creates a recorder attaching the Warning Events of the cluster to the nodes of g
+func NewRecorder(client kubernetes.Interface, g graph.GraphInterface, opts Options) *Recorder {
+	defaults := DefaultOptions()
+	if opts.MaxPerResource <= 0 {
+		opts.MaxPerResource = defaults.MaxPerResource
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaults.MaxAge
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaults.FlushInterval
+	}
+	return &Recorder{
+		client: client,
+		graph:  g,
+		opts:   opts,
+		events: make(map[types.UID]map[string]graph.WarningEvent),
+		dirty:  make(map[types.UID]bool),
+	}
+}
+
+// Start watches Warning Events and writes changed event lists to the nodes until ctx is
+// cancelled
+func (r *Recorder) Start(ctx context.Context) {
+	namespaces := r.opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(r.client, 0,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = "type=" + corev1.EventTypeWarning
+			}))
+		informer := factory.Core().V1().Events().Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    r.observe,
+			UpdateFunc: func(_, obj interface{}) { r.observe(obj) },
+		})
+		factory.Start(ctx.Done())
+	}
+
+	flush := time.NewTicker(r.opts.FlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			r.flush()
+		case <-prune.C:
+			r.prune()
+		}
+	}
+}
+
+// observe records a Warning Event against the node of its involved object. Deleted Events
+// are kept until MaxAge, as Kubernetes garbage collects them after an hour by default.
+func (r *Recorder) observe(obj interface{}) {
+	event, ok := obj.(*corev1.Event)
+	if !ok || event.Type != corev1.EventTypeWarning {
+		return
+	}
+	warning := warningEvent(event)
+	if time.Since(warning.LastSeen) > r.opts.MaxAge {
+		return
+	}
+
+	involved := event.InvolvedObject
+	uid := graph.ObjectID(involved.UID, involved.Namespace, involved.Kind, involved.Name)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events, exists := r.events[uid]
+	if !exists {
+		events = make(map[string]graph.WarningEvent)
+		r.events[uid] = events
+	}
+	events[event.Namespace+"/"+event.Name] = warning
+	r.dirty[uid] = true
+
+	// Keep noisy resources bounded: only the newest MaxPerResource events are ever served
+	if len(events) > 2*r.opts.MaxPerResource {
+		kept := make(map[string]bool, r.opts.MaxPerResource)
+		for _, name := range newestNames(events, r.opts.MaxPerResource) {
+			kept[name] = true
+		}
+		for name := range events {
+			if !kept[name] {
+				delete(events, name)
+			}
+		}
+	}
+}
+
+// newestNames returns the names of the n most recently seen events
+func newestNames(events map[string]graph.WarningEvent, n int) []string {
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return events[names[i]].LastSeen.After(events[names[j]].LastSeen) })
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// warningEvent converts an Event, reading the series of events recorded with the
+// events.k8s.io API when the deprecated count and timestamps are unset
+func warningEvent(event *corev1.Event) graph.WarningEvent {
+	warning := graph.WarningEvent{
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     event.Count,
+		FirstSeen: event.FirstTimestamp.Time,
+		LastSeen:  event.LastTimestamp.Time,
+		Source:    event.Source.Component,
+	}
+	if warning.Source == "" {
+		warning.Source = event.ReportingController
+	}
+	if warning.FirstSeen.IsZero() {
+		warning.FirstSeen = event.EventTime.Time
+	}
+	if series := event.Series; series != nil {
+		if warning.Count == 0 {
+			warning.Count = series.Count
+		}
+		if warning.LastSeen.IsZero() {
+			warning.LastSeen = series.LastObservedTime.Time
+		}
+	}
+	if warning.FirstSeen.IsZero() {
+		warning.FirstSeen = event.CreationTimestamp.Time
+	}
+	if warning.LastSeen.IsZero() {
+		warning.LastSeen = warning.FirstSeen
+	}
+	if warning.Count == 0 {
+		warning.Count = 1
+	}
+	return warning
+}
+
+// Events returns the recent Warning Events of a node, newest first
+func (r *Recorder) Events(uid types.UID) []graph.WarningEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recent(uid)
+}
+
+// recent returns the kept events of a node, newest first. Must be called with the lock held.
+func (r *Recorder) recent(uid types.UID) []graph.WarningEvent {
+	events := r.events[uid]
+	if len(events) == 0 {
+		return nil
+	}
+	result := make([]graph.WarningEvent, 0, len(events))
+	for _, event := range events {
+		result = append(result, event)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].Reason < result[j].Reason
+	})
+	if len(result) > r.opts.MaxPerResource {
+		result = result[:r.opts.MaxPerResource]
+	}
+	return result
+}
+
+// Enrich attaches the recent Warning Events of a resource to its node when it is updated
+func (r *Recorder) Enrich(node *graph.Node, _ interface{}) {
+	events := r.Events(node.UID)
+	if len(events) == 0 {
+		return
+	}
+	if node.Metadata == nil {
+		node.Metadata = &graph.ResourceMetadata{}
+	}
+	node.Metadata.Events = events
+}
+
+// flush writes the event lists that changed since the last flush to their nodes, without
+// waiting for the next update of the resources
+func (r *Recorder) flush() {
+	r.mu.Lock()
+	changed := make(map[types.UID][]graph.WarningEvent, len(r.dirty))
+	for uid := range r.dirty {
+		changed[uid] = r.recent(uid)
+	}
+	r.dirty = make(map[types.UID]bool)
+	r.mu.Unlock()
+
+	for uid, events := range changed {
+		r.graph.UpdateNode(uid, func(node *graph.Node) bool {
+			var current []graph.WarningEvent
+			if node.Metadata != nil {
+				current = node.Metadata.Events
+			}
+			if reflect.DeepEqual(current, events) {
+				return false
+			}
+			if node.Metadata == nil {
+				node.Metadata = &graph.ResourceMetadata{}
+			}
+			node.Metadata.Events = events
+			return true
+		})
+	}
+}
+
+// prune forgets events last seen more than MaxAge ago
+func (r *Recorder) prune() {
+	cutoff := time.Now().Add(-r.opts.MaxAge)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for uid, events := range r.events {
+		for name, event := range events {
+			if event.LastSeen.Before(cutoff) {
+				delete(events, name)
+				r.dirty[uid] = true
+			}
+		}
+		if len(events) == 0 {
+			delete(r.events, uid)
+		}
+	}
+	klog.V(3).Infof("Tracking Warning Events of %d resources", len(r.events))
+}
@@ -0,0 +1,337 @@
+// Package export mirrors the graph into an external graph database, such as Neo4j or
+// JanusGraph, so teams can run graph analytics and ad-hoc queries on the cluster topology
+// outside of Astrolabe. Nodes are upserted by UID with their kind as label, and edges by their
+// endpoints with their type as label. Only changes are written once the first full pass is done.
+package export
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+// Supported graph databases
+const (
+	BackendNeo4j      = "neo4j"
+	BackendJanusGraph = "janusgraph"
+)
+
+// Options configures the exporter
+type Options struct {
+	// Backend is the graph database, neo4j or janusgraph
+	Backend string
+	// URL is the HTTP endpoint of the database: the Neo4j server (e.g. http://neo4j:7474) or the
+	// Gremlin Server of JanusGraph (e.g. http://janusgraph:8182)
+	URL string
+	// Database is the Neo4j database written to
+	Database string
+	Username string
+	Password string
+	// Source tags every exported node and edge, so instances of several clusters can share a
+	// database without removing each other's resources
+	Source string
+	// BatchSize is the number of nodes or edges written in one request
+	BatchSize int
+	// Interval is how often the graph is checked for changes
+	Interval time.Duration
+	// Timeout of a single request to the database
+	Timeout time.Duration
+}
+
+// DefaultOptions returns the default exporter options
+func DefaultOptions() Options {
+	return Options{
+		Database:  "neo4j",
+		BatchSize: 500,
+		Interval:  30 * time.Second,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// NodeRecord is a node as written to the graph database
+type NodeRecord struct {
+	UID   string `json:"uid"`
+	Label string `json:"label"`
+	// Properties are flat scalar values, as graph databases do not store nested maps
+	Properties map[string]interface{} `json:"props"`
+}
+
+// EdgeRecord is an edge as written to the graph database
+type EdgeRecord struct {
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Label      string                 `json:"label"`
+	Properties map[string]interface{} `json:"props"`
+}
+
+// Sink writes records to a graph database. Writes must be idempotent: a batch is written
+// again when a later batch of the same pass fails.
+type Sink interface {
+	// Init prepares the database, e.g. creates the index on UIDs
+	Init(ctx context.Context) error
+	UpsertNodes(ctx context.Context, nodes []NodeRecord) error
+	UpsertEdges(ctx context.Context, edges []EdgeRecord) error
+	DeleteEdges(ctx context.Context, edges []EdgeRecord) error
+	// DeleteNodes removes nodes by UID along with their edges
+	DeleteNodes(ctx context.Context, uids []string) error
+	// Prune removes the nodes and edges of source not written by the pass syncID, left over
+	// from before a restart
+	Prune(ctx context.Context, source, syncID string) error
+}
+
+// NewSink creates the sink of the configured backend
+func NewSink(opts Options) (Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("graph export requires the URL of the database")
+	}
+	switch opts.Backend {
+	case BackendNeo4j:
+		return newNeo4jSink(opts), nil
+	case BackendJanusGraph:
+		return newJanusGraphSink(opts), nil
+	default:
+		return nil, fmt.Errorf("unsupported graph export backend %q (must be %s or %s)", opts.Backend, BackendNeo4j, BackendJanusGraph)
+	}
+}
+
+// edgeKey identifies an edge; the graph holds at most one edge between two nodes
+type edgeKey struct {
+	from, to string
+}
+
+// exportState is the graph as last written to the database
+type exportState struct {
+	nodes map[string]NodeRecord
+	edges map[edgeKey]EdgeRecord
+}
+
+// Exporter mirrors the graph into a sink
+type Exporter struct {
+	graph graph.GraphInterface
+	sink  Sink
+	opts  Options
+	// syncID tags the records written by this process, so a full pass can prune what an
+	// earlier process exported for resources deleted in the meantime
+	syncID string
+
+	last       *exportState
+	generation uint64
+}
+
+// NewExporter creates an exporter writing g to sink
+func NewExporter(g graph.GraphInterface, sink Sink, opts Options) *Exporter {
+	defaults := DefaultOptions()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaults.BatchSize
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	return &Exporter{
+		graph:  g,
+		sink:   sink,
+		opts:   opts,
+		syncID: strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// Start exports the graph, then its changes on every tick the graph changed, until ctx is
+// cancelled. A failed pass is retried on the next tick.
+func (e *Exporter) Start(ctx context.Context) error {
+	if err := e.sink.Init(ctx); err != nil {
+		return fmt.Errorf("failed to prepare the graph database: %w", err)
+	}
+
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if e.last == nil || e.graph.Generation() != e.generation {
+			if err := e.sync(ctx); err != nil {
+				metrics.GraphExportSyncs.WithLabelValues("error").Inc()
+				klog.Errorf("Graph export failed: %v", err)
+			} else {
+				metrics.GraphExportSyncs.WithLabelValues("success").Inc()
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sync writes the changes since the last successful pass, or the whole graph on the first
+func (e *Exporter) sync(ctx context.Context) error {
+	generation := e.graph.Generation()
+	current := e.snapshot()
+	initial := e.last == nil
+	previous := e.last
+	if initial {
+		previous = &exportState{nodes: map[string]NodeRecord{}, edges: map[edgeKey]EdgeRecord{}}
+	}
+
+	// The type of an edge is its label in the database, so an edge whose type changed is
+	// deleted and written again
+	var staleEdges, changedEdges []EdgeRecord
+	for key, edge := range previous.edges {
+		if now, exists := current.edges[key]; !exists || now.Label != edge.Label {
+			staleEdges = append(staleEdges, edge)
+		}
+	}
+	for key, edge := range current.edges {
+		if old, exists := previous.edges[key]; !exists || !sameProperties(old.Properties, edge.Properties) {
+			changedEdges = append(changedEdges, edge)
+		}
+	}
+	var deletedNodes []string
+	for uid := range previous.nodes {
+		if _, exists := current.nodes[uid]; !exists {
+			deletedNodes = append(deletedNodes, uid)
+		}
+	}
+	var changedNodes []NodeRecord
+	for uid, node := range current.nodes {
+		if old, exists := previous.nodes[uid]; !exists || old.Label != node.Label || !sameProperties(old.Properties, node.Properties) {
+			changedNodes = append(changedNodes, node)
+		}
+	}
+	sort.Strings(deletedNodes)
+	sort.Slice(changedNodes, func(i, j int) bool { return changedNodes[i].UID < changedNodes[j].UID })
+	sortEdges(staleEdges)
+	sortEdges(changedEdges)
+
+	if err := batches(staleEdges, e.opts.BatchSize, func(batch []EdgeRecord) error { return e.sink.DeleteEdges(ctx, batch) }); err != nil {
+		return fmt.Errorf("failed to delete edges: %w", err)
+	}
+	if err := batches(deletedNodes, e.opts.BatchSize, func(batch []string) error { return e.sink.DeleteNodes(ctx, batch) }); err != nil {
+		return fmt.Errorf("failed to delete nodes: %w", err)
+	}
+	if err := batches(changedNodes, e.opts.BatchSize, func(batch []NodeRecord) error { return e.sink.UpsertNodes(ctx, batch) }); err != nil {
+		return fmt.Errorf("failed to upsert nodes: %w", err)
+	}
+	if err := batches(changedEdges, e.opts.BatchSize, func(batch []EdgeRecord) error { return e.sink.UpsertEdges(ctx, batch) }); err != nil {
+		return fmt.Errorf("failed to upsert edges: %w", err)
+	}
+	if initial {
+		if err := e.sink.Prune(ctx, e.opts.Source, e.syncID); err != nil {
+			return fmt.Errorf("failed to prune resources exported before: %w", err)
+		}
+		klog.Infof("Graph exported to %s: %d node(s), %d edge(s)", e.opts.Backend, len(current.nodes), len(current.edges))
+	}
+
+	metrics.GraphExportRecords.WithLabelValues("upsert").Add(float64(len(changedNodes) + len(changedEdges)))
+	metrics.GraphExportRecords.WithLabelValues("delete").Add(float64(len(deletedNodes) + len(staleEdges)))
+	e.last = current
+	e.generation = generation
+	return nil
+}
+
+// snapshot converts the graph into the records written to the database
+func (e *Exporter) snapshot() *exportState {
+	nodes := e.graph.GetAllNodes()
+	state := &exportState{
+		nodes: make(map[string]NodeRecord, len(nodes)),
+		edges: make(map[edgeKey]EdgeRecord),
+	}
+	for _, node := range nodes {
+		state.nodes[string(node.UID)] = e.nodeRecord(node)
+	}
+	for _, node := range nodes {
+		for _, edge := range node.OutgoingEdges {
+			// Edges to nodes being removed are left out, they could not be matched
+			if _, exists := state.nodes[string(edge.ToUID)]; exists {
+				state.edges[edgeKey{string(edge.FromUID), string(edge.ToUID)}] = e.edgeRecord(edge)
+			}
+		}
+	}
+	return state
+}
+
+// nodeRecord flattens a node. Labels become label.<key> properties and unset fields are
+// empty, so a field that was cleared is overwritten.
+func (e *Exporter) nodeRecord(node *graph.Node) NodeRecord {
+	props := map[string]interface{}{
+		"uid":        string(node.UID),
+		"name":       node.Name,
+		"namespace":  node.Namespace,
+		"kind":       node.Kind,
+		"apiVersion": node.APIVersion,
+		"status":     string(node.Status),
+		"reason":     node.StatusReason,
+		"message":    node.StatusMessage,
+		"release":    node.HelmRelease,
+		"chart":      node.HelmChart,
+		"cluster":    node.Cluster,
+		"createdAt":  formatTime(node.CreationTimestamp),
+		"source":     e.opts.Source,
+		"syncId":     e.syncID,
+	}
+	for key, value := range node.Labels {
+		props["label."+key] = value
+	}
+	return NodeRecord{UID: string(node.UID), Label: node.Kind, Properties: props}
+}
+
+// edgeRecord flattens an edge. Edge metadata becomes metadata.<key> properties.
+func (e *Exporter) edgeRecord(edge *graph.Edge) EdgeRecord {
+	props := map[string]interface{}{
+		"type":          string(edge.Type),
+		"stale":         edge.Stale,
+		"lastConfirmed": formatTime(edge.LastConfirmed),
+		"source":        e.opts.Source,
+		"syncId":        e.syncID,
+	}
+	for key, value := range edge.Metadata {
+		props["metadata."+key] = value
+	}
+	return EdgeRecord{From: string(edge.FromUID), To: string(edge.ToUID), Label: string(edge.Type), Properties: props}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sameProperties compares flattened properties, whose values are all scalars
+func sameProperties(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, exists := b[key]; !exists || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func sortEdges(edges []EdgeRecord) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+// batches calls write with consecutive batches of at most size items
+func batches[T any](items []T, size int, write func([]T) error) error {
+	for start := 0; start < len(items); start += size {
+		end := min(start+size, len(items))
+		if err := write(items[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// neo4jSink writes to Neo4j through the transactional Cypher HTTP API. Nodes carry the
+// Resource label and the label of their kind, relationships the type of their edge in upper
+// case (routes-to becomes ROUTES_TO).
+type neo4jSink struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newNeo4jSink(opts Options) *neo4jSink {
+	return &neo4jSink{
+		endpoint: strings.TrimSuffix(opts.URL, "/") + "/db/" + url.PathEscape(opts.Database) + "/tx/commit",
+		username: opts.Username,
+		password: opts.Password,
+		client:   &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+type cypherStatement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type cypherResponse struct {
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (s *neo4jSink) Init(ctx context.Context) error {
+	return s.run(ctx, cypherStatement{Statement: "CREATE INDEX astrolabe_resource_uid IF NOT EXISTS FOR (n:Resource) ON (n.uid)"})
+}
+
+// UpsertNodes merges the nodes of each kind in one statement, as labels cannot be parameters
+func (s *neo4jSink) UpsertNodes(ctx context.Context, nodes []NodeRecord) error {
+	byLabel := make(map[string][]NodeRecord)
+	for _, node := range nodes {
+		byLabel[node.Label] = append(byLabel[node.Label], node)
+	}
+	statements := make([]cypherStatement, 0, len(byLabel))
+	for _, label := range sortedKeys(byLabel) {
+		statements = append(statements, cypherStatement{
+			Statement:  fmt.Sprintf("UNWIND $rows AS row MERGE (n:Resource {uid: row.uid}) SET n = row.props, n:`%s`", cypherName(label)),
+			Parameters: map[string]interface{}{"rows": byLabel[label]},
+		})
+	}
+	return s.run(ctx, statements...)
+}
+
+// UpsertEdges merges the relationships of each type in one statement
+func (s *neo4jSink) UpsertEdges(ctx context.Context, edges []EdgeRecord) error {
+	byType := make(map[string][]EdgeRecord)
+	for _, edge := range edges {
+		byType[edge.Label] = append(byType[edge.Label], edge)
+	}
+	statements := make([]cypherStatement, 0, len(byType))
+	for _, edgeType := range sortedKeys(byType) {
+		statements = append(statements, cypherStatement{
+			Statement: fmt.Sprintf("UNWIND $rows AS row MATCH (a:Resource {uid: row.from}), (b:Resource {uid: row.to}) "+
+				"MERGE (a)-[r:`%s`]->(b) SET r = row.props", relationshipType(edgeType)),
+			Parameters: map[string]interface{}{"rows": byType[edgeType]},
+		})
+	}
+	return s.run(ctx, statements...)
+}
+
+func (s *neo4jSink) DeleteEdges(ctx context.Context, edges []EdgeRecord) error {
+	return s.run(ctx, cypherStatement{
+		Statement:  "UNWIND $rows AS row MATCH (:Resource {uid: row.from})-[r]->(:Resource {uid: row.to}) DELETE r",
+		Parameters: map[string]interface{}{"rows": edges},
+	})
+}
+
+func (s *neo4jSink) DeleteNodes(ctx context.Context, uids []string) error {
+	return s.run(ctx, cypherStatement{
+		Statement:  "UNWIND $uids AS uid MATCH (n:Resource {uid: uid}) DETACH DELETE n",
+		Parameters: map[string]interface{}{"uids": uids},
+	})
+}
+
+func (s *neo4jSink) Prune(ctx context.Context, source, syncID string) error {
+	parameters := map[string]interface{}{"source": source, "syncId": syncID}
+	return s.run(ctx,
+		cypherStatement{
+			Statement:  "MATCH (:Resource)-[r {source: $source}]->(:Resource) WHERE r.syncId <> $syncId DELETE r",
+			Parameters: parameters,
+		},
+		cypherStatement{
+			Statement:  "MATCH (n:Resource {source: $source}) WHERE n.syncId <> $syncId DETACH DELETE n",
+			Parameters: parameters,
+		},
+	)
+}
+
+// run executes the statements in one transaction
+func (s *neo4jSink) run(ctx context.Context, statements ...cypherStatement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"statements": statements})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("neo4j returned %s: %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+
+	var result cypherResponse
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return fmt.Errorf("invalid neo4j response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("neo4j error %s: %s", result.Errors[0].Code, result.Errors[0].Message)
+	}
+	return nil
+}
+
+// cypherName keeps the characters of a label that are valid in a quoted Cypher name
+func cypherName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '`' {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// relationshipType converts an edge type to the Cypher convention, e.g. uses-configmap to
+// USES_CONFIGMAP
+func relationshipType(edgeType string) string {
+	return strings.ToUpper(strings.ReplaceAll(cypherName(edgeType), "-", "_"))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package federation
+
+import (
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/api/astrolabev1"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Conversion from the protobuf messages of a cluster. UIDs become <cluster>/<uid>, and
+// namespaces and releases <cluster>/<name>, so resources of different clusters never collide
+// and the API filters tell them apart.
+
+func prefixUID(cluster, uid string) types.UID {
+	return types.UID(cluster + "/" + uid)
+}
+
+// prefix prefixes a namespace or release name, leaving empty values (cluster-scoped
+// resources, resources outside a release) empty
+func prefix(cluster, name string) string {
+	if name == "" {
+		return ""
+	}
+	return cluster + "/" + name
+}
+
+func federatedNode(cluster string, message *astrolabev1.Node) *graph.Node {
+	return &graph.Node{
+		UID:               prefixUID(cluster, message.GetUid()),
+		Name:              message.GetName(),
+		Namespace:         prefix(cluster, message.GetNamespace()),
+		Kind:              message.GetKind(),
+		APIVersion:        message.GetApiVersion(),
+		ResourceVersion:   message.GetResourceVersion(),
+		Labels:            message.GetLabels(),
+		CreationTimestamp: timeOf(message.GetCreationTimestamp()),
+		Status:            graph.ResourceStatus(message.GetStatus()),
+		StatusMessage:     message.GetMessage(),
+		StatusReason:      message.GetReason(),
+		Cluster:           cluster,
+		HelmChart:         message.GetChart(),
+		HelmRelease:       prefix(cluster, message.GetRelease()),
+		Metadata:          federatedMetadata(cluster, message.GetMetadata()),
+	}
+}
+
+func federatedMetadata(cluster string, message *astrolabev1.Metadata) *graph.ResourceMetadata {
+	if message == nil {
+		return nil
+	}
+
+	metadata := &graph.ResourceMetadata{
+		NodeName:        message.GetNodeName(),
+		Image:           message.GetImage(),
+		RestartCount:    int(message.GetRestartCount()),
+		VolumeName:      message.GetVolumeName(),
+		ClaimRef:        federatedReference(cluster, message.GetClaimRef()),
+		ClusterIP:       message.GetClusterIp(),
+		ServiceType:     message.GetServiceType(),
+		IngressClass:    message.GetIngressClass(),
+		ScaleTargetRef:  federatedReference(cluster, message.GetScaleTargetRef()),
+		MinReplicas:     message.MinReplicas,
+		MaxReplicas:     message.GetMaxReplicas(),
+		CurrentReplicas: message.GetCurrentReplicas(),
+		DesiredReplicas: message.GetDesiredReplicas(),
+		Computed:        message.GetComputed(),
+	}
+	for _, container := range message.GetContainers() {
+		metadata.Containers = append(metadata.Containers, graph.ContainerInfo{
+			Name:                  container.GetName(),
+			Image:                 container.GetImage(),
+			Init:                  container.GetInit(),
+			Ready:                 container.GetReady(),
+			Restarts:              container.GetRestarts(),
+			State:                 container.GetState(),
+			Requests:              container.GetRequests(),
+			Limits:                container.GetLimits(),
+			LastTerminationReason: container.GetLastTerminationReason(),
+		})
+	}
+	if excerpt := message.GetLogExcerpt(); excerpt != nil {
+		metadata.LogExcerpt = &graph.LogExcerpt{
+			Container: excerpt.GetContainer(),
+			Previous:  excerpt.GetPrevious(),
+			Lines:     excerpt.GetLines(),
+			SampledAt: timeOf(excerpt.GetSampledAt()),
+		}
+	}
+	if replicas := message.GetReplicas(); replicas != nil {
+		metadata.Replicas = &graph.ReplicaInfo{
+			Desired:   replicas.GetDesired(),
+			Current:   replicas.GetCurrent(),
+			Ready:     replicas.GetReady(),
+			Available: replicas.GetAvailable(),
+		}
+	}
+	if gitOps := message.GetGitOps(); gitOps != nil {
+		metadata.GitOps = &graph.GitOpsStatus{
+			SyncStatus:           gitOps.GetSyncStatus(),
+			HealthStatus:         gitOps.GetHealthStatus(),
+			RepoURL:              gitOps.GetRepoUrl(),
+			Path:                 gitOps.GetPath(),
+			Revision:             gitOps.GetRevision(),
+			DestinationNamespace: gitOps.GetDestinationNamespace(),
+			SourceRef:            gitOps.GetSourceRef(),
+		}
+	}
+	return metadata
+}
+
+func federatedReference(cluster string, message *astrolabev1.ObjectReference) *graph.ObjectReference {
+	if message == nil {
+		return nil
+	}
+	ref := &graph.ObjectReference{
+		Kind:      message.GetKind(),
+		Namespace: prefix(cluster, message.GetNamespace()),
+		Name:      message.GetName(),
+	}
+	if uid := message.GetUid(); uid != "" {
+		ref.UID = prefixUID(cluster, uid)
+	}
+	return ref
+}
+
+func federatedEdge(cluster string, message *astrolabev1.Edge) *graph.Edge {
+	return &graph.Edge{
+		Type:          graph.EdgeType(message.GetType()),
+		FromUID:       prefixUID(cluster, message.GetFrom()),
+		ToUID:         prefixUID(cluster, message.GetTo()),
+		Metadata:      message.GetMetadata(),
+		LastConfirmed: timeOf(message.GetLastConfirmed()),
+		Stale:         message.GetStale(),
+	}
+}
+
+func timeOf(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
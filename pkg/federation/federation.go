@@ -0,0 +1,193 @@
+// Package federation aggregates the graphs of per-cluster Astrolabe instances into a central
+// graph. Each instance streams its graph over the gRPC WatchGraph API: the full graph first,
+// then only the changes. Resources of a cluster are merged under the cluster name, so one
+// central instance serves a global topology without informers on every cluster.
+package federation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/api/astrolabev1"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/supervisor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Cluster is an Astrolabe instance whose graph is federated
+type Cluster struct {
+	// Name prefixes the UIDs, namespaces and releases of the cluster's resources
+	Name string
+	// Address is the host:port of the instance's gRPC API
+	Address string
+	// CAFile enables TLS, verifying the instance's certificate against this CA bundle. The
+	// connection is in plaintext when it is empty.
+	CAFile string
+	// Token is sent as the API token of the instance when it scopes API access
+	Token string
+	// Dialer connects to the instance in-process instead of over the network, e.g. for a
+	// kubeconfig context watched by this process
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+}
+
+// upstream is a federated cluster and the credentials used to reach it
+type upstream struct {
+	Cluster
+	creds credentials.TransportCredentials
+}
+
+// tokenCredentials sends an API token with every call. It is allowed over plaintext
+// connections, like the rest of the federation traffic without a CA file.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// Federator keeps the nodes and edges of federated clusters in sync with their instances
+type Federator struct {
+	graph     graph.GraphInterface
+	upstreams []upstream
+}
+
+// NewFederator validates the clusters and creates a federator writing to g
+func NewFederator(g graph.GraphInterface, clusters []Cluster) (*Federator, error) {
+	f := &Federator{graph: g}
+	seen := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		switch {
+		case cluster.Name == "":
+			return nil, fmt.Errorf("federated cluster without a name")
+		case strings.ContainsAny(cluster.Name, "/:"):
+			return nil, fmt.Errorf("federated cluster name %q must not contain / or :", cluster.Name)
+		case seen[cluster.Name]:
+			return nil, fmt.Errorf("federated cluster %q is listed twice", cluster.Name)
+		case cluster.Address == "":
+			return nil, fmt.Errorf("federated cluster %q has no address", cluster.Name)
+		}
+		seen[cluster.Name] = true
+
+		creds := insecure.NewCredentials()
+		if cluster.CAFile != "" {
+			var err error
+			if creds, err = credentials.NewClientTLSFromFile(cluster.CAFile, ""); err != nil {
+				return nil, fmt.Errorf("federated cluster %q: failed to load CA file: %w", cluster.Name, err)
+			}
+		}
+		f.upstreams = append(f.upstreams, upstream{Cluster: cluster, creds: creds})
+	}
+	return f, nil
+}
+
+// Start follows every federated cluster under supervision until ctx is cancelled. A broken
+// stream is reopened with backoff and resynchronized from the full graph it starts with.
+func (f *Federator) Start(ctx context.Context) {
+	for _, u := range f.upstreams {
+		supervisor.Go(ctx, "federation/"+u.Name, func(ctx context.Context) error {
+			return f.follow(ctx, u)
+		})
+	}
+}
+
+// follow applies the graph updates of a cluster until the stream breaks or ctx is cancelled
+func (f *Federator) follow(ctx context.Context, u upstream) error {
+	options := []grpc.DialOption{grpc.WithTransportCredentials(u.creds)}
+	if u.Token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(tokenCredentials(u.Token)))
+	}
+	if u.Dialer != nil {
+		options = append(options, grpc.WithContextDialer(u.Dialer))
+	}
+	conn, err := grpc.NewClient(u.Address, options...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", u.Address, err)
+	}
+	defer conn.Close()
+
+	stream, err := astrolabev1.NewAstrolabeClient(conn).WatchGraph(ctx, &astrolabev1.WatchGraphRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to watch the graph of %s: %w", u.Address, err)
+	}
+	defer metrics.FederationConnected.WithLabelValues(u.Name).Set(0)
+
+	for {
+		update, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("graph stream of %s broke: %w", u.Address, err)
+		}
+		if update.GetInitial() {
+			metrics.FederationConnected.WithLabelValues(u.Name).Set(1)
+			klog.Infof("Federated cluster %s: received %d node(s) from %s", u.Name, len(update.GetNodes()), u.Address)
+		}
+		f.apply(u.Name, update)
+		metrics.FederationUpdates.WithLabelValues(u.Name).Inc()
+	}
+}
+
+// apply merges a graph update of a cluster into the graph. The initial update holds the full
+// graph of the cluster, so nodes and edges of the cluster missing from it are removed.
+func (f *Federator) apply(cluster string, update *astrolabev1.GraphUpdate) {
+	for _, uid := range update.GetDeletedNodes() {
+		f.graph.RemoveNode(prefixUID(cluster, uid))
+	}
+	for _, ref := range update.GetDeletedEdges() {
+		f.graph.RemoveEdge(prefixUID(cluster, ref.GetFrom()), prefixUID(cluster, ref.GetTo()))
+	}
+
+	nodes := make(map[types.UID]bool, len(update.GetNodes()))
+	for _, message := range update.GetNodes() {
+		node := federatedNode(cluster, message)
+		nodes[node.UID] = true
+		f.graph.AddNode(node)
+	}
+	type edgeKey struct{ from, to types.UID }
+	edges := make(map[edgeKey]bool, len(update.GetEdges()))
+	for _, message := range update.GetEdges() {
+		edge := federatedEdge(cluster, message)
+		edges[edgeKey{edge.FromUID, edge.ToUID}] = true
+		if !f.graph.AddEdge(edge) {
+			klog.V(3).Infof("Federated cluster %s: dropped edge %s -> %s between unknown nodes", cluster, edge.FromUID, edge.ToUID)
+		}
+	}
+
+	if !update.GetInitial() {
+		return
+	}
+	removed := 0
+	for _, node := range f.graph.GetAllNodes() {
+		if node.Cluster != cluster {
+			continue
+		}
+		if !nodes[node.UID] {
+			f.graph.RemoveNode(node.UID)
+			removed++
+			continue
+		}
+		var gone []types.UID
+		for toUID := range node.OutgoingEdges {
+			if !edges[edgeKey{node.UID, toUID}] {
+				gone = append(gone, toUID)
+			}
+		}
+		for _, toUID := range gone {
+			f.graph.RemoveEdge(node.UID, toUID)
+		}
+	}
+	if removed > 0 {
+		klog.Infof("Federated cluster %s: removed %d node(s) gone while disconnected", cluster, removed)
+	}
+}
@@ -0,0 +1,432 @@
+// Package golden runs declarative fixtures through the processors and the API, and compares
+// the resulting graph and responses with the expected ones kept next to the fixtures. It makes
+// refactoring processors and serializers safe: any change of behavior shows up as a drift,
+// and intended changes are accepted by regenerating the expected files.
+//
+// A fixture set is a directory holding:
+//
+//	objects.yaml     the Kubernetes objects, as YAML documents, added to the graph in order
+//	requests.yaml    optional: the API requests to snapshot, as a list of {name, path}
+//	graph.json       the expected nodes and edges of the graph
+//	responses/       the expected response of each request, as <name>.json
+//
+// Times that depend on when the set runs, such as firstSeen, are replaced by a placeholder.
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/api"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	objectsFile   = "objects.yaml"
+	requestsFile  = "requests.yaml"
+	graphFile     = "graph.json"
+	responsesDir  = "responses"
+	volatileValue = "<volatile>"
+)
+
+// volatileFields are the JSON fields whose values depend on when the set runs
+var volatileFields = map[string]bool{
+	"firstSeen":     true,
+	"firstReady":    true,
+	"lastConfirmed": true,
+	"computedAt":    true,
+	"age":           true,
+	"token":         true,
+}
+
+// Request is an API request whose response is snapshotted
+type Request struct {
+	// Name is the file name of the expected response, without .json
+	Name string `json:"name"`
+	// Path is the path and query of a GET request
+	Path string `json:"path"`
+}
+
+// Set is a fixture set
+type Set struct {
+	Name     string
+	Dir      string
+	Objects  []runtime.Object
+	Requests []Request
+}
+
+// Drift is a difference between a result and its expected file
+type Drift struct {
+	// File is the expected file, relative to the set directory
+	File string
+	// Line is the first line that differs, 1-based
+	Line int
+	// Expected and Actual are the lines that differ, empty past the end of the file
+	Expected string
+	Actual   string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s:%d:\n  expected: %s\n  actual:   %s", d.File, d.Line, d.Expected, d.Actual)
+}
+
+// Discover returns the fixture sets under dir: the directories holding an objects.yaml
+func Discover(dir string) ([]string, error) {
+	var sets []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && entry.Name() == objectsFile {
+			sets = append(sets, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
+
+// Load reads the fixture set of a directory
+func Load(dir string) (*Set, error) {
+	set := &Set{Name: filepath.Base(dir), Dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, objectsFile))
+	if err != nil {
+		return nil, err
+	}
+	set.Objects, err = decodeObjects(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", objectsFile, err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, requestsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.UnmarshalStrict(data, &set.Requests); err != nil {
+			return nil, fmt.Errorf("%s: %w", requestsFile, err)
+		}
+	}
+	names := make(map[string]bool, len(set.Requests))
+	for _, request := range set.Requests {
+		if request.Name == "" || request.Path == "" {
+			return nil, fmt.Errorf("%s: every request needs a name and a path", requestsFile)
+		}
+		if names[request.Name] {
+			return nil, fmt.Errorf("%s: request %s is listed twice", requestsFile, request.Name)
+		}
+		names[request.Name] = true
+	}
+	return set, nil
+}
+
+// decodeObjects decodes YAML documents into the typed objects the informers deliver, or into
+// unstructured objects for custom resources. Objects without a UID get one derived from their
+// kind, namespace and name, so the graph does not change between runs.
+func decodeObjects(data []byte) ([]runtime.Object, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	decoder := scheme.Codecs.UniversalDeserializer()
+	var objects []runtime.Object
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		jsonDoc, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(objects)+1, err)
+		}
+		if len(bytes.TrimSpace(jsonDoc)) == 0 || string(jsonDoc) == "null" {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(jsonDoc, nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			u := &unstructured.Unstructured{}
+			if err = u.UnmarshalJSON(jsonDoc); err == nil {
+				obj = u
+			}
+		} else if err == nil {
+			// Informers deliver typed objects without their type meta
+			obj.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(objects)+1, err)
+		}
+
+		kind, err := objectKind(jsonDoc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(objects)+1, err)
+		}
+		if accessor, ok := obj.(interface {
+			GetUID() types.UID
+			SetUID(types.UID)
+			GetNamespace() string
+			GetName() string
+		}); ok && accessor.GetUID() == "" {
+			accessor.SetUID(types.UID(strings.ToLower(strings.Join([]string{kind, accessor.GetNamespace(), accessor.GetName()}, "-"))))
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// objectKind returns the kind of a JSON object
+func objectKind(data []byte) (string, error) {
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", err
+	}
+	if meta.Kind == "" {
+		return "", errors.New("object has no kind")
+	}
+	return meta.Kind, nil
+}
+
+// Result is what a fixture set produced, as normalized JSON by expected file
+type Result map[string][]byte
+
+// Run processes the objects of a set into a new graph with every processor and snapshots the
+// graph and the responses to its requests
+func (s *Set) Run() (Result, error) {
+	g := graph.NewGraph()
+	registry := processors.NewProcessorRegistry(g, processors.Options{})
+	for _, obj := range s.Objects {
+		kind, err := runtimeKind(obj)
+		if err != nil {
+			return nil, err
+		}
+		registry.Process(obj, kind, processors.EventAdd)
+	}
+
+	result := make(Result, len(s.Requests)+1)
+	data, err := normalize(graphSnapshot(g))
+	if err != nil {
+		return nil, fmt.Errorf("graph: %w", err)
+	}
+	result[graphFile] = data
+
+	handler := api.NewServer(g, 0, api.DefaultOptions()).Handler()
+	for _, request := range s.Requests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, request.Path, nil))
+		snapshot := map[string]interface{}{"status": recorder.Code}
+		var body interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err == nil {
+			snapshot["body"] = body
+		} else {
+			snapshot["body"] = recorder.Body.String()
+		}
+		data, err := normalize(snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("request %s: %w", request.Name, err)
+		}
+		result[filepath.Join(responsesDir, request.Name+".json")] = data
+	}
+	return result, nil
+}
+
+// runtimeKind returns the kind of a decoded object, whose type meta is cleared when typed
+func runtimeKind(obj runtime.Object) (string, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetKind(), nil
+	}
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return "", err
+	}
+	return gvks[0].Kind, nil
+}
+
+// GraphSnapshot is the expected state of a graph
+type GraphSnapshot struct {
+	Nodes []*graph.Node `json:"nodes"`
+	Edges []*graph.Edge `json:"edges"`
+}
+
+// graphSnapshot returns the nodes and edges of a graph in a stable order
+func graphSnapshot(g *graph.Graph) GraphSnapshot {
+	dump := g.DumpState()
+	sort.SliceStable(dump.Edges, func(i, j int) bool {
+		a, b := dump.Edges[i], dump.Edges[j]
+		if a.FromUID != b.FromUID {
+			return a.FromUID < b.FromUID
+		}
+		if a.ToUID != b.ToUID {
+			return a.ToUID < b.ToUID
+		}
+		return a.Type < b.Type
+	})
+	return GraphSnapshot{Nodes: dump.Nodes, Edges: dump.Edges}
+}
+
+// normalize encodes v as indented JSON with sorted keys and the volatile fields replaced
+func normalize(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(scrub(generic)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scrub replaces the values of the volatile fields, leaving zero times alone
+func scrub(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if volatileFields[key] && field != nil && field != "0001-01-01T00:00:00Z" {
+				value[key] = volatileValue
+				continue
+			}
+			value[key] = scrub(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = scrub(item)
+		}
+	}
+	return v
+}
+
+// Compare returns the drifts between a result and the expected files of the set, including
+// expected responses no request produces anymore
+func (s *Set) Compare(result Result) ([]Drift, error) {
+	var drifts []Drift
+	for _, file := range sortedFiles(result) {
+		expected, err := os.ReadFile(filepath.Join(s.Dir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			drifts = append(drifts, Drift{File: file, Line: 1, Expected: "(missing file)", Actual: firstLine(result[file])})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if drift, differs := diff(file, expected, result[file]); differs {
+			drifts = append(drifts, drift)
+		}
+	}
+
+	stale, err := s.staleResponses(result)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range stale {
+		drifts = append(drifts, Drift{File: file, Line: 1, Expected: "(response of a removed request)", Actual: "(none)"})
+	}
+	return drifts, nil
+}
+
+// Update writes a result as the expected files of the set, and removes the expected responses
+// no request produces anymore
+func (s *Set) Update(result Result) error {
+	for file, data := range result {
+		path := filepath.Join(s.Dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	stale, err := s.staleResponses(result)
+	if err != nil {
+		return err
+	}
+	for _, file := range stale {
+		if err := os.Remove(filepath.Join(s.Dir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleResponses returns the expected responses that are not part of a result
+func (s *Set) staleResponses(result Result) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, responsesDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, entry := range entries {
+		file := filepath.Join(responsesDir, entry.Name())
+		if _, exists := result[file]; !exists && strings.HasSuffix(entry.Name(), ".json") {
+			stale = append(stale, file)
+		}
+	}
+	return stale, nil
+}
+
+// diff returns the first line that differs between the expected and actual content
+func diff(file string, expected, actual []byte) (Drift, bool) {
+	if bytes.Equal(expected, actual) {
+		return Drift{}, false
+	}
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; ; i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a || i >= len(expectedLines) || i >= len(actualLines) {
+			return Drift{File: file, Line: i + 1, Expected: e, Actual: a}, true
+		}
+	}
+}
+
+func firstLine(data []byte) string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	return line
+}
+
+func sortedFiles(result Result) []string {
+	files := make([]string, 0, len(result))
+	for file := range result {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,159 @@
+package graph
+
+import (
+	"maps"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The graph records the generation at which each node last changed, so that API clients can
+// poll for the nodes changed since the generation they last read instead of the whole graph.
+// A node changes when it is added, updated or removed, and when one of its edges is added,
+// removed or flagged stale, so the edges of a changed node are the ones to refresh. Resyncs
+// that deliver a node again unchanged, or only reconfirm its edges, do not change it. Removed
+// nodes are remembered up to maxRemovedNodes; changes before the oldest forgotten removal, or
+// before a repair of the graph, can no longer be listed.
+
+// maxRemovedNodes bounds the removed nodes remembered for ChangesSince
+const maxRemovedNodes = 10000
+
+// Changes are the nodes changed after a generation
+type Changes struct {
+	// Generation is the generation of the graph the changes were read from
+	Generation uint64
+	// Nodes are the nodes added or updated, or whose edges changed
+	Nodes []*Node
+	// Removed are the nodes removed, without their edges and metadata
+	Removed []*Node
+}
+
+// ChangeTracker is a graph that can list the nodes changed since a generation
+type ChangeTracker interface {
+	// ChangesSince returns the changes after generation. It returns false when they are no
+	// longer known, or generation is not one of this graph, and the whole graph must be read.
+	ChangesSince(generation uint64) (*Changes, bool)
+}
+
+// removedNode is a node removed from the graph and when
+type removedNode struct {
+	node       *Node
+	generation uint64
+}
+
+// touch records that nodes changed at the current generation. Must be called with lock held.
+func (g *Graph) touch(uids ...types.UID) {
+	for _, uid := range uids {
+		if _, exists := g.nodes[uid]; exists {
+			g.changedAt[uid] = g.generation
+		}
+	}
+}
+
+// resynced reports whether node is an unchanged copy of the node in the graph, as delivered
+// again by informer resyncs: same resource version, status and metadata. Its edges are kept
+// by addNode. Nodes without a resource version are never taken as unchanged. Must be called
+// with lock held.
+func (g *Graph) resynced(node *Node) bool {
+	old, exists := g.nodes[node.UID]
+	return exists && node.ResourceVersion != "" &&
+		old.ResourceVersion == node.ResourceVersion &&
+		old.Status == node.Status &&
+		old.StatusReason == node.StatusReason &&
+		old.StatusMessage == node.StatusMessage &&
+		reflect.DeepEqual(old.Metadata, node.Metadata)
+}
+
+// reconfirmed reports whether edge only reconfirms an edge of the graph that is not stale,
+// with the same type and metadata. Must be called with lock held.
+func (g *Graph) reconfirmed(edge *Edge) bool {
+	from, exists := g.nodes[edge.FromUID]
+	if !exists {
+		return false
+	}
+	old, exists := from.OutgoingEdges[edge.ToUID]
+	return exists && !old.Stale && !edge.Stale && old.Type == edge.Type && maps.Equal(old.Metadata, edge.Metadata)
+}
+
+// touchNeighbours records that the nodes with an edge to or from node changed. Must be called
+// with lock held.
+func (g *Graph) touchNeighbours(node *Node) {
+	for uid := range node.OutgoingEdges {
+		g.touch(uid)
+	}
+	for uid := range node.IncomingEdges {
+		g.touch(uid)
+	}
+}
+
+// recordRemoval remembers a node removed at the current generation, forgetting the oldest
+// half of the removals when there are too many. Must be called with lock held.
+func (g *Graph) recordRemoval(node *Node) {
+	delete(g.changedAt, node.UID)
+	g.removedAt[node.UID] = removedNode{
+		node: &Node{
+			UID:         node.UID,
+			Name:        node.Name,
+			Namespace:   node.Namespace,
+			Kind:        node.Kind,
+			APIVersion:  node.APIVersion,
+			Cluster:     node.Cluster,
+			HelmRelease: node.HelmRelease,
+			Annotations: node.Annotations,
+		},
+		generation: g.generation,
+	}
+	if len(g.removedAt) <= maxRemovedNodes {
+		return
+	}
+
+	generations := make([]uint64, 0, len(g.removedAt))
+	for _, removed := range g.removedAt {
+		generations = append(generations, removed.generation)
+	}
+	sort.Slice(generations, func(i, j int) bool { return generations[i] < generations[j] })
+	horizon := generations[len(generations)/2]
+	for uid, removed := range g.removedAt {
+		if removed.generation <= horizon {
+			delete(g.removedAt, uid)
+		}
+	}
+	g.changesHorizon = horizon
+}
+
+// forgetChanges makes the changes up to the current generation unknown, e.g. after edges
+// were repaired without recording which nodes they belonged to. Must be called with lock held.
+func (g *Graph) forgetChanges() {
+	g.changesHorizon = g.generation
+	g.removedAt = make(map[types.UID]removedNode)
+}
+
+// ChangesSince returns the nodes changed after generation, sorted by UID
+func (g *Graph) ChangesSince(generation uint64) (*Changes, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if generation < g.changesHorizon || generation > g.generation {
+		return nil, false
+	}
+	changes := &Changes{Generation: g.generation}
+	for uid, changed := range g.changedAt {
+		if changed > generation {
+			changes.Nodes = append(changes.Nodes, g.nodes[uid])
+		}
+	}
+	for _, removed := range g.removedAt {
+		if removed.generation > generation {
+			changes.Removed = append(changes.Removed, removed.node)
+		}
+	}
+	sort.Slice(changes.Nodes, func(i, j int) bool { return changes.Nodes[i].UID < changes.Nodes[j].UID })
+	sort.Slice(changes.Removed, func(i, j int) bool { return changes.Removed[i].UID < changes.Removed[j].UID })
+	return changes, true
+}
+
+// ChangesSince returns the changes of the current snapshot
+func (v *SnapshotView) ChangesSince(generation uint64) (*Changes, bool) {
+	return v.Current().ChangesSince(generation)
+}
@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestChangesSince(t *testing.T) {
+	pod := func(resourceVersion string, metadata *ResourceMetadata) *Node {
+		return &Node{UID: "pod", Kind: "Pod", Namespace: "default", Name: "app", ResourceVersion: resourceVersion, Status: StatusReady, Metadata: metadata}
+	}
+
+	tests := []struct {
+		name    string
+		update  func(g *Graph)
+		changed []types.UID
+	}{
+		{
+			name:   "resync",
+			update: func(g *Graph) { g.AddNode(pod("1", &ResourceMetadata{NodeName: "node-1"})) },
+		},
+		{
+			name:   "reconfirmed edge",
+			update: func(g *Graph) { g.AddEdge(&Edge{Type: EdgeOwnership, FromUID: "rs", ToUID: "pod"}) },
+		},
+		{
+			name:    "new resource version",
+			update:  func(g *Graph) { g.AddNode(pod("2", &ResourceMetadata{NodeName: "node-1"})) },
+			changed: []types.UID{"pod"},
+		},
+		{
+			name:    "metadata at the same resource version",
+			update:  func(g *Graph) { g.AddNode(pod("1", &ResourceMetadata{NodeName: "node-1", Zone: "zone-a"})) },
+			changed: []types.UID{"pod"},
+		},
+		{
+			name: "updated metadata",
+			update: func(g *Graph) {
+				g.UpdateNode("pod", func(node *Node) bool {
+					node.Metadata.Zone = "zone-a"
+					return true
+				})
+			},
+			changed: []types.UID{"pod"},
+		},
+		{
+			name: "edge metadata",
+			update: func(g *Graph) {
+				g.AddEdge(&Edge{Type: EdgeOwnership, FromUID: "rs", ToUID: "pod", Metadata: map[string]string{"controller": "true"}})
+			},
+			changed: []types.UID{"pod", "rs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGraph()
+			g.AddNode(&Node{UID: "rs", Kind: "ReplicaSet", Namespace: "default", Name: "app", ResourceVersion: "1"})
+			g.AddNode(pod("1", &ResourceMetadata{NodeName: "node-1"}))
+			g.AddEdge(&Edge{Type: EdgeOwnership, FromUID: "rs", ToUID: "pod"})
+			since := g.Generation()
+
+			tt.update(g)
+			changes, ok := g.ChangesSince(since)
+			if !ok {
+				t.Fatal("changes are not known")
+			}
+			var changed []types.UID
+			for _, node := range changes.Nodes {
+				changed = append(changed, node.UID)
+			}
+			if len(changed) != len(tt.changed) {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+			for i := range changed {
+				if changed[i] != tt.changed[i] {
+					t.Fatalf("changed = %v, want %v", changed, tt.changed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChartRelease is a Helm release running a version of a chart
+type ChartRelease struct {
+	Release   string
+	Namespace string
+	Version   string
+	Resources int
+}
+
+// ChartDependency is a subchart declared by a chart. Resources of an aliased subchart carry
+// the alias as their chart name.
+type ChartDependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+}
+
+// ChartName returns the name resources of the subchart carry in their helm.sh/chart label
+func (d ChartDependency) ChartName() string {
+	if d.Alias != "" {
+		return d.Alias
+	}
+	return d.Name
+}
+
+// ReleaseNamespace returns the namespace the Helm release of a node was installed in.
+// Cluster-scoped resources are attributed to it through the meta.helm.sh/release-namespace
+// annotation.
+func ReleaseNamespace(node *Node) string {
+	if namespace := node.Annotations["meta.helm.sh/release-namespace"]; namespace != "" {
+		return namespace
+	}
+	return node.Namespace
+}
+
+// DeployedRevision returns the current revision of a release in a namespace from its history
+// (sorted newest first): the newest deployed revision, or the newest revision when none is
+// deployed. It returns nil when the history of the release is unknown.
+func DeployedRevision(history []HelmRevision, namespace string) *HelmRevision {
+	var newest *HelmRevision
+	for i := range history {
+		revision := &history[i]
+		if revision.Namespace != namespace {
+			continue
+		}
+		if revision.Status == "deployed" {
+			return revision
+		}
+		if newest == nil {
+			newest = revision
+		}
+	}
+	return newest
+}
+
+// ParentChart returns the chart of the release a resource rendered from chart belongs to,
+// when chart is one of its subcharts, or "". Helm only records the direct dependencies of a
+// release's chart, so subcharts of subcharts are placed under the release's chart too.
+func ParentChart(revision *HelmRevision, chart string) string {
+	if revision == nil || chart == "" || chart == revision.Chart {
+		return ""
+	}
+	return revision.Chart
+}
+
+// SplitChart splits a helm.sh/chart value (<name>-<version>) into chart name and version.
+// Chart names may contain dashes and digits, so the version starts at the first dash that is
+// followed by a dotted version.
+func SplitChart(chart string) (string, string) {
+	for i := 0; i < len(chart)-1; i++ {
+		if chart[i] != '-' {
+			continue
+		}
+		version := chart[i+1:]
+		if version[0] >= '0' && version[0] <= '9' && strings.Contains(version, ".") {
+			return chart[:i], version
+		}
+	}
+	return chart, ""
+}
+
+// GetChartReleases returns the releases running any version of the named chart, sorted by
+// namespace, release and version
+func (g *Graph) GetChartReleases(chart string) []ChartRelease {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	type releaseKey struct{ release, namespace, version string }
+	counts := make(map[releaseKey]int)
+
+	for value, nodes := range g.byHelmChart {
+		name, version := SplitChart(value)
+		if name != chart {
+			continue
+		}
+		for _, node := range g.liveNodes(nodes) {
+			counts[releaseKey{node.HelmRelease, ReleaseNamespace(node), version}]++
+		}
+	}
+
+	releases := make([]ChartRelease, 0, len(counts))
+	for key, count := range counts {
+		releases = append(releases, ChartRelease{
+			Release:   key.release,
+			Namespace: key.namespace,
+			Version:   key.version,
+			Resources: count,
+		})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		a, b := releases[i], releases[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Release != b.Release {
+			return a.Release < b.Release
+		}
+		return a.Version < b.Version
+	})
+	return releases
+}
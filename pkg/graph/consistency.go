@@ -0,0 +1,378 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Consistency issue types
+const (
+	// IssueDanglingEdge is an edge whose other endpoint is missing from the nodes map or does
+	// not record the edge
+	IssueDanglingEdge = "dangling-edge"
+	// IssueOrphanedIndexEntry is an index entry for a node that was removed
+	IssueOrphanedIndexEntry = "orphaned-index-entry"
+	// IssueMissingIndexEntry is a node missing from the namespace/kind index
+	IssueMissingIndexEntry = "missing-index-entry"
+	// IssueMissingInBackend is a node in memory that is not persisted
+	IssueMissingInBackend = "missing-in-backend"
+	// IssueMissingInMemory is a persisted node that is not in memory
+	IssueMissingInMemory = "missing-in-memory"
+	// IssueOrphanedPendingEdge is an ownership edge waiting for its owner whose child was removed
+	IssueOrphanedPendingEdge = "orphaned-pending-edge"
+)
+
+var issueTypes = []string{
+	IssueDanglingEdge,
+	IssueOrphanedIndexEntry,
+	IssueMissingIndexEntry,
+	IssueMissingInBackend,
+	IssueMissingInMemory,
+	IssueOrphanedPendingEdge,
+}
+
+// maxIssueSamples limits the issue descriptions kept in a report
+const maxIssueSamples = 20
+
+// ConsistencyReport is the outcome of a consistency check
+type ConsistencyReport struct {
+	CheckedAt time.Time      `json:"checkedAt"`
+	Duration  string         `json:"duration"`
+	Nodes     int            `json:"nodes"`
+	Issues    map[string]int `json:"issues"`
+	Repaired  bool           `json:"repaired"`
+	// Samples describes some of the issues found
+	Samples []string `json:"samples,omitempty"`
+}
+
+func newConsistencyReport() ConsistencyReport {
+	report := ConsistencyReport{
+		CheckedAt: time.Now(),
+		Issues:    make(map[string]int, len(issueTypes)),
+	}
+	for _, issue := range issueTypes {
+		report.Issues[issue] = 0
+	}
+	return report
+}
+
+func (r *ConsistencyReport) add(issue, format string, args ...interface{}) {
+	r.Issues[issue]++
+	if len(r.Samples) < maxIssueSamples {
+		r.Samples = append(r.Samples, issue+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// Total returns the number of issues found
+func (r *ConsistencyReport) Total() int {
+	total := 0
+	for _, count := range r.Issues {
+		total += count
+	}
+	return total
+}
+
+// ConsistencyCheckable is a graph that can check (and repair) its own consistency
+type ConsistencyCheckable interface {
+	CheckConsistency(repair bool) ConsistencyReport
+}
+
+// CheckConsistency looks for dangling edges and index entries that do not match the nodes map.
+// With repair, dangling edges are dropped (processors recreate valid ones on the next resync)
+// and the indexes are rebuilt.
+func (g *Graph) CheckConsistency(repair bool) ConsistencyReport {
+	if repair {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	} else {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+	}
+
+	report := newConsistencyReport()
+	report.Nodes = len(g.nodes)
+
+	for uid, node := range g.nodes {
+		for toUID := range node.OutgoingEdges {
+			toNode, exists := g.nodes[toUID]
+			if exists && toNode.IncomingEdges[uid] != nil {
+				continue
+			}
+			report.add(IssueDanglingEdge, "%s/%s -> %s", node.Kind, node.Name, toUID)
+			if repair {
+				delete(node.OutgoingEdges, toUID)
+			}
+		}
+		for fromUID := range node.IncomingEdges {
+			fromNode, exists := g.nodes[fromUID]
+			if exists && fromNode.OutgoingEdges[uid] != nil {
+				continue
+			}
+			report.add(IssueDanglingEdge, "%s -> %s/%s", fromUID, node.Kind, node.Name)
+			if repair {
+				delete(node.IncomingEdges, fromUID)
+			}
+		}
+	}
+
+	for ownerUID, pendingList := range g.pendingOwnerEdges {
+		for _, pending := range pendingList {
+			if _, exists := g.nodes[pending.ToUID]; !exists {
+				report.add(IssueOrphanedPendingEdge, "%s -> %s", ownerUID, pending.ToUID)
+			}
+		}
+	}
+	if repair {
+		for _, pendingList := range g.pendingOwnerEdges {
+			for _, pending := range pendingList {
+				if _, exists := g.nodes[pending.ToUID]; !exists {
+					g.dropPendingOwnerEdges(pending.ToUID)
+				}
+			}
+		}
+	}
+
+	indexIssues := g.checkIndexes(&report)
+	if repair && indexIssues > 0 {
+		g.rebuildIndexes()
+	}
+
+	if repair && report.Total() > 0 {
+		report.Repaired = true
+		g.generation++
+		g.forgetChanges()
+	}
+	return report
+}
+
+// checkIndexes reports index entries that do not match the nodes map and returns their count.
+// Must be called with lock held.
+func (g *Graph) checkIndexes(report *ConsistencyReport) int {
+	issues := 0
+	orphaned := func(index string, nodes []*Node) {
+		for _, node := range nodes {
+			if _, exists := g.nodes[node.UID]; !exists {
+				report.add(IssueOrphanedIndexEntry, "%s index: %s/%s (%s)", index, node.Kind, node.Name, node.UID)
+				issues++
+			}
+		}
+	}
+
+	indexed := make(map[types.UID]bool, len(g.nodes))
+	for _, kinds := range g.byNamespaceKind {
+		for _, nodes := range kinds {
+			orphaned("namespace/kind", nodes)
+			for _, node := range nodes {
+				indexed[node.UID] = true
+			}
+		}
+	}
+	for grouper, groups := range g.byGroup {
+		for _, nodes := range groups {
+			orphaned(grouper, nodes)
+		}
+	}
+	g.byLabel.each(func(key string, uid types.UID) {
+		if _, exists := g.nodes[uid]; !exists {
+			report.add(IssueOrphanedIndexEntry, "label index: %s (%s)", key, uid)
+			issues++
+		}
+	})
+	for key, uids := range g.byName {
+		for uid := range uids {
+			if _, exists := g.nodes[uid]; !exists {
+				report.add(IssueOrphanedIndexEntry, "name index: %s/%s (%s)", key.kind, key.name, uid)
+				issues++
+			}
+		}
+	}
+	for _, nodes := range g.byHelmChart {
+		orphaned("chart", nodes)
+	}
+
+	for uid, node := range g.nodes {
+		if !indexed[uid] {
+			report.add(IssueMissingIndexEntry, "%s/%s (%s)", node.Kind, node.Name, uid)
+			issues++
+		}
+	}
+	return issues
+}
+
+// rebuildIndexes recreates all indexes from the nodes map. Must be called with lock held.
+func (g *Graph) rebuildIndexes() {
+	g.byNamespaceKind = make(map[string]map[string][]*Node)
+	g.byGroup = make(map[string]map[string][]*Node)
+	g.byLabel = newLabelIndex()
+	g.byName = make(nameIndex)
+	g.byHelmChart = make(map[string][]*Node)
+	g.search = newSearchIndex()
+	for _, node := range g.nodes {
+		g.addToIndexes(node)
+	}
+}
+
+// CheckConsistency checks the in-memory graph and compares the persisted nodes with the
+// nodes in memory. Since async writes lag behind, a node only counts as drift when it is
+// found missing by two consecutive checks. Memory is authoritative when repairing: missing
+// nodes are persisted with their edges and persisted nodes absent from memory are deleted.
+func (pg *PersistentGraph) CheckConsistency(repair bool) ConsistencyReport {
+	report := pg.Graph.CheckConsistency(repair)
+	if !pg.enabled {
+		return report
+	}
+
+	pg.checkMu.Lock()
+	defer pg.checkMu.Unlock()
+
+	stored, err := pg.backend.GetAllNodes()
+	if err != nil {
+		klog.Errorf("Consistency check: failed to read nodes from backend: %v", err)
+		return report
+	}
+
+	inBackend := make(map[types.UID]bool, len(stored))
+	for _, node := range stored {
+		inBackend[node.UID] = true
+	}
+
+	missingInBackend := make(map[types.UID]bool)
+	for _, node := range pg.Graph.GetAllNodes() {
+		if inBackend[node.UID] {
+			continue
+		}
+		missingInBackend[node.UID] = true
+		if !pg.suspectMissingInBackend[node.UID] {
+			continue
+		}
+		report.add(IssueMissingInBackend, "%s/%s/%s (%s)", node.Namespace, node.Kind, node.Name, node.UID)
+		if repair {
+			pg.persistNode(node)
+			delete(missingInBackend, node.UID)
+		}
+	}
+
+	missingInMemory := make(map[types.UID]bool)
+	for _, node := range stored {
+		if _, exists := pg.Graph.GetNode(node.UID); exists {
+			continue
+		}
+		missingInMemory[node.UID] = true
+		if !pg.suspectMissingInMemory[node.UID] {
+			continue
+		}
+		report.add(IssueMissingInMemory, "%s/%s/%s (%s)", node.Namespace, node.Kind, node.Name, node.UID)
+		if repair {
+			if err := pg.backend.DeleteNode(node.UID); err != nil {
+				klog.Errorf("Consistency check: failed to delete %s from backend: %v", node.UID, err)
+				continue
+			}
+			delete(missingInMemory, node.UID)
+		}
+	}
+
+	pg.suspectMissingInBackend = missingInBackend
+	pg.suspectMissingInMemory = missingInMemory
+
+	if repair && report.Total() > 0 {
+		report.Repaired = true
+	}
+	return report
+}
+
+// persistNode writes a node and its edges directly to the backend
+func (pg *PersistentGraph) persistNode(node *Node) {
+	if err := pg.backend.SaveNode(node); err != nil {
+		klog.Errorf("Consistency check: failed to persist %s: %v", node.UID, err)
+		return
+	}
+	pg.Graph.mu.RLock()
+	edges := make([]*Edge, 0, len(node.OutgoingEdges)+len(node.IncomingEdges))
+	for _, edge := range node.OutgoingEdges {
+		edges = append(edges, edge)
+	}
+	for _, edge := range node.IncomingEdges {
+		edges = append(edges, edge)
+	}
+	pg.Graph.mu.RUnlock()
+
+	for _, edge := range edges {
+		if err := pg.backend.SaveEdge(edge); err != nil {
+			klog.Errorf("Consistency check: failed to persist edge %s->%s: %v", edge.FromUID, edge.ToUID, err)
+		}
+	}
+}
+
+// ConsistencyChecker periodically checks the graph and reports drift through metrics
+type ConsistencyChecker struct {
+	target   ConsistencyCheckable
+	interval time.Duration
+	repair   bool
+	last     atomic.Pointer[ConsistencyReport]
+	mu       sync.Mutex
+}
+
+// NewConsistencyChecker creates a checker that runs every interval, repairing issues if repair is set
+func NewConsistencyChecker(target ConsistencyCheckable, interval time.Duration, repair bool) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		target:   target,
+		interval: interval,
+		repair:   repair,
+	}
+}
+
+// Start runs a check on every tick until ctx is cancelled
+func (c *ConsistencyChecker) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Run()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run performs a check now and records its outcome
+func (c *ConsistencyChecker) Run() ConsistencyReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := time.Now()
+	report := c.target.CheckConsistency(c.repair)
+	elapsed := time.Since(start)
+	report.Duration = elapsed.String()
+
+	metrics.ConsistencyDuration.Observe(elapsed.Seconds())
+	metrics.ConsistencyLastRun.Set(float64(report.CheckedAt.Unix()))
+	for issue, count := range report.Issues {
+		metrics.ConsistencyIssues.WithLabelValues(issue).Set(float64(count))
+		if report.Repaired && count > 0 {
+			metrics.ConsistencyRepairs.WithLabelValues(issue).Add(float64(count))
+		}
+	}
+
+	if total := report.Total(); total > 0 {
+		klog.Warningf("Consistency check found %d issue(s) (repaired: %v): %v", total, report.Repaired, report.Issues)
+	} else {
+		klog.V(2).Infof("Consistency check passed (%d nodes, took %v)", report.Nodes, elapsed)
+	}
+
+	c.last.Store(&report)
+	return report
+}
+
+// Last returns the report of the most recent check, or nil before the first one
+func (c *ConsistencyChecker) Last() *ConsistencyReport {
+	return c.last.Load()
+}
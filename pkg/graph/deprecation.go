@@ -0,0 +1,15 @@
+package graph
+
+// APIDeprecation describes the deprecated API version a resource was applied with
+type APIDeprecation struct {
+	// APIVersion is the deprecated group/version, e.g. policy/v1beta1
+	APIVersion string `json:"apiVersion"`
+	// DeprecatedIn and RemovedIn are the Kubernetes minor versions, e.g. 1.21
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+	// Replacement is the API version to migrate to, empty when the kind has none
+	Replacement string `json:"replacement,omitempty"`
+	// Removed is set when the Kubernetes version the API is checked against no longer serves
+	// it, so the next apply or Helm upgrade with it fails
+	Removed bool `json:"removed"`
+}
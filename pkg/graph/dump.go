@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// StateDump is the state of a graph captured for debugging a misbehaving instance: its nodes
+// and edges, the edges still waiting for an endpoint, the sizes of its indexes and the stacks
+// of all goroutines of the process
+type StateDump struct {
+	Time       time.Time `json:"time"`
+	Generation uint64    `json:"generation"`
+	Nodes      []*Node   `json:"nodes"`
+	Edges      []*Edge   `json:"edges"`
+
+	PendingEdges        []PendingEdgeDump        `json:"pendingEdges"`
+	ReversePendingEdges []ReversePendingEdgeDump `json:"reversePendingEdges"`
+	PendingOwnerEdges   []PendingOwnerEdgeDump   `json:"pendingOwnerEdges"`
+
+	// IndexSizes is the number of entries of each index
+	IndexSizes map[string]int `json:"indexSizes"`
+
+	// Goroutines holds the stacks of all goroutines, as in a panic
+	Goroutines string `json:"goroutines"`
+}
+
+// PendingEdgeDump is an edge waiting for its target to be created
+type PendingEdgeDump struct {
+	FromUID  types.UID         `json:"fromUID"`
+	Target   RefKey            `json:"target"`
+	EdgeType EdgeType          `json:"type"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ReversePendingEdgeDump is an edge waiting for its source to be created
+type ReversePendingEdgeDump struct {
+	ToUID    types.UID `json:"toUID"`
+	Source   RefKey    `json:"source"`
+	EdgeType EdgeType  `json:"type"`
+}
+
+// PendingOwnerEdgeDump is an ownership edge waiting for the owner to be created
+type PendingOwnerEdgeDump struct {
+	OwnerUID types.UID `json:"ownerUID"`
+	ToUID    types.UID `json:"toUID"`
+	Owner    RefKey    `json:"owner"`
+}
+
+// Dumpable is a graph that can capture its state for debugging
+type Dumpable interface {
+	DumpState() *StateDump
+}
+
+// DumpState captures the state of the graph and the goroutine stacks of the process
+func (g *Graph) DumpState() *StateDump {
+	dump := g.dumpGraph()
+
+	var stacks bytes.Buffer
+	if profile := pprof.Lookup("goroutine"); profile != nil {
+		profile.WriteTo(&stacks, 2)
+	}
+	dump.Goroutines = stacks.String()
+	return dump
+}
+
+// dumpGraph copies the nodes, edges and pending edges under the read lock
+func (g *Graph) dumpGraph() *StateDump {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	dump := &StateDump{
+		Time:       time.Now(),
+		Generation: g.generation,
+		Nodes:      make([]*Node, 0, len(g.nodes)),
+		IndexSizes: g.indexSizes(),
+	}
+	for _, node := range g.nodes {
+		copied := *node
+		dump.Nodes = append(dump.Nodes, &copied)
+		for _, edge := range node.OutgoingEdges {
+			copiedEdge := *edge
+			dump.Edges = append(dump.Edges, &copiedEdge)
+		}
+	}
+	sort.Slice(dump.Nodes, func(i, j int) bool { return dump.Nodes[i].UID < dump.Nodes[j].UID })
+	sort.Slice(dump.Edges, func(i, j int) bool {
+		if dump.Edges[i].FromUID != dump.Edges[j].FromUID {
+			return dump.Edges[i].FromUID < dump.Edges[j].FromUID
+		}
+		return dump.Edges[i].ToUID < dump.Edges[j].ToUID
+	})
+
+	for target, pending := range g.pendingEdges {
+		for _, edge := range pending {
+			dump.PendingEdges = append(dump.PendingEdges, PendingEdgeDump{
+				FromUID:  edge.FromUID,
+				Target:   target,
+				EdgeType: edge.EdgeType,
+				Metadata: edge.Metadata,
+			})
+		}
+	}
+	for source, pending := range g.reversePendingEdges {
+		for _, edge := range pending {
+			dump.ReversePendingEdges = append(dump.ReversePendingEdges, ReversePendingEdgeDump{
+				ToUID:    edge.ToUID,
+				Source:   source,
+				EdgeType: edge.EdgeType,
+			})
+		}
+	}
+	for owner, pending := range g.pendingOwnerEdges {
+		for _, edge := range pending {
+			dump.PendingOwnerEdges = append(dump.PendingOwnerEdges, PendingOwnerEdgeDump{
+				OwnerUID: owner,
+				ToUID:    edge.ToUID,
+				Owner:    edge.SourceRef,
+			})
+		}
+	}
+	return dump
+}
+
+// indexSizes counts the node entries of each index, and the terms of the search index. Must
+// be called with lock held.
+func (g *Graph) indexSizes() map[string]int {
+	sizes := map[string]int{
+		"nodes":  len(g.nodes),
+		"search": len(g.search.terms),
+	}
+	for _, kinds := range g.byNamespaceKind {
+		for _, nodes := range kinds {
+			sizes["namespaceKind"] += len(nodes)
+		}
+	}
+	for grouper, groups := range g.byGroup {
+		for _, nodes := range groups {
+			sizes["group:"+grouper] += len(nodes)
+		}
+	}
+	g.byLabel.each(func(string, types.UID) {
+		sizes["label"]++
+	})
+	for _, uids := range g.byName {
+		sizes["name"] += len(uids)
+	}
+	for _, nodes := range g.byHelmChart {
+		sizes["chart"] += len(nodes)
+	}
+	return sizes
+}
+
+// WriteStateDump writes the state of a graph as JSON
+func WriteStateDump(w io.Writer, g Dumpable) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g.DumpState())
+}
+
+// StateDumpName returns the name of the file of a dump captured at t
+func StateDumpName(t time.Time) string {
+	return fmt.Sprintf("astrolabe-dump-%s.json", t.UTC().Format("20060102T150405.000Z"))
+}
+
+// WriteStateDumpFile writes the state of a graph to a timestamped file in dir and returns
+// its path
+func WriteStateDumpFile(dir string, g Dumpable) (string, error) {
+	name := filepath.Join(dir, StateDumpName(time.Now()))
+	file, err := os.Create(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create dump file: %w", err)
+	}
+	if err := WriteStateDump(file, g); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write dump file %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write dump file %s: %w", name, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in grouper names
+const (
+	GrouperHelm      = "helm"
+	GrouperArgoCD    = "argocd"
+	GrouperPartOf    = "part-of"
+	GrouperKustomize = "kustomize"
+)
+
+// Labels set by Flux on the resources it applies
+const (
+	FluxKustomizeNameLabel      = "kustomize.toolkit.fluxcd.io/name"
+	FluxKustomizeNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+	FluxHelmNameLabel           = "helm.toolkit.fluxcd.io/name"
+	FluxHelmNamespaceLabel      = "helm.toolkit.fluxcd.io/namespace"
+)
+
+// Grouper assigns nodes to applications. Every grouper maintains its own index, so a node can
+// belong to one application per grouper (e.g. a Helm release and an app.kubernetes.io/part-of group).
+type Grouper interface {
+	// Name identifies the grouper and is reported as the application source
+	Name() string
+	// Group returns the application the node belongs to, or "" if none
+	Group(node *Node) string
+}
+
+// helmGrouper groups nodes by the Helm release recorded on the node
+type helmGrouper struct{}
+
+func (helmGrouper) Name() string { return GrouperHelm }
+
+func (helmGrouper) Group(node *Node) string { return node.HelmRelease }
+
+// argoCDGrouper groups resources by the ArgoCD application tracking them, using either
+// label-based tracking or the tracking-id annotation. Application nodes belong to their own group.
+type argoCDGrouper struct{}
+
+func (argoCDGrouper) Name() string { return GrouperArgoCD }
+
+func (argoCDGrouper) Group(node *Node) string {
+	if node.Kind == "Application" && strings.HasPrefix(node.APIVersion, "argoproj.io/") {
+		return node.Name
+	}
+	if instance := node.Labels["argocd.argoproj.io/instance"]; instance != "" {
+		return instance
+	}
+	// <app>:<group>/<kind>:<namespace>/<name>, with <app> prefixed by "<namespace>_" for apps outside the control plane namespace
+	if trackingID := node.Annotations["argocd.argoproj.io/tracking-id"]; trackingID != "" {
+		app, _, _ := strings.Cut(trackingID, ":")
+		if _, name, found := strings.Cut(app, "_"); found {
+			return name
+		}
+		return app
+	}
+	return ""
+}
+
+// kustomizeGrouper groups resources by the Flux Kustomization that applied them.
+// Kustomization nodes belong to their own group.
+type kustomizeGrouper struct{}
+
+func (kustomizeGrouper) Name() string { return GrouperKustomize }
+
+func (kustomizeGrouper) Group(node *Node) string {
+	if node.Kind == "Kustomization" && strings.HasPrefix(node.APIVersion, "kustomize.toolkit.fluxcd.io/") {
+		return node.Name
+	}
+	return node.Labels[FluxKustomizeNameLabel]
+}
+
+// LabelGrouper groups nodes by the value of a label or annotation
+type LabelGrouper struct {
+	GrouperName string
+	Key         string
+	Annotation  bool // read the key from annotations instead of labels
+}
+
+func (l LabelGrouper) Name() string { return l.GrouperName }
+
+func (l LabelGrouper) Group(node *Node) string {
+	if l.Annotation {
+		return node.Annotations[l.Key]
+	}
+	return node.Labels[l.Key]
+}
+
+// BuiltinGroupers returns the groupers that can be enabled by name
+func BuiltinGroupers() map[string]Grouper {
+	return map[string]Grouper{
+		GrouperHelm:      helmGrouper{},
+		GrouperArgoCD:    argoCDGrouper{},
+		GrouperPartOf:    LabelGrouper{GrouperName: GrouperPartOf, Key: "app.kubernetes.io/part-of"},
+		GrouperKustomize: kustomizeGrouper{},
+	}
+}
+
+var (
+	groupersMu      sync.RWMutex
+	defaultGroupers = []Grouper{
+		helmGrouper{},
+		BuiltinGroupers()[GrouperArgoCD],
+		BuiltinGroupers()[GrouperPartOf],
+		BuiltinGroupers()[GrouperKustomize],
+	}
+)
+
+// SetDefaultGroupers sets the groupers used by graphs created afterwards. The Helm grouper is
+// always kept since release queries depend on it. Call it at startup before creating graphs.
+func SetDefaultGroupers(groupers []Grouper) {
+	groupersMu.Lock()
+	defer groupersMu.Unlock()
+
+	result := []Grouper{helmGrouper{}}
+	for _, grouper := range groupers {
+		if grouper.Name() != GrouperHelm {
+			result = append(result, grouper)
+		}
+	}
+	defaultGroupers = result
+}
+
+func currentGroupers() []Grouper {
+	groupersMu.RLock()
+	defer groupersMu.RUnlock()
+	return defaultGroupers
+}
+
+// Application is a group of nodes formed by a grouper
+type Application struct {
+	Name       string
+	Source     string // grouper name
+	Namespaces []string
+	Nodes      []*Node
+}
+
+// GetNodesByGroup returns all nodes a grouper assigned to the given application
+func (g *Graph) GetNodesByGroup(grouper, group string) []*Node {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if nodes, exists := g.byGroup[grouper][group]; exists {
+		return g.liveNodes(nodes)
+	}
+	return nil
+}
+
+// GetApplications returns the applications of every grouper, sorted by source and name
+func (g *Graph) GetApplications() []Application {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var apps []Application
+	for _, grouper := range g.groupers {
+		for name, nodes := range g.byGroup[grouper.Name()] {
+			nodes = g.liveNodes(nodes)
+			namespaces := make(map[string]bool)
+			for _, node := range nodes {
+				if node.Namespace != "" {
+					namespaces[node.Namespace] = true
+				}
+			}
+
+			app := Application{
+				Name:       name,
+				Source:     grouper.Name(),
+				Namespaces: make([]string, 0, len(namespaces)),
+				Nodes:      nodes,
+			}
+			for ns := range namespaces {
+				app.Namespaces = append(app.Namespaces, ns)
+			}
+			sort.Strings(app.Namespaces)
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].Source != apps[j].Source {
+			return apps[i].Source < apps[j].Source
+		}
+		return apps[i].Name < apps[j].Name
+	})
+	return apps
+}
+
+// groupsEqual reports whether the groupers assign both nodes to the same applications
+func (g *Graph) groupsEqual(a, b *Node) bool {
+	for _, grouper := range g.groupers {
+		if grouper.Group(a) != grouper.Group(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Graph) addToGroups(node *Node) {
+	for _, grouper := range g.groupers {
+		group := grouper.Group(node)
+		if group == "" {
+			continue
+		}
+		groups, exists := g.byGroup[grouper.Name()]
+		if !exists {
+			groups = make(map[string][]*Node)
+			g.byGroup[grouper.Name()] = groups
+		}
+		groups[group] = append(groups[group], node)
+	}
+}
+
+func (g *Graph) removeFromGroups(node *Node) {
+	for _, grouper := range g.groupers {
+		group := grouper.Group(node)
+		if group == "" {
+			continue
+		}
+		groups := g.byGroup[grouper.Name()]
+		if nodes, exists := groups[group]; exists {
+			groups[group] = g.removeNodeFromSlice(nodes, node.UID)
+			if len(groups[group]) == 0 {
+				delete(groups, group)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Helm releases are nodes of their own, built from their release Secrets by the Secret
+// processor and linked to their resources and revisions with part-of-release edges. Flux
+// HelmRelease objects share the kind, release nodes are told apart by their API version.
+const (
+	KindHelmRelease       = "HelmRelease"
+	HelmReleaseAPIVersion = "helm.sh/v3"
+)
+
+// ReleaseNodeUID returns the UID of the node of a Helm release installed in a namespace
+func ReleaseNodeUID(namespace, release string) types.UID {
+	return types.UID("helm.sh/release/" + namespace + "/" + release)
+}
+
+// IsReleaseNode reports whether a node is the node of a Helm release
+func IsReleaseNode(node *Node) bool {
+	return node.Kind == KindHelmRelease && node.APIVersion == HelmReleaseAPIVersion
+}
+
+// NewReleaseNode creates the node of a Helm release from its current revision (see
+// DeployedRevision). The node carries the revision without the manifest APIs and chart
+// dependencies, which stay on the revision's Secret.
+func NewReleaseNode(current *HelmRevision) *Node {
+	revision := *current
+	revision.APIs = nil
+	revision.Dependencies = nil
+
+	node := &Node{
+		UID:               ReleaseNodeUID(current.Namespace, current.Release),
+		Name:              current.Release,
+		Namespace:         current.Namespace,
+		Kind:              KindHelmRelease,
+		APIVersion:        HelmReleaseAPIVersion,
+		ResourceVersion:   fmt.Sprint(current.Revision),
+		CreationTimestamp: current.FirstDeployed,
+		HelmRelease:       current.Release,
+		HelmChart:         current.Chart + "-" + current.ChartVersion,
+		Metadata:          &ResourceMetadata{HelmRevision: &revision},
+		OutgoingEdges:     make(map[types.UID]*Edge),
+		IncomingEdges:     make(map[types.UID]*Edge),
+	}
+	node.Status, node.StatusReason = releaseStatus(current.Status)
+	node.StatusMessage = fmt.Sprintf("Revision %d %s", current.Revision, current.Status)
+	return node
+}
+
+// releaseStatus returns the status and reason of a release whose current revision has a
+// Helm status
+func releaseStatus(status string) (ResourceStatus, string) {
+	switch status {
+	case "deployed":
+		return StatusReady, ReasonReleaseDeployed
+	case "failed":
+		return StatusError, ReasonReleaseFailed
+	case "pending-install", "pending-upgrade", "pending-rollback":
+		return StatusPending, ReasonReleasePending
+	case "uninstalling":
+		return StatusPending, ReasonReleaseUninstalling
+	case "uninstalled":
+		return StatusUnknown, ReasonReleaseUninstalled
+	default:
+		return StatusUnknown, ReasonUnknownPhase
+	}
+}
+
+// HelmRevision is a revision of a Helm release, decoded from the release Secret Helm stores
+// for every install, upgrade and rollback
+type HelmRevision struct {
+	Release       string    `json:"release"`
+	Namespace     string    `json:"namespace"`
+	Revision      int       `json:"revision"`
+	Status        string    `json:"status"`
+	Chart         string    `json:"chart"`
+	ChartVersion  string    `json:"chartVersion"`
+	AppVersion    string    `json:"appVersion,omitempty"`
+	FirstDeployed time.Time `json:"firstDeployed"`
+	LastDeployed  time.Time `json:"lastDeployed"`
+	Description   string    `json:"description,omitempty"`
+	// RollbackTo is the revision a rollback restored, 0 when the revision is no rollback
+	RollbackTo int `json:"rollbackTo,omitempty"`
+	// ValuesDigest is a SHA-256 of the user-supplied values, to tell whether they changed
+	// between revisions without exposing them
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+	// Secret is the name of the Secret the revision is stored in
+	Secret string `json:"secret"`
+	// Dependencies are the subcharts the chart declares in its Chart.yaml
+	Dependencies []ChartDependency `json:"dependencies,omitempty"`
+	// APIs are the API versions and kinds of the objects in the revision's manifest
+	APIs []ManifestAPI `json:"apis,omitempty"`
+}
+
+// ManifestAPI is an API version and kind used by the objects of a Helm release manifest. Helm
+// compares the next upgrade against the stored manifest, so it fails when it uses an API
+// version the cluster no longer serves.
+type ManifestAPI struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// RollbackMarker tags the resources of a Helm release whose deployed revision is a rollback,
+// either made with helm rollback or by going back to a lower revision
+type RollbackMarker struct {
+	// Revision is the deployed revision
+	Revision int `json:"revision"`
+	// FromRevision is the revision deployed before the rollback
+	FromRevision int `json:"fromRevision"`
+	// ToRevision is the revision whose content was restored
+	ToRevision int `json:"toRevision"`
+	// RolledBackAt is when the rolled back revision was deployed
+	RolledBackAt time.Time `json:"rolledBackAt"`
+}
+
+// GetReleaseHistory returns the revisions of the Helm releases named release, sorted by
+// namespace and newest revision first
+func (g *Graph) GetReleaseHistory(release string) []HelmRevision {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	// Helm labels its release Secrets with name=<release> and owner=helm
+	var revisions []HelmRevision
+	for uid := range g.byLabel.withValue("name", release) {
+		node, exists := g.nodes[uid]
+		if !exists || node.Kind != "Secret" || node.Metadata == nil || node.Metadata.HelmRevision == nil {
+			continue
+		}
+		if node.Metadata.HelmRevision.Release == release {
+			revisions = append(revisions, *node.Metadata.HelmRevision)
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		a, b := revisions[i], revisions[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Revision > b.Revision
+	})
+	return revisions
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ID strategy names
+const (
+	IDStrategyUID        = "uid"
+	IDStrategyClusterUID = "cluster-uid"
+	IDStrategyLogical    = "logical"
+)
+
+// ObjectIdentity is everything an ID strategy may use to identify an object
+type ObjectIdentity struct {
+	UID       types.UID // Kubernetes UID
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// IDStrategy derives the graph ID of an object. Node.UID holds this ID; the Kubernetes UID is
+// kept in Node.SourceUID whenever the two differ.
+type IDStrategy interface {
+	Name() string
+	ID(obj ObjectIdentity) types.UID
+}
+
+// uidStrategy uses the Kubernetes UID as is (single cluster)
+type uidStrategy struct{}
+
+func (uidStrategy) Name() string { return IDStrategyUID }
+
+func (uidStrategy) ID(obj ObjectIdentity) types.UID { return obj.UID }
+
+// clusterUIDStrategy prefixes the Kubernetes UID with the cluster name, for graphs combining clusters
+type clusterUIDStrategy struct {
+	cluster string
+}
+
+func (clusterUIDStrategy) Name() string { return IDStrategyClusterUID }
+
+func (s clusterUIDStrategy) ID(obj ObjectIdentity) types.UID {
+	return types.UID(s.cluster + "/" + string(obj.UID))
+}
+
+// logicalStrategy identifies objects by [cluster:]namespace/kind/name, so a recreated object or
+// an imported/previewed manifest without a UID maps to the same node
+type logicalStrategy struct {
+	cluster string
+}
+
+func (logicalStrategy) Name() string { return IDStrategyLogical }
+
+func (s logicalStrategy) ID(obj ObjectIdentity) types.UID {
+	namespace := obj.Namespace
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	id := namespace + "/" + obj.Kind + "/" + obj.Name
+	if s.cluster != "" {
+		id = s.cluster + ":" + id
+	}
+	return types.UID(id)
+}
+
+// NewIDStrategy returns the named strategy; cluster is required by cluster-uid and optional for logical
+func NewIDStrategy(name, cluster string) (IDStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", IDStrategyUID:
+		return uidStrategy{}, nil
+	case IDStrategyClusterUID:
+		if cluster == "" {
+			return nil, fmt.Errorf("the %s ID strategy requires a cluster name", IDStrategyClusterUID)
+		}
+		return clusterUIDStrategy{cluster: cluster}, nil
+	case IDStrategyLogical:
+		return logicalStrategy{cluster: cluster}, nil
+	}
+	return nil, fmt.Errorf("unknown ID strategy %q (expected %s, %s or %s)", name, IDStrategyUID, IDStrategyClusterUID, IDStrategyLogical)
+}
+
+var (
+	idStrategyMu sync.RWMutex
+	idStrategy   IDStrategy = uidStrategy{}
+)
+
+// SetIDStrategy sets the strategy used to identify nodes. Call it at startup before any node is created.
+func SetIDStrategy(strategy IDStrategy) {
+	idStrategyMu.Lock()
+	defer idStrategyMu.Unlock()
+	idStrategy = strategy
+}
+
+// CurrentIDStrategy returns the strategy used to identify nodes
+func CurrentIDStrategy() IDStrategy {
+	idStrategyMu.RLock()
+	defer idStrategyMu.RUnlock()
+	return idStrategy
+}
+
+// ObjectID returns the graph ID of a Kubernetes object under the current strategy
+func ObjectID(uid types.UID, namespace, kind, name string) types.UID {
+	return CurrentIDStrategy().ID(ObjectIdentity{UID: uid, Namespace: namespace, Kind: kind, Name: name})
+}
+
+// KubernetesUID returns the Kubernetes UID of a node
+func (n *Node) KubernetesUID() types.UID {
+	if n.SourceUID != "" {
+		return n.SourceUID
+	}
+	return n.UID
+}
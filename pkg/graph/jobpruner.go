@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// JobPruner bounds the finished Jobs kept per CronJob. On clusters with frequent CronJobs,
+// completed Jobs and their Pods otherwise accumulate in the graph. The pruner keeps the
+// latest keepSucceeded succeeded and keepFailed failed Jobs of each CronJob, by creation
+// time, and removes the others with the Pods they own. A negative limit keeps all Jobs of
+// that outcome.
+//
+// Pruned Jobs are remembered until they are deleted from the cluster, so the Job and Pod
+// processors can skip them on informer resyncs instead of adding them back.
+type JobPruner struct {
+	graph         GraphInterface
+	keepSucceeded int
+	keepFailed    int
+	interval      time.Duration
+
+	mu     sync.Mutex
+	pruned map[types.UID]bool
+}
+
+// NewJobPruner creates a pruner keeping the given number of succeeded and failed Jobs per
+// CronJob, pruning every interval
+func NewJobPruner(g GraphInterface, keepSucceeded, keepFailed int, interval time.Duration) *JobPruner {
+	return &JobPruner{
+		graph:         g,
+		keepSucceeded: keepSucceeded,
+		keepFailed:    keepFailed,
+		interval:      interval,
+		pruned:        make(map[types.UID]bool),
+	}
+}
+
+// Start prunes every interval until ctx is cancelled
+func (p *JobPruner) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Prune removes the finished Jobs of each CronJob beyond the limits, with their Pods, and
+// returns the number of Jobs removed
+func (p *JobPruner) Prune() int {
+	var count int
+	for _, node := range p.graph.GetAllNodes() {
+		if node.Kind != "CronJob" || node.Cluster != "" {
+			continue
+		}
+
+		var succeeded, failed []*Node
+		for _, child := range p.graph.OwnedDescendants(node.UID) {
+			if child.Kind != "Job" {
+				continue
+			}
+			switch child.StatusReason {
+			case ReasonJobComplete:
+				succeeded = append(succeeded, child)
+			case ReasonJobFailed:
+				failed = append(failed, child)
+			}
+		}
+		count += p.prune(succeeded, p.keepSucceeded)
+		count += p.prune(failed, p.keepFailed)
+	}
+
+	if count > 0 {
+		klog.Infof("Job pruner: removed %d finished Job(s) beyond the CronJob limits", count)
+	}
+	return count
+}
+
+// prune removes the Jobs beyond the latest keep ones, with the nodes they own
+func (p *JobPruner) prune(jobs []*Node, keep int) int {
+	if keep < 0 || len(jobs) <= keep {
+		return 0
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreationTimestamp.Equal(jobs[j].CreationTimestamp) {
+			return jobs[i].CreationTimestamp.After(jobs[j].CreationTimestamp)
+		}
+		return jobs[i].Name > jobs[j].Name
+	})
+
+	for _, job := range jobs[keep:] {
+		p.mu.Lock()
+		p.pruned[job.UID] = true
+		p.mu.Unlock()
+
+		for _, owned := range p.graph.OwnedDescendants(job.UID) {
+			p.graph.RemoveNode(owned.UID)
+		}
+		p.graph.RemoveNode(job.UID)
+		klog.V(3).Infof("Job pruner: removed Job %s/%s", job.Namespace, job.Name)
+	}
+	pruned := len(jobs) - keep
+	metrics.JobsPruned.Add(float64(pruned))
+	return pruned
+}
+
+// Pruned reports whether a Job was pruned
+func (p *JobPruner) Pruned(uid types.UID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pruned[uid]
+}
+
+// Forget drops a pruned Job once it is deleted from the cluster
+func (p *JobPruner) Forget(uid types.UID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pruned, uid)
+}
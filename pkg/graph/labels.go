@@ -0,0 +1,258 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LabelOperator is the operator of a label requirement, as in Kubernetes set-based selectors
+type LabelOperator string
+
+const (
+	LabelEquals       LabelOperator = "="
+	LabelNotEquals    LabelOperator = "!="
+	LabelIn           LabelOperator = "in"
+	LabelNotIn        LabelOperator = "notin"
+	LabelExists       LabelOperator = "exists"
+	LabelDoesNotExist LabelOperator = "!"
+)
+
+// LabelRequirement is a condition on one label key. As in Kubernetes, the negative operators
+// (!=, notin and !) also match nodes that do not have the key.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	// Values holds one value for = and !=, at least one for in and notin, and none for
+	// exists and !
+	Values []string
+}
+
+// Validate checks that the requirement has the number of values its operator takes
+func (r LabelRequirement) Validate() error {
+	if r.Key == "" {
+		return fmt.Errorf("label requirement without key")
+	}
+	switch r.Operator {
+	case LabelEquals, LabelNotEquals:
+		if len(r.Values) != 1 {
+			return fmt.Errorf("label requirement %s %s takes exactly one value", r.Key, r.Operator)
+		}
+	case LabelIn, LabelNotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("label requirement %s %s takes at least one value", r.Key, r.Operator)
+		}
+	case LabelExists, LabelDoesNotExist:
+		if len(r.Values) != 0 {
+			return fmt.Errorf("label requirement %s %s takes no values", r.Key, r.Operator)
+		}
+	default:
+		return fmt.Errorf("unknown label operator %q", r.Operator)
+	}
+	return nil
+}
+
+// Matches reports whether a label set satisfies the requirement
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case LabelEquals, LabelIn:
+		return exists && containsString(r.Values, value)
+	case LabelNotEquals, LabelNotIn:
+		return !exists || !containsString(r.Values, value)
+	case LabelExists:
+		return exists
+	case LabelDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+// positive reports whether the requirement only matches nodes that have the key, so that
+// the index can enumerate its candidates
+func (r LabelRequirement) positive() bool {
+	return r.Operator == LabelEquals || r.Operator == LabelIn || r.Operator == LabelExists
+}
+
+// EqualityRequirements converts an equality-based selector to label requirements, sorted by
+// key
+func EqualityRequirements(selector map[string]string) []LabelRequirement {
+	requirements := make([]LabelRequirement, 0, len(selector))
+	for key, value := range selector {
+		requirements = append(requirements, LabelRequirement{Key: key, Operator: LabelEquals, Values: []string{value}})
+	}
+	sort.Slice(requirements, func(i, j int) bool { return requirements[i].Key < requirements[j].Key })
+	return requirements
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// uidSet is a set of node UIDs
+type uidSet map[types.UID]struct{}
+
+// labelIndex maps label keys, and the values of each key, to the nodes that carry them. Sets
+// make additions and removals constant time, and the per-key sets answer "has key"
+// requirements directly. Like the search index it holds UIDs only, which are resolved
+// against the nodes map under the graph lock.
+type labelIndex struct {
+	keys map[string]*labelKeyIndex
+}
+
+type labelKeyIndex struct {
+	nodes  uidSet            // nodes with the key, whatever its value
+	values map[string]uidSet // value -> nodes
+}
+
+func newLabelIndex() *labelIndex {
+	return &labelIndex{keys: make(map[string]*labelKeyIndex)}
+}
+
+// add indexes the labels of a node
+func (l *labelIndex) add(node *Node) {
+	for key, value := range node.Labels {
+		k, exists := l.keys[key]
+		if !exists {
+			k = &labelKeyIndex{nodes: make(uidSet), values: make(map[string]uidSet)}
+			l.keys[key] = k
+		}
+		k.nodes[node.UID] = struct{}{}
+		uids, exists := k.values[value]
+		if !exists {
+			uids = make(uidSet)
+			k.values[value] = uids
+		}
+		uids[node.UID] = struct{}{}
+	}
+}
+
+// remove drops the labels of a node, as last indexed
+func (l *labelIndex) remove(node *Node) {
+	for key, value := range node.Labels {
+		k, exists := l.keys[key]
+		if !exists {
+			continue
+		}
+		delete(k.nodes, node.UID)
+		if uids, exists := k.values[value]; exists {
+			delete(uids, node.UID)
+			if len(uids) == 0 {
+				delete(k.values, value)
+			}
+		}
+		if len(k.nodes) == 0 {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// withValue returns the nodes labeled key=value; the set must not be modified
+func (l *labelIndex) withValue(key, value string) uidSet {
+	if k, exists := l.keys[key]; exists {
+		return k.values[value]
+	}
+	return nil
+}
+
+// candidates returns the sets of the nodes that may match a positive requirement
+func (l *labelIndex) candidates(r LabelRequirement) []uidSet {
+	k, exists := l.keys[r.Key]
+	if !exists {
+		return nil
+	}
+	if r.Operator == LabelExists {
+		return []uidSet{k.nodes}
+	}
+	var sets []uidSet
+	for _, value := range r.Values {
+		if uids, exists := k.values[value]; exists {
+			sets = append(sets, uids)
+		}
+	}
+	return sets
+}
+
+// each calls fn for every indexed node UID, once per key it is indexed under
+func (l *labelIndex) each(fn func(key string, uid types.UID)) {
+	for key, k := range l.keys {
+		for uid := range k.nodes {
+			fn(key, uid)
+		}
+	}
+}
+
+// selectNodes returns the nodes of nodes matching all requirements. The candidates are taken
+// from the smallest index entry of the positive requirements, and only checked against the
+// other requirements; queries with negative requirements only are checked against all nodes.
+// Must be called with the graph lock held.
+func (l *labelIndex) selectNodes(nodes map[types.UID]*Node, requirements []LabelRequirement) []*Node {
+	var smallest []uidSet
+	smallestSize := -1
+	for _, r := range requirements {
+		if !r.positive() {
+			continue
+		}
+		sets := l.candidates(r)
+		size := 0
+		for _, uids := range sets {
+			size += len(uids)
+		}
+		if size == 0 {
+			return nil
+		}
+		if smallestSize < 0 || size < smallestSize {
+			smallest, smallestSize = sets, size
+		}
+	}
+
+	matches := func(node *Node) bool {
+		for _, r := range requirements {
+			if !r.Matches(node.Labels) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var result []*Node
+	if smallestSize < 0 {
+		for _, node := range nodes {
+			if matches(node) {
+				result = append(result, node)
+			}
+		}
+		return result
+	}
+	for _, uids := range smallest {
+		for uid := range uids {
+			if node, exists := nodes[uid]; exists && matches(node) {
+				result = append(result, node)
+			}
+		}
+	}
+	return result
+}
+
+// GetNodesByLabelRequirements returns the nodes matching all label requirements, or an error
+// when a requirement is invalid. No requirements match no nodes, like GetNodesByLabelSelector.
+func (g *Graph) GetNodesByLabelRequirements(requirements []LabelRequirement) ([]*Node, error) {
+	for _, r := range requirements {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.byLabel.selectNodes(g.nodes, requirements), nil
+}
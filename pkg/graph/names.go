@@ -0,0 +1,68 @@
+package graph
+
+import "k8s.io/apimachinery/pkg/types"
+
+// nameKey identifies nodes by namespace, kind and name
+type nameKey struct {
+	namespace, kind, name string
+}
+
+// nameIndex maps the namespace, kind and name of nodes to their UIDs, so processors resolve
+// references by name in constant time instead of scanning the namespace/kind index. Several
+// nodes may share a name, e.g. the same object received from federated clusters, or a
+// re-created object whose previous node was not removed yet. Like the label index it holds
+// UIDs only, which are resolved against the nodes map under the graph lock.
+type nameIndex map[nameKey]uidSet
+
+func (n nameIndex) add(node *Node) {
+	key := nameKey{node.Namespace, node.Kind, node.Name}
+	uids, exists := n[key]
+	if !exists {
+		uids = make(uidSet, 1)
+		n[key] = uids
+	}
+	uids[node.UID] = struct{}{}
+}
+
+func (n nameIndex) remove(node *Node) {
+	key := nameKey{node.Namespace, node.Kind, node.Name}
+	if uids, exists := n[key]; exists {
+		delete(uids, node.UID)
+		if len(uids) == 0 {
+			delete(n, key)
+		}
+	}
+}
+
+// lookup returns the node with a name, preferring the one of the local cluster, then the
+// lowest UID. Must be called with the graph lock held.
+func (n nameIndex) lookup(nodes map[types.UID]*Node, namespace, kind, name string) *Node {
+	var found *Node
+	for uid := range n[nameKey{namespace, kind, name}] {
+		node, exists := nodes[uid]
+		if !exists {
+			continue
+		}
+		if found == nil || preferred(node, found) {
+			found = node
+		}
+	}
+	return found
+}
+
+// preferred reports whether node is preferred over other among nodes with the same name
+func preferred(node, other *Node) bool {
+	if local := node.Cluster == ""; local != (other.Cluster == "") {
+		return local
+	}
+	return node.UID < other.UID
+}
+
+// GetNodeByRef returns the node of a kind with a name in a namespace ("" for cluster-scoped
+// kinds)
+func (g *Graph) GetNodeByRef(namespace, kind, name string) (*Node, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	node := g.byName.lookup(g.nodes, namespace, kind, name)
+	return node, node != nil
+}
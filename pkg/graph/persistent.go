@@ -1,9 +1,13 @@
 package graph
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/supervisor"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 )
@@ -17,42 +21,123 @@ type PersistenceBackend interface {
 	SaveEdge(edge *Edge) error
 	DeleteEdge(fromUID, toUID types.UID) error
 	GetAllEdges() ([]*Edge, error)
+	WriteBatch(ops []WriteOp) error
 	LoadGraph() (*Graph, error)
 	SaveGraph(g *Graph) error
 	Close() error
 }
 
+// LogCompactor is implemented by backends that append writes to a change log, which is
+// periodically folded into the stored graph
+type LogCompactor interface {
+	CompactLog() error
+}
+
+const (
+	defaultBatchSize      = 100
+	defaultFlushInterval  = 30 * time.Second
+	defaultEnqueueTimeout = time.Second
+	defaultMaxOverflow    = 100000
+)
+
+// PersistentGraphOptions configures how a PersistentGraph writes to its backend
+type PersistentGraphOptions struct {
+	// AsyncWrites queues writes and applies them in batches from a background worker
+	AsyncWrites bool
+	// BatchSize is the number of queued writes that triggers a batch (default 100)
+	BatchSize int
+	// FlushInterval is the maximum time queued writes wait before being applied (default 30s)
+	FlushInterval time.Duration
+	// EnqueueTimeout is how long a write waits for room in the full queue before it is spilled
+	// to the overflow buffer (default 1s)
+	EnqueueTimeout time.Duration
+	// MaxOverflow bounds the writes held in the overflow buffer and the retry list together;
+	// writes beyond it are dropped (default 100000)
+	MaxOverflow int
+}
+
 // PersistentGraph wraps a Graph with persistence capabilities
 type PersistentGraph struct {
 	*Graph
-	backend     PersistenceBackend
-	enabled     bool
-	asyncWrites bool
-	writeChan   chan writeOp
-	stopChan    chan struct{}
-	wg          sync.WaitGroup
+	backend       PersistenceBackend
+	enabled       bool
+	asyncWrites   bool
+	batchSize     int
+	flushInterval time.Duration
+	writeChan     chan WriteOp
+	ctx           context.Context // cancelled by Close to stop the async writer
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+
+	// Writes that did not fit the queue, and writes of failed batches to retry. Retries are
+	// older than queued writes, which are older than spilled ones; while writes are spilled,
+	// new writes are spilled too so they are applied in order. enqueueMu serializes the
+	// writers, so a write cannot be queued while an earlier one is being spilled.
+	enqueueMu      sync.Mutex
+	enqueueTimeout time.Duration
+	maxOverflow    int
+	overflowMu     sync.Mutex
+	overflow       []WriteOp
+	retry          []WriteOp
+	spilled        chan struct{}
+
+	// Nodes found missing by the previous consistency check
+	checkMu                 sync.Mutex
+	suspectMissingInBackend map[types.UID]bool
+	suspectMissingInMemory  map[types.UID]bool
 }
 
-type writeOp struct {
-	opType string // "saveNode", "deleteNode", "saveEdge", "deleteEdge"
-	node   *Node
-	edge   *Edge
-	uid    types.UID
-	toUID  types.UID
+// WriteOpType identifies a persistence operation
+type WriteOpType string
+
+const (
+	OpSaveNode   WriteOpType = "saveNode"
+	OpDeleteNode WriteOpType = "deleteNode"
+	OpSaveEdge   WriteOpType = "saveEdge"
+	OpDeleteEdge WriteOpType = "deleteEdge"
+)
+
+// WriteOp is a single persistence operation, applied by backends in batches
+type WriteOp struct {
+	Type  WriteOpType
+	Node  *Node     // OpSaveNode
+	Edge  *Edge     // OpSaveEdge
+	UID   types.UID // OpDeleteNode, and source of OpDeleteEdge
+	ToUID types.UID // target of OpDeleteEdge
 }
 
 // NewPersistentGraph creates a new graph with persistence
-func NewPersistentGraph(backend PersistenceBackend, asyncWrites bool) *PersistentGraph {
+func NewPersistentGraph(backend PersistenceBackend, opts PersistentGraphOptions) *PersistentGraph {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.EnqueueTimeout <= 0 {
+		opts.EnqueueTimeout = defaultEnqueueTimeout
+	}
+	if opts.MaxOverflow <= 0 {
+		opts.MaxOverflow = defaultMaxOverflow
+	}
+
 	pg := &PersistentGraph{
-		Graph:       NewGraph(),
-		backend:     backend,
-		enabled:     backend != nil,
-		asyncWrites: asyncWrites,
-		stopChan:    make(chan struct{}),
+		Graph:         NewGraph(),
+		backend:       backend,
+		enabled:       backend != nil,
+		asyncWrites:   opts.AsyncWrites,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+
+		enqueueTimeout: opts.EnqueueTimeout,
+		maxOverflow:    opts.MaxOverflow,
+		spilled:        make(chan struct{}, 1),
 	}
+	pg.ctx, pg.cancel = context.WithCancel(context.Background())
 
-	if pg.enabled && asyncWrites {
-		pg.writeChan = make(chan writeOp, 1000) // Buffer for async writes
+	if pg.enabled && pg.asyncWrites {
+		// Buffer several batches so bursts don't block the informers
+		pg.writeChan = make(chan WriteOp, 10*pg.batchSize)
 		pg.startAsyncWriter()
 	}
 
@@ -90,17 +175,32 @@ func (pg *PersistentGraph) AddNode(node *Node) {
 	// Persist
 	if pg.enabled {
 		if pg.asyncWrites {
-			select {
-			case pg.writeChan <- writeOp{opType: "saveNode", node: node}:
-			default:
-				klog.Warning("Write channel full, dropping async write")
+			pg.enqueue(WriteOp{Type: OpSaveNode, Node: node})
+		} else {
+			if err := pg.backend.SaveNode(node); err != nil {
+				klog.Errorf("Failed to persist node %s: %v", node.UID, err)
 			}
+		}
+	}
+}
+
+// UpdateNode updates a node and persists it
+func (pg *PersistentGraph) UpdateNode(uid types.UID, update func(node *Node) bool) (*Node, bool) {
+	node, updated := pg.Graph.UpdateNode(uid, update)
+	if !updated {
+		return nil, false
+	}
+
+	if pg.enabled {
+		if pg.asyncWrites {
+			pg.enqueue(WriteOp{Type: OpSaveNode, Node: node})
 		} else {
 			if err := pg.backend.SaveNode(node); err != nil {
 				klog.Errorf("Failed to persist node %s: %v", node.UID, err)
 			}
 		}
 	}
+	return node, true
 }
 
 // RemoveNode removes a node and deletes it from persistence
@@ -111,11 +211,7 @@ func (pg *PersistentGraph) RemoveNode(uid types.UID) {
 	// Delete from persistence
 	if pg.enabled {
 		if pg.asyncWrites {
-			select {
-			case pg.writeChan <- writeOp{opType: "deleteNode", uid: uid}:
-			default:
-				klog.Warning("Write channel full, dropping async delete")
-			}
+			pg.enqueue(WriteOp{Type: OpDeleteNode, UID: uid})
 		} else {
 			if err := pg.backend.DeleteNode(uid); err != nil {
 				klog.Errorf("Failed to delete node %s from persistence: %v", uid, err)
@@ -136,11 +232,7 @@ func (pg *PersistentGraph) AddEdge(edge *Edge) bool {
 	// Persist
 	if pg.enabled {
 		if pg.asyncWrites {
-			select {
-			case pg.writeChan <- writeOp{opType: "saveEdge", edge: edge}:
-			default:
-				klog.Warning("Write channel full, dropping async edge write")
-			}
+			pg.enqueue(WriteOp{Type: OpSaveEdge, Edge: edge})
 		} else {
 			if err := pg.backend.SaveEdge(edge); err != nil {
 				klog.Errorf("Failed to persist edge %s->%s: %v", edge.FromUID, edge.ToUID, err)
@@ -159,11 +251,7 @@ func (pg *PersistentGraph) RemoveEdge(fromUID, toUID types.UID) {
 	// Delete from persistence
 	if pg.enabled {
 		if pg.asyncWrites {
-			select {
-			case pg.writeChan <- writeOp{opType: "deleteEdge", uid: fromUID, toUID: toUID}:
-			default:
-				klog.Warning("Write channel full, dropping async edge delete")
-			}
+			pg.enqueue(WriteOp{Type: OpDeleteEdge, UID: fromUID, ToUID: toUID})
 		} else {
 			if err := pg.backend.DeleteEdge(fromUID, toUID); err != nil {
 				klog.Errorf("Failed to delete edge from persistence: %v", err)
@@ -189,6 +277,25 @@ func (pg *PersistentGraph) Snapshot() error {
 	return nil
 }
 
+// Compact folds the change log of the backend into the stored graph; the cost follows the
+// number of writes since the last compaction rather than the size of the graph
+func (pg *PersistentGraph) Compact() error {
+	if !pg.enabled {
+		return nil
+	}
+	compactor, ok := pg.backend.(LogCompactor)
+	if !ok {
+		return fmt.Errorf("persistence backend %T has no change log", pg.backend)
+	}
+
+	start := time.Now()
+	if err := compactor.CompactLog(); err != nil {
+		return err
+	}
+	klog.V(2).Infof("Change log compaction completed in %v", time.Since(start))
+	return nil
+}
+
 // Close closes the persistent graph and flushes pending writes
 func (pg *PersistentGraph) Close() error {
 	if !pg.enabled {
@@ -197,92 +304,195 @@ func (pg *PersistentGraph) Close() error {
 
 	if pg.asyncWrites {
 		// Stop async writer
-		close(pg.stopChan)
+		pg.cancel()
 		pg.wg.Wait()
 
-		// Flush remaining writes
+		// Flush remaining writes: retries, then queued, then spilled writes
 		close(pg.writeChan)
+		remaining := make([]WriteOp, 0, len(pg.writeChan))
 		for op := range pg.writeChan {
-			pg.executeWriteOp(op)
+			remaining = append(remaining, op)
+		}
+		pg.overflowMu.Lock()
+		remaining = append(append(pg.retry, remaining...), pg.overflow...)
+		pg.retry, pg.overflow = nil, nil
+		pg.overflowMu.Unlock()
+		if len(remaining) > 0 {
+			pg.executeBatch(remaining)
+		}
+		pg.overflowMu.Lock()
+		if lost := len(pg.retry); lost > 0 {
+			metrics.PersistenceWrites.WithLabelValues("dropped").Add(float64(lost))
+			klog.Errorf("Dropped %d writes that could not be persisted before shutdown", lost)
 		}
+		pg.overflowMu.Unlock()
 	}
 
 	// Close backend
 	return pg.backend.Close()
 }
 
-// startAsyncWriter starts the async write worker
+// startAsyncWriter starts the async write worker, restarted by the supervisor if it fails
 func (pg *PersistentGraph) startAsyncWriter() {
 	pg.wg.Add(1)
 	go func() {
 		defer pg.wg.Done()
-
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-
-		batchSize := 100
-		batch := make([]writeOp, 0, batchSize)
-
-		for {
-			select {
-			case op := <-pg.writeChan:
-				batch = append(batch, op)
-
-				// Execute batch when full
-				if len(batch) >= batchSize {
-					pg.executeBatch(batch)
-					batch = batch[:0]
-				}
-
-			case <-ticker.C:
-				// Periodic flush
-				if len(batch) > 0 {
-					pg.executeBatch(batch)
-					batch = batch[:0]
-				}
-
-			case <-pg.stopChan:
-				// Final flush
-				if len(batch) > 0 {
-					pg.executeBatch(batch)
-				}
-				return
-			}
-		}
+		supervisor.Run(pg.ctx, "async-writer", pg.writeLoop)
 	}()
 }
 
-// executeBatch executes a batch of write operations
-func (pg *PersistentGraph) executeBatch(batch []writeOp) {
-	start := time.Now()
+// enqueue queues a write for the async writer. When the queue is full, the caller waits up
+// to the enqueue timeout for room, slowing down the informers, then spills the write to the
+// overflow buffer. Writes are only dropped when the overflow buffer is full too.
+func (pg *PersistentGraph) enqueue(op WriteOp) {
+	// Deciding whether to spill and queueing must not interleave with other writers, or a write
+	// queued after a spilled one, e.g. the delete of a node whose save was spilled, would be
+	// applied first. The async writer never takes this lock.
+	pg.enqueueMu.Lock()
+	defer pg.enqueueMu.Unlock()
+
+	pg.overflowMu.Lock()
+	if len(pg.overflow) > 0 {
+		// Keep the order: the writer applies spilled writes after the queued ones
+		pg.spill(op)
+		pg.overflowMu.Unlock()
+		return
+	}
+	pg.overflowMu.Unlock()
 
-	for _, op := range batch {
-		pg.executeWriteOp(op)
+	select {
+	case pg.writeChan <- op:
+		return
+	default:
 	}
 
-	klog.V(4).Infof("Executed batch of %d writes in %v", len(batch), time.Since(start))
+	timer := time.NewTimer(pg.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case pg.writeChan <- op:
+		metrics.PersistenceWrites.WithLabelValues("delayed").Inc()
+	case <-timer.C:
+		pg.overflowMu.Lock()
+		pg.spill(op)
+		pg.overflowMu.Unlock()
+	}
 }
 
-// executeWriteOp executes a single write operation
-func (pg *PersistentGraph) executeWriteOp(op writeOp) {
-	var err error
-
-	switch op.opType {
-	case "saveNode":
-		err = pg.backend.SaveNode(op.node)
-	case "deleteNode":
-		err = pg.backend.DeleteNode(op.uid)
-	case "saveEdge":
-		err = pg.backend.SaveEdge(op.edge)
-	case "deleteEdge":
-		err = pg.backend.DeleteEdge(op.uid, op.toUID)
+// spill adds a write to the overflow buffer and wakes up the writer; overflowMu must be held
+func (pg *PersistentGraph) spill(op WriteOp) {
+	if len(pg.overflow)+len(pg.retry) >= pg.maxOverflow {
+		metrics.PersistenceWrites.WithLabelValues("dropped").Inc()
+		klog.Warningf("Write queue and overflow buffer full, dropping %s write", op.Type)
+		return
+	}
+	pg.overflow = append(pg.overflow, op)
+	metrics.PersistenceWrites.WithLabelValues("spilled").Inc()
+	metrics.PersistenceOverflow.Set(float64(len(pg.overflow) + len(pg.retry)))
+	select {
+	case pg.spilled <- struct{}{}:
+	default:
 	}
+}
 
-	if err != nil {
-		klog.Errorf("Failed to execute %s: %v", op.opType, err)
+// writeLoop batches queued writes until ctx is cancelled. A batch being built when the loop
+// panics is lost; queued writes are picked up by the restarted loop.
+func (pg *PersistentGraph) writeLoop(ctx context.Context) error {
+	ticker := time.NewTicker(pg.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]WriteOp, 0, pg.batchSize)
+
+	for {
+		select {
+		case op := <-pg.writeChan:
+			batch = append(batch, op)
+
+			// Execute batch when full
+			if len(batch) >= pg.batchSize {
+				pg.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-pg.spilled:
+			// Apply what was queued before the spilled writes, then the spilled writes
+			for len(pg.writeChan) > 0 {
+				batch = append(batch, <-pg.writeChan)
+			}
+			pg.flush(batch)
+			batch = batch[:0]
+
+		case <-ticker.C:
+			// Periodic flush
+			pg.flush(batch)
+			batch = batch[:0]
+
+		case <-ctx.Done():
+			// Final flush
+			pg.flush(batch)
+			return nil
+		}
+	}
+}
+
+// flush applies the writes to retry and the batch, then the spilled writes once the queue is
+// drained, in chunks of the batch size
+func (pg *PersistentGraph) flush(batch []WriteOp) {
+	pg.overflowMu.Lock()
+	ops := append(pg.retry, batch...)
+	pg.retry = nil
+	pg.overflowMu.Unlock()
+	if len(ops) > 0 && !pg.executeBatch(ops) {
+		return
+	}
+
+	for len(pg.writeChan) == 0 {
+		pg.overflowMu.Lock()
+		n := min(len(pg.overflow), pg.batchSize)
+		chunk := append([]WriteOp(nil), pg.overflow[:n]...)
+		pg.overflow = pg.overflow[n:]
+		if len(pg.overflow) == 0 {
+			pg.overflow = nil
+		}
+		pg.overflowMu.Unlock()
+		if n == 0 || !pg.executeBatch(chunk) {
+			return
+		}
 	}
 }
 
+// executeBatch applies write operations to the backend in batches. When a batch fails, it and
+// the batches after it are kept for retry with the next flush, and false is returned.
+func (pg *PersistentGraph) executeBatch(ops []WriteOp) bool {
+	for start := 0; start < len(ops); start += pg.batchSize {
+		batch := ops[start:min(start+pg.batchSize, len(ops))]
+		begin := time.Now()
+
+		if err := pg.backend.WriteBatch(batch); err != nil {
+			failed := ops[start:]
+			klog.Errorf("Failed to execute batch of %d writes, retrying %d writes: %v", len(batch), len(failed), err)
+			pg.overflowMu.Lock()
+			pg.retry = append(pg.retry, failed...)
+			if excess := len(pg.retry) + len(pg.overflow) - pg.maxOverflow; excess > 0 {
+				// Drop the newest retries, the overflow buffer is kept in order after them
+				pg.retry = pg.retry[:max(len(pg.retry)-excess, 0)]
+				metrics.PersistenceWrites.WithLabelValues("dropped").Add(float64(excess))
+				klog.Warningf("Retry list full, dropping %d writes", excess)
+			}
+			metrics.PersistenceWrites.WithLabelValues("retried").Add(float64(len(failed)))
+			metrics.PersistenceOverflow.Set(float64(len(pg.overflow) + len(pg.retry)))
+			pg.overflowMu.Unlock()
+			return false
+		}
+
+		klog.V(4).Infof("Executed batch of %d writes in %v", len(batch), time.Since(begin))
+	}
+
+	pg.overflowMu.Lock()
+	metrics.PersistenceOverflow.Set(float64(len(pg.overflow) + len(pg.retry)))
+	pg.overflowMu.Unlock()
+	return true
+}
+
 // GetBackend returns the persistence backend
 func (pg *PersistentGraph) GetBackend() PersistenceBackend {
 	return pg.backend
@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+)
+
+// observedCreationGrace is how soon after its creation a resource must have been first seen for
+// its creation to count as observed
+const observedCreationGrace = time.Minute
+
+// trackReadiness carries the first-seen and first-ready times of a node over from the node it
+// replaces, and sets them when the node is first seen and first Ready
+func trackReadiness(old, node *Node) {
+	if old != nil {
+		node.FirstSeen = old.FirstSeen
+		if node.FirstReady.IsZero() {
+			node.FirstReady = old.FirstReady
+		}
+	}
+
+	now := time.Now()
+	if node.FirstSeen.IsZero() {
+		node.FirstSeen = now
+	}
+	if node.FirstReady.IsZero() && node.Status == StatusReady {
+		node.FirstReady = now
+		if timeToReady, ok := node.TimeToReady(); ok {
+			metrics.TimeToReady.WithLabelValues(node.Kind).Observe(timeToReady.Seconds())
+		}
+	}
+}
+
+// TimeToReady returns how long the resource took from its creation to first being Ready. It is
+// only known when Astrolabe saw the resource being created, or saw it become Ready, since the
+// resources found Ready at startup could have become Ready at any time.
+func (n *Node) TimeToReady() (time.Duration, bool) {
+	if n.FirstReady.IsZero() || n.CreationTimestamp.IsZero() {
+		return 0, false
+	}
+	seenCreated := n.FirstSeen.Sub(n.CreationTimestamp) <= observedCreationGrace
+	seenBecomingReady := n.FirstReady.After(n.FirstSeen)
+	if !seenCreated && !seenBecomingReady {
+		return 0, false
+	}
+	// Creation timestamps come from the API server's clock
+	return max(n.FirstReady.Sub(n.CreationTimestamp), 0), true
+}
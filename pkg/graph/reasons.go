@@ -0,0 +1,101 @@
+package graph
+
+// Status reasons are machine-readable codes explaining a node's status, set alongside the
+// human-readable StatusMessage. Clients should build logic and translations on reasons rather
+// than parsing messages. Where Kubernetes or a controller reports a reason of its own (e.g.
+// ImagePullBackOff or CrashLoopBackOff for containers, the Ready condition reason of Flux
+// objects), that reason is used as is.
+const (
+	// Generic
+	ReasonExists       = "Exists"
+	ReasonOwnerDeleted = "OwnerDeleted"
+	ReasonUnknownPhase = "UnknownPhase"
+	ReasonNotInCluster = "NotInCluster"
+
+	// Pods
+	ReasonPodRunning          = "PodRunning"
+	ReasonPodPending          = "PodPending"
+	ReasonPodSucceeded        = "PodSucceeded"
+	ReasonPodFailed           = "PodFailed"
+	ReasonPodStatusUnknown    = "PodStatusUnknown"
+	ReasonContainerNotReady   = "ContainerNotReady"
+	ReasonContainerTerminated = "ContainerTerminated"
+
+	// Workloads
+	ReasonScaledToZero           = "ScaledToZero"
+	ReasonReplicasReady          = "ReplicasReady"
+	ReasonReplicasUnavailable    = "ReplicasUnavailable"
+	ReasonReplicasPartiallyReady = "ReplicasPartiallyReady"
+	ReasonNoNodesToSchedule      = "NoNodesToSchedule"
+	ReasonJobComplete            = "JobComplete"
+	ReasonJobFailed              = "JobFailed"
+	ReasonJobRunning             = "JobRunning"
+	ReasonJobPending             = "JobPending"
+	ReasonJobsActive             = "JobsActive"
+	ReasonScheduled              = "Scheduled"
+
+	// Storage
+	ReasonPVCBound    = "PVCBound"
+	ReasonPVCUnbound  = "PVCUnbound"
+	ReasonPVCLost     = "PVCLost"
+	ReasonPVBound     = "PVBound"
+	ReasonPVAvailable = "PVAvailable"
+	ReasonPVReleased  = "PVReleased"
+	ReasonPVFailed    = "PVFailed"
+
+	// Volume attachments
+	ReasonVolumeAttached  = "VolumeAttached"
+	ReasonVolumeAttaching = "VolumeAttaching"
+	ReasonAttachFailed    = "AttachFailed"
+	ReasonDetachFailed    = "DetachFailed"
+
+	// Namespaces
+	ReasonNamespaceActive      = "NamespaceActive"
+	ReasonNamespaceTerminating = "NamespaceTerminating"
+
+	// Networking and policy
+	ReasonServiceActive           = "ServiceActive"
+	ReasonLoadBalancerReady       = "LoadBalancerReady"
+	ReasonLoadBalancerPending     = "LoadBalancerPending"
+	ReasonEndpointsReady          = "EndpointsReady"
+	ReasonNoReadyEndpoints        = "NoReadyEndpoints"
+	ReasonAbleToScale             = "AbleToScale"
+	ReasonUnableToScale           = "UnableToScale"
+	ReasonDisruptionBudgetMet     = "DisruptionBudgetMet"
+	ReasonInsufficientHealthyPods = "InsufficientHealthyPods"
+
+	// Helm releases, from the status of their current revision
+	ReasonReleaseDeployed     = "ReleaseDeployed"
+	ReasonReleaseFailed       = "ReleaseFailed"
+	ReasonReleasePending      = "ReleasePending"
+	ReasonReleaseUninstalling = "ReleaseUninstalling"
+	ReasonReleaseUninstalled  = "ReleaseUninstalled"
+
+	// kstatus (see processors.KStatus)
+	ReasonCurrent               = "Current"
+	ReasonTerminating           = "Terminating"
+	ReasonGenerationNotObserved = "LatestGenerationNotObserved"
+	ReasonStalled               = "Stalled"
+	ReasonReconciling           = "Reconciling"
+
+	// Cluster API Machines (MachineSets and MachineDeployments use the workload reasons)
+	ReasonMachineRunning      = "MachineRunning"
+	ReasonMachineProvisioning = "MachineProvisioning"
+	ReasonMachineDeleting     = "MachineDeleting"
+	ReasonMachineFailed       = "MachineFailed"
+
+	// cert-manager Certificates (Issuers and CertificateRequests use the reasons of their
+	// conditions)
+	ReasonCertificateValid          = "CertificateValid"
+	ReasonCertificateIssuing        = "CertificateIssuing"
+	ReasonCertificateNotReady       = "CertificateNotReady"
+	ReasonCertificateExpired        = "CertificateExpired"
+	ReasonCertificateExpiringSoon   = "CertificateExpiringSoon"
+	ReasonCertificateRenewalFailing = "CertificateRenewalFailing"
+	ReasonCertificateRenewalOverdue = "CertificateRenewalOverdue"
+
+	// GitOps
+	ReasonOutOfSync     = "OutOfSync"
+	ReasonSuspended     = "Suspended"
+	ReasonNotReconciled = "NotReconciled"
+)
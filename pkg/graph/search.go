@@ -0,0 +1,335 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// SearchField is the node field a search term was taken from
+type SearchField string
+
+const (
+	SearchFieldName      SearchField = "name"
+	SearchFieldImage     SearchField = "image"
+	SearchFieldLabel     SearchField = "label"
+	SearchFieldNamespace SearchField = "namespace"
+)
+
+// Weights of the fields when ranking results: a name match ranks above the same image match
+var searchFieldWeights = map[SearchField]float64{
+	SearchFieldName:      1.0,
+	SearchFieldImage:     0.9,
+	SearchFieldLabel:     0.8,
+	SearchFieldNamespace: 0.7,
+}
+
+// SearchResult is a node matching a search query
+type SearchResult struct {
+	Node  *Node
+	Score float64
+	// Matches lists the fields matched by the query
+	Matches []SearchField
+}
+
+// searchIndex maps the terms of node names, namespaces, images and labels to the nodes they
+// occur in. It is maintained incrementally with the other indexes and holds UIDs only, so
+// in-place node updates only need to reindex when the terms change.
+type searchIndex struct {
+	terms map[string]map[types.UID]SearchField // term -> nodes -> highest weighted field
+	byUID map[types.UID][]searchTerm
+}
+
+type searchTerm struct {
+	term  string
+	field SearchField
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		terms: make(map[string]map[types.UID]SearchField),
+		byUID: make(map[types.UID][]searchTerm),
+	}
+}
+
+// add indexes the terms of a node, replacing the terms of a previous version
+func (s *searchIndex) add(node *Node) {
+	terms := nodeSearchTerms(node)
+	if previous, exists := s.byUID[node.UID]; exists {
+		if searchTermsEqual(previous, terms) {
+			return
+		}
+		s.remove(node.UID)
+	}
+
+	for _, t := range terms {
+		uids, exists := s.terms[t.term]
+		if !exists {
+			uids = make(map[types.UID]SearchField)
+			s.terms[t.term] = uids
+		}
+		if field, exists := uids[node.UID]; !exists || searchFieldWeights[t.field] > searchFieldWeights[field] {
+			uids[node.UID] = t.field
+		}
+	}
+	s.byUID[node.UID] = terms
+}
+
+// remove drops the terms of a node
+func (s *searchIndex) remove(uid types.UID) {
+	for _, t := range s.byUID[uid] {
+		if uids, exists := s.terms[t.term]; exists {
+			delete(uids, uid)
+			if len(uids) == 0 {
+				delete(s.terms, t.term)
+			}
+		}
+	}
+	delete(s.byUID, uid)
+}
+
+// nodeSearchTerms returns the lowercased terms a node can be found by: its name and the parts
+// of it, its namespace, the repository names and references of its images, and its labels as
+// key=value and value
+func nodeSearchTerms(node *Node) []searchTerm {
+	seen := make(map[searchTerm]bool)
+	var terms []searchTerm
+	add := func(term string, field SearchField) {
+		term = strings.ToLower(term)
+		t := searchTerm{term: term, field: field}
+		if term == "" || seen[t] {
+			return
+		}
+		seen[t] = true
+		terms = append(terms, t)
+	}
+
+	add(node.Name, SearchFieldName)
+	for _, part := range splitSearchTerm(node.Name) {
+		add(part, SearchFieldName)
+	}
+	add(node.Namespace, SearchFieldNamespace)
+
+	if node.Metadata != nil {
+		images := []string{node.Metadata.Image}
+		for _, container := range node.Metadata.Containers {
+			images = append(images, container.Image)
+		}
+		for _, image := range images {
+			if image == "" {
+				continue
+			}
+			add(image, SearchFieldImage)
+			add(imageRepositoryName(image), SearchFieldImage)
+		}
+	}
+
+	for key, value := range node.Labels {
+		add(key+"="+value, SearchFieldLabel)
+		add(value, SearchFieldLabel)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].term != terms[j].term {
+			return terms[i].term < terms[j].term
+		}
+		return terms[i].field < terms[j].field
+	})
+	return terms
+}
+
+func searchTermsEqual(a, b []searchTerm) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSearchTerm splits a name into its dash, dot and underscore separated parts
+func splitSearchTerm(value string) []string {
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '-' || r == '.' || r == '_'
+	})
+	if len(parts) < 2 {
+		return nil
+	}
+	return parts
+}
+
+// imageRepositoryName returns the last path element of an image reference without tag or
+// digest, e.g. nginx for docker.io/library/nginx:1.25
+func imageRepositoryName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.Index(image, ":"); i >= 0 {
+		image = image[:i]
+	}
+	return image
+}
+
+// matchScore rates how well a query word matches an indexed term: exact matches score
+// highest, followed by prefix, substring and fuzzy (edit distance) matches. It returns 0 if
+// the term does not match.
+func matchScore(word, term string) float64 {
+	switch {
+	case term == word:
+		return 1.0
+	case strings.HasPrefix(term, word):
+		return 0.7 + 0.2*float64(len(word))/float64(len(term))
+	case len(word) >= 3 && strings.Contains(term, word):
+		return 0.5 + 0.2*float64(len(word))/float64(len(term))
+	}
+
+	// Fuzzy matches tolerate typos, against the whole term or a prefix of the same length
+	if len(word) < 4 {
+		return 0
+	}
+	maxEdits := 1
+	if len(word) >= 8 {
+		maxEdits = 2
+	}
+	if len(term) < len(word)-maxEdits {
+		return 0
+	}
+	distance := editDistance(word, term, maxEdits)
+	if len(term) > len(word) {
+		distance = min(distance, editDistance(word, term[:len(word)], maxEdits))
+	}
+	if distance > maxEdits {
+		return 0
+	}
+	return 0.4 - 0.1*float64(distance)
+}
+
+// editDistance returns the Levenshtein distance between a and b, or limit+1 once it exceeds limit
+func editDistance(a, b string, limit int) int {
+	if abs(len(a)-len(b)) > limit {
+		return limit + 1
+	}
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Search returns the nodes whose name, namespace, images or labels match every word of the
+// query, by prefix, substring or fuzzy match, ranked by score (highest first). A limit of 0
+// returns all matches.
+func (g *Graph) Search(query string, limit int) []SearchResult {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	type match struct {
+		score  float64
+		fields map[SearchField]bool
+	}
+	var matches map[types.UID]*match
+
+	for i, word := range words {
+		// Best score of the word per node
+		best := make(map[types.UID]float64)
+		fields := make(map[types.UID]SearchField)
+		for term, uids := range g.search.terms {
+			score := matchScore(word, term)
+			if score == 0 {
+				continue
+			}
+			for uid, field := range uids {
+				if i > 0 && matches[uid] == nil {
+					continue
+				}
+				if weighted := score * searchFieldWeights[field]; weighted > best[uid] {
+					best[uid] = weighted
+					fields[uid] = field
+				}
+			}
+		}
+
+		// Every word must match
+		next := make(map[types.UID]*match, len(best))
+		for uid, score := range best {
+			m := matches[uid]
+			if m == nil {
+				m = &match{fields: make(map[SearchField]bool)}
+			}
+			m.score += score
+			m.fields[fields[uid]] = true
+			next[uid] = m
+		}
+		matches = next
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for uid, m := range matches {
+		node, exists := g.nodes[uid]
+		if !exists {
+			continue
+		}
+		result := SearchResult{Node: node, Score: m.score / float64(len(words))}
+		for field := range m.fields {
+			result.Matches = append(result.Matches, field)
+		}
+		sort.Slice(result.Matches, func(i, j int) bool {
+			return searchFieldWeights[result.Matches[i]] > searchFieldWeights[result.Matches[j]]
+		})
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Node.Kind != b.Node.Kind {
+			return a.Node.Kind < b.Node.Kind
+		}
+		if a.Node.Namespace != b.Node.Namespace {
+			return a.Node.Namespace < b.Node.Namespace
+		}
+		return a.Node.Name < b.Node.Name
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
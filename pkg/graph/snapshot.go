@@ -0,0 +1,177 @@
+package graph
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// SnapshotView serves reads from an atomically swapped copy of the graph.
+// The copy is rebuilt periodically when the live graph changed, so readers never
+// contend with writers on the graph lock at the cost of slightly stale results.
+// Writes are forwarded to the live graph.
+type SnapshotView struct {
+	live     GraphInterface
+	interval time.Duration
+	current  atomic.Pointer[Graph]
+}
+
+// NewSnapshotView creates a snapshot view over the live graph, rebuilt at most once per interval
+func NewSnapshotView(live GraphInterface, interval time.Duration) *SnapshotView {
+	v := &SnapshotView{
+		live:     live,
+		interval: interval,
+	}
+	v.rebuild()
+	return v
+}
+
+// Start rebuilds the snapshot on every tick when the live graph changed, until ctx is cancelled
+func (v *SnapshotView) Start(ctx context.Context) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if v.live.Generation() != v.current.Load().generation {
+				v.rebuild()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Current returns the snapshot currently served to readers
+func (v *SnapshotView) Current() *Graph {
+	return v.current.Load()
+}
+
+func (v *SnapshotView) rebuild() {
+	start := time.Now()
+	snapshot := v.live.Clone()
+	v.current.Store(snapshot)
+	klog.V(4).Infof("Rebuilt read snapshot (generation %d, %d nodes) in %v", snapshot.generation, len(snapshot.nodes), time.Since(start))
+}
+
+// Read methods are served from the current snapshot
+
+func (v *SnapshotView) GetNode(uid types.UID) (*Node, bool) {
+	return v.Current().GetNode(uid)
+}
+
+func (v *SnapshotView) GetAllNodes() []*Node {
+	return v.Current().GetAllNodes()
+}
+
+func (v *SnapshotView) GetNodesByNamespaceKind(namespace, kind string) []*Node {
+	return v.Current().GetNodesByNamespaceKind(namespace, kind)
+}
+
+func (v *SnapshotView) GetNodeByRef(namespace, kind, name string) (*Node, bool) {
+	return v.Current().GetNodeByRef(namespace, kind, name)
+}
+
+func (v *SnapshotView) GetNodesByHelmRelease(release string) []*Node {
+	return v.Current().GetNodesByHelmRelease(release)
+}
+
+func (v *SnapshotView) GetNodesByLabelRequirements(requirements []LabelRequirement) ([]*Node, error) {
+	return v.Current().GetNodesByLabelRequirements(requirements)
+}
+
+func (v *SnapshotView) GetAllHelmReleases() []string {
+	return v.Current().GetAllHelmReleases()
+}
+
+func (v *SnapshotView) GetAllHelmCharts() []string {
+	return v.Current().GetAllHelmCharts()
+}
+
+func (v *SnapshotView) GetChartReleases(chart string) []ChartRelease {
+	return v.Current().GetChartReleases(chart)
+}
+
+func (v *SnapshotView) GetReleaseHistory(release string) []HelmRevision {
+	return v.Current().GetReleaseHistory(release)
+}
+
+func (v *SnapshotView) GetNodesByGroup(grouper, group string) []*Node {
+	return v.Current().GetNodesByGroup(grouper, group)
+}
+
+func (v *SnapshotView) GetApplications() []Application {
+	return v.Current().GetApplications()
+}
+
+func (v *SnapshotView) OwnedDescendants(uid types.UID) []*Node {
+	return v.Current().OwnedDescendants(uid)
+}
+
+func (v *SnapshotView) OutgoingEdges(uid types.UID) []*Edge {
+	return v.Current().OutgoingEdges(uid)
+}
+
+func (v *SnapshotView) IncomingEdges(uid types.UID) []*Edge {
+	return v.Current().IncomingEdges(uid)
+}
+
+func (v *SnapshotView) Search(query string, limit int) []SearchResult {
+	return v.Current().Search(query, limit)
+}
+
+func (v *SnapshotView) Generation() uint64 {
+	return v.Current().Generation()
+}
+
+func (v *SnapshotView) Clone() *Graph {
+	return v.Current().Clone()
+}
+
+// Write methods are forwarded to the live graph
+
+func (v *SnapshotView) AddNode(node *Node) {
+	v.live.AddNode(node)
+}
+
+func (v *SnapshotView) UpdateNode(uid types.UID, update func(node *Node) bool) (*Node, bool) {
+	return v.live.UpdateNode(uid, update)
+}
+
+func (v *SnapshotView) RemoveNode(uid types.UID) {
+	v.live.RemoveNode(uid)
+}
+
+func (v *SnapshotView) AddEdge(edge *Edge) bool {
+	return v.live.AddEdge(edge)
+}
+
+func (v *SnapshotView) RemoveEdge(fromUID, toUID types.UID) {
+	v.live.RemoveEdge(fromUID, toUID)
+}
+
+func (v *SnapshotView) AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType EdgeType, metadata map[string]string) {
+	v.live.AddPendingEdge(fromUID, targetRef, edgeType, metadata)
+}
+
+func (v *SnapshotView) AddReversePendingEdge(toUID types.UID, sourceRef RefKey, edgeType EdgeType) {
+	v.live.AddReversePendingEdge(toUID, sourceRef, edgeType)
+}
+
+func (v *SnapshotView) AddPendingOwnerEdge(childUID, ownerUID types.UID, ownerRef RefKey) {
+	v.live.AddPendingOwnerEdge(childUID, ownerUID, ownerRef)
+}
+
+// Edge sweeping works on the live graph
+
+func (v *SnapshotView) StaleEdges(cutoff time.Time) []*Edge {
+	return v.live.StaleEdges(cutoff)
+}
+
+func (v *SnapshotView) FlagStaleEdges(cutoff time.Time) int {
+	return v.live.FlagStaleEdges(cutoff)
+}
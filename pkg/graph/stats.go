@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"context"
+	"time"
+	"unsafe"
+)
+
+// Stats describes the size of a graph, for sizing an instance and troubleshooting it
+type Stats struct {
+	Generation  uint64
+	Nodes       int
+	Edges       int
+	NodesByKind map[string]int
+	EdgesByType map[EdgeType]int
+
+	// IndexSizes is the number of entries of each index
+	IndexSizes map[string]int
+
+	// Edges waiting for their target, their source or their owner to be created
+	PendingEdges        int
+	ReversePendingEdges int
+	PendingOwnerEdges   int
+
+	// EstimatedBytes is a rough estimate of the memory held by the nodes and edges, without
+	// the indexes: the size of their structs, strings and map entries
+	EstimatedBytes int64
+}
+
+// Measurable is a graph that can report its size
+type Measurable interface {
+	Stats() *Stats
+}
+
+// StoreStats describes the data of a persistence backend
+type StoreStats struct {
+	// Keys counts the stored keys by family, e.g. node, edge or index
+	Keys      map[string]int64
+	TotalKeys int64
+	// UsedMemoryBytes is the memory used by the store, 0 if unknown
+	UsedMemoryBytes int64
+
+	// The last full snapshot, nil if none was written
+	LastSnapshot *SnapshotInfo
+}
+
+// SnapshotInfo describes the last full snapshot of a persistence backend
+type SnapshotInfo struct {
+	Timestamp time.Time `json:"timestamp"`
+	Format    string    `json:"format"`
+	Nodes     int       `json:"nodes"`
+	Edges     int       `json:"edges"`
+	// Dirty means data was written after the snapshot
+	Dirty bool `json:"dirty,omitempty"`
+}
+
+// StoreStatsReporter is a persistence backend that can describe its data
+type StoreStatsReporter interface {
+	StoreStats(ctx context.Context) (*StoreStats, error)
+}
+
+// mapEntryBytes approximates the overhead of a map entry beyond its key and value
+const mapEntryBytes = 16
+
+// Stats counts the nodes, edges, index entries and pending edges of the graph
+func (g *Graph) Stats() *Stats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	stats := &Stats{
+		Generation:  g.generation,
+		Nodes:       len(g.nodes),
+		NodesByKind: make(map[string]int),
+		EdgesByType: make(map[EdgeType]int),
+		IndexSizes:  g.indexSizes(),
+	}
+	for _, node := range g.nodes {
+		stats.NodesByKind[node.Kind]++
+		stats.EstimatedBytes += estimateNodeBytes(node)
+		for _, edge := range node.OutgoingEdges {
+			stats.Edges++
+			stats.EdgesByType[edge.Type]++
+			stats.EstimatedBytes += estimateEdgeBytes(edge)
+		}
+	}
+	for _, pending := range g.pendingEdges {
+		stats.PendingEdges += len(pending)
+	}
+	for _, pending := range g.reversePendingEdges {
+		stats.ReversePendingEdges += len(pending)
+	}
+	for _, pending := range g.pendingOwnerEdges {
+		stats.PendingOwnerEdges += len(pending)
+	}
+	return stats
+}
+
+// estimateNodeBytes estimates the memory held by a node, without its edges
+func estimateNodeBytes(node *Node) int64 {
+	size := int64(unsafe.Sizeof(*node))
+	size += int64(len(node.UID) + len(node.SourceUID) + len(node.Name) + len(node.Namespace) +
+		len(node.Kind) + len(node.APIVersion) + len(node.ResourceVersion) + len(node.StatusMessage) +
+		len(node.StatusReason) + len(node.Cluster) + len(node.HelmChart) + len(node.HelmRelease))
+	size += estimateMapBytes(node.Labels) + estimateMapBytes(node.Annotations)
+	// Each edge is referenced from the maps of both of its nodes
+	size += int64(len(node.OutgoingEdges)+len(node.IncomingEdges)) * (mapEntryBytes + int64(unsafe.Sizeof(node.UID)) + int64(unsafe.Sizeof(&Edge{})))
+	if node.Metadata != nil {
+		size += int64(unsafe.Sizeof(*node.Metadata)) + int64(len(node.Metadata.Image))
+		for _, container := range node.Metadata.Containers {
+			size += int64(unsafe.Sizeof(container)) + int64(len(container.Name)+len(container.Image))
+		}
+	}
+	return size
+}
+
+// estimateEdgeBytes estimates the memory held by an edge
+func estimateEdgeBytes(edge *Edge) int64 {
+	return int64(unsafe.Sizeof(*edge)) + int64(len(edge.Type)+len(edge.FromUID)+len(edge.ToUID)) + estimateMapBytes(edge.Metadata)
+}
+
+// estimateMapBytes estimates the memory held by a map of strings
+func estimateMapBytes(m map[string]string) int64 {
+	var size int64
+	for key, value := range m {
+		size += mapEntryBytes + 2*int64(unsafe.Sizeof(key)) + int64(len(key)+len(value))
+	}
+	return size
+}
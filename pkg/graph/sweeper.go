@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// SweepMode controls what happens to edges that were not reconfirmed in time
+type SweepMode string
+
+const (
+	// SweepFlag marks stale edges but keeps them in the graph
+	SweepFlag SweepMode = "flag"
+	// SweepRemove removes stale edges from the graph
+	SweepRemove SweepMode = "remove"
+)
+
+// ParseSweepMode parses a sweep mode flag value
+func ParseSweepMode(value string) (SweepMode, error) {
+	switch mode := SweepMode(strings.ToLower(value)); mode {
+	case "":
+		return SweepFlag, nil
+	case SweepFlag, SweepRemove:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid sweep mode %q (expected flag or remove)", value)
+	}
+}
+
+// EdgeSweeper bounds the staleness of inferred relationships. Processors reconfirm the
+// edges of every object on each informer resync; edges that were not reconfirmed within
+// maxAge are flagged or removed.
+type EdgeSweeper struct {
+	graph  GraphInterface
+	maxAge time.Duration
+	mode   SweepMode
+}
+
+// NewEdgeSweeper creates a sweeper for edges not reconfirmed within maxAge
+func NewEdgeSweeper(g GraphInterface, maxAge time.Duration, mode SweepMode) *EdgeSweeper {
+	return &EdgeSweeper{
+		graph:  g,
+		maxAge: maxAge,
+		mode:   mode,
+	}
+}
+
+// Start sweeps at a fraction of maxAge until ctx is cancelled
+func (s *EdgeSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.maxAge / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep flags or removes the edges last confirmed more than maxAge ago and returns their count
+func (s *EdgeSweeper) Sweep() int {
+	cutoff := time.Now().Add(-s.maxAge)
+
+	var count int
+	switch s.mode {
+	case SweepRemove:
+		stale := s.graph.StaleEdges(cutoff)
+		for _, edge := range stale {
+			s.graph.RemoveEdge(edge.FromUID, edge.ToUID)
+		}
+		count = len(stale)
+	default:
+		count = s.graph.FlagStaleEdges(cutoff)
+	}
+
+	if count > 0 {
+		klog.Infof("Edge sweeper: %d edge(s) not reconfirmed within %v (%s)", count, s.maxAge, s.mode)
+	}
+	return count
+}
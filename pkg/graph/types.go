@@ -23,6 +23,7 @@ const (
 // Node represents a Kubernetes resource in the graph
 type Node struct {
 	UID               types.UID         `json:"uid"`
+	SourceUID         types.UID         `json:"sourceUID,omitempty"` // Kubernetes UID, when the ID strategy does not use it as is
 	Name              string            `json:"name"`
 	Namespace         string            `json:"namespace"`
 	Kind              string            `json:"kind"`
@@ -33,6 +34,11 @@ type Node struct {
 	CreationTimestamp time.Time         `json:"creationTimestamp"`
 	Status            ResourceStatus    `json:"status"`
 	StatusMessage     string            `json:"statusMessage"`
+	// StatusReason is a machine-readable code for the status (see reasons.go)
+	StatusReason string `json:"statusReason,omitempty"`
+
+	// Cluster is the federated cluster the node was received from, empty for the local cluster
+	Cluster string `json:"cluster,omitempty"`
 
 	// Helm-specific fields
 	HelmChart   string `json:"helmChart,omitempty"`
@@ -41,27 +47,106 @@ type Node struct {
 	// Resource-specific metadata
 	Metadata *ResourceMetadata `json:"metadata,omitempty"`
 
+	// When the graph first saw the resource, and first saw it Ready (see readiness.go)
+	FirstSeen  time.Time `json:"firstSeen"`
+	FirstReady time.Time `json:"firstReady"`
+
+	// DeletedAt is set on the tombstones of deleted resources, which are kept outside the
+	// graph for a retention window (see pkg/tombstones)
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
 	// Graph edges (stored as UIDs for efficient lookups)
 	OutgoingEdges map[types.UID]*Edge `json:"-"` // Edges from this node
 	IncomingEdges map[types.UID]*Edge `json:"-"` // Edges to this node
 }
 
+// GitOpsStatus describes the source and sync state of a GitOps application
+type GitOpsStatus struct {
+	SyncStatus           string `json:"syncStatus,omitempty"`
+	HealthStatus         string `json:"healthStatus,omitempty"`
+	RepoURL              string `json:"repoURL,omitempty"`
+	Path                 string `json:"path,omitempty"`
+	Revision             string `json:"revision,omitempty"`
+	DestinationNamespace string `json:"destinationNamespace,omitempty"`
+	// SourceRef is the Flux source the object is reconciled from (<Kind>/<namespace>/<name>)
+	SourceRef string `json:"sourceRef,omitempty"`
+}
+
+// CertificateInfo describes a cert-manager Certificate and the certificate it was issued
+type CertificateInfo struct {
+	SecretName string   `json:"secretName,omitempty"`
+	DNSNames   []string `json:"dnsNames,omitempty"`
+	// Issuer is the issuer reference as <Kind>/<name>
+	Issuer      string     `json:"issuer,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	RenewalTime *time.Time `json:"renewalTime,omitempty"`
+	// FailedIssuanceAttempts counts the consecutive failed issuances since the last success
+	FailedIssuanceAttempts int64 `json:"failedIssuanceAttempts,omitempty"`
+}
+
+// LogExcerpt is a sanitized sample of the last log lines of a failing container
+type LogExcerpt struct {
+	Container string    `json:"container"`
+	Previous  bool      `json:"previous"` // Logs come from the previous (crashed) container instance
+	Lines     string    `json:"lines"`
+	SampledAt time.Time `json:"sampledAt"`
+}
+
+// WarningEvent is a Kubernetes Warning Event about a resource, e.g. FailedScheduling
+type WarningEvent struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	// Count is how many times the event occurred between FirstSeen and LastSeen
+	Count     int32     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Source is the component that reported the event, e.g. default-scheduler
+	Source string `json:"source,omitempty"`
+}
+
 // ResourceMetadata contains resource-specific metadata
 type ResourceMetadata struct {
 	// Pod-specific
 	NodeName     string `json:"nodeName,omitempty"`
 	Image        string `json:"image,omitempty"`
 	RestartCount int    `json:"restartCount,omitempty"`
+	// Containers lists every container of the Pod (Image above is the first one's), or of the
+	// Pod template of a rollout revision
+	Containers []ContainerInfo `json:"containers,omitempty"`
+
+	// Pod-specific: tail of the crashed container's logs (requires --pod-log-sampling)
+	LogExcerpt *LogExcerpt `json:"logExcerpt,omitempty"`
 
 	// Workload-specific (Deployment, StatefulSet, etc.)
 	Replicas *ReplicaInfo `json:"replicas,omitempty"`
 
+	// Rollout revision-specific (ReplicaSet, ControllerRevision)
+	Revision     int64  `json:"revision,omitempty"`
+	TemplateHash string `json:"templateHash,omitempty"`
+	// Controller is the workload the revision belongs to, as <Kind>/<name>
+	Controller string `json:"controller,omitempty"`
+
+	// Cluster API-specific (Machine, MachineSet, MachineDeployment); Machines also set NodeName
+	ClusterName string `json:"clusterName,omitempty"`
+	Version     string `json:"version,omitempty"`
+
+	// Node-specific
+	Unschedulable bool `json:"unschedulable,omitempty"`
+
+	// Topology: the failure domain of Nodes, from their well-known labels, and of the Pods and
+	// PersistentVolumes on them. Local PVs set NodeName to the Node they are pinned to.
+	Zone   string `json:"zone,omitempty"`
+	Region string `json:"region,omitempty"`
+
 	// PVC-specific
 	VolumeName string `json:"volumeName,omitempty"`
 
 	// PV-specific
 	ClaimRef *ObjectReference `json:"claimRef,omitempty"`
 
+	// Secret-specific: the decoded payload of a Helm release Secret (type helm.sh/release.v1)
+	HelmRevision *HelmRevision `json:"helmRevision,omitempty"`
+
 	// Service-specific
 	ClusterIP   string `json:"clusterIP,omitempty"`
 	ServiceType string `json:"serviceType,omitempty"`
@@ -69,12 +154,54 @@ type ResourceMetadata struct {
 	// Ingress-specific
 	IngressClass string `json:"ingressClass,omitempty"`
 
+	// cert-manager Certificate-specific
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+
+	// Istio-specific: the hosts of a VirtualService, DestinationRule or Gateway
+	Hosts []string `json:"hosts,omitempty"`
+
 	// HPA-specific
 	ScaleTargetRef  *ObjectReference `json:"scaleTargetRef,omitempty"`
 	MinReplicas     *int32           `json:"minReplicas,omitempty"`
 	MaxReplicas     int32            `json:"maxReplicas,omitempty"`
 	CurrentReplicas int32            `json:"currentReplicas,omitempty"`
 	DesiredReplicas int32            `json:"desiredReplicas,omitempty"`
+
+	// GitOps-specific (ArgoCD Application, Flux Kustomization/HelmRelease)
+	GitOps *GitOpsStatus `json:"gitOps,omitempty"`
+
+	// Operator-defined fields computed from the raw object (see computedFields in the config file)
+	Computed map[string]string `json:"computed,omitempty"`
+
+	// Helm-managed resources: set while the deployed revision of their release is a rollback
+	Rollback *RollbackMarker `json:"rollback,omitempty"`
+
+	// Set when the resource was applied with an API version that is deprecated or removed in
+	// the cluster's Kubernetes version
+	Deprecation *APIDeprecation `json:"deprecation,omitempty"`
+
+	// Recent Warning Events about the resource, newest first (see --watch-events)
+	Events []WarningEvent `json:"events,omitempty"`
+
+	// Owners are the owner references of the object, kept whether or not the owners are in
+	// the graph
+	Owners []OwnerReference `json:"owners,omitempty"`
+}
+
+// ContainerInfo describes a container of a Pod
+type ContainerInfo struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Init  bool   `json:"init,omitempty"`
+	// Env lists the names of the environment variables (Pod templates only)
+	Env      []string `json:"env,omitempty"`
+	Ready    bool     `json:"ready"`
+	Restarts int32    `json:"restarts"`
+	// State is Running, Waiting or Terminated, with the reason if there is one
+	State                 string            `json:"state,omitempty"`
+	Requests              map[string]string `json:"requests,omitempty"`
+	Limits                map[string]string `json:"limits,omitempty"`
+	LastTerminationReason string            `json:"lastTerminationReason,omitempty"`
 }
 
 // ReplicaInfo contains replica information for workload resources
@@ -93,6 +220,16 @@ type ObjectReference struct {
 	UID       types.UID `json:"uid,omitempty"`
 }
 
+// OwnerReference is an owner reference of an object as set in its metadata
+type OwnerReference struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+	// Controller is set on the managing controller of the object
+	Controller bool `json:"controller,omitempty"`
+}
+
 // EdgeType represents the type of relationship between resources
 type EdgeType string
 
@@ -100,17 +237,31 @@ const (
 	// Ownership edges
 	EdgeOwnership EdgeType = "owns" // Deployment -> ReplicaSet -> Pod
 
-	// Service edges
-	EdgeServiceSelector EdgeType = "selects"   // Service -> Pod (via selector)
-	EdgeServiceEndpoint EdgeType = "endpoints" // Service -> EndpointSlice
+	// Rollout history edges
+	EdgeRevisionOf EdgeType = "revision-of" // ReplicaSet -> Deployment
+
+	// Selector edges
+	EdgeServiceSelector EdgeType = "selects" // PodDisruptionBudget/Istio Gateway -> Pod (via selector)
+
+	// Service endpoint edges, the routing path Service -> EndpointSlice -> Pod
+	EdgeHasEndpoints EdgeType = "has-endpoints" // Service -> EndpointSlice
+	EdgeEndpointOf   EdgeType = "endpoint-of"   // EndpointSlice -> Pod listed as an endpoint of its Service
 
 	// Ingress edges
-	EdgeIngressBackend EdgeType = "routes-to" // Ingress -> Service
+	EdgeIngressBackend EdgeType = "routes-to" // Ingress -> Service, VirtualService -> Service/Gateway
+
+	// Service mesh edges
+	EdgeTrafficPolicy EdgeType = "configures" // Istio DestinationRule -> Service
 
 	// Volume edges
 	EdgePodVolume  EdgeType = "mounts" // Pod -> PVC
 	EdgePVCBinding EdgeType = "binds"  // PVC -> PV
 
+	// Storage provisioning and attachment edges
+	EdgeProvisionedBy EdgeType = "provisioned-by" // PV -> StorageClass, StorageClass -> CSIDriver
+	EdgeAttaches      EdgeType = "attaches"       // VolumeAttachment -> PV
+	EdgeAttachedTo    EdgeType = "attached-to"    // VolumeAttachment -> Node
+
 	// ConfigMap/Secret edges
 	EdgeConfigMapRef EdgeType = "uses-configmap" // Pod/Workload -> ConfigMap
 	EdgeSecretRef    EdgeType = "uses-secret"    // Pod/Workload -> Secret
@@ -120,21 +271,70 @@ const (
 
 	// HPA edges
 	EdgeHPATarget EdgeType = "scales" // HPA -> Deployment/StatefulSet
+
+	// GitOps edges
+	EdgeManages EdgeType = "manages" // ArgoCD Application -> deployed resources
+
+	// cert-manager edges
+	EdgeCertificateSecret EdgeType = "stored-in" // Certificate -> Secret
+	EdgeIssuedBy          EdgeType = "issued-by" // Certificate/CertificateRequest -> Issuer/ClusterIssuer
+
+	// Scheduling and infrastructure edges
+	EdgeScheduledOn EdgeType = "runs-on"    // Pod -> Node
+	EdgeProvisions  EdgeType = "provisions" // Cluster API Machine -> Node
+
+	// Helm release edges
+	EdgePartOfRelease EdgeType = "part-of-release" // Release resources and Secrets -> HelmRelease
+
+	// Custom resource edges
+	EdgeReferences EdgeType = "references" // Custom resource -> object named in its spec
 )
 
+// EdgeTypes lists the edge types, in the order above
+var EdgeTypes = []EdgeType{
+	EdgeOwnership, EdgeRevisionOf, EdgeServiceSelector, EdgeHasEndpoints, EdgeEndpointOf, EdgeIngressBackend,
+	EdgeTrafficPolicy, EdgePodVolume, EdgePVCBinding, EdgeProvisionedBy, EdgeAttaches,
+	EdgeAttachedTo, EdgeConfigMapRef, EdgeSecretRef, EdgeServiceAccount, EdgeHPATarget,
+	EdgeManages, EdgeCertificateSecret, EdgeIssuedBy, EdgeScheduledOn, EdgeProvisions,
+	EdgePartOfRelease, EdgeReferences,
+}
+
 // Edge represents a relationship between two resources
 type Edge struct {
 	Type     EdgeType          `json:"type"`
 	FromUID  types.UID         `json:"fromUID"`
 	ToUID    types.UID         `json:"toUID"`
 	Metadata map[string]string `json:"metadata,omitempty"` // Additional edge metadata
+	// LastConfirmed is when a processor last created or re-created the edge
+	LastConfirmed time.Time `json:"lastConfirmed"`
+	// Stale is set by the edge sweeper when the edge was not reconfirmed in time
+	Stale bool `json:"stale,omitempty"`
 }
 
+// Edge metadata keys. List values are comma-separated.
+const (
+	// EdgeMetaPorts lists the target ports of Service -> Pod edges as name:port/protocol
+	EdgeMetaPorts = "ports"
+	// EdgeMetaMountPaths lists where a volume is mounted, as container:path
+	EdgeMetaMountPaths = "mountPaths"
+	// EdgeMetaReadOnly is "true" when a volume is only mounted read-only
+	EdgeMetaReadOnly = "readOnly"
+	// EdgeMetaRefs lists how a ConfigMap or Secret is used: volume, envFrom and/or env
+	EdgeMetaRefs = "refs"
+	// EdgeMetaKeys lists the keys of a ConfigMap or Secret used by env vars or volume items
+	EdgeMetaKeys = "keys"
+	// EdgeMetaEnvPrefix is the prefix of the env vars imported with envFrom
+	EdgeMetaEnvPrefix = "envPrefix"
+	// EdgeMetaRevision is the rollout revision number of a revision-of edge
+	EdgeMetaRevision = "revision"
+)
+
 // PendingEdge represents an edge waiting for a target resource to be created
 type PendingEdge struct {
 	FromUID    types.UID
 	TargetRef  RefKey
 	EdgeType   EdgeType
+	Metadata   map[string]string
 }
 
 // ReversePendingEdge represents an edge where we have the target but are waiting for the source
@@ -152,17 +352,38 @@ type Graph struct {
 	// Index by namespace and kind for efficient queries
 	byNamespaceKind map[string]map[string][]*Node // namespace -> kind -> nodes
 
-	// Index by Helm release for efficient queries
-	byHelmRelease map[string][]*Node // release name -> nodes
+	// Application indexes maintained by the groupers (the Helm release index is the "helm" grouper)
+	groupers []Grouper
+	byGroup  map[string]map[string][]*Node // grouper -> application -> nodes
 
 	// Index by labels for efficient selector queries
-	byLabel map[string]map[string][]*Node // label key -> label value -> nodes
+	byLabel *labelIndex // label key -> label value -> nodes
+
+	// Index by namespace, kind and name for reference resolution
+	byName nameIndex
+
+	// Index by Helm chart (<name>-<version>, as in the helm.sh/chart annotation)
+	byHelmChart map[string][]*Node
+
+	// Index of name, namespace, image and label terms for Search
+	search *searchIndex
 
 	// Pending edges waiting for target resources to be created
 	pendingEdges map[RefKey][]PendingEdge // target ref -> pending edges
 	
 	// Reverse pending edges waiting for source resources to be created
 	reversePendingEdges map[RefKey][]ReversePendingEdge // source ref -> reverse pending edges
+
+	// Ownership edges waiting for the owner to be created, keyed by owner UID
+	pendingOwnerEdges map[types.UID][]ReversePendingEdge // owner UID -> reverse pending edges
+
+	// Incremented on every visible mutation, used to detect changes cheaply
+	generation uint64
+
+	// Generation at which each node last changed, and the nodes removed, for ChangesSince
+	changedAt      map[types.UID]uint64
+	removedAt      map[types.UID]removedNode
+	changesHorizon uint64
 }
 
 // NewGraph creates a new empty graph
@@ -170,10 +391,17 @@ func NewGraph() *Graph {
 	return &Graph{
 		nodes:               make(map[types.UID]*Node),
 		byNamespaceKind:     make(map[string]map[string][]*Node),
-		byHelmRelease:       make(map[string][]*Node),
-		byLabel:             make(map[string]map[string][]*Node),
+		groupers:            currentGroupers(),
+		byGroup:             make(map[string]map[string][]*Node),
+		byLabel:             newLabelIndex(),
+		byName:              make(nameIndex),
+		byHelmChart:         make(map[string][]*Node),
+		search:              newSearchIndex(),
 		pendingEdges:        make(map[RefKey][]PendingEdge),
 		reversePendingEdges: make(map[RefKey][]ReversePendingEdge),
+		pendingOwnerEdges:   make(map[types.UID][]ReversePendingEdge),
+		changedAt:           make(map[types.UID]uint64),
+		removedAt:           make(map[types.UID]removedNode),
 	}
 }
 
@@ -181,9 +409,42 @@ func NewGraph() *Graph {
 func (g *Graph) AddNode(node *Node) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.addNode(node, !g.resynced(node))
+}
+
+// UpdateNode applies update to a copy of a node and stores it, atomically with respect to
+// the other writers. update returns false to leave the node unchanged. It returns the updated
+// node, or false when update left it unchanged or the node is not in the graph, so a removed
+// node is never added back.
+func (g *Graph) UpdateNode(uid types.UID, update func(node *Node) bool) (*Node, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	node, exists := g.nodes[uid]
+	if !exists {
+		return nil, false
+	}
+	// Readers may hold the current node, so update a copy
+	updated := *node
+	if node.Metadata != nil {
+		metadata := *node.Metadata
+		updated.Metadata = &metadata
+	}
+	if !update(&updated) {
+		return nil, false
+	}
+	g.addNode(&updated, true)
+	return &updated, true
+}
+
+// addNode adds or updates a node, recording it as changed when touch is set or the node is
+// new. Must be called with lock held.
+func (g *Graph) addNode(node *Node, touch bool) {
+	g.generation++
 
 	// Check if this is an update or new node
 	oldNode, isUpdate := g.nodes[node.UID]
+	trackReadiness(oldNode, node)
 
 	if isUpdate {
 		// Preserve existing edges when updating
@@ -193,8 +454,10 @@ func (g *Graph) AddNode(node *Node) {
 		// Only update indexes if indexable fields changed
 		needsReindex := oldNode.Namespace != node.Namespace ||
 			oldNode.Kind != node.Kind ||
-			oldNode.HelmRelease != node.HelmRelease ||
-			!labelsEqual(oldNode.Labels, node.Labels)
+			oldNode.Name != node.Name ||
+			oldNode.HelmChart != node.HelmChart ||
+			!labelsEqual(oldNode.Labels, node.Labels) ||
+			!g.groupsEqual(oldNode, node)
 
 		if needsReindex {
 			g.removeFromIndexes(oldNode)
@@ -202,8 +465,9 @@ func (g *Graph) AddNode(node *Node) {
 			g.addToIndexes(node)
 			klog.V(3).Infof("Graph: UPDATED %s/%s (reindexed, release: %s, status: %s)", node.Kind, node.Name, node.HelmRelease, node.Status)
 		} else {
-			// In-place update without touching indexes
+			// In-place update without touching indexes; images may still have changed
 			g.nodes[node.UID] = node
+			g.search.add(node)
 			klog.V(4).Infof("Graph: UPDATED %s/%s (in-place, status: %s)", node.Kind, node.Name, node.Status)
 		}
 	} else {
@@ -221,8 +485,14 @@ func (g *Graph) AddNode(node *Node) {
 
 		// Check for pending edges targeting this node
 		g.processPendingEdgesForNode(node)
+		g.touchNeighbours(node)
+		delete(g.removedAt, node.UID)
 
 		klog.V(2).Infof("Graph: ADDED %s/%s (release: %s, status: %s)", node.Kind, node.Name, node.HelmRelease, node.Status)
+		touch = true
+	}
+	if touch {
+		g.touch(node.UID)
 	}
 }
 
@@ -248,6 +518,7 @@ func (g *Graph) RemoveNode(uid types.UID) {
 	if !exists {
 		return
 	}
+	g.generation++
 
 	// Remove all edges connected to this node
 	for _, edge := range node.OutgoingEdges {
@@ -266,6 +537,9 @@ func (g *Graph) RemoveNode(uid types.UID) {
 
 	// Remove from main map
 	delete(g.nodes, uid)
+	g.dropPendingOwnerEdges(uid)
+	g.touchNeighbours(node)
+	g.recordRemoval(node)
 }
 
 // GetNode retrieves a node by UID
@@ -276,6 +550,38 @@ func (g *Graph) GetNode(uid types.UID) (*Node, bool) {
 	return node, exists
 }
 
+// OutgoingEdges returns the edges from a node, nil if it is not in the graph. The edge maps
+// of nodes change under the graph lock, so writers read them through this copy.
+func (g *Graph) OutgoingEdges(uid types.UID) []*Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	node, exists := g.nodes[uid]
+	if !exists {
+		return nil
+	}
+	return edgeList(node.OutgoingEdges)
+}
+
+// IncomingEdges returns the edges to a node, nil if it is not in the graph
+func (g *Graph) IncomingEdges(uid types.UID) []*Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	node, exists := g.nodes[uid]
+	if !exists {
+		return nil
+	}
+	return edgeList(node.IncomingEdges)
+}
+
+// edgeList copies the edges of an edge map. Must be called with lock held.
+func edgeList(edges map[types.UID]*Edge) []*Edge {
+	list := make([]*Edge, 0, len(edges))
+	for _, edge := range edges {
+		list = append(list, edge)
+	}
+	return list
+}
+
 // AddEdge adds an edge between two nodes
 func (g *Graph) AddEdge(edge *Edge) bool {
 	g.mu.Lock()
@@ -288,8 +594,18 @@ func (g *Graph) AddEdge(edge *Edge) bool {
 		return false
 	}
 
+	// Edges restored from storage keep their timestamp, processors always confirm anew
+	if edge.LastConfirmed.IsZero() {
+		edge.LastConfirmed = time.Now()
+	}
+
+	reconfirmed := g.reconfirmed(edge)
 	fromNode.OutgoingEdges[edge.ToUID] = edge
 	toNode.IncomingEdges[edge.FromUID] = edge
+	g.generation++
+	if !reconfirmed {
+		g.touch(edge.FromUID, edge.ToUID)
+	}
 
 	return true
 }
@@ -299,6 +615,8 @@ func (g *Graph) RemoveEdge(fromUID, toUID types.UID) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	g.generation++
+
 	if fromNode, exists := g.nodes[fromUID]; exists {
 		delete(fromNode.OutgoingEdges, toUID)
 	}
@@ -306,6 +624,61 @@ func (g *Graph) RemoveEdge(fromUID, toUID types.UID) {
 	if toNode, exists := g.nodes[toUID]; exists {
 		delete(toNode.IncomingEdges, fromUID)
 	}
+	g.touch(fromUID, toUID)
+}
+
+// StaleEdges returns the edges that were last confirmed before cutoff
+func (g *Graph) StaleEdges(cutoff time.Time) []*Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var stale []*Edge
+	for _, node := range g.nodes {
+		if node.Cluster != "" {
+			// Federated edges are swept by the instance of their cluster
+			continue
+		}
+		for _, edge := range node.OutgoingEdges {
+			if edge.LastConfirmed.Before(cutoff) {
+				stale = append(stale, edge)
+			}
+		}
+	}
+	return stale
+}
+
+// FlagStaleEdges marks the edges last confirmed before cutoff as stale and returns how many
+// were newly flagged. Reconfirming an edge replaces it and so clears the flag.
+func (g *Graph) FlagStaleEdges(cutoff time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	flagged := 0
+	var touched []types.UID
+	for _, node := range g.nodes {
+		if node.Cluster != "" {
+			continue
+		}
+		for toUID, edge := range node.OutgoingEdges {
+			if edge.Stale || !edge.LastConfirmed.Before(cutoff) {
+				continue
+			}
+			// Readers may hold the old edge, so swap in a flagged copy
+			copied := *edge
+			copied.Stale = true
+			node.OutgoingEdges[toUID] = &copied
+			if toNode, exists := g.nodes[toUID]; exists {
+				toNode.IncomingEdges[node.UID] = &copied
+			}
+			touched = append(touched, node.UID, toUID)
+			flagged++
+		}
+	}
+	if flagged > 0 {
+		g.generation++
+		g.touch(touched...)
+	}
+	return flagged
 }
 
 // GetNodesByNamespaceKind returns all nodes of a specific kind in a namespace
@@ -346,48 +719,16 @@ func (g *Graph) GetNodesByHelmRelease(release string) []*Node {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	if nodes, exists := g.byHelmRelease[release]; exists {
-		// Return a copy to avoid concurrent modification
-		result := make([]*Node, len(nodes))
-		copy(result, nodes)
-		return result
+	if nodes, exists := g.byGroup[GrouperHelm][release]; exists {
+		return g.liveNodes(nodes)
 	}
 	return nil
 }
 
 // GetNodesByLabelSelector returns nodes matching a label selector
 func (g *Graph) GetNodesByLabelSelector(selector map[string]string) []*Node {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-
-	if len(selector) == 0 {
-		return nil
-	}
-
-	// Start with nodes matching the first label
-	var candidates []*Node
-	first := true
-
-	for key, value := range selector {
-		if valueMap, exists := g.byLabel[key]; exists {
-			if nodes, exists := valueMap[value]; exists {
-				if first {
-					candidates = make([]*Node, len(nodes))
-					copy(candidates, nodes)
-					first = false
-				} else {
-					// Intersect with existing candidates
-					candidates = g.intersectNodes(candidates, nodes)
-				}
-			} else {
-				return nil // No nodes match this label
-			}
-		} else {
-			return nil // No nodes have this label key
-		}
-	}
-
-	return candidates
+	nodes, _ := g.GetNodesByLabelRequirements(EqualityRequirements(selector))
+	return nodes
 }
 
 // GetAllNodes returns all nodes in the graph
@@ -407,8 +748,8 @@ func (g *Graph) GetAllHelmReleases() []string {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	releases := make([]string, 0, len(g.byHelmRelease))
-	for release := range g.byHelmRelease {
+	releases := make([]string, 0, len(g.byGroup[GrouperHelm]))
+	for release := range g.byGroup[GrouperHelm] {
 		if release != "" {
 			releases = append(releases, release)
 		}
@@ -421,20 +762,109 @@ func (g *Graph) GetAllHelmCharts() []string {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	charts := make(map[string]bool)
-	for _, node := range g.nodes {
-		if node.HelmChart != "" {
-			charts[node.HelmChart] = true
-		}
+	result := make([]string, 0, len(g.byHelmChart))
+	for chart := range g.byHelmChart {
+		result = append(result, chart)
 	}
+	return result
+}
 
-	result := make([]string, 0, len(charts))
-	for chart := range charts {
-		result = append(result, chart)
+// OwnedDescendants returns the nodes Kubernetes garbage collection would delete together
+// with the given owner: transitive ownership children whose owners are all being deleted.
+func (g *Graph) OwnedDescendants(uid types.UID) []*Node {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if _, exists := g.nodes[uid]; !exists {
+		return nil
 	}
+
+	deleted := map[types.UID]bool{uid: true}
+	queue := []types.UID{uid}
+	var result []*Node
+
+	for len(queue) > 0 {
+		current := g.nodes[queue[0]]
+		queue = queue[1:]
+
+		for childUID, edge := range current.OutgoingEdges {
+			if edge.Type != EdgeOwnership || deleted[childUID] {
+				continue
+			}
+			child, exists := g.nodes[childUID]
+			if !exists {
+				continue
+			}
+
+			// Children with another live owner survive garbage collection
+			orphaned := true
+			for ownerUID, incoming := range child.IncomingEdges {
+				if incoming.Type == EdgeOwnership && !deleted[ownerUID] {
+					orphaned = false
+					break
+				}
+			}
+			if !orphaned {
+				continue
+			}
+
+			deleted[childUID] = true
+			result = append(result, child)
+			queue = append(queue, childUID)
+		}
+	}
+
 	return result
 }
 
+// Generation returns a counter that changes whenever nodes or edges change
+func (g *Graph) Generation() uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.generation
+}
+
+// Clone returns a deep copy of the nodes, edges and indexes of the graph.
+// Pending edges are not copied; the clone is meant for read-only use.
+func (g *Graph) Clone() *Graph {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	clone := NewGraph()
+	clone.generation = g.generation
+	clone.groupers = g.groupers
+	clone.changesHorizon = g.changesHorizon
+	for uid, changed := range g.changedAt {
+		clone.changedAt[uid] = changed
+	}
+	for uid, removed := range g.removedAt {
+		clone.removedAt[uid] = removed
+	}
+
+	for uid, node := range g.nodes {
+		copied := *node
+		copied.OutgoingEdges = make(map[types.UID]*Edge, len(node.OutgoingEdges))
+		copied.IncomingEdges = make(map[types.UID]*Edge, len(node.IncomingEdges))
+		clone.nodes[uid] = &copied
+		clone.addToIndexes(&copied)
+	}
+
+	// Edges are shared between both endpoints, so copy each one once
+	for _, node := range g.nodes {
+		for toUID, edge := range node.OutgoingEdges {
+			copied := *edge
+			if fromNode, exists := clone.nodes[edge.FromUID]; exists {
+				fromNode.OutgoingEdges[toUID] = &copied
+			}
+			if toNode, exists := clone.nodes[toUID]; exists {
+				toNode.IncomingEdges[edge.FromUID] = &copied
+			}
+		}
+	}
+
+	return clone
+}
+
 // Helper functions
 
 func (g *Graph) addToIndexes(node *Node) {
@@ -449,18 +879,22 @@ func (g *Graph) addToIndexes(node *Node) {
 	}
 	g.byNamespaceKind[nsKey][node.Kind] = append(g.byNamespaceKind[nsKey][node.Kind], node)
 
-	// Add to Helm release index
-	if node.HelmRelease != "" {
-		g.byHelmRelease[node.HelmRelease] = append(g.byHelmRelease[node.HelmRelease], node)
-	}
+	// Add to application indexes (including Helm releases)
+	g.addToGroups(node)
 
 	// Add to label index
-	for key, value := range node.Labels {
-		if _, exists := g.byLabel[key]; !exists {
-			g.byLabel[key] = make(map[string][]*Node)
-		}
-		g.byLabel[key][value] = append(g.byLabel[key][value], node)
+	g.byLabel.add(node)
+
+	// Add to name index
+	g.byName.add(node)
+
+	// Add to chart index
+	if node.HelmChart != "" {
+		g.byHelmChart[node.HelmChart] = append(g.byHelmChart[node.HelmChart], node)
 	}
+
+	// Add to search index
+	g.search.add(node)
 }
 
 func (g *Graph) removeFromIndexes(node *Node) {
@@ -482,30 +916,38 @@ func (g *Graph) removeFromIndexes(node *Node) {
 		}
 	}
 
-	// Remove from Helm release index
-	if node.HelmRelease != "" {
-		if nodes, exists := g.byHelmRelease[node.HelmRelease]; exists {
-			g.byHelmRelease[node.HelmRelease] = g.removeNodeFromSlice(nodes, node.UID)
-			if len(g.byHelmRelease[node.HelmRelease]) == 0 {
-				delete(g.byHelmRelease, node.HelmRelease)
-			}
+	// Remove from application indexes (including Helm releases)
+	g.removeFromGroups(node)
+
+	// Remove from label index
+	g.byLabel.remove(node)
+
+	// Remove from name index
+	g.byName.remove(node)
+
+	// Remove from chart index
+	if nodes, exists := g.byHelmChart[node.HelmChart]; exists {
+		g.byHelmChart[node.HelmChart] = g.removeNodeFromSlice(nodes, node.UID)
+		if len(g.byHelmChart[node.HelmChart]) == 0 {
+			delete(g.byHelmChart, node.HelmChart)
 		}
 	}
 
-	// Remove from label index
-	for key, value := range node.Labels {
-		if valueMap, exists := g.byLabel[key]; exists {
-			if nodes, exists := valueMap[value]; exists {
-				valueMap[value] = g.removeNodeFromSlice(nodes, node.UID)
-				if len(valueMap[value]) == 0 {
-					delete(valueMap, value)
-				}
-			}
-			if len(valueMap) == 0 {
-				delete(g.byLabel, key)
-			}
+	// Remove from search index
+	g.search.remove(node.UID)
+}
+
+// liveNodes returns a copy of an index slice with every entry resolved to the current node.
+// In-place updates replace nodes without touching the indexes, so index entries may point
+// at a previous version of a node. Must be called with lock held.
+func (g *Graph) liveNodes(nodes []*Node) []*Node {
+	result := make([]*Node, 0, len(nodes))
+	for _, node := range nodes {
+		if current, exists := g.nodes[node.UID]; exists {
+			result = append(result, current)
 		}
 	}
+	return result
 }
 
 func (g *Graph) removeNodeFromSlice(nodes []*Node, uid types.UID) []*Node {
@@ -545,7 +987,7 @@ func NewNodeFromObject(obj metav1.Object, kind, apiVersion string) *Node {
 	}
 
 	node := &Node{
-		UID:               obj.GetUID(),
+		UID:               ObjectID(obj.GetUID(), obj.GetNamespace(), kind, obj.GetName()),
 		Name:              obj.GetName(),
 		Namespace:         obj.GetNamespace(),
 		Kind:              kind,
@@ -558,10 +1000,16 @@ func NewNodeFromObject(obj metav1.Object, kind, apiVersion string) *Node {
 		OutgoingEdges:     make(map[types.UID]*Edge),
 		IncomingEdges:     make(map[types.UID]*Edge),
 	}
+	if node.UID != obj.GetUID() {
+		node.SourceUID = obj.GetUID()
+	}
 
-	// Extract Helm information from labels/annotations
+	// Extract Helm information from labels/annotations. Charts following the Helm conventions
+	// set helm.sh/chart as a label.
 	if chart, ok := annotations["helm.sh/chart"]; ok {
 		node.HelmChart = chart
+	} else if chart, ok := labels["helm.sh/chart"]; ok {
+		node.HelmChart = chart
 	}
 
 	if release, ok := annotations["meta.helm.sh/release-name"]; ok {
@@ -575,15 +1023,31 @@ type GraphInterface interface {
 	GetNode(uid types.UID) (*Node, bool)
 	GetAllNodes() []*Node
 	GetNodesByNamespaceKind(namespace, kind string) []*Node
+	GetNodeByRef(namespace, kind, name string) (*Node, bool)
 	GetNodesByHelmRelease(release string) []*Node
+	GetNodesByLabelRequirements(requirements []LabelRequirement) ([]*Node, error)
 	GetAllHelmReleases() []string
 	GetAllHelmCharts() []string
+	GetChartReleases(chart string) []ChartRelease
+	GetReleaseHistory(release string) []HelmRevision
+	GetNodesByGroup(grouper, group string) []*Node
+	GetApplications() []Application
+	OwnedDescendants(uid types.UID) []*Node
+	OutgoingEdges(uid types.UID) []*Edge
+	IncomingEdges(uid types.UID) []*Edge
+	Search(query string, limit int) []SearchResult
+	Generation() uint64
+	Clone() *Graph
 	AddNode(node *Node)
+	UpdateNode(uid types.UID, update func(node *Node) bool) (*Node, bool)
 	RemoveNode(uid types.UID)
 	AddEdge(edge *Edge) bool
 	RemoveEdge(fromUID, toUID types.UID)
-	AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType EdgeType)
+	StaleEdges(cutoff time.Time) []*Edge
+	FlagStaleEdges(cutoff time.Time) int
+	AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType EdgeType, metadata map[string]string)
 	AddReversePendingEdge(toUID types.UID, sourceRef RefKey, edgeType EdgeType)
+	AddPendingOwnerEdge(childUID, ownerUID types.UID, ownerRef RefKey)
 }
 
 type RefKey struct {
@@ -609,9 +1073,11 @@ func (g *Graph) processPendingEdgesForNode(node *Node) {
 			for _, pending := range pendingList {
 				// Create the edge
 				edge := &Edge{
-					Type:    pending.EdgeType,
-					FromUID: pending.FromUID,
-					ToUID:   node.UID,
+					Type:          pending.EdgeType,
+					FromUID:       pending.FromUID,
+					ToUID:         node.UID,
+					Metadata:      pending.Metadata,
+					LastConfirmed: time.Now(),
 				}
 				
 				// Add edge to both nodes
@@ -643,9 +1109,10 @@ func (g *Graph) processPendingEdgesForNode(node *Node) {
 			for _, reversePending := range reversePendingList {
 				// Create the edge
 				edge := &Edge{
-					Type:    reversePending.EdgeType,
-					FromUID: node.UID,
-					ToUID:   reversePending.ToUID,
+					Type:          reversePending.EdgeType,
+					FromUID:       node.UID,
+					ToUID:         reversePending.ToUID,
+					LastConfirmed: time.Now(),
 				}
 				
 				// Add edge to both nodes
@@ -665,10 +1132,50 @@ func (g *Graph) processPendingEdgesForNode(node *Node) {
 	for _, key := range matchedReverseKeys {
 		delete(g.reversePendingEdges, key)
 	}
+
+	// Check if there are children waiting for this node as their owner
+	if ownerPendingList, exists := g.pendingOwnerEdges[node.UID]; exists {
+		klog.V(2).Infof("Found %d pending ownership edge(s) from %s/%s", len(ownerPendingList), node.Kind, node.Name)
+
+		for _, ownerPending := range ownerPendingList {
+			if childNode, exists := g.nodes[ownerPending.ToUID]; exists {
+				edge := &Edge{
+					Type:          EdgeOwnership,
+					FromUID:       node.UID,
+					ToUID:         childNode.UID,
+					LastConfirmed: time.Now(),
+				}
+				node.OutgoingEdges[childNode.UID] = edge
+				childNode.IncomingEdges[node.UID] = edge
+				klog.V(2).Infof("Created pending ownership edge: %s/%s -> %s/%s",
+					node.Kind, node.Name, childNode.Kind, childNode.Name)
+			}
+		}
+
+		delete(g.pendingOwnerEdges, node.UID)
+	}
+}
+
+// dropPendingOwnerEdges forgets the ownership edges of a child waiting for an owner that may
+// never be added, e.g. when the owner was garbage collected. Must be called with lock held.
+func (g *Graph) dropPendingOwnerEdges(childUID types.UID) {
+	for ownerUID, pendingList := range g.pendingOwnerEdges {
+		kept := pendingList[:0]
+		for _, pending := range pendingList {
+			if pending.ToUID != childUID {
+				kept = append(kept, pending)
+			}
+		}
+		if len(kept) == 0 {
+			delete(g.pendingOwnerEdges, ownerUID)
+		} else {
+			g.pendingOwnerEdges[ownerUID] = kept
+		}
+	}
 }
 
 // AddPendingEdge adds an edge to the pending list if the target doesn't exist yet
-func (g *Graph) AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType EdgeType) {
+func (g *Graph) AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType EdgeType, metadata map[string]string) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
@@ -676,8 +1183,17 @@ func (g *Graph) AddPendingEdge(fromUID types.UID, targetRef RefKey, edgeType Edg
 		FromUID:   fromUID,
 		TargetRef: targetRef,
 		EdgeType:  edgeType,
+		Metadata:  metadata,
 	}
-	
+
+	// Processors re-add their pending edges on every update, keep the latest metadata
+	for i, existing := range g.pendingEdges[targetRef] {
+		if existing.FromUID == fromUID && existing.EdgeType == edgeType {
+			g.pendingEdges[targetRef][i].Metadata = metadata
+			return
+		}
+	}
+
 	g.pendingEdges[targetRef] = append(g.pendingEdges[targetRef], pending)
 	
 	if fromNode, exists := g.nodes[fromUID]; exists {
@@ -704,3 +1220,47 @@ func (g *Graph) AddReversePendingEdge(toUID types.UID, sourceRef RefKey, edgeTyp
 			sourceRef.GVK.Kind, sourceRef.Name, toNode.Kind, toNode.Name)
 	}
 }
+
+// AddPendingOwnerEdge records an ownership edge whose owner is not in the graph yet.
+// Owners are matched by UID, so the edge is created as soon as the owner is added
+// regardless of informer ordering. If the owner has appeared in the meantime the
+// edge is created immediately.
+func (g *Graph) AddPendingOwnerEdge(childUID, ownerUID types.UID, ownerRef RefKey) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	childNode, childExists := g.nodes[childUID]
+	if !childExists {
+		return
+	}
+
+	if ownerNode, exists := g.nodes[ownerUID]; exists {
+		edge := &Edge{
+			Type:          EdgeOwnership,
+			FromUID:       ownerUID,
+			ToUID:         childUID,
+			LastConfirmed: time.Now(),
+		}
+		ownerNode.OutgoingEdges[childUID] = edge
+		childNode.IncomingEdges[ownerUID] = edge
+		g.generation++
+		g.touch(ownerUID, childUID)
+		return
+	}
+
+	// Child updates re-register the same pending edge; keep a single entry
+	for _, existing := range g.pendingOwnerEdges[ownerUID] {
+		if existing.ToUID == childUID {
+			return
+		}
+	}
+
+	g.pendingOwnerEdges[ownerUID] = append(g.pendingOwnerEdges[ownerUID], ReversePendingEdge{
+		ToUID:     childUID,
+		SourceRef: ownerRef,
+		EdgeType:  EdgeOwnership,
+	})
+
+	klog.V(2).Infof("Added pending ownership edge: %s/%s -> %s/%s (waiting for owner)",
+		ownerRef.GVK.Kind, ownerRef.Name, childNode.Kind, childNode.Name)
+}
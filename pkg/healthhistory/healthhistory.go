@@ -0,0 +1,229 @@
+// Package healthhistory keeps a long-term history of the health of each Helm release. The graph
+// is sampled every minute, and the samples are downsampled to one point per release and hour,
+// kept by the persistence backend, so health trends survive restarts and do not require
+// Prometheus.
+package healthhistory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/klog/v2"
+)
+
+// State is the health of a release at a sample
+type State string
+
+const (
+	// StateReady means every resource of the release is Ready
+	StateReady State = "ready"
+	// StateDegraded means some resources are Pending or Unknown, but none in Error
+	StateDegraded State = "degraded"
+	// StateError means some resources are in Error
+	StateError State = "error"
+)
+
+// Point counts the samples of a release in each state over one hour
+type Point struct {
+	// Time is the start of the hour
+	Time     time.Time `json:"time"`
+	Ready    int       `json:"ready"`
+	Degraded int       `json:"degraded"`
+	Error    int       `json:"error"`
+}
+
+// Samples returns the number of samples of the point
+func (p Point) Samples() int {
+	return p.Ready + p.Degraded + p.Error
+}
+
+// Add adds the samples of other, of the same hour
+func (p *Point) Add(other Point) {
+	p.Ready += other.Ready
+	p.Degraded += other.Degraded
+	p.Error += other.Error
+}
+
+func (p *Point) count(state State) {
+	switch state {
+	case StateReady:
+		p.Ready++
+	case StateDegraded:
+		p.Degraded++
+	case StateError:
+		p.Error++
+	}
+}
+
+// Store keeps the points of each release
+type Store interface {
+	// SaveHealthPoint stores a point of a release, adding its samples to those already stored
+	// for the same hour, e.g. before a restart
+	SaveHealthPoint(release string, point Point) error
+	// ReadHealthHistory returns the points of a release from the hour of from, oldest first
+	ReadHealthHistory(release string, from time.Time) ([]Point, error)
+	// TrimHealthHistory removes the points of every release older than before
+	TrimHealthHistory(before time.Time) error
+}
+
+// Options configures the history
+type Options struct {
+	// SampleInterval is how often the health of the releases is sampled (default 1m)
+	SampleInterval time.Duration
+	// Retention is how long points are kept; 0 keeps them forever
+	Retention time.Duration
+}
+
+// DefaultOptions returns the default history options
+func DefaultOptions() Options {
+	return Options{
+		SampleInterval: time.Minute,
+		Retention:      90 * 24 * time.Hour,
+	}
+}
+
+// Recorder samples the health of the releases in the graph and stores one point per release
+// and hour
+type Recorder struct {
+	store Store
+	graph graph.GraphInterface
+	opts  Options
+
+	mu sync.Mutex
+	// hour is the hour in progress, whose points are stored once it ends
+	hour    time.Time
+	current map[string]*Point
+}
+
+// NewRecorder creates a recorder of the health of the releases of g, kept in store
+func NewRecorder(store Store, g graph.GraphInterface, opts Options) *Recorder {
+	if opts.SampleInterval <= 0 {
+		opts.SampleInterval = DefaultOptions().SampleInterval
+	}
+	return &Recorder{
+		store:   store,
+		graph:   g,
+		opts:    opts,
+		current: make(map[string]*Point),
+	}
+}
+
+// Start samples the graph every SampleInterval until ctx is cancelled, then stores the points
+// of the hour in progress, which a later run adds to
+func (r *Recorder) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.SampleInterval)
+	defer ticker.Stop()
+
+	r.sample(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			r.sample(now)
+		case <-ctx.Done():
+			r.mu.Lock()
+			r.flush()
+			r.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// sample counts the state of every release in the point of its hour, storing the points of
+// the previous hour when a new one starts
+func (r *Recorder) sample(now time.Time) {
+	states := releaseStates(r.graph.GetAllNodes())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hour := now.Truncate(time.Hour)
+	if !hour.Equal(r.hour) {
+		r.flush()
+		r.hour = hour
+		if r.opts.Retention > 0 {
+			if err := r.store.TrimHealthHistory(now.Add(-r.opts.Retention)); err != nil {
+				klog.Errorf("Failed to trim release health history: %v", err)
+			}
+		}
+	}
+	for release, state := range states {
+		point, exists := r.current[release]
+		if !exists {
+			point = &Point{Time: hour}
+			r.current[release] = point
+		}
+		point.count(state)
+	}
+}
+
+// flush stores the points of the hour in progress. Must be called with the lock held.
+func (r *Recorder) flush() {
+	for release, point := range r.current {
+		if err := r.store.SaveHealthPoint(release, *point); err != nil {
+			klog.Errorf("Failed to store health history of release %s: %v", release, err)
+		}
+	}
+	r.current = make(map[string]*Point)
+}
+
+// History returns the points of a release from the hour of from, oldest first, including the
+// hour in progress
+func (r *Recorder) History(release string, from time.Time) ([]Point, error) {
+	points, err := r.store.ReadHealthHistory(release, from)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	current, exists := r.current[release]
+	if exists {
+		point := *current
+		current = &point
+	}
+	r.mu.Unlock()
+	if !exists || current.Time.Before(from.Truncate(time.Hour)) {
+		return points, nil
+	}
+	// The hour in progress may have been stored before a restart
+	if n := len(points); n > 0 && points[n-1].Time.Equal(current.Time) {
+		points[n-1].Add(*current)
+		return points, nil
+	}
+	points = append(points, *current)
+	sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+// releaseStates returns the state of every release with resources in nodes
+func releaseStates(nodes []*graph.Node) map[string]State {
+	states := make(map[string]State)
+	for _, node := range nodes {
+		if node.HelmRelease == "" {
+			continue
+		}
+		state := StateReady
+		switch node.Status {
+		case graph.StatusReady:
+		case graph.StatusError:
+			state = StateError
+		default:
+			state = StateDegraded
+		}
+		if current, exists := states[node.HelmRelease]; !exists || severity(state) > severity(current) {
+			states[node.HelmRelease] = state
+		}
+	}
+	return states
+}
+
+func severity(state State) int {
+	switch state {
+	case StateError:
+		return 2
+	case StateDegraded:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package informers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Processors resolve references to other resources, such as the Service of an Ingress or the
+// ConfigMaps of a Pod, when the referencing resource is processed. When a widely referenced
+// resource changes or is deleted, its dependents, i.e. the resources with edges to it, are
+// reprocessed from the informer caches so their edges and statuses follow promptly instead of
+// at their next resync. Dependents are deduplicated and reprocessed at most at a fixed rate, so
+// a change to a ConfigMap used by thousands of Pods does not starve the other events.
+
+// dependentRef identifies a dependent to reprocess
+type dependentRef struct {
+	kind, namespace, name string
+}
+
+// dependentQueue holds the dependents waiting to be reprocessed, each at most once
+type dependentQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []dependentRef
+	queued  map[dependentRef]bool
+	closed  bool
+}
+
+func newDependentQueue() *dependentQueue {
+	q := &dependentQueue{queued: make(map[dependentRef]bool)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a dependent unless it is already queued
+func (q *dependentQueue) push(ref dependentRef) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.queued[ref] {
+		return
+	}
+	q.queued[ref] = true
+	q.pending = append(q.pending, ref)
+	metrics.DependentQueueDepth.Inc()
+	q.cond.Signal()
+}
+
+// pop blocks until a dependent is queued. It returns false once the queue is closed.
+func (q *dependentQueue) pop() (dependentRef, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return dependentRef{}, false
+	}
+	ref := q.pending[0]
+	q.pending = q.pending[1:]
+	delete(q.queued, ref)
+	metrics.DependentQueueDepth.Dec()
+	return ref, true
+}
+
+// close wakes up the worker and drops the queued dependents
+func (q *dependentQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	metrics.DependentQueueDepth.Sub(float64(len(q.pending)))
+	q.pending = nil
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// dependents returns the resources with edges other than ownership to a node, which the
+// processors resolved from the node's namespace, kind and name
+func dependents(g graph.GraphInterface, node *graph.Node) []dependentRef {
+	var refs []dependentRef
+	for _, edge := range g.IncomingEdges(node.UID) {
+		if edge.Type == graph.EdgeOwnership {
+			continue
+		}
+		source, exists := g.GetNode(edge.FromUID)
+		if !exists || source.Cluster != "" {
+			continue
+		}
+		refs = append(refs, dependentRef{kind: source.Kind, namespace: source.Namespace, name: source.Name})
+	}
+	return refs
+}
+
+// eventNode returns the node of the object of an event, if it is in the graph
+func (m *Manager) eventNode(e *event) *graph.Node {
+	metaObj, ok := e.obj.(metav1.Object)
+	if !ok {
+		return nil
+	}
+	node, exists := m.graph.GetNode(graph.ObjectID(metaObj.GetUID(), metaObj.GetNamespace(), e.kind, metaObj.GetName()))
+	if !exists {
+		return nil
+	}
+	return node
+}
+
+// queueDependents queues the dependents of the node of an event that changed it: an update
+// with a new resource version, or a delete, whose dependents are looked up before the node
+// and its edges are removed. Adds are left to the pending edges, and resyncs deliver the
+// dependents themselves.
+func (m *Manager) queueDependents(e *event, old *graph.Node, deleted []dependentRef) {
+	refs := deleted
+	if e.eventType == processors.EventUpdate {
+		if updated := m.eventNode(e); updated != nil && updated.ResourceVersion != old.ResourceVersion {
+			refs = dependents(m.graph, updated)
+		}
+	}
+	for _, ref := range refs {
+		m.dependents.push(ref)
+	}
+}
+
+// reprocessDependents feeds the queued dependents to the event queue from the informer caches,
+// at most at the dependent limiter's rate, until the dependent queue is closed
+func (m *Manager) reprocessDependents(ctx context.Context) error {
+	queue, events := m.dependents, m.queue
+	for {
+		ref, ok := queue.pop()
+		if !ok {
+			return nil
+		}
+		if err := m.dependentLimiter.Wait(ctx); err != nil {
+			// Shutting down
+			return nil
+		}
+		obj, exists := m.cachedObject(ref)
+		if !exists {
+			continue
+		}
+		klog.V(3).Infof("Reprocessing %s %s/%s (a resource it references changed)", ref.kind, ref.namespace, ref.name)
+		metrics.DependentReprocesses.WithLabelValues(ref.kind).Inc()
+		events.push(obj, ref.kind, processors.EventUpdate)
+	}
+}
+
+// cachedObject returns the object of a resource from the informer caches
+func (m *Manager) cachedObject(ref dependentRef) (interface{}, bool) {
+	key := ref.name
+	if ref.namespace != "" {
+		key = ref.namespace + "/" + ref.name
+	}
+	for _, watched := range m.watched.list() {
+		if watched.kind != ref.kind || (watched.namespace != "" && watched.namespace != ref.namespace) {
+			continue
+		}
+		if obj, exists, err := watched.informer.GetStore().GetByKey(key); err == nil && exists {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// newDependentLimiter returns the limiter of dependent reprocessing, nil when disabled
+func newDependentLimiter(perSecond int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), perSecond)
+}
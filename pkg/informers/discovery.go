@@ -0,0 +1,308 @@
+package informers
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+// DefaultDiscoveryTTL is how long discovered API resources are cached before being refreshed
+const DefaultDiscoveryTTL = 5 * time.Minute
+
+// builtinGroups maps the kinds of informerFactories to their API group
+var builtinGroups = map[string]string{
+	"Pod":                     "",
+	"Service":                 "",
+	"ServiceAccount":          "",
+	"ConfigMap":               "",
+	"Secret":                  "",
+	"PersistentVolumeClaim":   "",
+	"Namespace":               "",
+	"PersistentVolume":        "",
+	"Node":                    "",
+	"StorageClass":            "storage.k8s.io",
+	"CSIDriver":               "storage.k8s.io",
+	"VolumeAttachment":        "storage.k8s.io",
+	"HorizontalPodAutoscaler": "autoscaling",
+	"PodDisruptionBudget":     "policy",
+	"Deployment":              "apps",
+	"StatefulSet":             "apps",
+	"DaemonSet":               "apps",
+	"ReplicaSet":              "apps",
+	"ControllerRevision":      "apps",
+	"Job":                     "batch",
+	"CronJob":                 "batch",
+	"Ingress":                 "networking.k8s.io",
+	"EndpointSlice":           "discovery.k8s.io",
+}
+
+// APIReport lists the API resources served by the cluster, as last discovered
+type APIReport struct {
+	RefreshedAt time.Time `json:"refreshedAt"`
+	// Error is the error of the last refresh; the groups that were discovered are still listed
+	Error  string     `json:"error,omitempty"`
+	Groups []APIGroup `json:"groups"`
+}
+
+// APIGroup is an API group and its resources ("" is the core group)
+type APIGroup struct {
+	Name             string        `json:"name"`
+	PreferredVersion string        `json:"preferredVersion"`
+	Resources        []APIResource `json:"resources"`
+}
+
+// APIResource is a resource served in a version of an API group
+type APIResource struct {
+	Version    string `json:"version"`
+	Resource   string `json:"resource"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+	// Watched is set when an informer tracks the kind of the resource, in any version
+	Watched bool `json:"watched"`
+}
+
+// discoveryCache caches the API groups and resources served by the cluster, so checking
+// whether a custom resource is served does not query the API server every time
+type discoveryCache struct {
+	client discovery.DiscoveryInterface
+	ttl    time.Duration
+
+	mu          sync.RWMutex
+	groups      []*metav1.APIGroup
+	resources   map[schema.GroupVersion][]metav1.APIResource
+	refreshedAt time.Time
+	err         error
+}
+
+func newDiscoveryCache(client discovery.DiscoveryInterface, ttl time.Duration) *discoveryCache {
+	if ttl <= 0 {
+		ttl = DefaultDiscoveryTTL
+	}
+	return &discoveryCache{client: client, ttl: ttl}
+}
+
+// refresh discovers the served API resources. Groups whose discovery fails, typically an
+// unavailable aggregated API, are left out while the others are still cached.
+func (c *discoveryCache) refresh() error {
+	groups, lists, err := c.client.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		metrics.DiscoveryRefreshes.WithLabelValues("error").Inc()
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		return err
+	}
+
+	resources := make(map[schema.GroupVersion][]metav1.APIResource, len(lists))
+	for _, list := range lists {
+		gv, parseErr := schema.ParseGroupVersion(list.GroupVersion)
+		if parseErr != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			// Subresources such as pods/log are not watchable
+			if !strings.Contains(resource.Name, "/") {
+				resources[gv] = append(resources[gv], resource)
+			}
+		}
+	}
+
+	result := "success"
+	if err != nil {
+		result = "partial"
+		klog.Warningf("Partial API discovery: %v", err)
+	}
+	metrics.DiscoveryRefreshes.WithLabelValues(result).Inc()
+
+	c.mu.Lock()
+	c.groups = groups
+	c.resources = resources
+	c.refreshedAt = time.Now()
+	c.err = err
+	c.mu.Unlock()
+	return nil
+}
+
+// fresh refreshes the cache if it is older than its TTL
+func (c *discoveryCache) fresh() {
+	c.mu.RLock()
+	expired := time.Since(c.refreshedAt) > c.ttl
+	c.mu.RUnlock()
+	if expired {
+		if err := c.refresh(); err != nil {
+			klog.Warningf("Failed to discover the API resources of the cluster: %v", err)
+		}
+	}
+}
+
+// served reports whether the cluster serves a resource
+func (c *discoveryCache) served(gvr schema.GroupVersionResource) bool {
+	c.fresh()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, resource := range c.resources[gvr.GroupVersion()] {
+		if resource.Name == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// report lists the cached groups and resources, flagging those of watched kinds
+func (c *discoveryCache) report(watched map[schema.GroupKind]bool) APIReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := APIReport{RefreshedAt: c.refreshedAt, Groups: make([]APIGroup, 0, len(c.groups))}
+	if c.err != nil {
+		report.Error = c.err.Error()
+	}
+	for _, group := range c.groups {
+		apiGroup := APIGroup{
+			Name:             group.Name,
+			PreferredVersion: group.PreferredVersion.Version,
+			Resources:        make([]APIResource, 0),
+		}
+		for _, version := range group.Versions {
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+			for _, resource := range c.resources[gv] {
+				apiGroup.Resources = append(apiGroup.Resources, APIResource{
+					Version:    version.Version,
+					Resource:   resource.Name,
+					Kind:       resource.Kind,
+					Namespaced: resource.Namespaced,
+					Watched:    watched[schema.GroupKind{Group: group.Name, Kind: resource.Kind}],
+				})
+			}
+		}
+		report.Groups = append(report.Groups, apiGroup)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Name < report.Groups[j].Name })
+	return report
+}
+
+// APIs reports the API resources served by the cluster and whether their kinds are watched
+func (m *Manager) APIs() APIReport {
+	m.discovery.fresh()
+	return m.discovery.report(m.watchedGroupKinds())
+}
+
+// watchedGroupKinds returns the kinds tracked by informers, including the namespaced kinds
+// only started when a namespace is activated in lazy mode
+func (m *Manager) watchedGroupKinds() map[schema.GroupKind]bool {
+	watched := make(map[schema.GroupKind]bool)
+	for _, informer := range m.watched.list() {
+		if group, builtin := builtinGroups[informer.kind]; builtin {
+			watched[schema.GroupKind{Group: group, Kind: informer.kind}] = true
+		}
+	}
+
+	m.lazy.mu.Lock()
+	for _, kind := range m.lazy.kinds {
+		watched[schema.GroupKind{Group: builtinGroups[kind], Kind: kind}] = true
+	}
+	for kind, gvr := range m.lazy.dynamic {
+		watched[schema.GroupKind{Group: gvr.Group, Kind: kind}] = true
+	}
+	m.lazy.mu.Unlock()
+
+	m.dynamic.mu.Lock()
+	for kind, gvr := range m.dynamic.watched {
+		watched[schema.GroupKind{Group: gvr.Group, Kind: kind}] = true
+	}
+	m.dynamic.mu.Unlock()
+	return watched
+}
+
+// refreshDiscovery refreshes the discovery cache and starts watching the custom resource
+// kinds whose CRD was installed since they were last checked
+func (m *Manager) refreshDiscovery() {
+	if err := m.discovery.refresh(); err != nil {
+		klog.Warningf("Failed to refresh the API resources of the cluster: %v", err)
+		return
+	}
+	if m.dynamicClient == nil {
+		return
+	}
+
+	for _, kind := range m.kindFilter.EnabledKinds() {
+		if candidates, isDynamic := dynamicKinds[kind]; isDynamic {
+			m.watchServed(kind, candidates)
+		}
+	}
+	for _, resource := range m.processorOptions.CustomResources {
+		m.watchServed(resource.Kind, []schema.GroupVersionResource{resource.Resource})
+	}
+}
+
+// watchServed starts watching a custom resource kind that is not watched yet when one of its
+// candidate resources is served
+func (m *Manager) watchServed(kind string, candidates []schema.GroupVersionResource) {
+	if m.dynamicWatched(kind) {
+		return
+	}
+	for _, candidate := range candidates {
+		if m.discovery.served(candidate) {
+			klog.Infof("%s is now served by the cluster, watching %s", candidate.GroupResource().String(), kind)
+			m.watchDynamic(kind, candidate)
+			return
+		}
+	}
+}
+
+// dynamicWatched reports whether a custom resource kind is watched
+func (m *Manager) dynamicWatched(kind string) bool {
+	m.dynamic.mu.Lock()
+	_, watched := m.dynamic.watched[kind]
+	m.dynamic.mu.Unlock()
+	if watched {
+		return true
+	}
+
+	m.lazy.mu.Lock()
+	defer m.lazy.mu.Unlock()
+	_, watched = m.lazy.dynamic[kind]
+	return watched
+}
+
+// watchDynamic starts watching a custom resource kind after the initial registration. In lazy
+// mode the kind is also started in the namespaces already activated.
+func (m *Manager) watchDynamic(kind string, gvr schema.GroupVersionResource) {
+	if m.lazy.enabled {
+		m.lazy.mu.Lock()
+		defer m.lazy.mu.Unlock()
+		m.lazy.dynamic[kind] = gvr
+		for namespace, ns := range m.lazy.active {
+			factory := m.newDynamicFactory(namespace, m.selectorFor(kind))
+			if err := m.register(kind, namespace, factory.ForResource(gvr).Informer()); err != nil {
+				klog.Errorf("Failed to register %s informer in namespace %s: %v", kind, namespace, err)
+				continue
+			}
+			factory.Start(ns.stopCh)
+		}
+		return
+	}
+
+	namespaces := []string{""}
+	if len(m.namespaces) > 0 {
+		namespaces = m.namespaces
+	}
+	for _, namespace := range namespaces {
+		factory := m.dynamicFactoryFor(namespace, m.selectorFor(kind))
+		if err := m.register(kind, namespace, factory.ForResource(gvr).Informer()); err != nil {
+			klog.Errorf("Failed to register %s informer: %v", kind, err)
+			continue
+		}
+		factory.Start(m.stopCh)
+	}
+	m.dynamic.mu.Lock()
+	m.dynamic.watched[kind] = gvr
+	m.dynamic.mu.Unlock()
+}
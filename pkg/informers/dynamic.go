@@ -0,0 +1,99 @@
+package informers
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+)
+
+// dynamicKinds maps custom resource kinds to the resources watched through dynamic informers,
+// in order of preference. The first version served by the cluster is watched.
+var dynamicKinds = map[string][]schema.GroupVersionResource{
+	"Application": {
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+	},
+	"Kustomization": {
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"},
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Resource: "kustomizations"},
+	},
+	"HelmRelease": {
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Resource: "helmreleases"},
+	},
+	"Machine": {
+		{Group: "cluster.x-k8s.io", Version: "v1beta1", Resource: "machines"},
+		{Group: "cluster.x-k8s.io", Version: "v1beta2", Resource: "machines"},
+	},
+	"MachineSet": {
+		{Group: "cluster.x-k8s.io", Version: "v1beta1", Resource: "machinesets"},
+		{Group: "cluster.x-k8s.io", Version: "v1beta2", Resource: "machinesets"},
+	},
+	"MachineDeployment": {
+		{Group: "cluster.x-k8s.io", Version: "v1beta1", Resource: "machinedeployments"},
+		{Group: "cluster.x-k8s.io", Version: "v1beta2", Resource: "machinedeployments"},
+	},
+	"VirtualService": {
+		{Group: "networking.istio.io", Version: "v1", Resource: "virtualservices"},
+		{Group: "networking.istio.io", Version: "v1beta1", Resource: "virtualservices"},
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"},
+	},
+	"DestinationRule": {
+		{Group: "networking.istio.io", Version: "v1", Resource: "destinationrules"},
+		{Group: "networking.istio.io", Version: "v1beta1", Resource: "destinationrules"},
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "destinationrules"},
+	},
+	"Gateway": {
+		{Group: "networking.istio.io", Version: "v1", Resource: "gateways"},
+		{Group: "networking.istio.io", Version: "v1beta1", Resource: "gateways"},
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"},
+	},
+	"Certificate": {
+		{Group: "cert-manager.io", Version: "v1", Resource: "certificates"},
+	},
+	"CertificateRequest": {
+		{Group: "cert-manager.io", Version: "v1", Resource: "certificaterequests"},
+	},
+	"Issuer": {
+		{Group: "cert-manager.io", Version: "v1", Resource: "issuers"},
+	},
+	"ClusterIssuer": {
+		{Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"},
+	},
+}
+
+// dynamicFactoryFor returns the dynamic informer factory for a namespace ("" for cluster-wide)
+// and label selector, creating it on first use
+func (m *Manager) dynamicFactoryFor(namespace, selector string) dynamicinformer.DynamicSharedInformerFactory {
+	key := factoryKey{namespace: namespace, selector: selector}
+	if factory, exists := m.dynamicFactories[key]; exists {
+		return factory
+	}
+	factory := m.newDynamicFactory(namespace, selector)
+	m.dynamicFactories[key] = factory
+	return factory
+}
+
+// newDynamicFactory creates a dynamic informer factory for a namespace ("" for cluster-wide)
+// and label selector
+func (m *Manager) newDynamicFactory(namespace, selector string) dynamicinformer.DynamicSharedInformerFactory {
+	return dynamicinformer.NewFilteredDynamicSharedInformerFactory(m.dynamicClient, ResyncPeriod, namespace, func(options *metav1.ListOptions) {
+		if selector != "" {
+			options.LabelSelector = selector
+		}
+	})
+}
+
+// dynamicState tracks the custom resource kinds watched outside lazy mode and their resource
+type dynamicState struct {
+	mu      sync.Mutex
+	watched map[string]schema.GroupVersionResource
+}
+
+// resourceServed reports whether the API server serves a resource, so informers are only
+// started for custom resources whose CRD is installed (they would never sync otherwise)
+func (m *Manager) resourceServed(gvr schema.GroupVersionResource) bool {
+	return m.discovery.served(gvr)
+}
@@ -0,0 +1,206 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// lazyState tracks the namespaces watched in lazy mode. Namespaces are discovered through a
+// cluster-wide Namespace informer; the informers of namespaced kinds are only started for
+// namespaces matching the configured patterns, or when a namespace is first queried.
+type lazyState struct {
+	enabled  bool
+	patterns []string
+
+	// Namespaced kinds registered when a namespace is activated
+	kinds   []string
+	dynamic map[string]schema.GroupVersionResource
+
+	mu         sync.Mutex
+	namespaces cache.SharedIndexInformer
+	active     map[string]*lazyNamespace
+	stopped    bool
+}
+
+// lazyNamespace holds the informers of an activated namespace
+type lazyNamespace struct {
+	stopCh chan struct{}
+	// synced is closed once the caches of the namespace have synced
+	synced chan struct{}
+}
+
+// matches reports whether a namespace is watched from the start
+func (l *lazyState) matches(namespace string) bool {
+	for _, pattern := range l.patterns {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// registerNamespaceDiscovery activates namespaces matching the patterns as they appear and
+// stops the informers of deleted namespaces
+func (m *Manager) registerNamespaceDiscovery() error {
+	informer := m.factoryFor("", m.selectorFor("Namespace")).Core().V1().Namespaces().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok && m.lazy.matches(ns.Name) {
+				m.activateNamespace(ns.Name, "matches namespace patterns")
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				m.deactivateNamespace(ns.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register namespace discovery: %w", err)
+	}
+
+	m.lazy.mu.Lock()
+	m.lazy.namespaces = informer
+	m.lazy.mu.Unlock()
+	klog.Infof("Lazy namespace mode: watching namespaces matching %v, others on first query", m.lazy.patterns)
+	return nil
+}
+
+// ActivateNamespace starts watching an existing namespace in lazy mode and waits until its
+// caches have synced or ctx is done. It does nothing when lazy mode is disabled, the namespace
+// is already watched or does not exist.
+func (m *Manager) ActivateNamespace(ctx context.Context, namespace string) error {
+	if !m.lazy.enabled || namespace == "" {
+		return nil
+	}
+
+	m.lazy.mu.Lock()
+	namespaces := m.lazy.namespaces
+	m.lazy.mu.Unlock()
+	if namespaces == nil || !namespaces.HasSynced() {
+		return fmt.Errorf("namespaces not discovered yet")
+	}
+	if _, exists, err := namespaces.GetIndexer().GetByKey(namespace); err != nil || !exists {
+		return err
+	}
+
+	ns := m.activateNamespace(namespace, "queried")
+	if ns == nil {
+		return nil
+	}
+	select {
+	case <-ns.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// activateNamespace registers and starts the informers of the namespaced kinds in a namespace,
+// returning nil once the manager is stopped
+func (m *Manager) activateNamespace(namespace, reason string) *lazyNamespace {
+	m.lazy.mu.Lock()
+	defer m.lazy.mu.Unlock()
+
+	if ns, exists := m.lazy.active[namespace]; exists {
+		return ns
+	}
+	if m.lazy.stopped {
+		return nil
+	}
+
+	klog.Infof("Starting informers for namespace %s (%s)", namespace, reason)
+	ns := &lazyNamespace{
+		stopCh: make(chan struct{}),
+		synced: make(chan struct{}),
+	}
+
+	// Kinds with their own label selector get factories of their own
+	factories := make(map[string]informers.SharedInformerFactory)
+	for _, kind := range m.lazy.kinds {
+		selector := m.selectorFor(kind)
+		factory, exists := factories[selector]
+		if !exists {
+			factory = m.newFactory(namespace, selector)
+			factories[selector] = factory
+		}
+		if err := m.register(kind, namespace, informerFactories[kind](factory)); err != nil {
+			klog.Errorf("Failed to register %s informer in namespace %s: %v", kind, namespace, err)
+		}
+	}
+	dynamicFactories := make(map[string]dynamicinformer.DynamicSharedInformerFactory)
+	for kind, gvr := range m.lazy.dynamic {
+		selector := m.selectorFor(kind)
+		factory, exists := dynamicFactories[selector]
+		if !exists {
+			factory = m.newDynamicFactory(namespace, selector)
+			dynamicFactories[selector] = factory
+		}
+		if err := m.register(kind, namespace, factory.ForResource(gvr).Informer()); err != nil {
+			klog.Errorf("Failed to register %s informer in namespace %s: %v", kind, namespace, err)
+		}
+	}
+	for _, factory := range factories {
+		factory.Start(ns.stopCh)
+	}
+	for _, factory := range dynamicFactories {
+		factory.Start(ns.stopCh)
+	}
+
+	go func() {
+		for _, factory := range factories {
+			factory.WaitForCacheSync(ns.stopCh)
+		}
+		for _, factory := range dynamicFactories {
+			factory.WaitForCacheSync(ns.stopCh)
+		}
+		close(ns.synced)
+		klog.V(2).Infof("Informer caches of namespace %s synced", namespace)
+	}()
+
+	m.lazy.active[namespace] = ns
+	metrics.WatchedNamespaces.Set(float64(len(m.lazy.active)))
+	return ns
+}
+
+// deactivateNamespace stops the informers of a deleted namespace. Its resources were deleted
+// (and removed from the graph) before the namespace itself.
+func (m *Manager) deactivateNamespace(namespace string) {
+	m.lazy.mu.Lock()
+	defer m.lazy.mu.Unlock()
+
+	ns, exists := m.lazy.active[namespace]
+	if !exists {
+		return
+	}
+	close(ns.stopCh)
+	delete(m.lazy.active, namespace)
+	m.watched.forget(namespace)
+	metrics.WatchedNamespaces.Set(float64(len(m.lazy.active)))
+	klog.Infof("Stopped informers for deleted namespace %s", namespace)
+}
+
+// stopLazyNamespaces stops the informers of all activated namespaces
+func (m *Manager) stopLazyNamespaces() {
+	m.lazy.mu.Lock()
+	defer m.lazy.mu.Unlock()
+
+	m.lazy.stopped = true
+	for namespace, ns := range m.lazy.active {
+		close(ns.stopCh)
+		delete(m.lazy.active, namespace)
+	}
+}
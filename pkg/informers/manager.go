@@ -3,99 +3,354 @@ package informers
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ammarlakis/astrolabe/pkg/audit"
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
 	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"github.com/ammarlakis/astrolabe/pkg/supervisor"
+	"github.com/ammarlakis/astrolabe/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 )
 
 const (
-	defaultResyncPeriod = 10 * time.Minute
+	// ResyncPeriod is how often informers redeliver every object, reconfirming its edges
+	ResyncPeriod = 10 * time.Minute
 )
 
+// Options configures which resources the manager watches and how they are processed
+type Options struct {
+	// LabelSelector filters watched resources (empty = all resources)
+	LabelSelector string
+	// LabelSelectors refine LabelSelector by kind and exclude labeled objects
+	LabelSelectors LabelSelectors
+	// Namespaces restricts namespaced informers to these namespaces (empty = cluster-wide)
+	Namespaces []string
+	// Processors configures the processor registry, including the watched kinds
+	Processors processors.Options
+	// DynamicClient watches custom resources (optional, custom resources are skipped without it)
+	DynamicClient dynamic.Interface
+	// LazyNamespaces discovers namespaces first and only starts the informers of namespaced
+	// kinds for namespaces matching NamespacePatterns or when a namespace is first queried
+	LazyNamespaces bool
+	// NamespacePatterns are glob patterns of the namespaces watched from the start in lazy mode
+	NamespacePatterns []string
+	// Prune handles nodes whose object is gone from the synced caches, checked after the
+	// initial sync and then every resync period
+	Prune PruneMode
+	// EventRateLimit caps the events processed per second (0 = unlimited), allowing bursts of
+	// EventBurst events. Events of an object updated while queued are coalesced meanwhile.
+	EventRateLimit int
+	EventBurst     int
+	// DiscoveryTTL is how long the discovered API resources are cached (default
+	// DefaultDiscoveryTTL). They are refreshed at this interval, starting the informers of
+	// custom resources installed in the meantime.
+	DiscoveryTTL time.Duration
+	// DependentRate caps the dependents of changed resources reprocessed per second, see
+	// dependents.go (0 = dependents wait for their resync)
+	DependentRate int
+	// Workers is the number of workers processing the events of kinds concurrently, see
+	// workers.go. The events of the other kinds are processed by a single worker.
+	Workers map[string]int
+}
+
 // Manager manages all Kubernetes informers and updates the graph
 type Manager struct {
 	clientset     *kubernetes.Clientset
 	graph         graph.GraphInterface
-	factory       informers.SharedInformerFactory
 	stopCh        chan struct{}
 	labelSelector string
+	selectors     LabelSelectors
+	kindFilter    processors.KindFilter
+
+	// Informer factories by namespace and label selector, see factoryKey
+	namespaces []string
+	factories  map[factoryKey]informers.SharedInformerFactory
+
+	// Dynamic informer factories for custom resources, by namespace and label selector
+	dynamicClient    dynamic.Interface
+	dynamicFactories map[factoryKey]dynamicinformer.DynamicSharedInformerFactory
+	dynamic          dynamicState
+
+	// Served API resources, see discovery.go
+	discovery *discoveryCache
+
+	// Processors for different resource types, rebuilt from their options when the watched
+	// kinds are reconfigured
+	processors       *processors.ProcessorRegistry
+	processorGraph   graph.GraphInterface
+	processorOptions processors.Options
+
+	// Events are queued by the informer handlers and processed by a single worker, at most
+	// at the limiter's rate, which hands the events of kinds with workers to their pool
+	queue   *eventQueue
+	limiter *rate.Limiter
+	workers map[string]int
+	pools   map[string]*workerPool
+
+	// Dependents of changed resources waiting to be reprocessed, see dependents.go
+	dependents       *dependentQueue
+	dependentLimiter *rate.Limiter
+
+	// Lazily started namespaces, see lazy.go
+	lazy lazyState
 
-	// Processors for different resource types
-	processors *processors.ProcessorRegistry
+	// Registered informers, compared with the graph to prune stale nodes (see prune.go)
+	watched   watchedInformers
+	pruneMode PruneMode
+
+	// Filters waiting to be applied by the running manager, see reconfigure.go
+	filtersMu      sync.Mutex
+	pendingFilters *Filters
+	reconfigure    chan struct{}
+	reconciling    bool
+
+	// synced is set once the informer caches of the current run have synced
+	synced atomic.Bool
 }
 
 // NewManager creates a new informer manager
-func NewManager(clientset *kubernetes.Clientset, g graph.GraphInterface, labelSelector string) *Manager {
-	// Create shared informer factory with label selector
-	var factory informers.SharedInformerFactory
-
-	if labelSelector != "" {
-		factory = informers.NewSharedInformerFactoryWithOptions(
-			clientset,
-			defaultResyncPeriod,
-			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
-				options.LabelSelector = labelSelector
-			}),
-		)
-	} else {
-		factory = informers.NewSharedInformerFactory(clientset, defaultResyncPeriod)
+func NewManager(clientset *kubernetes.Clientset, g graph.GraphInterface, opts Options) *Manager {
+	var limiter *rate.Limiter
+	if opts.EventRateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.EventRateLimit), max(opts.EventBurst, 1))
+	}
+
+	// Pruning writes through its own audit wrapper, the processors get one for events
+	var pruneGraph graph.GraphInterface = g
+	if opts.Processors.Audit != nil {
+		pruneGraph = opts.Processors.Audit.Graph(g, audit.OriginPrune)
 	}
 
 	return &Manager{
-		clientset:     clientset,
-		graph:         g,
-		factory:       factory,
-		stopCh:        make(chan struct{}),
-		labelSelector: labelSelector,
-		processors:    processors.NewProcessorRegistry(g),
+		clientset:        clientset,
+		graph:            pruneGraph,
+		stopCh:           make(chan struct{}),
+		labelSelector:    opts.LabelSelector,
+		selectors:        opts.LabelSelectors,
+		kindFilter:       opts.Processors.Kinds,
+		namespaces:       opts.Namespaces,
+		factories:        make(map[factoryKey]informers.SharedInformerFactory),
+		processors:       processors.NewProcessorRegistry(g, opts.Processors),
+		processorGraph:   g,
+		processorOptions: opts.Processors,
+		queue:            newEventQueue(),
+		limiter:          limiter,
+		workers:          opts.Workers,
+		dependents:       newDependentQueue(),
+		dependentLimiter: newDependentLimiter(opts.DependentRate),
+		pruneMode:        opts.Prune,
+		reconfigure:      make(chan struct{}, 1),
+
+		dynamicClient:    opts.DynamicClient,
+		dynamicFactories: make(map[factoryKey]dynamicinformer.DynamicSharedInformerFactory),
+		dynamic:          dynamicState{watched: make(map[string]schema.GroupVersionResource)},
+		discovery:        newDiscoveryCache(clientset.Discovery(), opts.DiscoveryTTL),
+
+		lazy: lazyState{
+			enabled:  opts.LazyNamespaces,
+			patterns: opts.NamespacePatterns,
+			dynamic:  make(map[string]schema.GroupVersionResource),
+			active:   make(map[string]*lazyNamespace),
+		},
 	}
 }
 
-// Start starts all informers
+// factoryKey identifies an informer factory: the informers of a factory share its namespace
+// ("" for cluster-wide) and label selector, so kinds with their own selector (see
+// LabelSelectors) get factories of their own
+type factoryKey struct {
+	namespace string
+	selector  string
+}
+
+// factoryFor returns the shared informer factory for a namespace ("" for cluster-wide) and
+// label selector, creating it on first use
+func (m *Manager) factoryFor(namespace, selector string) informers.SharedInformerFactory {
+	key := factoryKey{namespace: namespace, selector: selector}
+	if factory, exists := m.factories[key]; exists {
+		return factory
+	}
+	factory := m.newFactory(namespace, selector)
+	m.factories[key] = factory
+	return factory
+}
+
+// newFactory creates a shared informer factory for a namespace ("" for cluster-wide) and
+// label selector
+func (m *Manager) newFactory(namespace, selector string) informers.SharedInformerFactory {
+	options := []informers.SharedInformerOption{}
+	if namespace != "" {
+		options = append(options, informers.WithNamespace(namespace))
+	}
+	if selector != "" {
+		options = append(options, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		}))
+	}
+
+	return informers.NewSharedInformerFactoryWithOptions(m.clientset, ResyncPeriod, options...)
+}
+
+// Start starts all informers and blocks until ctx is cancelled. It returns an error, after
+// stopping the informers, when they cannot be started or the event worker keeps failing; Start
+// can then be called again. When the filters are changed with Reconfigure, the informers are
+// stopped and started again with the new filters.
 func (m *Manager) Start(ctx context.Context) error {
+	for {
+		reconfigured, err := m.run(ctx)
+		if err != nil || !reconfigured {
+			return err
+		}
+	}
+}
+
+// run starts all informers and blocks until ctx is cancelled or new filters are to be
+// applied, which it reports
+func (m *Manager) run(ctx context.Context) (bool, error) {
 	klog.Info("Starting informer manager")
+	m.reset()
+	defer m.Stop()
+	if m.reconciling {
+		m.processors = processors.NewProcessorRegistry(m.processorGraph, m.processorOptions)
+	}
 
 	// Register all informers
-	if err := m.registerInformers(); err != nil {
-		return fmt.Errorf("failed to register informers: %w", err)
+	if err := m.registerInformers(ctx); err != nil {
+		return false, fmt.Errorf("failed to register informers: %w", err)
+	}
+
+	// A panicking processor only loses its event: the worker is restarted on the same queue
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	defer stopWorker()
+	m.pools = m.startPools(workerCtx)
+	supervisor.Go(workerCtx, "event-worker", m.processEvents)
+	if m.dependentLimiter != nil {
+		supervisor.Go(workerCtx, "dependent-worker", m.reprocessDependents)
 	}
 
-	// Start the factory
-	m.factory.Start(m.stopCh)
+	// Start the factories
+	for _, factory := range m.factories {
+		factory.Start(m.stopCh)
+	}
+	for _, factory := range m.dynamicFactories {
+		factory.Start(m.stopCh)
+	}
 	// Wait for caches to sync
 	klog.Info("Waiting for informer caches to sync")
+	_, span := tracing.Start(ctx, "informers.sync", attribute.Int("astrolabe.informer.factories", len(m.factories)+len(m.dynamicFactories)))
 	if !m.waitForCacheSync() {
-		return fmt.Errorf("failed to sync informer caches")
+		err := fmt.Errorf("failed to sync informer caches")
+		tracing.End(span, err)
+		return false, err
 	}
+	span.End()
 
 	klog.Info("All informer caches synced successfully")
+	m.synced.Store(true)
+
+	if m.reconciling {
+		// Nodes that matched the previous filters must go, whatever the prune mode
+		m.reconcile()
+		m.reconciling = false
+	} else {
+		// Nodes restored from Redis may belong to objects deleted while we were down
+		m.prune()
+	}
+
+	ticker := time.NewTicker(ResyncPeriod)
+	defer ticker.Stop()
+	discoveryTicker := time.NewTicker(m.discovery.ttl)
+	defer discoveryTicker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.prune()
+		case <-discoveryTicker.C:
+			m.refreshDiscovery()
+		case <-m.reconfigure:
+			if m.applyFilters() {
+				return true, nil
+			}
+		case <-ctx.Done():
+			return false, nil
+		}
+	}
+}
 
-	// Wait for context cancellation
-	<-ctx.Done()
-	m.Stop()
+// reset drops the informers of a previous run, so Start can be called again
+func (m *Manager) reset() {
+	m.synced.Store(false)
+	m.stopCh = make(chan struct{})
+	m.factories = make(map[factoryKey]informers.SharedInformerFactory)
+	m.dynamicFactories = make(map[factoryKey]dynamicinformer.DynamicSharedInformerFactory)
+	m.watched.clear()
+	m.dynamic.mu.Lock()
+	m.dynamic.watched = make(map[string]schema.GroupVersionResource)
+	m.dynamic.mu.Unlock()
 
-	return nil
+	// Lazily activated namespaces register informers, and read the queue, under the lock
+	m.lazy.mu.Lock()
+	m.queue = newEventQueue()
+	metrics.EventQueueDepth.Reset()
+	metrics.EventWorkerBacklog.Reset()
+	m.dependents = newDependentQueue()
+	m.lazy.kinds = nil
+	m.lazy.dynamic = make(map[string]schema.GroupVersionResource)
+	m.lazy.namespaces = nil
+	m.lazy.active = make(map[string]*lazyNamespace)
+	m.lazy.stopped = false
+	m.lazy.mu.Unlock()
+}
+
+// HasSynced reports whether the informer caches have synced, i.e. the graph holds the watched
+// resources. It is false again while the informers are rebuilt with new filters.
+func (m *Manager) HasSynced() bool {
+	return m.synced.Load()
 }
 
 // Stop stops all informers
 func (m *Manager) Stop() {
 	klog.Info("Stopping informer manager")
 	close(m.stopCh)
+	m.stopLazyNamespaces()
+	m.queue.close()
+	m.dependents.close()
 }
 
 // waitForCacheSync waits for all informer caches to sync
 func (m *Manager) waitForCacheSync() bool {
-	synced := m.factory.WaitForCacheSync(m.stopCh)
-	for informerType, ok := range synced {
-		if !ok {
-			klog.Errorf("Failed to sync cache for %v", informerType)
-			return false
+	for key, factory := range m.factories {
+		synced := factory.WaitForCacheSync(m.stopCh)
+		for informerType, ok := range synced {
+			if !ok {
+				klog.Errorf("Failed to sync cache for %v (namespace %q)", informerType, key.namespace)
+				return false
+			}
+		}
+	}
+	for key, factory := range m.dynamicFactories {
+		synced := factory.WaitForCacheSync(m.stopCh)
+		for resource, ok := range synced {
+			if !ok {
+				klog.Errorf("Failed to sync cache for %s (namespace %q)", resource.String(), key.namespace)
+				return false
+			}
 		}
 	}
 	return true
@@ -103,7 +358,82 @@ func (m *Manager) waitForCacheSync() bool {
 
 // Generic event handlers
 
-func (m *Manager) onEvent(obj interface{}, kind string, eventType processors.EventType) {
+func (m *Manager) onEvent(queue *eventQueue, obj interface{}, kind string, eventType processors.EventType) {
 	klog.V(2).Infof("Cache: %s %s", string(eventType), kind)
-	m.processors.Process(obj, kind, eventType)
+	// Deletes missed while disconnected arrive as tombstones holding the last known state
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	queue.push(obj, kind, eventType)
+}
+
+// processEvents hands queued events to the processors, or to the worker pool of their kind,
+// until the queue is closed. While the worker waits for the rate limiter, or for a busy pool,
+// further updates of queued objects are coalesced.
+func (m *Manager) processEvents(ctx context.Context) error {
+	// The processors are rebuilt for the next run when the watched kinds change
+	registry, pools := m.processors, m.pools
+	for {
+		e, ok := m.queue.pop()
+		if !ok {
+			return nil
+		}
+		if m.limiter != nil {
+			start := time.Now()
+			if err := m.limiter.Wait(ctx); err != nil {
+				// Shutting down
+				return nil
+			}
+			metrics.EventRateLimitDelay.Observe(time.Since(start).Seconds())
+		}
+		if pool := pools[e.kind]; pool != nil {
+			if !pool.dispatch(ctx, e) {
+				return nil
+			}
+			continue
+		}
+		m.process(ctx, registry, e)
+	}
+}
+
+// process hands an event to the processors and queues the dependents of the resource it
+// changed
+func (m *Manager) process(ctx context.Context, registry *processors.ProcessorRegistry, e *event) {
+	metrics.EventProcessingLag.WithLabelValues(string(e.eventType)).Observe(time.Since(e.queued).Seconds())
+	span := m.traceEvent(ctx, e)
+	defer span.End()
+
+	var old *graph.Node
+	var deleted []dependentRef
+	if m.dependentLimiter != nil {
+		old = m.eventNode(e)
+		if old != nil && e.eventType == processors.EventDelete {
+			deleted = dependents(m.graph, old)
+		}
+	}
+	registry.Process(e.obj, e.kind, e.eventType)
+	if old != nil {
+		m.queueDependents(e, old, deleted)
+	}
+}
+
+// traceEvent starts the span of an event about to be processed. The span starts when the event
+// was queued, so the time it waited for the worker shows next to its processing.
+func (m *Manager) traceEvent(ctx context.Context, e *event) trace.Span {
+	attrs := []attribute.KeyValue{
+		attribute.String("k8s.kind", e.kind),
+		attribute.String("astrolabe.event.type", string(e.eventType)),
+	}
+	if object, err := meta.Accessor(e.obj); err == nil {
+		attrs = append(attrs,
+			attribute.String("k8s.namespace.name", object.GetNamespace()),
+			attribute.String("k8s.object.name", object.GetName()),
+		)
+	}
+	_, span := tracing.Tracer().Start(ctx, "process "+e.kind,
+		trace.WithTimestamp(e.queued),
+		trace.WithAttributes(attrs...),
+	)
+	span.AddEvent("processing")
+	return span
 }
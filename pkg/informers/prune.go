@@ -0,0 +1,181 @@
+package informers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// PruneMode controls what happens to graph nodes whose object is gone from the informer caches.
+// Such nodes are left behind by deletes missed while Astrolabe was down, in particular when the
+// graph is restored from Redis.
+type PruneMode string
+
+const (
+	// PruneOff keeps stale nodes
+	PruneOff PruneMode = "off"
+	// PruneMark flags stale nodes with the NotInCluster reason but keeps them in the graph
+	PruneMark PruneMode = "mark"
+	// PruneRemove removes stale nodes from the graph
+	PruneRemove PruneMode = "remove"
+)
+
+// ParsePruneMode parses a prune mode flag value
+func ParsePruneMode(value string) (PruneMode, error) {
+	switch mode := PruneMode(strings.ToLower(value)); mode {
+	case "":
+		return PruneOff, nil
+	case PruneOff, PruneMark, PruneRemove:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid prune mode %q (expected off, mark or remove)", value)
+	}
+}
+
+// watchedInformer is a registered informer and the scope it covers
+type watchedInformer struct {
+	kind      string
+	namespace string // "" for cluster-wide informers
+	informer  cache.SharedIndexInformer
+}
+
+// watchedInformers tracks the registered informers, so the graph can be compared with their
+// stores
+type watchedInformers struct {
+	mu        sync.Mutex
+	informers []watchedInformer
+}
+
+func (w *watchedInformers) add(kind, namespace string, informer cache.SharedIndexInformer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.informers = append(w.informers, watchedInformer{kind, namespace, informer})
+}
+
+// clear drops every informer
+func (w *watchedInformers) clear() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.informers = nil
+}
+
+// forget drops the informers of a namespace whose informers were stopped
+func (w *watchedInformers) forget(namespace string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	kept := w.informers[:0]
+	for _, watched := range w.informers {
+		if watched.namespace != namespace {
+			kept = append(kept, watched)
+		}
+	}
+	w.informers = kept
+}
+
+func (w *watchedInformers) list() []watchedInformer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]watchedInformer(nil), w.informers...)
+}
+
+// prune reconciles the graph with the informer stores: nodes of a watched kind and namespace
+// whose object is in none of the synced stores are marked or removed. Kinds and namespaces
+// without a synced informer are left alone, since their nodes cannot be confirmed. It returns
+// the number of stale nodes found.
+func (m *Manager) prune() int {
+	return m.pruneWith(m.pruneMode)
+}
+
+// pruneWith prunes the graph with a prune mode
+func (m *Manager) pruneWith(mode PruneMode) int {
+	if mode == PruneOff || mode == "" {
+		return 0
+	}
+	start := time.Now()
+
+	type scope struct{ kind, namespace string }
+	covered := make(map[scope]bool) // false when an informer of the scope has not synced
+	live := make(map[types.UID]bool)
+	for _, watched := range m.watched.list() {
+		key := scope{watched.kind, watched.namespace}
+		if !watched.informer.HasSynced() {
+			covered[key] = false
+			continue
+		}
+		if _, seen := covered[key]; !seen {
+			covered[key] = true
+		}
+		for _, obj := range watched.informer.GetStore().List() {
+			if metaObj, ok := obj.(metav1.Object); ok {
+				live[graph.ObjectID(metaObj.GetUID(), metaObj.GetNamespace(), watched.kind, metaObj.GetName())] = true
+				// Release nodes are live while Secrets of their release are
+				if release := helmReleaseOf(watched.kind, metaObj); release != "" {
+					live[graph.ReleaseNodeUID(metaObj.GetNamespace(), release)] = true
+				}
+			}
+		}
+	}
+
+	stale := 0
+	for _, node := range m.graph.GetAllNodes() {
+		if live[node.UID] || node.Cluster != "" {
+			continue
+		}
+		kind := node.Kind
+		if graph.IsReleaseNode(node) {
+			kind = "Secret"
+		}
+		synced, watched := covered[scope{kind, ""}]
+		if !watched {
+			synced, watched = covered[scope{kind, node.Namespace}]
+		}
+		if !watched || !synced {
+			continue
+		}
+
+		stale++
+		switch mode {
+		case PruneRemove:
+			klog.V(2).Infof("Pruning %s %s/%s (no longer in the cluster)", node.Kind, node.Namespace, node.Name)
+			m.graph.RemoveNode(node.UID)
+			metrics.PrunedNodes.WithLabelValues(node.Kind).Inc()
+		case PruneMark:
+			// Marked in place, so nodes updated or removed since they were listed are not reverted
+			_, marked := m.graph.UpdateNode(node.UID, func(node *graph.Node) bool {
+				if node.StatusReason == graph.ReasonNotInCluster {
+					return false
+				}
+				node.Status = graph.StatusUnknown
+				node.StatusReason = graph.ReasonNotInCluster
+				node.StatusMessage = "Resource no longer exists in the cluster"
+				return true
+			})
+			if marked {
+				metrics.PrunedNodes.WithLabelValues(node.Kind).Inc()
+			}
+		}
+	}
+
+	metrics.StaleNodes.Set(float64(stale))
+	if stale > 0 {
+		klog.Infof("Found %d node(s) no longer in the cluster (%s) in %v", stale, mode, time.Since(start))
+	}
+	return stale
+}
+
+// helmReleaseOf returns the release a Helm release Secret stores a revision of, from the labels
+// Helm sets on it, or ""
+func helmReleaseOf(kind string, obj metav1.Object) string {
+	if kind != "Secret" || obj.GetLabels()["owner"] != "helm" {
+		return ""
+	}
+	return obj.GetLabels()["name"]
+}
@@ -0,0 +1,129 @@
+package informers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// event is an informer notification waiting to be processed
+type event struct {
+	obj       interface{}
+	kind      string
+	eventType processors.EventType
+	key       string
+	queued    time.Time
+}
+
+// eventQueue decouples informer handlers from the processors. Delete events are processed
+// before adds and updates, so scale-down storms do not leave phantom resources in the graph
+// while a backlog of updates is worked off. A queued delete supersedes the pending add or
+// update of the same object, which keeps the reordering from resurrecting deleted objects.
+// Adds and updates are deduplicated per object: an update of an object that is still queued
+// replaces the queued state in place, so a burst of updates is processed once with the latest
+// state.
+type eventQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	deletes *list.List
+	others  *list.List
+	pending map[string]*list.Element // pending add/update by object key
+	closed  bool
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{
+		deletes: list.New(),
+		others:  list.New(),
+		pending: make(map[string]*list.Element),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// eventKey identifies the object of an event by kind and UID
+func eventKey(obj interface{}, kind string) string {
+	if metaObj, ok := obj.(metav1.Object); ok {
+		return kind + "/" + string(metaObj.GetUID())
+	}
+	return ""
+}
+
+// push queues an event
+func (q *eventQueue) push(obj interface{}, kind string, eventType processors.EventType) {
+	e := &event{
+		obj:       obj,
+		kind:      kind,
+		eventType: eventType,
+		key:       eventKey(obj, kind),
+		queued:    time.Now(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	if eventType == processors.EventDelete {
+		if element, exists := q.pending[e.key]; exists {
+			superseded := q.others.Remove(element).(*event)
+			metrics.EventQueueDepth.WithLabelValues(string(superseded.eventType)).Dec()
+			metrics.EventsSuperseded.Inc()
+			delete(q.pending, e.key)
+		}
+		q.deletes.PushBack(e)
+	} else {
+		if element, exists := q.pending[e.key]; exists {
+			// Keep the queued event's type and position, and so its lag, with the newer state
+			element.Value.(*event).obj = obj
+			metrics.EventsCoalesced.Inc()
+			return
+		}
+		element := q.others.PushBack(e)
+		if e.key != "" {
+			q.pending[e.key] = element
+		}
+	}
+	metrics.EventQueueDepth.WithLabelValues(string(eventType)).Inc()
+	q.cond.Signal()
+}
+
+// pop blocks until an event is available, returning deletes first. It returns false once the
+// queue is closed.
+func (q *eventQueue) pop() (*event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.deletes.Len() == 0 && q.others.Len() == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, false
+	}
+
+	var e *event
+	if front := q.deletes.Front(); front != nil {
+		e = q.deletes.Remove(front).(*event)
+	} else {
+		e = q.others.Remove(q.others.Front()).(*event)
+		if e.key != "" {
+			delete(q.pending, e.key)
+		}
+	}
+	metrics.EventQueueDepth.WithLabelValues(string(e.eventType)).Dec()
+	return e, true
+}
+
+// close wakes up the worker and drops the queued events
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
@@ -0,0 +1,123 @@
+package informers
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// Filters select the resources the informers watch
+type Filters struct {
+	// LabelSelector filters watched resources (empty = all resources)
+	LabelSelector string
+	// LabelSelectors refine LabelSelector by kind and exclude labeled objects
+	LabelSelectors LabelSelectors
+	// Namespaces restricts namespaced informers to these namespaces (empty = cluster-wide)
+	Namespaces []string
+	// Kinds selects the watched kinds
+	Kinds processors.KindFilter
+}
+
+// Reconfigure changes the filters of the running manager without restarting the process. The
+// informers are stopped and rebuilt with the new filters, and once their caches have synced
+// the graph is reconciled: nodes of kinds and namespaces no longer watched and nodes missing
+// from the new caches, such as those whose labels no longer match the selector, are removed. In
+// lazy namespace mode namespaces cannot be set.
+func (m *Manager) Reconfigure(filters Filters) error {
+	if err := filters.Kinds.Validate(); err != nil {
+		return err
+	}
+	if _, err := labels.Parse(filters.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label selector %q: %w", filters.LabelSelector, err)
+	}
+	if err := filters.LabelSelectors.Validate(); err != nil {
+		return err
+	}
+	if m.lazy.enabled && len(filters.Namespaces) > 0 {
+		return fmt.Errorf("namespaces cannot be set in lazy namespace mode")
+	}
+
+	m.filtersMu.Lock()
+	m.pendingFilters = &filters
+	m.filtersMu.Unlock()
+	select {
+	case m.reconfigure <- struct{}{}:
+	default:
+		// A reconfiguration is already pending and will pick up these filters
+	}
+	return nil
+}
+
+// applyFilters replaces the filters with the pending ones and reports whether they changed,
+// in which case the informers must be rebuilt
+func (m *Manager) applyFilters() bool {
+	m.filtersMu.Lock()
+	filters := m.pendingFilters
+	m.pendingFilters = nil
+	m.filtersMu.Unlock()
+	if filters == nil {
+		return false
+	}
+	kinds := filters.Kinds.EnabledKinds()
+	kindsChanged := !slices.Equal(kinds, m.kindFilter.EnabledKinds())
+	selectorsChanged := !reflect.DeepEqual(filters.LabelSelectors, m.selectors)
+	if filters.LabelSelector == m.labelSelector && slices.Equal(filters.Namespaces, m.namespaces) && !kindsChanged && !selectorsChanged {
+		return false
+	}
+
+	klog.Infof("Rebuilding informers with label selector %q and namespaces %v (were %q and %v)",
+		filters.LabelSelector, filters.Namespaces, m.labelSelector, m.namespaces)
+	if kindsChanged {
+		klog.Infof("Watched kinds: %s", strings.Join(kinds, ", "))
+	}
+	if selectorsChanged {
+		klog.Infof("Label selectors of kinds: %v, exclusions: %v", filters.LabelSelectors.Kinds, filters.LabelSelectors.Exclude)
+	}
+	// Lazily activated namespaces create their factories with the selector under the lock
+	m.lazy.mu.Lock()
+	m.labelSelector = filters.LabelSelector
+	m.selectors = filters.LabelSelectors
+	m.namespaces = slices.Clone(filters.Namespaces)
+	m.lazy.mu.Unlock()
+	m.kindFilter = filters.Kinds
+	m.processorOptions.Kinds = filters.Kinds
+	m.reconciling = true
+	return true
+}
+
+// reconcile removes the nodes that no longer match the filters once the rebuilt informers
+// have synced: nodes of supported kinds that are no longer watched, nodes of watched kinds in
+// namespaces that are no longer watched, and nodes of the watched kinds and namespaces missing
+// from the synced caches
+func (m *Manager) reconcile() {
+	removed := 0
+	supported := processors.SupportedKinds()
+	for _, node := range m.graph.GetAllNodes() {
+		if node.Cluster != "" || m.kindFilter.Enabled(node.Kind) || !slices.Contains(supported, node.Kind) {
+			continue
+		}
+		klog.V(2).Infof("Removing %s %s/%s (kind no longer watched)", node.Kind, node.Namespace, node.Name)
+		m.graph.RemoveNode(node.UID)
+		metrics.PrunedNodes.WithLabelValues(node.Kind).Inc()
+		removed++
+	}
+	if len(m.namespaces) > 0 {
+		for _, node := range m.graph.GetAllNodes() {
+			if node.Cluster != "" || node.Namespace == "" || !m.kindFilter.Enabled(node.Kind) || slices.Contains(m.namespaces, node.Namespace) {
+				continue
+			}
+			klog.V(2).Infof("Removing %s %s/%s (namespace no longer watched)", node.Kind, node.Namespace, node.Name)
+			m.graph.RemoveNode(node.UID)
+			metrics.PrunedNodes.WithLabelValues(node.Kind).Inc()
+			removed++
+		}
+	}
+	removed += m.pruneWith(PruneRemove)
+	klog.Infof("Reconciled the graph with the new filters: removed %d node(s)", removed)
+}
@@ -1,24 +1,30 @@
 package informers
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/ammarlakis/astrolabe/pkg/processors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 )
 
-func (m *Manager) register(kind string, informer cache.SharedIndexInformer) error {
-
+func (m *Manager) register(kind, namespace string, informer cache.SharedIndexInformer) error {
+	// Handlers keep feeding the queue of the run they were registered in
+	queue := m.queue
 	handler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			m.onEvent(obj, kind, processors.EventAdd)
+			m.onEvent(queue, obj, kind, processors.EventAdd)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			m.onEvent(newObj, kind, processors.EventUpdate)
+			m.onEvent(queue, newObj, kind, processors.EventUpdate)
 		},
 		DeleteFunc: func(obj interface{}) {
-			m.onEvent(obj, kind, processors.EventDelete)
+			m.onEvent(queue, obj, kind, processors.EventDelete)
 		},
 	}
 	_, err := informer.AddEventHandler(handler)
@@ -26,103 +32,149 @@ func (m *Manager) register(kind string, informer cache.SharedIndexInformer) erro
 		klog.Errorf("Failed to register %s informer: %v", kind, err)
 		return err
 	}
+	m.watched.add(kind, namespace, informer)
 	klog.V(2).Infof("Registered %s informer", kind)
 	return nil
 }
 
-// registerInformers registers all resource informers
-func (m *Manager) registerInformers() error {
-	type pair struct {
-		kind     string
-		informer cache.SharedIndexInformer
-	}
-	registers := []pair{
-		{
-			kind:     "Pod",
-			informer: m.factory.Core().V1().Pods().Informer(),
-		},
-		{
-			kind:     "Service",
-			informer: m.factory.Core().V1().Services().Informer(),
-		},
-		{
-			kind:     "ServiceAccount",
-			informer: m.factory.Core().V1().ServiceAccounts().Informer(),
-		},
-		{
-			kind:     "ConfigMap",
-			informer: m.factory.Core().V1().ConfigMaps().Informer(),
-		},
-		{
-			kind:     "Secret",
-			informer: m.factory.Core().V1().Secrets().Informer(),
-		},
-		{
-			kind:     "PersistentVolumeClaim",
-			informer: m.factory.Core().V1().PersistentVolumeClaims().Informer(),
-		},
-		{
-			kind:     "Namespace",
-			informer: m.factory.Core().V1().Namespaces().Informer(),
-		},
-		{
-			kind:     "PersistentVolume",
-			informer: m.factory.Core().V1().PersistentVolumes().Informer(),
-		},
-		{
-			kind:     "StorageClass",
-			informer: m.factory.Storage().V1().StorageClasses().Informer(),
-		},
-		{
-			kind:     "HorizontalPodAutoscaler",
-			informer: m.factory.Autoscaling().V1().HorizontalPodAutoscalers().Informer(),
-		},
-		{
-			kind:     "PodDisruptionBudget",
-			informer: m.factory.Policy().V1().PodDisruptionBudgets().Informer(),
-		},
-		{
-			kind:     "Deployment",
-			informer: m.factory.Apps().V1().Deployments().Informer(),
-		},
-		{
-			kind:     "StatefulSet",
-			informer: m.factory.Apps().V1().StatefulSets().Informer(),
-		},
-		{
-			kind:     "DaemonSet",
-			informer: m.factory.Apps().V1().DaemonSets().Informer(),
-		},
-		{
-			kind:     "ReplicaSet",
-			informer: m.factory.Apps().V1().ReplicaSets().Informer(),
-		},
-		{
-			kind:     "Job",
-			informer: m.factory.Batch().V1().Jobs().Informer(),
-		},
-		{
-			kind:     "CronJob",
-			informer: m.factory.Batch().V1().CronJobs().Informer(),
-		},
-		{
-			kind:     "Ingress",
-			informer: m.factory.Networking().V1().Ingresses().Informer(),
-		},
-		{
-			kind:     "EndpointSlice",
-			informer: m.factory.Discovery().V1().EndpointSlices().Informer(),
-		},
-	}
+// informerFactories creates the shared informer of each supported kind
+var informerFactories = map[string]func(f informers.SharedInformerFactory) cache.SharedIndexInformer{
+	"Pod": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Pods().Informer()
+	},
+	"Service": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Services().Informer()
+	},
+	"ServiceAccount": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ServiceAccounts().Informer()
+	},
+	"ConfigMap": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ConfigMaps().Informer()
+	},
+	"Secret": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Secrets().Informer()
+	},
+	"PersistentVolumeClaim": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().PersistentVolumeClaims().Informer()
+	},
+	"Namespace": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Namespaces().Informer()
+	},
+	"PersistentVolume": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().PersistentVolumes().Informer()
+	},
+	"Node": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Nodes().Informer()
+	},
+	"StorageClass": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Storage().V1().StorageClasses().Informer()
+	},
+	"CSIDriver": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Storage().V1().CSIDrivers().Informer()
+	},
+	"VolumeAttachment": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Storage().V1().VolumeAttachments().Informer()
+	},
+	"HorizontalPodAutoscaler": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Autoscaling().V2().HorizontalPodAutoscalers().Informer()
+	},
+	"PodDisruptionBudget": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Policy().V1().PodDisruptionBudgets().Informer()
+	},
+	"Deployment": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().Deployments().Informer()
+	},
+	"StatefulSet": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().StatefulSets().Informer()
+	},
+	"DaemonSet": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().DaemonSets().Informer()
+	},
+	"ReplicaSet": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().ReplicaSets().Informer()
+	},
+	"ControllerRevision": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().ControllerRevisions().Informer()
+	},
+	"Job": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().Jobs().Informer()
+	},
+	"CronJob": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().CronJobs().Informer()
+	},
+	"Ingress": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Networking().V1().Ingresses().Informer()
+	},
+	"EndpointSlice": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Discovery().V1().EndpointSlices().Informer()
+	},
+}
+
+// clusterScopedKinds maps cluster-scoped kinds to their API resource, used to check
+// list permissions when only specific namespaces are watched
+var clusterScopedKinds = map[string]schema.GroupResource{
+	"Namespace":        {Group: "", Resource: "namespaces"},
+	"PersistentVolume": {Group: "", Resource: "persistentvolumes"},
+	"Node":             {Group: "", Resource: "nodes"},
+	"StorageClass":     {Group: "storage.k8s.io", Resource: "storageclasses"},
+	"CSIDriver":        {Group: "storage.k8s.io", Resource: "csidrivers"},
+	"VolumeAttachment": {Group: "storage.k8s.io", Resource: "volumeattachments"},
+}
 
+// registerInformers registers the informers of all enabled kinds. When namespaces are
+// configured, namespaced kinds get one informer per namespace and cluster-scoped kinds
+// are only watched if the service account is allowed to list them. In lazy mode the
+// namespaced kinds are only recorded here and registered when a namespace is activated.
+func (m *Manager) registerInformers(ctx context.Context) error {
 	var errors []error
 
-	for _, register := range registers {
-		if err := m.register(register.kind, register.informer); err != nil {
-			klog.Errorf("Failed to register %s informer: %v", register.kind, err)
+	for _, kind := range m.kindFilter.EnabledKinds() {
+		if candidates, isDynamic := dynamicKinds[kind]; isDynamic {
+			if err := m.registerDynamic(kind, candidates); err != nil {
+				errors = append(errors, err)
+			}
+			continue
+		}
+
+		newInformer, exists := informerFactories[kind]
+		if !exists {
+			klog.Warningf("No informer available for kind %s", kind)
+			continue
+		}
+
+		namespaces := []string{""}
+		if len(m.namespaces) > 0 || m.lazy.enabled {
+			if resource, clusterScoped := clusterScopedKinds[kind]; clusterScoped {
+				if !m.canListClusterWide(ctx, resource) {
+					klog.Warningf("Not allowed to list %s cluster-wide, %s resources will not be tracked", resource.String(), kind)
+					continue
+				}
+			} else if m.lazy.enabled {
+				m.lazy.kinds = append(m.lazy.kinds, kind)
+				continue
+			} else {
+				namespaces = m.namespaces
+			}
+		}
+
+		for _, namespace := range namespaces {
+			if err := m.register(kind, namespace, newInformer(m.factoryFor(namespace, m.selectorFor(kind)))); err != nil {
+				klog.Errorf("Failed to register %s informer: %v", kind, err)
+				errors = append(errors, err)
+			}
+		}
+	}
+
+	for _, resource := range m.processorOptions.CustomResources {
+		if err := m.registerDynamic(resource.Kind, []schema.GroupVersionResource{resource.Resource}); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if m.lazy.enabled {
+		if err := m.registerNamespaceDiscovery(); err != nil {
 			errors = append(errors, err)
 		}
-		klog.V(2).Infof("Registered %s informer", register.kind)
 	}
 
 	if len(errors) > 0 {
@@ -130,3 +182,63 @@ func (m *Manager) registerInformers() error {
 	}
 	return nil
 }
+
+// registerDynamic registers the dynamic informers of a custom resource kind if its CRD is
+// installed, watching the first of the candidate versions served by the cluster
+func (m *Manager) registerDynamic(kind string, candidates []schema.GroupVersionResource) error {
+	if m.dynamicClient == nil {
+		klog.V(2).Infof("No dynamic client, not watching %s", kind)
+		return nil
+	}
+	var gvr schema.GroupVersionResource
+	for _, candidate := range candidates {
+		if m.resourceServed(candidate) {
+			gvr = candidate
+			break
+		}
+	}
+	if gvr.Empty() {
+		klog.Infof("%s is not served by the cluster, not watching %s", candidates[0].GroupResource().String(), kind)
+		return nil
+	}
+
+	if m.lazy.enabled {
+		m.lazy.dynamic[kind] = gvr
+		return nil
+	}
+
+	namespaces := []string{""}
+	if len(m.namespaces) > 0 {
+		namespaces = m.namespaces
+	}
+	for _, namespace := range namespaces {
+		informer := m.dynamicFactoryFor(namespace, m.selectorFor(kind)).ForResource(gvr).Informer()
+		if err := m.register(kind, namespace, informer); err != nil {
+			return err
+		}
+	}
+	m.dynamic.mu.Lock()
+	m.dynamic.watched[kind] = gvr
+	m.dynamic.mu.Unlock()
+	return nil
+}
+
+// canListClusterWide asks the API server whether we may list a resource across all namespaces
+func (m *Manager) canListClusterWide(ctx context.Context, resource schema.GroupResource) bool {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "list",
+				Group:    resource.Group,
+				Resource: resource.Resource,
+			},
+		},
+	}
+
+	result, err := m.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		klog.Warningf("Failed to check list permission for %s: %v", resource.String(), err)
+		return false
+	}
+	return result.Status.Allowed
+}
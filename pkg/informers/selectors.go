@@ -0,0 +1,91 @@
+package informers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// LabelSelectors refine the label selector of the informers by kind, and exclude labeled
+// objects whatever their kind. They are applied server-side: the informers of a kind list and
+// watch the objects matching the selector of the kind and none of the exclusions.
+type LabelSelectors struct {
+	// Kinds replace the label selector for these kinds, "" watching all their objects
+	Kinds map[string]string
+	// Exclude are selectors of the objects not watched, e.g. astrolabe.io/ignore=true. Each
+	// has a single requirement, which the informers negate.
+	Exclude []string
+}
+
+// Validate checks the syntax of the selectors
+func (s LabelSelectors) Validate() error {
+	for kind, selector := range s.Kinds {
+		if _, err := labels.Parse(selector); err != nil {
+			return fmt.Errorf("invalid label selector %q of kind %s: %w", selector, kind, err)
+		}
+	}
+	for _, selector := range s.Exclude {
+		if _, err := negateSelector(selector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// negateSelector returns the selector matching the objects an exclusion selector does not
+func negateSelector(selector string) (string, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid exclusion selector %q: %w", selector, err)
+	}
+	requirements, _ := parsed.Requirements()
+	if len(requirements) != 1 {
+		return "", fmt.Errorf("invalid exclusion selector %q: expected a single requirement, list several exclusions instead", selector)
+	}
+
+	requirement := requirements[0]
+	var operator selection.Operator
+	switch requirement.Operator() {
+	case selection.Equals, selection.DoubleEquals:
+		operator = selection.NotEquals
+	case selection.NotEquals:
+		operator = selection.Equals
+	case selection.In:
+		operator = selection.NotIn
+	case selection.NotIn:
+		operator = selection.In
+	case selection.Exists:
+		operator = selection.DoesNotExist
+	case selection.DoesNotExist:
+		operator = selection.Exists
+	default:
+		return "", fmt.Errorf("invalid exclusion selector %q: %s cannot be negated", selector, requirement.Operator())
+	}
+	negated, err := labels.NewRequirement(requirement.Key(), operator, requirement.Values().List())
+	if err != nil {
+		return "", fmt.Errorf("invalid exclusion selector %q: %w", selector, err)
+	}
+	return negated.String(), nil
+}
+
+// selectorFor returns the label selector of the informers of a kind: the selector of the kind,
+// or the label selector, with the negated exclusions
+func (m *Manager) selectorFor(kind string) string {
+	var requirements []string
+	selector := m.labelSelector
+	if kindSelector, exists := m.selectors.Kinds[kind]; exists {
+		selector = kindSelector
+	}
+	if selector != "" {
+		requirements = append(requirements, selector)
+	}
+	for _, exclusion := range m.selectors.Exclude {
+		// Invalid exclusions were rejected by Validate
+		if negated, err := negateSelector(exclusion); err == nil {
+			requirements = append(requirements, negated)
+		}
+	}
+	return strings.Join(requirements, ",")
+}
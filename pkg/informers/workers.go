@@ -0,0 +1,115 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	"github.com/ammarlakis/astrolabe/pkg/supervisor"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workerBuffer is the number of events each worker of a pool holds before the event worker
+// waits for it, leaving further updates of the objects to be coalesced in the queue
+const workerBuffer = 64
+
+// ParseWorkers parses the number of workers of kinds, e.g. "Pod=8,ReplicaSet=2"
+func ParseWorkers(value string) (map[string]int, error) {
+	workers := make(map[string]int)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kind, count, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid entry %q, expected <kind>=<workers>", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number of workers %q for kind %s", count, kind)
+		}
+		canonical := supportedKind(strings.TrimSpace(kind))
+		if canonical == "" {
+			return nil, fmt.Errorf("unsupported kind %q (supported: %s)", kind, strings.Join(processors.SupportedKinds(), ", "))
+		}
+		workers[canonical] = n
+	}
+	return workers, nil
+}
+
+// supportedKind returns the spelling of a supported kind, "" if it is not supported
+func supportedKind(kind string) string {
+	for _, supported := range processors.SupportedKinds() {
+		if strings.EqualFold(supported, kind) {
+			return supported
+		}
+	}
+	return ""
+}
+
+// workerPool processes the events of a kind on several workers, each with its own processor
+// registry so that audit records are attributed to the right event. Events are sharded by
+// object, so the events of an object are processed in order by the same worker; the release
+// Secrets of a Helm release are sharded by release, as its revisions are compared with each
+// other.
+type workerPool struct {
+	kind   string
+	shards []chan *event
+}
+
+// startPools starts the worker pools of the watched kinds configured with workers. The events
+// of the other kinds are processed by the event worker itself.
+func (m *Manager) startPools(ctx context.Context) map[string]*workerPool {
+	pools := make(map[string]*workerPool)
+	for kind, workers := range m.workers {
+		if workers == 0 || !m.kindFilter.Enabled(kind) {
+			continue
+		}
+		pool := &workerPool{kind: kind, shards: make([]chan *event, workers)}
+		for i := range pool.shards {
+			events := make(chan *event, workerBuffer)
+			pool.shards[i] = events
+			registry := processors.NewProcessorRegistry(m.processorGraph, m.processorOptions)
+			// A panicking processor only loses its event: the worker is restarted on the same shard
+			supervisor.Go(ctx, fmt.Sprintf("event-worker-%s-%d", kind, i), func(ctx context.Context) error {
+				for {
+					select {
+					case e := <-events:
+						metrics.EventWorkerBacklog.WithLabelValues(kind).Dec()
+						m.process(ctx, registry, e)
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			})
+		}
+		pools[kind] = pool
+	}
+	return pools
+}
+
+// dispatch hands an event to the worker of its object, waiting while the worker is busy. It
+// returns false if ctx is cancelled meanwhile.
+func (p *workerPool) dispatch(ctx context.Context, e *event) bool {
+	key := e.key
+	if metaObj, ok := e.obj.(metav1.Object); ok {
+		if release := helmReleaseOf(e.kind, metaObj); release != "" {
+			key = metaObj.GetNamespace() + "/" + release
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	metrics.EventWorkerBacklog.WithLabelValues(p.kind).Inc()
+	select {
+	case p.shards[hash.Sum32()%uint32(len(p.shards))] <- e:
+		return true
+	case <-ctx.Done():
+		metrics.EventWorkerBacklog.WithLabelValues(p.kind).Dec()
+		return false
+	}
+}
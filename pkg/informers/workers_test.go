@@ -0,0 +1,203 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/processors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestWorkerPools processes the events of related Deployments, ReplicaSets and Pods on several
+// workers per kind, sharing the enrichers and status rules. Run with -race.
+func TestWorkerPools(t *testing.T) {
+	const objects = 20
+	const updates = 5
+
+	// Range expressions change the state of a compiled JSONPath while it executes
+	enricher, err := processors.NewEnricher([]processors.ComputedField{
+		{Kind: "Pod", Name: "node", JSONPath: ".spec.nodeName"},
+		{Kind: "Pod", Name: "containers", JSONPath: `{range .spec.containers[*]}{.name}{" "}{end}`},
+		{Kind: "Deployment", Name: "replicas", JSONPath: ".spec.replicas"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := processors.NewStatusEngine([]processors.StatusRule{
+		{Kind: "Pod", JSONPath: `{range .status.containerStatuses[*]}{.state.waiting.reason}{end}`, Equals: "CrashLoopBackOff", Status: graph.StatusError, Reason: "CrashLoopBackOff"},
+		{Kind: "Pod", JSONPath: ".status.phase", Equals: "Failed", Status: graph.StatusError, Reason: "Failed"},
+		{Kind: "ReplicaSet", JSONPath: ".status.readyReplicas", Equals: "1", Status: graph.StatusReady, Reason: "Ready"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := graph.NewGraph()
+	m := NewManager(nil, g, Options{
+		Workers: map[string]int{"Deployment": 4, "ReplicaSet": 4, "Pod": 4},
+		Processors: processors.Options{
+			Status:    status,
+			Enrichers: []processors.NodeEnricher{enricher},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pools := m.startPools(ctx)
+
+	dispatch := func(kind string, obj metav1.Object, eventType processors.EventType) {
+		e := &event{
+			obj:       obj,
+			kind:      kind,
+			eventType: eventType,
+			key:       obj.GetNamespace() + "/" + obj.GetName(),
+			queued:    time.Now(),
+		}
+		if !pools[kind].dispatch(ctx, e) {
+			t.Errorf("failed to dispatch %s %s", kind, e.key)
+		}
+	}
+
+	var wg sync.WaitGroup
+	run := func(kind string, build func(i, update int) metav1.Object) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for update := 0; update < updates; update++ {
+				for i := 0; i < objects; i++ {
+					eventType := processors.EventUpdate
+					if update == 0 {
+						eventType = processors.EventAdd
+					}
+					dispatch(kind, build(i, update), eventType)
+				}
+			}
+		}()
+	}
+	run("Deployment", func(i, update int) metav1.Object { return testDeployment(i, update) })
+	run("ReplicaSet", func(i, update int) metav1.Object { return testReplicaSet(i, update) })
+	run("Pod", func(i, update int) metav1.Object { return testPod(i, update) })
+	wg.Wait()
+
+	waitFor(t, "ownership edges", func() bool {
+		for i := 0; i < objects; i++ {
+			if !hasEdge(g, testUID("rs", i), testUID("pod", i)) || !hasEdge(g, testUID("deploy", i), testUID("rs", i)) {
+				return false
+			}
+		}
+		return true
+	})
+
+	for i := 0; i < objects; i += 2 {
+		dispatch("Pod", testPod(i, updates), processors.EventDelete)
+	}
+	waitFor(t, "deleted Pods", func() bool {
+		for i := 0; i < objects; i++ {
+			if _, exists := g.GetNode(testUID("pod", i)); exists == (i%2 == 0) {
+				return false
+			}
+		}
+		return true
+	})
+
+	pod, _ := g.GetNode(testUID("pod", 1))
+	if pod.Status != graph.StatusError || pod.Metadata == nil || pod.Metadata.Computed["node"] != "node-1" || pod.Metadata.Computed["containers"] != "app sidecar " {
+		t.Errorf("Pod not evaluated by the status rules and enrichers: %s %+v", pod.Status, pod.Metadata)
+	}
+}
+
+func testUID(prefix string, i int) types.UID {
+	return types.UID(fmt.Sprintf("%s-%d", prefix, i))
+}
+
+func testMeta(prefix string, i, update int) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            fmt.Sprintf("app-%d-%s", i, prefix),
+		Namespace:       "default",
+		UID:             testUID(prefix, i),
+		ResourceVersion: fmt.Sprint(update + 1),
+		Labels:          map[string]string{"app": fmt.Sprintf("app-%d", i)},
+	}
+}
+
+func ownedBy(meta metav1.ObjectMeta, kind, prefix string, i int) metav1.ObjectMeta {
+	controller := true
+	meta.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       kind,
+		Name:       fmt.Sprintf("app-%d-%s", i, prefix),
+		UID:        testUID(prefix, i),
+		Controller: &controller,
+	}}
+	return meta
+}
+
+func testDeployment(i, update int) *appsv1.Deployment {
+	replicas := int32(update + 1)
+	return &appsv1.Deployment{
+		ObjectMeta: testMeta("deploy", i, update),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": fmt.Sprintf("app-%d", i)}},
+		},
+	}
+}
+
+func testReplicaSet(i, update int) *appsv1.ReplicaSet {
+	replicas := int32(1)
+	return &appsv1.ReplicaSet{
+		ObjectMeta: ownedBy(testMeta("rs", i, update), "Deployment", "deploy", i),
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": fmt.Sprintf("app-%d", i)}},
+		},
+		Status: appsv1.ReplicaSetStatus{Replicas: 1, ReadyReplicas: int32(update % 2)},
+	}
+}
+
+func testPod(i, update int) *corev1.Pod {
+	phase := corev1.PodRunning
+	if i%2 == 1 {
+		phase = corev1.PodFailed
+	}
+	return &corev1.Pod{
+		ObjectMeta: ownedBy(testMeta("pod", i, update), "ReplicaSet", "rs", i),
+		Spec: corev1.PodSpec{
+			NodeName:   fmt.Sprintf("node-%d", i),
+			Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "sidecar", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			},
+		},
+	}
+}
+
+func hasEdge(g *graph.Graph, fromUID, toUID types.UID) bool {
+	for _, edge := range g.OutgoingEdges(fromUID) {
+		if edge.ToUID == toUID {
+			return true
+		}
+	}
+	return false
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,281 @@
+package logsampler
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// Options configures pod log sampling
+type Options struct {
+	TailLines       int64         // Number of log lines requested from the container
+	MaxExcerptBytes int           // Maximum size of the excerpt attached to the node
+	Rate            float64       // Log requests per second
+	Burst           int           // Log requests allowed in a burst
+	QueueSize       int           // Pending sample requests before new ones are dropped
+	RequestTimeout  time.Duration // Timeout of a single logs API call
+}
+
+// DefaultOptions returns the default sampling options
+func DefaultOptions() Options {
+	return Options{
+		TailLines:       20,
+		MaxExcerptBytes: 2048,
+		Rate:            1,
+		Burst:           5,
+		QueueSize:       100,
+		RequestTimeout:  10 * time.Second,
+	}
+}
+
+// sampleKey identifies one crash of a container; a new restart count means a new sample
+type sampleKey struct {
+	container    string
+	restartCount int32
+}
+
+type request struct {
+	uid       types.UID
+	namespace string
+	name      string
+	key       sampleKey
+	previous  bool
+}
+
+type sample struct {
+	key     sampleKey
+	excerpt *graph.LogExcerpt
+}
+
+// Sampler fetches the last log lines of failing Pods and attaches them to the graph nodes
+type Sampler struct {
+	client  kubernetes.Interface
+	graph   graph.GraphInterface
+	opts    Options
+	limiter *rate.Limiter
+	queue   chan request
+
+	mu       sync.Mutex
+	samples  map[types.UID]sample
+	inflight map[types.UID]sampleKey
+}
+
+// NewSampler creates a new pod log sampler
+func NewSampler(client kubernetes.Interface, g graph.GraphInterface, opts Options) *Sampler {
+	defaults := DefaultOptions()
+	if opts.TailLines <= 0 {
+		opts.TailLines = defaults.TailLines
+	}
+	if opts.MaxExcerptBytes <= 0 {
+		opts.MaxExcerptBytes = defaults.MaxExcerptBytes
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = defaults.Rate
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = defaults.Burst
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaults.QueueSize
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = defaults.RequestTimeout
+	}
+
+	return &Sampler{
+		client:   client,
+		graph:    g,
+		opts:     opts,
+		limiter:  rate.NewLimiter(rate.Limit(opts.Rate), opts.Burst),
+		queue:    make(chan request, opts.QueueSize),
+		samples:  make(map[types.UID]sample),
+		inflight: make(map[types.UID]sampleKey),
+	}
+}
+
+// Enrich attaches the last sampled excerpt to failing Pod nodes and schedules a new
+// sample when a container crashed again since
+func (s *Sampler) Enrich(node *graph.Node, obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || node.Kind != "Pod" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if node.Status != graph.StatusError {
+		// Recovered: forget the old crash
+		delete(s.samples, node.UID)
+		return
+	}
+
+	key, previous, found := failingContainer(pod)
+	if !found {
+		return
+	}
+
+	current, sampled := s.samples[node.UID]
+	if sampled {
+		setExcerpt(node, current.excerpt)
+		if current.key == key {
+			return
+		}
+	}
+
+	if pending, ok := s.inflight[node.UID]; ok && pending == key {
+		return
+	}
+
+	select {
+	case s.queue <- request{uid: node.UID, namespace: pod.Namespace, name: pod.Name, key: key, previous: previous}:
+		s.inflight[node.UID] = key
+	default:
+		klog.V(2).Infof("Log sampling queue full, skipping Pod %s/%s", pod.Namespace, pod.Name)
+	}
+}
+
+// Start processes sample requests until the context is cancelled
+func (s *Sampler) Start(ctx context.Context) {
+	pruneTicker := time.NewTicker(time.Minute)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pruneTicker.C:
+			s.prune()
+		case req := <-s.queue:
+			if err := s.limiter.Wait(ctx); err != nil {
+				return
+			}
+			s.sample(ctx, req)
+		}
+	}
+}
+
+func (s *Sampler) sample(ctx context.Context, req request) {
+	defer func() {
+		s.mu.Lock()
+		if s.inflight[req.uid] == req.key {
+			delete(s.inflight, req.uid)
+		}
+		s.mu.Unlock()
+	}()
+
+	reqCtx, cancel := context.WithTimeout(ctx, s.opts.RequestTimeout)
+	defer cancel()
+
+	limitBytes := int64(s.opts.MaxExcerptBytes) * 4
+	raw, err := s.client.CoreV1().Pods(req.namespace).GetLogs(req.name, &corev1.PodLogOptions{
+		Container:  req.key.container,
+		Previous:   req.previous,
+		TailLines:  &s.opts.TailLines,
+		LimitBytes: &limitBytes,
+	}).DoRaw(reqCtx)
+	if err != nil {
+		klog.V(2).Infof("Failed to sample logs of Pod %s/%s container %s: %v", req.namespace, req.name, req.key.container, err)
+		return
+	}
+
+	excerpt := &graph.LogExcerpt{
+		Container: req.key.container,
+		Previous:  req.previous,
+		Lines:     sanitize(string(raw), s.opts.MaxExcerptBytes),
+		SampledAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.samples[req.uid] = sample{key: req.key, excerpt: excerpt}
+	s.mu.Unlock()
+
+	// Attach to the current node right away instead of waiting for the next Pod update
+	_, attached := s.graph.UpdateNode(req.uid, func(node *graph.Node) bool {
+		if node.Status != graph.StatusError {
+			return false
+		}
+		setExcerpt(node, excerpt)
+		return true
+	})
+	if !attached {
+		return
+	}
+
+	klog.V(2).Infof("Sampled %d bytes of logs from Pod %s/%s container %s", len(excerpt.Lines), req.namespace, req.name, req.key.container)
+}
+
+// prune forgets samples of Pods that are no longer in the graph
+func (s *Sampler) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for uid := range s.samples {
+		if _, exists := s.graph.GetNode(uid); !exists {
+			delete(s.samples, uid)
+		}
+	}
+}
+
+func setExcerpt(node *graph.Node, excerpt *graph.LogExcerpt) {
+	if node.Metadata == nil {
+		node.Metadata = &graph.ResourceMetadata{}
+	}
+	node.Metadata.LogExcerpt = excerpt
+}
+
+// failingContainer returns the first crashed container and whether its logs come from the previous instance
+func failingContainer(pod *corev1.Pod) (sampleKey, bool, bool) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			return sampleKey{container: cs.Name, restartCount: cs.RestartCount}, true, true
+		}
+		if cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0 {
+			return sampleKey{container: cs.Name, restartCount: cs.RestartCount}, false, true
+		}
+	}
+	return sampleKey{}, false, false
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+	secretLike = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|authorization)["']?\s*[:=]\s*["']?)[^\s"',;]+`)
+	bearer     = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+)
+
+// sanitize strips terminal escapes and control characters, redacts credential-looking
+// values and keeps at most maxBytes from the end of the log
+func sanitize(logs string, maxBytes int) string {
+	logs = ansiEscape.ReplaceAllString(logs, "")
+	logs = secretLike.ReplaceAllString(logs, "${1}[REDACTED]")
+	logs = bearer.ReplaceAllString(logs, "${1}[REDACTED]")
+	logs = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, logs)
+	logs = strings.TrimRight(logs, "\n")
+
+	if len(logs) > maxBytes {
+		logs = logs[len(logs)-maxBytes:]
+		// Drop the partial first line
+		if i := strings.IndexByte(logs, '\n'); i >= 0 {
+			logs = logs[i+1:]
+		}
+	}
+	return logs
+}
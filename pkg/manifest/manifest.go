@@ -0,0 +1,102 @@
+// Package manifest fetches the live objects of tracked resources from the Kubernetes API, so
+// clients can show full specs without credentials of their own. Objects are sanitized before
+// they are served: managed fields are dropped and the payload of Secrets is redacted.
+package manifest
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// Redacted replaces the values of Secret keys
+const Redacted = "<redacted>"
+
+// lastAppliedAnnotation holds the object as last applied by kubectl, Secret data included
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Ref identifies the object to fetch
+type Ref struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// Fetcher reads objects of any kind through the dynamic client, mapping kinds to resources
+// with cached discovery data
+type Fetcher struct {
+	client dynamic.Interface
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+}
+
+// NewFetcher creates a fetcher
+func NewFetcher(client dynamic.Interface, discoveryClient discovery.DiscoveryInterface) *Fetcher {
+	return &Fetcher{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}
+}
+
+// Fetch gets the live object and sanitizes it. Errors of the Kubernetes API are returned as
+// is, so callers can tell a missing object from missing permissions.
+func (f *Fetcher) Fetch(ctx context.Context, ref Ref) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion %q: %w", ref.APIVersion, err)
+	}
+
+	mapping, err := f.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if meta.IsNoMatchError(err) {
+		// The CRD may have been installed after discovery was cached
+		f.mapper.Reset()
+		mapping, err = f.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resource dynamic.ResourceInterface = f.client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resource = f.client.Resource(mapping.Resource).Namespace(ref.Namespace)
+	}
+	obj, err := resource.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	Sanitize(obj)
+	return obj, nil
+}
+
+// Sanitize drops the managed fields of an object and, for Secrets, replaces the value of
+// every key with Redacted and drops the last applied configuration
+func Sanitize(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+
+	if obj.GetKind() != "Secret" || obj.GetAPIVersion() != "v1" {
+		return
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, found, _ := unstructured.NestedMap(obj.Object, field)
+		if !found {
+			continue
+		}
+		for key := range values {
+			values[key] = Redacted
+		}
+		unstructured.SetNestedMap(obj.Object, values, field)
+	}
+	if annotations := obj.GetAnnotations(); annotations[lastAppliedAnnotation] != "" {
+		delete(annotations, lastAppliedAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
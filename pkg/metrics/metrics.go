@@ -0,0 +1,333 @@
+// Package metrics holds the Prometheus metrics exported by Astrolabe on /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "astrolabe"
+
+var (
+	// EventQueueDepth is the number of queued informer events by event type
+	EventQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_queue_depth",
+		Help:      "Number of informer events waiting to be processed, by event type.",
+	}, []string{"event"})
+
+	// EventProcessingLag observes the time between receiving and processing an informer event
+	EventProcessingLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_processing_lag_seconds",
+		Help:      "Time informer events spent queued before processing, by event type.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"event"})
+
+	// EventsSuperseded counts queued adds and updates dropped because the object was deleted
+	EventsSuperseded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_superseded_total",
+		Help:      "Queued add and update events dropped because a delete of the same object arrived.",
+	})
+
+	// EventsCoalesced counts adds and updates merged into a queued event of the same object
+	EventsCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_coalesced_total",
+		Help:      "Add and update events merged into an event of the same object that was still queued.",
+	})
+
+	// EventRateLimitDelay observes how long the event worker waited for the event rate limiter
+	EventRateLimitDelay = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_rate_limit_delay_seconds",
+		Help:      "Time the event worker waited for the event rate limiter before processing an event.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+
+	// EventWorkerBacklog is the number of events handed to the worker pool of a kind and not
+	// processed yet
+	EventWorkerBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_worker_backlog",
+		Help:      "Number of informer events dispatched to the worker pool of a kind and waiting to be processed.",
+	}, []string{"kind"})
+
+	// DependentQueueDepth is the number of dependents of changed resources waiting to be
+	// reprocessed
+	DependentQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dependent_queue_depth",
+		Help:      "Number of resources waiting to be reprocessed because a resource they reference changed.",
+	})
+
+	// DependentReprocesses counts the resources reprocessed because a resource they reference
+	// changed
+	DependentReprocesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dependent_reprocesses_total",
+		Help:      "Resources reprocessed because a resource they reference changed or was deleted, by kind.",
+	}, []string{"kind"})
+
+	// TimeToReady observes how long resources took from creation to first being Ready
+	TimeToReady = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "time_to_ready_seconds",
+		Help:      "Time resources took from their creation to first being Ready, by kind.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"kind"})
+
+	// TimelineEvents counts the events recorded in the release timeline by type
+	TimelineEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "timeline_events_total",
+		Help:      "Events recorded in the release timeline, by event type.",
+	}, []string{"type"})
+
+	// WatchedNamespaces is the number of namespaces whose informers were started in lazy mode
+	WatchedNamespaces = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "lazy_watched_namespaces",
+		Help:      "Number of namespaces whose informers were started in lazy namespace mode.",
+	})
+
+	// ConsistencyIssues is the number of issues of each type found by the last consistency check
+	ConsistencyIssues = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consistency_issues",
+		Help:      "Number of inconsistencies of each type found by the last consistency check.",
+	}, []string{"type"})
+
+	// ConsistencyRepairs counts repaired inconsistencies by type
+	ConsistencyRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consistency_repairs_total",
+		Help:      "Number of inconsistencies repaired by the consistency checker.",
+	}, []string{"type"})
+
+	// ConsistencyLastRun is the time of the last consistency check
+	ConsistencyLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consistency_last_run_timestamp_seconds",
+		Help:      "Unix time of the last consistency check.",
+	})
+
+	// ConsistencyDuration observes how long consistency checks take
+	ConsistencyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "consistency_check_duration_seconds",
+		Help:      "Duration of consistency checks.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+
+	// StaleNodes is the number of nodes the last prune pass found missing from the informer caches
+	StaleNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stale_nodes",
+		Help:      "Number of graph nodes whose object was missing from the informer caches in the last prune pass.",
+	})
+
+	// PrunedNodes counts stale nodes removed or marked by kind
+	PrunedNodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pruned_nodes_total",
+		Help:      "Number of graph nodes removed or marked because their object no longer exists, by kind.",
+	}, []string{"kind"})
+
+	// SubsystemUp reports whether each supervised subsystem is running
+	SubsystemUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subsystem_up",
+		Help:      "Whether a supervised subsystem is running (1) or waiting to be restarted (0).",
+	}, []string{"subsystem"})
+
+	// SubsystemRestarts counts restarts of supervised subsystems by reason (panic, error or exited)
+	SubsystemRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subsystem_restarts_total",
+		Help:      "Number of restarts of supervised subsystems, by subsystem and reason.",
+	}, []string{"subsystem", "reason"})
+
+	// DiscoveryRefreshes counts the refreshes of the API discovery cache by result
+	DiscoveryRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discovery_refreshes_total",
+		Help:      "Number of refreshes of the cached API discovery data, by result (success, partial or error).",
+	}, []string{"result"})
+
+	// FederationConnected reports whether the watch stream of each federated cluster is up
+	FederationConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "federation_connected",
+		Help:      "Whether the graph of a federated cluster is being received (1) or not (0).",
+	}, []string{"cluster"})
+
+	// FederationUpdates counts the graph updates received from each federated cluster
+	FederationUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "federation_updates_total",
+		Help:      "Number of graph updates received from federated clusters, by cluster.",
+	}, []string{"cluster"})
+
+	// GraphExportSyncs counts the passes mirroring the graph into the external graph database
+	GraphExportSyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graph_export_syncs_total",
+		Help:      "Number of passes mirroring graph changes into the external graph database, by result (success or error).",
+	}, []string{"result"})
+
+	// GraphExportRecords counts the nodes and edges written to the external graph database
+	GraphExportRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graph_export_records_total",
+		Help:      "Number of nodes and edges written to the external graph database, by operation (upsert or delete).",
+	}, []string{"operation"})
+
+	// PersistenceLogEntries counts the entries of the Redis change log
+	PersistenceLogEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "persistence_log_entries_total",
+		Help:      "Number of Redis change log entries, by operation (appended, compacted or replayed).",
+	}, []string{"operation"})
+
+	// PersistenceWrites counts async Redis writes that did not go straight through the queue
+	PersistenceWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "persistence_writes_total",
+		Help:      "Number of async persistence writes that waited for the full queue (delayed), were spilled to the overflow buffer (spilled), were retried after a failed batch (retried) or were lost (dropped), by result.",
+	}, []string{"result"})
+
+	// PersistenceOverflow is the number of writes waiting in the overflow buffer and retry list
+	PersistenceOverflow = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "persistence_overflow_writes",
+		Help:      "Number of async persistence writes waiting in the overflow buffer or retry list.",
+	})
+
+	// PersistenceOrphanedKeys is the number of orphaned Redis keys found by the last storage
+	// compaction that are deleted by the next one
+	PersistenceOrphanedKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "persistence_orphaned_keys",
+		Help:      "Number of Redis keys, or index set members, of nodes and edges no longer in the graph found by the last storage compaction, by type (node, edge or index).",
+	}, []string{"type"})
+
+	// PersistenceReclaimedKeys counts the orphaned Redis keys deleted by storage compaction
+	PersistenceReclaimedKeys = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "persistence_reclaimed_keys_total",
+		Help:      "Number of orphaned Redis keys, or index set members, deleted by storage compaction, by type (node, edge or index).",
+	}, []string{"type"})
+
+	// AuditEntries counts the graph mutations of the audit log
+	AuditEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_entries_total",
+		Help:      "Number of graph mutations written to the audit log, by result (recorded or failed).",
+	}, []string{"result"})
+
+	// AnalysisDuration observes how long each background analysis takes
+	AnalysisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "analysis_duration_seconds",
+		Help:      "Duration of background graph analyses, by analysis.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"analysis"})
+
+	// AnalysisFindings is the number of findings of the last run of each analysis
+	AnalysisFindings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "analysis_findings",
+		Help:      "Number of findings of the last run of each graph analysis.",
+	}, []string{"analysis"})
+
+	// JobsPruned counts the finished Jobs removed by the Job pruner
+	JobsPruned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_pruned_total",
+		Help:      "Number of finished Jobs of CronJobs removed from the graph, with their Pods, beyond the kept history.",
+	})
+
+	// OrphanedUninstalls is the number of uninstalled releases with resources left
+	OrphanedUninstalls = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orphaned_uninstalls",
+		Help:      "Number of Helm releases uninstalled for longer than the grace period whose resources remain.",
+	})
+
+	// PodAnomalies is the number of Pods flagged for restarting or flapping
+	PodAnomalies = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pod_anomalies",
+		Help:      "Number of Pods that restarted, or changed status, at least as often as the thresholds of their namespace within the anomaly window.",
+	})
+
+	// PodAnomaliesDetected counts the Pods flagged for restarting or flapping
+	PodAnomaliesDetected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pod_anomalies_detected_total",
+		Help:      "Number of times a Pod was flagged for restarting or flapping.",
+	})
+
+	// Tombstones is the number of deleted resources kept for the tombstone retention window
+	Tombstones = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tombstones",
+		Help:      "Number of deleted resources kept as tombstones.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		EventQueueDepth,
+		EventProcessingLag,
+		EventsSuperseded,
+		EventsCoalesced,
+		EventRateLimitDelay,
+		EventWorkerBacklog,
+		DependentQueueDepth,
+		DependentReprocesses,
+		TimelineEvents,
+		TimeToReady,
+		WatchedNamespaces,
+		ConsistencyIssues,
+		ConsistencyRepairs,
+		ConsistencyLastRun,
+		ConsistencyDuration,
+		StaleNodes,
+		PrunedNodes,
+		SubsystemUp,
+		SubsystemRestarts,
+		DiscoveryRefreshes,
+		FederationConnected,
+		FederationUpdates,
+		GraphExportSyncs,
+		GraphExportRecords,
+		PersistenceLogEntries,
+		PersistenceWrites,
+		PersistenceOverflow,
+		PersistenceOrphanedKeys,
+		PersistenceReclaimedKeys,
+		AuditEntries,
+		AnalysisDuration,
+		AnalysisFindings,
+		JobsPruned,
+		OrphanedUninstalls,
+		PodAnomalies,
+		PodAnomaliesDetected,
+		Tombstones,
+	)
+}
+
+// Register registers collectors computed outside this package, such as the per-release series
+func Register(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(collectors...)
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
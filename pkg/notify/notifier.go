@@ -0,0 +1,324 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// EventType is the kind of status transition being notified
+type EventType string
+
+const (
+	EventError     EventType = "error"
+	EventRecovered EventType = "recovered"
+)
+
+// Payload formats
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Webhook is a notification target with its routing rules
+type Webhook struct {
+	Name string
+	URL  string
+	// Format is "json" (default) or "slack" for Slack-compatible incoming webhooks
+	Format string
+	// Releases are release name patterns (path.Match syntax) routed to this webhook (empty = all)
+	Releases []string
+	// Namespaces limits the webhook to these namespaces (empty = all)
+	Namespaces []string
+}
+
+// Options configures the notifier
+type Options struct {
+	Webhooks []Webhook
+	// Debounce is how long a transition must persist before it is sent
+	Debounce time.Duration
+	// Timeout of a single webhook request
+	Timeout time.Duration
+}
+
+// Event is the JSON payload posted to webhooks
+type Event struct {
+	Type           EventType            `json:"type"`
+	Release        string               `json:"release"`
+	Chart          string               `json:"chart,omitempty"`
+	Namespace      string               `json:"namespace"`
+	Kind           string               `json:"kind"`
+	Name           string               `json:"name"`
+	UID            types.UID            `json:"uid"`
+	Status         graph.ResourceStatus `json:"status"`
+	PreviousStatus graph.ResourceStatus `json:"previousStatus"`
+	Reason         string               `json:"reason,omitempty"`
+	Message        string               `json:"message,omitempty"`
+	Timestamp      time.Time            `json:"timestamp"`
+}
+
+// resourceState tracks what was last sent for a resource and the transition waiting for its debounce
+type resourceState struct {
+	notifiedError bool
+	pending       *Event
+	timer         *time.Timer
+}
+
+// Notifier posts release resource status transitions to webhooks
+type Notifier struct {
+	opts   Options
+	client *http.Client
+	queue  chan Event
+
+	mu        sync.Mutex
+	resources map[types.UID]*resourceState
+}
+
+// NewNotifier validates the webhooks and creates a notifier
+func NewNotifier(opts Options) (*Notifier, error) {
+	for i, webhook := range opts.Webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook %q has no url", webhook.Name)
+		}
+		switch webhook.Format {
+		case "":
+			opts.Webhooks[i].Format = FormatJSON
+		case FormatJSON, FormatSlack:
+		default:
+			return nil, fmt.Errorf("webhook %q has unknown format %q (expected %s or %s)", webhook.Name, webhook.Format, FormatJSON, FormatSlack)
+		}
+		for _, pattern := range webhook.Releases {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("webhook %q has invalid release pattern %q: %w", webhook.Name, pattern, err)
+			}
+		}
+	}
+	if opts.Debounce < 0 {
+		opts.Debounce = 0
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	return &Notifier{
+		opts:      opts,
+		client:    &http.Client{Timeout: opts.Timeout},
+		queue:     make(chan Event, 1000),
+		resources: make(map[types.UID]*resourceState),
+	}, nil
+}
+
+// NodeChanged records Error transitions of resources that belong to a release
+func (n *Notifier) NodeChanged(old, updated *graph.Node) {
+	if updated == nil {
+		// Deleted resources don't recover; drop anything pending
+		n.forget(old.UID)
+		return
+	}
+	if updated.HelmRelease == "" {
+		return
+	}
+
+	previous := graph.StatusUnknown
+	if old != nil {
+		previous = old.Status
+	}
+	if previous == updated.Status {
+		return
+	}
+
+	var eventType EventType
+	switch {
+	case updated.Status == graph.StatusError:
+		eventType = EventError
+	case updated.Status == graph.StatusReady && n.awaitingRecovery(updated.UID):
+		// Whatever the statuses in between, e.g. Pending during a rollout
+		eventType = EventRecovered
+	default:
+		return
+	}
+
+	n.record(Event{
+		Type:           eventType,
+		Release:        updated.HelmRelease,
+		Chart:          updated.HelmChart,
+		Namespace:      updated.Namespace,
+		Kind:           updated.Kind,
+		Name:           updated.Name,
+		UID:            updated.UID,
+		Status:         updated.Status,
+		PreviousStatus: previous,
+		Reason:         updated.StatusReason,
+		Message:        updated.StatusMessage,
+		Timestamp:      time.Now(),
+	})
+}
+
+// awaitingRecovery reports whether an error of a resource was notified, or is about to be
+func (n *Notifier) awaitingRecovery(uid types.UID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	state, exists := n.resources[uid]
+	return exists && (state.notifiedError || state.pending != nil)
+}
+
+// record schedules an event after the debounce period. A transition that is reverted
+// within the period cancels the pending one instead of sending both.
+func (n *Notifier) record(event Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	state, exists := n.resources[event.UID]
+	if !exists {
+		state = &resourceState{}
+		n.resources[event.UID] = state
+	}
+
+	isError := event.Type == EventError
+	if state.notifiedError == isError {
+		// Back to the last notified state: nothing to tell
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		state.pending = nil
+		if !isError {
+			delete(n.resources, event.UID)
+		}
+		return
+	}
+
+	state.pending = &event
+	if state.timer != nil {
+		return
+	}
+	uid := event.UID
+	state.timer = time.AfterFunc(n.opts.Debounce, func() { n.fire(uid) })
+}
+
+func (n *Notifier) fire(uid types.UID) {
+	n.mu.Lock()
+	state, exists := n.resources[uid]
+	if !exists || state.pending == nil {
+		n.mu.Unlock()
+		return
+	}
+	event := *state.pending
+	state.pending = nil
+	state.timer = nil
+	state.notifiedError = event.Type == EventError
+	if !state.notifiedError {
+		delete(n.resources, uid)
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- event:
+	default:
+		klog.Warningf("Notification queue full, dropping %s event for %s/%s", event.Type, event.Kind, event.Name)
+	}
+}
+
+func (n *Notifier) forget(uid types.UID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if state, exists := n.resources[uid]; exists {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(n.resources, uid)
+	}
+}
+
+// Start delivers notifications until the context is cancelled
+func (n *Notifier) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			for _, webhook := range n.opts.Webhooks {
+				if !webhook.matches(event) {
+					continue
+				}
+				if err := n.send(ctx, webhook, event); err != nil {
+					klog.Errorf("Failed to notify webhook %s: %v", webhook.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func (w Webhook) matches(event Event) bool {
+	if len(w.Namespaces) > 0 && !contains(w.Namespaces, event.Namespace) {
+		return false
+	}
+	if len(w.Releases) == 0 {
+		return true
+	}
+	for _, pattern := range w.Releases {
+		if matched, _ := path.Match(pattern, event.Release); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) send(ctx context.Context, webhook Webhook, event Event) error {
+	var payload interface{} = event
+	if webhook.Format == FormatSlack {
+		payload = map[string]string{"text": slackText(event)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	klog.V(2).Infof("Notified %s of %s event for %s/%s/%s", webhook.Name, event.Type, event.Namespace, event.Kind, event.Name)
+	return nil
+}
+
+func slackText(event Event) string {
+	switch event.Type {
+	case EventError:
+		return fmt.Sprintf(":red_circle: *%s* in release *%s* (%s) failed: %s", event.Kind+"/"+event.Name, event.Release, event.Namespace, event.Message)
+	default:
+		return fmt.Sprintf(":large_green_circle: *%s* in release *%s* (%s) recovered: %s", event.Kind+"/"+event.Name, event.Release, event.Namespace, event.Message)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
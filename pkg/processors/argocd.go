@@ -0,0 +1,120 @@
+package processors
+
+import (
+	"fmt"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// ArgoApplicationProcessor processes ArgoCD Application resources
+type ArgoApplicationProcessor struct {
+	*BaseProcessor
+}
+
+func NewArgoApplicationProcessor(g graph.GraphInterface) *ArgoApplicationProcessor {
+	return &ArgoApplicationProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *ArgoApplicationProcessor) Process(obj interface{}, eventType EventType) error {
+	app, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected Application, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(app, "Application")
+	}
+
+	node := graph.NewNodeFromObject(app, "Application", app.GetAPIVersion())
+
+	health, _, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+	sync, _, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
+	node.Status = argoHealthStatus(health)
+	node.StatusMessage = fmt.Sprintf("Sync: %s, Health: %s", valueOr(sync, "Unknown"), valueOr(health, "Unknown"))
+	node.StatusReason = argoStatusReason(health, sync)
+
+	gitOps := &graph.GitOpsStatus{SyncStatus: sync, HealthStatus: health}
+	gitOps.RepoURL, _, _ = unstructured.NestedString(app.Object, "spec", "source", "repoURL")
+	gitOps.Path, _, _ = unstructured.NestedString(app.Object, "spec", "source", "path")
+	gitOps.Revision, _, _ = unstructured.NestedString(app.Object, "status", "sync", "revision")
+	gitOps.DestinationNamespace, _, _ = unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+	node.Metadata = &graph.ResourceMetadata{GitOps: gitOps}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, app.GetOwnerReferences())
+	p.createManagesEdges(node, app)
+
+	return nil
+}
+
+// createManagesEdges links the Application to the resources it deploys, as reported in
+// status.resources and as found through the tracking label/annotation, and drops edges
+// to resources it no longer manages
+func (p *ArgoApplicationProcessor) createManagesEdges(node *graph.Node, app *unstructured.Unstructured) {
+	managed := make(map[types.UID]bool)
+
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+	for _, item := range resources {
+		resource, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(resource, "kind")
+		namespace, _, _ := unstructured.NestedString(resource, "namespace")
+		name, _, _ := unstructured.NestedString(resource, "name")
+		if kind == "" || name == "" {
+			continue
+		}
+
+		if target := p.findNodeByNamespaceKindName(namespace, kind, name); target != nil {
+			managed[target.UID] = true
+		}
+		p.createEdgeOrPending(node.UID, namespace, kind, name, graph.EdgeManages)
+	}
+
+	// Resources tracked by label or annotation (covers kinds missing from status.resources)
+	for _, target := range p.graph.GetNodesByGroup(graph.GrouperArgoCD, app.GetName()) {
+		if target.UID == node.UID {
+			continue
+		}
+		managed[target.UID] = true
+		p.createEdgeIfNodeExists(node.UID, target.UID, graph.EdgeManages)
+	}
+
+	p.pruneEdges(node.UID, graph.EdgeManages, managed)
+
+	klog.V(3).Infof("Application %s/%s manages %d tracked resource(s)", app.GetNamespace(), app.GetName(), len(managed))
+}
+
+// argoHealthStatus maps ArgoCD health to a resource status
+func argoHealthStatus(health string) graph.ResourceStatus {
+	switch health {
+	case "Healthy":
+		return graph.StatusReady
+	case "Progressing", "Suspended":
+		return graph.StatusPending
+	case "Degraded", "Missing":
+		return graph.StatusError
+	default:
+		return graph.StatusUnknown
+	}
+}
+
+// argoStatusReason uses the health status as reason, reporting healthy applications that are
+// out of sync as OutOfSync
+func argoStatusReason(health, sync string) string {
+	if health == "Healthy" && sync == "OutOfSync" {
+		return graph.ReasonOutOfSync
+	}
+	return valueOr(health, "Unknown")
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
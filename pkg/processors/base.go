@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -12,7 +13,10 @@ import (
 
 // BaseProcessor provides common functionality for all processors
 type BaseProcessor struct {
-	graph graph.GraphInterface
+	graph     graph.GraphInterface
+	status    *StatusEngine
+	enrichers []NodeEnricher
+	timeline  *timeline.Timeline
 }
 
 // NewBaseProcessor creates a new base processor
@@ -20,6 +24,69 @@ func NewBaseProcessor(g graph.GraphInterface) *BaseProcessor {
 	return &BaseProcessor{graph: g}
 }
 
+// baseProcessor gives the registry access to the shared base of a processor
+type baseProcessor interface {
+	base() *BaseProcessor
+}
+
+func (p *BaseProcessor) base() *BaseProcessor {
+	return p
+}
+
+// addNode runs the generic status and enrichment steps on a node and adds it to the graph
+func (p *BaseProcessor) addNode(node *graph.Node, obj interface{}) {
+	recordOwners(node, obj)
+	p.status.apply(node, obj)
+	for _, enricher := range p.enrichers {
+		enricher.Enrich(node, obj)
+	}
+	p.keepRollbackMarker(node)
+	p.graph.AddNode(node)
+	p.linkRelease(node)
+}
+
+// linkRelease links a resource, or a release Secret, of a Helm release to the node of the
+// release when it is in the graph, and drops the link to a release it no longer belongs to
+func (p *BaseProcessor) linkRelease(node *graph.Node) {
+	var release types.UID
+	switch {
+	case node.Metadata != nil && node.Metadata.HelmRevision != nil:
+		release = graph.ReleaseNodeUID(node.Metadata.HelmRevision.Namespace, node.Metadata.HelmRevision.Release)
+	case node.HelmRelease != "":
+		release = graph.ReleaseNodeUID(graph.ReleaseNamespace(node), node.HelmRelease)
+	}
+
+	keep := make(map[types.UID]bool, 1)
+	if release != "" {
+		p.createEdgeIfNodeExists(node.UID, release, graph.EdgePartOfRelease)
+		keep[release] = true
+	}
+	p.pruneEdges(node.UID, graph.EdgePartOfRelease, keep)
+}
+
+// recordOwners keeps the owner references of an object on its node, so its owners are known
+// even when they are not in the graph, e.g. filtered out by the label selector
+func recordOwners(node *graph.Node, obj interface{}) {
+	metaObj, ok := obj.(v1.Object)
+	if !ok || len(metaObj.GetOwnerReferences()) == 0 {
+		return
+	}
+
+	if node.Metadata == nil {
+		node.Metadata = &graph.ResourceMetadata{}
+	}
+	node.Metadata.Owners = make([]graph.OwnerReference, 0, len(metaObj.GetOwnerReferences()))
+	for _, owner := range metaObj.GetOwnerReferences() {
+		node.Metadata.Owners = append(node.Metadata.Owners, graph.OwnerReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			UID:        owner.UID,
+			Controller: owner.Controller != nil && *owner.Controller,
+		})
+	}
+}
+
 // handleDelete removes a node from the graph
 func (p *BaseProcessor) handleDelete(obj interface{}, kind string) error {
 	metaObj, ok := obj.(v1.Object)
@@ -27,7 +94,7 @@ func (p *BaseProcessor) handleDelete(obj interface{}, kind string) error {
 		return fmt.Errorf("object does not implement metav1.Object")
 	}
 
-	uid := metaObj.GetUID()
+	uid := graph.ObjectID(metaObj.GetUID(), metaObj.GetNamespace(), kind, metaObj.GetName())
 	klog.V(3).Infof("Deleting %s: %s/%s (UID: %s)", kind, metaObj.GetNamespace(), metaObj.GetName(), uid)
 
 	p.graph.RemoveNode(uid)
@@ -37,30 +104,49 @@ func (p *BaseProcessor) handleDelete(obj interface{}, kind string) error {
 // createOwnershipEdges creates edges from owner references
 func (p *BaseProcessor) createOwnershipEdges(node *graph.Node, ownerRefs []v1.OwnerReference) {
 	for _, owner := range ownerRefs {
+		// Owners are namespaced with their children or cluster-scoped
+		ownerID := graph.ObjectID(owner.UID, node.Namespace, owner.Kind, owner.Name)
+
 		// Try to find the owner node in the graph
-		if ownerNode, exists := p.graph.GetNode(owner.UID); exists {
+		if ownerNode, exists := p.graph.GetNode(ownerID); exists {
 			edge := &graph.Edge{
 				Type:    graph.EdgeOwnership,
-				FromUID: owner.UID,
+				FromUID: ownerID,
 				ToUID:   node.UID,
 			}
 			p.graph.AddEdge(edge)
 			klog.V(4).Infof("Created ownership edge: %s/%s -> %s/%s",
 				ownerNode.Kind, ownerNode.Name, node.Kind, node.Name)
 		} else {
-			klog.V(4).Infof("Owner not found in graph yet: %s/%s (UID: %s)",
+			klog.V(4).Infof("Owner not found in graph yet: %s/%s (UID: %s), deferring ownership edge",
 				owner.Kind, owner.Name, owner.UID)
+			refKey := graph.RefKey{
+				GVK:       schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind),
+				Namespace: node.Namespace,
+				Name:      owner.Name,
+			}
+			p.graph.AddPendingOwnerEdge(node.UID, ownerID, refKey)
+		}
+	}
+}
+
+// pruneEdges removes the outgoing edges of a type whose target is not in keep
+func (p *BaseProcessor) pruneEdges(fromUID types.UID, edgeType graph.EdgeType, keep map[types.UID]bool) {
+	var stale []types.UID
+	for _, edge := range p.graph.OutgoingEdges(fromUID) {
+		if edge.Type == edgeType && !keep[edge.ToUID] {
+			stale = append(stale, edge.ToUID)
 		}
 	}
+	for _, toUID := range stale {
+		p.graph.RemoveEdge(fromUID, toUID)
+	}
 }
 
 // findNodeByNamespaceKindName finds a node by namespace, kind, and name
 func (p *BaseProcessor) findNodeByNamespaceKindName(namespace, kind, name string) *graph.Node {
-	nodes := p.graph.GetNodesByNamespaceKind(namespace, kind)
-	for _, node := range nodes {
-		if node.Name == name {
-			return node
-		}
+	if node, exists := p.graph.GetNodeByRef(namespace, kind, name); exists {
+		return node
 	}
 	return nil
 }
@@ -96,22 +182,34 @@ func matchesSelector(labels, selector map[string]string) bool {
 
 // createEdgeIfNodeExists creates an edge if the target node exists
 func (p *BaseProcessor) createEdgeIfNodeExists(fromUID, toUID types.UID, edgeType graph.EdgeType) {
+	p.createEdgeWithMetadata(fromUID, toUID, edgeType, nil)
+}
+
+// createEdgeWithMetadata creates an edge carrying details of the relationship (ports, mount
+// paths, ...) if both nodes exist
+func (p *BaseProcessor) createEdgeWithMetadata(fromUID, toUID types.UID, edgeType graph.EdgeType, metadata map[string]string) {
 	edge := &graph.Edge{
-		Type:    edgeType,
-		FromUID: fromUID,
-		ToUID:   toUID,
+		Type:     edgeType,
+		FromUID:  fromUID,
+		ToUID:    toUID,
+		Metadata: metadata,
 	}
 	p.graph.AddEdge(edge)
 }
 
 // createEdgeOrPending creates an edge if the target exists, otherwise adds it to pending edges
 func (p *BaseProcessor) createEdgeOrPending(fromUID types.UID, targetNamespace, targetKind, targetName string, edgeType graph.EdgeType) {
+	p.createEdgeOrPendingWithMetadata(fromUID, targetNamespace, targetKind, targetName, edgeType, nil)
+}
+
+// createEdgeOrPendingWithMetadata is createEdgeOrPending for edges carrying metadata
+func (p *BaseProcessor) createEdgeOrPendingWithMetadata(fromUID types.UID, targetNamespace, targetKind, targetName string, edgeType graph.EdgeType, metadata map[string]string) {
 	// Try to find the target node
 	targetNode := p.findNodeByNamespaceKindName(targetNamespace, targetKind, targetName)
 	
 	if targetNode != nil {
 		// Target exists, create edge immediately
-		p.createEdgeIfNodeExists(fromUID, targetNode.UID, edgeType)
+		p.createEdgeWithMetadata(fromUID, targetNode.UID, edgeType, metadata)
 	} else {
 		// Target doesn't exist yet, add to pending edges
 		refKey := graph.RefKey{
@@ -122,7 +220,7 @@ func (p *BaseProcessor) createEdgeOrPending(fromUID types.UID, targetNamespace,
 			Namespace: targetNamespace,
 			Name:      targetName,
 		}
-		p.graph.AddPendingEdge(fromUID, refKey, edgeType)
+		p.graph.AddPendingEdge(fromUID, refKey, edgeType, metadata)
 	}
 }
 
@@ -0,0 +1,270 @@
+package processors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// cert-manager resources describe how TLS certificates are issued. A Certificate is linked to
+// the Secret its key pair is stored in and to its Issuer or ClusterIssuer, and owns the
+// CertificateRequests of its issuances. The expiry of a Certificate is evaluated on every
+// event and resync, so one whose renewal is failing is flagged before it expires.
+
+const (
+	// certificateExpiryWarning is how long before expiry a Certificate is flagged at the latest
+	certificateExpiryWarning = 7 * 24 * time.Hour
+	// certificateRenewalGrace is how long a renewal may take past the renewal time before it
+	// is overdue
+	certificateRenewalGrace = time.Hour
+)
+
+// CertificateProcessor processes cert-manager Certificate resources
+type CertificateProcessor struct {
+	*BaseProcessor
+}
+
+func NewCertificateProcessor(g graph.GraphInterface) *CertificateProcessor {
+	return &CertificateProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *CertificateProcessor) Process(obj interface{}, eventType EventType) error {
+	cert, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected Certificate, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(cert, "Certificate")
+	}
+
+	info := &graph.CertificateInfo{}
+	info.SecretName, _, _ = unstructured.NestedString(cert.Object, "spec", "secretName")
+	info.DNSNames, _, _ = unstructured.NestedStringSlice(cert.Object, "spec", "dnsNames")
+	info.NotAfter = nestedTime(cert.Object, "status", "notAfter")
+	info.RenewalTime = nestedTime(cert.Object, "status", "renewalTime")
+	info.FailedIssuanceAttempts, _, _ = unstructured.NestedInt64(cert.Object, "status", "failedIssuanceAttempts")
+	issuerKind, issuerName, linkIssuer := certManagerIssuerRef(cert)
+	if issuerName != "" {
+		info.Issuer = issuerKind + "/" + issuerName
+	}
+
+	node := graph.NewNodeFromObject(cert, "Certificate", cert.GetAPIVersion())
+	node.Status, node.StatusReason, node.StatusMessage = certificateStatus(cert, info, time.Now())
+	node.Metadata = &graph.ResourceMetadata{Certificate: info}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, cert.GetOwnerReferences())
+
+	stored := make(map[types.UID]bool)
+	if info.SecretName != "" {
+		p.createEdgeOrPending(node.UID, cert.GetNamespace(), "Secret", info.SecretName, graph.EdgeCertificateSecret)
+		if secret := p.findNodeByNamespaceKindName(cert.GetNamespace(), "Secret", info.SecretName); secret != nil {
+			stored[secret.UID] = true
+		}
+	}
+	p.pruneEdges(node.UID, graph.EdgeCertificateSecret, stored)
+	linkCertManagerIssuer(p.BaseProcessor, node, cert.GetNamespace(), issuerKind, issuerName, linkIssuer)
+
+	return nil
+}
+
+// linkCertManagerIssuer creates the issued-by edge of a Certificate or CertificateRequest and
+// drops the one to a previous issuer
+func linkCertManagerIssuer(p *BaseProcessor, node *graph.Node, namespace, kind, name string, link bool) {
+	issuers := make(map[types.UID]bool)
+	if link {
+		if kind == "ClusterIssuer" {
+			namespace = ""
+		}
+		p.createEdgeOrPending(node.UID, namespace, kind, name, graph.EdgeIssuedBy)
+		if issuer := p.findNodeByNamespaceKindName(namespace, kind, name); issuer != nil {
+			issuers[issuer.UID] = true
+		}
+	}
+	p.pruneEdges(node.UID, graph.EdgeIssuedBy, issuers)
+}
+
+// certManagerIssuerRef returns the issuer of a Certificate or CertificateRequest and whether
+// it is a cert-manager Issuer or ClusterIssuer, which are tracked; external issuers are not
+func certManagerIssuerRef(obj *unstructured.Unstructured) (string, string, bool) {
+	name, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "name")
+	kind, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "kind")
+	group, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "group")
+	kind = valueOr(kind, "Issuer")
+	tracked := (group == "" || group == "cert-manager.io") && (kind == "Issuer" || kind == "ClusterIssuer")
+	return kind, name, name != "" && tracked
+}
+
+// certificateStatus evaluates the Ready condition and the expiry of a Certificate. A valid
+// certificate whose renewal is failing or overdue, or that expires soon, is Pending so it
+// stands out before it expires; an expired or not ready one is an Error.
+func certificateStatus(cert *unstructured.Unstructured, info *graph.CertificateInfo, now time.Time) (graph.ResourceStatus, string, string) {
+	ready := condition(cert.Object, "Ready")
+	issuing := condition(cert.Object, "Issuing")
+
+	if info.NotAfter != nil && !now.Before(*info.NotAfter) {
+		return graph.StatusError, graph.ReasonCertificateExpired, fmt.Sprintf("Expired at %s", info.NotAfter.Format(time.RFC3339))
+	}
+	if ready == nil || conditionStatus(ready) != "True" {
+		if issuing != nil && conditionStatus(issuing) == "True" {
+			_, message := conditionDetails(issuing, graph.ReasonCertificateIssuing)
+			return graph.StatusPending, graph.ReasonCertificateIssuing, message
+		}
+		if ready == nil {
+			return graph.StatusPending, graph.ReasonCertificateIssuing, "Waiting for the certificate to be issued"
+		}
+		reason, message := conditionDetails(ready, graph.ReasonCertificateNotReady)
+		return graph.StatusError, reason, message
+	}
+
+	if info.NotAfter == nil {
+		_, message := conditionDetails(ready, graph.ReasonCertificateValid)
+		return graph.StatusReady, graph.ReasonCertificateValid, message
+	}
+	remaining := info.NotAfter.Sub(now)
+	expiry := "expires in " + approximateDuration(remaining)
+	switch {
+	case info.FailedIssuanceAttempts > 0:
+		return graph.StatusPending, graph.ReasonCertificateRenewalFailing,
+			fmt.Sprintf("Renewal failed %d time(s), %s", info.FailedIssuanceAttempts, expiry)
+	case info.RenewalTime != nil && now.After(info.RenewalTime.Add(certificateRenewalGrace)):
+		return graph.StatusPending, graph.ReasonCertificateRenewalOverdue,
+			fmt.Sprintf("Renewal due since %s, %s", info.RenewalTime.Format(time.RFC3339), expiry)
+	case remaining < certificateExpiryWarning && remaining < certificateLifetime(cert, info)/3:
+		return graph.StatusPending, graph.ReasonCertificateExpiringSoon, "Certificate " + expiry
+	default:
+		return graph.StatusReady, graph.ReasonCertificateValid, "Certificate " + expiry
+	}
+}
+
+// certificateLifetime returns the validity period of the issued certificate, assuming the
+// default of 90 days when its start is unknown
+func certificateLifetime(cert *unstructured.Unstructured, info *graph.CertificateInfo) time.Duration {
+	if notBefore := nestedTime(cert.Object, "status", "notBefore"); notBefore != nil && info.NotAfter != nil {
+		return info.NotAfter.Sub(*notBefore)
+	}
+	return 90 * 24 * time.Hour
+}
+
+// approximateDuration formats a duration in whole days, hours or minutes
+func approximateDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%d minutes", int(d/time.Minute))
+	}
+}
+
+// nestedTime parses an RFC 3339 timestamp field, or returns nil
+func nestedTime(obj map[string]interface{}, fields ...string) *time.Time {
+	value, found, _ := unstructured.NestedString(obj, fields...)
+	if !found {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// CertificateRequestProcessor processes cert-manager CertificateRequest resources
+type CertificateRequestProcessor struct {
+	*BaseProcessor
+}
+
+func NewCertificateRequestProcessor(g graph.GraphInterface) *CertificateRequestProcessor {
+	return &CertificateRequestProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *CertificateRequestProcessor) Process(obj interface{}, eventType EventType) error {
+	request, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected CertificateRequest, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(request, "CertificateRequest")
+	}
+
+	node := graph.NewNodeFromObject(request, "CertificateRequest", request.GetAPIVersion())
+	node.Status, node.StatusReason, node.StatusMessage = certificateRequestStatus(request)
+	issuerKind, issuerName, linkIssuer := certManagerIssuerRef(request)
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, request.GetOwnerReferences())
+	linkCertManagerIssuer(p.BaseProcessor, node, request.GetNamespace(), issuerKind, issuerName, linkIssuer)
+
+	return nil
+}
+
+// certificateRequestStatus maps the Denied and Ready conditions of a CertificateRequest.
+// Ready is False with the Pending reason while the issuer works on it.
+func certificateRequestStatus(request *unstructured.Unstructured) (graph.ResourceStatus, string, string) {
+	if denied := condition(request.Object, "Denied"); denied != nil && conditionStatus(denied) == "True" {
+		reason, message := conditionDetails(denied, "Denied")
+		return graph.StatusError, reason, message
+	}
+	ready := condition(request.Object, "Ready")
+	if ready == nil {
+		return graph.StatusPending, "Pending", "Waiting for approval"
+	}
+	reason, message := conditionDetails(ready, "Pending")
+	switch {
+	case conditionStatus(ready) == "True":
+		return graph.StatusReady, reason, message
+	case reason == "Pending":
+		return graph.StatusPending, reason, message
+	default:
+		return graph.StatusError, reason, message
+	}
+}
+
+// CertManagerIssuerProcessor processes cert-manager Issuer and ClusterIssuer resources
+type CertManagerIssuerProcessor struct {
+	*BaseProcessor
+	kind string
+}
+
+func NewCertManagerIssuerProcessor(g graph.GraphInterface, kind string) *CertManagerIssuerProcessor {
+	return &CertManagerIssuerProcessor{BaseProcessor: NewBaseProcessor(g), kind: kind}
+}
+
+func (p *CertManagerIssuerProcessor) Process(obj interface{}, eventType EventType) error {
+	issuer, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected %s, got %T", p.kind, obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(issuer, p.kind)
+	}
+
+	node := graph.NewNodeFromObject(issuer, p.kind, issuer.GetAPIVersion())
+	if ready := condition(issuer.Object, "Ready"); ready != nil {
+		reason, message := conditionDetails(ready, graph.ReasonCurrent)
+		switch conditionStatus(ready) {
+		case "True":
+			node.Status = graph.StatusReady
+		case "False":
+			node.Status = graph.StatusError
+		default:
+			node.Status = graph.StatusPending
+		}
+		node.StatusReason, node.StatusMessage = reason, message
+	} else {
+		node.Status, node.StatusReason, node.StatusMessage = graph.StatusPending, graph.ReasonNotReconciled, "Not reconciled yet"
+	}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, issuer.GetOwnerReferences())
+
+	return nil
+}
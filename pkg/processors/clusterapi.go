@@ -0,0 +1,150 @@
+package processors
+
+import (
+	"fmt"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Cluster API resources describe the machines a cluster runs on. Machines are linked to the
+// Node they provision through status.nodeRef, which connects them to the Pods scheduled on
+// that Node when Astrolabe watches the cluster managing its own machines. MachineSets and
+// MachineDeployments are linked to their Machines through owner references.
+
+// MachineProcessor processes Cluster API Machine resources
+type MachineProcessor struct {
+	*BaseProcessor
+}
+
+func NewMachineProcessor(g graph.GraphInterface) *MachineProcessor {
+	return &MachineProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *MachineProcessor) Process(obj interface{}, eventType EventType) error {
+	machine, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected Machine, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(machine, "Machine")
+	}
+
+	node := graph.NewNodeFromObject(machine, "Machine", machine.GetAPIVersion())
+	node.Status, node.StatusReason, node.StatusMessage = machineStatus(machine)
+
+	nodeName, _, _ := unstructured.NestedString(machine.Object, "status", "nodeRef", "name")
+	node.Metadata = &graph.ResourceMetadata{NodeName: nodeName}
+	node.Metadata.ClusterName, _, _ = unstructured.NestedString(machine.Object, "spec", "clusterName")
+	node.Metadata.Version, _, _ = unstructured.NestedString(machine.Object, "spec", "version")
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, machine.GetOwnerReferences())
+
+	if nodeName != "" {
+		p.createEdgeOrPending(node.UID, "", "Node", nodeName, graph.EdgeProvisions)
+	}
+
+	return nil
+}
+
+// machineStatus maps the phase of a Machine, preferring the failure reason reported by
+// the infrastructure provider
+func machineStatus(machine *unstructured.Unstructured) (graph.ResourceStatus, string, string) {
+	phase, _, _ := unstructured.NestedString(machine.Object, "status", "phase")
+	failureReason, _, _ := unstructured.NestedString(machine.Object, "status", "failureReason")
+	failureMessage, _, _ := unstructured.NestedString(machine.Object, "status", "failureMessage")
+	if failureReason != "" || failureMessage != "" {
+		return graph.StatusError, valueOr(failureReason, graph.ReasonMachineFailed), valueOr(failureMessage, failureReason)
+	}
+
+	switch phase {
+	case "Running":
+		return graph.StatusReady, graph.ReasonMachineRunning, "Running"
+	case "Pending", "Provisioning", "Provisioned":
+		return graph.StatusPending, graph.ReasonMachineProvisioning, phase
+	case "Deleting", "Deleted":
+		return graph.StatusPending, graph.ReasonMachineDeleting, phase
+	case "Failed":
+		return graph.StatusError, graph.ReasonMachineFailed, "Failed"
+	default:
+		return graph.StatusUnknown, graph.ReasonUnknownPhase, fmt.Sprintf("Phase: %s", valueOr(phase, "Unknown"))
+	}
+}
+
+// MachineSetProcessor processes Cluster API MachineSet resources
+type MachineSetProcessor struct {
+	*BaseProcessor
+}
+
+func NewMachineSetProcessor(g graph.GraphInterface) *MachineSetProcessor {
+	return &MachineSetProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *MachineSetProcessor) Process(obj interface{}, eventType EventType) error {
+	return processMachineGroup(p.BaseProcessor, obj, eventType, "MachineSet")
+}
+
+// MachineDeploymentProcessor processes Cluster API MachineDeployment resources
+type MachineDeploymentProcessor struct {
+	*BaseProcessor
+}
+
+func NewMachineDeploymentProcessor(g graph.GraphInterface) *MachineDeploymentProcessor {
+	return &MachineDeploymentProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *MachineDeploymentProcessor) Process(obj interface{}, eventType EventType) error {
+	return processMachineGroup(p.BaseProcessor, obj, eventType, "MachineDeployment")
+}
+
+// processMachineGroup processes MachineSets and MachineDeployments, whose status is derived
+// from their ready replicas like the one of workloads
+func processMachineGroup(p *BaseProcessor, obj interface{}, eventType EventType, kind string) error {
+	group, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected %s, got %T", kind, obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(group, kind)
+	}
+
+	node := graph.NewNodeFromObject(group, kind, group.GetAPIVersion())
+
+	desired, found, _ := unstructured.NestedInt64(group.Object, "spec", "replicas")
+	if !found {
+		desired = 1
+	}
+	current, _, _ := unstructured.NestedInt64(group.Object, "status", "replicas")
+	ready, _, _ := unstructured.NestedInt64(group.Object, "status", "readyReplicas")
+	available, _, _ := unstructured.NestedInt64(group.Object, "status", "availableReplicas")
+
+	switch {
+	case desired == 0 && ready == 0:
+		node.Status, node.StatusReason, node.StatusMessage = graph.StatusReady, graph.ReasonScaledToZero, "Scaled to zero (0/0)"
+	case ready == desired:
+		node.Status, node.StatusReason, node.StatusMessage = graph.StatusReady, graph.ReasonReplicasReady, fmt.Sprintf("All machines ready (%d/%d)", ready, desired)
+	case ready == 0:
+		node.Status, node.StatusReason, node.StatusMessage = graph.StatusError, graph.ReasonReplicasUnavailable, fmt.Sprintf("No machines ready (0/%d)", desired)
+	default:
+		node.Status, node.StatusReason, node.StatusMessage = graph.StatusPending, graph.ReasonReplicasPartiallyReady, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
+	}
+
+	node.Metadata = &graph.ResourceMetadata{
+		Replicas: &graph.ReplicaInfo{
+			Desired:   int32(desired),
+			Current:   int32(current),
+			Ready:     int32(ready),
+			Available: int32(available),
+		},
+	}
+	node.Metadata.ClusterName, _, _ = unstructured.NestedString(group.Object, "spec", "clusterName")
+	node.Metadata.Version, _, _ = unstructured.NestedString(group.Object, "spec", "template", "spec", "version")
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, group.GetOwnerReferences())
+
+	return nil
+}
@@ -2,6 +2,7 @@ package processors
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ammarlakis/astrolabe/pkg/graph"
 	corev1 "k8s.io/api/core/v1"
@@ -11,12 +12,27 @@ import (
 // PodProcessor processes Pod resources
 type PodProcessor struct {
 	*BaseProcessor
+	// prunedJobs are the Jobs the Job pruner removed, whose Pods are not added back (optional)
+	prunedJobs PrunedJobs
 }
 
 func NewPodProcessor(g graph.GraphInterface) *PodProcessor {
 	return &PodProcessor{BaseProcessor: NewBaseProcessor(g)}
 }
 
+// ofPrunedJob reports whether a Pod is owned by a Job the Job pruner removed
+func (p *PodProcessor) ofPrunedJob(pod *corev1.Pod) bool {
+	if p.prunedJobs == nil {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "Job" && p.prunedJobs.Pruned(graph.ObjectID(owner.UID, pod.Namespace, "Job", owner.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *PodProcessor) Process(obj interface{}, eventType EventType) error {
 	pod, ok := obj.(*corev1.Pod)
 	if !ok {
@@ -26,15 +42,21 @@ func (p *PodProcessor) Process(obj interface{}, eventType EventType) error {
 	if eventType == EventDelete {
 		return p.handleDelete(pod, "Pod")
 	}
+	if p.ofPrunedJob(pod) {
+		klog.V(4).Infof("Skipping Pod of pruned Job: %s/%s", pod.Namespace, pod.Name)
+		return nil
+	}
 
 	node := graph.NewNodeFromObject(pod, "Pod", "v1")
-	node.Status, node.StatusMessage = p.getPodStatus(pod)
+	node.Status, node.StatusReason, node.StatusMessage = p.getPodStatus(pod)
 
 	// Set metadata
 	metadata := &graph.ResourceMetadata{
 		NodeName:     pod.Spec.NodeName,
 		RestartCount: p.getTotalRestartCount(pod),
+		Containers:   p.getContainers(pod),
 	}
+	metadata.Zone, metadata.Region = p.nodeTopology(pod.Spec.NodeName)
 
 	if len(pod.Spec.Containers) > 0 {
 		metadata.Image = pod.Spec.Containers[0].Image
@@ -43,15 +65,17 @@ func (p *PodProcessor) Process(obj interface{}, eventType EventType) error {
 	node.Metadata = metadata
 
 	// Add node to graph
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 
 	// Create ownership edges
 	p.createOwnershipEdges(node, pod.GetOwnerReferences())
 
 	// Create edges to PVCs
+	mounts := volumeMounts(&pod.Spec)
 	for _, volume := range pod.Spec.Volumes {
 		if volume.PersistentVolumeClaim != nil {
-			p.createEdgeOrPending(node.UID, pod.Namespace, "PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName, graph.EdgePodVolume)
+			p.createEdgeOrPendingWithMetadata(node.UID, pod.Namespace, "PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName,
+				graph.EdgePodVolume, pvcEdgeMetadata(volume, mounts[volume.Name]))
 		}
 	}
 
@@ -63,34 +87,55 @@ func (p *PodProcessor) Process(obj interface{}, eventType EventType) error {
 		p.createEdgeOrPending(node.UID, pod.Namespace, "ServiceAccount", pod.Spec.ServiceAccountName, graph.EdgeServiceAccount)
 	}
 
+	// Create edge to the Node the Pod is scheduled on
+	if pod.Spec.NodeName != "" {
+		p.createEdgeOrPending(node.UID, "", "Node", pod.Spec.NodeName, graph.EdgeScheduledOn)
+	}
+
 	return nil
 }
 
-func (p *PodProcessor) getPodStatus(pod *corev1.Pod) (graph.ResourceStatus, string) {
+// getPodStatus returns the status, reason and message of a Pod. Container reasons reported by
+// the kubelet (e.g. ImagePullBackOff, CrashLoopBackOff, OOMKilled) are used as reasons.
+func (p *PodProcessor) getPodStatus(pod *corev1.Pod) (graph.ResourceStatus, string, string) {
 	switch pod.Status.Phase {
 	case corev1.PodRunning:
 		// Check container statuses
 		for _, cs := range pod.Status.ContainerStatuses {
 			if !cs.Ready {
 				if cs.State.Waiting != nil {
-					return graph.StatusPending, fmt.Sprintf("Container not ready: %s", cs.State.Waiting.Reason)
+					return graph.StatusPending, valueOr(cs.State.Waiting.Reason, graph.ReasonContainerNotReady),
+						fmt.Sprintf("Container not ready: %s", cs.State.Waiting.Reason)
 				}
 				if cs.State.Terminated != nil {
-					return graph.StatusError, fmt.Sprintf("Container terminated: %s", cs.State.Terminated.Reason)
+					return graph.StatusError, valueOr(cs.State.Terminated.Reason, graph.ReasonContainerTerminated),
+						fmt.Sprintf("Container terminated: %s", cs.State.Terminated.Reason)
 				}
 			}
 		}
-		return graph.StatusReady, "Pod is running"
+		return graph.StatusReady, graph.ReasonPodRunning, "Pod is running"
 	case corev1.PodPending:
-		return graph.StatusPending, "Pod is pending"
+		// Surface why the Pod cannot start, e.g. Unschedulable or ImagePullBackOff
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason != "" {
+				return graph.StatusPending, condition.Reason, "Pod is pending"
+			}
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "ContainerCreating" {
+				return graph.StatusPending, cs.State.Waiting.Reason, "Pod is pending"
+			}
+		}
+		return graph.StatusPending, graph.ReasonPodPending, "Pod is pending"
 	case corev1.PodSucceeded:
-		return graph.StatusReady, "Pod succeeded"
+		return graph.StatusReady, graph.ReasonPodSucceeded, "Pod succeeded"
 	case corev1.PodFailed:
-		return graph.StatusError, "Pod failed"
+		// e.g. Evicted or DeadlineExceeded
+		return graph.StatusError, valueOr(pod.Status.Reason, graph.ReasonPodFailed), "Pod failed"
 	case corev1.PodUnknown:
-		return graph.StatusUnknown, "Pod status unknown"
+		return graph.StatusUnknown, graph.ReasonPodStatusUnknown, "Pod status unknown"
 	default:
-		return graph.StatusUnknown, fmt.Sprintf("Unknown phase: %s", pod.Status.Phase)
+		return graph.StatusUnknown, graph.ReasonUnknownPhase, fmt.Sprintf("Unknown phase: %s", pod.Status.Phase)
 	}
 }
 
@@ -102,6 +147,68 @@ func (p *PodProcessor) getTotalRestartCount(pod *corev1.Pod) int {
 	return total
 }
 
+// getContainers describes the init and regular containers of a pod
+func (p *PodProcessor) getContainers(pod *corev1.Pod) []graph.ContainerInfo {
+	containers := make([]graph.ContainerInfo, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, c := range pod.Spec.InitContainers {
+		containers = append(containers, containerInfo(c, pod.Status.InitContainerStatuses, true))
+	}
+	for _, c := range pod.Spec.Containers {
+		containers = append(containers, containerInfo(c, pod.Status.ContainerStatuses, false))
+	}
+	return containers
+}
+
+func containerInfo(c corev1.Container, statuses []corev1.ContainerStatus, init bool) graph.ContainerInfo {
+	info := graph.ContainerInfo{
+		Name:     c.Name,
+		Image:    c.Image,
+		Init:     init,
+		Requests: resourceStrings(c.Resources.Requests),
+		Limits:   resourceStrings(c.Resources.Limits),
+	}
+
+	for _, cs := range statuses {
+		if cs.Name != c.Name {
+			continue
+		}
+		info.Ready = cs.Ready
+		info.Restarts = cs.RestartCount
+		switch {
+		case cs.State.Running != nil:
+			info.State = "Running"
+		case cs.State.Waiting != nil:
+			info.State = joinReason("Waiting", cs.State.Waiting.Reason)
+		case cs.State.Terminated != nil:
+			info.State = joinReason("Terminated", cs.State.Terminated.Reason)
+		}
+		if cs.LastTerminationState.Terminated != nil {
+			info.LastTerminationReason = cs.LastTerminationState.Terminated.Reason
+		}
+		break
+	}
+	return info
+}
+
+func joinReason(state, reason string) string {
+	if reason == "" {
+		return state
+	}
+	return state + ": " + reason
+}
+
+// resourceStrings formats resource quantities, nil if there are none
+func resourceStrings(resources corev1.ResourceList) map[string]string {
+	if len(resources) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
+
 // ServiceProcessor processes Service resources
 type ServiceProcessor struct {
 	*BaseProcessor
@@ -123,6 +230,7 @@ func (p *ServiceProcessor) Process(obj interface{}, eventType EventType) error {
 
 	node := graph.NewNodeFromObject(service, "Service", "v1")
 	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonServiceActive
 	node.StatusMessage = "Service is active"
 
 	node.Metadata = &graph.ResourceMetadata{
@@ -130,7 +238,7 @@ func (p *ServiceProcessor) Process(obj interface{}, eventType EventType) error {
 		ServiceType: string(service.Spec.Type),
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, service.GetOwnerReferences())
 
 	// Note: We do NOT create direct Service -> Pod edges here.
@@ -163,9 +271,10 @@ func (p *ServiceAccountProcessor) Process(obj interface{}, eventType EventType)
 
 	node := graph.NewNodeFromObject(sa, "ServiceAccount", "v1")
 	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
 	node.StatusMessage = "ServiceAccount exists"
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, sa.GetOwnerReferences())
 
 	return nil
@@ -192,9 +301,10 @@ func (p *ConfigMapProcessor) Process(obj interface{}, eventType EventType) error
 
 	node := graph.NewNodeFromObject(cm, "ConfigMap", "v1")
 	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
 	node.StatusMessage = "ConfigMap exists"
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, cm.GetOwnerReferences())
 
 	return nil
@@ -203,10 +313,14 @@ func (p *ConfigMapProcessor) Process(obj interface{}, eventType EventType) error
 // SecretProcessor processes Secret resources
 type SecretProcessor struct {
 	*BaseProcessor
+
+	// Deployed rollback revision of each release, by <namespace>/<release>, 0 when the
+	// deployed revision is no rollback (see trackRollback)
+	rollbacks map[string]int
 }
 
 func NewSecretProcessor(g graph.GraphInterface) *SecretProcessor {
-	return &SecretProcessor{BaseProcessor: NewBaseProcessor(g)}
+	return &SecretProcessor{BaseProcessor: NewBaseProcessor(g), rollbacks: make(map[string]int)}
 }
 
 func (p *SecretProcessor) Process(obj interface{}, eventType EventType) error {
@@ -215,27 +329,58 @@ func (p *SecretProcessor) Process(obj interface{}, eventType EventType) error {
 		return fmt.Errorf("expected Secret, got %T", obj)
 	}
 
-	// Check if secret is a helm secret
-	if secret.Type == "helm.sh/release.v1" {
-		release := secret.Name
-		// inform manager to update the release resources
-		klog.V(3).InfoS(fmt.Sprintf("secret %s/%s is managing Helm release %s", secret.Namespace, secret.Name, release), "Secret is a Helm release")
-	}
-
 	if eventType == EventDelete {
-		return p.handleDelete(secret, "Secret")
+		uid := graph.ObjectID(secret.UID, secret.Namespace, "Secret", secret.Name)
+		existing, exists := p.graph.GetNode(uid)
+		if err := p.handleDelete(secret, "Secret"); err != nil {
+			return err
+		}
+		if exists && existing.Metadata != nil && existing.Metadata.HelmRevision != nil {
+			p.syncReleaseNode(existing.Metadata.HelmRevision)
+		}
+		return nil
 	}
 
 	node := graph.NewNodeFromObject(secret, "Secret", "v1")
 	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
 	node.StatusMessage = "Secret exists"
 
-	p.graph.AddNode(node)
+	if secret.Type == helmReleaseSecretType {
+		if revision := p.helmRevision(node, secret); revision != nil {
+			node.Metadata = &graph.ResourceMetadata{HelmRevision: revision}
+		}
+	}
+
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, secret.GetOwnerReferences())
 
+	if node.Metadata != nil && node.Metadata.HelmRevision != nil {
+		p.trackRollback(node.Metadata.HelmRevision)
+		p.syncReleaseNode(node.Metadata.HelmRevision)
+	}
+
 	return nil
 }
 
+// helmRevision decodes the release stored in a Helm release Secret. Resyncs deliver unchanged
+// Secrets, so the revision of the node already in the graph is reused when the resource
+// version matches.
+func (p *SecretProcessor) helmRevision(node *graph.Node, secret *corev1.Secret) *graph.HelmRevision {
+	if existing, exists := p.graph.GetNode(node.UID); exists && existing.ResourceVersion == node.ResourceVersion &&
+		existing.Metadata != nil && existing.Metadata.HelmRevision != nil {
+		return existing.Metadata.HelmRevision
+	}
+
+	revision, err := decodeHelmRelease(secret)
+	if err != nil {
+		klog.V(2).Infof("Failed to decode Helm release Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		return nil
+	}
+	klog.V(3).Infof("Secret %s/%s holds revision %d of Helm release %s (%s)", secret.Namespace, secret.Name, revision.Revision, revision.Release, revision.Status)
+	return revision
+}
+
 // PVCProcessor processes PersistentVolumeClaim resources
 type PVCProcessor struct {
 	*BaseProcessor
@@ -256,13 +401,13 @@ func (p *PVCProcessor) Process(obj interface{}, eventType EventType) error {
 	}
 
 	node := graph.NewNodeFromObject(pvc, "PersistentVolumeClaim", "v1")
-	node.Status, node.StatusMessage = p.getPVCStatus(pvc)
+	node.Status, node.StatusReason, node.StatusMessage = p.getPVCStatus(pvc)
 
 	node.Metadata = &graph.ResourceMetadata{
 		VolumeName: pvc.Spec.VolumeName,
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, pvc.GetOwnerReferences())
 
 	// Create edge to PV if bound
@@ -273,16 +418,16 @@ func (p *PVCProcessor) Process(obj interface{}, eventType EventType) error {
 	return nil
 }
 
-func (p *PVCProcessor) getPVCStatus(pvc *corev1.PersistentVolumeClaim) (graph.ResourceStatus, string) {
+func (p *PVCProcessor) getPVCStatus(pvc *corev1.PersistentVolumeClaim) (graph.ResourceStatus, string, string) {
 	switch pvc.Status.Phase {
 	case corev1.ClaimBound:
-		return graph.StatusReady, "Bound"
+		return graph.StatusReady, graph.ReasonPVCBound, "Bound"
 	case corev1.ClaimPending:
-		return graph.StatusPending, "Pending"
+		return graph.StatusPending, graph.ReasonPVCUnbound, "Pending"
 	case corev1.ClaimLost:
-		return graph.StatusError, "Lost"
+		return graph.StatusError, graph.ReasonPVCLost, "Lost"
 	default:
-		return graph.StatusUnknown, fmt.Sprintf("Phase: %s", pvc.Status.Phase)
+		return graph.StatusUnknown, graph.ReasonUnknownPhase, fmt.Sprintf("Phase: %s", pvc.Status.Phase)
 	}
 }
 
@@ -306,7 +451,7 @@ func (p *PVProcessor) Process(obj interface{}, eventType EventType) error {
 	}
 
 	node := graph.NewNodeFromObject(pv, "PersistentVolume", "v1")
-	node.Status, node.StatusMessage = p.getPVStatus(pv)
+	node.Status, node.StatusReason, node.StatusMessage = p.getPVStatus(pv)
 
 	// Set claim reference if bound
 	if pv.Spec.ClaimRef != nil {
@@ -320,27 +465,108 @@ func (p *PVProcessor) Process(obj interface{}, eventType EventType) error {
 		}
 	}
 
-	p.graph.AddNode(node)
+	// Local volumes without a zone of their own are in the zone of their Node
+	zone, region, nodeName := volumeTopology(pv)
+	if zone == "" {
+		zone, region = p.nodeTopology(nodeName)
+	}
+	if zone != "" || region != "" || nodeName != "" {
+		if node.Metadata == nil {
+			node.Metadata = &graph.ResourceMetadata{}
+		}
+		node.Metadata.Zone, node.Metadata.Region, node.Metadata.NodeName = zone, region, nodeName
+	}
+
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, pv.GetOwnerReferences())
 
+	// Create edge to the StorageClass the volume was provisioned from
+	if pv.Spec.StorageClassName != "" {
+		p.createEdgeOrPending(node.UID, "", "StorageClass", pv.Spec.StorageClassName, graph.EdgeProvisionedBy)
+	}
+
 	return nil
 }
 
-func (p *PVProcessor) getPVStatus(pv *corev1.PersistentVolume) (graph.ResourceStatus, string) {
+func (p *PVProcessor) getPVStatus(pv *corev1.PersistentVolume) (graph.ResourceStatus, string, string) {
 	switch pv.Status.Phase {
 	case corev1.VolumeBound:
-		return graph.StatusReady, "Bound"
+		return graph.StatusReady, graph.ReasonPVBound, "Bound"
 	case corev1.VolumeAvailable:
-		return graph.StatusReady, "Available"
+		return graph.StatusReady, graph.ReasonPVAvailable, "Available"
 	case corev1.VolumeReleased:
-		return graph.StatusPending, "Released"
+		return graph.StatusPending, graph.ReasonPVReleased, "Released"
 	case corev1.VolumeFailed:
-		return graph.StatusError, "Failed"
+		return graph.StatusError, graph.ReasonPVFailed, "Failed"
 	default:
-		return graph.StatusUnknown, fmt.Sprintf("Phase: %s", pv.Status.Phase)
+		return graph.StatusUnknown, graph.ReasonUnknownPhase, fmt.Sprintf("Phase: %s", pv.Status.Phase)
 	}
 }
 
+// NodeProcessor processes Kubernetes Node resources
+type NodeProcessor struct {
+	*BaseProcessor
+}
+
+func NewNodeProcessor(g graph.GraphInterface) *NodeProcessor {
+	return &NodeProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *NodeProcessor) Process(obj interface{}, eventType EventType) error {
+	k8sNode, ok := obj.(*corev1.Node)
+	if !ok {
+		return fmt.Errorf("expected Node, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(k8sNode, "Node")
+	}
+
+	node := graph.NewNodeFromObject(k8sNode, "Node", "v1")
+	node.Status, node.StatusReason, node.StatusMessage = p.getNodeStatus(k8sNode)
+	node.Metadata = &graph.ResourceMetadata{
+		Version:       k8sNode.Status.NodeInfo.KubeletVersion,
+		Unschedulable: k8sNode.Spec.Unschedulable,
+	}
+	node.Metadata.Zone, node.Metadata.Region = topologyOf(k8sNode.Labels)
+
+	// The Pods and volumes on a new Node, or one whose labels changed, take its topology
+	existingZone, existingRegion := p.nodeTopology(k8sNode.Name)
+	_, existed := p.graph.GetNode(node.UID)
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, k8sNode.GetOwnerReferences())
+	if !existed || existingZone != node.Metadata.Zone || existingRegion != node.Metadata.Region {
+		p.propagateTopology(node)
+	}
+
+	return nil
+}
+
+// getNodeStatus derives the status from the Ready condition, using the kubelet's reason
+// (e.g. KubeletReady, KubeletNotReady, NodeStatusUnknown)
+func (p *NodeProcessor) getNodeStatus(k8sNode *corev1.Node) (graph.ResourceStatus, string, string) {
+	for _, condition := range k8sNode.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		message := valueOr(condition.Message, condition.Reason)
+		if k8sNode.Spec.Unschedulable {
+			message += " (scheduling disabled)"
+		}
+		reason := valueOr(condition.Reason, graph.ReasonUnknownPhase)
+		switch condition.Status {
+		case corev1.ConditionTrue:
+			return graph.StatusReady, reason, message
+		case corev1.ConditionFalse:
+			return graph.StatusError, reason, message
+		default:
+			return graph.StatusUnknown, reason, message
+		}
+	}
+	return graph.StatusUnknown, graph.ReasonUnknownPhase, "Ready condition not reported"
+}
+
 // NamespaceProcessor processes Namespace resources
 type NamespaceProcessor struct {
 	*BaseProcessor
@@ -365,29 +591,57 @@ func (p *NamespaceProcessor) Process(obj interface{}, eventType EventType) error
 	switch ns.Status.Phase {
 	case corev1.NamespaceActive:
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonNamespaceActive
 		node.StatusMessage = "Active"
 	case corev1.NamespaceTerminating:
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonNamespaceTerminating
 		node.StatusMessage = "Terminating"
 	default:
 		node.Status = graph.StatusUnknown
+		node.StatusReason = graph.ReasonUnknownPhase
 		node.StatusMessage = fmt.Sprintf("Phase: %s", ns.Status.Phase)
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 
 	return nil
 }
 
-// createConfigMapSecretEdges creates edges from a pod spec to ConfigMaps and Secrets
+// createConfigMapSecretEdges creates edges from a pod spec to ConfigMaps and Secrets, recording
+// how each is used (volume, envFrom, env), the keys referenced and where volumes are mounted
 func (p *BaseProcessor) createConfigMapSecretEdges(node *graph.Node, podSpec *corev1.PodSpec) {
+	var refs []*configReference
+	byTarget := make(map[string]*configReference)
+	reference := func(kind, name string) *configReference {
+		if ref, exists := byTarget[kind+"/"+name]; exists {
+			return ref
+		}
+		ref := &configReference{kind: kind, name: name}
+		byTarget[kind+"/"+name] = ref
+		refs = append(refs, ref)
+		return ref
+	}
+	mounts := volumeMounts(podSpec)
+
 	// From volumes
 	for _, volume := range podSpec.Volumes {
-		if volume.ConfigMap != nil {
-			p.createEdgeOrPending(node.UID, node.Namespace, "ConfigMap", volume.ConfigMap.Name, graph.EdgeConfigMapRef)
+		var ref *configReference
+		var items []corev1.KeyToPath
+		switch {
+		case volume.ConfigMap != nil:
+			ref, items = reference("ConfigMap", volume.ConfigMap.Name), volume.ConfigMap.Items
+		case volume.Secret != nil:
+			ref, items = reference("Secret", volume.Secret.SecretName), volume.Secret.Items
+		default:
+			continue
 		}
-		if volume.Secret != nil {
-			p.createEdgeOrPending(node.UID, node.Namespace, "Secret", volume.Secret.SecretName, graph.EdgeSecretRef)
+		ref.add(&ref.refs, "volume")
+		for _, item := range items {
+			ref.add(&ref.keys, item.Key)
+		}
+		for _, mount := range mounts[volume.Name] {
+			ref.add(&ref.mountPaths, mount.path)
 		}
 	}
 
@@ -395,24 +649,117 @@ func (p *BaseProcessor) createConfigMapSecretEdges(node *graph.Node, podSpec *co
 	for _, container := range podSpec.Containers {
 		// From envFrom
 		for _, envFrom := range container.EnvFrom {
-			if envFrom.ConfigMapRef != nil {
-				p.createEdgeOrPending(node.UID, node.Namespace, "ConfigMap", envFrom.ConfigMapRef.Name, graph.EdgeConfigMapRef)
+			var ref *configReference
+			switch {
+			case envFrom.ConfigMapRef != nil:
+				ref = reference("ConfigMap", envFrom.ConfigMapRef.Name)
+			case envFrom.SecretRef != nil:
+				ref = reference("Secret", envFrom.SecretRef.Name)
+			default:
+				continue
 			}
-			if envFrom.SecretRef != nil {
-				p.createEdgeOrPending(node.UID, node.Namespace, "Secret", envFrom.SecretRef.Name, graph.EdgeSecretRef)
+			ref.add(&ref.refs, "envFrom")
+			if envFrom.Prefix != "" {
+				ref.add(&ref.envPrefixes, envFrom.Prefix)
 			}
 		}
 
 		// From env
 		for _, env := range container.Env {
-			if env.ValueFrom != nil {
-				if env.ValueFrom.ConfigMapKeyRef != nil {
-					p.createEdgeOrPending(node.UID, node.Namespace, "ConfigMap", env.ValueFrom.ConfigMapKeyRef.Name, graph.EdgeConfigMapRef)
-				}
-				if env.ValueFrom.SecretKeyRef != nil {
-					p.createEdgeOrPending(node.UID, node.Namespace, "Secret", env.ValueFrom.SecretKeyRef.Name, graph.EdgeSecretRef)
-				}
+			if env.ValueFrom == nil {
+				continue
+			}
+			if keyRef := env.ValueFrom.ConfigMapKeyRef; keyRef != nil {
+				ref := reference("ConfigMap", keyRef.Name)
+				ref.add(&ref.refs, "env")
+				ref.add(&ref.keys, keyRef.Key)
+			}
+			if keyRef := env.ValueFrom.SecretKeyRef; keyRef != nil {
+				ref := reference("Secret", keyRef.Name)
+				ref.add(&ref.refs, "env")
+				ref.add(&ref.keys, keyRef.Key)
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		edgeType := graph.EdgeConfigMapRef
+		if ref.kind == "Secret" {
+			edgeType = graph.EdgeSecretRef
+		}
+		p.createEdgeOrPendingWithMetadata(node.UID, node.Namespace, ref.kind, ref.name, edgeType, ref.metadata())
+	}
+}
+
+// configReference collects the uses of a ConfigMap or Secret in a pod spec
+type configReference struct {
+	kind, name  string
+	refs        []string
+	keys        []string
+	mountPaths  []string
+	envPrefixes []string
+}
+
+// add appends value to list unless it is already there
+func (r *configReference) add(list *[]string, value string) {
+	for _, existing := range *list {
+		if existing == value {
+			return
+		}
+	}
+	*list = append(*list, value)
+}
+
+func (r *configReference) metadata() map[string]string {
+	metadata := map[string]string{graph.EdgeMetaRefs: strings.Join(r.refs, ",")}
+	if len(r.keys) > 0 {
+		metadata[graph.EdgeMetaKeys] = strings.Join(r.keys, ",")
+	}
+	if len(r.mountPaths) > 0 {
+		metadata[graph.EdgeMetaMountPaths] = strings.Join(r.mountPaths, ",")
+	}
+	if len(r.envPrefixes) > 0 {
+		metadata[graph.EdgeMetaEnvPrefix] = strings.Join(r.envPrefixes, ",")
+	}
+	return metadata
+}
+
+// volumeMount is where a container mounts a volume
+type volumeMount struct {
+	path     string // container:mountPath
+	readOnly bool
+}
+
+// volumeMounts returns the mounts of each volume of a pod spec by volume name
+func volumeMounts(podSpec *corev1.PodSpec) map[string][]volumeMount {
+	mounts := make(map[string][]volumeMount)
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, container := range containers {
+			for _, mount := range container.VolumeMounts {
+				mounts[mount.Name] = append(mounts[mount.Name], volumeMount{
+					path:     container.Name + ":" + mount.MountPath,
+					readOnly: mount.ReadOnly,
+				})
 			}
 		}
 	}
+	return mounts
+}
+
+// pvcEdgeMetadata describes how a pod spec mounts a PersistentVolumeClaim volume
+func pvcEdgeMetadata(volume corev1.Volume, mounts []volumeMount) map[string]string {
+	metadata := make(map[string]string)
+	mountedReadOnly := len(mounts) > 0
+	paths := make([]string, 0, len(mounts))
+	for _, mount := range mounts {
+		paths = append(paths, mount.path)
+		mountedReadOnly = mountedReadOnly && mount.readOnly
+	}
+	if len(paths) > 0 {
+		metadata[graph.EdgeMetaMountPaths] = strings.Join(paths, ",")
+	}
+	if volume.PersistentVolumeClaim.ReadOnly || mountedReadOnly {
+		metadata[graph.EdgeMetaReadOnly] = "true"
+	}
+	return metadata
 }
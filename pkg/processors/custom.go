@@ -0,0 +1,313 @@
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Custom resources of the config file are watched without a dedicated processor. Their status
+// follows the kstatus conventions and their edges are inferred:
+//   - ownership from their owner references
+//   - manages from the Deployment named by their *.kubernetes.io/managed-by label, the operator
+//     reconciling them, when it runs in their namespace
+//   - references from their spec: objects with kind and name fields, <kind>Ref objects with a
+//     name field and <kind>Name fields, e.g. issuerRef or secretName, of the watched kinds
+//   - references at the paths configured for the kind, which the inference misses
+//
+// References to Secrets, ConfigMaps and ServiceAccounts are uses-secret, uses-configmap and
+// uses-sa edges like those of workloads, the others are references edges.
+
+// CustomResource is a custom resource kind watched without a dedicated processor
+type CustomResource struct {
+	Kind     string
+	Resource schema.GroupVersionResource
+	// ClusterScoped custom resources are referenced without a namespace
+	ClusterScoped bool
+	References    []ReferencePath
+}
+
+// ReferencePath locates the references of a custom resource to objects of a kind
+type ReferencePath struct {
+	// Path is the dot-separated path of the references, e.g. spec.backup.credentials, whose
+	// lists are traversed. A reference is the name of the referenced object, or an object with
+	// a name and optionally a namespace and kind.
+	Path string
+	// Kind is the kind of the referenced objects, unless the references name it
+	Kind string
+}
+
+// clusterScopedKinds are the watched kinds referenced without a namespace
+var clusterScopedKinds = map[string]bool{
+	"Namespace":        true,
+	"Node":             true,
+	"PersistentVolume": true,
+	"StorageClass":     true,
+	"CSIDriver":        true,
+	"VolumeAttachment": true,
+	"ClusterIssuer":    true,
+}
+
+// managedByLabel is the label naming the operator reconciling a resource
+const managedByLabel = "app.kubernetes.io/managed-by"
+
+// referenceEdgeTypes are the edge types of references, pruned when references are removed
+var referenceEdgeTypes = []graph.EdgeType{graph.EdgeReferences, graph.EdgeSecretRef, graph.EdgeConfigMapRef, graph.EdgeServiceAccount}
+
+// reference identifies an object referenced by a custom resource
+type reference struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+// CustomResourceProcessor processes the custom resources of a kind of the config file
+type CustomResourceProcessor struct {
+	*BaseProcessor
+	resource CustomResource
+	// kinds maps the lower-cased kinds that can be referenced to their spelling, set by the
+	// registry once every processor is known
+	kinds map[string]string
+	// clusterScoped are the referenced kinds without a namespace
+	clusterScoped map[string]bool
+}
+
+func NewCustomResourceProcessor(g graph.GraphInterface, resource CustomResource) *CustomResourceProcessor {
+	return &CustomResourceProcessor{
+		BaseProcessor: NewBaseProcessor(g),
+		resource:      resource,
+		kinds:         map[string]string{strings.ToLower(resource.Kind): resource.Kind},
+		clusterScoped: clusterScopedKinds,
+	}
+}
+
+// setReferenceableKinds sets the kinds the custom resources can be linked to
+func (p *CustomResourceProcessor) setReferenceableKinds(kinds []string, clusterScoped map[string]bool) {
+	p.kinds = make(map[string]string, len(kinds))
+	for _, kind := range kinds {
+		p.kinds[strings.ToLower(kind)] = kind
+	}
+	p.clusterScoped = clusterScoped
+}
+
+func (p *CustomResourceProcessor) Process(obj interface{}, eventType EventType) error {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected %s, got %T", p.resource.Kind, obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(resource, p.resource.Kind)
+	}
+
+	node := graph.NewNodeFromObject(resource, p.resource.Kind, resource.GetAPIVersion())
+	status, _ := KStatus{}.EvaluateStatus(node, resource.Object)
+	node.Status, node.StatusReason, node.StatusMessage = status.Status, status.Reason, status.Message
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, resource.GetOwnerReferences())
+	p.linkManager(node)
+	p.linkReferences(node, resource)
+
+	return nil
+}
+
+// linkManager links the Deployment of the operator named by the managed-by label of a custom
+// resource, and drops the link to an operator that no longer manages it
+func (p *CustomResourceProcessor) linkManager(node *graph.Node) {
+	manager := managedBy(node.Labels)
+	if strings.EqualFold(manager, "Helm") || node.Namespace == "" || p.kinds["deployment"] == "" {
+		manager = ""
+	}
+	if manager != "" {
+		p.createReverseEdgeOrPending(node.UID, node.Namespace, "Deployment", manager, graph.EdgeManages)
+	}
+
+	var stale []types.UID
+	for _, edge := range p.graph.IncomingEdges(node.UID) {
+		if edge.Type != graph.EdgeManages {
+			continue
+		}
+		if from, exists := p.graph.GetNode(edge.FromUID); exists && from.Kind == "Deployment" && from.Name != manager {
+			stale = append(stale, edge.FromUID)
+		}
+	}
+	for _, fromUID := range stale {
+		p.graph.RemoveEdge(fromUID, node.UID)
+	}
+}
+
+// managedBy returns the operator named by the app.kubernetes.io/managed-by label, or another
+// *.kubernetes.io/managed-by label
+func managedBy(labels map[string]string) string {
+	if manager := labels[managedByLabel]; manager != "" {
+		return manager
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if strings.HasSuffix(key, "kubernetes.io/managed-by") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if labels[key] != "" {
+			return labels[key]
+		}
+	}
+	return ""
+}
+
+// linkReferences links a custom resource to the objects it references, and drops the links to
+// objects it no longer references
+func (p *CustomResourceProcessor) linkReferences(node *graph.Node, resource *unstructured.Unstructured) {
+	var refs []reference
+	if spec, ok := resource.Object["spec"].(map[string]interface{}); ok {
+		p.inferReferences(spec, node.Namespace, &refs)
+	}
+	for _, path := range p.resource.References {
+		for _, value := range lookup(resource.Object, strings.Split(path.Path, ".")) {
+			if ref, ok := p.reference(value, path.Kind, node.Namespace); ok {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	keep := make(map[graph.EdgeType]map[types.UID]bool, len(referenceEdgeTypes))
+	for _, edgeType := range referenceEdgeTypes {
+		keep[edgeType] = make(map[types.UID]bool)
+	}
+	seen := make(map[reference]bool, len(refs))
+	for _, ref := range refs {
+		if seen[ref] || (ref.kind == node.Kind && ref.namespace == node.Namespace && ref.name == node.Name) {
+			continue
+		}
+		seen[ref] = true
+
+		edgeType := referenceEdgeType(ref.kind)
+		if target := p.findNodeByNamespaceKindName(ref.namespace, ref.kind, ref.name); target != nil {
+			p.createEdgeIfNodeExists(node.UID, target.UID, edgeType)
+			keep[edgeType][target.UID] = true
+		} else {
+			p.createEdgeOrPending(node.UID, ref.namespace, ref.kind, ref.name, edgeType)
+		}
+	}
+	for _, edgeType := range referenceEdgeTypes {
+		p.pruneEdges(node.UID, edgeType, keep[edgeType])
+	}
+}
+
+// referenceEdgeType returns the type of the edges of references to a kind
+func referenceEdgeType(kind string) graph.EdgeType {
+	switch kind {
+	case "Secret":
+		return graph.EdgeSecretRef
+	case "ConfigMap":
+		return graph.EdgeConfigMapRef
+	case "ServiceAccount":
+		return graph.EdgeServiceAccount
+	default:
+		return graph.EdgeReferences
+	}
+}
+
+// inferReferences collects the references found in a value of the spec of a custom resource
+func (p *CustomResourceProcessor) inferReferences(value interface{}, namespace string, refs *[]reference) {
+	switch value := value.(type) {
+	case []interface{}:
+		for _, item := range value {
+			p.inferReferences(item, namespace, refs)
+		}
+	case map[string]interface{}:
+		// An object with kind and name fields, e.g. a scaleTargetRef
+		if _, named := value["kind"].(string); named {
+			if ref, ok := p.reference(value, "", namespace); ok {
+				*refs = append(*refs, ref)
+			}
+		}
+		for key, field := range value {
+			switch field := field.(type) {
+			case string:
+				// e.g. secretName: tls-cert
+				if kind := p.kindOf(key, "Name"); kind != "" {
+					if ref, ok := p.reference(field, kind, namespace); ok {
+						*refs = append(*refs, ref)
+					}
+				}
+			case map[string]interface{}:
+				// e.g. configMapRef: {name: settings}
+				if kind := p.kindOf(key, "Ref"); kind != "" {
+					if ref, ok := p.reference(field, kind, namespace); ok {
+						*refs = append(*refs, ref)
+					}
+				}
+				p.inferReferences(field, namespace, refs)
+			case []interface{}:
+				p.inferReferences(field, namespace, refs)
+			}
+		}
+	}
+}
+
+// kindOf returns the kind a field named <kind><suffix> refers to, "" if it names no watched kind
+func (p *CustomResourceProcessor) kindOf(field, suffix string) string {
+	prefix, found := strings.CutSuffix(field, suffix)
+	if !found || prefix == "" {
+		return ""
+	}
+	return p.kinds[strings.ToLower(prefix)]
+}
+
+// reference returns the reference of a value: the name of an object of kind, or an object with
+// name, namespace and kind fields. Objects of kinds that are not watched are not referenced.
+func (p *CustomResourceProcessor) reference(value interface{}, kind, namespace string) (reference, bool) {
+	var name string
+	switch value := value.(type) {
+	case string:
+		name = value
+	case map[string]interface{}:
+		name, _ = value["name"].(string)
+		if refKind, _ := value["kind"].(string); refKind != "" {
+			kind = refKind
+		}
+		if refNamespace, _ := value["namespace"].(string); refNamespace != "" {
+			namespace = refNamespace
+		}
+	}
+	kind = p.kinds[strings.ToLower(kind)]
+	if name == "" || kind == "" {
+		return reference{}, false
+	}
+	if p.clusterScoped[kind] {
+		namespace = ""
+	}
+	return reference{namespace: namespace, kind: kind, name: name}, true
+}
+
+// lookup returns the values at a path of an object, traversing the lists on the path
+func lookup(value interface{}, path []string) []interface{} {
+	if items, ok := value.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range items {
+			values = append(values, lookup(item, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field, exists := fields[path[0]]
+	if !exists {
+		return nil
+	}
+	return lookup(field, path[1:])
+}
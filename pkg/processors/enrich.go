@@ -0,0 +1,128 @@
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+// NodeEnricher adds information to a node from its raw object before it is stored in the graph
+type NodeEnricher interface {
+	Enrich(node *graph.Node, obj interface{})
+}
+
+// ComputedField derives a named metadata field from a JSONPath over the raw object of a kind
+type ComputedField struct {
+	Kind     string
+	Name     string
+	JSONPath string
+}
+
+type compiledField struct {
+	name string
+	path jsonPath
+}
+
+// jsonPath is a validated JSONPath expression. A parsed client-go JSONPath changes its state
+// while it executes, so it can neither be shared by the worker pools nor run again after a
+// range; every evaluation parses the expression anew.
+type jsonPath struct {
+	name       string
+	expression string
+}
+
+// parseJSONPath validates an expression, adding the braces kubectl makes optional
+func parseJSONPath(name, expression string) (jsonPath, error) {
+	if !strings.HasPrefix(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+	p := jsonPath{name: name, expression: expression}
+	if _, err := p.parse(); err != nil {
+		return jsonPath{}, err
+	}
+	return p, nil
+}
+
+func (p jsonPath) parse() (*jsonpath.JSONPath, error) {
+	path := jsonpath.New(p.name).AllowMissingKeys(true)
+	if err := path.Parse(p.expression); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// Execute writes the results of the expression over data
+func (p jsonPath) Execute(w io.Writer, data interface{}) error {
+	path, err := p.parse()
+	if err != nil {
+		return err
+	}
+	return path.Execute(w, data)
+}
+
+// Enricher evaluates computed fields against raw objects and stores the results on nodes
+type Enricher struct {
+	fields map[string][]compiledField // kind -> fields
+}
+
+// NewEnricher compiles the JSONPath expressions of the computed fields
+func NewEnricher(fields []ComputedField) (*Enricher, error) {
+	e := &Enricher{fields: make(map[string][]compiledField)}
+
+	for _, field := range fields {
+		if field.Kind == "" || field.Name == "" || field.JSONPath == "" {
+			return nil, fmt.Errorf("computed field requires kind, name and jsonPath: %+v", field)
+		}
+
+		path, err := parseJSONPath(field.Name, field.JSONPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonPath for computed field %s/%s: %w", field.Kind, field.Name, err)
+		}
+
+		e.fields[field.Kind] = append(e.fields[field.Kind], compiledField{name: field.Name, path: path})
+	}
+
+	return e, nil
+}
+
+// Enrich sets the computed fields configured for the node's kind from the raw object
+func (e *Enricher) Enrich(node *graph.Node, obj interface{}) {
+	if e == nil {
+		return
+	}
+	fields := e.fields[node.Kind]
+	if len(fields) == 0 {
+		return
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		klog.V(2).Infof("Failed to convert %s/%s for computed fields: %v", node.Kind, node.Name, err)
+		return
+	}
+
+	for _, field := range fields {
+		var buf bytes.Buffer
+		if err := field.path.Execute(&buf, raw); err != nil {
+			klog.V(4).Infof("Computed field %s not available on %s/%s: %v", field.name, node.Kind, node.Name, err)
+			continue
+		}
+		if buf.Len() == 0 {
+			continue
+		}
+
+		if node.Metadata == nil {
+			node.Metadata = &graph.ResourceMetadata{}
+		}
+		if node.Metadata.Computed == nil {
+			node.Metadata.Computed = make(map[string]string)
+		}
+		node.Metadata.Computed[field.name] = buf.String()
+	}
+}
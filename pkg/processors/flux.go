@@ -0,0 +1,254 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// FluxKustomizationProcessor processes Flux Kustomization resources
+type FluxKustomizationProcessor struct {
+	*BaseProcessor
+}
+
+func NewFluxKustomizationProcessor(g graph.GraphInterface) *FluxKustomizationProcessor {
+	return &FluxKustomizationProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *FluxKustomizationProcessor) Process(obj interface{}, eventType EventType) error {
+	ks, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected Kustomization, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(ks, "Kustomization")
+	}
+
+	node := graph.NewNodeFromObject(ks, "Kustomization", ks.GetAPIVersion())
+	node.Status, node.StatusReason, node.StatusMessage = fluxStatus(ks)
+
+	gitOps := fluxGitOpsStatus(ks)
+	gitOps.Path, _, _ = unstructured.NestedString(ks.Object, "spec", "path")
+	node.Metadata = &graph.ResourceMetadata{GitOps: gitOps}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, ks.GetOwnerReferences())
+	p.createManagesEdges(node, ks)
+
+	return nil
+}
+
+// createManagesEdges links the Kustomization to the resources in its inventory and to those
+// carrying its Flux labels, and drops edges to resources it no longer applies
+func (p *FluxKustomizationProcessor) createManagesEdges(node *graph.Node, ks *unstructured.Unstructured) {
+	managed := make(map[types.UID]bool)
+
+	// Inventory entries are identified as <namespace>_<name>_<group>_<kind>
+	entries, _, _ := unstructured.NestedSlice(ks.Object, "status", "inventory", "entries")
+	for _, item := range entries {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _, _ := unstructured.NestedString(entry, "id")
+		parts := strings.Split(id, "_")
+		if len(parts) != 4 || parts[1] == "" || parts[3] == "" {
+			continue
+		}
+		namespace, name, kind := parts[0], parts[1], parts[3]
+
+		if target := p.findNodeByNamespaceKindName(namespace, kind, name); target != nil {
+			managed[target.UID] = true
+		}
+		p.createEdgeOrPending(node.UID, namespace, kind, name, graph.EdgeManages)
+	}
+
+	// Resources labelled by kustomize-controller (covers Kustomizations without inventory)
+	for _, target := range p.graph.GetNodesByGroup(graph.GrouperKustomize, ks.GetName()) {
+		if target.UID == node.UID || target.Labels[graph.FluxKustomizeNamespaceLabel] != ks.GetNamespace() {
+			continue
+		}
+		managed[target.UID] = true
+		p.createEdgeIfNodeExists(node.UID, target.UID, graph.EdgeManages)
+	}
+
+	p.pruneEdges(node.UID, graph.EdgeManages, managed)
+
+	klog.V(3).Infof("Kustomization %s/%s manages %d tracked resource(s)", ks.GetNamespace(), ks.GetName(), len(managed))
+}
+
+// FluxHelmReleaseProcessor processes Flux HelmRelease resources
+type FluxHelmReleaseProcessor struct {
+	*BaseProcessor
+}
+
+func NewFluxHelmReleaseProcessor(g graph.GraphInterface) *FluxHelmReleaseProcessor {
+	return &FluxHelmReleaseProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *FluxHelmReleaseProcessor) Process(obj interface{}, eventType EventType) error {
+	hr, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected HelmRelease, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(hr, "HelmRelease")
+	}
+
+	node := graph.NewNodeFromObject(hr, "HelmRelease", hr.GetAPIVersion())
+	node.Status, node.StatusReason, node.StatusMessage = fluxStatus(hr)
+
+	gitOps := fluxGitOpsStatus(hr)
+	gitOps.Path, _, _ = unstructured.NestedString(hr.Object, "spec", "chart", "spec", "chart")
+	if gitOps.Revision == "" {
+		// helm.toolkit.fluxcd.io/v2 records the chart version in the release history
+		if history, _, _ := unstructured.NestedSlice(hr.Object, "status", "history"); len(history) > 0 {
+			if latest, ok := history[0].(map[string]interface{}); ok {
+				gitOps.Revision, _, _ = unstructured.NestedString(latest, "chartVersion")
+			}
+		}
+	}
+	node.Metadata = &graph.ResourceMetadata{GitOps: gitOps}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, hr.GetOwnerReferences())
+	p.createManagesEdges(node, hr)
+
+	return nil
+}
+
+// createManagesEdges links the HelmRelease to the resources of the Helm release it installs
+func (p *FluxHelmReleaseProcessor) createManagesEdges(node *graph.Node, hr *unstructured.Unstructured) {
+	releaseName, releaseNamespace := fluxHelmReleaseTarget(hr)
+	managed := make(map[types.UID]bool)
+
+	for _, target := range p.graph.GetNodesByHelmRelease(releaseName) {
+		if name, labelled := target.Labels[graph.FluxHelmNameLabel]; labelled {
+			if name != hr.GetName() || target.Labels[graph.FluxHelmNamespaceLabel] != hr.GetNamespace() {
+				continue
+			}
+		} else if target.Namespace != "" && target.Namespace != releaseNamespace {
+			// A release of the same name in another namespace
+			continue
+		}
+		managed[target.UID] = true
+		p.createEdgeIfNodeExists(node.UID, target.UID, graph.EdgeManages)
+	}
+
+	p.pruneEdges(node.UID, graph.EdgeManages, managed)
+
+	klog.V(3).Infof("HelmRelease %s/%s manages %d resource(s) of release %s", hr.GetNamespace(), hr.GetName(), len(managed), releaseName)
+}
+
+// fluxHelmReleaseTarget returns the name and namespace of the Helm release installed by a
+// HelmRelease, following helm-controller's defaults
+func fluxHelmReleaseTarget(hr *unstructured.Unstructured) (string, string) {
+	targetNamespace, _, _ := unstructured.NestedString(hr.Object, "spec", "targetNamespace")
+	namespace := valueOr(targetNamespace, hr.GetNamespace())
+
+	if history, _, _ := unstructured.NestedSlice(hr.Object, "status", "history"); len(history) > 0 {
+		if latest, ok := history[0].(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(latest, "name")
+			releaseNamespace, _, _ := unstructured.NestedString(latest, "namespace")
+			if name != "" {
+				return name, valueOr(releaseNamespace, namespace)
+			}
+		}
+	}
+	if releaseName, _, _ := unstructured.NestedString(hr.Object, "spec", "releaseName"); releaseName != "" {
+		return releaseName, namespace
+	}
+	if targetNamespace != "" {
+		return targetNamespace + "-" + hr.GetName(), namespace
+	}
+	return hr.GetName(), namespace
+}
+
+// fluxStatus derives the node status from the Ready condition of a Flux object, using the
+// condition reason (e.g. ReconciliationSucceeded, InstallFailed) as the status reason
+func fluxStatus(obj *unstructured.Unstructured) (graph.ResourceStatus, string, string) {
+	if suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend"); suspended {
+		return graph.StatusPending, graph.ReasonSuspended, "Reconciliation suspended"
+	}
+
+	ready := fluxCondition(obj, "Ready")
+	if ready == nil {
+		return graph.StatusUnknown, graph.ReasonNotReconciled, "Not reconciled yet"
+	}
+
+	status, _, _ := unstructured.NestedString(ready, "status")
+	reason, _, _ := unstructured.NestedString(ready, "reason")
+	message, _, _ := unstructured.NestedString(ready, "message")
+	message = valueOr(message, reason)
+	reason = valueOr(reason, graph.ReasonNotReconciled)
+
+	switch status {
+	case "True":
+		return graph.StatusReady, reason, message
+	case "False":
+		if reason == "DependencyNotReady" {
+			return graph.StatusPending, reason, message
+		}
+		if reconciling := fluxCondition(obj, "Reconciling"); reconciling != nil {
+			if value, _, _ := unstructured.NestedString(reconciling, "status"); value == "True" {
+				return graph.StatusPending, reason, message
+			}
+		}
+		return graph.StatusError, reason, message
+	default:
+		return graph.StatusPending, reason, message
+	}
+}
+
+// fluxGitOpsStatus extracts the source and reconciliation state common to Flux objects
+func fluxGitOpsStatus(obj *unstructured.Unstructured) *graph.GitOpsStatus {
+	gitOps := &graph.GitOpsStatus{}
+	if ready := fluxCondition(obj, "Ready"); ready != nil {
+		gitOps.SyncStatus, _, _ = unstructured.NestedString(ready, "reason")
+	}
+	if healthy := fluxCondition(obj, "Healthy"); healthy != nil {
+		status, _, _ := unstructured.NestedString(healthy, "status")
+		gitOps.HealthStatus = map[string]string{"True": "Healthy", "False": "Unhealthy"}[status]
+	}
+	gitOps.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "lastAppliedRevision")
+	gitOps.DestinationNamespace, _, _ = unstructured.NestedString(obj.Object, "spec", "targetNamespace")
+
+	// Kustomizations reference their source directly, HelmReleases through the chart template
+	// (or spec.chartRef in helm.toolkit.fluxcd.io/v2)
+	sourceRef, found, _ := unstructured.NestedMap(obj.Object, "spec", "sourceRef")
+	if !found {
+		sourceRef, found, _ = unstructured.NestedMap(obj.Object, "spec", "chart", "spec", "sourceRef")
+	}
+	if !found {
+		sourceRef, found, _ = unstructured.NestedMap(obj.Object, "spec", "chartRef")
+	}
+	if found {
+		kind, _, _ := unstructured.NestedString(sourceRef, "kind")
+		name, _, _ := unstructured.NestedString(sourceRef, "name")
+		namespace, _, _ := unstructured.NestedString(sourceRef, "namespace")
+		gitOps.SourceRef = fmt.Sprintf("%s/%s/%s", kind, valueOr(namespace, obj.GetNamespace()), name)
+	}
+
+	return gitOps
+}
+
+// fluxCondition returns the status condition of the given type, or nil
+func fluxCondition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, _, _ := unstructured.NestedString(condition, "type"); value == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
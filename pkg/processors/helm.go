@@ -0,0 +1,334 @@
+package processors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// helmReleaseSecretType is the type of the Secrets the Helm 3 storage driver keeps a release
+// revision in
+const helmReleaseSecretType corev1.SecretType = "helm.sh/release.v1"
+
+// maxHelmReleaseSize bounds the decompressed size of a release payload
+const maxHelmReleaseSize = 64 << 20
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// helmRelease holds the fields of a Helm 3 release record that are tracked
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		// Helm writes zero times as "", which time.Time does not parse
+		FirstDeployed string `json:"first_deployed"`
+		LastDeployed  string `json:"last_deployed"`
+		Description   string `json:"description"`
+		Status        string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+			// Dependencies are the subcharts declared in Chart.yaml
+			Dependencies []graph.ChartDependency `json:"dependencies"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Config   json.RawMessage `json:"config"`
+	Manifest string          `json:"manifest"`
+}
+
+// decodeHelmRelease decodes the release key of a Helm release Secret: JSON, gzipped and base64
+// encoded by Helm on top of the Secret's own encoding. Helm 2 protobuf releases, stored in
+// ConfigMaps, are not supported.
+func decodeHelmRelease(secret *corev1.Secret) (*graph.HelmRevision, error) {
+	payload, ok := secret.Data["release"]
+	if !ok {
+		return nil, fmt.Errorf("no release key")
+	}
+
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+	n, err := base64.StdEncoding.Decode(data, payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64: %w", err)
+	}
+	data = data[:n]
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing: %w", err)
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(io.LimitReader(reader, maxHelmReleaseSize)); err != nil {
+			return nil, fmt.Errorf("decompressing: %w", err)
+		}
+	}
+
+	var release helmRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("decoding release: %w", err)
+	}
+
+	revision := &graph.HelmRevision{
+		Release:       release.Name,
+		Namespace:     valueOr(release.Namespace, secret.Namespace),
+		Revision:      release.Version,
+		Status:        release.Info.Status,
+		Chart:         release.Chart.Metadata.Name,
+		ChartVersion:  release.Chart.Metadata.Version,
+		AppVersion:    release.Chart.Metadata.AppVersion,
+		FirstDeployed: parseHelmTime(release.Info.FirstDeployed),
+		LastDeployed:  parseHelmTime(release.Info.LastDeployed),
+		Description:   release.Info.Description,
+		RollbackTo:    rollbackTarget(release.Info.Description),
+		Secret:        secret.Name,
+		Dependencies:  release.Chart.Metadata.Dependencies,
+		APIs:          manifestAPIs(release.Manifest),
+	}
+	if len(release.Config) > 0 && string(release.Config) != "null" {
+		if digest, err := valuesDigest(release.Config); err == nil {
+			revision.ValuesDigest = digest
+		}
+	}
+	return revision, nil
+}
+
+// manifestAPIs returns the distinct API versions and kinds of the objects in a rendered
+// manifest, sorted
+func manifestAPIs(manifest string) []graph.ManifestAPI {
+	seen := make(map[graph.ManifestAPI]bool)
+	for _, document := range strings.Split(manifest, "\n---") {
+		var object struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if err := yaml.Unmarshal([]byte(document), &object); err != nil || object.APIVersion == "" || object.Kind == "" {
+			continue
+		}
+		seen[graph.ManifestAPI{APIVersion: object.APIVersion, Kind: object.Kind}] = true
+	}
+
+	apis := make([]graph.ManifestAPI, 0, len(seen))
+	for api := range seen {
+		apis = append(apis, api)
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].APIVersion != apis[j].APIVersion {
+			return apis[i].APIVersion < apis[j].APIVersion
+		}
+		return apis[i].Kind < apis[j].Kind
+	})
+	return apis
+}
+
+// valuesDigest hashes release values in a canonical form, so equal values hash equally
+// whatever the key order Helm serialized them in
+func valuesDigest(config json.RawMessage) (string, error) {
+	var values interface{}
+	if err := json.Unmarshal(config, &values); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func parseHelmTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
+
+// rollbackTarget returns the revision restored by a helm rollback, from the description Helm
+// gives the new revision ("Rollback to 3"), or 0
+func rollbackTarget(description string) int {
+	var target int
+	if _, err := fmt.Sscanf(description, "Rollback to %d", &target); err != nil {
+		return 0
+	}
+	return target
+}
+
+// releaseRollback tells whether the current revision of a release in a namespace is a
+// rollback. history is sorted newest first. The current revision is the newest deployed one,
+// or one being rolled back to; it is a rollback when helm rollback created it, or when it is
+// lower than a revision that was deployed after it.
+func releaseRollback(history []graph.HelmRevision, namespace string) *graph.RollbackMarker {
+	var current *graph.HelmRevision
+	newer := 0 // newest superseded revision above the current one
+	for i := range history {
+		revision := &history[i]
+		if revision.Namespace != namespace {
+			continue
+		}
+		if revision.Status == "deployed" || revision.Status == "pending-rollback" {
+			current = revision
+			break
+		}
+		if revision.Status == "superseded" && newer == 0 {
+			newer = revision.Revision
+		}
+	}
+	if current == nil {
+		return nil
+	}
+
+	marker := &graph.RollbackMarker{Revision: current.Revision, RolledBackAt: current.LastDeployed}
+	switch {
+	case newer > current.Revision:
+		marker.FromRevision = newer
+		marker.ToRevision = current.Revision
+	case current.RollbackTo > 0 || current.Status == "pending-rollback":
+		marker.ToRevision = current.RollbackTo
+		// The revision deployed before is the newest one below the rollback that was deployed,
+		// superseded by the rollback, or still deployed while the rollback is pending
+		for _, revision := range history {
+			if revision.Namespace == namespace && revision.Revision < current.Revision &&
+				(revision.Status == "superseded" || revision.Status == "deployed") {
+				marker.FromRevision = revision.Revision
+				break
+			}
+		}
+	default:
+		return nil
+	}
+	return marker
+}
+
+// trackRollback updates the rollback markers of a release's resources when its current
+// revision becomes, or stops being, a rollback, and records new rollbacks in the timeline
+func (p *SecretProcessor) trackRollback(revision *graph.HelmRevision) {
+	key := revision.Namespace + "/" + revision.Release
+	marker := releaseRollback(p.graph.GetReleaseHistory(revision.Release), revision.Namespace)
+
+	markedRevision := 0
+	if marker != nil {
+		markedRevision = marker.Revision
+	}
+	if previous, known := p.rollbacks[key]; known && previous == markedRevision {
+		return
+	}
+	p.rollbacks[key] = markedRevision
+
+	if marker != nil {
+		klog.Infof("Helm release %s/%s rolled back from revision %d to %d (revision %d)",
+			revision.Namespace, revision.Release, marker.FromRevision, marker.ToRevision, marker.Revision)
+		p.timeline.Record(timeline.Event{
+			Time:      marker.RolledBackAt,
+			Type:      timeline.EventRollback,
+			Release:   revision.Release,
+			Namespace: revision.Namespace,
+			Message:   fmt.Sprintf("Rolled back from revision %d to %d", marker.FromRevision, marker.ToRevision),
+			Details: map[string]string{
+				"revision":     strconv.Itoa(marker.Revision),
+				"fromRevision": strconv.Itoa(marker.FromRevision),
+				"toRevision":   strconv.Itoa(marker.ToRevision),
+			},
+		})
+	}
+
+	for _, node := range p.graph.GetNodesByHelmRelease(revision.Release) {
+		if node.Namespace != revision.Namespace && node.Namespace != "" {
+			continue
+		}
+		// Tagged in place, so concurrent updates are kept and removed nodes are not added back
+		p.graph.UpdateNode(node.UID, func(tagged *graph.Node) bool {
+			var current *graph.RollbackMarker
+			if tagged.Metadata != nil {
+				current = tagged.Metadata.Rollback
+			}
+			if (current == nil && marker == nil) || (current != nil && marker != nil && *current == *marker) {
+				return false
+			}
+			metadata := graph.ResourceMetadata{}
+			if tagged.Metadata != nil {
+				metadata = *tagged.Metadata
+			}
+			metadata.Rollback = marker
+			tagged.Metadata = &metadata
+			return true
+		})
+	}
+}
+
+// keepRollbackMarker carries the rollback marker of a Helm-managed resource over to its
+// reprocessed node; markers are only set and cleared by trackRollback
+func (p *BaseProcessor) keepRollbackMarker(node *graph.Node) {
+	if node.HelmRelease == "" || (node.Metadata != nil && node.Metadata.Rollback != nil) {
+		return
+	}
+	existing, exists := p.graph.GetNode(node.UID)
+	if !exists || existing.Metadata == nil || existing.Metadata.Rollback == nil {
+		return
+	}
+	if node.Metadata == nil {
+		node.Metadata = &graph.ResourceMetadata{}
+	}
+	node.Metadata.Rollback = existing.Metadata.Rollback
+}
+
+// syncReleaseNode updates the node of the release a revision belongs to from the release
+// Secrets in the graph: it follows the newest revision, e.g. a failed or pending upgrade, and
+// is removed with the last Secret. A new release node is linked to the resources and release
+// Secrets of the release already in the graph; those added later link themselves.
+func (p *SecretProcessor) syncReleaseNode(revision *graph.HelmRevision) {
+	uid := graph.ReleaseNodeUID(revision.Namespace, revision.Release)
+	existing, exists := p.graph.GetNode(uid)
+
+	// The history is sorted newest first
+	var history []graph.HelmRevision
+	for _, r := range p.graph.GetReleaseHistory(revision.Release) {
+		if r.Namespace == revision.Namespace {
+			history = append(history, r)
+		}
+	}
+	if len(history) == 0 {
+		if exists {
+			klog.V(3).Infof("Helm release %s/%s has no release Secrets left, removing its node", revision.Namespace, revision.Release)
+			p.graph.RemoveNode(uid)
+		}
+		return
+	}
+
+	node := graph.NewReleaseNode(&history[0])
+	if exists && existing.Status == node.Status && existing.Metadata != nil &&
+		reflect.DeepEqual(existing.Metadata.HelmRevision, node.Metadata.HelmRevision) {
+		return
+	}
+	p.graph.AddNode(node)
+	if exists {
+		return
+	}
+
+	for _, member := range p.graph.GetNodesByHelmRelease(revision.Release) {
+		if member.UID != uid && graph.ReleaseNamespace(member) == revision.Namespace {
+			p.createEdgeIfNodeExists(member.UID, uid, graph.EdgePartOfRelease)
+		}
+	}
+	for _, r := range history {
+		if secret := p.findNodeByNamespaceKindName(r.Namespace, "Secret", r.Secret); secret != nil {
+			p.createEdgeIfNodeExists(secret.UID, uid, graph.EdgePartOfRelease)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Istio networking resources put mesh routing in the topology: VirtualServices route to the
+// Services of their destinations and are bound to Gateways, DestinationRules configure the
+// traffic to a Service, and Gateways select the gateway Pods they are served by. Istio does
+// not report a status for these resources, so they are Ready as long as they exist.
+
+// istioMeshGateway is the reserved gateway name of the sidecars of the mesh
+const istioMeshGateway = "mesh"
+
+// VirtualServiceProcessor processes Istio VirtualService resources
+type VirtualServiceProcessor struct {
+	*BaseProcessor
+}
+
+func NewVirtualServiceProcessor(g graph.GraphInterface) *VirtualServiceProcessor {
+	return &VirtualServiceProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *VirtualServiceProcessor) Process(obj interface{}, eventType EventType) error {
+	vs, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected VirtualService, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(vs, "VirtualService")
+	}
+
+	node := graph.NewNodeFromObject(vs, "VirtualService", vs.GetAPIVersion())
+	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
+	node.Metadata = &graph.ResourceMetadata{}
+	node.Metadata.Hosts, _, _ = unstructured.NestedStringSlice(vs.Object, "spec", "hosts")
+	gateways, _, _ := unstructured.NestedStringSlice(vs.Object, "spec", "gateways")
+	destinations := virtualServiceDestinations(vs)
+	node.StatusMessage = fmt.Sprintf("%d destination(s)", len(destinations))
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, vs.GetOwnerReferences())
+
+	// Edges to routes that were removed are dropped; targets that do not exist yet stay pending
+	routed := make(map[types.UID]bool)
+	for _, host := range destinations {
+		namespace, name, ok := istioServiceHost(host, vs.GetNamespace())
+		if !ok {
+			continue
+		}
+		p.createEdgeOrPending(node.UID, namespace, "Service", name, graph.EdgeIngressBackend)
+		if target := p.findNodeByNamespaceKindName(namespace, "Service", name); target != nil {
+			routed[target.UID] = true
+		}
+	}
+	for _, gateway := range gateways {
+		namespace, name := istioGatewayRef(gateway, vs.GetNamespace())
+		if name == istioMeshGateway {
+			continue
+		}
+		p.createEdgeOrPending(node.UID, namespace, "Gateway", name, graph.EdgeIngressBackend)
+		if target := p.findNodeByNamespaceKindName(namespace, "Gateway", name); target != nil {
+			routed[target.UID] = true
+		}
+	}
+	p.pruneEdges(node.UID, graph.EdgeIngressBackend, routed)
+
+	return nil
+}
+
+// virtualServiceDestinations returns the distinct destination hosts of the HTTP, TLS and TCP
+// routes of a VirtualService
+func virtualServiceDestinations(vs *unstructured.Unstructured) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, protocol := range []string{"http", "tls", "tcp"} {
+		routes, _, _ := unstructured.NestedSlice(vs.Object, "spec", protocol)
+		for _, route := range routes {
+			routeMap, ok := route.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			destinations, _, _ := unstructured.NestedSlice(routeMap, "route")
+			for _, destination := range destinations {
+				destinationMap, ok := destination.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				host, _, _ := unstructured.NestedString(destinationMap, "destination", "host")
+				if host != "" && !seen[host] {
+					seen[host] = true
+					hosts = append(hosts, host)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// istioServiceHost resolves an Istio host to a Service. Short names are relative to the
+// namespace of the resource, and <name>.<namespace>[.svc[.<cluster domain>]] names the Service
+// of another namespace. Other hosts, such as external or wildcard hosts, are not Services.
+func istioServiceHost(host, namespace string) (string, string, bool) {
+	if strings.Contains(host, "*") {
+		return "", "", false
+	}
+	labels := strings.Split(host, ".")
+	switch {
+	case len(labels) == 1:
+		return namespace, labels[0], true
+	case len(labels) == 2:
+		return labels[1], labels[0], true
+	case labels[2] == "svc":
+		return labels[1], labels[0], true
+	default:
+		return "", "", false
+	}
+}
+
+// istioGatewayRef splits a gateway reference, <namespace>/<name> or a name in the namespace
+// of the referencing resource
+func istioGatewayRef(ref, namespace string) (string, string) {
+	if gatewayNamespace, name, found := strings.Cut(ref, "/"); found {
+		return gatewayNamespace, name
+	}
+	return namespace, ref
+}
+
+// DestinationRuleProcessor processes Istio DestinationRule resources
+type DestinationRuleProcessor struct {
+	*BaseProcessor
+}
+
+func NewDestinationRuleProcessor(g graph.GraphInterface) *DestinationRuleProcessor {
+	return &DestinationRuleProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *DestinationRuleProcessor) Process(obj interface{}, eventType EventType) error {
+	rule, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected DestinationRule, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(rule, "DestinationRule")
+	}
+
+	node := graph.NewNodeFromObject(rule, "DestinationRule", rule.GetAPIVersion())
+	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
+	host, _, _ := unstructured.NestedString(rule.Object, "spec", "host")
+	subsets, _, _ := unstructured.NestedSlice(rule.Object, "spec", "subsets")
+	node.StatusMessage = fmt.Sprintf("%d subset(s)", len(subsets))
+	node.Metadata = &graph.ResourceMetadata{Hosts: []string{host}}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, rule.GetOwnerReferences())
+
+	keep := make(map[types.UID]bool)
+	if namespace, name, ok := istioServiceHost(host, rule.GetNamespace()); ok {
+		p.createEdgeOrPending(node.UID, namespace, "Service", name, graph.EdgeTrafficPolicy)
+		if target := p.findNodeByNamespaceKindName(namespace, "Service", name); target != nil {
+			keep[target.UID] = true
+		}
+	}
+	p.pruneEdges(node.UID, graph.EdgeTrafficPolicy, keep)
+
+	return nil
+}
+
+// IstioGatewayProcessor processes Istio Gateway resources
+type IstioGatewayProcessor struct {
+	*BaseProcessor
+}
+
+func NewIstioGatewayProcessor(g graph.GraphInterface) *IstioGatewayProcessor {
+	return &IstioGatewayProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *IstioGatewayProcessor) Process(obj interface{}, eventType EventType) error {
+	gateway, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("expected Gateway, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(gateway, "Gateway")
+	}
+
+	node := graph.NewNodeFromObject(gateway, "Gateway", gateway.GetAPIVersion())
+	node.Metadata = &graph.ResourceMetadata{}
+	servers, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "servers")
+	for _, server := range servers {
+		if serverMap, ok := server.(map[string]interface{}); ok {
+			hosts, _, _ := unstructured.NestedStringSlice(serverMap, "hosts")
+			node.Metadata.Hosts = append(node.Metadata.Hosts, hosts...)
+		}
+	}
+
+	// The selector matches gateway Pods in any namespace. Pods started later are linked on
+	// the next resync.
+	selector, _, _ := unstructured.NestedStringMap(gateway.Object, "spec", "selector")
+	var pods []*graph.Node
+	if len(selector) > 0 {
+		for _, candidate := range p.graph.GetAllNodes() {
+			if candidate.Kind == "Pod" && matchesSelector(candidate.Labels, selector) {
+				pods = append(pods, candidate)
+			}
+		}
+	}
+	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
+	node.StatusMessage = fmt.Sprintf("%d server(s), %d gateway pod(s)", len(servers), len(pods))
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, gateway.GetOwnerReferences())
+
+	selected := make(map[types.UID]bool, len(pods))
+	for _, pod := range pods {
+		p.createEdgeIfNodeExists(node.UID, pod.UID, graph.EdgeServiceSelector)
+		selected[pod.UID] = true
+	}
+	p.pruneEdges(node.UID, graph.EdgeServiceSelector, selected)
+
+	return nil
+}
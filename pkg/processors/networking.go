@@ -2,6 +2,7 @@ package processors
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ammarlakis/astrolabe/pkg/graph"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
@@ -9,6 +10,8 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 )
 
@@ -36,9 +39,11 @@ func (p *IngressProcessor) Process(obj interface{}, eventType EventType) error {
 	// Check if ingress has load balancer IP
 	if len(ingress.Status.LoadBalancer.Ingress) > 0 {
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonLoadBalancerReady
 		node.StatusMessage = "Ingress has load balancer"
 	} else {
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonLoadBalancerPending
 		node.StatusMessage = "Waiting for load balancer"
 	}
 
@@ -49,7 +54,7 @@ func (p *IngressProcessor) Process(obj interface{}, eventType EventType) error {
 		}
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, ingress.GetOwnerReferences())
 
 	// Create edges to Services (or add to pending if Service doesn't exist yet)
@@ -108,31 +113,84 @@ func (p *EndpointSliceProcessor) Process(obj interface{}, eventType EventType) e
 
 	if readyCount > 0 {
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonEndpointsReady
 		node.StatusMessage = fmt.Sprintf("%d ready endpoint(s)", readyCount)
 	} else {
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonNoReadyEndpoints
 		node.StatusMessage = "No ready endpoints"
 	}
 
-	p.graph.AddNode(node)
-	p.createOwnershipEdges(node, endpointSlice.GetOwnerReferences())
+	p.addNode(node, obj)
+
+	// The Service owning the slice is linked by the has-endpoints edge alone: a pair of nodes
+	// holds a single edge, and an ownership edge would replace it depending on which of the
+	// Service and the slice is processed first
+	serviceName := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	p.createOwnershipEdges(node, ownersExcept(endpointSlice.GetOwnerReferences(), "Service", serviceName))
 
 	// Create edge FROM Service TO EndpointSlice (via kubernetes.io/service-name label)
 	// We have the EndpointSlice (target) but need to wait for the Service (source)
-	if serviceName, ok := endpointSlice.Labels["kubernetes.io/service-name"]; ok {
-		p.createReverseEdgeOrPending(node.UID, endpointSlice.Namespace, "Service", serviceName, graph.EdgeServiceEndpoint)
+	if serviceName != "" {
+		p.createReverseEdgeOrPending(node.UID, endpointSlice.Namespace, "Service", serviceName, graph.EdgeHasEndpoints)
 	}
 
-	// Create edges to Pods
+	// Create edges to Pods, recording the target ports traffic is sent to, and drop the edges
+	// to Pods that are no longer endpoints
+	var metadata map[string]string
+	if ports := endpointPorts(endpointSlice.Ports); ports != "" {
+		metadata = map[string]string{graph.EdgeMetaPorts: ports}
+	}
+	keep := make(map[types.UID]bool)
 	for _, endpoint := range endpointSlice.Endpoints {
-		if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
-			p.createEdgeOrPending(node.UID, endpointSlice.Namespace, "Pod", endpoint.TargetRef.Name, graph.EdgeServiceSelector)
+		if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" {
+			continue
+		}
+		if pod := p.findNodeByNamespaceKindName(endpointSlice.Namespace, "Pod", endpoint.TargetRef.Name); pod != nil {
+			p.createEdgeWithMetadata(node.UID, pod.UID, graph.EdgeEndpointOf, metadata)
+			keep[pod.UID] = true
+		} else {
+			p.createEdgeOrPendingWithMetadata(node.UID, endpointSlice.Namespace, "Pod", endpoint.TargetRef.Name, graph.EdgeEndpointOf, metadata)
 		}
 	}
+	p.pruneEdges(node.UID, graph.EdgeEndpointOf, keep)
+	// Slices linked their Pods by selects edges before, which a graph restored from storage
+	// may still hold
+	p.pruneEdges(node.UID, graph.EdgeServiceSelector, nil)
 
 	return nil
 }
 
+// ownersExcept returns the owner references other than the one to the object of a kind and name
+func ownersExcept(owners []metav1.OwnerReference, kind, name string) []metav1.OwnerReference {
+	result := make([]metav1.OwnerReference, 0, len(owners))
+	for _, owner := range owners {
+		if owner.Kind != kind || owner.Name != name {
+			result = append(result, owner)
+		}
+	}
+	return result
+}
+
+// endpointPorts formats the ports of an EndpointSlice as name:port/protocol, comma-separated
+func endpointPorts(ports []discoveryv1.EndpointPort) string {
+	formatted := make([]string, 0, len(ports))
+	for _, port := range ports {
+		if port.Port == nil {
+			continue
+		}
+		value := fmt.Sprintf("%d", *port.Port)
+		if port.Name != nil && *port.Name != "" {
+			value = *port.Name + ":" + value
+		}
+		if port.Protocol != nil {
+			value += "/" + string(*port.Protocol)
+		}
+		formatted = append(formatted, value)
+	}
+	return strings.Join(formatted, ",")
+}
+
 // StorageClassProcessor processes StorageClass resources
 type StorageClassProcessor struct {
 	*BaseProcessor
@@ -154,13 +212,101 @@ func (p *StorageClassProcessor) Process(obj interface{}, eventType EventType) er
 
 	node := graph.NewNodeFromObject(sc, "StorageClass", "storage.k8s.io/v1")
 	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
 	node.StatusMessage = "StorageClass exists"
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
+
+	// Create edge to the CSI driver provisioning the volumes of the class (in-tree and
+	// external provisioners have no CSIDriver object, so their edge stays pending)
+	if sc.Provisioner != "" {
+		p.createEdgeOrPending(node.UID, "", "CSIDriver", sc.Provisioner, graph.EdgeProvisionedBy)
+	}
+
+	return nil
+}
+
+// CSIDriverProcessor processes CSIDriver resources
+type CSIDriverProcessor struct {
+	*BaseProcessor
+}
+
+func NewCSIDriverProcessor(g graph.GraphInterface) *CSIDriverProcessor {
+	return &CSIDriverProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *CSIDriverProcessor) Process(obj interface{}, eventType EventType) error {
+	driver, ok := obj.(*storagev1.CSIDriver)
+	if !ok {
+		return fmt.Errorf("expected CSIDriver, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(driver, "CSIDriver")
+	}
+
+	node := graph.NewNodeFromObject(driver, "CSIDriver", "storage.k8s.io/v1")
+	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
+	node.StatusMessage = "CSIDriver exists"
+
+	p.addNode(node, obj)
 
 	return nil
 }
 
+// VolumeAttachmentProcessor processes VolumeAttachment resources
+type VolumeAttachmentProcessor struct {
+	*BaseProcessor
+}
+
+func NewVolumeAttachmentProcessor(g graph.GraphInterface) *VolumeAttachmentProcessor {
+	return &VolumeAttachmentProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+func (p *VolumeAttachmentProcessor) Process(obj interface{}, eventType EventType) error {
+	attachment, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok {
+		return fmt.Errorf("expected VolumeAttachment, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(attachment, "VolumeAttachment")
+	}
+
+	node := graph.NewNodeFromObject(attachment, "VolumeAttachment", "storage.k8s.io/v1")
+	node.Status, node.StatusReason, node.StatusMessage = p.getAttachmentStatus(attachment)
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, attachment.GetOwnerReferences())
+
+	// Create edges to the attached PersistentVolume and to the Node it is attached to
+	if pvName := attachment.Spec.Source.PersistentVolumeName; pvName != nil && *pvName != "" {
+		p.createEdgeOrPending(node.UID, "", "PersistentVolume", *pvName, graph.EdgeAttaches)
+	}
+	if attachment.Spec.NodeName != "" {
+		p.createEdgeOrPending(node.UID, "", "Node", attachment.Spec.NodeName, graph.EdgeAttachedTo)
+	}
+
+	return nil
+}
+
+// getAttachmentStatus reports the attach and detach errors of the CSI attacher, which
+// otherwise only show up as mount timeouts of the Pods using the volume
+func (p *VolumeAttachmentProcessor) getAttachmentStatus(attachment *storagev1.VolumeAttachment) (graph.ResourceStatus, string, string) {
+	status := attachment.Status
+	switch {
+	case status.DetachError != nil:
+		return graph.StatusError, graph.ReasonDetachFailed, fmt.Sprintf("Detach failed: %s", status.DetachError.Message)
+	case status.AttachError != nil:
+		return graph.StatusError, graph.ReasonAttachFailed, fmt.Sprintf("Attach failed: %s", status.AttachError.Message)
+	case status.Attached:
+		return graph.StatusReady, graph.ReasonVolumeAttached, fmt.Sprintf("Attached to %s", attachment.Spec.NodeName)
+	default:
+		return graph.StatusPending, graph.ReasonVolumeAttaching, fmt.Sprintf("Attaching to %s", attachment.Spec.NodeName)
+	}
+}
+
 // HPAProcessor processes HorizontalPodAutoscaler resources
 type HPAProcessor struct {
 	*BaseProcessor
@@ -193,9 +339,11 @@ func (p *HPAProcessor) Process(obj interface{}, eventType EventType) error {
 
 	if ableToScale {
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonAbleToScale
 		node.StatusMessage = fmt.Sprintf("Scaling: %d/%d replicas", hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas)
 	} else {
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonUnableToScale
 		node.StatusMessage = "Unable to scale"
 	}
 
@@ -211,7 +359,7 @@ func (p *HPAProcessor) Process(obj interface{}, eventType EventType) error {
 		DesiredReplicas: hpa.Status.DesiredReplicas,
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, hpa.GetOwnerReferences())
 
 	// Create edge to scale target
@@ -244,13 +392,15 @@ func (p *PDBProcessor) Process(obj interface{}, eventType EventType) error {
 	// Check PDB status
 	if pdb.Status.CurrentHealthy >= pdb.Status.DesiredHealthy {
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonDisruptionBudgetMet
 		node.StatusMessage = fmt.Sprintf("Healthy: %d/%d", pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy)
 	} else {
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonInsufficientHealthyPods
 		node.StatusMessage = fmt.Sprintf("Unhealthy: %d/%d", pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy)
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, pdb.GetOwnerReferences())
 
 	// Create edges to Pods via selector
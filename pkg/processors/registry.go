@@ -1,7 +1,14 @@
 package processors
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/audit"
 	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 )
 
@@ -19,64 +26,261 @@ type Processor interface {
 	Process(obj interface{}, eventType EventType) error
 }
 
-// ProcessorRegistry manages all resource processors
-type ProcessorRegistry struct {
-	graph      graph.GraphInterface
-	processors map[string]Processor
+// processorFactory creates the processor of a resource kind
+type processorFactory struct {
+	kind string
+	new  func(g graph.GraphInterface) Processor
 }
 
-// NewProcessorRegistry creates a new processor registry
-func NewProcessorRegistry(g graph.GraphInterface) *ProcessorRegistry {
-	registry := &ProcessorRegistry{
-		graph:      g,
-		processors: make(map[string]Processor),
+// processorFactories lists every supported kind and its processor
+var processorFactories = []processorFactory{
+	// Core resources
+	{"Pod", func(g graph.GraphInterface) Processor { return NewPodProcessor(g) }},
+	{"Service", func(g graph.GraphInterface) Processor { return NewServiceProcessor(g) }},
+	{"ServiceAccount", func(g graph.GraphInterface) Processor { return NewServiceAccountProcessor(g) }},
+	{"ConfigMap", func(g graph.GraphInterface) Processor { return NewConfigMapProcessor(g) }},
+	{"Secret", func(g graph.GraphInterface) Processor { return NewSecretProcessor(g) }},
+	{"PersistentVolumeClaim", func(g graph.GraphInterface) Processor { return NewPVCProcessor(g) }},
+	{"PersistentVolume", func(g graph.GraphInterface) Processor { return NewPVProcessor(g) }},
+	{"Namespace", func(g graph.GraphInterface) Processor { return NewNamespaceProcessor(g) }},
+	{"Node", func(g graph.GraphInterface) Processor { return NewNodeProcessor(g) }},
+
+	{"Deployment", func(g graph.GraphInterface) Processor { return NewDeploymentProcessor(g) }},
+	{"StatefulSet", func(g graph.GraphInterface) Processor { return NewStatefulSetProcessor(g) }},
+	{"DaemonSet", func(g graph.GraphInterface) Processor { return NewDaemonSetProcessor(g) }},
+	{"ReplicaSet", func(g graph.GraphInterface) Processor { return NewReplicaSetProcessor(g) }},
+	{"ControllerRevision", func(g graph.GraphInterface) Processor { return NewControllerRevisionProcessor(g) }},
+
+	{"Job", func(g graph.GraphInterface) Processor { return NewJobProcessor(g) }},
+	{"CronJob", func(g graph.GraphInterface) Processor { return NewCronJobProcessor(g) }},
+
+	{"Ingress", func(g graph.GraphInterface) Processor { return NewIngressProcessor(g) }},
+	{"EndpointSlice", func(g graph.GraphInterface) Processor { return NewEndpointSliceProcessor(g) }},
+
+	{"StorageClass", func(g graph.GraphInterface) Processor { return NewStorageClassProcessor(g) }},
+	{"CSIDriver", func(g graph.GraphInterface) Processor { return NewCSIDriverProcessor(g) }},
+	{"VolumeAttachment", func(g graph.GraphInterface) Processor { return NewVolumeAttachmentProcessor(g) }},
+
+	{"HorizontalPodAutoscaler", func(g graph.GraphInterface) Processor { return NewHPAProcessor(g) }},
+
+	{"PodDisruptionBudget", func(g graph.GraphInterface) Processor { return NewPDBProcessor(g) }},
+
+	// Custom resources (watched through dynamic informers when the CRD is installed)
+	{"Application", func(g graph.GraphInterface) Processor { return NewArgoApplicationProcessor(g) }},
+	{"Kustomization", func(g graph.GraphInterface) Processor { return NewFluxKustomizationProcessor(g) }},
+	{"HelmRelease", func(g graph.GraphInterface) Processor { return NewFluxHelmReleaseProcessor(g) }},
+	{"Machine", func(g graph.GraphInterface) Processor { return NewMachineProcessor(g) }},
+	{"MachineSet", func(g graph.GraphInterface) Processor { return NewMachineSetProcessor(g) }},
+	{"MachineDeployment", func(g graph.GraphInterface) Processor { return NewMachineDeploymentProcessor(g) }},
+	{"VirtualService", func(g graph.GraphInterface) Processor { return NewVirtualServiceProcessor(g) }},
+	{"DestinationRule", func(g graph.GraphInterface) Processor { return NewDestinationRuleProcessor(g) }},
+	{"Gateway", func(g graph.GraphInterface) Processor { return NewIstioGatewayProcessor(g) }},
+	{"Certificate", func(g graph.GraphInterface) Processor { return NewCertificateProcessor(g) }},
+	{"CertificateRequest", func(g graph.GraphInterface) Processor { return NewCertificateRequestProcessor(g) }},
+	{"Issuer", func(g graph.GraphInterface) Processor { return NewCertManagerIssuerProcessor(g, "Issuer") }},
+	{"ClusterIssuer", func(g graph.GraphInterface) Processor { return NewCertManagerIssuerProcessor(g, "ClusterIssuer") }},
+}
+
+// SupportedKinds returns all kinds that have a processor
+func SupportedKinds() []string {
+	kinds := make([]string, 0, len(processorFactories))
+	for _, factory := range processorFactories {
+		kinds = append(kinds, factory.kind)
 	}
+	return kinds
+}
 
-	// Register all processors
-	registry.registerProcessors()
+// KindFilter selects which resource kinds are watched.
+// An empty Watch list means all supported kinds; Exclude always wins.
+type KindFilter struct {
+	Watch   []string
+	Exclude []string
+}
 
-	return registry
+// Validate checks that all kinds in the filter are supported
+func (f KindFilter) Validate() error {
+	for _, kind := range append(append([]string{}, f.Watch...), f.Exclude...) {
+		if canonicalKind(kind) == "" {
+			return fmt.Errorf("unsupported kind %q (supported: %s)", kind, strings.Join(SupportedKinds(), ", "))
+		}
+	}
+	return nil
 }
 
-// registerProcessors registers all resource type processors
-func (r *ProcessorRegistry) registerProcessors() {
-	// Core resources
-	type pair struct {
-		kind      string
-		processor Processor
+// Enabled reports whether a kind passes the filter
+func (f KindFilter) Enabled(kind string) bool {
+	for _, excluded := range f.Exclude {
+		if strings.EqualFold(excluded, kind) {
+			return false
+		}
+	}
+	if len(f.Watch) == 0 {
+		return true
+	}
+	for _, watched := range f.Watch {
+		if strings.EqualFold(watched, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledKinds returns the supported kinds that pass the filter
+func (f KindFilter) EnabledKinds() []string {
+	kinds := make([]string, 0, len(processorFactories))
+	for _, kind := range SupportedKinds() {
+		if f.Enabled(kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// canonicalKind returns the supported kind matching name case-insensitively, or ""
+func canonicalKind(name string) string {
+	for _, kind := range SupportedKinds() {
+		if strings.EqualFold(kind, name) {
+			return kind
+		}
 	}
+	return ""
+}
+
+// CascadeMode controls what happens to owned resources when their owner is deleted
+type CascadeMode string
+
+const (
+	// CascadeNone leaves children in the graph until their own delete events arrive
+	CascadeNone CascadeMode = "none"
+	// CascadeMark flags children as awaiting garbage collection
+	CascadeMark CascadeMode = "mark"
+	// CascadeRemove removes children immediately, as background garbage collection will
+	CascadeRemove CascadeMode = "remove"
+)
+
+// ParseCascadeMode parses a cascade mode flag value
+func ParseCascadeMode(value string) (CascadeMode, error) {
+	switch mode := CascadeMode(strings.ToLower(value)); mode {
+	case "", CascadeNone:
+		return CascadeNone, nil
+	case CascadeMark, CascadeRemove:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid cascade mode %q (expected none, mark or remove)", value)
+	}
+}
+
+// Options configures the processor registry
+type Options struct {
+	// Kinds selects the kinds that get a processor
+	Kinds KindFilter
+	// CascadeDelete controls handling of children when an owner is deleted
+	CascadeDelete CascadeMode
+	// Status overrides the built-in status of the processors with rules and kstatus
+	Status *StatusEngine
+	// Enrichers run on every node before it is added to the graph, in order
+	Enrichers []NodeEnricher
+	// Observers are told about every processed change of a node
+	Observers []ChangeObserver
+	// Timeline records release events such as rollbacks (optional)
+	Timeline *timeline.Timeline
+	// Audit records the graph mutations of every event with the event (optional)
+	Audit *audit.Log
+	// KeepInactiveReplicaSets is the number of inactive ReplicaSets, i.e. previous rollout
+	// revisions, kept per Deployment (0 keeps none)
+	KeepInactiveReplicaSets int
+	// PrunedJobs are the finished Jobs pruned from the graph, skipped with their Pods (optional)
+	PrunedJobs PrunedJobs
+	// CustomResources are the custom resource kinds watched without a dedicated processor,
+	// whose edges are inferred (see custom.go). They are watched whatever Kinds selects.
+	CustomResources []CustomResource
+}
 
-	processors := []pair{
-		{"Pod", NewPodProcessor(r.graph)},
-		{"Service", NewServiceProcessor(r.graph)},
-		{"ServiceAccount", NewServiceAccountProcessor(r.graph)},
-		{"ConfigMap", NewConfigMapProcessor(r.graph)},
-		{"Secret", NewSecretProcessor(r.graph)},
-		{"PersistentVolumeClaim", NewPVCProcessor(r.graph)},
-		{"PersistentVolume", NewPVProcessor(r.graph)},
-		{"Namespace", NewNamespaceProcessor(r.graph)},
+// PrunedJobs tells the Job and Pod processors which finished Jobs were pruned from the graph
+// (see graph.JobPruner), so informer resyncs do not add them back
+type PrunedJobs interface {
+	Pruned(uid types.UID) bool
+	Forget(uid types.UID)
+}
 
-		{"Deployment", NewDeploymentProcessor(r.graph)},
-		{"StatefulSet", NewStatefulSetProcessor(r.graph)},
-		{"DaemonSet", NewDaemonSetProcessor(r.graph)},
-		{"ReplicaSet", NewReplicaSetProcessor(r.graph)},
+// ChangeObserver is notified after an event changed a node. old is nil for new nodes and
+// updated is nil for deleted nodes.
+type ChangeObserver interface {
+	NodeChanged(old, updated *graph.Node)
+}
 
-		{"Job", NewJobProcessor(r.graph)},
-		{"CronJob", NewCronJobProcessor(r.graph)},
+// ProcessorRegistry manages all resource processors
+type ProcessorRegistry struct {
+	graph         graph.GraphInterface
+	processors    map[string]Processor
+	cascadeDelete CascadeMode
+	observers     []ChangeObserver
+	audit         *audit.Graph
+}
 
-		{"Ingress", NewIngressProcessor(r.graph)},
-		{"EndpointSlice", NewEndpointSliceProcessor(r.graph)},
+// NewProcessorRegistry creates a new processor registry for the kinds enabled in the options
+func NewProcessorRegistry(g graph.GraphInterface, opts Options) *ProcessorRegistry {
+	var audited *audit.Graph
+	if opts.Audit != nil {
+		audited = opts.Audit.Graph(g, audit.OriginEvent)
+		g = audited
+	}
 
-		{"StorageClass", NewStorageClassProcessor(r.graph)},
+	registry := &ProcessorRegistry{
+		graph:         g,
+		processors:    make(map[string]Processor),
+		cascadeDelete: opts.CascadeDelete,
+		observers:     opts.Observers,
+		audit:         audited,
+	}
 
-		{"HorizontalPodAutoscaler", NewHPAProcessor(r.graph)},
+	var custom []*CustomResourceProcessor
+	for _, resource := range opts.CustomResources {
+		processor := NewCustomResourceProcessor(g, resource)
+		custom = append(custom, processor)
+		registry.processors[resource.Kind] = processor
+	}
+	for _, factory := range processorFactories {
+		if !opts.Kinds.Enabled(factory.kind) {
+			continue
+		}
+		registry.processors[factory.kind] = factory.new(g)
+	}
 
-		{"PodDisruptionBudget", NewPDBProcessor(r.graph)},
+	// Custom resources are linked to the objects of every watched kind
+	kinds := make([]string, 0, len(registry.processors))
+	clusterScoped := make(map[string]bool, len(clusterScopedKinds))
+	for kind := range clusterScopedKinds {
+		clusterScoped[kind] = true
+	}
+	for _, resource := range opts.CustomResources {
+		clusterScoped[resource.Kind] = resource.ClusterScoped
+	}
+	for kind := range registry.processors {
+		kinds = append(kinds, kind)
+	}
+	for _, processor := range custom {
+		processor.setReferenceableKinds(kinds, clusterScoped)
 	}
 
-	for _, processor := range processors {
-		r.processors[processor.kind] = processor.processor
+	for _, processor := range registry.processors {
+		if p, ok := processor.(baseProcessor); ok {
+			p.base().status = opts.Status
+			p.base().enrichers = opts.Enrichers
+			p.base().timeline = opts.Timeline
+		}
+		if p, ok := processor.(*ReplicaSetProcessor); ok {
+			p.keepInactive = opts.KeepInactiveReplicaSets
+		}
+		if p, ok := processor.(*JobProcessor); ok {
+			p.pruned = opts.PrunedJobs
+		}
+		if p, ok := processor.(*PodProcessor); ok {
+			p.prunedJobs = opts.PrunedJobs
+		}
 	}
+
+	return registry
 }
 
 // Process processes a resource event
@@ -87,7 +291,69 @@ func (r *ProcessorRegistry) Process(obj interface{}, kind string, eventType Even
 		return
 	}
 
+	metaObj, isMeta := obj.(v1.Object)
+	var id types.UID
+	if isMeta {
+		id = graph.ObjectID(metaObj.GetUID(), metaObj.GetNamespace(), kind, metaObj.GetName())
+	}
+
+	// Descendants must be looked up before the owner and its edges are removed
+	var orphans []*graph.Node
+	if isMeta && eventType == EventDelete && r.cascadeDelete != CascadeNone && r.cascadeDelete != "" {
+		orphans = r.graph.OwnedDescendants(id)
+	}
+
+	if r.audit != nil {
+		r.audit.SetEvent(kind, id, string(eventType))
+		defer r.audit.ClearEvent()
+	}
+
+	var old *graph.Node
+	if isMeta && len(r.observers) > 0 {
+		old, _ = r.graph.GetNode(id)
+	}
+
 	if err := processor.Process(obj, eventType); err != nil {
 		klog.Errorf("Failed to process %s event for %s: %v", eventType, kind, err)
+		return
+	}
+
+	if isMeta && len(r.observers) > 0 {
+		updated, exists := r.graph.GetNode(id)
+		if !exists {
+			updated = nil
+		}
+		r.notify(old, updated)
+	}
+
+	r.cascade(orphans)
+}
+
+func (r *ProcessorRegistry) notify(old, updated *graph.Node) {
+	if old == nil && updated == nil {
+		return
+	}
+	for _, observer := range r.observers {
+		observer.NodeChanged(old, updated)
+	}
+}
+
+// cascade marks or removes children of a deleted owner, mimicking background garbage collection
+func (r *ProcessorRegistry) cascade(orphans []*graph.Node) {
+	for _, orphan := range orphans {
+		switch r.cascadeDelete {
+		case CascadeRemove:
+			klog.V(3).Infof("Cascade removing %s/%s (owner deleted)", orphan.Kind, orphan.Name)
+			r.graph.RemoveNode(orphan.UID)
+			r.notify(orphan, nil)
+		case CascadeMark:
+			// Other workers may have updated or removed the orphan since it was listed
+			r.graph.UpdateNode(orphan.UID, func(node *graph.Node) bool {
+				node.Status = graph.StatusPending
+				node.StatusReason = graph.ReasonOwnerDeleted
+				node.StatusMessage = "Owner deleted, awaiting garbage collection"
+				return true
+			})
+		}
 	}
 }
@@ -0,0 +1,209 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Rollout revisions are the ReplicaSets of Deployments and the ControllerRevisions of
+// StatefulSets and DaemonSets. Their Pod templates are recorded so the API can tell what
+// changed between revisions. Only recent revisions are kept in the graph: the active
+// ReplicaSets plus the latest inactive ones (one by default, see
+// Options.KeepInactiveReplicaSets), and the two latest ControllerRevisions.
+
+// controllerRevisionsKept is the number of ControllerRevisions kept per workload
+const controllerRevisionsKept = 2
+
+// templateContainers describes the containers of a Pod template
+func templateContainers(spec *corev1.PodSpec) []graph.ContainerInfo {
+	containers := make([]graph.ContainerInfo, 0, len(spec.InitContainers)+len(spec.Containers))
+	add := func(c corev1.Container, init bool) {
+		info := graph.ContainerInfo{
+			Name:     c.Name,
+			Image:    c.Image,
+			Init:     init,
+			Requests: resourceStrings(c.Resources.Requests),
+			Limits:   resourceStrings(c.Resources.Limits),
+		}
+		for _, env := range c.Env {
+			info.Env = append(info.Env, env.Name)
+		}
+		containers = append(containers, info)
+	}
+	for _, c := range spec.InitContainers {
+		add(c, true)
+	}
+	for _, c := range spec.Containers {
+		add(c, false)
+	}
+	return containers
+}
+
+// revisionController returns the workload controlling a revision as <Kind>/<name>, or ""
+func revisionController(obj v1.Object) string {
+	if owner := v1.GetControllerOf(obj); owner != nil {
+		return owner.Kind + "/" + owner.Name
+	}
+	return ""
+}
+
+// revisionSiblings returns the other revisions of a kind belonging to the same workload
+func (p *BaseProcessor) revisionSiblings(node *graph.Node) []*graph.Node {
+	var siblings []*graph.Node
+	for _, other := range p.graph.GetNodesByNamespaceKind(node.Namespace, node.Kind) {
+		if other.UID != node.UID && other.Metadata != nil && other.Metadata.Controller == node.Metadata.Controller {
+			siblings = append(siblings, other)
+		}
+	}
+	return siblings
+}
+
+// ReplicaSetProcessor helpers
+
+// replicaSetRevision returns the Deployment revision of a ReplicaSet, 0 if unknown
+func replicaSetRevision(rs *appsv1.ReplicaSet) int64 {
+	revision, _ := strconv.ParseInt(rs.Annotations["deployment.kubernetes.io/revision"], 10, 64)
+	return revision
+}
+
+func replicaSetInactive(rs *appsv1.ReplicaSet) bool {
+	return rs.Status.Replicas == 0 && rs.Status.ReadyReplicas == 0
+}
+
+func nodeInactive(node *graph.Node) bool {
+	return node.Metadata != nil && node.Metadata.Replicas != nil && node.Metadata.Replicas.Current == 0 && node.Metadata.Replicas.Ready == 0
+}
+
+// keepInactiveReplicaSet reports whether an inactive ReplicaSet is one of the latest
+// keepInactive inactive ones of its Deployment, i.e. the previous rollout revisions, and
+// removes the older inactive ones
+func (p *ReplicaSetProcessor) keepInactiveReplicaSet(node *graph.Node) bool {
+	if p.keepInactive <= 0 || node.Metadata.Controller == "" || node.Metadata.Revision == 0 {
+		return false
+	}
+
+	var inactive []*graph.Node
+	newer := 0
+	for _, sibling := range p.revisionSiblings(node) {
+		if !nodeInactive(sibling) {
+			continue
+		}
+		if sibling.Metadata.Revision > node.Metadata.Revision {
+			newer++
+		}
+		inactive = append(inactive, sibling)
+	}
+	if newer >= p.keepInactive {
+		return false
+	}
+
+	sort.Slice(inactive, func(i, j int) bool { return inactive[i].Metadata.Revision > inactive[j].Metadata.Revision })
+	kept := 1 // this revision
+	for _, sibling := range inactive {
+		if sibling.Metadata.Revision > node.Metadata.Revision || kept < p.keepInactive {
+			kept++
+			continue
+		}
+		klog.V(4).Infof("Dropping older inactive ReplicaSet %s/%s (revision %d)", sibling.Namespace, sibling.Name, sibling.Metadata.Revision)
+		p.graph.RemoveNode(sibling.UID)
+	}
+	return true
+}
+
+// createRevisionEdge links a ReplicaSet to the Deployment it is a rollout revision of, with
+// its revision number
+func (p *ReplicaSetProcessor) createRevisionEdge(node *graph.Node, rs *appsv1.ReplicaSet) {
+	owner := v1.GetControllerOf(rs)
+	if owner == nil || owner.Kind != "Deployment" || node.Metadata.Revision == 0 {
+		return
+	}
+	p.createEdgeOrPendingWithMetadata(node.UID, rs.Namespace, "Deployment", owner.Name, graph.EdgeRevisionOf,
+		map[string]string{graph.EdgeMetaRevision: strconv.FormatInt(node.Metadata.Revision, 10)})
+}
+
+// ControllerRevisionProcessor processes the ControllerRevisions of StatefulSets and DaemonSets
+type ControllerRevisionProcessor struct {
+	*BaseProcessor
+}
+
+func NewControllerRevisionProcessor(g graph.GraphInterface) *ControllerRevisionProcessor {
+	return &ControllerRevisionProcessor{BaseProcessor: NewBaseProcessor(g)}
+}
+
+// controllerRevisionData is the Pod template patch stored in the revisions of StatefulSets
+// and DaemonSets
+type controllerRevisionData struct {
+	Spec struct {
+		Template corev1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+func (p *ControllerRevisionProcessor) Process(obj interface{}, eventType EventType) error {
+	revision, ok := obj.(*appsv1.ControllerRevision)
+	if !ok {
+		return fmt.Errorf("expected ControllerRevision, got %T", obj)
+	}
+
+	if eventType == EventDelete {
+		return p.handleDelete(revision, "ControllerRevision")
+	}
+
+	node := graph.NewNodeFromObject(revision, "ControllerRevision", "apps/v1")
+	node.Status = graph.StatusReady
+	node.StatusReason = graph.ReasonExists
+	node.StatusMessage = fmt.Sprintf("Revision %d", revision.Revision)
+	node.Metadata = &graph.ResourceMetadata{
+		Revision:     revision.Revision,
+		TemplateHash: revision.Labels["controller.kubernetes.io/hash"],
+		Controller:   revisionController(revision),
+	}
+	if node.Metadata.Controller == "" {
+		// Revisions of other controllers do not describe Pod templates
+		return nil
+	}
+
+	var data controllerRevisionData
+	if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+		klog.V(2).Infof("Failed to decode ControllerRevision %s/%s: %v", revision.Namespace, revision.Name, err)
+	} else {
+		node.Metadata.Containers = templateContainers(&data.Spec.Template.Spec)
+	}
+
+	// Keep the latest revisions only
+	siblings := p.revisionSiblings(node)
+	newer := 0
+	for _, sibling := range siblings {
+		if sibling.Metadata.Revision > node.Metadata.Revision {
+			newer++
+		}
+	}
+	if newer >= controllerRevisionsKept {
+		if _, exists := p.graph.GetNode(node.UID); exists {
+			p.graph.RemoveNode(node.UID)
+		}
+		return nil
+	}
+
+	p.addNode(node, obj)
+	p.createOwnershipEdges(node, revision.GetOwnerReferences())
+
+	sort.Slice(siblings, func(i, j int) bool { return siblings[i].Metadata.Revision > siblings[j].Metadata.Revision })
+	kept := 1 // this revision
+	for _, sibling := range siblings {
+		if sibling.Metadata.Revision > node.Metadata.Revision || kept < controllerRevisionsKept {
+			kept++
+			continue
+		}
+		p.graph.RemoveNode(sibling.UID)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// StatusResult is the status of a node with its reason and message
+type StatusResult struct {
+	Status  graph.ResourceStatus
+	Reason  string
+	Message string
+}
+
+// StatusEvaluator computes the status of a node from its raw object. ok is false when the
+// evaluator has no opinion on the object.
+type StatusEvaluator interface {
+	EvaluateStatus(node *graph.Node, raw map[string]interface{}) (result StatusResult, ok bool)
+}
+
+// StatusRule sets the status of objects of a kind when a JSONPath expression matches
+type StatusRule struct {
+	Kind     string
+	JSONPath string
+	// Equals is the value the expression must produce; empty matches any non-empty result
+	Equals  string
+	Status  graph.ResourceStatus
+	Reason  string
+	Message string
+}
+
+type compiledRule struct {
+	StatusRule
+	path jsonPath
+}
+
+// StatusEngine chooses the status of nodes. The processors set the built-in status of their
+// kind; the engine then applies, in order of precedence:
+//   - the first matching user rule of the kind
+//   - kstatus evaluation, for kinds configured to use it
+//
+// Without rules or kstatus kinds for a kind, the built-in status is kept.
+type StatusEngine struct {
+	rules   map[string][]compiledRule // kind -> rules, in configured order
+	kstatus []string                  // kind patterns evaluated with kstatus
+}
+
+// NewStatusEngine compiles the status rules. kstatusKinds are kind names or patterns (e.g. *)
+// whose status follows the kstatus conventions instead of the built-in logic.
+func NewStatusEngine(rules []StatusRule, kstatusKinds []string) (*StatusEngine, error) {
+	e := &StatusEngine{rules: make(map[string][]compiledRule)}
+
+	for _, rule := range rules {
+		if rule.Kind == "" || rule.JSONPath == "" {
+			return nil, fmt.Errorf("status rule requires kind and jsonPath: %+v", rule)
+		}
+		switch rule.Status {
+		case graph.StatusReady, graph.StatusPending, graph.StatusError, graph.StatusUnknown:
+		default:
+			return nil, fmt.Errorf("invalid status %q in status rule for %s (expected Ready, Pending, Error or Unknown)", rule.Status, rule.Kind)
+		}
+
+		jp, err := parseJSONPath(rule.Kind, rule.JSONPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonPath in status rule for %s: %w", rule.Kind, err)
+		}
+		e.rules[rule.Kind] = append(e.rules[rule.Kind], compiledRule{StatusRule: rule, path: jp})
+	}
+
+	for _, kind := range kstatusKinds {
+		if _, err := path.Match(kind, ""); err != nil {
+			return nil, fmt.Errorf("invalid kstatus kind pattern %q: %w", kind, err)
+		}
+		e.kstatus = append(e.kstatus, kind)
+	}
+
+	return e, nil
+}
+
+// apply overrides the built-in status of a node with the configured evaluators
+func (e *StatusEngine) apply(node *graph.Node, obj interface{}) {
+	if e == nil || (len(e.rules[node.Kind]) == 0 && !e.usesKStatus(node.Kind)) {
+		return
+	}
+
+	raw, err := rawObject(obj)
+	if err != nil {
+		klog.V(2).Infof("Failed to convert %s/%s for status evaluation: %v", node.Kind, node.Name, err)
+		return
+	}
+
+	for _, evaluator := range e.evaluators(node.Kind) {
+		if result, ok := evaluator.EvaluateStatus(node, raw); ok {
+			node.Status = result.Status
+			node.StatusReason = result.Reason
+			node.StatusMessage = result.Message
+			return
+		}
+	}
+}
+
+// evaluators returns the evaluators of a kind, in order of precedence
+func (e *StatusEngine) evaluators(kind string) []StatusEvaluator {
+	var evaluators []StatusEvaluator
+	for i := range e.rules[kind] {
+		evaluators = append(evaluators, &e.rules[kind][i])
+	}
+	if e.usesKStatus(kind) {
+		evaluators = append(evaluators, KStatus{})
+	}
+	return evaluators
+}
+
+func (e *StatusEngine) usesKStatus(kind string) bool {
+	for _, pattern := range e.kstatus {
+		if matched, _ := path.Match(pattern, kind); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluateStatus applies the rule if its expression matches
+func (r *compiledRule) EvaluateStatus(node *graph.Node, raw map[string]interface{}) (StatusResult, bool) {
+	var buf bytes.Buffer
+	if err := r.path.Execute(&buf, raw); err != nil {
+		klog.V(4).Infof("Status rule for %s not applicable to %s: %v", r.Kind, node.Name, err)
+		return StatusResult{}, false
+	}
+
+	value := buf.String()
+	if (r.Equals == "" && value == "") || (r.Equals != "" && value != r.Equals) {
+		return StatusResult{}, false
+	}
+
+	return StatusResult{
+		Status:  r.Status,
+		Reason:  r.Reason,
+		Message: valueOr(r.Message, valueOr(r.Reason, "Matched status rule")),
+	}, true
+}
+
+// KStatus evaluates the status of any object, including custom resources, following the
+// kstatus conventions (https://github.com/kubernetes-sigs/cli-utils/tree/master/pkg/kstatus):
+// deleted objects are terminating, objects whose latest generation was not observed yet are in
+// progress, and otherwise the Stalled, Reconciling and Ready conditions decide. Objects without
+// these conditions are current.
+type KStatus struct{}
+
+// EvaluateStatus always returns a status
+func (KStatus) EvaluateStatus(node *graph.Node, raw map[string]interface{}) (StatusResult, bool) {
+	obj := &unstructured.Unstructured{Object: raw}
+
+	if obj.GetDeletionTimestamp() != nil {
+		return StatusResult{graph.StatusPending, graph.ReasonTerminating, "Resource is being deleted"}, true
+	}
+
+	if observed, found, _ := unstructured.NestedInt64(raw, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return StatusResult{graph.StatusPending, graph.ReasonGenerationNotObserved,
+			fmt.Sprintf("Generation %d not observed yet (observed %d)", obj.GetGeneration(), observed)}, true
+	}
+
+	if stalled := condition(raw, "Stalled"); stalled != nil && conditionStatus(stalled) == "True" {
+		reason, message := conditionDetails(stalled, graph.ReasonStalled)
+		return StatusResult{graph.StatusError, reason, message}, true
+	}
+	if reconciling := condition(raw, "Reconciling"); reconciling != nil && conditionStatus(reconciling) == "True" {
+		reason, message := conditionDetails(reconciling, graph.ReasonReconciling)
+		return StatusResult{graph.StatusPending, reason, message}, true
+	}
+	if ready := condition(raw, "Ready"); ready != nil {
+		reason, message := conditionDetails(ready, graph.ReasonCurrent)
+		switch conditionStatus(ready) {
+		case "True":
+			return StatusResult{graph.StatusReady, reason, message}, true
+		case "False":
+			return StatusResult{graph.StatusError, reason, message}, true
+		default:
+			return StatusResult{graph.StatusPending, reason, message}, true
+		}
+	}
+
+	return StatusResult{graph.StatusReady, graph.ReasonCurrent, "Resource is current"}, true
+}
+
+// condition returns the status condition of a type, or nil
+func condition(raw map[string]interface{}, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(raw, "status", "conditions")
+	for _, item := range conditions {
+		if c, ok := item.(map[string]interface{}); ok && c["type"] == conditionType {
+			return c
+		}
+	}
+	return nil
+}
+
+func conditionStatus(c map[string]interface{}) string {
+	status, _ := c["status"].(string)
+	return status
+}
+
+// conditionDetails returns the reason and message of a condition, with defaults for both
+func conditionDetails(c map[string]interface{}, defaultReason string) (string, string) {
+	reason, _ := c["reason"].(string)
+	message, _ := c["message"].(string)
+	reason = valueOr(reason, defaultReason)
+	return reason, valueOr(message, reason)
+}
+
+// rawObject returns the unstructured content of a typed or unstructured object
+func rawObject(obj interface{}) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
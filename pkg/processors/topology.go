@@ -0,0 +1,128 @@
+package processors
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The failure domain of Nodes is read from their well-known topology labels, falling back to
+// the deprecated failure-domain.beta ones. Pods take the zone and region of the Node they are
+// scheduled on, and PersistentVolumes those of their labels, set by the volume provisioners,
+// or of their node affinity. When a Node is added or its topology changes, it is propagated to
+// the Pods scheduled on it and the local volumes pinned to it.
+
+// zoneSeparator joins the zones of a volume available in several zones, as provisioners do in
+// the zone label of regional volumes
+const zoneSeparator = "__"
+
+// topologyOf returns the zone and region of the topology labels of an object
+func topologyOf(labels map[string]string) (string, string) {
+	zone := valueOr(labels[corev1.LabelTopologyZone], labels[corev1.LabelFailureDomainBetaZone])
+	region := valueOr(labels[corev1.LabelTopologyRegion], labels[corev1.LabelFailureDomainBetaRegion])
+	return zone, region
+}
+
+// nodeTopology returns the zone and region of a Node of the graph, "" when it is unknown
+func (p *BaseProcessor) nodeTopology(name string) (string, string) {
+	if name == "" {
+		return "", ""
+	}
+	node := p.findNodeByNamespaceKindName("", "Node", name)
+	if node == nil || node.Metadata == nil {
+		return "", ""
+	}
+	return node.Metadata.Zone, node.Metadata.Region
+}
+
+// volumeTopology returns the zone and region of a PersistentVolume from its labels, or from
+// the values its node affinity requires, and the Node a local volume is pinned to
+func volumeTopology(pv *corev1.PersistentVolume) (string, string, string) {
+	zone, region := topologyOf(pv.Labels)
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return zone, region, ""
+	}
+
+	values := make(map[string]map[string]bool)
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, requirement := range term.MatchExpressions {
+			if requirement.Operator != corev1.NodeSelectorOpIn {
+				continue
+			}
+			if values[requirement.Key] == nil {
+				values[requirement.Key] = make(map[string]bool)
+			}
+			for _, value := range requirement.Values {
+				values[requirement.Key][value] = true
+			}
+		}
+	}
+	if zone == "" {
+		zone = joinValues(values[corev1.LabelTopologyZone], values[corev1.LabelFailureDomainBetaZone])
+	}
+	if region == "" {
+		region = joinValues(values[corev1.LabelTopologyRegion], values[corev1.LabelFailureDomainBetaRegion])
+	}
+	var hostname string
+	if hosts := values[corev1.LabelHostname]; len(hosts) == 1 {
+		for host := range hosts {
+			hostname = host
+		}
+	}
+	return zone, region, hostname
+}
+
+// joinValues joins the sorted values of the first non-empty set
+func joinValues(sets ...map[string]bool) string {
+	for _, set := range sets {
+		if len(set) == 0 {
+			continue
+		}
+		values := make([]string, 0, len(set))
+		for value := range set {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		return strings.Join(values, zoneSeparator)
+	}
+	return ""
+}
+
+// propagateTopology sets the zone and region of a Node on the Pods scheduled on it and the
+// local PersistentVolumes pinned to it
+func (p *NodeProcessor) propagateTopology(node *graph.Node) {
+	zone, region := node.Metadata.Zone, node.Metadata.Region
+
+	var targets []types.UID
+	for _, edge := range p.graph.IncomingEdges(node.UID) {
+		if edge.Type == graph.EdgeScheduledOn {
+			targets = append(targets, edge.FromUID)
+		}
+	}
+	for _, pv := range p.graph.GetNodesByNamespaceKind("", "PersistentVolume") {
+		if pv.Metadata != nil && pv.Metadata.NodeName == node.Name {
+			targets = append(targets, pv.UID)
+		}
+	}
+
+	for _, uid := range targets {
+		// Updated in place, so Pods and volumes deleted meanwhile are not added back
+		p.graph.UpdateNode(uid, func(target *graph.Node) bool {
+			// Volumes labeled with a zone keep it
+			if labelZone, _ := topologyOf(target.Labels); target.Kind == "PersistentVolume" && labelZone != "" {
+				return false
+			}
+			if target.Metadata == nil {
+				target.Metadata = &graph.ResourceMetadata{}
+			}
+			if target.Metadata.Zone == zone && target.Metadata.Region == region {
+				return false
+			}
+			target.Metadata.Zone, target.Metadata.Region = zone, region
+			return true
+		})
+	}
+}
@@ -32,7 +32,7 @@ func (p *DeploymentProcessor) Process(obj interface{}, eventType EventType) erro
 	node := graph.NewNodeFromObject(deployment, "Deployment", "apps/v1")
 
 	// Set status
-	node.Status, node.StatusMessage = p.getDeploymentStatus(deployment)
+	node.Status, node.StatusReason, node.StatusMessage = p.getDeploymentStatus(deployment)
 
 	// Set metadata
 	node.Metadata = &graph.ResourceMetadata{
@@ -50,7 +50,7 @@ func (p *DeploymentProcessor) Process(obj interface{}, eventType EventType) erro
 	}
 
 	// Add node to graph
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 
 	// Create ownership edges
 	p.createOwnershipEdges(node, deployment.GetOwnerReferences())
@@ -66,23 +66,23 @@ func (p *DeploymentProcessor) Process(obj interface{}, eventType EventType) erro
 	return nil
 }
 
-func (p *DeploymentProcessor) getDeploymentStatus(deployment *appsv1.Deployment) (graph.ResourceStatus, string) {
+func (p *DeploymentProcessor) getDeploymentStatus(deployment *appsv1.Deployment) (graph.ResourceStatus, string, string) {
 	desired := getInt32Value(deployment.Spec.Replicas, 1)
 	ready := deployment.Status.ReadyReplicas
 
 	if desired == 0 && ready == 0 {
-		return graph.StatusReady, "Scaled to zero (0/0)"
+		return graph.StatusReady, graph.ReasonScaledToZero, "Scaled to zero (0/0)"
 	}
 
 	if ready == desired {
-		return graph.StatusReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
+		return graph.StatusReady, graph.ReasonReplicasReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
 	}
 
 	if ready == 0 && desired > 0 {
-		return graph.StatusError, fmt.Sprintf("No replicas ready (0/%d)", desired)
+		return graph.StatusError, graph.ReasonReplicasUnavailable, fmt.Sprintf("No replicas ready (0/%d)", desired)
 	}
 
-	return graph.StatusPending, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
+	return graph.StatusPending, graph.ReasonReplicasPartiallyReady, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
 }
 
 // StatefulSetProcessor processes StatefulSet resources
@@ -105,7 +105,7 @@ func (p *StatefulSetProcessor) Process(obj interface{}, eventType EventType) err
 	}
 
 	node := graph.NewNodeFromObject(sts, "StatefulSet", "apps/v1")
-	node.Status, node.StatusMessage = p.getStatefulSetStatus(sts)
+	node.Status, node.StatusReason, node.StatusMessage = p.getStatefulSetStatus(sts)
 
 	node.Metadata = &graph.ResourceMetadata{
 		Replicas: &graph.ReplicaInfo{
@@ -120,7 +120,7 @@ func (p *StatefulSetProcessor) Process(obj interface{}, eventType EventType) err
 		node.Metadata.Image = sts.Spec.Template.Spec.Containers[0].Image
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, sts.GetOwnerReferences())
 	p.createConfigMapSecretEdges(node, &sts.Spec.Template.Spec)
 
@@ -131,23 +131,23 @@ func (p *StatefulSetProcessor) Process(obj interface{}, eventType EventType) err
 	return nil
 }
 
-func (p *StatefulSetProcessor) getStatefulSetStatus(sts *appsv1.StatefulSet) (graph.ResourceStatus, string) {
+func (p *StatefulSetProcessor) getStatefulSetStatus(sts *appsv1.StatefulSet) (graph.ResourceStatus, string, string) {
 	desired := getInt32Value(sts.Spec.Replicas, 1)
 	ready := sts.Status.ReadyReplicas
 
 	if desired == 0 && ready == 0 {
-		return graph.StatusReady, "Scaled to zero (0/0)"
+		return graph.StatusReady, graph.ReasonScaledToZero, "Scaled to zero (0/0)"
 	}
 
 	if ready == desired {
-		return graph.StatusReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
+		return graph.StatusReady, graph.ReasonReplicasReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
 	}
 
 	if ready == 0 && desired > 0 {
-		return graph.StatusError, fmt.Sprintf("No replicas ready (0/%d)", desired)
+		return graph.StatusError, graph.ReasonReplicasUnavailable, fmt.Sprintf("No replicas ready (0/%d)", desired)
 	}
 
-	return graph.StatusPending, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
+	return graph.StatusPending, graph.ReasonReplicasPartiallyReady, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
 }
 
 // DaemonSetProcessor processes DaemonSet resources
@@ -170,7 +170,7 @@ func (p *DaemonSetProcessor) Process(obj interface{}, eventType EventType) error
 	}
 
 	node := graph.NewNodeFromObject(ds, "DaemonSet", "apps/v1")
-	node.Status, node.StatusMessage = p.getDaemonSetStatus(ds)
+	node.Status, node.StatusReason, node.StatusMessage = p.getDaemonSetStatus(ds)
 
 	node.Metadata = &graph.ResourceMetadata{
 		Replicas: &graph.ReplicaInfo{
@@ -185,7 +185,7 @@ func (p *DaemonSetProcessor) Process(obj interface{}, eventType EventType) error
 		node.Metadata.Image = ds.Spec.Template.Spec.Containers[0].Image
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, ds.GetOwnerReferences())
 	p.createConfigMapSecretEdges(node, &ds.Spec.Template.Spec)
 
@@ -196,32 +196,34 @@ func (p *DaemonSetProcessor) Process(obj interface{}, eventType EventType) error
 	return nil
 }
 
-func (p *DaemonSetProcessor) getDaemonSetStatus(ds *appsv1.DaemonSet) (graph.ResourceStatus, string) {
+func (p *DaemonSetProcessor) getDaemonSetStatus(ds *appsv1.DaemonSet) (graph.ResourceStatus, string, string) {
 	desired := ds.Status.DesiredNumberScheduled
 	ready := ds.Status.NumberReady
 
 	if desired == 0 && ready == 0 {
-		return graph.StatusReady, "No nodes to schedule (0/0)"
+		return graph.StatusReady, graph.ReasonNoNodesToSchedule, "No nodes to schedule (0/0)"
 	}
 
 	if ready == desired {
-		return graph.StatusReady, fmt.Sprintf("All pods ready (%d/%d)", ready, desired)
+		return graph.StatusReady, graph.ReasonReplicasReady, fmt.Sprintf("All pods ready (%d/%d)", ready, desired)
 	}
 
 	if ready == 0 && desired > 0 {
-		return graph.StatusError, fmt.Sprintf("No pods ready (0/%d)", desired)
+		return graph.StatusError, graph.ReasonReplicasUnavailable, fmt.Sprintf("No pods ready (0/%d)", desired)
 	}
 
-	return graph.StatusPending, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
+	return graph.StatusPending, graph.ReasonReplicasPartiallyReady, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
 }
 
 // ReplicaSetProcessor processes ReplicaSet resources
 type ReplicaSetProcessor struct {
 	*BaseProcessor
+	// keepInactive is the number of inactive ReplicaSets kept per Deployment
+	keepInactive int
 }
 
 func NewReplicaSetProcessor(g graph.GraphInterface) *ReplicaSetProcessor {
-	return &ReplicaSetProcessor{BaseProcessor: NewBaseProcessor(g)}
+	return &ReplicaSetProcessor{BaseProcessor: NewBaseProcessor(g), keepInactive: 1}
 }
 
 func (p *ReplicaSetProcessor) Process(obj interface{}, eventType EventType) error {
@@ -234,14 +236,8 @@ func (p *ReplicaSetProcessor) Process(obj interface{}, eventType EventType) erro
 		return p.handleDelete(rs, "ReplicaSet")
 	}
 
-	// Skip inactive ReplicaSets (old versions with 0 replicas)
-	if rs.Status.Replicas == 0 && rs.Status.ReadyReplicas == 0 {
-		klog.V(4).Infof("Skipping inactive ReplicaSet: %s/%s", rs.Namespace, rs.Name)
-		return nil
-	}
-
 	node := graph.NewNodeFromObject(rs, "ReplicaSet", "apps/v1")
-	node.Status, node.StatusMessage = p.getReplicaSetStatus(rs)
+	node.Status, node.StatusReason, node.StatusMessage = p.getReplicaSetStatus(rs)
 
 	node.Metadata = &graph.ResourceMetadata{
 		Replicas: &graph.ReplicaInfo{
@@ -250,14 +246,28 @@ func (p *ReplicaSetProcessor) Process(obj interface{}, eventType EventType) erro
 			Ready:     rs.Status.ReadyReplicas,
 			Available: rs.Status.AvailableReplicas,
 		},
+		Containers:   templateContainers(&rs.Spec.Template.Spec),
+		Revision:     replicaSetRevision(rs),
+		TemplateHash: rs.Labels["pod-template-hash"],
+		Controller:   revisionController(rs),
+	}
+
+	// Skip inactive ReplicaSets (old versions with 0 replicas), except the previous revisions
+	if replicaSetInactive(rs) && !p.keepInactiveReplicaSet(node) {
+		klog.V(4).Infof("Skipping inactive ReplicaSet: %s/%s", rs.Namespace, rs.Name)
+		if _, exists := p.graph.GetNode(node.UID); exists {
+			p.graph.RemoveNode(node.UID)
+		}
+		return nil
 	}
 
 	if len(rs.Spec.Template.Spec.Containers) > 0 {
 		node.Metadata.Image = rs.Spec.Template.Spec.Containers[0].Image
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, rs.GetOwnerReferences())
+	p.createRevisionEdge(node, rs)
 	p.createConfigMapSecretEdges(node, &rs.Spec.Template.Spec)
 
 	if rs.Spec.Template.Spec.ServiceAccountName != "" {
@@ -267,28 +277,30 @@ func (p *ReplicaSetProcessor) Process(obj interface{}, eventType EventType) erro
 	return nil
 }
 
-func (p *ReplicaSetProcessor) getReplicaSetStatus(rs *appsv1.ReplicaSet) (graph.ResourceStatus, string) {
+func (p *ReplicaSetProcessor) getReplicaSetStatus(rs *appsv1.ReplicaSet) (graph.ResourceStatus, string, string) {
 	desired := getInt32Value(rs.Spec.Replicas, 1)
 	ready := rs.Status.ReadyReplicas
 
 	if desired == 0 && ready == 0 {
-		return graph.StatusReady, "Scaled to zero (0/0)"
+		return graph.StatusReady, graph.ReasonScaledToZero, "Scaled to zero (0/0)"
 	}
 
 	if ready == desired {
-		return graph.StatusReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
+		return graph.StatusReady, graph.ReasonReplicasReady, fmt.Sprintf("All replicas ready (%d/%d)", ready, desired)
 	}
 
 	if ready == 0 && desired > 0 {
-		return graph.StatusError, fmt.Sprintf("No replicas ready (0/%d)", desired)
+		return graph.StatusError, graph.ReasonReplicasUnavailable, fmt.Sprintf("No replicas ready (0/%d)", desired)
 	}
 
-	return graph.StatusPending, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
+	return graph.StatusPending, graph.ReasonReplicasPartiallyReady, fmt.Sprintf("Partially ready (%d/%d)", ready, desired)
 }
 
 // JobProcessor processes Job resources
 type JobProcessor struct {
 	*BaseProcessor
+	// pruned are the Jobs the Job pruner removed, not added back (optional)
+	pruned PrunedJobs
 }
 
 func NewJobProcessor(g graph.GraphInterface) *JobProcessor {
@@ -302,11 +314,18 @@ func (p *JobProcessor) Process(obj interface{}, eventType EventType) error {
 	}
 
 	if eventType == EventDelete {
+		if p.pruned != nil {
+			p.pruned.Forget(graph.ObjectID(job.UID, job.Namespace, "Job", job.Name))
+		}
 		return p.handleDelete(job, "Job")
 	}
 
 	node := graph.NewNodeFromObject(job, "Job", "batch/v1")
-	node.Status, node.StatusMessage = p.getJobStatus(job)
+	if p.pruned != nil && p.pruned.Pruned(node.UID) {
+		klog.V(4).Infof("Skipping pruned Job: %s/%s", job.Namespace, job.Name)
+		return nil
+	}
+	node.Status, node.StatusReason, node.StatusMessage = p.getJobStatus(job)
 
 	if len(job.Spec.Template.Spec.Containers) > 0 {
 		node.Metadata = &graph.ResourceMetadata{
@@ -314,7 +333,7 @@ func (p *JobProcessor) Process(obj interface{}, eventType EventType) error {
 		}
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, job.GetOwnerReferences())
 	p.createConfigMapSecretEdges(node, &job.Spec.Template.Spec)
 
@@ -325,20 +344,20 @@ func (p *JobProcessor) Process(obj interface{}, eventType EventType) error {
 	return nil
 }
 
-func (p *JobProcessor) getJobStatus(job *batchv1.Job) (graph.ResourceStatus, string) {
+func (p *JobProcessor) getJobStatus(job *batchv1.Job) (graph.ResourceStatus, string, string) {
 	if job.Status.Succeeded > 0 {
-		return graph.StatusReady, "Job completed successfully"
+		return graph.StatusReady, graph.ReasonJobComplete, "Job completed successfully"
 	}
 
 	if job.Status.Failed > 0 {
-		return graph.StatusError, fmt.Sprintf("Job failed (%d failures)", job.Status.Failed)
+		return graph.StatusError, graph.ReasonJobFailed, fmt.Sprintf("Job failed (%d failures)", job.Status.Failed)
 	}
 
 	if job.Status.Active > 0 {
-		return graph.StatusPending, "Job is running"
+		return graph.StatusPending, graph.ReasonJobRunning, "Job is running"
 	}
 
-	return graph.StatusPending, "Job is pending"
+	return graph.StatusPending, graph.ReasonJobPending, "Job is pending"
 }
 
 // CronJobProcessor processes CronJob resources
@@ -365,9 +384,11 @@ func (p *CronJobProcessor) Process(obj interface{}, eventType EventType) error {
 	activeCount := len(cronJob.Status.Active)
 	if activeCount > 0 {
 		node.Status = graph.StatusPending
+		node.StatusReason = graph.ReasonJobsActive
 		node.StatusMessage = fmt.Sprintf("%d active job(s)", activeCount)
 	} else {
 		node.Status = graph.StatusReady
+		node.StatusReason = graph.ReasonScheduled
 		node.StatusMessage = "CronJob scheduled"
 	}
 
@@ -377,7 +398,7 @@ func (p *CronJobProcessor) Process(obj interface{}, eventType EventType) error {
 		}
 	}
 
-	p.graph.AddNode(node)
+	p.addNode(node, obj)
 	p.createOwnershipEdges(node, cronJob.GetOwnerReferences())
 	p.createConfigMapSecretEdges(node, &cronJob.Spec.JobTemplate.Spec.Template.Spec)
 
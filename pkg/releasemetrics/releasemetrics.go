@@ -0,0 +1,147 @@
+// Package releasemetrics exports the state of each Helm release in the graph as Prometheus
+// series (resource counts by status and Pod restarts), so alerting rules can fire when a
+// release degrades. The series are computed from the graph when /metrics is scraped.
+package releasemetrics
+
+import (
+	"path"
+	"sort"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OtherRelease is the release label of the series aggregating the releases beyond
+// MaxReleases
+const OtherRelease = "_other"
+
+// Options configures the release metrics
+type Options struct {
+	// Releases lists glob patterns of the releases exported (empty = all releases)
+	Releases []string
+	// MaxReleases is the number of releases with their own series; the others are aggregated
+	// into the OtherRelease series (0 = no limit)
+	MaxReleases int
+}
+
+// DefaultOptions returns the default release metrics options
+func DefaultOptions() Options {
+	return Options{MaxReleases: 100}
+}
+
+var (
+	resourcesDesc = prometheus.NewDesc("astrolabe_release_resources_total",
+		"Number of resources of a Helm release.", []string{"release"}, nil)
+	readyDesc = prometheus.NewDesc("astrolabe_release_resources_ready",
+		"Number of Ready resources of a Helm release.", []string{"release"}, nil)
+	errorDesc = prometheus.NewDesc("astrolabe_release_resources_error",
+		"Number of resources of a Helm release in Error.", []string{"release"}, nil)
+	restartsDesc = prometheus.NewDesc("astrolabe_release_pods_restart_total",
+		"Sum of the container restarts of the Pods of a Helm release.", []string{"release"}, nil)
+)
+
+// Collector computes the release series from the graph on every scrape
+type Collector struct {
+	graph graph.GraphInterface
+	opts  Options
+}
+
+// NewCollector creates a collector of the release series of g
+func NewCollector(g graph.GraphInterface, opts Options) *Collector {
+	return &Collector{graph: g, opts: opts}
+}
+
+type releaseStats struct {
+	release                  string
+	resources, ready, errors int
+	restarts                 int
+}
+
+func (s *releaseStats) add(other *releaseStats) {
+	s.resources += other.resources
+	s.ready += other.ready
+	s.errors += other.errors
+	s.restarts += other.restarts
+}
+
+// Describe sends the descriptors of the release series
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourcesDesc
+	ch <- readyDesc
+	ch <- errorDesc
+	ch <- restartsDesc
+}
+
+// Collect sends the series of the exported releases
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.stats() {
+		ch <- prometheus.MustNewConstMetric(resourcesDesc, prometheus.GaugeValue, float64(stats.resources), stats.release)
+		ch <- prometheus.MustNewConstMetric(readyDesc, prometheus.GaugeValue, float64(stats.ready), stats.release)
+		ch <- prometheus.MustNewConstMetric(errorDesc, prometheus.GaugeValue, float64(stats.errors), stats.release)
+		ch <- prometheus.MustNewConstMetric(restartsDesc, prometheus.GaugeValue, float64(stats.restarts), stats.release)
+	}
+}
+
+// stats counts the resources of the exported releases in one pass over the graph. Beyond
+// MaxReleases, releases with resources in Error keep their own series first, then the
+// largest ones; the rest are summed into the OtherRelease series.
+func (c *Collector) stats() []*releaseStats {
+	byRelease := make(map[string]*releaseStats)
+	for _, node := range c.graph.GetAllNodes() {
+		if node.HelmRelease == "" || !c.exported(node.HelmRelease) {
+			continue
+		}
+		stats, exists := byRelease[node.HelmRelease]
+		if !exists {
+			stats = &releaseStats{release: node.HelmRelease}
+			byRelease[node.HelmRelease] = stats
+		}
+		stats.resources++
+		switch node.Status {
+		case graph.StatusReady:
+			stats.ready++
+		case graph.StatusError:
+			stats.errors++
+		}
+		if node.Kind == "Pod" && node.Metadata != nil {
+			stats.restarts += node.Metadata.RestartCount
+		}
+	}
+
+	result := make([]*releaseStats, 0, len(byRelease))
+	for _, stats := range byRelease {
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.errors > 0) != (b.errors > 0) {
+			return a.errors > 0
+		}
+		if a.resources != b.resources {
+			return a.resources > b.resources
+		}
+		return a.release < b.release
+	})
+	if c.opts.MaxReleases <= 0 || len(result) <= c.opts.MaxReleases {
+		return result
+	}
+
+	other := &releaseStats{release: OtherRelease}
+	for _, stats := range result[c.opts.MaxReleases:] {
+		other.add(stats)
+	}
+	return append(result[:c.opts.MaxReleases], other)
+}
+
+// exported reports whether a release matches the Releases patterns
+func (c *Collector) exported(release string) bool {
+	if len(c.opts.Releases) == 0 {
+		return true
+	}
+	for _, pattern := range c.opts.Releases {
+		if matched, _ := path.Match(pattern, release); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// auditStreamKey is the stream holding the audit log of graph mutations
+	auditStreamKey = "astrolabe:audit"
+	// auditCheckpointsKey indexes the checkpoints of the audit log by time
+	auditCheckpointsKey = "astrolabe:audit:checkpoints"
+)
+
+// AppendAudit appends audit records to the audit stream, indexing checkpoints
+func (s *RedisStore) AppendAudit(records []audit.Record) error {
+	pipe := s.client.Pipeline()
+	adds := make([]*redis.StringCmd, len(records))
+	for i, record := range records {
+		adds[i] = pipe.XAdd(s.ctx, &redis.XAddArgs{Stream: auditStreamKey, Values: map[string]interface{}{"entry": record.Data}})
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to append audit records: %w", err)
+	}
+
+	for i, record := range records {
+		if !record.Checkpoint {
+			continue
+		}
+		id := adds[i].Val()
+		err := s.client.ZAdd(s.ctx, auditCheckpointsKey, redis.Z{Score: float64(streamIDTime(id)), Member: id}).Err()
+		if err != nil {
+			return fmt.Errorf("failed to index audit checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// AuditCheckpoint returns the ID of the last checkpoint appended at or before at
+func (s *RedisStore) AuditCheckpoint(at time.Time) (string, error) {
+	ids, err := s.client.ZRevRangeByScore(s.ctx, auditCheckpointsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(at.UnixMilli(), 10),
+		Count: 1,
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// ReadAudit calls fn with the audit records from the one with ID from up to the ones appended
+// at to, in pipelined chunks
+func (s *RedisStore) ReadAudit(from string, to time.Time, fn func(id string, data []byte) error) error {
+	start := from
+	if start == "" {
+		start = "-"
+	}
+	end := strconv.FormatInt(to.UnixMilli(), 10)
+	for {
+		messages, err := s.client.XRangeN(s.ctx, auditStreamKey, start, end, snapshotChunkSize).Result()
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			data, _ := message.Values["entry"].(string)
+			if err := fn(message.ID, []byte(data)); err != nil {
+				return err
+			}
+		}
+		if len(messages) < snapshotChunkSize {
+			return nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+// TrimAudit removes the audit records before the last checkpoint at or before before, which
+// are not needed to replay any later moment
+func (s *RedisStore) TrimAudit(before time.Time) error {
+	checkpoint, err := s.AuditCheckpoint(before)
+	if err != nil || checkpoint == "" {
+		return err
+	}
+	_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.XTrimMinID(s.ctx, auditStreamKey, checkpoint)
+		pipe.ZRemRangeByScore(s.ctx, auditCheckpointsKey, "-inf", "("+strconv.FormatInt(streamIDTime(checkpoint), 10))
+		return nil
+	})
+	return err
+}
+
+// streamIDTime returns the millisecond timestamp of a stream entry ID
+func streamIDTime(id string) int64 {
+	ms, _, _ := strings.Cut(id, "-")
+	value, _ := strconv.ParseInt(ms, 10, 64)
+	return value
+}
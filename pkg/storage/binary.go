@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/storage/snapshotv1"
+	"github.com/klauspost/compress/zstd"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// The binary snapshot format stores the whole graph as a single zstd-compressed protobuf
+// blob, which is an order of magnitude smaller and faster to restore than one JSON record per
+// node and edge. Writes between snapshots are appended to the change log, which the next
+// snapshot trims, and replayed in memory on load.
+
+// SnapshotFormat selects how full snapshots are stored
+type SnapshotFormat string
+
+const (
+	// SnapshotRecords stores every node and edge as a JSON record, with its indexes
+	SnapshotRecords SnapshotFormat = "records"
+	// SnapshotBinary stores the graph as one zstd-compressed protobuf blob
+	SnapshotBinary SnapshotFormat = "binary"
+)
+
+// snapshotKey holds the blob of the binary snapshot format
+const snapshotKey = "astrolabe:snapshot"
+
+// ParseSnapshotFormat validates a --snapshot-format value
+func ParseSnapshotFormat(value string) (SnapshotFormat, error) {
+	switch format := SnapshotFormat(strings.ToLower(value)); format {
+	case SnapshotRecords, SnapshotBinary:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown snapshot format %q (expected records or binary)", value)
+}
+
+// SetSnapshotFormat sets how SaveGraph stores the graph. The binary format appends the writes
+// between snapshots to the change log. Data stored in the other format is converted on the
+// next LoadGraph.
+func (s *RedisStore) SetSnapshotFormat(format SnapshotFormat) {
+	s.format = format
+	if format == SnapshotBinary {
+		s.deltaLog = true
+	}
+}
+
+// binary reports whether snapshots are stored in the binary format
+func (s *RedisStore) binary() bool {
+	return s.format == SnapshotBinary
+}
+
+var (
+	// Encoders and decoders are safe for concurrent EncodeAll and DecodeAll calls
+	snapshotEncoder, _ = zstd.NewWriter(nil)
+	snapshotDecoder, _ = zstd.NewReader(nil)
+)
+
+// saveBinary stores the graph as a binary snapshot. The blob and its manifest are written in
+// one transaction, and the change log entries it supersedes are trimmed afterwards.
+func (s *RedisStore) saveBinary(g *graph.Graph) error {
+	klog.Info("Saving binary graph snapshot to Redis...")
+	start := time.Now()
+
+	writes, err := s.beginSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to mark snapshot in progress: %w", err)
+	}
+	// Log entries appended before the snapshot starts are superseded by it
+	logTail, err := s.logTail()
+	if err != nil {
+		return fmt.Errorf("failed to read change log: %w", err)
+	}
+
+	data, nodes, edges, err := encodeSnapshot(g)
+	if err != nil {
+		return err
+	}
+	manifest := SnapshotManifest{
+		SchemaVersion: snapshotSchemaVersion,
+		Format:        SnapshotBinary,
+		Timestamp:     time.Now(),
+		Nodes:         nodes,
+		Edges:         edges,
+		Checksum:      blobChecksum(data),
+	}
+	_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(s.ctx, snapshotKey, data, 0)
+		return s.writeManifest(pipe, manifest, writes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	if logTail != "" {
+		if err := s.markCompacted(logTail); err != nil {
+			return fmt.Errorf("failed to trim change log: %w", err)
+		}
+	}
+
+	klog.Infof("Saved %d nodes and %d edges to Redis in %v (%d bytes)", nodes, edges, time.Since(start), len(data))
+	return nil
+}
+
+// loadSnapshot returns the blob of the binary snapshot, or nil when there is none
+func (s *RedisStore) loadSnapshot() ([]byte, error) {
+	data, err := s.client.Get(s.ctx, snapshotKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// loadBinary decodes a binary snapshot into a graph
+func loadBinary(data []byte) (*graph.Graph, loadResult, error) {
+	result := loadResult{format: SnapshotBinary, checksum: blobChecksum(data)}
+
+	raw, err := snapshotDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	var snapshot snapshotv1.Snapshot
+	if err := proto.Unmarshal(raw, &snapshot); err != nil {
+		return nil, result, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	g := graph.NewGraph()
+	for _, pb := range snapshot.Nodes {
+		result.nodes++
+		node, err := nodeFromProto(pb)
+		if err != nil {
+			klog.Errorf("Failed to decode node %s: %v", pb.Uid, err)
+			result.corruptNodes++
+			continue
+		}
+		g.AddNode(node)
+	}
+	for _, pb := range snapshot.Edges {
+		result.edges++
+		if !g.AddEdge(edgeFromProto(pb)) {
+			result.danglingEdges++
+		}
+	}
+	return g, result, nil
+}
+
+// storedGraph returns the graph stored in the binary format: the snapshot with the change
+// log replayed
+func (s *RedisStore) storedGraph() (*graph.Graph, error) {
+	data, err := s.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	g := graph.NewGraph()
+	if data != nil {
+		if g, _, err = loadBinary(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.replayLog(g); err != nil {
+		return nil, fmt.Errorf("failed to replay change log: %w", err)
+	}
+	return g, nil
+}
+
+// storedEdges returns the edges of a graph
+func storedEdges(g *graph.Graph) []*graph.Edge {
+	var edges []*graph.Edge
+	for _, node := range g.GetAllNodes() {
+		for _, edge := range node.OutgoingEdges {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// encodeSnapshot encodes and compresses the nodes and edges of a graph, returning their counts
+func encodeSnapshot(g *graph.Graph) ([]byte, int, int, error) {
+	nodes := g.GetAllNodes()
+	snapshot := &snapshotv1.Snapshot{Nodes: make([]*snapshotv1.Node, 0, len(nodes))}
+	for _, node := range nodes {
+		pb, err := nodeToProto(node)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to encode node %s: %w", node.UID, err)
+		}
+		snapshot.Nodes = append(snapshot.Nodes, pb)
+		for _, edge := range node.OutgoingEdges {
+			snapshot.Edges = append(snapshot.Edges, edgeToProto(edge))
+		}
+	}
+
+	raw, err := proto.Marshal(snapshot)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return snapshotEncoder.EncodeAll(raw, nil), len(snapshot.Nodes), len(snapshot.Edges), nil
+}
+
+func nodeToProto(node *graph.Node) (*snapshotv1.Node, error) {
+	pb := &snapshotv1.Node{
+		Uid:               string(node.UID),
+		SourceUid:         string(node.SourceUID),
+		Name:              node.Name,
+		Namespace:         node.Namespace,
+		Kind:              node.Kind,
+		ApiVersion:        node.APIVersion,
+		ResourceVersion:   node.ResourceVersion,
+		Labels:            node.Labels,
+		Annotations:       node.Annotations,
+		CreationTimestamp: timestampToProto(node.CreationTimestamp),
+		Status:            string(node.Status),
+		StatusMessage:     node.StatusMessage,
+		StatusReason:      node.StatusReason,
+		Cluster:           node.Cluster,
+		HelmChart:         node.HelmChart,
+		HelmRelease:       node.HelmRelease,
+		FirstSeen:         timestampToProto(node.FirstSeen),
+		FirstReady:        timestampToProto(node.FirstReady),
+	}
+	if node.Metadata != nil {
+		metadata, err := json.Marshal(node.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		pb.Metadata = metadata
+	}
+	return pb, nil
+}
+
+func nodeFromProto(pb *snapshotv1.Node) (*graph.Node, error) {
+	node := &graph.Node{
+		UID:               types.UID(pb.Uid),
+		SourceUID:         types.UID(pb.SourceUid),
+		Name:              pb.Name,
+		Namespace:         pb.Namespace,
+		Kind:              pb.Kind,
+		APIVersion:        pb.ApiVersion,
+		ResourceVersion:   pb.ResourceVersion,
+		Labels:            pb.Labels,
+		Annotations:       pb.Annotations,
+		CreationTimestamp: timestampFromProto(pb.CreationTimestamp),
+		Status:            graph.ResourceStatus(pb.Status),
+		StatusMessage:     pb.StatusMessage,
+		StatusReason:      pb.StatusReason,
+		Cluster:           pb.Cluster,
+		HelmChart:         pb.HelmChart,
+		HelmRelease:       pb.HelmRelease,
+		FirstSeen:         timestampFromProto(pb.FirstSeen),
+		FirstReady:        timestampFromProto(pb.FirstReady),
+		OutgoingEdges:     make(map[types.UID]*graph.Edge),
+		IncomingEdges:     make(map[types.UID]*graph.Edge),
+	}
+	if len(pb.Metadata) > 0 {
+		node.Metadata = &graph.ResourceMetadata{}
+		if err := json.Unmarshal(pb.Metadata, node.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	return node, nil
+}
+
+func edgeToProto(edge *graph.Edge) *snapshotv1.Edge {
+	return &snapshotv1.Edge{
+		Type:          string(edge.Type),
+		FromUid:       string(edge.FromUID),
+		ToUid:         string(edge.ToUID),
+		Metadata:      edge.Metadata,
+		LastConfirmed: timestampToProto(edge.LastConfirmed),
+		Stale:         edge.Stale,
+	}
+}
+
+func edgeFromProto(pb *snapshotv1.Edge) *graph.Edge {
+	return &graph.Edge{
+		Type:          graph.EdgeType(pb.Type),
+		FromUID:       types.UID(pb.FromUid),
+		ToUID:         types.UID(pb.ToUid),
+		Metadata:      pb.Metadata,
+		LastConfirmed: timestampFromProto(pb.LastConfirmed),
+		Stale:         pb.Stale,
+	}
+}
+
+// timestampToProto converts a time, leaving zero times unset
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timestampFromProto converts a timestamp, unset timestamps to the zero time
+func timestampFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// blobChecksum is the SHA-256 digest of a binary snapshot
+func blobChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
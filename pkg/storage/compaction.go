@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+// Types of the keys storage compaction reclaims
+const (
+	KeyTypeNode  = "node"
+	KeyTypeEdge  = "edge"
+	KeyTypeIndex = "index"
+)
+
+var keyTypes = []string{KeyTypeNode, KeyTypeEdge, KeyTypeIndex}
+
+// CompactionReport is the outcome of a storage compaction
+type CompactionReport struct {
+	CompactedAt time.Time `json:"compactedAt"`
+	Duration    string    `json:"duration"`
+	// Scanned is the number of node, edge and index keys read
+	Scanned int `json:"scanned"`
+	// Orphaned counts the orphans found for the first time, deleted by the next compaction
+	// unless they are written again meanwhile, by key type
+	Orphaned map[string]int `json:"orphaned"`
+	// Reclaimed counts the orphans deleted, by key type
+	Reclaimed map[string]int `json:"reclaimed"`
+	// Rescued is the number of keys that were orphaned and are part of the graph again
+	Rescued int `json:"rescued"`
+}
+
+func newCompactionReport() CompactionReport {
+	report := CompactionReport{
+		CompactedAt: time.Now(),
+		Orphaned:    make(map[string]int, len(keyTypes)),
+		Reclaimed:   make(map[string]int, len(keyTypes)),
+	}
+	for _, keyType := range keyTypes {
+		report.Orphaned[keyType] = 0
+		report.Reclaimed[keyType] = 0
+	}
+	return report
+}
+
+// Compactor deletes the keys Redis keeps for nodes and edges that are no longer in the graph.
+// Deletes are written asynchronously, so a crash between a node's removal and its delete, or
+// a failed delete, leaves its record, edges and index entries in Redis forever.
+//
+// Compaction cross-checks the node, edge and index keys against the live graph, which is
+// authoritative. Since queued writes lag behind the graph, a key only counts as orphaned when
+// two consecutive compactions find it missing from the graph: the first gives orphaned records
+// a TTL, so they expire even if Astrolabe stops before the next one, and the second deletes
+// them. Records written again in between lose their TTL.
+type Compactor struct {
+	store    *RedisStore
+	graph    graph.GraphInterface
+	interval time.Duration
+	ttl      time.Duration
+
+	mu sync.Mutex
+	// suspects are the keys, and index members, found orphaned by the previous compaction
+	suspects map[string]bool
+	ran      bool
+}
+
+// NewCompactor creates a compactor of the keys of store against g, running every interval.
+// Orphaned records expire after ttl unless the next compaction deletes them first.
+func NewCompactor(store *RedisStore, g graph.GraphInterface, interval, ttl time.Duration) *Compactor {
+	return &Compactor{
+		store:    store,
+		graph:    g,
+		interval: interval,
+		ttl:      ttl,
+		suspects: make(map[string]bool),
+	}
+}
+
+// Start compacts every interval until ctx is cancelled
+func (c *Compactor) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.Run(); err != nil {
+				klog.Errorf("Storage compaction failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run compacts the stored keys now
+func (c *Compactor) Run() (CompactionReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Change log compaction rewrites records from the log, the graph may be ahead of them
+	c.store.compactMu.Lock()
+	defer c.store.compactMu.Unlock()
+
+	start := time.Now()
+	report := newCompactionReport()
+	run := compaction{
+		store:    c.store,
+		ttl:      c.ttl,
+		report:   &report,
+		live:     liveKeys(c.graph.Clone()),
+		previous: c.suspects,
+		suspects: make(map[string]bool),
+		// Records given a TTL before a restart are not known to be suspects
+		rescueAll: !c.ran,
+		pipe:      c.store.client.Pipeline(),
+	}
+
+	for _, prefix := range []string{nodeKeyPrefix, edgeKeyPrefix} {
+		if err := run.records(prefix); err != nil {
+			return report, err
+		}
+	}
+	if err := run.indexes(); err != nil {
+		return report, err
+	}
+	if err := run.flush(); err != nil {
+		return report, err
+	}
+	c.suspects = run.suspects
+	c.ran = true
+
+	elapsed := time.Since(start)
+	report.Duration = elapsed.String()
+	reclaimed := 0
+	for _, keyType := range keyTypes {
+		metrics.PersistenceOrphanedKeys.WithLabelValues(keyType).Set(float64(report.Orphaned[keyType]))
+		metrics.PersistenceReclaimedKeys.WithLabelValues(keyType).Add(float64(report.Reclaimed[keyType]))
+		reclaimed += report.Reclaimed[keyType]
+	}
+	if reclaimed > 0 {
+		klog.Infof("Storage compaction reclaimed %d orphaned key(s) of %d scanned: %v", reclaimed, report.Scanned, report.Reclaimed)
+	} else {
+		klog.V(2).Infof("Storage compaction found no orphaned keys to reclaim (%d scanned, took %v)", report.Scanned, elapsed)
+	}
+	return report, nil
+}
+
+// liveSet is the stored keys of a graph
+type liveSet struct {
+	records map[string]bool
+	// indexes maps index keys to their members
+	indexes map[string]map[string]bool
+}
+
+// liveKeys returns the node, edge and index keys a graph is stored under
+func liveKeys(g *graph.Graph) liveSet {
+	l := liveSet{records: make(map[string]bool), indexes: make(map[string]map[string]bool)}
+	for _, node := range g.GetAllNodes() {
+		l.records[nodeKeyPrefix+string(node.UID)] = true
+		for _, edge := range node.OutgoingEdges {
+			l.records[edgeKeyPrefix+string(edge.FromUID)+":"+string(edge.ToUID)] = true
+		}
+		for _, indexKey := range indexKeys(node) {
+			members := l.indexes[indexKey]
+			if members == nil {
+				members = make(map[string]bool)
+				l.indexes[indexKey] = members
+			}
+			members[string(node.UID)] = true
+		}
+	}
+	return l
+}
+
+// compaction is a single run of a Compactor
+type compaction struct {
+	store  *RedisStore
+	ttl    time.Duration
+	report *CompactionReport
+	live   liveSet
+	// previous are the suspects of the previous compaction, suspects those of this one
+	previous  map[string]bool
+	suspects  map[string]bool
+	rescueAll bool
+
+	pipe    redis.Pipeliner
+	persist []*redis.BoolCmd
+	// writes is set when the queued commands change the stored data
+	writes bool
+}
+
+// records compacts the node or edge records under prefix
+func (r *compaction) records(prefix string) error {
+	keyType := KeyTypeNode
+	if prefix == edgeKeyPrefix {
+		keyType = KeyTypeEdge
+	}
+	return r.scan(prefix, func(key string) {
+		if r.live.records[key] {
+			if r.previous[key] || r.rescueAll {
+				r.persist = append(r.persist, r.pipe.Persist(r.store.ctx, key))
+			}
+			return
+		}
+		r.writes = true
+		if r.previous[key] {
+			r.pipe.Del(r.store.ctx, key)
+			r.report.Reclaimed[keyType]++
+			return
+		}
+		r.suspects[key] = true
+		r.pipe.Expire(r.store.ctx, key, r.ttl)
+		r.report.Orphaned[keyType]++
+	})
+}
+
+// indexes removes the members of index sets that are not in them in the graph. Index sets are
+// not read back, and Redis deletes the sets left empty.
+func (r *compaction) indexes() error {
+	var keys []string
+	err := r.scan(indexKeyPrefix, func(key string) {
+		keys = append(keys, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += snapshotChunkSize {
+		chunk := keys[start:min(start+snapshotChunkSize, len(keys))]
+		reads := make([]*redis.StringSliceCmd, len(chunk))
+		_, err := r.store.client.Pipelined(r.store.ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range chunk {
+				reads[i] = pipe.SMembers(r.store.ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read index sets: %w", err)
+		}
+
+		for i, key := range chunk {
+			for _, member := range reads[i].Val() {
+				if r.live.indexes[key][member] {
+					continue
+				}
+				id := key + "\x00" + member
+				if !r.previous[id] {
+					r.suspects[id] = true
+					r.report.Orphaned[KeyTypeIndex]++
+					continue
+				}
+				r.pipe.SRem(r.store.ctx, key, member)
+				r.report.Reclaimed[KeyTypeIndex]++
+				r.writes = true
+			}
+		}
+		if err := r.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan calls fn with every key under prefix, sending the queued commands in chunks
+func (r *compaction) scan(prefix string, fn func(key string)) error {
+	var cursor uint64
+	for {
+		keys, nextCursor, err := r.store.client.Scan(r.store.ctx, cursor, prefix+"*", snapshotChunkSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", prefix, err)
+		}
+		r.report.Scanned += len(keys)
+		for _, key := range keys {
+			fn(key)
+		}
+		if err := r.flush(); err != nil {
+			return err
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// flush sends the queued commands. Deletes and expiries mark the stored data modified, so the
+// records are not checked against the snapshot manifest on the next load.
+func (r *compaction) flush() error {
+	if r.pipe.Len() == 0 {
+		return nil
+	}
+	if r.writes {
+		r.store.markDirty(r.pipe)
+		r.writes = false
+	}
+	if _, err := r.pipe.Exec(r.store.ctx); err != nil {
+		return fmt.Errorf("failed to compact keys: %w", err)
+	}
+	for _, cmd := range r.persist {
+		if cmd.Val() {
+			r.report.Rescued++
+		}
+	}
+	r.persist = r.persist[:0]
+	return nil
+}
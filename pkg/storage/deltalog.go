@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// The delta log is an append-only Redis stream of node and edge writes. With it enabled,
+// incremental writes are one XADD each instead of rewriting records and indexes, so the cost
+// of persistence follows the rate of changes rather than the size of the graph. Compaction
+// folds the logged writes into the node and edge records, in order, and trims the log; on
+// startup the records are loaded and the remaining entries replayed in memory. Every entry
+// holds the full state it writes, so replaying an entry twice is harmless.
+
+const (
+	// logStreamKey is the stream holding the delta log
+	logStreamKey = "astrolabe:log"
+	// logCompactedField is the metadata field holding the ID of the last compacted entry
+	logCompactedField = "logCompactedID"
+)
+
+// EnableDeltaLog makes incremental writes append to the delta log instead of updating the
+// records. The log is folded into the records by CompactLog.
+func (s *RedisStore) EnableDeltaLog() {
+	s.deltaLog = true
+}
+
+// appendLog appends write operations to the delta log in one MULTI/EXEC pipeline
+func (s *RedisStore) appendLog(ops ...graph.WriteOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	appended := 0
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		for _, op := range ops {
+			values, err := encodeLogEntry(op)
+			if err != nil {
+				klog.Errorf("Failed to encode %s log entry: %v", op.Type, err)
+				continue
+			}
+			pipe.XAdd(s.ctx, &redis.XAddArgs{Stream: logStreamKey, Values: values})
+			appended++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append to change log: %w", err)
+	}
+	metrics.PersistenceLogEntries.WithLabelValues("appended").Add(float64(appended))
+	return nil
+}
+
+// CompactLog applies the logged writes to the node and edge records in chunks and trims them
+// from the log. Writes appended while it runs are compacted by the next run.
+func (s *RedisStore) CompactLog() error {
+	if s.binary() {
+		return fmt.Errorf("the change log of binary snapshots is trimmed by taking a snapshot")
+	}
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	start := time.Now()
+	compacted := 0
+	err := s.readLog(func(ops []graph.WriteOp, last string) error {
+		if err := s.applyBatch(ops); err != nil {
+			return err
+		}
+		if err := s.markCompacted(last); err != nil {
+			return err
+		}
+		compacted += len(ops)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if compacted > 0 {
+		metrics.PersistenceLogEntries.WithLabelValues("compacted").Add(float64(compacted))
+		klog.V(2).Infof("Compacted %d change log entries in %v", compacted, time.Since(start))
+	}
+	return nil
+}
+
+// compactForRead brings the records up to date before they are read, when the delta log is enabled
+func (s *RedisStore) compactForRead() error {
+	if !s.deltaLog {
+		return nil
+	}
+	if err := s.CompactLog(); err != nil {
+		return fmt.Errorf("failed to compact change log: %w", err)
+	}
+	return nil
+}
+
+// replayLog applies the entries not compacted yet to a loaded graph
+func (s *RedisStore) replayLog(g *graph.Graph) error {
+	start := time.Now()
+	replayed := 0
+	err := s.readLog(func(ops []graph.WriteOp, _ string) error {
+		for _, op := range ops {
+			switch op.Type {
+			case graph.OpSaveNode:
+				g.AddNode(op.Node)
+			case graph.OpDeleteNode:
+				g.RemoveNode(op.UID)
+			case graph.OpSaveEdge:
+				// Edges of nodes deleted later in the log are dropped
+				g.AddEdge(op.Edge)
+			case graph.OpDeleteEdge:
+				g.RemoveEdge(op.UID, op.ToUID)
+			}
+		}
+		replayed += len(ops)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if replayed > 0 {
+		metrics.PersistenceLogEntries.WithLabelValues("replayed").Add(float64(replayed))
+		klog.Infof("Replayed %d change log entries in %v", replayed, time.Since(start))
+	}
+	return nil
+}
+
+// readLog calls fn with the entries after the last compacted one, in chunks, with the ID of
+// the last entry of each chunk. Entries that cannot be decoded are skipped.
+func (s *RedisStore) readLog(fn func(ops []graph.WriteOp, last string) error) error {
+	compactedID, err := s.client.HGet(s.ctx, metadataKey, logCompactedField).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to read compacted log position: %w", err)
+	}
+	from := "-"
+	if compactedID != "" {
+		from = "(" + compactedID
+	}
+
+	for {
+		messages, err := s.client.XRangeN(s.ctx, logStreamKey, from, "+", snapshotChunkSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read change log: %w", err)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ops := make([]graph.WriteOp, 0, len(messages))
+		for _, message := range messages {
+			op, err := decodeLogEntry(message.Values)
+			if err != nil {
+				klog.Errorf("Skipping change log entry %s: %v", message.ID, err)
+				continue
+			}
+			ops = append(ops, op)
+		}
+		last := messages[len(messages)-1].ID
+		if err := fn(ops, last); err != nil {
+			return err
+		}
+		from = "(" + last
+	}
+}
+
+// logTail returns the ID of the last entry of the delta log, or "" when it is empty
+func (s *RedisStore) logTail() (string, error) {
+	messages, err := s.client.XRevRangeN(s.ctx, logStreamKey, "+", "-", 1).Result()
+	if err != nil || len(messages) == 0 {
+		return "", err
+	}
+	return messages[0].ID, nil
+}
+
+// markCompacted records that the entries up to id are reflected in the records and trims them
+// from the log. The last compacted entry is kept, as trimming is by minimum ID.
+func (s *RedisStore) markCompacted(id string) error {
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(s.ctx, metadataKey, logCompactedField, id)
+		pipe.XTrimMinID(s.ctx, logStreamKey, id)
+		return nil
+	})
+	return err
+}
+
+// encodeLogEntry converts a write operation to the fields of a stream entry
+func encodeLogEntry(op graph.WriteOp) (map[string]interface{}, error) {
+	values := map[string]interface{}{"op": string(op.Type)}
+	switch op.Type {
+	case graph.OpSaveNode:
+		data, err := encodeNode(op.Node)
+		if err != nil {
+			return nil, err
+		}
+		values["data"] = data
+	case graph.OpSaveEdge:
+		data, err := json.Marshal(op.Edge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal edge: %w", err)
+		}
+		values["data"] = data
+	case graph.OpDeleteNode:
+		values["uid"] = string(op.UID)
+	case graph.OpDeleteEdge:
+		values["uid"] = string(op.UID)
+		values["to"] = string(op.ToUID)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Type)
+	}
+	return values, nil
+}
+
+// decodeLogEntry converts the fields of a stream entry back to a write operation
+func decodeLogEntry(values map[string]interface{}) (graph.WriteOp, error) {
+	field := func(name string) string {
+		value, _ := values[name].(string)
+		return value
+	}
+
+	op := graph.WriteOp{Type: graph.WriteOpType(field("op"))}
+	switch op.Type {
+	case graph.OpSaveNode:
+		node, err := decodeNode([]byte(field("data")))
+		if err != nil {
+			return op, err
+		}
+		op.Node = node
+	case graph.OpSaveEdge:
+		var edge graph.Edge
+		if err := json.Unmarshal([]byte(field("data")), &edge); err != nil {
+			return op, fmt.Errorf("failed to unmarshal edge: %w", err)
+		}
+		op.Edge = &edge
+	case graph.OpDeleteNode:
+		op.UID = types.UID(field("uid"))
+	case graph.OpDeleteEdge:
+		op.UID = types.UID(field("uid"))
+		op.ToUID = types.UID(field("to"))
+	default:
+		return op, fmt.Errorf("unknown operation %q", op.Type)
+	}
+	return op, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/healthhistory"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// healthHistoryKeyPrefix prefixes the sorted set of the hourly health points of a release,
+	// scored by the Unix time of their hour
+	healthHistoryKeyPrefix = "astrolabe:health:release:"
+	// healthHistoryReleasesKey lists the releases with health points
+	healthHistoryReleasesKey = "astrolabe:health:releases"
+)
+
+// SaveHealthPoint stores an hourly health point of a release, adding its samples to those of
+// the point already stored for the hour
+func (s *RedisStore) SaveHealthPoint(release string, point healthhistory.Point) error {
+	key := healthHistoryKeyPrefix + release
+	score := strconv.FormatInt(point.Time.Unix(), 10)
+	stored, err := s.client.ZRangeByScore(s.ctx, key, &redis.ZRangeBy{Min: score, Max: score}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read health point: %w", err)
+	}
+	for _, data := range stored {
+		var existing healthhistory.Point
+		if err := json.Unmarshal([]byte(data), &existing); err == nil {
+			point.Add(existing)
+		}
+	}
+	data, err := json.Marshal(point)
+	if err != nil {
+		return fmt.Errorf("failed to encode health point: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(s.ctx, key, score, score)
+		pipe.ZAdd(s.ctx, key, redis.Z{Score: float64(point.Time.Unix()), Member: data})
+		pipe.SAdd(s.ctx, healthHistoryReleasesKey, release)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save health point: %w", err)
+	}
+	return nil
+}
+
+// ReadHealthHistory returns the health points of a release from the hour of from, oldest first
+func (s *RedisStore) ReadHealthHistory(release string, from time.Time) ([]healthhistory.Point, error) {
+	stored, err := s.client.ZRangeByScore(s.ctx, healthHistoryKeyPrefix+release, &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Truncate(time.Hour).Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read health history: %w", err)
+	}
+	points := make([]healthhistory.Point, 0, len(stored))
+	for _, data := range stored {
+		var point healthhistory.Point
+		if err := json.Unmarshal([]byte(data), &point); err != nil {
+			return nil, fmt.Errorf("failed to decode health point: %w", err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// TrimHealthHistory removes the health points older than before, and the releases left
+// without points
+func (s *RedisStore) TrimHealthHistory(before time.Time) error {
+	releases, err := s.client.SMembers(s.ctx, healthHistoryReleasesKey).Result()
+	if err != nil {
+		return err
+	}
+	max := "(" + strconv.FormatInt(before.Unix(), 10)
+	for _, release := range releases {
+		key := healthHistoryKeyPrefix + release
+		if err := s.client.ZRemRangeByScore(s.ctx, key, "-inf", max).Err(); err != nil {
+			return err
+		}
+		count, err := s.client.ZCard(s.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := s.client.SRem(s.ctx, healthHistoryReleasesKey, release).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
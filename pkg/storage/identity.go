@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// remapIDs re-keys a loaded graph whose node IDs do not match the current ID strategy,
+// using the Kubernetes UID stored with each node. It returns the graph unchanged if all IDs match.
+func remapIDs(g *graph.Graph) (*graph.Graph, bool) {
+	strategy := graph.CurrentIDStrategy()
+	nodes := g.GetAllNodes()
+
+	ids := make(map[types.UID]types.UID, len(nodes))
+	changed := false
+	for _, node := range nodes {
+		id := strategy.ID(graph.ObjectIdentity{
+			UID:       node.KubernetesUID(),
+			Namespace: node.Namespace,
+			Kind:      node.Kind,
+			Name:      node.Name,
+		})
+		ids[node.UID] = id
+		if id != node.UID {
+			changed = true
+		}
+	}
+	if !changed {
+		return g, false
+	}
+
+	remapped := graph.NewGraph()
+	for _, node := range nodes {
+		copied := *node
+		copied.UID = ids[node.UID]
+		copied.SourceUID = node.KubernetesUID()
+		if copied.SourceUID == copied.UID {
+			copied.SourceUID = ""
+		}
+		copied.OutgoingEdges = nil
+		copied.IncomingEdges = nil
+		remapped.AddNode(&copied)
+	}
+	for _, node := range nodes {
+		for _, edge := range node.OutgoingEdges {
+			remapped.AddEdge(&graph.Edge{
+				Type:          edge.Type,
+				FromUID:       ids[edge.FromUID],
+				ToUID:         ids[edge.ToUID],
+				Metadata:      edge.Metadata,
+				LastConfirmed: edge.LastConfirmed,
+				Stale:         edge.Stale,
+			})
+		}
+	}
+
+	return remapped, true
+}
+
+// rewrite replaces all stored nodes, edges and indexes with the given graph
+func (s *RedisStore) rewrite(g *graph.Graph) error {
+	for _, prefix := range []string{nodeKeyPrefix, edgeKeyPrefix, indexKeyPrefix} {
+		if err := s.deleteKeysByPattern(prefix + "*"); err != nil {
+			return fmt.Errorf("failed to delete %s keys: %w", prefix, err)
+		}
+	}
+	return s.SaveGraph(g)
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+// snapshotSchemaVersion is bumped whenever the stored node or edge format changes incompatibly
+const snapshotSchemaVersion = 1
+
+// VerifyMode controls what happens when a loaded graph does not match its snapshot manifest
+type VerifyMode string
+
+const (
+	// VerifyStrict refuses to load data that fails verification
+	VerifyStrict VerifyMode = "strict"
+	// VerifyWarn loads the data and logs every problem found
+	VerifyWarn VerifyMode = "warn"
+	// VerifyOff skips verification
+	VerifyOff VerifyMode = "off"
+)
+
+// ParseVerifyMode validates a --snapshot-verify value
+func ParseVerifyMode(value string) (VerifyMode, error) {
+	switch mode := VerifyMode(strings.ToLower(value)); mode {
+	case VerifyStrict, VerifyWarn, VerifyOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown snapshot verify mode %q (expected strict, warn or off)", value)
+}
+
+// SnapshotManifest describes the data written by the last full snapshot
+type SnapshotManifest struct {
+	SchemaVersion int
+	Format        SnapshotFormat
+	Timestamp     time.Time
+	Nodes         int
+	Edges         int
+	NodeChecksum  string
+	EdgeChecksum  string
+	// Checksum is the digest of the blob of a binary snapshot
+	Checksum string
+	// Dirty means data was written after (or while) the snapshot was taken, so only record integrity can be verified
+	Dirty bool
+}
+
+// checksum is an order-independent digest of a set of records: the XOR of their SHA-256 hashes
+type checksum [sha256.Size]byte
+
+func (c *checksum) add(key string, data []byte) {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(data)
+	sum := h.Sum(nil)
+	for i := range c {
+		c[i] ^= sum[i]
+	}
+}
+
+func (c checksum) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+// markDirty counts an incremental write; queued with every write outside of SaveGraph
+func (s *RedisStore) markDirty(c redis.Cmdable) {
+	c.HIncrBy(s.ctx, metadataKey, "writes", 1)
+}
+
+// beginSnapshot marks the stored data as changing and returns the write counter the
+// snapshot's manifest is valid for
+func (s *RedisStore) beginSnapshot() (int64, error) {
+	return s.client.HIncrBy(s.ctx, metadataKey, "writes", 1).Result()
+}
+
+// writeManifest stores the manifest of a completed snapshot started at the given write counter
+func (s *RedisStore) writeManifest(c redis.Cmdable, m SnapshotManifest, writes int64) error {
+	return c.HSet(s.ctx, metadataKey, map[string]interface{}{
+		"schemaVersion":  m.SchemaVersion,
+		"format":         string(m.Format),
+		"timestamp":      m.Timestamp.UTC().Format(time.RFC3339Nano),
+		"nodes":          m.Nodes,
+		"edges":          m.Edges,
+		"nodeChecksum":   m.NodeChecksum,
+		"edgeChecksum":   m.EdgeChecksum,
+		"checksum":       m.Checksum,
+		"snapshotWrites": writes,
+	}).Err()
+}
+
+// readManifest returns the stored manifest, or nil when no snapshot has written one
+func (s *RedisStore) readManifest() (*SnapshotManifest, error) {
+	fields, err := s.client.HGetAll(s.ctx, metadataKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := fields["schemaVersion"]; !exists {
+		return nil, nil
+	}
+
+	m := &SnapshotManifest{
+		Format:       SnapshotFormat(fields["format"]),
+		NodeChecksum: fields["nodeChecksum"],
+		EdgeChecksum: fields["edgeChecksum"],
+		Checksum:     fields["checksum"],
+		Dirty:        fields["writes"] != fields["snapshotWrites"],
+	}
+	if m.Format == "" {
+		// Manifests written before the binary format describe records
+		m.Format = SnapshotRecords
+	}
+	if m.SchemaVersion, err = strconv.Atoi(fields["schemaVersion"]); err != nil {
+		return nil, fmt.Errorf("invalid schemaVersion: %w", err)
+	}
+	if m.Nodes, err = strconv.Atoi(fields["nodes"]); err != nil {
+		return nil, fmt.Errorf("invalid node count: %w", err)
+	}
+	if m.Edges, err = strconv.Atoi(fields["edges"]); err != nil {
+		return nil, fmt.Errorf("invalid edge count: %w", err)
+	}
+	if m.Timestamp, err = time.Parse(time.RFC3339Nano, fields["timestamp"]); err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return m, nil
+}
+
+// loadResult is what LoadGraph observed while reading the stored records or snapshot
+type loadResult struct {
+	format                     SnapshotFormat
+	nodes, edges               int
+	nodeChecksum, edgeChecksum checksum
+	// checksum is the digest of the blob of a binary snapshot
+	checksum                   string
+	corruptNodes, corruptEdges int
+	danglingEdges              int
+}
+
+// verify compares the loaded records with the manifest and applies the verify mode
+func (s *RedisStore) verify(result loadResult) error {
+	if s.verifyMode == VerifyOff {
+		return nil
+	}
+
+	var problems []string
+
+	manifest, err := s.readManifest()
+	switch {
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("snapshot manifest is unreadable: %v", err))
+	case manifest == nil:
+		if result.nodes > 0 {
+			klog.Warning("No snapshot manifest found, loaded data cannot be verified")
+		}
+	case manifest.SchemaVersion != snapshotSchemaVersion:
+		problems = append(problems, fmt.Sprintf("snapshot schema version %d is not supported (expected %d)", manifest.SchemaVersion, snapshotSchemaVersion))
+	case manifest.Format != result.format:
+		problems = append(problems, fmt.Sprintf("snapshot manifest describes %s data but %s data was loaded", manifest.Format, result.format))
+	case manifest.Dirty:
+		klog.Infof("Data was modified after the snapshot taken at %s, verifying record integrity only", manifest.Timestamp.Format(time.RFC3339))
+	case manifest.Format == SnapshotBinary:
+		if result.checksum != manifest.Checksum || result.nodes != manifest.Nodes || result.edges != manifest.Edges {
+			problems = append(problems, fmt.Sprintf("snapshot does not match its manifest (%d nodes and %d edges loaded, %d and %d expected)",
+				result.nodes, result.edges, manifest.Nodes, manifest.Edges))
+		}
+	default:
+		if result.nodes != manifest.Nodes || result.nodeChecksum.String() != manifest.NodeChecksum {
+			problems = append(problems, fmt.Sprintf("nodes do not match the snapshot manifest (%d loaded, %d expected)", result.nodes, manifest.Nodes))
+		}
+		if result.edges != manifest.Edges || result.edgeChecksum.String() != manifest.EdgeChecksum {
+			problems = append(problems, fmt.Sprintf("edges do not match the snapshot manifest (%d loaded, %d expected)", result.edges, manifest.Edges))
+		}
+	}
+
+	if result.corruptNodes > 0 {
+		problems = append(problems, fmt.Sprintf("%d node record(s) could not be decoded", result.corruptNodes))
+	}
+	if result.corruptEdges > 0 {
+		problems = append(problems, fmt.Sprintf("%d edge record(s) could not be decoded", result.corruptEdges))
+	}
+	if result.danglingEdges > 0 {
+		problems = append(problems, fmt.Sprintf("%d edge(s) reference missing nodes", result.danglingEdges))
+	}
+
+	if len(problems) == 0 {
+		if manifest != nil {
+			klog.Info("Loaded graph verified against snapshot manifest")
+		}
+		return nil
+	}
+
+	if s.verifyMode == VerifyStrict {
+		return fmt.Errorf("snapshot verification failed: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		klog.Warningf("Snapshot verification: %s", problem)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ammarlakis/astrolabe/pkg/graph"
@@ -23,16 +24,29 @@ const (
 	namespaceKindIndex = "astrolabe:index:ns-kind:"
 	helmReleaseIndex   = "astrolabe:index:helm-release:"
 	labelIndex         = "astrolabe:index:label:"
+
+	// Maximum number of commands sent in one pipeline when saving a full snapshot
+	snapshotChunkSize = 500
 )
 
 // RedisStore provides persistent storage for the graph using Redis
 type RedisStore struct {
-	client *redis.Client
-	ctx    context.Context
+	client     *redis.Client
+	ctx        context.Context
+	verifyMode VerifyMode
+
+	// deltaLog appends writes to the change log instead of updating records (see EnableDeltaLog)
+	deltaLog  bool
+	compactMu sync.Mutex
+
+	// format is how full snapshots are stored (see SetSnapshotFormat)
+	format SnapshotFormat
 }
 
 // NewRedisStore creates a new Redis store
 func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
+	// No idle connections are dialed ahead: they would be dialed in the background while the
+	// tracing hook is added, which go-redis does not synchronize
 	client := redis.NewClient(&redis.Options{
 		Addr:         addr,
 		Password:     password,
@@ -41,8 +55,8 @@ func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
 		ReadTimeout:  3 * time.Second,
 		WriteTimeout: 3 * time.Second,
 		PoolSize:     10,
-		MinIdleConns: 5,
 	})
+	client.AddHook(tracingHook{})
 
 	ctx := context.Background()
 
@@ -54,11 +68,23 @@ func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
 	klog.Info("Successfully connected to Redis")
 
 	return &RedisStore{
-		client: client,
-		ctx:    ctx,
+		client:     client,
+		ctx:        ctx,
+		verifyMode: VerifyWarn,
+		format:     SnapshotRecords,
 	}, nil
 }
 
+// Ping checks that Redis is reachable
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// SetVerifyMode sets how LoadGraph handles data that does not match the snapshot manifest
+func (s *RedisStore) SetVerifyMode(mode VerifyMode) {
+	s.verifyMode = mode
+}
+
 // Close closes the Redis connection
 func (s *RedisStore) Close() error {
 	return s.client.Close()
@@ -66,9 +92,42 @@ func (s *RedisStore) Close() error {
 
 // SaveNode persists a node to Redis
 func (s *RedisStore) SaveNode(node *graph.Node) error {
-	// Serialize node (without edges to avoid circular references)
+	if s.deltaLog {
+		return s.appendLog(graph.WriteOp{Type: graph.OpSaveNode, Node: node})
+	}
+
+	// Node and index updates are sent in a single round-trip
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		s.markDirty(pipe)
+		_, err := s.queueSaveNode(pipe, node)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save node to Redis: %w", err)
+	}
+
+	return nil
+}
+
+// queueSaveNode queues the commands persisting a node and its indexes and returns the stored record
+func (s *RedisStore) queueSaveNode(pipe redis.Pipeliner, node *graph.Node) ([]byte, error) {
+	data, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	key := nodeKeyPrefix + string(node.UID)
+	pipe.Set(s.ctx, key, data, 0)
+	s.updateIndexes(pipe, node)
+
+	return data, nil
+}
+
+// encodeNode serializes a node without its edges, which are stored separately
+func encodeNode(node *graph.Node) ([]byte, error) {
 	nodeData := &SerializedNode{
 		UID:               node.UID,
+		SourceUID:         node.SourceUID,
 		Name:              node.Name,
 		Namespace:         node.Namespace,
 		Kind:              node.Kind,
@@ -79,53 +138,53 @@ func (s *RedisStore) SaveNode(node *graph.Node) error {
 		CreationTimestamp: node.CreationTimestamp,
 		Status:            node.Status,
 		StatusMessage:     node.StatusMessage,
+		StatusReason:      node.StatusReason,
+		Cluster:           node.Cluster,
 		HelmChart:         node.HelmChart,
 		HelmRelease:       node.HelmRelease,
 		Metadata:          node.Metadata,
+		FirstSeen:         node.FirstSeen,
+		FirstReady:        node.FirstReady,
 	}
 
 	data, err := json.Marshal(nodeData)
 	if err != nil {
-		return fmt.Errorf("failed to marshal node: %w", err)
-	}
-
-	// Save node
-	key := nodeKeyPrefix + string(node.UID)
-	if err := s.client.Set(s.ctx, key, data, 0).Err(); err != nil {
-		return fmt.Errorf("failed to save node to Redis: %w", err)
+		return nil, fmt.Errorf("failed to marshal node: %w", err)
 	}
-
-	// Update indexes
-	if err := s.updateIndexes(node); err != nil {
-		klog.Errorf("Failed to update indexes for node %s: %v", node.UID, err)
-	}
-
-	return nil
+	return data, nil
 }
 
 // DeleteNode removes a node from Redis
 func (s *RedisStore) DeleteNode(uid types.UID) error {
-	// Get node first to update indexes
-	node, err := s.GetNode(uid)
-	if err != nil {
-		klog.V(4).Infof("Node %s not found in Redis, skipping delete", uid)
-		return nil
+	if s.deltaLog {
+		return s.appendLog(graph.WriteOp{Type: graph.OpDeleteNode, UID: uid})
 	}
 
-	// Delete node
-	key := nodeKeyPrefix + string(uid)
-	if err := s.client.Del(s.ctx, key).Err(); err != nil {
-		return fmt.Errorf("failed to delete node from Redis: %w", err)
+	// Get the node to update indexes, and its edges, like batched deletes
+	node, err := s.getNode(uid)
+	if err != nil {
+		klog.V(4).Infof("Node %s not found in Redis, deleting its edges only", uid)
+		node = nil
 	}
-
-	// Remove from indexes
-	if err := s.removeFromIndexes(node); err != nil {
-		klog.Errorf("Failed to remove node from indexes: %v", err)
+	edgeKeys, err := s.nodeEdgeKeys(uid)
+	if err != nil {
+		return fmt.Errorf("failed to fetch edges for deletion: %w", err)
 	}
 
-	// Delete associated edges
-	if err := s.deleteNodeEdges(uid); err != nil {
-		klog.Errorf("Failed to delete edges for node %s: %v", uid, err)
+	// Delete node, its index entries and its edges in a single transaction
+	_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		s.markDirty(pipe)
+		pipe.Del(s.ctx, nodeKeyPrefix+string(uid))
+		if node != nil {
+			s.removeFromIndexes(pipe, node)
+		}
+		for _, key := range edgeKeys {
+			pipe.Del(s.ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete node from Redis: %w", err)
 	}
 
 	return nil
@@ -133,6 +192,25 @@ func (s *RedisStore) DeleteNode(uid types.UID) error {
 
 // GetNode retrieves a node from Redis
 func (s *RedisStore) GetNode(uid types.UID) (*graph.Node, error) {
+	if s.binary() {
+		g, err := s.storedGraph()
+		if err != nil {
+			return nil, err
+		}
+		node, exists := g.GetNode(uid)
+		if !exists {
+			return nil, fmt.Errorf("node not found: %s", uid)
+		}
+		return node, nil
+	}
+	if err := s.compactForRead(); err != nil {
+		return nil, err
+	}
+	return s.getNode(uid)
+}
+
+// getNode retrieves the stored record of a node
+func (s *RedisStore) getNode(uid types.UID) (*graph.Node, error) {
 	key := nodeKeyPrefix + string(uid)
 	data, err := s.client.Get(s.ctx, key).Bytes()
 	if err == redis.Nil {
@@ -142,6 +220,11 @@ func (s *RedisStore) GetNode(uid types.UID) (*graph.Node, error) {
 		return nil, fmt.Errorf("failed to get node from Redis: %w", err)
 	}
 
+	return decodeNode(data)
+}
+
+// decodeNode converts a serialized node into a graph.Node without edges
+func decodeNode(data []byte) (*graph.Node, error) {
 	var nodeData SerializedNode
 	if err := json.Unmarshal(data, &nodeData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal node: %w", err)
@@ -150,6 +233,7 @@ func (s *RedisStore) GetNode(uid types.UID) (*graph.Node, error) {
 	// Convert to graph.Node
 	node := &graph.Node{
 		UID:               nodeData.UID,
+		SourceUID:         nodeData.SourceUID,
 		Name:              nodeData.Name,
 		Namespace:         nodeData.Namespace,
 		Kind:              nodeData.Kind,
@@ -160,9 +244,13 @@ func (s *RedisStore) GetNode(uid types.UID) (*graph.Node, error) {
 		CreationTimestamp: nodeData.CreationTimestamp,
 		Status:            nodeData.Status,
 		StatusMessage:     nodeData.StatusMessage,
+		StatusReason:      nodeData.StatusReason,
+		Cluster:           nodeData.Cluster,
 		HelmChart:         nodeData.HelmChart,
 		HelmRelease:       nodeData.HelmRelease,
 		Metadata:          nodeData.Metadata,
+		FirstSeen:         nodeData.FirstSeen,
+		FirstReady:        nodeData.FirstReady,
 		OutgoingEdges:     make(map[types.UID]*graph.Edge),
 		IncomingEdges:     make(map[types.UID]*graph.Edge),
 	}
@@ -172,6 +260,17 @@ func (s *RedisStore) GetNode(uid types.UID) (*graph.Node, error) {
 
 // GetAllNodes retrieves all nodes from Redis
 func (s *RedisStore) GetAllNodes() ([]*graph.Node, error) {
+	if s.binary() {
+		g, err := s.storedGraph()
+		if err != nil {
+			return nil, err
+		}
+		return g.GetAllNodes(), nil
+	}
+	if err := s.compactForRead(); err != nil {
+		return nil, err
+	}
+
 	// Scan for all node keys
 	var cursor uint64
 	var nodes []*graph.Node
@@ -184,7 +283,7 @@ func (s *RedisStore) GetAllNodes() ([]*graph.Node, error) {
 
 		for _, key := range keys {
 			uid := types.UID(key[len(nodeKeyPrefix):])
-			node, err := s.GetNode(uid)
+			node, err := s.getNode(uid)
 			if err != nil {
 				klog.Errorf("Failed to get node %s: %v", uid, err)
 				continue
@@ -203,24 +302,49 @@ func (s *RedisStore) GetAllNodes() ([]*graph.Node, error) {
 
 // SaveEdge persists an edge to Redis
 func (s *RedisStore) SaveEdge(edge *graph.Edge) error {
+	if s.deltaLog {
+		return s.appendLog(graph.WriteOp{Type: graph.OpSaveEdge, Edge: edge})
+	}
+
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		s.markDirty(pipe)
+		_, _, err := s.queueSaveEdge(pipe, edge)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save edge to Redis: %w", err)
+	}
+
+	return nil
+}
+
+// queueSaveEdge queues the command persisting an edge and returns its key and stored record
+func (s *RedisStore) queueSaveEdge(pipe redis.Pipeliner, edge *graph.Edge) (string, []byte, error) {
 	data, err := json.Marshal(edge)
 	if err != nil {
-		return fmt.Errorf("failed to marshal edge: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal edge: %w", err)
 	}
 
 	// Save edge with composite key: from:to
 	key := edgeKeyPrefix + string(edge.FromUID) + ":" + string(edge.ToUID)
-	if err := s.client.Set(s.ctx, key, data, 0).Err(); err != nil {
-		return fmt.Errorf("failed to save edge to Redis: %w", err)
-	}
+	pipe.Set(s.ctx, key, data, 0)
 
-	return nil
+	return key, data, nil
 }
 
 // DeleteEdge removes an edge from Redis
 func (s *RedisStore) DeleteEdge(fromUID, toUID types.UID) error {
+	if s.deltaLog {
+		return s.appendLog(graph.WriteOp{Type: graph.OpDeleteEdge, UID: fromUID, ToUID: toUID})
+	}
+
 	key := edgeKeyPrefix + string(fromUID) + ":" + string(toUID)
-	if err := s.client.Del(s.ctx, key).Err(); err != nil {
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		s.markDirty(pipe)
+		pipe.Del(s.ctx, key)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to delete edge from Redis: %w", err)
 	}
 	return nil
@@ -228,6 +352,17 @@ func (s *RedisStore) DeleteEdge(fromUID, toUID types.UID) error {
 
 // GetAllEdges retrieves all edges from Redis
 func (s *RedisStore) GetAllEdges() ([]*graph.Edge, error) {
+	if s.binary() {
+		g, err := s.storedGraph()
+		if err != nil {
+			return nil, err
+		}
+		return storedEdges(g), nil
+	}
+	if err := s.compactForRead(); err != nil {
+		return nil, err
+	}
+
 	var cursor uint64
 	var edges []*graph.Edge
 
@@ -262,67 +397,349 @@ func (s *RedisStore) GetAllEdges() ([]*graph.Edge, error) {
 	return edges, nil
 }
 
-// LoadGraph loads the entire graph from Redis
+// WriteBatch applies a batch of write operations in order. With the delta log enabled they are
+// appended to the log, otherwise applied to the records.
+func (s *RedisStore) WriteBatch(ops []graph.WriteOp) error {
+	if s.deltaLog {
+		return s.appendLog(ops...)
+	}
+	return s.applyBatch(ops)
+}
+
+// applyBatch applies a batch of write operations in order using a single MULTI/EXEC pipeline.
+// Node deletions need the stored node to clean up its indexes and the keys of its edges, so
+// those are fetched beforehand; nodes and edges saved earlier in the batch are tracked so a
+// later delete of the same node removes them too.
+func (s *RedisStore) applyBatch(ops []graph.WriteOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	// Fetch nodes being deleted so their index entries can be removed
+	deleted := make(map[types.UID]*graph.Node)
+	edgeKeys := make(map[types.UID][]string)
+	var gets map[types.UID]*redis.StringCmd
+	for _, op := range ops {
+		if op.Type == graph.OpDeleteNode {
+			if gets == nil {
+				gets = make(map[types.UID]*redis.StringCmd)
+			}
+			gets[op.UID] = nil
+		}
+	}
+	if len(gets) > 0 {
+		pipe := s.client.Pipeline()
+		for uid := range gets {
+			gets[uid] = pipe.Get(s.ctx, nodeKeyPrefix+string(uid))
+		}
+		// Missing nodes surface as redis.Nil on the individual commands
+		if _, err := pipe.Exec(s.ctx); err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to fetch nodes for deletion: %w", err)
+		}
+		for uid, cmd := range gets {
+			keys, err := s.nodeEdgeKeys(uid)
+			if err != nil {
+				return fmt.Errorf("failed to fetch edges for deletion: %w", err)
+			}
+			edgeKeys[uid] = keys
+
+			data, err := cmd.Bytes()
+			if err != nil {
+				klog.V(4).Infof("Node %s not found in Redis, skipping delete", uid)
+				continue
+			}
+			node, err := decodeNode(data)
+			if err != nil {
+				klog.Errorf("Failed to decode node %s for deletion: %v", uid, err)
+				continue
+			}
+			deleted[uid] = node
+		}
+	}
+
+	// Nodes and edges written by earlier operations of the batch
+	saved := make(map[types.UID]*graph.Node)
+	savedEdges := make(map[string]*graph.Edge)
+
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		s.markDirty(pipe)
+		for _, op := range ops {
+			switch op.Type {
+			case graph.OpSaveNode:
+				if _, err := s.queueSaveNode(pipe, op.Node); err != nil {
+					klog.Errorf("Failed to queue node %s: %v", op.Node.UID, err)
+					continue
+				}
+				saved[op.Node.UID] = op.Node
+			case graph.OpDeleteNode:
+				pipe.Del(s.ctx, nodeKeyPrefix+string(op.UID))
+				if node, exists := deleted[op.UID]; exists {
+					s.removeFromIndexes(pipe, node)
+				}
+				if node, exists := saved[op.UID]; exists {
+					s.removeFromIndexes(pipe, node)
+					delete(saved, op.UID)
+				}
+				for _, key := range edgeKeys[op.UID] {
+					pipe.Del(s.ctx, key)
+				}
+				for key, edge := range savedEdges {
+					if edge.FromUID == op.UID || edge.ToUID == op.UID {
+						pipe.Del(s.ctx, key)
+						delete(savedEdges, key)
+					}
+				}
+			case graph.OpSaveEdge:
+				key, _, err := s.queueSaveEdge(pipe, op.Edge)
+				if err != nil {
+					klog.Errorf("Failed to queue edge %s->%s: %v", op.Edge.FromUID, op.Edge.ToUID, err)
+					continue
+				}
+				savedEdges[key] = op.Edge
+			case graph.OpDeleteEdge:
+				key := edgeKeyPrefix + string(op.UID) + ":" + string(op.ToUID)
+				pipe.Del(s.ctx, key)
+				delete(savedEdges, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute write batch: %w", err)
+	}
+
+	return nil
+}
+
+// LoadGraph loads the entire graph from Redis and verifies it against the snapshot manifest.
+// Data stored in the other snapshot format is converted to the configured one.
 func (s *RedisStore) LoadGraph() (*graph.Graph, error) {
 	klog.Info("Loading graph from Redis...")
 	start := time.Now()
 
-	g := graph.NewGraph()
-
-	// Load all nodes
-	nodes, err := s.GetAllNodes()
+	blob, err := s.loadSnapshot()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load nodes: %w", err)
+		return nil, err
 	}
 
-	klog.Infof("Loaded %d nodes from Redis", len(nodes))
+	var g *graph.Graph
+	var result loadResult
+	if blob != nil {
+		if g, result, err = loadBinary(blob); err != nil {
+			return nil, err
+		}
+		klog.Infof("Loaded %d nodes and %d edges from the binary snapshot (%d bytes)", result.nodes, result.edges, len(blob))
+	} else {
+		if !s.deltaLog {
+			// Fold a log left by a previous run with the delta log enabled into the records
+			if err := s.CompactLog(); err != nil {
+				return nil, fmt.Errorf("failed to compact change log: %w", err)
+			}
+		}
+		if g, result, err = s.loadRecords(); err != nil {
+			return nil, err
+		}
+	}
 
-	// Add nodes to graph
-	for _, node := range nodes {
+	if err := s.verify(result); err != nil {
+		return nil, err
+	}
+
+	// The writes after a binary snapshot are only kept in the log
+	if s.deltaLog || blob != nil {
+		if err := s.replayLog(g); err != nil {
+			return nil, fmt.Errorf("failed to replay change log: %w", err)
+		}
+	}
+
+	converted := (blob != nil) != s.binary() && (blob != nil || result.nodes > 0)
+	if remapped, changed := remapIDs(g); changed {
+		klog.Infof("Stored node IDs were created with another ID strategy, migrating them to %q", graph.CurrentIDStrategy().Name())
+		g = remapped
+		if err := s.rewrite(g); err != nil {
+			return nil, fmt.Errorf("failed to migrate node IDs: %w", err)
+		}
+	} else if converted {
+		klog.Infof("Converting the stored graph from %s to %s", result.format, s.format)
+		if err := s.rewrite(g); err != nil {
+			return nil, fmt.Errorf("failed to convert the stored graph: %w", err)
+		}
+	}
+	if blob != nil && !s.binary() {
+		if err := s.client.Del(s.ctx, snapshotKey).Err(); err != nil {
+			return nil, fmt.Errorf("failed to delete binary snapshot: %w", err)
+		}
+	}
+
+	klog.Infof("Graph loaded from Redis in %v", time.Since(start))
+
+	return g, nil
+}
+
+// loadRecords loads the node and edge records
+func (s *RedisStore) loadRecords() (*graph.Graph, loadResult, error) {
+	g := graph.NewGraph()
+	result := loadResult{format: SnapshotRecords}
+
+	// Load all nodes
+	err := s.scanRecords(nodeKeyPrefix, func(key string, data []byte) {
+		result.nodes++
+		result.nodeChecksum.add(key, data)
+
+		node, err := decodeNode(data)
+		if err != nil {
+			klog.Errorf("Failed to decode node %s: %v", key, err)
+			result.corruptNodes++
+			return
+		}
 		g.AddNode(node)
+	})
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to load nodes: %w", err)
 	}
 
+	klog.Infof("Loaded %d nodes from Redis", result.nodes)
+
 	// Load all edges
-	edges, err := s.GetAllEdges()
+	err = s.scanRecords(edgeKeyPrefix, func(key string, data []byte) {
+		result.edges++
+		result.edgeChecksum.add(key, data)
+
+		var edge graph.Edge
+		if err := json.Unmarshal(data, &edge); err != nil {
+			klog.Errorf("Failed to unmarshal edge %s: %v", key, err)
+			result.corruptEdges++
+			return
+		}
+		if !g.AddEdge(&edge) {
+			result.danglingEdges++
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to load edges: %w", err)
+		return nil, result, fmt.Errorf("failed to load edges: %w", err)
 	}
 
-	klog.Infof("Loaded %d edges from Redis", len(edges))
+	klog.Infof("Loaded %d edges from Redis", result.edges)
 
-	// Add edges to graph
-	for _, edge := range edges {
-		g.AddEdge(edge)
-	}
+	return g, result, nil
+}
 
-	klog.Infof("Graph loaded from Redis in %v", time.Since(start))
+// scanRecords calls fn with every string record under the key prefix, fetching them in pipelined chunks
+func (s *RedisStore) scanRecords(prefix string, fn func(key string, data []byte)) error {
+	var cursor uint64
+	for {
+		keys, nextCursor, err := s.client.Scan(s.ctx, cursor, prefix+"*", snapshotChunkSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", prefix, err)
+		}
 
-	return g, nil
+		if len(keys) > 0 {
+			values, err := s.client.MGet(s.ctx, keys...).Result()
+			if err != nil {
+				return fmt.Errorf("failed to fetch records: %w", err)
+			}
+			for i, value := range values {
+				// Keys deleted between SCAN and MGET come back as nil
+				if data, ok := value.(string); ok {
+					fn(keys[i], []byte(data))
+				}
+			}
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return nil
+		}
+	}
 }
 
-// SaveGraph saves the entire graph to Redis
+// SaveGraph saves the entire graph to Redis. The snapshot manifest is only written once every
+// chunk has been stored, so an interrupted snapshot is detected on the next load.
 func (s *RedisStore) SaveGraph(g *graph.Graph) error {
+	if s.binary() {
+		return s.saveBinary(g)
+	}
+
 	klog.Info("Saving graph to Redis...")
 	start := time.Now()
 
+	writes, err := s.beginSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to mark snapshot in progress: %w", err)
+	}
+	// Log entries appended before the snapshot starts are superseded by it
+	logTail, err := s.logTail()
+	if err != nil {
+		return fmt.Errorf("failed to read change log: %w", err)
+	}
+
 	nodes := g.GetAllNodes()
 
-	// Save all nodes
-	for _, node := range nodes {
-		if err := s.SaveNode(node); err != nil {
-			klog.Errorf("Failed to save node %s: %v", node.UID, err)
+	// Save nodes and their outgoing edges in pipelined chunks
+	edgeCount := 0
+	failed := 0
+	var nodeChecksum, edgeChecksum checksum
+	pipe := s.client.Pipeline()
+	queued := 0
+
+	flush := func() {
+		if queued == 0 {
+			return
+		}
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			klog.Errorf("Failed to save graph chunk: %v", err)
+			failed++
 		}
+		queued = 0
 	}
 
-	// Save all edges
-	edgeCount := 0
 	for _, node := range nodes {
+		data, err := s.queueSaveNode(pipe, node)
+		if err != nil {
+			klog.Errorf("Failed to save node %s: %v", node.UID, err)
+			failed++
+			continue
+		}
+		nodeChecksum.add(nodeKeyPrefix+string(node.UID), data)
+		queued++
+
 		for _, edge := range node.OutgoingEdges {
-			if err := s.SaveEdge(edge); err != nil {
+			key, data, err := s.queueSaveEdge(pipe, edge)
+			if err != nil {
 				klog.Errorf("Failed to save edge: %v", err)
-			} else {
-				edgeCount++
+				failed++
+				continue
 			}
+			edgeChecksum.add(key, data)
+			edgeCount++
+			queued++
+		}
+
+		if queued >= snapshotChunkSize {
+			flush()
+		}
+	}
+	flush()
+
+	if failed > 0 {
+		return fmt.Errorf("snapshot incomplete: %d write(s) failed", failed)
+	}
+
+	err = s.writeManifest(s.client, SnapshotManifest{
+		SchemaVersion: snapshotSchemaVersion,
+		Format:        SnapshotRecords,
+		Timestamp:     time.Now(),
+		Nodes:         len(nodes),
+		Edges:         edgeCount,
+		NodeChecksum:  nodeChecksum.String(),
+		EdgeChecksum:  edgeChecksum.String(),
+	}, writes)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot manifest: %w", err)
+	}
+	if logTail != "" {
+		if err := s.markCompacted(logTail); err != nil {
+			return fmt.Errorf("failed to trim change log: %w", err)
 		}
 	}
 
@@ -333,73 +750,57 @@ func (s *RedisStore) SaveGraph(g *graph.Graph) error {
 
 // Helper functions
 
-func (s *RedisStore) updateIndexes(node *graph.Node) error {
-	// Namespace/Kind index
-	nsKey := node.Namespace
-	if nsKey == "" {
-		nsKey = "_cluster"
-	}
-	indexKey := namespaceKindIndex + nsKey + ":" + node.Kind
-	if err := s.client.SAdd(s.ctx, indexKey, string(node.UID)).Err(); err != nil {
-		return err
-	}
-
-	// Helm release index
-	if node.HelmRelease != "" {
-		indexKey := helmReleaseIndex + node.HelmRelease
-		if err := s.client.SAdd(s.ctx, indexKey, string(node.UID)).Err(); err != nil {
-			return err
-		}
+func (s *RedisStore) updateIndexes(c redis.Cmdable, node *graph.Node) {
+	for _, indexKey := range indexKeys(node) {
+		c.SAdd(s.ctx, indexKey, string(node.UID))
 	}
+}
 
-	// Label indexes
-	for key, value := range node.Labels {
-		indexKey := labelIndex + key + ":" + value
-		if err := s.client.SAdd(s.ctx, indexKey, string(node.UID)).Err(); err != nil {
-			return err
-		}
+func (s *RedisStore) removeFromIndexes(c redis.Cmdable, node *graph.Node) {
+	for _, indexKey := range indexKeys(node) {
+		c.SRem(s.ctx, indexKey, string(node.UID))
 	}
-
-	return nil
 }
 
-func (s *RedisStore) removeFromIndexes(node *graph.Node) error {
+// indexKeys returns the keys of the index sets a node belongs to
+func indexKeys(node *graph.Node) []string {
 	// Namespace/Kind index
 	nsKey := node.Namespace
 	if nsKey == "" {
 		nsKey = "_cluster"
 	}
-	indexKey := namespaceKindIndex + nsKey + ":" + node.Kind
-	s.client.SRem(s.ctx, indexKey, string(node.UID))
+	keys := []string{namespaceKindIndex + nsKey + ":" + node.Kind}
 
 	// Helm release index
 	if node.HelmRelease != "" {
-		indexKey := helmReleaseIndex + node.HelmRelease
-		s.client.SRem(s.ctx, indexKey, string(node.UID))
+		keys = append(keys, helmReleaseIndex+node.HelmRelease)
 	}
 
 	// Label indexes
 	for key, value := range node.Labels {
-		indexKey := labelIndex + key + ":" + value
-		s.client.SRem(s.ctx, indexKey, string(node.UID))
+		keys = append(keys, labelIndex+key+":"+value)
 	}
-
-	return nil
+	return keys
 }
 
-func (s *RedisStore) deleteNodeEdges(uid types.UID) error {
-	// Delete all edges where this node is from or to
-	pattern := edgeKeyPrefix + string(uid) + ":*"
-	if err := s.deleteKeysByPattern(pattern); err != nil {
-		return err
-	}
-
-	pattern = edgeKeyPrefix + "*:" + string(uid)
-	if err := s.deleteKeysByPattern(pattern); err != nil {
-		return err
+// nodeEdgeKeys returns the keys of all stored edges from or to a node
+func (s *RedisStore) nodeEdgeKeys(uid types.UID) ([]string, error) {
+	var keys []string
+	for _, pattern := range []string{edgeKeyPrefix + string(uid) + ":*", edgeKeyPrefix + "*:" + string(uid)} {
+		var cursor uint64
+		for {
+			found, nextCursor, err := s.client.Scan(s.ctx, cursor, pattern, 100).Result()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, found...)
+			cursor = nextCursor
+			if cursor == 0 {
+				break
+			}
+		}
 	}
-
-	return nil
+	return keys, nil
 }
 
 func (s *RedisStore) deleteKeysByPattern(pattern string) error {
@@ -427,6 +828,7 @@ func (s *RedisStore) deleteKeysByPattern(pattern string) error {
 // SerializedNode is a node without edges for serialization
 type SerializedNode struct {
 	UID               types.UID               `json:"uid"`
+	SourceUID         types.UID               `json:"sourceUID,omitempty"`
 	Name              string                  `json:"name"`
 	Namespace         string                  `json:"namespace"`
 	Kind              string                  `json:"kind"`
@@ -437,9 +839,13 @@ type SerializedNode struct {
 	CreationTimestamp time.Time               `json:"creationTimestamp"`
 	Status            graph.ResourceStatus    `json:"status"`
 	StatusMessage     string                  `json:"statusMessage"`
+	StatusReason      string                  `json:"statusReason,omitempty"`
+	Cluster           string                  `json:"cluster,omitempty"`
 	HelmChart         string                  `json:"helmChart,omitempty"`
 	HelmRelease       string                  `json:"helmRelease,omitempty"`
 	Metadata          *graph.ResourceMetadata `json:"metadata,omitempty"`
+	FirstSeen         time.Time               `json:"firstSeen"`
+	FirstReady        time.Time               `json:"firstReady"`
 }
 
 // GetStats returns Redis statistics
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/storage/storagetest"
+)
+
+func TestRedisStoreConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) graph.PersistenceBackend {
+		store, err := NewRedisStore(miniredis.RunT(t).Addr(), "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
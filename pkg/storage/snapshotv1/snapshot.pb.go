@@ -0,0 +1,466 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: astrolabe/snapshot/v1/snapshot.proto
+
+package snapshotv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Snapshot is the whole graph as stored by --snapshot-format=binary, compressed with zstd.
+// Writes made after it are kept in the change log and replayed on load.
+type Snapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*Node                `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Edges         []*Edge                `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_astrolabe_snapshot_v1_snapshot_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Snapshot) GetNodes() []*Node {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *Snapshot) GetEdges() []*Edge {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
+// Node is a graph node without its edges
+type Node struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Uid               string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	SourceUid         string                 `protobuf:"bytes,2,opt,name=source_uid,json=sourceUid,proto3" json:"source_uid,omitempty"`
+	Name              string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Namespace         string                 `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind              string                 `protobuf:"bytes,5,opt,name=kind,proto3" json:"kind,omitempty"`
+	ApiVersion        string                 `protobuf:"bytes,6,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
+	ResourceVersion   string                 `protobuf:"bytes,7,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	Labels            map[string]string      `protobuf:"bytes,8,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations       map[string]string      `protobuf:"bytes,9,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CreationTimestamp *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=creation_timestamp,json=creationTimestamp,proto3" json:"creation_timestamp,omitempty"`
+	Status            string                 `protobuf:"bytes,11,opt,name=status,proto3" json:"status,omitempty"`
+	StatusMessage     string                 `protobuf:"bytes,12,opt,name=status_message,json=statusMessage,proto3" json:"status_message,omitempty"`
+	StatusReason      string                 `protobuf:"bytes,13,opt,name=status_reason,json=statusReason,proto3" json:"status_reason,omitempty"`
+	Cluster           string                 `protobuf:"bytes,14,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	HelmChart         string                 `protobuf:"bytes,15,opt,name=helm_chart,json=helmChart,proto3" json:"helm_chart,omitempty"`
+	HelmRelease       string                 `protobuf:"bytes,16,opt,name=helm_release,json=helmRelease,proto3" json:"helm_release,omitempty"`
+	// metadata is the JSON encoding of the kind-specific metadata, whose fields change too
+	// often to be mirrored here
+	Metadata      []byte                 `protobuf:"bytes,17,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	FirstSeen     *timestamppb.Timestamp `protobuf:"bytes,18,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	FirstReady    *timestamppb.Timestamp `protobuf:"bytes,19,opt,name=first_ready,json=firstReady,proto3" json:"first_ready,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Node) Reset() {
+	*x = Node{}
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Node) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Node) ProtoMessage() {}
+
+func (x *Node) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Node.ProtoReflect.Descriptor instead.
+func (*Node) Descriptor() ([]byte, []int) {
+	return file_astrolabe_snapshot_v1_snapshot_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Node) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *Node) GetSourceUid() string {
+	if x != nil {
+		return x.SourceUid
+	}
+	return ""
+}
+
+func (x *Node) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Node) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Node) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Node) GetApiVersion() string {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return ""
+}
+
+func (x *Node) GetResourceVersion() string {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return ""
+}
+
+func (x *Node) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Node) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *Node) GetCreationTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreationTimestamp
+	}
+	return nil
+}
+
+func (x *Node) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Node) GetStatusMessage() string {
+	if x != nil {
+		return x.StatusMessage
+	}
+	return ""
+}
+
+func (x *Node) GetStatusReason() string {
+	if x != nil {
+		return x.StatusReason
+	}
+	return ""
+}
+
+func (x *Node) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *Node) GetHelmChart() string {
+	if x != nil {
+		return x.HelmChart
+	}
+	return ""
+}
+
+func (x *Node) GetHelmRelease() string {
+	if x != nil {
+		return x.HelmRelease
+	}
+	return ""
+}
+
+func (x *Node) GetMetadata() []byte {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Node) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Node) GetFirstReady() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstReady
+	}
+	return nil
+}
+
+type Edge struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	FromUid       string                 `protobuf:"bytes,2,opt,name=from_uid,json=fromUid,proto3" json:"from_uid,omitempty"`
+	ToUid         string                 `protobuf:"bytes,3,opt,name=to_uid,json=toUid,proto3" json:"to_uid,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LastConfirmed *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_confirmed,json=lastConfirmed,proto3" json:"last_confirmed,omitempty"`
+	Stale         bool                   `protobuf:"varint,6,opt,name=stale,proto3" json:"stale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Edge) Reset() {
+	*x = Edge{}
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Edge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Edge) ProtoMessage() {}
+
+func (x *Edge) ProtoReflect() protoreflect.Message {
+	mi := &file_astrolabe_snapshot_v1_snapshot_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Edge.ProtoReflect.Descriptor instead.
+func (*Edge) Descriptor() ([]byte, []int) {
+	return file_astrolabe_snapshot_v1_snapshot_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Edge) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Edge) GetFromUid() string {
+	if x != nil {
+		return x.FromUid
+	}
+	return ""
+}
+
+func (x *Edge) GetToUid() string {
+	if x != nil {
+		return x.ToUid
+	}
+	return ""
+}
+
+func (x *Edge) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Edge) GetLastConfirmed() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastConfirmed
+	}
+	return nil
+}
+
+func (x *Edge) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+var File_astrolabe_snapshot_v1_snapshot_proto protoreflect.FileDescriptor
+
+const file_astrolabe_snapshot_v1_snapshot_proto_rawDesc = "" +
+	"\n" +
+	"$astrolabe/snapshot/v1/snapshot.proto\x12\x15astrolabe.snapshot.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"p\n" +
+	"\bSnapshot\x121\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x1b.astrolabe.snapshot.v1.NodeR\x05nodes\x121\n" +
+	"\x05edges\x18\x02 \x03(\v2\x1b.astrolabe.snapshot.v1.EdgeR\x05edges\"\xf4\x06\n" +
+	"\x04Node\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1d\n" +
+	"\n" +
+	"source_uid\x18\x02 \x01(\tR\tsourceUid\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x1c\n" +
+	"\tnamespace\x18\x04 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04kind\x18\x05 \x01(\tR\x04kind\x12\x1f\n" +
+	"\vapi_version\x18\x06 \x01(\tR\n" +
+	"apiVersion\x12)\n" +
+	"\x10resource_version\x18\a \x01(\tR\x0fresourceVersion\x12?\n" +
+	"\x06labels\x18\b \x03(\v2'.astrolabe.snapshot.v1.Node.LabelsEntryR\x06labels\x12N\n" +
+	"\vannotations\x18\t \x03(\v2,.astrolabe.snapshot.v1.Node.AnnotationsEntryR\vannotations\x12I\n" +
+	"\x12creation_timestamp\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\x11creationTimestamp\x12\x16\n" +
+	"\x06status\x18\v \x01(\tR\x06status\x12%\n" +
+	"\x0estatus_message\x18\f \x01(\tR\rstatusMessage\x12#\n" +
+	"\rstatus_reason\x18\r \x01(\tR\fstatusReason\x12\x18\n" +
+	"\acluster\x18\x0e \x01(\tR\acluster\x12\x1d\n" +
+	"\n" +
+	"helm_chart\x18\x0f \x01(\tR\thelmChart\x12!\n" +
+	"\fhelm_release\x18\x10 \x01(\tR\vhelmRelease\x12\x1a\n" +
+	"\bmetadata\x18\x11 \x01(\fR\bmetadata\x129\n" +
+	"\n" +
+	"first_seen\x18\x12 \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x12;\n" +
+	"\vfirst_ready\x18\x13 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"firstReady\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa9\x02\n" +
+	"\x04Edge\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x19\n" +
+	"\bfrom_uid\x18\x02 \x01(\tR\afromUid\x12\x15\n" +
+	"\x06to_uid\x18\x03 \x01(\tR\x05toUid\x12E\n" +
+	"\bmetadata\x18\x04 \x03(\v2).astrolabe.snapshot.v1.Edge.MetadataEntryR\bmetadata\x12A\n" +
+	"\x0elast_confirmed\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\rlastConfirmed\x12\x14\n" +
+	"\x05stale\x18\x06 \x01(\bR\x05stale\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01BCZAgithub.com/ammarlakis/astrolabe/pkg/storage/snapshotv1;snapshotv1b\x06proto3"
+
+var (
+	file_astrolabe_snapshot_v1_snapshot_proto_rawDescOnce sync.Once
+	file_astrolabe_snapshot_v1_snapshot_proto_rawDescData []byte
+)
+
+func file_astrolabe_snapshot_v1_snapshot_proto_rawDescGZIP() []byte {
+	file_astrolabe_snapshot_v1_snapshot_proto_rawDescOnce.Do(func() {
+		file_astrolabe_snapshot_v1_snapshot_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_astrolabe_snapshot_v1_snapshot_proto_rawDesc), len(file_astrolabe_snapshot_v1_snapshot_proto_rawDesc)))
+	})
+	return file_astrolabe_snapshot_v1_snapshot_proto_rawDescData
+}
+
+var file_astrolabe_snapshot_v1_snapshot_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_astrolabe_snapshot_v1_snapshot_proto_goTypes = []any{
+	(*Snapshot)(nil),              // 0: astrolabe.snapshot.v1.Snapshot
+	(*Node)(nil),                  // 1: astrolabe.snapshot.v1.Node
+	(*Edge)(nil),                  // 2: astrolabe.snapshot.v1.Edge
+	nil,                           // 3: astrolabe.snapshot.v1.Node.LabelsEntry
+	nil,                           // 4: astrolabe.snapshot.v1.Node.AnnotationsEntry
+	nil,                           // 5: astrolabe.snapshot.v1.Edge.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_astrolabe_snapshot_v1_snapshot_proto_depIdxs = []int32{
+	1, // 0: astrolabe.snapshot.v1.Snapshot.nodes:type_name -> astrolabe.snapshot.v1.Node
+	2, // 1: astrolabe.snapshot.v1.Snapshot.edges:type_name -> astrolabe.snapshot.v1.Edge
+	3, // 2: astrolabe.snapshot.v1.Node.labels:type_name -> astrolabe.snapshot.v1.Node.LabelsEntry
+	4, // 3: astrolabe.snapshot.v1.Node.annotations:type_name -> astrolabe.snapshot.v1.Node.AnnotationsEntry
+	6, // 4: astrolabe.snapshot.v1.Node.creation_timestamp:type_name -> google.protobuf.Timestamp
+	6, // 5: astrolabe.snapshot.v1.Node.first_seen:type_name -> google.protobuf.Timestamp
+	6, // 6: astrolabe.snapshot.v1.Node.first_ready:type_name -> google.protobuf.Timestamp
+	5, // 7: astrolabe.snapshot.v1.Edge.metadata:type_name -> astrolabe.snapshot.v1.Edge.MetadataEntry
+	6, // 8: astrolabe.snapshot.v1.Edge.last_confirmed:type_name -> google.protobuf.Timestamp
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_astrolabe_snapshot_v1_snapshot_proto_init() }
+func file_astrolabe_snapshot_v1_snapshot_proto_init() {
+	if File_astrolabe_snapshot_v1_snapshot_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_astrolabe_snapshot_v1_snapshot_proto_rawDesc), len(file_astrolabe_snapshot_v1_snapshot_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_astrolabe_snapshot_v1_snapshot_proto_goTypes,
+		DependencyIndexes: file_astrolabe_snapshot_v1_snapshot_proto_depIdxs,
+		MessageInfos:      file_astrolabe_snapshot_v1_snapshot_proto_msgTypes,
+	}.Build()
+	File_astrolabe_snapshot_v1_snapshot_proto = out.File
+	file_astrolabe_snapshot_v1_snapshot_proto_goTypes = nil
+	file_astrolabe_snapshot_v1_snapshot_proto_depIdxs = nil
+}
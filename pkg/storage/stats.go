@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+)
+
+// keyFamilyPrefix is the prefix of the keys counted by StoreStats
+const keyFamilyPrefix = "astrolabe:"
+
+// StoreStats counts the keys of each family (node, edge, index, log, ...) and reports the
+// memory used by Redis and the manifest of the last snapshot. Keys are counted with SCAN, so
+// the cost follows the size of the database.
+func (s *RedisStore) StoreStats(ctx context.Context) (*graph.StoreStats, error) {
+	stats := &graph.StoreStats{Keys: make(map[string]int64)}
+
+	var cursor uint64
+	for {
+		keys, nextCursor, err := s.client.Scan(ctx, cursor, keyFamilyPrefix+"*", snapshotChunkSize).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan keys: %w", err)
+		}
+		for _, key := range keys {
+			family, _, _ := strings.Cut(strings.TrimPrefix(key, keyFamilyPrefix), ":")
+			stats.Keys[family]++
+			stats.TotalKeys++
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+
+	info, err := s.client.Info(ctx, "memory").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory info: %w", err)
+	}
+	stats.UsedMemoryBytes = usedMemory(info)
+
+	manifest, err := s.readManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot manifest: %w", err)
+	}
+	if manifest != nil {
+		stats.LastSnapshot = &graph.SnapshotInfo{
+			Timestamp: manifest.Timestamp,
+			Format:    string(manifest.Format),
+			Nodes:     manifest.Nodes,
+			Edges:     manifest.Edges,
+			Dirty:     manifest.Dirty,
+		}
+	}
+	return stats, nil
+}
+
+// usedMemory returns the used_memory field of the memory section of INFO, 0 if missing
+func usedMemory(info string) int64 {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "used_memory:"); found {
+			used, _ := strconv.ParseInt(value, 10, 64)
+			return used
+		}
+	}
+	return 0
+}
@@ -0,0 +1,502 @@
+// Package storagetest is a conformance suite for graph.PersistenceBackend implementations.
+//
+// Backends call Run from their own tests with a factory returning an empty backend:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) graph.PersistenceBackend {
+//			return newEmptyBackend(t)
+//		})
+//	}
+//
+// The suite checks the semantics the persistent graph relies on: round-trips of nodes and
+// edges, idempotent saves and deletes, in-order application of write batches, cleanup of a
+// deleted node's edges, full graph load and save, and safety under concurrent writers.
+package storagetest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Factory returns a new, empty backend. The suite closes it at the end of each test.
+type Factory func(t *testing.T) graph.PersistenceBackend
+
+// Run runs the conformance suite against backends created by newBackend
+func Run(t *testing.T, newBackend Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b graph.PersistenceBackend)
+	}{
+		{"NodeRoundTrip", testNodeRoundTrip},
+		{"GetMissingNode", testGetMissingNode},
+		{"SaveNodeIdempotent", testSaveNodeIdempotent},
+		{"SaveNodeOverwrites", testSaveNodeOverwrites},
+		{"DeleteNode", testDeleteNode},
+		{"DeleteMissingNode", testDeleteMissingNode},
+		{"DeleteNodeRemovesEdges", testDeleteNodeRemovesEdges},
+		{"EdgeRoundTrip", testEdgeRoundTrip},
+		{"SaveEdgeIdempotent", testSaveEdgeIdempotent},
+		{"DeleteEdge", testDeleteEdge},
+		{"DeleteMissingEdge", testDeleteMissingEdge},
+		{"EmptyBatch", testEmptyBatch},
+		{"BatchAppliesInOrder", testBatchAppliesInOrder},
+		{"BatchDeleteRemovesEdgesSavedEarlier", testBatchDeleteRemovesEdgesSavedEarlier},
+		{"BatchRecreateAfterDelete", testBatchRecreateAfterDelete},
+		{"LoadGraph", testLoadGraph},
+		{"LoadGraphEmpty", testLoadGraphEmpty},
+		{"SaveGraphRoundTrip", testSaveGraphRoundTrip},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := newBackend(t)
+			defer func() {
+				if err := backend.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			}()
+			tc.test(t, backend)
+		})
+	}
+}
+
+// Fixtures
+
+func newNode(uid, kind, name string) *graph.Node {
+	return &graph.Node{
+		UID:               types.UID(uid),
+		Name:              name,
+		Namespace:         "default",
+		Kind:              kind,
+		APIVersion:        "v1",
+		ResourceVersion:   "1",
+		Labels:            map[string]string{"app": name},
+		Annotations:       map[string]string{},
+		CreationTimestamp: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		FirstSeen:         time.Date(2024, 1, 15, 10, 30, 1, 0, time.UTC),
+		FirstReady:        time.Date(2024, 1, 15, 10, 30, 12, 0, time.UTC),
+		Status:            graph.StatusReady,
+		StatusMessage:     "ready",
+		StatusReason:      graph.ReasonReplicasReady,
+		HelmRelease:       "release",
+		HelmChart:         "chart-1.0.0",
+		Metadata:          &graph.ResourceMetadata{Image: "nginx:1.25", RestartCount: 1},
+		OutgoingEdges:     make(map[types.UID]*graph.Edge),
+		IncomingEdges:     make(map[types.UID]*graph.Edge),
+	}
+}
+
+func newEdge(from, to string, edgeType graph.EdgeType) *graph.Edge {
+	return &graph.Edge{
+		Type:          edgeType,
+		FromUID:       types.UID(from),
+		ToUID:         types.UID(to),
+		LastConfirmed: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+	}
+}
+
+// Helpers
+
+func mustSaveNodes(t *testing.T, b graph.PersistenceBackend, nodes ...*graph.Node) {
+	t.Helper()
+	for _, node := range nodes {
+		if err := b.SaveNode(node); err != nil {
+			t.Fatalf("SaveNode(%s): %v", node.UID, err)
+		}
+	}
+}
+
+func mustSaveEdges(t *testing.T, b graph.PersistenceBackend, edges ...*graph.Edge) {
+	t.Helper()
+	for _, edge := range edges {
+		if err := b.SaveEdge(edge); err != nil {
+			t.Fatalf("SaveEdge(%s->%s): %v", edge.FromUID, edge.ToUID, err)
+		}
+	}
+}
+
+func nodeUIDs(t *testing.T, b graph.PersistenceBackend) []string {
+	t.Helper()
+	nodes, err := b.GetAllNodes()
+	if err != nil {
+		t.Fatalf("GetAllNodes: %v", err)
+	}
+	uids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		uids = append(uids, string(node.UID))
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+func edgeKeys(t *testing.T, b graph.PersistenceBackend) []string {
+	t.Helper()
+	edges, err := b.GetAllEdges()
+	if err != nil {
+		t.Fatalf("GetAllEdges: %v", err)
+	}
+	keys := make([]string, 0, len(edges))
+	for _, edge := range edges {
+		keys = append(keys, fmt.Sprintf("%s->%s:%s", edge.FromUID, edge.ToUID, edge.Type))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func expectStrings(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func expectNodeEqual(t *testing.T, got, want *graph.Node) {
+	t.Helper()
+	switch {
+	case got.UID != want.UID:
+		t.Errorf("UID = %q, want %q", got.UID, want.UID)
+	case got.Name != want.Name || got.Namespace != want.Namespace || got.Kind != want.Kind:
+		t.Errorf("identity = %s/%s/%s, want %s/%s/%s", got.Namespace, got.Kind, got.Name, want.Namespace, want.Kind, want.Name)
+	case got.APIVersion != want.APIVersion || got.ResourceVersion != want.ResourceVersion:
+		t.Errorf("versions = %s/%s, want %s/%s", got.APIVersion, got.ResourceVersion, want.APIVersion, want.ResourceVersion)
+	case got.Status != want.Status || got.StatusMessage != want.StatusMessage || got.StatusReason != want.StatusReason:
+		t.Errorf("status = %s/%s (%s), want %s/%s (%s)", got.Status, got.StatusReason, got.StatusMessage, want.Status, want.StatusReason, want.StatusMessage)
+	case got.HelmRelease != want.HelmRelease || got.HelmChart != want.HelmChart:
+		t.Errorf("helm = %s/%s, want %s/%s", got.HelmRelease, got.HelmChart, want.HelmRelease, want.HelmChart)
+	case !got.CreationTimestamp.Equal(want.CreationTimestamp):
+		t.Errorf("creationTimestamp = %v, want %v", got.CreationTimestamp, want.CreationTimestamp)
+	case !got.FirstSeen.Equal(want.FirstSeen) || !got.FirstReady.Equal(want.FirstReady):
+		t.Errorf("firstSeen/firstReady = %v/%v, want %v/%v", got.FirstSeen, got.FirstReady, want.FirstSeen, want.FirstReady)
+	case len(got.Labels) != len(want.Labels):
+		t.Errorf("labels = %v, want %v", got.Labels, want.Labels)
+	case got.Metadata == nil || got.Metadata.Image != want.Metadata.Image || got.Metadata.RestartCount != want.Metadata.RestartCount:
+		t.Errorf("metadata = %+v, want %+v", got.Metadata, want.Metadata)
+	}
+	for key, value := range want.Labels {
+		if got.Labels[key] != value {
+			t.Errorf("label %s = %q, want %q", key, got.Labels[key], value)
+		}
+	}
+}
+
+// Nodes
+
+func testNodeRoundTrip(t *testing.T, b graph.PersistenceBackend) {
+	want := newNode("uid-a", "Pod", "a")
+	mustSaveNodes(t, b, want)
+
+	got, err := b.GetNode(want.UID)
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	expectNodeEqual(t, got, want)
+}
+
+func testGetMissingNode(t *testing.T, b graph.PersistenceBackend) {
+	if _, err := b.GetNode("missing"); err == nil {
+		t.Fatal("GetNode of a missing node returned no error")
+	}
+}
+
+func testSaveNodeIdempotent(t *testing.T, b graph.PersistenceBackend) {
+	node := newNode("uid-a", "Pod", "a")
+	mustSaveNodes(t, b, node, node, node)
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-a")
+}
+
+func testSaveNodeOverwrites(t *testing.T, b graph.PersistenceBackend) {
+	node := newNode("uid-a", "Pod", "a")
+	mustSaveNodes(t, b, node)
+
+	updated := newNode("uid-a", "Pod", "a")
+	updated.Status = graph.StatusError
+	updated.StatusMessage = "crashed"
+	updated.ResourceVersion = "2"
+	mustSaveNodes(t, b, updated)
+
+	got, err := b.GetNode("uid-a")
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	expectNodeEqual(t, got, updated)
+}
+
+func testDeleteNode(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Pod", "b"))
+
+	if err := b.DeleteNode("uid-a"); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-b")
+	if _, err := b.GetNode("uid-a"); err == nil {
+		t.Fatal("GetNode of a deleted node returned no error")
+	}
+}
+
+func testDeleteMissingNode(t *testing.T, b graph.PersistenceBackend) {
+	if err := b.DeleteNode("missing"); err != nil {
+		t.Fatalf("DeleteNode of a missing node: %v", err)
+	}
+}
+
+func testDeleteNodeRemovesEdges(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"), newNode("uid-c", "ConfigMap", "c"))
+	mustSaveEdges(t, b,
+		newEdge("uid-b", "uid-a", graph.EdgeServiceSelector),
+		newEdge("uid-a", "uid-c", graph.EdgeConfigMapRef),
+		newEdge("uid-b", "uid-c", graph.EdgeConfigMapRef),
+	)
+
+	if err := b.DeleteNode("uid-a"); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	expectStrings(t, "edges", edgeKeys(t, b), "uid-b->uid-c:uses-configmap")
+}
+
+// Edges
+
+func testEdgeRoundTrip(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"))
+	want := newEdge("uid-b", "uid-a", graph.EdgeServiceSelector)
+	want.Metadata = map[string]string{"port": "8080"}
+	mustSaveEdges(t, b, want)
+
+	edges, err := b.GetAllEdges()
+	if err != nil {
+		t.Fatalf("GetAllEdges: %v", err)
+	}
+	if len(edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(edges))
+	}
+	got := edges[0]
+	if got.Type != want.Type || got.FromUID != want.FromUID || got.ToUID != want.ToUID {
+		t.Errorf("edge = %s %s->%s, want %s %s->%s", got.Type, got.FromUID, got.ToUID, want.Type, want.FromUID, want.ToUID)
+	}
+	if got.Metadata["port"] != "8080" {
+		t.Errorf("edge metadata = %v, want %v", got.Metadata, want.Metadata)
+	}
+	if !got.LastConfirmed.Equal(want.LastConfirmed) {
+		t.Errorf("lastConfirmed = %v, want %v", got.LastConfirmed, want.LastConfirmed)
+	}
+}
+
+func testSaveEdgeIdempotent(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"))
+	edge := newEdge("uid-b", "uid-a", graph.EdgeServiceSelector)
+	mustSaveEdges(t, b, edge, edge)
+
+	// Edges are keyed by their endpoints, the last write wins
+	mustSaveEdges(t, b, newEdge("uid-b", "uid-a", graph.EdgeOwnership))
+	expectStrings(t, "edges", edgeKeys(t, b), "uid-b->uid-a:owns")
+}
+
+func testDeleteEdge(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"))
+	mustSaveEdges(t, b, newEdge("uid-b", "uid-a", graph.EdgeServiceSelector), newEdge("uid-a", "uid-b", graph.EdgeOwnership))
+
+	if err := b.DeleteEdge("uid-b", "uid-a"); err != nil {
+		t.Fatalf("DeleteEdge: %v", err)
+	}
+	expectStrings(t, "edges", edgeKeys(t, b), "uid-a->uid-b:owns")
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-a", "uid-b")
+}
+
+func testDeleteMissingEdge(t *testing.T, b graph.PersistenceBackend) {
+	if err := b.DeleteEdge("missing-a", "missing-b"); err != nil {
+		t.Fatalf("DeleteEdge of a missing edge: %v", err)
+	}
+}
+
+// Batches
+
+func testEmptyBatch(t *testing.T, b graph.PersistenceBackend) {
+	if err := b.WriteBatch(nil); err != nil {
+		t.Fatalf("WriteBatch(nil): %v", err)
+	}
+}
+
+func testBatchAppliesInOrder(t *testing.T, b graph.PersistenceBackend) {
+	a := newNode("uid-a", "Pod", "a")
+	c := newNode("uid-c", "Pod", "c")
+	updated := newNode("uid-c", "Pod", "c")
+	updated.Status = graph.StatusError
+
+	err := b.WriteBatch([]graph.WriteOp{
+		{Type: graph.OpSaveNode, Node: a},
+		{Type: graph.OpDeleteNode, UID: a.UID},
+		{Type: graph.OpSaveNode, Node: c},
+		{Type: graph.OpSaveNode, Node: updated},
+	})
+	if err != nil {
+		t.Fatalf("WriteBatch: %v", err)
+	}
+
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-c")
+	got, err := b.GetNode("uid-c")
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if got.Status != graph.StatusError {
+		t.Errorf("status = %s, want the last saved status %s", got.Status, graph.StatusError)
+	}
+}
+
+func testBatchDeleteRemovesEdgesSavedEarlier(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-b", "Service", "b"))
+
+	err := b.WriteBatch([]graph.WriteOp{
+		{Type: graph.OpSaveNode, Node: newNode("uid-a", "Pod", "a")},
+		{Type: graph.OpSaveEdge, Edge: newEdge("uid-b", "uid-a", graph.EdgeServiceSelector)},
+		{Type: graph.OpDeleteNode, UID: "uid-a"},
+	})
+	if err != nil {
+		t.Fatalf("WriteBatch: %v", err)
+	}
+
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-b")
+	expectStrings(t, "edges", edgeKeys(t, b))
+}
+
+func testBatchRecreateAfterDelete(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"))
+	mustSaveEdges(t, b, newEdge("uid-b", "uid-a", graph.EdgeServiceSelector))
+
+	// Stable IDs (e.g. the logical ID strategy) are reused when an object is recreated
+	err := b.WriteBatch([]graph.WriteOp{
+		{Type: graph.OpDeleteNode, UID: "uid-a"},
+		{Type: graph.OpSaveNode, Node: newNode("uid-a", "Pod", "a")},
+		{Type: graph.OpSaveEdge, Edge: newEdge("uid-b", "uid-a", graph.EdgeHasEndpoints)},
+	})
+	if err != nil {
+		t.Fatalf("WriteBatch: %v", err)
+	}
+
+	expectStrings(t, "nodes", nodeUIDs(t, b), "uid-a", "uid-b")
+	expectStrings(t, "edges", edgeKeys(t, b), "uid-b->uid-a:has-endpoints")
+}
+
+// Graphs
+
+func testLoadGraph(t *testing.T, b graph.PersistenceBackend) {
+	mustSaveNodes(t, b, newNode("uid-a", "Pod", "a"), newNode("uid-b", "Service", "b"))
+	mustSaveEdges(t, b, newEdge("uid-b", "uid-a", graph.EdgeServiceSelector))
+
+	g, err := b.LoadGraph()
+	if err != nil {
+		t.Fatalf("LoadGraph: %v", err)
+	}
+
+	nodes := g.GetAllNodes()
+	if len(nodes) != 2 {
+		t.Fatalf("loaded %d nodes, want 2", len(nodes))
+	}
+	service, exists := g.GetNode("uid-b")
+	if !exists {
+		t.Fatal("node uid-b not loaded")
+	}
+	edge, exists := service.OutgoingEdges["uid-a"]
+	if !exists || edge.Type != graph.EdgeServiceSelector {
+		t.Fatalf("edge uid-b->uid-a not loaded: %v", service.OutgoingEdges)
+	}
+	if pod, _ := g.GetNode("uid-a"); pod == nil || pod.IncomingEdges["uid-b"] == nil {
+		t.Fatal("edge uid-b->uid-a missing from the incoming edges of uid-a")
+	}
+	if len(g.GetNodesByNamespaceKind("default", "Pod")) != 1 {
+		t.Error("loaded nodes are not indexed")
+	}
+}
+
+func testLoadGraphEmpty(t *testing.T, b graph.PersistenceBackend) {
+	g, err := b.LoadGraph()
+	if err != nil {
+		t.Fatalf("LoadGraph of an empty backend: %v", err)
+	}
+	if nodes := g.GetAllNodes(); len(nodes) != 0 {
+		t.Fatalf("loaded %d nodes from an empty backend", len(nodes))
+	}
+}
+
+func testSaveGraphRoundTrip(t *testing.T, b graph.PersistenceBackend) {
+	g := graph.NewGraph()
+	for i := 0; i < 50; i++ {
+		g.AddNode(newNode(fmt.Sprintf("uid-%02d", i), "Pod", fmt.Sprintf("pod-%02d", i)))
+	}
+	for i := 1; i < 50; i++ {
+		g.AddEdge(newEdge("uid-00", fmt.Sprintf("uid-%02d", i), graph.EdgeOwnership))
+	}
+
+	if err := b.SaveGraph(g); err != nil {
+		t.Fatalf("SaveGraph: %v", err)
+	}
+	loaded, err := b.LoadGraph()
+	if err != nil {
+		t.Fatalf("LoadGraph: %v", err)
+	}
+
+	if got := len(loaded.GetAllNodes()); got != 50 {
+		t.Fatalf("loaded %d nodes, want 50", got)
+	}
+	owner, exists := loaded.GetNode("uid-00")
+	if !exists {
+		t.Fatal("node uid-00 not loaded")
+	}
+	if got := len(owner.OutgoingEdges); got != 49 {
+		t.Fatalf("loaded %d edges, want 49", got)
+	}
+}
+
+func testConcurrentWrites(t *testing.T, b graph.PersistenceBackend) {
+	const writers, perWriter = 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*3)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				uid := fmt.Sprintf("uid-%d-%d", w, i)
+				if err := b.SaveNode(newNode(uid, "Pod", uid)); err != nil {
+					errs <- err
+				}
+				if i > 0 {
+					if err := b.SaveEdge(newEdge(fmt.Sprintf("uid-%d-0", w), uid, graph.EdgeOwnership)); err != nil {
+						errs <- err
+					}
+				}
+				// Every other node is deleted again, together with its edge
+				if i%2 == 1 {
+					if err := b.DeleteNode(types.UID(uid)); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write: %v", err)
+	}
+
+	wantNodes := writers * (perWriter + 1) / 2
+	if got := len(nodeUIDs(t, b)); got != wantNodes {
+		t.Errorf("got %d nodes, want %d", got, wantNodes)
+	}
+	wantEdges := writers * (perWriter/2 + perWriter%2 - 1)
+	if got := len(edgeKeys(t, b)); got != wantEdges {
+		t.Errorf("got %d edges, want %d", got, wantEdges)
+	}
+}
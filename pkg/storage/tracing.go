@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ammarlakis/astrolabe/pkg/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// tracingHook records a span of every Redis command and pipeline
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis "+cmd.Name(),
+			semconv.DBSystemRedis,
+			semconv.DBOperationName(cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		tracing.End(span, commandError(err))
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis pipeline",
+			semconv.DBSystemRedis,
+			attribute.Int("db.redis.pipeline_length", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		tracing.End(span, commandError(err))
+		return err
+	}
+}
+
+// commandError returns the error of a command, except redis.Nil for missing keys, which is not
+// a failure
+func commandError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+// Package supervisor keeps the long-running subsystems of the server alive: a subsystem that
+// returns or panics before its context is cancelled is logged, counted and restarted with
+// exponential backoff, instead of leaving the server half-alive.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// initialBackoff is the delay before the first restart of a failed subsystem
+	initialBackoff = time.Second
+	// maxBackoff caps the delay between restarts
+	maxBackoff = 2 * time.Minute
+	// healthyAfter is how long a subsystem must run for its backoff to be reset
+	healthyAfter = time.Minute
+)
+
+// Restart reasons, used as metric label values
+const (
+	reasonPanic  = "panic"
+	reasonError  = "error"
+	reasonExited = "exited"
+)
+
+// Go runs a subsystem in the background under supervision, see Run
+func Go(ctx context.Context, name string, run func(ctx context.Context) error) {
+	go Run(ctx, name, run)
+}
+
+// Run runs a subsystem until ctx is cancelled. run should block until ctx is done; when it
+// returns earlier, with or without an error, or panics, it is restarted after a backoff.
+func Run(ctx context.Context, name string, run func(ctx context.Context) error) {
+	backoff := initialBackoff
+	restarts := 0
+
+	for {
+		started := time.Now()
+		metrics.SubsystemUp.WithLabelValues(name).Set(1)
+		err := runOnce(ctx, run)
+		metrics.SubsystemUp.WithLabelValues(name).Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+
+		uptime := time.Since(started)
+		if uptime >= healthyAfter {
+			backoff = initialBackoff
+		}
+
+		var panicked *panicError
+		reason := reasonExited
+		switch {
+		case errors.As(err, &panicked):
+			reason = reasonPanic
+		case err != nil:
+			reason = reasonError
+		default:
+			err = fmt.Errorf("subsystem returned before shutdown")
+		}
+		restarts++
+		metrics.SubsystemRestarts.WithLabelValues(name, reason).Inc()
+		klog.ErrorS(err, "Subsystem stopped, restarting", "subsystem", name, "reason", reason,
+			"uptime", uptime.Round(time.Millisecond).String(), "restarts", restarts, "backoff", backoff.String())
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// panicError is a panic recovered from a subsystem
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.value, e.stack)
+}
+
+// runOnce runs a subsystem, turning a panic into a panicError carrying the stack trace
+func runOnce(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return run(ctx)
+}
@@ -0,0 +1,264 @@
+package tenancy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Graph returns a view of g limited to the scope, or g itself when the scope is unrestricted.
+// Reads return only the nodes in scope; writes go to g.
+func (s *Scope) Graph(g graph.GraphInterface) graph.GraphInterface {
+	if s.Unrestricted() {
+		return g
+	}
+	return &scopedGraph{GraphInterface: g, scope: s}
+}
+
+// scopedGraph filters the reads of a graph by a scope. Namespaced resources are in scope when
+// their namespace and release are. Cluster-scoped resources are in scope when they belong to a
+// release installed in a namespace in scope, or are directly related to a namespaced resource
+// in scope (the PersistentVolume of a PersistentVolumeClaim, the Node of a Pod).
+type scopedGraph struct {
+	graph.GraphInterface
+	scope *Scope
+}
+
+// AllowsDetached reports whether a node kept outside the graph, such as the tombstone of a
+// deleted resource, is in scope. Its neighbours are no longer known, so cluster-scoped
+// resources are only in scope through the release they belong to.
+func (s *Scope) AllowsDetached(node *graph.Node) bool {
+	if s.Unrestricted() {
+		return true
+	}
+	if node.Namespace != "" {
+		return s.AllowsNamespace(node.Namespace) && s.AllowsRelease(node.HelmRelease)
+	}
+	if node.HelmRelease == "" || !s.AllowsRelease(node.HelmRelease) {
+		return false
+	}
+	namespace := node.Annotations["meta.helm.sh/release-namespace"]
+	return namespace != "" && s.AllowsNamespace(namespace)
+}
+
+func (g *scopedGraph) visible(node *graph.Node) bool {
+	if node.Namespace != "" {
+		return g.namespacedVisible(node)
+	}
+	if node.HelmRelease != "" && g.scope.AllowsRelease(node.HelmRelease) {
+		if namespace := node.Annotations["meta.helm.sh/release-namespace"]; namespace != "" && g.scope.AllowsNamespace(namespace) {
+			return true
+		}
+	}
+	for uid := range node.OutgoingEdges {
+		if neighbour, exists := g.GraphInterface.GetNode(uid); exists && neighbour.Namespace != "" && g.namespacedVisible(neighbour) {
+			return true
+		}
+	}
+	for uid := range node.IncomingEdges {
+		if neighbour, exists := g.GraphInterface.GetNode(uid); exists && neighbour.Namespace != "" && g.namespacedVisible(neighbour) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *scopedGraph) namespacedVisible(node *graph.Node) bool {
+	return g.scope.AllowsNamespace(node.Namespace) && g.scope.AllowsRelease(node.HelmRelease)
+}
+
+func (g *scopedGraph) filter(nodes []*graph.Node) []*graph.Node {
+	result := make([]*graph.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if g.visible(node) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func (g *scopedGraph) GetNode(uid types.UID) (*graph.Node, bool) {
+	node, exists := g.GraphInterface.GetNode(uid)
+	if !exists || !g.visible(node) {
+		return nil, false
+	}
+	return node, true
+}
+
+func (g *scopedGraph) GetAllNodes() []*graph.Node {
+	return g.filter(g.GraphInterface.GetAllNodes())
+}
+
+func (g *scopedGraph) GetNodesByNamespaceKind(namespace, kind string) []*graph.Node {
+	return g.filter(g.GraphInterface.GetNodesByNamespaceKind(namespace, kind))
+}
+
+func (g *scopedGraph) GetNodeByRef(namespace, kind, name string) (*graph.Node, bool) {
+	node, exists := g.GraphInterface.GetNodeByRef(namespace, kind, name)
+	if !exists || !g.visible(node) {
+		return nil, false
+	}
+	return node, true
+}
+
+func (g *scopedGraph) GetNodesByHelmRelease(release string) []*graph.Node {
+	if !g.scope.AllowsRelease(release) {
+		return nil
+	}
+	return g.filter(g.GraphInterface.GetNodesByHelmRelease(release))
+}
+
+func (g *scopedGraph) GetNodesByLabelRequirements(requirements []graph.LabelRequirement) ([]*graph.Node, error) {
+	nodes, err := g.GraphInterface.GetNodesByLabelRequirements(requirements)
+	if err != nil {
+		return nil, err
+	}
+	return g.filter(nodes), nil
+}
+
+func (g *scopedGraph) GetNodesByGroup(grouper, group string) []*graph.Node {
+	return g.filter(g.GraphInterface.GetNodesByGroup(grouper, group))
+}
+
+func (g *scopedGraph) OwnedDescendants(uid types.UID) []*graph.Node {
+	if _, exists := g.GetNode(uid); !exists {
+		return nil
+	}
+	return g.filter(g.GraphInterface.OwnedDescendants(uid))
+}
+
+// GetAllHelmReleases returns the releases with resources in scope
+func (g *scopedGraph) GetAllHelmReleases() []string {
+	releases := make([]string, 0)
+	for _, release := range g.GraphInterface.GetAllHelmReleases() {
+		if len(g.GetNodesByHelmRelease(release)) > 0 {
+			releases = append(releases, release)
+		}
+	}
+	return releases
+}
+
+// GetAllHelmCharts returns the charts of the resources in scope
+func (g *scopedGraph) GetAllHelmCharts() []string {
+	seen := make(map[string]bool)
+	charts := make([]string, 0)
+	for _, node := range g.GetAllNodes() {
+		if node.HelmChart != "" && !seen[node.HelmChart] {
+			seen[node.HelmChart] = true
+			charts = append(charts, node.HelmChart)
+		}
+	}
+	return charts
+}
+
+func (g *scopedGraph) GetChartReleases(chart string) []graph.ChartRelease {
+	releases := make([]graph.ChartRelease, 0)
+	for _, release := range g.GraphInterface.GetChartReleases(chart) {
+		if g.scope.AllowsNamespace(release.Namespace) && g.scope.AllowsRelease(release.Release) {
+			releases = append(releases, release)
+		}
+	}
+	return releases
+}
+
+func (g *scopedGraph) GetReleaseHistory(release string) []graph.HelmRevision {
+	if !g.scope.AllowsRelease(release) {
+		return nil
+	}
+	revisions := make([]graph.HelmRevision, 0)
+	for _, revision := range g.GraphInterface.GetReleaseHistory(release) {
+		if g.scope.AllowsNamespace(revision.Namespace) {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions
+}
+
+// GetApplications returns the applications with resources in scope, limited to those resources
+func (g *scopedGraph) GetApplications() []graph.Application {
+	var apps []graph.Application
+	for _, app := range g.GraphInterface.GetApplications() {
+		app.Nodes = g.filter(app.Nodes)
+		if len(app.Nodes) == 0 {
+			continue
+		}
+		namespaces := make(map[string]bool)
+		for _, node := range app.Nodes {
+			if node.Namespace != "" {
+				namespaces[node.Namespace] = true
+			}
+		}
+		app.Namespaces = make([]string, 0, len(namespaces))
+		for namespace := range namespaces {
+			app.Namespaces = append(app.Namespaces, namespace)
+		}
+		sort.Strings(app.Namespaces)
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+func (g *scopedGraph) Search(query string, limit int) []graph.SearchResult {
+	var results []graph.SearchResult
+	for _, result := range g.GraphInterface.Search(query, 0) {
+		if !g.visible(result.Node) {
+			continue
+		}
+		results = append(results, result)
+		if limit > 0 && len(results) == limit {
+			break
+		}
+	}
+	return results
+}
+
+func (g *scopedGraph) OutgoingEdges(uid types.UID) []*graph.Edge {
+	if _, visible := g.GetNode(uid); !visible {
+		return nil
+	}
+	var edges []*graph.Edge
+	for _, edge := range g.GraphInterface.OutgoingEdges(uid) {
+		if _, visible := g.GetNode(edge.ToUID); visible {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func (g *scopedGraph) IncomingEdges(uid types.UID) []*graph.Edge {
+	if _, visible := g.GetNode(uid); !visible {
+		return nil
+	}
+	var edges []*graph.Edge
+	for _, edge := range g.GraphInterface.IncomingEdges(uid) {
+		if _, visible := g.GetNode(edge.FromUID); visible {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func (g *scopedGraph) StaleEdges(cutoff time.Time) []*graph.Edge {
+	var edges []*graph.Edge
+	for _, edge := range g.GraphInterface.StaleEdges(cutoff) {
+		_, fromVisible := g.GetNode(edge.FromUID)
+		_, toVisible := g.GetNode(edge.ToUID)
+		if fromVisible && toVisible {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// Clone returns a copy of the graph holding only the nodes in scope
+func (g *scopedGraph) Clone() *graph.Graph {
+	clone := g.GraphInterface.Clone()
+	for _, node := range g.GraphInterface.GetAllNodes() {
+		if !g.visible(node) {
+			clone.RemoveNode(node.UID)
+		}
+	}
+	return clone
+}
@@ -0,0 +1,117 @@
+// Package tenancy scopes API access to namespaces and Helm releases. API tokens are bound to a
+// scope, and the API reads the graph through a view of the caller's scope (see Scope.Graph), so
+// teams can be given access to their own topology without seeing the rest of the cluster.
+package tenancy
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+)
+
+// Scope is what an API token gives access to: the resources in its namespaces that belong to
+// its releases. Namespaces and releases are path.Match patterns; an empty list allows any.
+// A nil scope is unrestricted.
+type Scope struct {
+	// Name identifies the token in logs
+	Name       string
+	Namespaces []string
+	Releases   []string
+}
+
+// Unrestricted reports whether the scope gives access to the whole graph
+func (s *Scope) Unrestricted() bool {
+	return s == nil || (len(s.Namespaces) == 0 && len(s.Releases) == 0)
+}
+
+// AllowsNamespace reports whether resources in the namespace may be in scope
+func (s *Scope) AllowsNamespace(namespace string) bool {
+	if s == nil || len(s.Namespaces) == 0 {
+		return true
+	}
+	return matchAny(s.Namespaces, namespace)
+}
+
+// AllowsRelease reports whether resources of the release may be in scope
+func (s *Scope) AllowsRelease(release string) bool {
+	if s == nil || len(s.Releases) == 0 {
+		return true
+	}
+	return release != "" && matchAny(s.Releases, release)
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Token binds an API token to a scope
+type Token struct {
+	Name  string
+	Token string
+	Scope Scope
+}
+
+// Authenticator resolves API tokens to their scope
+type Authenticator struct {
+	scopes map[[sha256.Size]byte]*Scope
+}
+
+// NewAuthenticator validates the tokens and creates an authenticator for them. Tokens are
+// kept hashed.
+func NewAuthenticator(tokens []Token) (*Authenticator, error) {
+	a := &Authenticator{scopes: make(map[[sha256.Size]byte]*Scope, len(tokens))}
+	names := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		switch {
+		case token.Name == "":
+			return nil, fmt.Errorf("API token without a name")
+		case names[token.Name]:
+			return nil, fmt.Errorf("API token %q is listed twice", token.Name)
+		case token.Token == "":
+			return nil, fmt.Errorf("API token %q is empty", token.Name)
+		}
+		names[token.Name] = true
+		for _, pattern := range append(append([]string{}, token.Scope.Namespaces...), token.Scope.Releases...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("API token %q: invalid pattern %q: %w", token.Name, pattern, err)
+			}
+		}
+
+		hash := sha256.Sum256([]byte(token.Token))
+		if _, exists := a.scopes[hash]; exists {
+			return nil, fmt.Errorf("API token %q has the same value as another token", token.Name)
+		}
+		scope := token.Scope
+		scope.Name = token.Name
+		a.scopes[hash] = &scope
+	}
+	return a, nil
+}
+
+// Authenticate returns the scope of a token, or false when the token is unknown
+func (a *Authenticator) Authenticate(token string) (*Scope, bool) {
+	if token == "" {
+		return nil, false
+	}
+	scope, exists := a.scopes[sha256.Sum256([]byte(token))]
+	return scope, exists
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context carrying the scope of the caller
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// FromContext returns the scope of the caller, nil (unrestricted) when none was set
+func FromContext(ctx context.Context) *Scope {
+	scope, _ := ctx.Value(scopeKey{}).(*Scope)
+	return scope
+}
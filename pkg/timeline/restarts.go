@@ -0,0 +1,263 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// EventRestart is Pods of a workload recreated shortly after a probable cause: a change of a
+// ConfigMap or Secret they use, or a rollout of the workload
+const EventRestart EventType = "restart"
+
+// DefaultCorrelationWindow is how long after a change recreated Pods are attributed to it
+const DefaultCorrelationWindow = 5 * time.Minute
+
+// RestartCorrelator records the recreation of Pods in the timeline when it follows a change of
+// a ConfigMap or Secret they use, or a rollout of their workload, within the window, so the
+// timeline tells why the Pods of a release restarted. Recreated Pods without a probable cause
+// are not recorded. It is a processors.ChangeObserver.
+type RestartCorrelator struct {
+	timeline *Timeline
+	graph    graph.GraphInterface
+	window   time.Duration
+
+	mu sync.Mutex
+	// changes holds when ConfigMaps and Secrets last changed
+	changes map[types.UID]time.Time
+	// rollouts holds the latest rollout of each workload, by <namespace>/<Kind>/<name>
+	rollouts map[string]rollout
+	// recorded holds when an event was last recorded for a workload and causes, so the Pods
+	// of one restart are recorded once
+	recorded  map[string]time.Time
+	lastPrune time.Time
+}
+
+type rollout struct {
+	time     time.Time
+	revision int64
+}
+
+// cause is a probable cause of a restart
+type cause struct {
+	key         string
+	time        time.Time
+	description string
+}
+
+// NewRestartCorrelator creates a correlator recording in t the restarts following a change by
+// at most window. The graph is read to find the owners and references of recreated Pods.
+func NewRestartCorrelator(t *Timeline, g graph.GraphInterface, window time.Duration) *RestartCorrelator {
+	if window <= 0 {
+		window = DefaultCorrelationWindow
+	}
+	return &RestartCorrelator{
+		timeline: t,
+		graph:    g,
+		window:   window,
+		changes:  make(map[types.UID]time.Time),
+		rollouts: make(map[string]rollout),
+		recorded: make(map[string]time.Time),
+	}
+}
+
+// NodeChanged tracks the changes of ConfigMaps, Secrets and rollout revisions, and correlates
+// new Pods with them
+func (c *RestartCorrelator) NodeChanged(old, updated *graph.Node) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+
+	if updated == nil {
+		delete(c.changes, old.UID)
+		return
+	}
+	switch updated.Kind {
+	case "ConfigMap", "Secret":
+		// Resyncs deliver the same version again
+		if old != nil && old.ResourceVersion != updated.ResourceVersion {
+			c.changes[updated.UID] = now
+		}
+	case "ReplicaSet", "ControllerRevision":
+		c.trackRollout(old, updated, now)
+	case "Pod":
+		if old == nil {
+			c.correlate(updated, now)
+		}
+	}
+}
+
+// trackRollout records a new revision of a workload: a revision created, or an earlier one
+// becoming the latest again on a rollback
+func (c *RestartCorrelator) trackRollout(old, updated *graph.Node, now time.Time) {
+	if updated.Metadata == nil || updated.Metadata.Controller == "" || updated.Metadata.Revision == 0 {
+		return
+	}
+	at := updated.CreationTimestamp
+	if old != nil {
+		if old.Metadata != nil && old.Metadata.Revision == updated.Metadata.Revision {
+			return
+		}
+		at = now
+	}
+	key := updated.Namespace + "/" + updated.Metadata.Controller
+	if previous, exists := c.rollouts[key]; exists && !at.After(previous.time) {
+		return
+	}
+	c.rollouts[key] = rollout{time: at, revision: updated.Metadata.Revision}
+}
+
+// correlate records a restart event when a new Pod follows a change it probably caused. Pods
+// created before the window, such as those listed on startup, are ignored.
+func (c *RestartCorrelator) correlate(pod *graph.Node, now time.Time) {
+	created := pod.CreationTimestamp
+	if created.IsZero() {
+		created = now
+	}
+	if now.Sub(created) > c.window {
+		return
+	}
+	workload, release := c.workload(pod)
+	if workload == nil {
+		return
+	}
+
+	var causes []cause
+	within := func(t time.Time) bool {
+		return !t.After(created) && created.Sub(t) <= c.window
+	}
+	for _, edge := range pod.OutgoingEdges {
+		if edge.Type != graph.EdgeConfigMapRef && edge.Type != graph.EdgeSecretRef {
+			continue
+		}
+		changed, exists := c.changes[edge.ToUID]
+		if !exists || !within(changed) {
+			continue
+		}
+		target, exists := c.graph.GetNode(edge.ToUID)
+		if !exists {
+			continue
+		}
+		if release == "" {
+			release = target.HelmRelease
+		}
+		causes = append(causes, cause{
+			key:         target.Kind + "/" + target.Name,
+			time:        changed,
+			description: fmt.Sprintf("%s %s changed", target.Kind, target.Name),
+		})
+	}
+	workloadRef := workload.Kind + "/" + workload.Name
+	if latest, exists := c.rollouts[workload.Namespace+"/"+workloadRef]; exists && within(latest.time) {
+		causes = append(causes, cause{
+			key:         "rollout/" + strconv.FormatInt(latest.revision, 10),
+			time:        latest.time,
+			description: fmt.Sprintf("%s %s rolled out revision %d", workload.Kind, workload.Name, latest.revision),
+		})
+	}
+	if len(causes) == 0 || release == "" {
+		return
+	}
+
+	// The most recent change is the most probable cause
+	sort.Slice(causes, func(i, j int) bool {
+		if !causes[i].time.Equal(causes[j].time) {
+			return causes[i].time.After(causes[j].time)
+		}
+		return causes[i].key < causes[j].key
+	})
+	keys := make([]string, 0, len(causes))
+	descriptions := make([]string, 0, len(causes))
+	for _, cause := range causes {
+		keys = append(keys, cause.key)
+		descriptions = append(descriptions, fmt.Sprintf("%s after %s", formatDelay(created.Sub(cause.time)), cause.description))
+	}
+	recordKey := string(workload.UID) + "|" + strings.Join(keys, ",")
+	if last, exists := c.recorded[recordKey]; exists && created.Sub(last) <= c.window {
+		return
+	}
+	c.recorded[recordKey] = created
+
+	c.timeline.Record(Event{
+		Time:      created,
+		Type:      EventRestart,
+		Release:   release,
+		Namespace: pod.Namespace,
+		Message:   fmt.Sprintf("Pods of %s %s restarted %s", workload.Kind, workload.Name, strings.Join(descriptions, " and ")),
+		Details: map[string]string{
+			"workload": workloadRef,
+			"pod":      pod.Name,
+			"causes":   strings.Join(keys, ","),
+			"delay":    formatDelay(created.Sub(causes[0].time)),
+		},
+	})
+}
+
+// workload returns the workload of a Pod, following the rollout revision it belongs to, and
+// the first release found on the way
+func (c *RestartCorrelator) workload(pod *graph.Node) (*graph.Node, string) {
+	owner := c.owner(pod)
+	if owner == nil {
+		return nil, pod.HelmRelease
+	}
+	if owner.Metadata != nil && owner.Metadata.Controller != "" {
+		if controller := c.owner(owner); controller != nil {
+			owner = controller
+		}
+	}
+	release := pod.HelmRelease
+	if release == "" {
+		release = owner.HelmRelease
+	}
+	return owner, release
+}
+
+// owner returns the node owning a node, if it is in the graph
+func (c *RestartCorrelator) owner(node *graph.Node) *graph.Node {
+	for _, edge := range node.IncomingEdges {
+		if edge.Type != graph.EdgeOwnership {
+			continue
+		}
+		if owner, exists := c.graph.GetNode(edge.FromUID); exists {
+			return owner
+		}
+	}
+	return nil
+}
+
+// prune drops the changes, rollouts and recorded events that can no longer be correlated, at
+// most once per window. Must be called with the lock held.
+func (c *RestartCorrelator) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < c.window {
+		return
+	}
+	c.lastPrune = now
+	cutoff := now.Add(-2 * c.window)
+	for uid, t := range c.changes {
+		if t.Before(cutoff) {
+			delete(c.changes, uid)
+		}
+	}
+	for key, r := range c.rollouts {
+		if r.time.Before(cutoff) {
+			delete(c.rollouts, key)
+		}
+	}
+	for key, t := range c.recorded {
+		if t.Before(cutoff) {
+			delete(c.recorded, key)
+		}
+	}
+}
+
+// formatDelay formats the delay between a change and a restart, e.g. 20s or 2m5s
+func formatDelay(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
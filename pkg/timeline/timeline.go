@@ -0,0 +1,107 @@
+// Package timeline keeps a bounded, in-memory log of notable release events, such as Helm
+// rollbacks and Pod restarts with their probable causes, so dashboards can explain what
+// happened to a release and when.
+package timeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+)
+
+// DefaultCapacity is the number of events kept when no capacity is configured
+const DefaultCapacity = 1000
+
+// EventType identifies what happened
+type EventType string
+
+const (
+	// EventRollback is a Helm release rolled back to an earlier revision
+	EventRollback EventType = "rollback"
+	// EventOrphanedUninstall is a Helm release uninstalled with resources left past the grace
+	// period
+	EventOrphanedUninstall EventType = "orphaned-uninstall"
+)
+
+// Event is an entry of the timeline
+type Event struct {
+	Time      time.Time
+	Type      EventType
+	Release   string
+	Namespace string
+	Message   string
+	// Details holds event specific values, e.g. the revisions of a rollback
+	Details map[string]string
+}
+
+// Timeline is a ring of the latest events. A nil Timeline records nothing.
+type Timeline struct {
+	mu       sync.RWMutex
+	events   []Event
+	next     int // index of the oldest event once the ring is full
+	capacity int
+}
+
+// New creates a timeline keeping the latest capacity events
+func New(capacity int) *Timeline {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Timeline{capacity: capacity}
+}
+
+// Record adds an event, dropping the oldest one when the timeline is full
+func (t *Timeline) Record(event Event) {
+	if t == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.events) < t.capacity {
+		t.events = append(t.events, event)
+	} else {
+		t.events[t.next] = event
+		t.next = (t.next + 1) % t.capacity
+	}
+	metrics.TimelineEvents.WithLabelValues(string(event.Type)).Inc()
+}
+
+// Filter selects events; empty fields match everything
+type Filter struct {
+	Release   string
+	Namespace string
+	Since     time.Time
+}
+
+// Events returns the events matching the filter, oldest first
+func (t *Timeline) Events(filter Filter) []Event {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	var events []Event
+	for _, event := range t.events {
+		if filter.Release != "" && event.Release != filter.Release {
+			continue
+		}
+		if filter.Namespace != "" && event.Namespace != filter.Namespace {
+			continue
+		}
+		if !filter.Since.IsZero() && event.Time.Before(filter.Since) {
+			continue
+		}
+		events = append(events, event)
+	}
+	t.mu.RUnlock()
+
+	// Events are recorded when processed, which is not always the order they happened in
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
@@ -0,0 +1,116 @@
+// Package tombstones keeps the last state of deleted resources for a retention window, so
+// users investigating an incident can still see what was deleted, when, and what it was
+// connected to. Tombstones are kept in memory outside the graph, which keeps name-based edge
+// resolution and the indexes limited to live resources.
+package tombstones
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Store records a tombstone for every deleted node it is told about. It is a
+// processors.ChangeObserver. A nil Store records nothing.
+type Store struct {
+	retention time.Duration
+
+	mu    sync.Mutex
+	nodes map[types.UID]*graph.Node
+	// expiry lists the tombstones in deletion order, so expired ones are dropped from its front
+	expiry []expiryEntry
+}
+
+type expiryEntry struct {
+	uid       types.UID
+	deletedAt time.Time
+}
+
+// New creates a store keeping tombstones for the retention window
+func New(retention time.Duration) *Store {
+	return &Store{
+		retention: retention,
+		nodes:     make(map[types.UID]*graph.Node),
+	}
+}
+
+// NodeChanged records a tombstone when a node is deleted, and drops it when a node with the
+// same UID is created again
+func (s *Store) NodeChanged(old, updated *graph.Node) {
+	if s == nil {
+		return
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if updated != nil {
+		if _, exists := s.nodes[updated.UID]; exists {
+			delete(s.nodes, updated.UID)
+			metrics.Tombstones.Set(float64(len(s.nodes)))
+		}
+		return
+	}
+
+	s.nodes[old.UID] = tombstone(old, now)
+	s.expiry = append(s.expiry, expiryEntry{uid: old.UID, deletedAt: now})
+	s.expire(now)
+}
+
+// tombstone copies a deleted node with its edges, which the graph no longer holds
+func tombstone(node *graph.Node, deletedAt time.Time) *graph.Node {
+	copied := *node
+	copied.DeletedAt = &deletedAt
+	copied.OutgoingEdges = make(map[types.UID]*graph.Edge, len(node.OutgoingEdges))
+	for uid, edge := range node.OutgoingEdges {
+		copied.OutgoingEdges[uid] = edge
+	}
+	copied.IncomingEdges = make(map[types.UID]*graph.Edge, len(node.IncomingEdges))
+	for uid, edge := range node.IncomingEdges {
+		copied.IncomingEdges[uid] = edge
+	}
+	return &copied
+}
+
+// expire drops the tombstones older than the retention window. Must be called with the lock
+// held.
+func (s *Store) expire(now time.Time) {
+	cutoff := now.Add(-s.retention)
+	i := 0
+	for ; i < len(s.expiry) && s.expiry[i].deletedAt.Before(cutoff); i++ {
+		entry := s.expiry[i]
+		// The node may have been re-created, and deleted again since
+		if node, exists := s.nodes[entry.uid]; exists && node.DeletedAt.Equal(entry.deletedAt) {
+			delete(s.nodes, entry.uid)
+		}
+	}
+	s.expiry = s.expiry[i:]
+	metrics.Tombstones.Set(float64(len(s.nodes)))
+}
+
+// Nodes returns the tombstones within the retention window, most recently deleted first. The
+// nodes must not be modified.
+func (s *Store) Nodes() []*graph.Node {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.expire(time.Now())
+	nodes := make([]*graph.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].DeletedAt.Equal(*nodes[j].DeletedAt) {
+			return nodes[i].DeletedAt.After(*nodes[j].DeletedAt)
+		}
+		return nodes[i].UID < nodes[j].UID
+	})
+	return nodes
+}
@@ -0,0 +1,95 @@
+// Package tracing exports OpenTelemetry spans of API requests, informer event processing and
+// Redis operations over OTLP/gRPC, to an OpenTelemetry Collector or any backend accepting OTLP
+// such as Jaeger, Tempo or the Datadog Agent. Until Setup is called, spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ammarlakis/astrolabe/pkg/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+)
+
+// instrumentationName names the tracer of the spans
+const instrumentationName = "github.com/ammarlakis/astrolabe"
+
+// serviceName is the service.name of the exported spans
+const serviceName = "astrolabe"
+
+// Options configure the export of spans
+type Options struct {
+	// Endpoint is the host:port of the OTLP/gRPC receiver
+	Endpoint string
+	// Insecure sends spans without TLS
+	Insecure bool
+	// SampleRatio is the fraction of traces recorded, unless the caller of a request decided
+	// whether to sample it
+	SampleRatio float64
+}
+
+// Setup exports spans to the OTLP receiver of opts and propagates the W3C trace context of API
+// requests. The returned function flushes the spans not yet exported and stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v must be between 0 and 1", opts.SampleRatio)
+	}
+	exporterOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOptions = append(exporterOptions, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so an unreachable receiver does not delay startup
+	exporter, err := otlptracegrpc.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version.Get()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		klog.V(2).Infof("Tracing: %v", err)
+	}))
+	klog.Infof("Exporting traces to %s (sample ratio %v)", opts.Endpoint, opts.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of Astrolabe's spans, which records nothing until Setup is called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span with attributes
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, marking it failed with err when not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
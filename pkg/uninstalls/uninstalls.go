@@ -0,0 +1,276 @@
+// Package uninstalls detects Helm releases that were uninstalled while some of their resources
+// remain, e.g. after a failed or partial helm uninstall or resources annotated with
+// helm.sh/resource-policy: keep. A release is uninstalled when its last release Secret is
+// deleted, or when its newest revision is marked uninstalled (helm uninstall --keep-history).
+// Helm deletes the resources of a release as it uninstalls it, so the resources still present
+// after a grace period are reported as leftovers for clean-up.
+package uninstalls
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ammarlakis/astrolabe/pkg/graph"
+	"github.com/ammarlakis/astrolabe/pkg/metrics"
+	"github.com/ammarlakis/astrolabe/pkg/timeline"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// States of a release, as reported by Detector.State
+const (
+	// StateInstalled is a release whose release Secrets are present
+	StateInstalled = "installed"
+	// StateUninstalling is an uninstalled release with resources left, within the grace period
+	StateUninstalling = "uninstalling"
+	// StateOrphaned is an uninstalled release with resources left past the grace period
+	StateOrphaned = "orphaned-uninstall"
+	// StateUninstalled is an uninstalled release without resources left
+	StateUninstalled = "uninstalled"
+)
+
+// helmUninstalled is the status of the revision of a release uninstalled with --keep-history
+const helmUninstalled = "uninstalled"
+
+// Key identifies a release
+type Key struct {
+	Release   string
+	Namespace string
+}
+
+// Uninstall is a release uninstalled while resources of it remained
+type Uninstall struct {
+	Key
+	// At is when the uninstall was detected
+	At time.Time
+	// Reported is set once the grace period passed with resources left
+	Reported bool
+}
+
+// revision is a release Secret of a release
+type revision struct {
+	number int
+	status string
+}
+
+// Detector tracks the release Secrets of every release and records the uninstalled releases.
+// It is a processors.ChangeObserver.
+type Detector struct {
+	graph    graph.GraphInterface
+	grace    time.Duration
+	timeline *timeline.Timeline
+
+	mu          sync.Mutex
+	revisions   map[Key]map[types.UID]revision
+	uninstalled map[Key]*Uninstall
+}
+
+// New creates a detector reporting the releases uninstalled for longer than grace with
+// resources of g left. Orphaned uninstalls are recorded in the timeline (optional).
+func New(g graph.GraphInterface, grace time.Duration, t *timeline.Timeline) *Detector {
+	return &Detector{
+		graph:       g,
+		grace:       grace,
+		timeline:    t,
+		revisions:   make(map[Key]map[types.UID]revision),
+		uninstalled: make(map[Key]*Uninstall),
+	}
+}
+
+// NodeChanged follows the release Secrets of each release
+func (d *Detector) NodeChanged(old, updated *graph.Node) {
+	node := updated
+	if node == nil {
+		node = old
+	}
+	if node.Cluster != "" || node.Metadata == nil || node.Metadata.HelmRevision == nil {
+		return
+	}
+	helmRevision := node.Metadata.HelmRevision
+	key := Key{Release: helmRevision.Release, Namespace: helmRevision.Namespace}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	revisions := d.revisions[key]
+	if updated != nil {
+		if revisions == nil {
+			revisions = make(map[types.UID]revision)
+			d.revisions[key] = revisions
+		}
+		revisions[updated.UID] = revision{number: helmRevision.Revision, status: helmRevision.Status}
+	} else {
+		delete(revisions, old.UID)
+	}
+
+	if !uninstalled(revisions) {
+		delete(d.uninstalled, key)
+		return
+	}
+	if len(revisions) == 0 {
+		delete(d.revisions, key)
+	}
+	if _, recorded := d.uninstalled[key]; !recorded {
+		d.uninstalled[key] = &Uninstall{Key: key, At: time.Now()}
+		klog.V(2).Infof("Release %s/%s was uninstalled", key.Namespace, key.Release)
+	}
+}
+
+// uninstalled reports whether the release Secrets left of a release mark it uninstalled: there
+// are none, or the newest revision is uninstalled
+func uninstalled(revisions map[types.UID]revision) bool {
+	var newest revision
+	for _, r := range revisions {
+		if r.number > newest.number {
+			newest = r
+		}
+	}
+	return len(revisions) == 0 || newest.status == helmUninstalled
+}
+
+// Start checks the uninstalled releases at a fraction of the grace period until ctx is
+// cancelled
+func (d *Detector) Start(ctx context.Context) {
+	ticker := time.NewTicker(max(d.grace/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check forgets the uninstalled releases without resources left, and reports those past the
+// grace period with resources left. It returns the number of orphaned uninstalls.
+func (d *Detector) Check() int {
+	d.mu.Lock()
+	pending := make([]Uninstall, 0, len(d.uninstalled))
+	for _, u := range d.uninstalled {
+		pending = append(pending, *u)
+	}
+	d.mu.Unlock()
+
+	now := time.Now()
+	orphaned := 0
+	for _, u := range pending {
+		leftovers := Leftovers(d.graph, u.Release, u.Namespace)
+		d.mu.Lock()
+		current, exists := d.uninstalled[u.Key]
+		switch {
+		case !exists || !current.At.Equal(u.At):
+			// Reinstalled, or uninstalled again, meanwhile
+		case len(leftovers) == 0:
+			delete(d.uninstalled, u.Key)
+		case now.Sub(u.At) >= d.grace:
+			orphaned++
+			if !current.Reported {
+				current.Reported = true
+				d.report(u, len(leftovers))
+			}
+		}
+		d.mu.Unlock()
+	}
+	metrics.OrphanedUninstalls.Set(float64(orphaned))
+	return orphaned
+}
+
+// report logs an orphaned uninstall and records it in the timeline
+func (d *Detector) report(u Uninstall, leftovers int) {
+	klog.Warningf("Release %s/%s was uninstalled %v ago but %d resource(s) of it remain",
+		u.Namespace, u.Release, time.Since(u.At).Round(time.Second), leftovers)
+	d.timeline.Record(timeline.Event{
+		Type:      timeline.EventOrphanedUninstall,
+		Release:   u.Release,
+		Namespace: u.Namespace,
+		Message:   fmt.Sprintf("Release uninstalled but %d resource(s) remain", leftovers),
+		Details:   map[string]string{"uninstalledAt": u.At.UTC().Format(time.RFC3339), "leftovers": fmt.Sprint(leftovers)},
+	})
+}
+
+// Uninstalls returns the uninstalled releases of a name that still have resources, or had
+// when last checked, by namespace
+func (d *Detector) Uninstalls(release string) []Uninstall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var uninstalls []Uninstall
+	for key, u := range d.uninstalled {
+		if key.Release == release {
+			uninstalls = append(uninstalls, *u)
+		}
+	}
+	sort.Slice(uninstalls, func(i, j int) bool { return uninstalls[i].Namespace < uninstalls[j].Namespace })
+	return uninstalls
+}
+
+// Installed reports whether release Secrets of a release are present and it is not uninstalled
+func (d *Detector) Installed(key Key) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, uninstalled := d.uninstalled[key]
+	return len(d.revisions[key]) > 0 && !uninstalled
+}
+
+// State returns the state of an uninstalled release with the given number of leftovers
+func (d *Detector) State(u Uninstall, leftovers int, now time.Time) string {
+	switch {
+	case leftovers == 0:
+		return StateUninstalled
+	case now.Sub(u.At) >= d.grace:
+		return StateOrphaned
+	default:
+		return StateUninstalling
+	}
+}
+
+// Grace returns the grace period after which an uninstalled release with resources left is
+// orphaned
+func (d *Detector) Grace() time.Duration {
+	return d.grace
+}
+
+// Leftovers returns the resources of a release in a namespace, except its release Secrets and
+// the resources owned by other resources, such as Pods, which go with their owner
+func Leftovers(g graph.GraphInterface, release, namespace string) []*graph.Node {
+	var leftovers []*graph.Node
+	for _, node := range g.GetNodesByHelmRelease(release) {
+		if node.Cluster != "" || graph.ReleaseNamespace(node) != namespace {
+			continue
+		}
+		if node.Metadata != nil && node.Metadata.HelmRevision != nil {
+			continue
+		}
+		if owned(g, node) {
+			continue
+		}
+		leftovers = append(leftovers, node)
+	}
+	sort.Slice(leftovers, func(i, j int) bool {
+		if leftovers[i].Kind != leftovers[j].Kind {
+			return leftovers[i].Kind < leftovers[j].Kind
+		}
+		if leftovers[i].Namespace != leftovers[j].Namespace {
+			return leftovers[i].Namespace < leftovers[j].Namespace
+		}
+		return leftovers[i].Name < leftovers[j].Name
+	})
+	return leftovers
+}
+
+// owned reports whether a node is owned by another node of the graph
+func owned(g graph.GraphInterface, node *graph.Node) bool {
+	for _, edge := range node.IncomingEdges {
+		if edge.Type != graph.EdgeOwnership {
+			continue
+		}
+		if _, exists := g.GetNode(edge.FromUID); exists {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+// Package version reports the version of the running build
+package version
+
+import "runtime/debug"
+
+// Version is set at build time, e.g. with
+// -ldflags "-X github.com/ammarlakis/astrolabe/pkg/version.Version=v1.2.0"
+var Version = ""
+
+// Get returns the version of the build: Version when set, else the module version or VCS
+// revision recorded by the Go toolchain, else "dev"
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return "dev-" + setting.Value[:12]
+		}
+	}
+	return "dev"
+}